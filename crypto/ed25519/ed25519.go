@@ -6,12 +6,15 @@ import (
 	"github.com/perlin-network/noise/crypto"
 )
 
+// SchemeName identifies the ed25519 signature scheme.
+const SchemeName = "ed25519"
+
 // Ed25519 represents the ed25519 cryptographic signature scheme.
 type Ed25519 struct {
 }
 
 var (
-	_ crypto.SignaturePolicy = (*Ed25519)(nil)
+	_ crypto.SchemePolicy = (*Ed25519)(nil)
 )
 
 // New returns an Ed25519 structure.
@@ -48,6 +51,11 @@ func (p *Ed25519) RandomKeyPair() *crypto.KeyPair {
 	return RandomKeyPair()
 }
 
+// Scheme returns the identifier of the ed25519 signature scheme.
+func (p *Ed25519) Scheme() string {
+	return SchemeName
+}
+
 // Sign returns an ed25519-signed message given an private key and message.
 func (p *Ed25519) Sign(privateKey []byte, message []byte) []byte {
 	if len(privateKey) != PrivateKeySize {
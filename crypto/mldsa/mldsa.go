@@ -0,0 +1,107 @@
+package mldsa
+
+import (
+	"crypto/mldsa"
+
+	"github.com/perlin-network/noise/crypto"
+)
+
+// SchemeName identifies the ML-DSA-65 signature scheme.
+const SchemeName = "ml-dsa-65"
+
+const (
+	// PrivateKeySize is the size of private keys, which are the seeds keys are
+	// expanded from.
+	PrivateKeySize = mldsa.PrivateKeySize
+	// PublicKeySize is the size of public keys.
+	PublicKeySize = mldsa.MLDSA65PublicKeySize
+	// SignatureSize is the size of signatures.
+	SignatureSize = mldsa.MLDSA65SignatureSize
+)
+
+// MLDSA represents the post-quantum ML-DSA-65 cryptographic signature scheme
+// specified in FIPS 204.
+type MLDSA struct {
+}
+
+var (
+	_ crypto.SchemePolicy = (*MLDSA)(nil)
+)
+
+// New returns an MLDSA structure.
+func New() *MLDSA {
+	return &MLDSA{}
+}
+
+// GenerateKeys generates a private and public key using the ML-DSA-65 signature scheme.
+func (p *MLDSA) GenerateKeys() ([]byte, []byte, error) {
+	privateKey, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey.Bytes(), privateKey.PublicKey().Bytes(), nil
+}
+
+// PrivateKeySize returns the private key length.
+func (p *MLDSA) PrivateKeySize() int {
+	return PrivateKeySize
+}
+
+// PrivateToPublic returns the public key given the private key.
+func (p *MLDSA) PrivateToPublic(privateKey []byte) ([]byte, error) {
+	key, err := mldsa.NewPrivateKey(mldsa.MLDSA65(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+// PublicKeySize returns the public key length.
+func (p *MLDSA) PublicKeySize() int {
+	return PublicKeySize
+}
+
+// RandomKeyPair generates a randomly seeded ML-DSA-65 key pair.
+func (p *MLDSA) RandomKeyPair() *crypto.KeyPair {
+	return RandomKeyPair()
+}
+
+// Scheme returns the identifier of the ML-DSA-65 signature scheme.
+func (p *MLDSA) Scheme() string {
+	return SchemeName
+}
+
+// Sign returns an ML-DSA-65 signature given a private key and message.
+func (p *MLDSA) Sign(privateKey []byte, message []byte) []byte {
+	key, err := mldsa.NewPrivateKey(mldsa.MLDSA65(), privateKey)
+	if err != nil {
+		return make([]byte, 0)
+	}
+
+	signature, err := key.Sign(nil, message, nil)
+	if err != nil {
+		return make([]byte, 0)
+	}
+	return signature
+}
+
+// Verify returns true if the signature was signed using the given public key and message.
+func (p *MLDSA) Verify(publicKey []byte, message []byte, signature []byte) bool {
+	key, err := mldsa.NewPublicKey(mldsa.MLDSA65(), publicKey)
+	if err != nil {
+		return false
+	}
+	return mldsa.Verify(key, message, signature, nil) == nil
+}
+
+// RandomKeyPair generates a randomly seeded ML-DSA-65 key pair.
+func RandomKeyPair() *crypto.KeyPair {
+	privateKey, publicKey, err := New().GenerateKeys()
+	if err != nil {
+		panic(err)
+	}
+	return &crypto.KeyPair{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}
+}
@@ -18,6 +18,22 @@ type SignaturePolicy interface {
 	Verify(publicKey []byte, message []byte, signature []byte) bool
 }
 
+// SchemePolicy is a SignaturePolicy which identifies its signature scheme, so
+// that signatures can be told apart from those made under other schemes.
+type SchemePolicy interface {
+	SignaturePolicy
+	Scheme() string
+}
+
+// Scheme returns the identifier of the signature scheme of a signature policy,
+// or an empty string should it not identify its scheme.
+func Scheme(sp SignaturePolicy) string {
+	if identified, ok := sp.(SchemePolicy); ok {
+		return identified.Scheme()
+	}
+	return ""
+}
+
 // HashPolicy defines how to create a cryptographic hash.
 type HashPolicy interface {
 	HashBytes(b []byte) []byte
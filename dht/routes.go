@@ -80,6 +80,8 @@ func (t *RoutingTable) Update(target peer.ID) {
 			bucket.PushFront(target)
 		}
 	} else {
+		// Keep track of the address the peer was last seen at.
+		element.Value = target
 		bucket.MoveToFront(element)
 	}
 
@@ -220,6 +222,28 @@ func (t *RoutingTable) FindClosestPeers(target peer.ID, count int) (peers []peer
 	return peers
 }
 
+// Closest returns up to count peers in the routing table with the smallest
+// XorID distance to an arbitrary key, closest first, without querying the
+// network. The node itself is left out.
+func (t *RoutingTable) Closest(key []byte, count int) []peer.ID {
+	if len(t.self.Id) != len(key) {
+		return []peer.ID{}
+	}
+
+	target := peer.ID{Id: key}
+	peers := t.GetPeers()
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].XorID(target).Less(peers[j].XorID(target))
+	})
+
+	if len(peers) > count {
+		peers = peers[:count]
+	}
+
+	return peers
+}
+
 // Bucket returns a specific Bucket by ID.
 func (t *RoutingTable) Bucket(id int) *Bucket {
 	if id >= 0 && id < len(t.buckets) {
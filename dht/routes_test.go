@@ -105,6 +105,21 @@ func TestGetPeers(t *testing.T) {
 	}
 }
 
+func TestUpdateMovedPeer(t *testing.T) {
+	t.Parallel()
+
+	routingTable := CreateRoutingTable(id1)
+	routingTable.Update(id2)
+	routingTable.Update(peer.CreateID("0003", id2.PublicKey))
+
+	tester := routingTable.GetPeerAddresses()
+	testee := []string{"0003"}
+
+	if !reflect.DeepEqual(tester, testee) {
+		t.Fatalf("update() of a moved peer failed got: %v, expected : %v", tester, testee)
+	}
+}
+
 func TestRemovePeer(t *testing.T) {
 	t.Parallel()
 
@@ -234,3 +249,46 @@ func TestRoutingTable(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestClosest(t *testing.T) {
+	t.Parallel()
+
+	routingTable := CreateRoutingTable(id1)
+
+	for i := 0; i < 64; i++ {
+		routingTable.Update(peer.CreateID(hex.EncodeToString(MustReadRand(4)), MustReadRand(32)))
+	}
+
+	key := MustReadRand(len(id1.Id))
+	target := peer.ID{Id: key}
+
+	closest := routingTable.Closest(key, 8)
+	if len(closest) != 8 {
+		t.Fatalf("len(closest) = %d, expected 8", len(closest))
+	}
+
+	for _, peer := range routingTable.GetPeers() {
+		if peer.XorID(target).Less(closest[len(closest)-1].XorID(target)) {
+			found := false
+			for _, id := range closest {
+				found = found || id.Equals(peer)
+			}
+			if !found {
+				t.Fatalf("closest() left out %v", peer)
+			}
+		}
+	}
+
+	for i := 1; i < len(closest); i++ {
+		if !closest[i-1].XorID(target).Less(closest[i].XorID(target)) {
+			t.Fatal("closest() should sort peers by distance")
+		}
+		if closest[i].Equals(id1) {
+			t.Fatal("closest() should leave out self")
+		}
+	}
+
+	if len(routingTable.Closest(key[:4], 8)) != 0 {
+		t.Fatal("closest() should reject keys of the wrong size")
+	}
+}
@@ -1,6 +1,7 @@
 package basic
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"time"
@@ -76,7 +77,13 @@ func ExampleBasicPlugin() {
 	}
 
 	// Wait for all nodes to finish discovering other peers.
-	time.Sleep(1 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for _, node := range nodes {
+		if err := node.WaitForPeers(ctx, numNodes-1); err != nil {
+			fmt.Println(err)
+		}
+	}
 
 	// Broadcast out a message from Node 0.
 	expected := "This is a broadcasted message from Node 0."
@@ -10,11 +10,24 @@
 	It has these top-level messages:
 		ID
 		Message
+		Signature
 		Ping
+		Batch
 		Pong
+		Keepalive
+		KeepaliveAck
 		LookupNodeRequest
 		LookupNodeResponse
+		CompactPeers
 		Bytes
+		HandshakeOffer
+		HandshakeMetadata
+		Handshake
+		PeerRecord
+		PeerBundle
+		SignedPeerBundle
+		ServiceRecord
+		ServiceRecords
 */
 package protobuf
 
@@ -28,6 +41,7 @@ import bytes "bytes"
 
 import strings "strings"
 import reflect "reflect"
+import sortkeys "github.com/gogo/protobuf/sortkeys"
 
 import io "io"
 
@@ -43,9 +57,12 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 
 type ID struct {
+	// public_key of the peer (we no longer use the public key as the peer ID, but use it to verify messages)
 	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
-	Address   string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
-	Id        []byte `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	// address is the network address of the peer
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// id is the computed hash of the public key
+	Id []byte `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
 }
 
 func (m *ID) Reset()                    { *m = ID{} }
@@ -85,6 +102,41 @@ type Message struct {
 	MessageNonce uint64 `protobuf:"varint,5,opt,name=message_nonce,json=messageNonce,proto3" json:"message_nonce,omitempty"`
 	// reply_flag indicates this is a reply to a request
 	ReplyFlag bool `protobuf:"varint,6,opt,name=reply_flag,json=replyFlag,proto3" json:"reply_flag,omitempty"`
+	// metadata holds application-defined key/value pairs covered by the sender's signature.
+	Metadata map[string]string `protobuf:"bytes,7,rep,name=metadata" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// signatures holds the sender's signatures under additional signature
+	// schemes, attached while migrating from one scheme to another.
+	Signatures []*Signature `protobuf:"bytes,8,rep,name=signatures" json:"signatures,omitempty"`
+	// critical_extensions lists the extensions whose fields a receiver must
+	// understand to handle the message, covered by the sender's signature.
+	CriticalExtensions []uint32 `protobuf:"varint,9,rep,packed,name=critical_extensions,json=criticalExtensions" json:"critical_extensions,omitempty"`
+	// protocol is the tag of the application protocol the message belongs
+	// to, being empty for the default one. Covered by the sender's signature.
+	Protocol string `protobuf:"bytes,10,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	// budget_ms is how long, in milliseconds, the sender of a request is
+	// willing to wait for its reply. It is a hint to the receiver's handlers,
+	// and is not covered by the sender's signature.
+	BudgetMs uint64 `protobuf:"varint,11,opt,name=budget_ms,json=budgetMs,proto3" json:"budget_ms,omitempty"`
+	// hints are small application-level routing hints relays may read
+	// without decoding the message. Covered by the sender's signature.
+	Hints []*Hint `protobuf:"bytes,12,rep,name=hints" json:"hints,omitempty"`
+	// padding is filler appended to hide the size of the message, stripped
+	// by the receiver before the message is handled. It is not covered by
+	// the sender's signature.
+	Padding []byte `protobuf:"bytes,13,opt,name=padding,proto3" json:"padding,omitempty"`
+	// replay_nonce increases with every envelope the sender signs, so that
+	// receivers may drop envelopes replayed to them. Covered by the sender's
+	// signature.
+	ReplayNonce uint64 `protobuf:"varint,14,opt,name=replay_nonce,json=replayNonce,proto3" json:"replay_nonce,omitempty"`
+	// signature_scheme identifies the scheme the sender signed the message
+	// under, and is absent should it be ed25519. Covered by the sender's
+	// signature.
+	SignatureScheme string `protobuf:"bytes,15,opt,name=signature_scheme,json=signatureScheme,proto3" json:"signature_scheme,omitempty"`
+	// replay_stamp_ms is the time, in milliseconds since the Unix epoch, the
+	// sender stamped the replay nonce at, so that receivers may drop envelopes
+	// too old to hold a window of nonces for. Covered by the sender's
+	// signature.
+	ReplayStampMs uint64 `protobuf:"varint,16,opt,name=replay_stamp_ms,json=replayStampMs,proto3" json:"replay_stamp_ms,omitempty"`
 }
 
 func (m *Message) Reset()                    { *m = Message{} }
@@ -133,27 +185,183 @@ func (m *Message) GetReplyFlag() bool {
 	return false
 }
 
+func (m *Message) GetMetadata() map[string]string {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *Message) GetSignatures() []*Signature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+func (m *Message) GetCriticalExtensions() []uint32 {
+	if m != nil {
+		return m.CriticalExtensions
+	}
+	return nil
+}
+
+func (m *Message) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *Message) GetBudgetMs() uint64 {
+	if m != nil {
+		return m.BudgetMs
+	}
+	return 0
+}
+
+func (m *Message) GetHints() []*Hint {
+	if m != nil {
+		return m.Hints
+	}
+	return nil
+}
+
+func (m *Message) GetPadding() []byte {
+	if m != nil {
+		return m.Padding
+	}
+	return nil
+}
+
+func (m *Message) GetReplayNonce() uint64 {
+	if m != nil {
+		return m.ReplayNonce
+	}
+	return 0
+}
+
+func (m *Message) GetSignatureScheme() string {
+	if m != nil {
+		return m.SignatureScheme
+	}
+	return ""
+}
+
+func (m *Message) GetReplayStampMs() uint64 {
+	if m != nil {
+		return m.ReplayStampMs
+	}
+	return 0
+}
+
+// Signature is a signature of a message under a named signature scheme.
+type Signature struct {
+	Scheme string `protobuf:"bytes,1,opt,name=scheme,proto3" json:"scheme,omitempty"`
+	// public_key is the sender's public key under the scheme, covered by the sender's signature.
+	PublicKey []byte `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *Signature) Reset()                    { *m = Signature{} }
+func (*Signature) ProtoMessage()               {}
+func (*Signature) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{2} }
+
+func (m *Signature) GetScheme() string {
+	if m != nil {
+		return m.Scheme
+	}
+	return ""
+}
+
+func (m *Signature) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
+}
+
+func (m *Signature) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 type Ping struct {
 }
 
 func (m *Ping) Reset()                    { *m = Ping{} }
 func (*Ping) ProtoMessage()               {}
-func (*Ping) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{2} }
+func (*Ping) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{3} }
+
+// Batch carries multiple application payloads from the same sender under a
+// single envelope and signature.
+type Batch struct {
+	Payloads []*google_protobuf.Any `protobuf:"bytes,1,rep,name=payloads" json:"payloads,omitempty"`
+}
+
+func (m *Batch) Reset()                    { *m = Batch{} }
+func (*Batch) ProtoMessage()               {}
+func (*Batch) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{4} }
+
+func (m *Batch) GetPayloads() []*google_protobuf.Any {
+	if m != nil {
+		return m.Payloads
+	}
+	return nil
+}
 
 type Pong struct {
 }
 
 func (m *Pong) Reset()                    { *m = Pong{} }
 func (*Pong) ProtoMessage()               {}
-func (*Pong) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{3} }
+func (*Pong) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{5} }
+
+// Keepalive probes a silent peer for liveness, and is answered by the network
+// itself with a KeepaliveAck.
+type Keepalive struct {
+	// payload is a small application payload piggybacking on the keepalive,
+	// only sent to peers advertising they consume them.
+	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *Keepalive) Reset()                    { *m = Keepalive{} }
+func (*Keepalive) ProtoMessage()               {}
+func (*Keepalive) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{6} }
+
+func (m *Keepalive) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+type KeepaliveAck struct {
+	Payload []byte `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *KeepaliveAck) Reset()                    { *m = KeepaliveAck{} }
+func (*KeepaliveAck) ProtoMessage()               {}
+func (*KeepaliveAck) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{7} }
+
+func (m *KeepaliveAck) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
 
 type LookupNodeRequest struct {
 	Target *ID `protobuf:"bytes,1,opt,name=target" json:"target,omitempty"`
+	// known holds 4-byte prefixes of the IDs of peers the requester already knows, which may be left out of the response.
+	Known []byte `protobuf:"bytes,2,opt,name=known,proto3" json:"known,omitempty"`
 }
 
 func (m *LookupNodeRequest) Reset()                    { *m = LookupNodeRequest{} }
 func (*LookupNodeRequest) ProtoMessage()               {}
-func (*LookupNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{4} }
+func (*LookupNodeRequest) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{8} }
 
 func (m *LookupNodeRequest) GetTarget() *ID {
 	if m != nil {
@@ -162,13 +370,22 @@ func (m *LookupNodeRequest) GetTarget() *ID {
 	return nil
 }
 
+func (m *LookupNodeRequest) GetKnown() []byte {
+	if m != nil {
+		return m.Known
+	}
+	return nil
+}
+
 type LookupNodeResponse struct {
 	Peers []*ID `protobuf:"bytes,1,rep,name=peers" json:"peers,omitempty"`
+	// compact holds the peers instead for requesters which advertise support for it.
+	Compact *CompactPeers `protobuf:"bytes,2,opt,name=compact" json:"compact,omitempty"`
 }
 
 func (m *LookupNodeResponse) Reset()                    { *m = LookupNodeResponse{} }
 func (*LookupNodeResponse) ProtoMessage()               {}
-func (*LookupNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{5} }
+func (*LookupNodeResponse) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{9} }
 
 func (m *LookupNodeResponse) GetPeers() []*ID {
 	if m != nil {
@@ -177,13 +394,71 @@ func (m *LookupNodeResponse) GetPeers() []*ID {
 	return nil
 }
 
+func (m *LookupNodeResponse) GetCompact() *CompactPeers {
+	if m != nil {
+		return m.Compact
+	}
+	return nil
+}
+
+// CompactPeers is a compact encoding of a list of peers.
+type CompactPeers struct {
+	// public_keys holds the fixed-width public keys of all peers back to back.
+	PublicKeys []byte `protobuf:"bytes,1,opt,name=public_keys,json=publicKeys,proto3" json:"public_keys,omitempty"`
+	KeySize    uint32 `protobuf:"varint,2,opt,name=key_size,json=keySize,proto3" json:"key_size,omitempty"`
+	// prefixes holds the distinct addresses of all peers, excluding their ports.
+	Prefixes []string `protobuf:"bytes,3,rep,name=prefixes" json:"prefixes,omitempty"`
+	// prefix_indices and ports hold the address of each peer.
+	PrefixIndices []uint32 `protobuf:"varint,4,rep,packed,name=prefix_indices,json=prefixIndices" json:"prefix_indices,omitempty"`
+	Ports         []uint32 `protobuf:"varint,5,rep,packed,name=ports" json:"ports,omitempty"`
+}
+
+func (m *CompactPeers) Reset()                    { *m = CompactPeers{} }
+func (*CompactPeers) ProtoMessage()               {}
+func (*CompactPeers) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{10} }
+
+func (m *CompactPeers) GetPublicKeys() []byte {
+	if m != nil {
+		return m.PublicKeys
+	}
+	return nil
+}
+
+func (m *CompactPeers) GetKeySize() uint32 {
+	if m != nil {
+		return m.KeySize
+	}
+	return 0
+}
+
+func (m *CompactPeers) GetPrefixes() []string {
+	if m != nil {
+		return m.Prefixes
+	}
+	return nil
+}
+
+func (m *CompactPeers) GetPrefixIndices() []uint32 {
+	if m != nil {
+		return m.PrefixIndices
+	}
+	return nil
+}
+
+func (m *CompactPeers) GetPorts() []uint32 {
+	if m != nil {
+		return m.Ports
+	}
+	return nil
+}
+
 type Bytes struct {
 	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 }
 
 func (m *Bytes) Reset()                    { *m = Bytes{} }
 func (*Bytes) ProtoMessage()               {}
-func (*Bytes) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{6} }
+func (*Bytes) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{11} }
 
 func (m *Bytes) GetData() []byte {
 	if m != nil {
@@ -192,937 +467,14364 @@ func (m *Bytes) GetData() []byte {
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*ID)(nil), "protobuf.ID")
-	proto.RegisterType((*Message)(nil), "protobuf.Message")
-	proto.RegisterType((*Ping)(nil), "protobuf.Ping")
-	proto.RegisterType((*Pong)(nil), "protobuf.Pong")
-	proto.RegisterType((*LookupNodeRequest)(nil), "protobuf.LookupNodeRequest")
-	proto.RegisterType((*LookupNodeResponse)(nil), "protobuf.LookupNodeResponse")
-	proto.RegisterType((*Bytes)(nil), "protobuf.Bytes")
+// HandshakeOffer lists the protocol versions and capabilities a node supports, in order of preference.
+type HandshakeOffer struct {
+	Versions     []string `protobuf:"bytes,1,rep,name=versions" json:"versions,omitempty"`
+	Capabilities []string `protobuf:"bytes,2,rep,name=capabilities" json:"capabilities,omitempty"`
+	// metadata holds application-defined key/value pairs describing the sender, sorted by key.
+	Metadata []*HandshakeMetadata `protobuf:"bytes,3,rep,name=metadata" json:"metadata,omitempty"`
+	// services holds the service records the sender advertises.
+	Services *ServiceRecords `protobuf:"bytes,4,opt,name=services" json:"services,omitempty"`
 }
-func (this *ID) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+func (m *HandshakeOffer) Reset()                    { *m = HandshakeOffer{} }
+func (*HandshakeOffer) ProtoMessage()               {}
+func (*HandshakeOffer) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{12} }
+
+func (m *HandshakeOffer) GetVersions() []string {
+	if m != nil {
+		return m.Versions
 	}
+	return nil
+}
 
-	that1, ok := that.(*ID)
-	if !ok {
-		that2, ok := that.(ID)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *ID")
-		}
+func (m *HandshakeOffer) GetCapabilities() []string {
+	if m != nil {
+		return m.Capabilities
 	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *ID but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *ID but is not nil && this == nil")
+	return nil
+}
+
+func (m *HandshakeOffer) GetMetadata() []*HandshakeMetadata {
+	if m != nil {
+		return m.Metadata
 	}
-	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
-		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	return nil
+}
+
+func (m *HandshakeOffer) GetServices() *ServiceRecords {
+	if m != nil {
+		return m.Services
 	}
-	if this.Address != that1.Address {
-		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	return nil
+}
+
+type HandshakeMetadata struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *HandshakeMetadata) Reset()                    { *m = HandshakeMetadata{} }
+func (*HandshakeMetadata) ProtoMessage()               {}
+func (*HandshakeMetadata) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{13} }
+
+func (m *HandshakeMetadata) GetKey() string {
+	if m != nil {
+		return m.Key
 	}
-	if !bytes.Equal(this.Id, that1.Id) {
-		return fmt.Errorf("Id this(%v) Not Equal that(%v)", this.Id, that1.Id)
+	return ""
+}
+
+func (m *HandshakeMetadata) GetValue() []byte {
+	if m != nil {
+		return m.Value
 	}
 	return nil
 }
-func (this *ID) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
+
+// Handshake is exchanged over every new connection before any messages may be sent.
+type Handshake struct {
+	// Sender's address and public key.
+	Sender *ID `protobuf:"bytes,1,opt,name=sender" json:"sender,omitempty"`
+	// offer is the set of versions and capabilities the sender supports.
+	Offer *HandshakeOffer `protobuf:"bytes,2,opt,name=offer" json:"offer,omitempty"`
+	// echo is the offer the sender received from the remote side, so that a stripped or modified offer is detected.
+	Echo *HandshakeOffer `protobuf:"bytes,3,opt,name=echo" json:"echo,omitempty"`
+	// Sender's signature over all other fields of the handshake.
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	// session_token is presented by a dialer to resume a prior session, or issued by an acceptor for a future one.
+	SessionToken []byte `protobuf:"bytes,5,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+	// resumed is set by an acceptor which accepted the presented session token.
+	Resumed bool `protobuf:"varint,6,opt,name=resumed,proto3" json:"resumed,omitempty"`
+	// control is set by a dialer opening a connection reserved for control messages to a peer it is already connected to.
+	Control bool `protobuf:"varint,7,opt,name=control,proto3" json:"control,omitempty"`
+	// affinity is handed by an acceptor to a dialer, so that the dialer reconnects to it directly.
+	Affinity *Affinity `protobuf:"bytes,8,opt,name=affinity" json:"affinity,omitempty"`
+	// affinity_token is echoed by a dialer holding an affinity token issued for the address it dialed.
+	AffinityToken []byte `protobuf:"bytes,9,opt,name=affinity_token,json=affinityToken,proto3" json:"affinity_token,omitempty"`
+	// puzzle is handed by an acceptor under load to a dialer, which must solve it for the handshake to continue.
+	Puzzle *HandshakePuzzle `protobuf:"bytes,10,opt,name=puzzle" json:"puzzle,omitempty"`
+	// puzzle_solution is sent by a dialer alongside its final step, solving the puzzle it was handed.
+	PuzzleSolution []byte `protobuf:"bytes,11,opt,name=puzzle_solution,json=puzzleSolution,proto3" json:"puzzle_solution,omitempty"`
+	// ephemeral_key is the X25519 public key a sender encrypting the connection derives its session keys from.
+	EphemeralKey []byte `protobuf:"bytes,12,opt,name=ephemeral_key,json=ephemeralKey,proto3" json:"ephemeral_key,omitempty"`
+	// signature_scheme identifies the scheme the sender signed the handshake step under, and is absent should it be ed25519.
+	SignatureScheme string `protobuf:"bytes,13,opt,name=signature_scheme,json=signatureScheme,proto3" json:"signature_scheme,omitempty"`
+	// ratchet_records is how many records a sender encrypting the connection has each key seal at most before it is ratcheted, and is absent should it not bound them.
+	RatchetRecords uint64 `protobuf:"varint,14,opt,name=ratchet_records,json=ratchetRecords,proto3" json:"ratchet_records,omitempty"`
+	// ratchet_interval_ms is how long a sender encrypting the connection has each key seal records for at most before it is ratcheted, and is absent should it not bound them.
+	RatchetIntervalMs uint64 `protobuf:"varint,15,opt,name=ratchet_interval_ms,json=ratchetIntervalMs,proto3" json:"ratchet_interval_ms,omitempty"`
+}
+
+func (m *Handshake) Reset()                    { *m = Handshake{} }
+func (*Handshake) ProtoMessage()               {}
+func (*Handshake) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{14} }
+
+func (m *Handshake) GetSender() *ID {
+	if m != nil {
+		return m.Sender
 	}
+	return nil
+}
 
-	that1, ok := that.(*ID)
-	if !ok {
-		that2, ok := that.(ID)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
+func (m *Handshake) GetOffer() *HandshakeOffer {
+	if m != nil {
+		return m.Offer
 	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetEcho() *HandshakeOffer {
+	if m != nil {
+		return m.Echo
 	}
-	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
 	}
-	if this.Address != that1.Address {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetSessionToken() []byte {
+	if m != nil {
+		return m.SessionToken
 	}
-	if !bytes.Equal(this.Id, that1.Id) {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetResumed() bool {
+	if m != nil {
+		return m.Resumed
 	}
-	return true
+	return false
 }
-func (this *Message) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+func (m *Handshake) GetControl() bool {
+	if m != nil {
+		return m.Control
 	}
+	return false
+}
 
-	that1, ok := that.(*Message)
-	if !ok {
-		that2, ok := that.(Message)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *Message")
-		}
-	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *Message but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *Message but is not nil && this == nil")
-	}
-	if !this.Message.Equal(that1.Message) {
-		return fmt.Errorf("Message this(%v) Not Equal that(%v)", this.Message, that1.Message)
-	}
-	if !this.Sender.Equal(that1.Sender) {
-		return fmt.Errorf("Sender this(%v) Not Equal that(%v)", this.Sender, that1.Sender)
-	}
-	if !bytes.Equal(this.Signature, that1.Signature) {
-		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
-	}
-	if this.RequestNonce != that1.RequestNonce {
-		return fmt.Errorf("RequestNonce this(%v) Not Equal that(%v)", this.RequestNonce, that1.RequestNonce)
-	}
-	if this.MessageNonce != that1.MessageNonce {
-		return fmt.Errorf("MessageNonce this(%v) Not Equal that(%v)", this.MessageNonce, that1.MessageNonce)
-	}
-	if this.ReplyFlag != that1.ReplyFlag {
-		return fmt.Errorf("ReplyFlag this(%v) Not Equal that(%v)", this.ReplyFlag, that1.ReplyFlag)
+func (m *Handshake) GetAffinity() *Affinity {
+	if m != nil {
+		return m.Affinity
 	}
 	return nil
 }
-func (this *Message) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
-	}
 
-	that1, ok := that.(*Message)
-	if !ok {
-		that2, ok := that.(Message)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
+func (m *Handshake) GetAffinityToken() []byte {
+	if m != nil {
+		return m.AffinityToken
 	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetPuzzle() *HandshakePuzzle {
+	if m != nil {
+		return m.Puzzle
 	}
-	if !this.Message.Equal(that1.Message) {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetPuzzleSolution() []byte {
+	if m != nil {
+		return m.PuzzleSolution
 	}
-	if !this.Sender.Equal(that1.Sender) {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetEphemeralKey() []byte {
+	if m != nil {
+		return m.EphemeralKey
 	}
-	if !bytes.Equal(this.Signature, that1.Signature) {
-		return false
+	return nil
+}
+
+func (m *Handshake) GetSignatureScheme() string {
+	if m != nil {
+		return m.SignatureScheme
 	}
-	if this.RequestNonce != that1.RequestNonce {
-		return false
+	return ""
+}
+
+func (m *Handshake) GetRatchetRecords() uint64 {
+	if m != nil {
+		return m.RatchetRecords
 	}
-	if this.MessageNonce != that1.MessageNonce {
-		return false
+	return 0
+}
+
+func (m *Handshake) GetRatchetIntervalMs() uint64 {
+	if m != nil {
+		return m.RatchetIntervalMs
 	}
-	if this.ReplyFlag != that1.ReplyFlag {
-		return false
+	return 0
+}
+
+// PeerRecord describes a peer handed out in a peer bundle.
+type PeerRecord struct {
+	PublicKey []byte   `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Addresses []string `protobuf:"bytes,2,rep,name=addresses" json:"addresses,omitempty"`
+	// last_seen is when the exporting node last saw the peer, in Unix nanoseconds.
+	LastSeen int64    `protobuf:"varint,3,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	Tags     []string `protobuf:"bytes,4,rep,name=tags" json:"tags,omitempty"`
+	// last_connected is when the exporting node last dialed the peer, in Unix nanoseconds.
+	LastConnected int64 `protobuf:"varint,5,opt,name=last_connected,json=lastConnected,proto3" json:"last_connected,omitempty"`
+	// connects and failures count the dials to the peer which succeeded and failed.
+	Connects uint64 `protobuf:"varint,6,opt,name=connects,proto3" json:"connects,omitempty"`
+	Failures uint64 `protobuf:"varint,7,opt,name=failures,proto3" json:"failures,omitempty"`
+	// failure_streak is the number of dials which failed since the peer was last connected.
+	FailureStreak uint64 `protobuf:"varint,8,opt,name=failure_streak,json=failureStreak,proto3" json:"failure_streak,omitempty"`
+	// rtt is how long the latest successful dial to the peer took, in nanoseconds.
+	Rtt int64 `protobuf:"varint,9,opt,name=rtt,proto3" json:"rtt,omitempty"`
+}
+
+func (m *PeerRecord) Reset()                    { *m = PeerRecord{} }
+func (*PeerRecord) ProtoMessage()               {}
+func (*PeerRecord) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{15} }
+
+func (m *PeerRecord) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
 	}
-	return true
+	return nil
 }
-func (this *Ping) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+func (m *PeerRecord) GetAddresses() []string {
+	if m != nil {
+		return m.Addresses
 	}
+	return nil
+}
 
-	that1, ok := that.(*Ping)
-	if !ok {
-		that2, ok := that.(Ping)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *Ping")
-		}
+func (m *PeerRecord) GetLastSeen() int64 {
+	if m != nil {
+		return m.LastSeen
 	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *Ping but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *Ping but is not nil && this == nil")
+	return 0
+}
+
+func (m *PeerRecord) GetTags() []string {
+	if m != nil {
+		return m.Tags
 	}
 	return nil
 }
-func (this *Ping) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
+
+func (m *PeerRecord) GetLastConnected() int64 {
+	if m != nil {
+		return m.LastConnected
 	}
+	return 0
+}
 
-	that1, ok := that.(*Ping)
-	if !ok {
-		that2, ok := that.(Ping)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
+func (m *PeerRecord) GetConnects() uint64 {
+	if m != nil {
+		return m.Connects
 	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+	return 0
+}
+
+func (m *PeerRecord) GetFailures() uint64 {
+	if m != nil {
+		return m.Failures
 	}
-	return true
+	return 0
 }
-func (this *Pong) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+func (m *PeerRecord) GetFailureStreak() uint64 {
+	if m != nil {
+		return m.FailureStreak
 	}
+	return 0
+}
 
-	that1, ok := that.(*Pong)
-	if !ok {
-		that2, ok := that.(Pong)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *Pong")
-		}
+func (m *PeerRecord) GetRtt() int64 {
+	if m != nil {
+		return m.Rtt
 	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *Pong but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *Pong but is not nil && this == nil")
+	return 0
+}
+
+// PeerBundle is a curated list of peers, valid for max_age nanoseconds past
+// created_at in Unix nanoseconds.
+type PeerBundle struct {
+	Records   []*PeerRecord `protobuf:"bytes,1,rep,name=records" json:"records,omitempty"`
+	CreatedAt int64         `protobuf:"varint,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	MaxAge    int64         `protobuf:"varint,3,opt,name=max_age,json=maxAge,proto3" json:"max_age,omitempty"`
+}
+
+func (m *PeerBundle) Reset()                    { *m = PeerBundle{} }
+func (*PeerBundle) ProtoMessage()               {}
+func (*PeerBundle) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{16} }
+
+func (m *PeerBundle) GetRecords() []*PeerRecord {
+	if m != nil {
+		return m.Records
 	}
 	return nil
 }
-func (this *Pong) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
-	}
 
-	that1, ok := that.(*Pong)
-	if !ok {
-		that2, ok := that.(Pong)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
-	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+func (m *PeerBundle) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
 	}
-	return true
+	return 0
 }
-func (this *LookupNodeRequest) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+func (m *PeerBundle) GetMaxAge() int64 {
+	if m != nil {
+		return m.MaxAge
 	}
+	return 0
+}
 
-	that1, ok := that.(*LookupNodeRequest)
-	if !ok {
-		that2, ok := that.(LookupNodeRequest)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *LookupNodeRequest")
-		}
+// SignedPeerBundle is a serialized PeerBundle signed by the exporting node.
+type SignedPeerBundle struct {
+	Bundle    []byte `protobuf:"bytes,1,opt,name=bundle,proto3" json:"bundle,omitempty"`
+	Signer    []byte `protobuf:"bytes,2,opt,name=signer,proto3" json:"signer,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *SignedPeerBundle) Reset()                    { *m = SignedPeerBundle{} }
+func (*SignedPeerBundle) ProtoMessage()               {}
+func (*SignedPeerBundle) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{17} }
+
+func (m *SignedPeerBundle) GetBundle() []byte {
+	if m != nil {
+		return m.Bundle
 	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *LookupNodeRequest but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *LookupNodeRequest but is not nil && this == nil")
+	return nil
+}
+
+func (m *SignedPeerBundle) GetSigner() []byte {
+	if m != nil {
+		return m.Signer
 	}
-	if !this.Target.Equal(that1.Target) {
-		return fmt.Errorf("Target this(%v) Not Equal that(%v)", this.Target, that1.Target)
+	return nil
+}
+
+func (m *SignedPeerBundle) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
 	}
 	return nil
 }
-func (this *LookupNodeRequest) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
+
+// ServiceRecord advertises a named auxiliary service a node offers, such as an
+// HTTP API.
+type ServiceRecord struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// metadata holds small application-defined key/value pairs describing the service, sorted by key.
+	Metadata []*HandshakeMetadata `protobuf:"bytes,3,rep,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *ServiceRecord) Reset()                    { *m = ServiceRecord{} }
+func (*ServiceRecord) ProtoMessage()               {}
+func (*ServiceRecord) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{18} }
+
+func (m *ServiceRecord) GetName() string {
+	if m != nil {
+		return m.Name
 	}
+	return ""
+}
 
-	that1, ok := that.(*LookupNodeRequest)
-	if !ok {
-		that2, ok := that.(LookupNodeRequest)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
-	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
-	}
-	if !this.Target.Equal(that1.Target) {
-		return false
+func (m *ServiceRecord) GetAddress() string {
+	if m != nil {
+		return m.Address
 	}
-	return true
+	return ""
 }
-func (this *LookupNodeResponse) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
-	}
 
-	that1, ok := that.(*LookupNodeResponse)
-	if !ok {
-		that2, ok := that.(LookupNodeResponse)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *LookupNodeResponse")
-		}
-	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *LookupNodeResponse but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *LookupNodeResponse but is not nil && this == nil")
-	}
-	if len(this.Peers) != len(that1.Peers) {
-		return fmt.Errorf("Peers this(%v) Not Equal that(%v)", len(this.Peers), len(that1.Peers))
+func (m *ServiceRecord) GetMetadata() []*HandshakeMetadata {
+	if m != nil {
+		return m.Metadata
 	}
-	for i := range this.Peers {
-		if !this.Peers[i].Equal(that1.Peers[i]) {
-			return fmt.Errorf("Peers this[%v](%v) Not Equal that[%v](%v)", i, this.Peers[i], i, that1.Peers[i])
-		}
+	return nil
+}
+
+// ServiceRecords replaces every service record a node advertised before,
+// unless version is not greater than that of the records held already.
+type ServiceRecords struct {
+	Records []*ServiceRecord `protobuf:"bytes,1,rep,name=records" json:"records,omitempty"`
+	Version uint64           `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (m *ServiceRecords) Reset()                    { *m = ServiceRecords{} }
+func (*ServiceRecords) ProtoMessage()               {}
+func (*ServiceRecords) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{19} }
+
+func (m *ServiceRecords) GetRecords() []*ServiceRecord {
+	if m != nil {
+		return m.Records
 	}
 	return nil
 }
-func (this *LookupNodeResponse) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
+
+func (m *ServiceRecords) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
 	}
+	return 0
+}
 
-	that1, ok := that.(*LookupNodeResponse)
-	if !ok {
-		that2, ok := that.(LookupNodeResponse)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
+// Rejection notifies a peer that a message it sent was dropped, and why.
+type Rejection struct {
+	RequestNonce uint64 `protobuf:"varint,1,opt,name=request_nonce,json=requestNonce,proto3" json:"request_nonce,omitempty"`
+	MessageNonce uint64 `protobuf:"varint,2,opt,name=message_nonce,json=messageNonce,proto3" json:"message_nonce,omitempty"`
+	Hash         []byte `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+	Reason       uint32 `protobuf:"varint,4,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (m *Rejection) Reset()                    { *m = Rejection{} }
+func (*Rejection) ProtoMessage()               {}
+func (*Rejection) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{20} }
+
+func (m *Rejection) GetRequestNonce() uint64 {
+	if m != nil {
+		return m.RequestNonce
 	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+	return 0
+}
+
+func (m *Rejection) GetMessageNonce() uint64 {
+	if m != nil {
+		return m.MessageNonce
 	}
-	if len(this.Peers) != len(that1.Peers) {
-		return false
+	return 0
+}
+
+func (m *Rejection) GetHash() []byte {
+	if m != nil {
+		return m.Hash
 	}
-	for i := range this.Peers {
-		if !this.Peers[i].Equal(that1.Peers[i]) {
-			return false
-		}
+	return nil
+}
+
+func (m *Rejection) GetReason() uint32 {
+	if m != nil {
+		return m.Reason
 	}
-	return true
+	return 0
 }
-func (this *Bytes) VerboseEqual(that interface{}) error {
-	if that == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that == nil && this != nil")
+
+// Routed carries a message toward the peer closest to a key, every node on
+// the way forwarding it to its connected peer closest to the key.
+type Routed struct {
+	Key     []byte               `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Hops    uint32               `protobuf:"varint,2,opt,name=hops,proto3" json:"hops,omitempty"`
+	MaxHops uint32               `protobuf:"varint,3,opt,name=max_hops,json=maxHops,proto3" json:"max_hops,omitempty"`
+	Message *google_protobuf.Any `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *Routed) Reset()                    { *m = Routed{} }
+func (*Routed) ProtoMessage()               {}
+func (*Routed) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{21} }
+
+func (m *Routed) GetKey() []byte {
+	if m != nil {
+		return m.Key
 	}
+	return nil
+}
 
-	that1, ok := that.(*Bytes)
-	if !ok {
-		that2, ok := that.(Bytes)
-		if ok {
-			that1 = &that2
-		} else {
-			return fmt.Errorf("that is not of type *Bytes")
-		}
+func (m *Routed) GetHops() uint32 {
+	if m != nil {
+		return m.Hops
 	}
-	if that1 == nil {
-		if this == nil {
-			return nil
-		}
-		return fmt.Errorf("that is type *Bytes but is nil && this != nil")
-	} else if this == nil {
-		return fmt.Errorf("that is type *Bytes but is not nil && this == nil")
+	return 0
+}
+
+func (m *Routed) GetMaxHops() uint32 {
+	if m != nil {
+		return m.MaxHops
 	}
-	if !bytes.Equal(this.Data, that1.Data) {
-		return fmt.Errorf("Data this(%v) Not Equal that(%v)", this.Data, that1.Data)
+	return 0
+}
+
+func (m *Routed) GetMessage() *google_protobuf.Any {
+	if m != nil {
+		return m.Message
 	}
 	return nil
 }
-func (this *Bytes) Equal(that interface{}) bool {
-	if that == nil {
-		return this == nil
+
+// Hint is an application-level routing hint carried by a message envelope.
+type Hint struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Hint) Reset()                    { *m = Hint{} }
+func (*Hint) ProtoMessage()               {}
+func (*Hint) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{22} }
+
+func (m *Hint) GetKey() string {
+	if m != nil {
+		return m.Key
 	}
+	return ""
+}
 
-	that1, ok := that.(*Bytes)
-	if !ok {
-		that2, ok := that.(Bytes)
-		if ok {
-			that1 = &that2
-		} else {
-			return false
-		}
+func (m *Hint) GetValue() []byte {
+	if m != nil {
+		return m.Value
 	}
-	if that1 == nil {
-		return this == nil
-	} else if this == nil {
-		return false
+	return nil
+}
+
+// ReachabilityProbe asks a peer to dial back the address the sender
+// advertises, and report whether it answered there.
+type ReachabilityProbe struct {
+}
+
+func (m *ReachabilityProbe) Reset()                    { *m = ReachabilityProbe{} }
+func (*ReachabilityProbe) ProtoMessage()               {}
+func (*ReachabilityProbe) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{23} }
+
+// ReachabilityResult answers a ReachabilityProbe, unless the peer refused to
+// dial the sender back.
+type ReachabilityResult struct {
+	Reachable bool `protobuf:"varint,1,opt,name=reachable,proto3" json:"reachable,omitempty"`
+	Refused   bool `protobuf:"varint,2,opt,name=refused,proto3" json:"refused,omitempty"`
+}
+
+func (m *ReachabilityResult) Reset()                    { *m = ReachabilityResult{} }
+func (*ReachabilityResult) ProtoMessage()               {}
+func (*ReachabilityResult) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{24} }
+
+func (m *ReachabilityResult) GetReachable() bool {
+	if m != nil {
+		return m.Reachable
 	}
-	if !bytes.Equal(this.Data, that1.Data) {
-		return false
+	return false
+}
+
+func (m *ReachabilityResult) GetRefused() bool {
+	if m != nil {
+		return m.Refused
 	}
-	return true
+	return false
 }
-func (this *ID) GoString() string {
-	if this == nil {
-		return "nil"
+
+// UpgradeAnnouncement announces that a node will require a protocol version
+// from activation onward, in Unix nanoseconds.
+type UpgradeAnnouncement struct {
+	Version    uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Activation int64  `protobuf:"varint,2,opt,name=activation,proto3" json:"activation,omitempty"`
+	Note       string `protobuf:"bytes,3,opt,name=note,proto3" json:"note,omitempty"`
+	IssuedAt   int64  `protobuf:"varint,4,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"`
+}
+
+func (m *UpgradeAnnouncement) Reset()                    { *m = UpgradeAnnouncement{} }
+func (*UpgradeAnnouncement) ProtoMessage()               {}
+func (*UpgradeAnnouncement) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{25} }
+
+func (m *UpgradeAnnouncement) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
 	}
-	s := make([]string, 0, 7)
-	s = append(s, "&protobuf.ID{")
-	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
-	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
-	s = append(s, "Id: "+fmt.Sprintf("%#v", this.Id)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return 0
 }
-func (this *Message) GoString() string {
-	if this == nil {
-		return "nil"
+
+func (m *UpgradeAnnouncement) GetActivation() int64 {
+	if m != nil {
+		return m.Activation
 	}
-	s := make([]string, 0, 10)
-	s = append(s, "&protobuf.Message{")
-	if this.Message != nil {
-		s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
+	return 0
+}
+
+func (m *UpgradeAnnouncement) GetNote() string {
+	if m != nil {
+		return m.Note
 	}
-	if this.Sender != nil {
-		s = append(s, "Sender: "+fmt.Sprintf("%#v", this.Sender)+",\n")
+	return ""
+}
+
+func (m *UpgradeAnnouncement) GetIssuedAt() int64 {
+	if m != nil {
+		return m.IssuedAt
 	}
-	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
-	s = append(s, "RequestNonce: "+fmt.Sprintf("%#v", this.RequestNonce)+",\n")
-	s = append(s, "MessageNonce: "+fmt.Sprintf("%#v", this.MessageNonce)+",\n")
-	s = append(s, "ReplyFlag: "+fmt.Sprintf("%#v", this.ReplyFlag)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return 0
 }
-func (this *Ping) GoString() string {
-	if this == nil {
-		return "nil"
+
+// SignedUpgradeAnnouncement is a serialized UpgradeAnnouncement signed by
+// the announcing node, relayed between peers as is.
+type SignedUpgradeAnnouncement struct {
+	Announcement []byte `protobuf:"bytes,1,opt,name=announcement,proto3" json:"announcement,omitempty"`
+	Signer       []byte `protobuf:"bytes,2,opt,name=signer,proto3" json:"signer,omitempty"`
+	Signature    []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *SignedUpgradeAnnouncement) Reset()      { *m = SignedUpgradeAnnouncement{} }
+func (*SignedUpgradeAnnouncement) ProtoMessage() {}
+func (*SignedUpgradeAnnouncement) Descriptor() ([]byte, []int) {
+	return fileDescriptorStream, []int{26}
+}
+
+func (m *SignedUpgradeAnnouncement) GetAnnouncement() []byte {
+	if m != nil {
+		return m.Announcement
 	}
-	s := make([]string, 0, 4)
-	s = append(s, "&protobuf.Ping{")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return nil
 }
-func (this *Pong) GoString() string {
-	if this == nil {
-		return "nil"
+
+func (m *SignedUpgradeAnnouncement) GetSigner() []byte {
+	if m != nil {
+		return m.Signer
 	}
-	s := make([]string, 0, 4)
-	s = append(s, "&protobuf.Pong{")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return nil
 }
-func (this *LookupNodeRequest) GoString() string {
-	if this == nil {
-		return "nil"
+
+func (m *SignedUpgradeAnnouncement) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&protobuf.LookupNodeRequest{")
-	if this.Target != nil {
-		s = append(s, "Target: "+fmt.Sprintf("%#v", this.Target)+",\n")
-	}
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return nil
 }
-func (this *LookupNodeResponse) GoString() string {
-	if this == nil {
-		return "nil"
+
+// PeerSample describes a node sampled by the peer sampling service, and
+// how many shuffles ago it was sampled.
+type PeerSample struct {
+	Id  *ID    `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Age uint32 `protobuf:"varint,2,opt,name=age,proto3" json:"age,omitempty"`
+}
+
+func (m *PeerSample) Reset()                    { *m = PeerSample{} }
+func (*PeerSample) ProtoMessage()               {}
+func (*PeerSample) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{27} }
+
+func (m *PeerSample) GetId() *ID {
+	if m != nil {
+		return m.Id
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&protobuf.LookupNodeResponse{")
-	if this.Peers != nil {
-		s = append(s, "Peers: "+fmt.Sprintf("%#v", this.Peers)+",\n")
+	return nil
+}
+
+func (m *PeerSample) GetAge() uint32 {
+	if m != nil {
+		return m.Age
 	}
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return 0
 }
-func (this *Bytes) GoString() string {
-	if this == nil {
-		return "nil"
+
+// ShuffleRequest offers a peer samples in exchange for as many of its own.
+type ShuffleRequest struct {
+	Samples []*PeerSample `protobuf:"bytes,1,rep,name=samples" json:"samples,omitempty"`
+}
+
+func (m *ShuffleRequest) Reset()                    { *m = ShuffleRequest{} }
+func (*ShuffleRequest) ProtoMessage()               {}
+func (*ShuffleRequest) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{28} }
+
+func (m *ShuffleRequest) GetSamples() []*PeerSample {
+	if m != nil {
+		return m.Samples
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&protobuf.Bytes{")
-	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return nil
 }
-func valueToGoStringStream(v interface{}, typ string) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+
+// ShuffleResponse answers a ShuffleRequest with samples of the peer.
+type ShuffleResponse struct {
+	Samples []*PeerSample `protobuf:"bytes,1,rep,name=samples" json:"samples,omitempty"`
+}
+
+func (m *ShuffleResponse) Reset()                    { *m = ShuffleResponse{} }
+func (*ShuffleResponse) ProtoMessage()               {}
+func (*ShuffleResponse) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{29} }
+
+func (m *ShuffleResponse) GetSamples() []*PeerSample {
+	if m != nil {
+		return m.Samples
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+	return nil
 }
-func (m *ID) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+
+// DiagnosticsRequest asks a peer for its view of our session, carrying when
+// it was sent in Unix nanoseconds.
+type DiagnosticsRequest struct {
+	SentAt int64 `protobuf:"varint,1,opt,name=sent_at,json=sentAt,proto3" json:"sent_at,omitempty"`
+}
+
+func (m *DiagnosticsRequest) Reset()                    { *m = DiagnosticsRequest{} }
+func (*DiagnosticsRequest) ProtoMessage()               {}
+func (*DiagnosticsRequest) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{30} }
+
+func (m *DiagnosticsRequest) GetSentAt() int64 {
+	if m != nil {
+		return m.SentAt
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *ID) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.PublicKey) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
-		i += copy(dAtA[i:], m.PublicKey)
+// DiagnosticsReport is a peer's view of our session with it, unless it
+// refused to share it.
+type DiagnosticsReport struct {
+	Refused          bool   `protobuf:"varint,1,opt,name=refused,proto3" json:"refused,omitempty"`
+	Rtt              int64  `protobuf:"varint,2,opt,name=rtt,proto3" json:"rtt,omitempty"`
+	ObservedAddress  string `protobuf:"bytes,3,opt,name=observed_address,json=observedAddress,proto3" json:"observed_address,omitempty"`
+	MessagesSent     uint64 `protobuf:"varint,4,opt,name=messages_sent,json=messagesSent,proto3" json:"messages_sent,omitempty"`
+	MessagesReceived uint64 `protobuf:"varint,5,opt,name=messages_received,json=messagesReceived,proto3" json:"messages_received,omitempty"`
+	BytesSent        uint64 `protobuf:"varint,6,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`
+	BytesReceived    uint64 `protobuf:"varint,7,opt,name=bytes_received,json=bytesReceived,proto3" json:"bytes_received,omitempty"`
+	Violations       uint64 `protobuf:"varint,8,opt,name=violations,proto3" json:"violations,omitempty"`
+	Quarantined      bool   `protobuf:"varint,9,opt,name=quarantined,proto3" json:"quarantined,omitempty"`
+	Time             int64  `protobuf:"varint,10,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (m *DiagnosticsReport) Reset()                    { *m = DiagnosticsReport{} }
+func (*DiagnosticsReport) ProtoMessage()               {}
+func (*DiagnosticsReport) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{31} }
+
+func (m *DiagnosticsReport) GetRefused() bool {
+	if m != nil {
+		return m.Refused
 	}
-	if len(m.Address) > 0 {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
-		i += copy(dAtA[i:], m.Address)
+	return false
+}
+
+func (m *DiagnosticsReport) GetRtt() int64 {
+	if m != nil {
+		return m.Rtt
 	}
-	if len(m.Id) > 0 {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(len(m.Id)))
-		i += copy(dAtA[i:], m.Id)
+	return 0
+}
+
+func (m *DiagnosticsReport) GetObservedAddress() string {
+	if m != nil {
+		return m.ObservedAddress
 	}
-	return i, nil
+	return ""
 }
 
-func (m *Message) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (m *DiagnosticsReport) GetMessagesSent() uint64 {
+	if m != nil {
+		return m.MessagesSent
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Message) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Message != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(m.Message.Size()))
-		n1, err := m.Message.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n1
+func (m *DiagnosticsReport) GetMessagesReceived() uint64 {
+	if m != nil {
+		return m.MessagesReceived
 	}
-	if m.Sender != nil {
-		dAtA[i] = 0x12
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(m.Sender.Size()))
-		n2, err := m.Sender.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n2
+	return 0
+}
+
+func (m *DiagnosticsReport) GetBytesSent() uint64 {
+	if m != nil {
+		return m.BytesSent
 	}
-	if len(m.Signature) > 0 {
-		dAtA[i] = 0x1a
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
-		i += copy(dAtA[i:], m.Signature)
+	return 0
+}
+
+func (m *DiagnosticsReport) GetBytesReceived() uint64 {
+	if m != nil {
+		return m.BytesReceived
 	}
-	if m.RequestNonce != 0 {
-		dAtA[i] = 0x20
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(m.RequestNonce))
+	return 0
+}
+
+func (m *DiagnosticsReport) GetViolations() uint64 {
+	if m != nil {
+		return m.Violations
 	}
-	if m.MessageNonce != 0 {
-		dAtA[i] = 0x28
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(m.MessageNonce))
+	return 0
+}
+
+func (m *DiagnosticsReport) GetQuarantined() bool {
+	if m != nil {
+		return m.Quarantined
 	}
-	if m.ReplyFlag {
-		dAtA[i] = 0x30
-		i++
-		if m.ReplyFlag {
-			dAtA[i] = 1
-		} else {
-			dAtA[i] = 0
-		}
-		i++
+	return false
+}
+
+func (m *DiagnosticsReport) GetTime() int64 {
+	if m != nil {
+		return m.Time
 	}
-	return i, nil
+	return 0
 }
 
-func (m *Ping) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+// Goodbye tells a peer we are about to disconnect from it, such as to hand
+// our address over to a successor process, and that it may reconnect after
+// retry_after nanoseconds.
+type Goodbye struct {
+	RetryAfter int64 `protobuf:"varint,1,opt,name=retry_after,json=retryAfter,proto3" json:"retry_after,omitempty"`
+}
+
+func (m *Goodbye) Reset()                    { *m = Goodbye{} }
+func (*Goodbye) ProtoMessage()               {}
+func (*Goodbye) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{32} }
+
+func (m *Goodbye) GetRetryAfter() int64 {
+	if m != nil {
+		return m.RetryAfter
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Ping) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	return i, nil
+// HandoverSession is a resumable session handed over to a successor process.
+// Sessions held for peers we dialed carry their address. Times are in Unix
+// nanoseconds.
+type HandoverSession struct {
+	Token        []byte          `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	PublicKey    []byte          `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Version      string          `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	Offer        *HandshakeOffer `protobuf:"bytes,4,opt,name=offer" json:"offer,omitempty"`
+	MessageNonce uint64          `protobuf:"varint,5,opt,name=message_nonce,json=messageNonce,proto3" json:"message_nonce,omitempty"`
+	RequestNonce uint64          `protobuf:"varint,6,opt,name=request_nonce,json=requestNonce,proto3" json:"request_nonce,omitempty"`
+	Expiry       int64           `protobuf:"varint,7,opt,name=expiry,proto3" json:"expiry,omitempty"`
+	Address      string          `protobuf:"bytes,8,opt,name=address,proto3" json:"address,omitempty"`
+	Resumption   []byte          `protobuf:"bytes,9,opt,name=resumption,proto3" json:"resumption,omitempty"`
 }
 
-func (m *Pong) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (m *HandoverSession) Reset()                    { *m = HandoverSession{} }
+func (*HandoverSession) ProtoMessage()               {}
+func (*HandoverSession) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{33} }
+
+func (m *HandoverSession) GetToken() []byte {
+	if m != nil {
+		return m.Token
 	}
-	return dAtA[:n], nil
+	return nil
 }
 
-func (m *Pong) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	return i, nil
+func (m *HandoverSession) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
+	}
+	return nil
 }
 
-func (m *LookupNodeRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (m *HandoverSession) GetVersion() string {
+	if m != nil {
+		return m.Version
 	}
-	return dAtA[:n], nil
+	return ""
 }
 
-func (m *LookupNodeRequest) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if m.Target != nil {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(m.Target.Size()))
-		n3, err := m.Target.MarshalTo(dAtA[i:])
-		if err != nil {
-			return 0, err
-		}
-		i += n3
+func (m *HandoverSession) GetOffer() *HandshakeOffer {
+	if m != nil {
+		return m.Offer
 	}
-	return i, nil
+	return nil
 }
 
-func (m *LookupNodeResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (m *HandoverSession) GetMessageNonce() uint64 {
+	if m != nil {
+		return m.MessageNonce
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *LookupNodeResponse) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Peers) > 0 {
-		for _, msg := range m.Peers {
-			dAtA[i] = 0xa
-			i++
-			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
-			n, err := msg.MarshalTo(dAtA[i:])
-			if err != nil {
-				return 0, err
-			}
-			i += n
-		}
+func (m *HandoverSession) GetRequestNonce() uint64 {
+	if m != nil {
+		return m.RequestNonce
 	}
-	return i, nil
+	return 0
 }
 
-func (m *Bytes) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
+func (m *HandoverSession) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
 	}
-	return dAtA[:n], nil
+	return 0
 }
 
-func (m *Bytes) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Data) > 0 {
-		dAtA[i] = 0xa
-		i++
-		i = encodeVarintStream(dAtA, i, uint64(len(m.Data)))
-		i += copy(dAtA[i:], m.Data)
+func (m *HandoverSession) GetAddress() string {
+	if m != nil {
+		return m.Address
 	}
-	return i, nil
+	return ""
 }
 
-func encodeVarintStream(dAtA []byte, offset int, v uint64) int {
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *HandoverSession) GetResumption() []byte {
+	if m != nil {
+		return m.Resumption
 	}
-	dAtA[offset] = uint8(v)
-	return offset + 1
+	return nil
 }
-func (m *ID) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.PublicKey)
-	if l > 0 {
-		n += 1 + l + sovStream(uint64(l))
+
+// HandoverAddress is the cached result of dialing a peer back at an address.
+type HandoverAddress struct {
+	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Address   string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Status    uint32 `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	Expiry    int64  `protobuf:"varint,4,opt,name=expiry,proto3" json:"expiry,omitempty"`
+}
+
+func (m *HandoverAddress) Reset()                    { *m = HandoverAddress{} }
+func (*HandoverAddress) ProtoMessage()               {}
+func (*HandoverAddress) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{34} }
+
+func (m *HandoverAddress) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
 	}
-	l = len(m.Address)
-	if l > 0 {
-		n += 1 + l + sovStream(uint64(l))
+	return nil
+}
+
+func (m *HandoverAddress) GetAddress() string {
+	if m != nil {
+		return m.Address
 	}
-	l = len(m.Id)
-	if l > 0 {
-		n += 1 + l + sovStream(uint64(l))
+	return ""
+}
+
+func (m *HandoverAddress) GetStatus() uint32 {
+	if m != nil {
+		return m.Status
 	}
-	return n
+	return 0
 }
 
-func (m *Message) Size() (n int) {
-	var l int
-	_ = l
-	if m.Message != nil {
-		l = m.Message.Size()
-		n += 1 + l + sovStream(uint64(l))
+func (m *HandoverAddress) GetExpiry() int64 {
+	if m != nil {
+		return m.Expiry
 	}
-	if m.Sender != nil {
-		l = m.Sender.Size()
-		n += 1 + l + sovStream(uint64(l))
+	return 0
+}
+
+// HandoverPlugin is the state a plugin or connection gater handed over,
+// keyed by its type.
+type HandoverPlugin struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	State []byte `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (m *HandoverPlugin) Reset()                    { *m = HandoverPlugin{} }
+func (*HandoverPlugin) ProtoMessage()               {}
+func (*HandoverPlugin) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{35} }
+
+func (m *HandoverPlugin) GetName() string {
+	if m != nil {
+		return m.Name
 	}
-	l = len(m.Signature)
-	if l > 0 {
-		n += 1 + l + sovStream(uint64(l))
+	return ""
+}
+
+func (m *HandoverPlugin) GetState() []byte {
+	if m != nil {
+		return m.State
 	}
-	if m.RequestNonce != 0 {
-		n += 1 + sovStream(uint64(m.RequestNonce))
+	return nil
+}
+
+// HandoverState is the state of a node handed over to a successor process
+// taking over its identity and address. Times are in Unix nanoseconds.
+type HandoverState struct {
+	PublicKey            []byte             `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Address              string             `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	CreatedAt            int64              `protobuf:"varint,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Peers                []*PeerRecord      `protobuf:"bytes,4,rep,name=peers" json:"peers,omitempty"`
+	Issued               []*HandoverSession `protobuf:"bytes,5,rep,name=issued" json:"issued,omitempty"`
+	Held                 []*HandoverSession `protobuf:"bytes,6,rep,name=held" json:"held,omitempty"`
+	Addresses            []*HandoverAddress `protobuf:"bytes,7,rep,name=addresses" json:"addresses,omitempty"`
+	Reachability         uint32             `protobuf:"varint,8,opt,name=reachability,proto3" json:"reachability,omitempty"`
+	ReachabilityOutcomes []bool             `protobuf:"varint,9,rep,packed,name=reachability_outcomes,json=reachabilityOutcomes" json:"reachability_outcomes,omitempty"`
+	ReachabilityChecked  int64              `protobuf:"varint,10,opt,name=reachability_checked,json=reachabilityChecked,proto3" json:"reachability_checked,omitempty"`
+	Plugins              []*HandoverPlugin  `protobuf:"bytes,11,rep,name=plugins" json:"plugins,omitempty"`
+}
+
+func (m *HandoverState) Reset()                    { *m = HandoverState{} }
+func (*HandoverState) ProtoMessage()               {}
+func (*HandoverState) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{36} }
+
+func (m *HandoverState) GetPublicKey() []byte {
+	if m != nil {
+		return m.PublicKey
 	}
-	if m.MessageNonce != 0 {
-		n += 1 + sovStream(uint64(m.MessageNonce))
+	return nil
+}
+
+func (m *HandoverState) GetAddress() string {
+	if m != nil {
+		return m.Address
 	}
-	if m.ReplyFlag {
-		n += 2
+	return ""
+}
+
+func (m *HandoverState) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
 	}
-	return n
+	return 0
 }
 
-func (m *Ping) Size() (n int) {
-	var l int
-	_ = l
-	return n
+func (m *HandoverState) GetPeers() []*PeerRecord {
+	if m != nil {
+		return m.Peers
+	}
+	return nil
 }
 
-func (m *Pong) Size() (n int) {
-	var l int
-	_ = l
-	return n
+func (m *HandoverState) GetIssued() []*HandoverSession {
+	if m != nil {
+		return m.Issued
+	}
+	return nil
 }
 
-func (m *LookupNodeRequest) Size() (n int) {
-	var l int
-	_ = l
-	if m.Target != nil {
-		l = m.Target.Size()
-		n += 1 + l + sovStream(uint64(l))
+func (m *HandoverState) GetHeld() []*HandoverSession {
+	if m != nil {
+		return m.Held
 	}
-	return n
+	return nil
 }
 
-func (m *LookupNodeResponse) Size() (n int) {
-	var l int
-	_ = l
-	if len(m.Peers) > 0 {
-		for _, e := range m.Peers {
-			l = e.Size()
-			n += 1 + l + sovStream(uint64(l))
-		}
+func (m *HandoverState) GetAddresses() []*HandoverAddress {
+	if m != nil {
+		return m.Addresses
 	}
-	return n
+	return nil
 }
 
-func (m *Bytes) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.Data)
-	if l > 0 {
-		n += 1 + l + sovStream(uint64(l))
+func (m *HandoverState) GetReachability() uint32 {
+	if m != nil {
+		return m.Reachability
 	}
-	return n
+	return 0
 }
 
-func sovStream(x uint64) (n int) {
-	for {
-		n++
-		x >>= 7
-		if x == 0 {
-			break
+func (m *HandoverState) GetReachabilityOutcomes() []bool {
+	if m != nil {
+		return m.ReachabilityOutcomes
+	}
+	return nil
+}
+
+func (m *HandoverState) GetReachabilityChecked() int64 {
+	if m != nil {
+		return m.ReachabilityChecked
+	}
+	return 0
+}
+
+func (m *HandoverState) GetPlugins() []*HandoverPlugin {
+	if m != nil {
+		return m.Plugins
+	}
+	return nil
+}
+
+// JournalEntry is a message received journaled ahead of being handled. Its
+// payload is only set should the message have arrived within a batch.
+type JournalEntry struct {
+	Frame      []byte               `protobuf:"bytes,1,opt,name=frame,proto3" json:"frame,omitempty"`
+	Payload    *google_protobuf.Any `protobuf:"bytes,2,opt,name=payload" json:"payload,omitempty"`
+	ReceivedAt int64                `protobuf:"varint,3,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
+}
+
+func (m *JournalEntry) Reset()                    { *m = JournalEntry{} }
+func (*JournalEntry) ProtoMessage()               {}
+func (*JournalEntry) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{37} }
+
+func (m *JournalEntry) GetFrame() []byte {
+	if m != nil {
+		return m.Frame
+	}
+	return nil
+}
+
+func (m *JournalEntry) GetPayload() *google_protobuf.Any {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+func (m *JournalEntry) GetReceivedAt() int64 {
+	if m != nil {
+		return m.ReceivedAt
+	}
+	return 0
+}
+
+// HandshakeExtension is the payload an application handshake extension
+// contributed to a handshake.
+type HandshakeExtension struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Payload []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *HandshakeExtension) Reset()                    { *m = HandshakeExtension{} }
+func (*HandshakeExtension) ProtoMessage()               {}
+func (*HandshakeExtension) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{38} }
+
+func (m *HandshakeExtension) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *HandshakeExtension) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
+// HandshakeExtensions is exchanged right after the handshake, should both
+// peers support any handshake extension in common.
+type HandshakeExtensions struct {
+	// Sender's address and public key.
+	Sender     *ID                   `protobuf:"bytes,1,opt,name=sender" json:"sender,omitempty"`
+	Extensions []*HandshakeExtension `protobuf:"bytes,2,rep,name=extensions" json:"extensions,omitempty"`
+	// Sender's signature over all other fields.
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *HandshakeExtensions) Reset()                    { *m = HandshakeExtensions{} }
+func (*HandshakeExtensions) ProtoMessage()               {}
+func (*HandshakeExtensions) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{39} }
+
+func (m *HandshakeExtensions) GetSender() *ID {
+	if m != nil {
+		return m.Sender
+	}
+	return nil
+}
+
+func (m *HandshakeExtensions) GetExtensions() []*HandshakeExtension {
+	if m != nil {
+		return m.Extensions
+	}
+	return nil
+}
+
+func (m *HandshakeExtensions) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Affinity is handed by an acceptor to a dialer during the handshake, so that
+// the dialer reconnects to it at a direct address rather than at the address
+// it dialed, such as the name of a load-balanced pool of nodes.
+type Affinity struct {
+	Token   []byte `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// lifetime is how long the affinity holds for, in nanoseconds.
+	Lifetime int64 `protobuf:"varint,3,opt,name=lifetime,proto3" json:"lifetime,omitempty"`
+}
+
+func (m *Affinity) Reset()                    { *m = Affinity{} }
+func (*Affinity) ProtoMessage()               {}
+func (*Affinity) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{40} }
+
+func (m *Affinity) GetToken() []byte {
+	if m != nil {
+		return m.Token
+	}
+	return nil
+}
+
+func (m *Affinity) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *Affinity) GetLifetime() int64 {
+	if m != nil {
+		return m.Lifetime
+	}
+	return 0
+}
+
+// AffinityRevoked tells a peer to stop using affinity tokens we issued, such
+// as when we are being drained.
+type AffinityRevoked struct {
+	Tokens [][]byte `protobuf:"bytes,1,rep,name=tokens" json:"tokens,omitempty"`
+}
+
+func (m *AffinityRevoked) Reset()                    { *m = AffinityRevoked{} }
+func (*AffinityRevoked) ProtoMessage()               {}
+func (*AffinityRevoked) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{41} }
+
+func (m *AffinityRevoked) GetTokens() [][]byte {
+	if m != nil {
+		return m.Tokens
+	}
+	return nil
+}
+
+// HandshakePuzzle is a proof-of-work puzzle: a solution is such that hashing the seed, the dialer's public key and the solution yields a hash starting with at least difficulty zero bits.
+type HandshakePuzzle struct {
+	Seed       []byte `protobuf:"bytes,1,opt,name=seed,proto3" json:"seed,omitempty"`
+	Difficulty uint32 `protobuf:"varint,2,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+}
+
+func (m *HandshakePuzzle) Reset()                    { *m = HandshakePuzzle{} }
+func (*HandshakePuzzle) ProtoMessage()               {}
+func (*HandshakePuzzle) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{42} }
+
+func (m *HandshakePuzzle) GetSeed() []byte {
+	if m != nil {
+		return m.Seed
+	}
+	return nil
+}
+
+func (m *HandshakePuzzle) GetDifficulty() uint32 {
+	if m != nil {
+		return m.Difficulty
+	}
+	return 0
+}
+
+// Chunk is a part of a message too large to fit in a single frame, which is
+// reassembled from all chunks of the same transfer.
+type Chunk struct {
+	// transfer identifies the message the chunk is part of, per sender.
+	Transfer uint64 `protobuf:"varint,1,opt,name=transfer,proto3" json:"transfer,omitempty"`
+	// index is the position of the chunk in the message, out of count chunks.
+	Index uint32 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Count uint32 `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	// size is the size of the whole message, a serialized google.protobuf.Any.
+	Size_ uint64 `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Data  []byte `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Chunk) Reset()                    { *m = Chunk{} }
+func (*Chunk) ProtoMessage()               {}
+func (*Chunk) Descriptor() ([]byte, []int) { return fileDescriptorStream, []int{43} }
+
+func (m *Chunk) GetTransfer() uint64 {
+	if m != nil {
+		return m.Transfer
+	}
+	return 0
+}
+
+func (m *Chunk) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Chunk) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *Chunk) GetSize_() uint64 {
+	if m != nil {
+		return m.Size_
+	}
+	return 0
+}
+
+func (m *Chunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ID)(nil), "protobuf.ID")
+	proto.RegisterType((*Message)(nil), "protobuf.Message")
+	proto.RegisterType((*Signature)(nil), "protobuf.Signature")
+	proto.RegisterType((*Ping)(nil), "protobuf.Ping")
+	proto.RegisterType((*Batch)(nil), "protobuf.Batch")
+	proto.RegisterType((*Pong)(nil), "protobuf.Pong")
+	proto.RegisterType((*Keepalive)(nil), "protobuf.Keepalive")
+	proto.RegisterType((*KeepaliveAck)(nil), "protobuf.KeepaliveAck")
+	proto.RegisterType((*LookupNodeRequest)(nil), "protobuf.LookupNodeRequest")
+	proto.RegisterType((*LookupNodeResponse)(nil), "protobuf.LookupNodeResponse")
+	proto.RegisterType((*CompactPeers)(nil), "protobuf.CompactPeers")
+	proto.RegisterType((*Bytes)(nil), "protobuf.Bytes")
+	proto.RegisterType((*HandshakeOffer)(nil), "protobuf.HandshakeOffer")
+	proto.RegisterType((*HandshakeMetadata)(nil), "protobuf.HandshakeMetadata")
+	proto.RegisterType((*Handshake)(nil), "protobuf.Handshake")
+	proto.RegisterType((*PeerRecord)(nil), "protobuf.PeerRecord")
+	proto.RegisterType((*PeerBundle)(nil), "protobuf.PeerBundle")
+	proto.RegisterType((*SignedPeerBundle)(nil), "protobuf.SignedPeerBundle")
+	proto.RegisterType((*ServiceRecord)(nil), "protobuf.ServiceRecord")
+	proto.RegisterType((*ServiceRecords)(nil), "protobuf.ServiceRecords")
+	proto.RegisterType((*Rejection)(nil), "protobuf.Rejection")
+	proto.RegisterType((*Routed)(nil), "protobuf.Routed")
+	proto.RegisterType((*Hint)(nil), "protobuf.Hint")
+	proto.RegisterType((*ReachabilityProbe)(nil), "protobuf.ReachabilityProbe")
+	proto.RegisterType((*ReachabilityResult)(nil), "protobuf.ReachabilityResult")
+	proto.RegisterType((*UpgradeAnnouncement)(nil), "protobuf.UpgradeAnnouncement")
+	proto.RegisterType((*SignedUpgradeAnnouncement)(nil), "protobuf.SignedUpgradeAnnouncement")
+	proto.RegisterType((*PeerSample)(nil), "protobuf.PeerSample")
+	proto.RegisterType((*ShuffleRequest)(nil), "protobuf.ShuffleRequest")
+	proto.RegisterType((*ShuffleResponse)(nil), "protobuf.ShuffleResponse")
+	proto.RegisterType((*DiagnosticsRequest)(nil), "protobuf.DiagnosticsRequest")
+	proto.RegisterType((*DiagnosticsReport)(nil), "protobuf.DiagnosticsReport")
+	proto.RegisterType((*Goodbye)(nil), "protobuf.Goodbye")
+	proto.RegisterType((*HandoverSession)(nil), "protobuf.HandoverSession")
+	proto.RegisterType((*HandoverAddress)(nil), "protobuf.HandoverAddress")
+	proto.RegisterType((*HandoverPlugin)(nil), "protobuf.HandoverPlugin")
+	proto.RegisterType((*HandoverState)(nil), "protobuf.HandoverState")
+	proto.RegisterType((*JournalEntry)(nil), "protobuf.JournalEntry")
+	proto.RegisterType((*HandshakeExtension)(nil), "protobuf.HandshakeExtension")
+	proto.RegisterType((*HandshakeExtensions)(nil), "protobuf.HandshakeExtensions")
+	proto.RegisterType((*Affinity)(nil), "protobuf.Affinity")
+	proto.RegisterType((*AffinityRevoked)(nil), "protobuf.AffinityRevoked")
+	proto.RegisterType((*HandshakePuzzle)(nil), "protobuf.HandshakePuzzle")
+	proto.RegisterType((*Chunk)(nil), "protobuf.Chunk")
+}
+func (this *ID) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ID)
+	if !ok {
+		that2, ok := that.(ID)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ID")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ID but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ID but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if !bytes.Equal(this.Id, that1.Id) {
+		return fmt.Errorf("Id this(%v) Not Equal that(%v)", this.Id, that1.Id)
+	}
+	return nil
+}
+func (this *ID) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ID)
+	if !ok {
+		that2, ok := that.(ID)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if !bytes.Equal(this.Id, that1.Id) {
+		return false
+	}
+	return true
+}
+func (this *Message) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Message)
+	if !ok {
+		that2, ok := that.(Message)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Message")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Message but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Message but is not nil && this == nil")
+	}
+	if !this.Message.Equal(that1.Message) {
+		return fmt.Errorf("Message this(%v) Not Equal that(%v)", this.Message, that1.Message)
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return fmt.Errorf("Sender this(%v) Not Equal that(%v)", this.Sender, that1.Sender)
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return fmt.Errorf("RequestNonce this(%v) Not Equal that(%v)", this.RequestNonce, that1.RequestNonce)
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return fmt.Errorf("MessageNonce this(%v) Not Equal that(%v)", this.MessageNonce, that1.MessageNonce)
+	}
+	if this.ReplyFlag != that1.ReplyFlag {
+		return fmt.Errorf("ReplyFlag this(%v) Not Equal that(%v)", this.ReplyFlag, that1.ReplyFlag)
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return fmt.Errorf("Metadata this(%v) Not Equal that(%v)", len(this.Metadata), len(that1.Metadata))
+	}
+	for i := range this.Metadata {
+		if this.Metadata[i] != that1.Metadata[i] {
+			return fmt.Errorf("Metadata this[%v](%v) Not Equal that[%v](%v)", i, this.Metadata[i], i, that1.Metadata[i])
+		}
+	}
+	if len(this.Signatures) != len(that1.Signatures) {
+		return fmt.Errorf("Signatures this(%v) Not Equal that(%v)", len(this.Signatures), len(that1.Signatures))
+	}
+	for i := range this.Signatures {
+		if !this.Signatures[i].Equal(that1.Signatures[i]) {
+			return fmt.Errorf("Signatures this[%v](%v) Not Equal that[%v](%v)", i, this.Signatures[i], i, that1.Signatures[i])
+		}
+	}
+	if len(this.CriticalExtensions) != len(that1.CriticalExtensions) {
+		return fmt.Errorf("CriticalExtensions this(%v) Not Equal that(%v)", len(this.CriticalExtensions), len(that1.CriticalExtensions))
+	}
+	for i := range this.CriticalExtensions {
+		if this.CriticalExtensions[i] != that1.CriticalExtensions[i] {
+			return fmt.Errorf("CriticalExtensions this[%v](%v) Not Equal that[%v](%v)", i, this.CriticalExtensions[i], i, that1.CriticalExtensions[i])
+		}
+	}
+	if this.Protocol != that1.Protocol {
+		return fmt.Errorf("Protocol this(%v) Not Equal that(%v)", this.Protocol, that1.Protocol)
+	}
+	if this.BudgetMs != that1.BudgetMs {
+		return fmt.Errorf("BudgetMs this(%v) Not Equal that(%v)", this.BudgetMs, that1.BudgetMs)
+	}
+	if len(this.Hints) != len(that1.Hints) {
+		return fmt.Errorf("Hints this(%v) Not Equal that(%v)", len(this.Hints), len(that1.Hints))
+	}
+	for i := range this.Hints {
+		if !this.Hints[i].Equal(that1.Hints[i]) {
+			return fmt.Errorf("Hints this[%v](%v) Not Equal that[%v](%v)", i, this.Hints[i], i, that1.Hints[i])
+		}
+	}
+	if !bytes.Equal(this.Padding, that1.Padding) {
+		return fmt.Errorf("Padding this(%v) Not Equal that(%v)", this.Padding, that1.Padding)
+	}
+	if this.ReplayNonce != that1.ReplayNonce {
+		return fmt.Errorf("ReplayNonce this(%v) Not Equal that(%v)", this.ReplayNonce, that1.ReplayNonce)
+	}
+	if this.SignatureScheme != that1.SignatureScheme {
+		return fmt.Errorf("SignatureScheme this(%v) Not Equal that(%v)", this.SignatureScheme, that1.SignatureScheme)
+	}
+	if this.ReplayStampMs != that1.ReplayStampMs {
+		return fmt.Errorf("ReplayStampMs this(%v) Not Equal that(%v)", this.ReplayStampMs, that1.ReplayStampMs)
+	}
+	return nil
+}
+func (this *Message) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Message)
+	if !ok {
+		that2, ok := that.(Message)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Message.Equal(that1.Message) {
+		return false
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return false
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return false
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return false
+	}
+	if this.ReplyFlag != that1.ReplyFlag {
+		return false
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return false
+	}
+	for i := range this.Metadata {
+		if this.Metadata[i] != that1.Metadata[i] {
+			return false
+		}
+	}
+	if len(this.Signatures) != len(that1.Signatures) {
+		return false
+	}
+	for i := range this.Signatures {
+		if !this.Signatures[i].Equal(that1.Signatures[i]) {
+			return false
+		}
+	}
+	if len(this.CriticalExtensions) != len(that1.CriticalExtensions) {
+		return false
+	}
+	for i := range this.CriticalExtensions {
+		if this.CriticalExtensions[i] != that1.CriticalExtensions[i] {
+			return false
+		}
+	}
+	if this.Protocol != that1.Protocol {
+		return false
+	}
+	if this.BudgetMs != that1.BudgetMs {
+		return false
+	}
+	if len(this.Hints) != len(that1.Hints) {
+		return false
+	}
+	for i := range this.Hints {
+		if !this.Hints[i].Equal(that1.Hints[i]) {
+			return false
+		}
+	}
+	if !bytes.Equal(this.Padding, that1.Padding) {
+		return false
+	}
+	if this.ReplayNonce != that1.ReplayNonce {
+		return false
+	}
+	if this.SignatureScheme != that1.SignatureScheme {
+		return false
+	}
+	if this.ReplayStampMs != that1.ReplayStampMs {
+		return false
+	}
+	return true
+}
+func (this *Signature) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Signature)
+	if !ok {
+		that2, ok := that.(Signature)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Signature")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Signature but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Signature but is not nil && this == nil")
+	}
+	if this.Scheme != that1.Scheme {
+		return fmt.Errorf("Scheme this(%v) Not Equal that(%v)", this.Scheme, that1.Scheme)
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	return nil
+}
+func (this *Signature) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Signature)
+	if !ok {
+		that2, ok := that.(Signature)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Scheme != that1.Scheme {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	return true
+}
+func (this *Ping) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Ping)
+	if !ok {
+		that2, ok := that.(Ping)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Ping")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Ping but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Ping but is not nil && this == nil")
+	}
+	return nil
+}
+func (this *Ping) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Ping)
+	if !ok {
+		that2, ok := that.(Ping)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *Batch) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Batch)
+	if !ok {
+		that2, ok := that.(Batch)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Batch")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Batch but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Batch but is not nil && this == nil")
+	}
+	if len(this.Payloads) != len(that1.Payloads) {
+		return fmt.Errorf("Payloads this(%v) Not Equal that(%v)", len(this.Payloads), len(that1.Payloads))
+	}
+	for i := range this.Payloads {
+		if !this.Payloads[i].Equal(that1.Payloads[i]) {
+			return fmt.Errorf("Payloads this[%v](%v) Not Equal that[%v](%v)", i, this.Payloads[i], i, that1.Payloads[i])
+		}
+	}
+	return nil
+}
+func (this *Batch) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Batch)
+	if !ok {
+		that2, ok := that.(Batch)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Payloads) != len(that1.Payloads) {
+		return false
+	}
+	for i := range this.Payloads {
+		if !this.Payloads[i].Equal(that1.Payloads[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *Pong) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Pong)
+	if !ok {
+		that2, ok := that.(Pong)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Pong")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Pong but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Pong but is not nil && this == nil")
+	}
+	return nil
+}
+func (this *Pong) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Pong)
+	if !ok {
+		that2, ok := that.(Pong)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *Keepalive) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Keepalive)
+	if !ok {
+		that2, ok := that.(Keepalive)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Keepalive")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Keepalive but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Keepalive but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return fmt.Errorf("Payload this(%v) Not Equal that(%v)", this.Payload, that1.Payload)
+	}
+	return nil
+}
+func (this *Keepalive) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Keepalive)
+	if !ok {
+		that2, ok := that.(Keepalive)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return false
+	}
+	return true
+}
+func (this *KeepaliveAck) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*KeepaliveAck)
+	if !ok {
+		that2, ok := that.(KeepaliveAck)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *KeepaliveAck")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *KeepaliveAck but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *KeepaliveAck but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return fmt.Errorf("Payload this(%v) Not Equal that(%v)", this.Payload, that1.Payload)
+	}
+	return nil
+}
+func (this *KeepaliveAck) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*KeepaliveAck)
+	if !ok {
+		that2, ok := that.(KeepaliveAck)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return false
+	}
+	return true
+}
+func (this *LookupNodeRequest) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*LookupNodeRequest)
+	if !ok {
+		that2, ok := that.(LookupNodeRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *LookupNodeRequest")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *LookupNodeRequest but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *LookupNodeRequest but is not nil && this == nil")
+	}
+	if !this.Target.Equal(that1.Target) {
+		return fmt.Errorf("Target this(%v) Not Equal that(%v)", this.Target, that1.Target)
+	}
+	if !bytes.Equal(this.Known, that1.Known) {
+		return fmt.Errorf("Known this(%v) Not Equal that(%v)", this.Known, that1.Known)
+	}
+	return nil
+}
+func (this *LookupNodeRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LookupNodeRequest)
+	if !ok {
+		that2, ok := that.(LookupNodeRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Target.Equal(that1.Target) {
+		return false
+	}
+	if !bytes.Equal(this.Known, that1.Known) {
+		return false
+	}
+	return true
+}
+func (this *LookupNodeResponse) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*LookupNodeResponse)
+	if !ok {
+		that2, ok := that.(LookupNodeResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *LookupNodeResponse")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *LookupNodeResponse but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *LookupNodeResponse but is not nil && this == nil")
+	}
+	if len(this.Peers) != len(that1.Peers) {
+		return fmt.Errorf("Peers this(%v) Not Equal that(%v)", len(this.Peers), len(that1.Peers))
+	}
+	for i := range this.Peers {
+		if !this.Peers[i].Equal(that1.Peers[i]) {
+			return fmt.Errorf("Peers this[%v](%v) Not Equal that[%v](%v)", i, this.Peers[i], i, that1.Peers[i])
+		}
+	}
+	if !this.Compact.Equal(that1.Compact) {
+		return fmt.Errorf("Compact this(%v) Not Equal that(%v)", this.Compact, that1.Compact)
+	}
+	return nil
+}
+func (this *LookupNodeResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LookupNodeResponse)
+	if !ok {
+		that2, ok := that.(LookupNodeResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Peers) != len(that1.Peers) {
+		return false
+	}
+	for i := range this.Peers {
+		if !this.Peers[i].Equal(that1.Peers[i]) {
+			return false
+		}
+	}
+	if !this.Compact.Equal(that1.Compact) {
+		return false
+	}
+	return true
+}
+func (this *CompactPeers) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*CompactPeers)
+	if !ok {
+		that2, ok := that.(CompactPeers)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *CompactPeers")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *CompactPeers but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *CompactPeers but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.PublicKeys, that1.PublicKeys) {
+		return fmt.Errorf("PublicKeys this(%v) Not Equal that(%v)", this.PublicKeys, that1.PublicKeys)
+	}
+	if this.KeySize != that1.KeySize {
+		return fmt.Errorf("KeySize this(%v) Not Equal that(%v)", this.KeySize, that1.KeySize)
+	}
+	if len(this.Prefixes) != len(that1.Prefixes) {
+		return fmt.Errorf("Prefixes this(%v) Not Equal that(%v)", len(this.Prefixes), len(that1.Prefixes))
+	}
+	for i := range this.Prefixes {
+		if this.Prefixes[i] != that1.Prefixes[i] {
+			return fmt.Errorf("Prefixes this[%v](%v) Not Equal that[%v](%v)", i, this.Prefixes[i], i, that1.Prefixes[i])
+		}
+	}
+	if len(this.PrefixIndices) != len(that1.PrefixIndices) {
+		return fmt.Errorf("PrefixIndices this(%v) Not Equal that(%v)", len(this.PrefixIndices), len(that1.PrefixIndices))
+	}
+	for i := range this.PrefixIndices {
+		if this.PrefixIndices[i] != that1.PrefixIndices[i] {
+			return fmt.Errorf("PrefixIndices this[%v](%v) Not Equal that[%v](%v)", i, this.PrefixIndices[i], i, that1.PrefixIndices[i])
+		}
+	}
+	if len(this.Ports) != len(that1.Ports) {
+		return fmt.Errorf("Ports this(%v) Not Equal that(%v)", len(this.Ports), len(that1.Ports))
+	}
+	for i := range this.Ports {
+		if this.Ports[i] != that1.Ports[i] {
+			return fmt.Errorf("Ports this[%v](%v) Not Equal that[%v](%v)", i, this.Ports[i], i, that1.Ports[i])
+		}
+	}
+	return nil
+}
+func (this *CompactPeers) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CompactPeers)
+	if !ok {
+		that2, ok := that.(CompactPeers)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.PublicKeys, that1.PublicKeys) {
+		return false
+	}
+	if this.KeySize != that1.KeySize {
+		return false
+	}
+	if len(this.Prefixes) != len(that1.Prefixes) {
+		return false
+	}
+	for i := range this.Prefixes {
+		if this.Prefixes[i] != that1.Prefixes[i] {
+			return false
+		}
+	}
+	if len(this.PrefixIndices) != len(that1.PrefixIndices) {
+		return false
+	}
+	for i := range this.PrefixIndices {
+		if this.PrefixIndices[i] != that1.PrefixIndices[i] {
+			return false
+		}
+	}
+	if len(this.Ports) != len(that1.Ports) {
+		return false
+	}
+	for i := range this.Ports {
+		if this.Ports[i] != that1.Ports[i] {
+			return false
+		}
+	}
+	return true
+}
+func (this *Bytes) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Bytes)
+	if !ok {
+		that2, ok := that.(Bytes)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Bytes")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Bytes but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Bytes but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Data, that1.Data) {
+		return fmt.Errorf("Data this(%v) Not Equal that(%v)", this.Data, that1.Data)
+	}
+	return nil
+}
+func (this *Bytes) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Bytes)
+	if !ok {
+		that2, ok := that.(Bytes)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Data, that1.Data) {
+		return false
+	}
+	return true
+}
+func (this *HandshakeOffer) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandshakeOffer)
+	if !ok {
+		that2, ok := that.(HandshakeOffer)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandshakeOffer")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandshakeOffer but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandshakeOffer but is not nil && this == nil")
+	}
+	if len(this.Versions) != len(that1.Versions) {
+		return fmt.Errorf("Versions this(%v) Not Equal that(%v)", len(this.Versions), len(that1.Versions))
+	}
+	for i := range this.Versions {
+		if this.Versions[i] != that1.Versions[i] {
+			return fmt.Errorf("Versions this[%v](%v) Not Equal that[%v](%v)", i, this.Versions[i], i, that1.Versions[i])
+		}
+	}
+	if len(this.Capabilities) != len(that1.Capabilities) {
+		return fmt.Errorf("Capabilities this(%v) Not Equal that(%v)", len(this.Capabilities), len(that1.Capabilities))
+	}
+	for i := range this.Capabilities {
+		if this.Capabilities[i] != that1.Capabilities[i] {
+			return fmt.Errorf("Capabilities this[%v](%v) Not Equal that[%v](%v)", i, this.Capabilities[i], i, that1.Capabilities[i])
+		}
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return fmt.Errorf("Metadata this(%v) Not Equal that(%v)", len(this.Metadata), len(that1.Metadata))
+	}
+	for i := range this.Metadata {
+		if !this.Metadata[i].Equal(that1.Metadata[i]) {
+			return fmt.Errorf("Metadata this[%v](%v) Not Equal that[%v](%v)", i, this.Metadata[i], i, that1.Metadata[i])
+		}
+	}
+	if !this.Services.Equal(that1.Services) {
+		return fmt.Errorf("Services this(%v) Not Equal that(%v)", this.Services, that1.Services)
+	}
+	return nil
+}
+func (this *HandshakeOffer) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandshakeOffer)
+	if !ok {
+		that2, ok := that.(HandshakeOffer)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Versions) != len(that1.Versions) {
+		return false
+	}
+	for i := range this.Versions {
+		if this.Versions[i] != that1.Versions[i] {
+			return false
+		}
+	}
+	if len(this.Capabilities) != len(that1.Capabilities) {
+		return false
+	}
+	for i := range this.Capabilities {
+		if this.Capabilities[i] != that1.Capabilities[i] {
+			return false
+		}
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return false
+	}
+	for i := range this.Metadata {
+		if !this.Metadata[i].Equal(that1.Metadata[i]) {
+			return false
+		}
+	}
+	if !this.Services.Equal(that1.Services) {
+		return false
+	}
+	return true
+}
+func (this *HandshakeMetadata) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandshakeMetadata)
+	if !ok {
+		that2, ok := that.(HandshakeMetadata)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandshakeMetadata")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandshakeMetadata but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandshakeMetadata but is not nil && this == nil")
+	}
+	if this.Key != that1.Key {
+		return fmt.Errorf("Key this(%v) Not Equal that(%v)", this.Key, that1.Key)
+	}
+	if !bytes.Equal(this.Value, that1.Value) {
+		return fmt.Errorf("Value this(%v) Not Equal that(%v)", this.Value, that1.Value)
+	}
+	return nil
+}
+func (this *HandshakeMetadata) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandshakeMetadata)
+	if !ok {
+		that2, ok := that.(HandshakeMetadata)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Key != that1.Key {
+		return false
+	}
+	if !bytes.Equal(this.Value, that1.Value) {
+		return false
+	}
+	return true
+}
+func (this *Handshake) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Handshake)
+	if !ok {
+		that2, ok := that.(Handshake)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Handshake")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Handshake but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Handshake but is not nil && this == nil")
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return fmt.Errorf("Sender this(%v) Not Equal that(%v)", this.Sender, that1.Sender)
+	}
+	if !this.Offer.Equal(that1.Offer) {
+		return fmt.Errorf("Offer this(%v) Not Equal that(%v)", this.Offer, that1.Offer)
+	}
+	if !this.Echo.Equal(that1.Echo) {
+		return fmt.Errorf("Echo this(%v) Not Equal that(%v)", this.Echo, that1.Echo)
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	if !bytes.Equal(this.SessionToken, that1.SessionToken) {
+		return fmt.Errorf("SessionToken this(%v) Not Equal that(%v)", this.SessionToken, that1.SessionToken)
+	}
+	if this.Resumed != that1.Resumed {
+		return fmt.Errorf("Resumed this(%v) Not Equal that(%v)", this.Resumed, that1.Resumed)
+	}
+	if this.Control != that1.Control {
+		return fmt.Errorf("Control this(%v) Not Equal that(%v)", this.Control, that1.Control)
+	}
+	if !this.Affinity.Equal(that1.Affinity) {
+		return fmt.Errorf("Affinity this(%v) Not Equal that(%v)", this.Affinity, that1.Affinity)
+	}
+	if !bytes.Equal(this.AffinityToken, that1.AffinityToken) {
+		return fmt.Errorf("AffinityToken this(%v) Not Equal that(%v)", this.AffinityToken, that1.AffinityToken)
+	}
+	if !this.Puzzle.Equal(that1.Puzzle) {
+		return fmt.Errorf("Puzzle this(%v) Not Equal that(%v)", this.Puzzle, that1.Puzzle)
+	}
+	if !bytes.Equal(this.PuzzleSolution, that1.PuzzleSolution) {
+		return fmt.Errorf("PuzzleSolution this(%v) Not Equal that(%v)", this.PuzzleSolution, that1.PuzzleSolution)
+	}
+	if !bytes.Equal(this.EphemeralKey, that1.EphemeralKey) {
+		return fmt.Errorf("EphemeralKey this(%v) Not Equal that(%v)", this.EphemeralKey, that1.EphemeralKey)
+	}
+	if this.SignatureScheme != that1.SignatureScheme {
+		return fmt.Errorf("SignatureScheme this(%v) Not Equal that(%v)", this.SignatureScheme, that1.SignatureScheme)
+	}
+	if this.RatchetRecords != that1.RatchetRecords {
+		return fmt.Errorf("RatchetRecords this(%v) Not Equal that(%v)", this.RatchetRecords, that1.RatchetRecords)
+	}
+	if this.RatchetIntervalMs != that1.RatchetIntervalMs {
+		return fmt.Errorf("RatchetIntervalMs this(%v) Not Equal that(%v)", this.RatchetIntervalMs, that1.RatchetIntervalMs)
+	}
+	return nil
+}
+func (this *Handshake) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Handshake)
+	if !ok {
+		that2, ok := that.(Handshake)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return false
+	}
+	if !this.Offer.Equal(that1.Offer) {
+		return false
+	}
+	if !this.Echo.Equal(that1.Echo) {
+		return false
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	if !bytes.Equal(this.SessionToken, that1.SessionToken) {
+		return false
+	}
+	if this.Resumed != that1.Resumed {
+		return false
+	}
+	if this.Control != that1.Control {
+		return false
+	}
+	if !this.Affinity.Equal(that1.Affinity) {
+		return false
+	}
+	if !bytes.Equal(this.AffinityToken, that1.AffinityToken) {
+		return false
+	}
+	if !this.Puzzle.Equal(that1.Puzzle) {
+		return false
+	}
+	if !bytes.Equal(this.PuzzleSolution, that1.PuzzleSolution) {
+		return false
+	}
+	if !bytes.Equal(this.EphemeralKey, that1.EphemeralKey) {
+		return false
+	}
+	if this.SignatureScheme != that1.SignatureScheme {
+		return false
+	}
+	if this.RatchetRecords != that1.RatchetRecords {
+		return false
+	}
+	if this.RatchetIntervalMs != that1.RatchetIntervalMs {
+		return false
+	}
+	return true
+}
+func (this *PeerRecord) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*PeerRecord)
+	if !ok {
+		that2, ok := that.(PeerRecord)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *PeerRecord")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *PeerRecord but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *PeerRecord but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if len(this.Addresses) != len(that1.Addresses) {
+		return fmt.Errorf("Addresses this(%v) Not Equal that(%v)", len(this.Addresses), len(that1.Addresses))
+	}
+	for i := range this.Addresses {
+		if this.Addresses[i] != that1.Addresses[i] {
+			return fmt.Errorf("Addresses this[%v](%v) Not Equal that[%v](%v)", i, this.Addresses[i], i, that1.Addresses[i])
+		}
+	}
+	if this.LastSeen != that1.LastSeen {
+		return fmt.Errorf("LastSeen this(%v) Not Equal that(%v)", this.LastSeen, that1.LastSeen)
+	}
+	if len(this.Tags) != len(that1.Tags) {
+		return fmt.Errorf("Tags this(%v) Not Equal that(%v)", len(this.Tags), len(that1.Tags))
+	}
+	for i := range this.Tags {
+		if this.Tags[i] != that1.Tags[i] {
+			return fmt.Errorf("Tags this[%v](%v) Not Equal that[%v](%v)", i, this.Tags[i], i, that1.Tags[i])
+		}
+	}
+	if this.LastConnected != that1.LastConnected {
+		return fmt.Errorf("LastConnected this(%v) Not Equal that(%v)", this.LastConnected, that1.LastConnected)
+	}
+	if this.Connects != that1.Connects {
+		return fmt.Errorf("Connects this(%v) Not Equal that(%v)", this.Connects, that1.Connects)
+	}
+	if this.Failures != that1.Failures {
+		return fmt.Errorf("Failures this(%v) Not Equal that(%v)", this.Failures, that1.Failures)
+	}
+	if this.FailureStreak != that1.FailureStreak {
+		return fmt.Errorf("FailureStreak this(%v) Not Equal that(%v)", this.FailureStreak, that1.FailureStreak)
+	}
+	if this.Rtt != that1.Rtt {
+		return fmt.Errorf("Rtt this(%v) Not Equal that(%v)", this.Rtt, that1.Rtt)
+	}
+	return nil
+}
+func (this *PeerRecord) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*PeerRecord)
+	if !ok {
+		that2, ok := that.(PeerRecord)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if len(this.Addresses) != len(that1.Addresses) {
+		return false
+	}
+	for i := range this.Addresses {
+		if this.Addresses[i] != that1.Addresses[i] {
+			return false
+		}
+	}
+	if this.LastSeen != that1.LastSeen {
+		return false
+	}
+	if len(this.Tags) != len(that1.Tags) {
+		return false
+	}
+	for i := range this.Tags {
+		if this.Tags[i] != that1.Tags[i] {
+			return false
+		}
+	}
+	if this.LastConnected != that1.LastConnected {
+		return false
+	}
+	if this.Connects != that1.Connects {
+		return false
+	}
+	if this.Failures != that1.Failures {
+		return false
+	}
+	if this.FailureStreak != that1.FailureStreak {
+		return false
+	}
+	if this.Rtt != that1.Rtt {
+		return false
+	}
+	return true
+}
+func (this *PeerBundle) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*PeerBundle)
+	if !ok {
+		that2, ok := that.(PeerBundle)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *PeerBundle")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *PeerBundle but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *PeerBundle but is not nil && this == nil")
+	}
+	if len(this.Records) != len(that1.Records) {
+		return fmt.Errorf("Records this(%v) Not Equal that(%v)", len(this.Records), len(that1.Records))
+	}
+	for i := range this.Records {
+		if !this.Records[i].Equal(that1.Records[i]) {
+			return fmt.Errorf("Records this[%v](%v) Not Equal that[%v](%v)", i, this.Records[i], i, that1.Records[i])
+		}
+	}
+	if this.CreatedAt != that1.CreatedAt {
+		return fmt.Errorf("CreatedAt this(%v) Not Equal that(%v)", this.CreatedAt, that1.CreatedAt)
+	}
+	if this.MaxAge != that1.MaxAge {
+		return fmt.Errorf("MaxAge this(%v) Not Equal that(%v)", this.MaxAge, that1.MaxAge)
+	}
+	return nil
+}
+func (this *PeerBundle) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*PeerBundle)
+	if !ok {
+		that2, ok := that.(PeerBundle)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Records) != len(that1.Records) {
+		return false
+	}
+	for i := range this.Records {
+		if !this.Records[i].Equal(that1.Records[i]) {
+			return false
+		}
+	}
+	if this.CreatedAt != that1.CreatedAt {
+		return false
+	}
+	if this.MaxAge != that1.MaxAge {
+		return false
+	}
+	return true
+}
+func (this *SignedPeerBundle) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*SignedPeerBundle)
+	if !ok {
+		that2, ok := that.(SignedPeerBundle)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *SignedPeerBundle")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *SignedPeerBundle but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *SignedPeerBundle but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Bundle, that1.Bundle) {
+		return fmt.Errorf("Bundle this(%v) Not Equal that(%v)", this.Bundle, that1.Bundle)
+	}
+	if !bytes.Equal(this.Signer, that1.Signer) {
+		return fmt.Errorf("Signer this(%v) Not Equal that(%v)", this.Signer, that1.Signer)
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	return nil
+}
+
+func (this *ServiceRecord) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ServiceRecord)
+	if !ok {
+		that2, ok := that.(ServiceRecord)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ServiceRecord")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ServiceRecord but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ServiceRecord but is not nil && this == nil")
+	}
+	if this.Name != that1.Name {
+		return fmt.Errorf("Name this(%v) Not Equal that(%v)", this.Name, that1.Name)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return fmt.Errorf("Metadata this(%v) Not Equal that(%v)", len(this.Metadata), len(that1.Metadata))
+	}
+	for i := range this.Metadata {
+		if !this.Metadata[i].Equal(that1.Metadata[i]) {
+			return fmt.Errorf("Metadata this[%v](%v) Not Equal that[%v](%v)", i, this.Metadata[i], i, that1.Metadata[i])
+		}
+	}
+	return nil
+}
+
+func (this *ServiceRecords) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ServiceRecords)
+	if !ok {
+		that2, ok := that.(ServiceRecords)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ServiceRecords")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ServiceRecords but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ServiceRecords but is not nil && this == nil")
+	}
+	if len(this.Records) != len(that1.Records) {
+		return fmt.Errorf("Records this(%v) Not Equal that(%v)", len(this.Records), len(that1.Records))
+	}
+	for i := range this.Records {
+		if !this.Records[i].Equal(that1.Records[i]) {
+			return fmt.Errorf("Records this[%v](%v) Not Equal that[%v](%v)", i, this.Records[i], i, that1.Records[i])
+		}
+	}
+	if this.Version != that1.Version {
+		return fmt.Errorf("Version this(%v) Not Equal that(%v)", this.Version, that1.Version)
+	}
+	return nil
+}
+func (this *Rejection) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Rejection)
+	if !ok {
+		that2, ok := that.(Rejection)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Rejection")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Rejection but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Rejection but is not nil && this == nil")
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return fmt.Errorf("RequestNonce this(%v) Not Equal that(%v)", this.RequestNonce, that1.RequestNonce)
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return fmt.Errorf("MessageNonce this(%v) Not Equal that(%v)", this.MessageNonce, that1.MessageNonce)
+	}
+	if !bytes.Equal(this.Hash, that1.Hash) {
+		return fmt.Errorf("Hash this(%v) Not Equal that(%v)", this.Hash, that1.Hash)
+	}
+	if this.Reason != that1.Reason {
+		return fmt.Errorf("Reason this(%v) Not Equal that(%v)", this.Reason, that1.Reason)
+	}
+	return nil
+}
+func (this *Routed) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Routed)
+	if !ok {
+		that2, ok := that.(Routed)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Routed")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Routed but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Routed but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Key, that1.Key) {
+		return fmt.Errorf("Key this(%v) Not Equal that(%v)", this.Key, that1.Key)
+	}
+	if this.Hops != that1.Hops {
+		return fmt.Errorf("Hops this(%v) Not Equal that(%v)", this.Hops, that1.Hops)
+	}
+	if this.MaxHops != that1.MaxHops {
+		return fmt.Errorf("MaxHops this(%v) Not Equal that(%v)", this.MaxHops, that1.MaxHops)
+	}
+	if !this.Message.Equal(that1.Message) {
+		return fmt.Errorf("Message this(%v) Not Equal that(%v)", this.Message, that1.Message)
+	}
+	return nil
+}
+func (this *Hint) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Hint)
+	if !ok {
+		that2, ok := that.(Hint)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Hint")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Hint but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Hint but is not nil && this == nil")
+	}
+	if this.Key != that1.Key {
+		return fmt.Errorf("Key this(%v) Not Equal that(%v)", this.Key, that1.Key)
+	}
+	if !bytes.Equal(this.Value, that1.Value) {
+		return fmt.Errorf("Value this(%v) Not Equal that(%v)", this.Value, that1.Value)
+	}
+	return nil
+}
+func (this *ReachabilityProbe) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ReachabilityProbe)
+	if !ok {
+		that2, ok := that.(ReachabilityProbe)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ReachabilityProbe")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ReachabilityProbe but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ReachabilityProbe but is not nil && this == nil")
+	}
+	return nil
+}
+func (this *ReachabilityResult) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ReachabilityResult)
+	if !ok {
+		that2, ok := that.(ReachabilityResult)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ReachabilityResult")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ReachabilityResult but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ReachabilityResult but is not nil && this == nil")
+	}
+	if this.Reachable != that1.Reachable {
+		return fmt.Errorf("Reachable this(%v) Not Equal that(%v)", this.Reachable, that1.Reachable)
+	}
+	if this.Refused != that1.Refused {
+		return fmt.Errorf("Refused this(%v) Not Equal that(%v)", this.Refused, that1.Refused)
+	}
+	return nil
+}
+func (this *UpgradeAnnouncement) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*UpgradeAnnouncement)
+	if !ok {
+		that2, ok := that.(UpgradeAnnouncement)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *UpgradeAnnouncement")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *UpgradeAnnouncement but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *UpgradeAnnouncement but is not nil && this == nil")
+	}
+	if this.Version != that1.Version {
+		return fmt.Errorf("Version this(%v) Not Equal that(%v)", this.Version, that1.Version)
+	}
+	if this.Activation != that1.Activation {
+		return fmt.Errorf("Activation this(%v) Not Equal that(%v)", this.Activation, that1.Activation)
+	}
+	if this.Note != that1.Note {
+		return fmt.Errorf("Note this(%v) Not Equal that(%v)", this.Note, that1.Note)
+	}
+	if this.IssuedAt != that1.IssuedAt {
+		return fmt.Errorf("IssuedAt this(%v) Not Equal that(%v)", this.IssuedAt, that1.IssuedAt)
+	}
+	return nil
+}
+func (this *SignedUpgradeAnnouncement) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*SignedUpgradeAnnouncement)
+	if !ok {
+		that2, ok := that.(SignedUpgradeAnnouncement)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *SignedUpgradeAnnouncement")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *SignedUpgradeAnnouncement but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *SignedUpgradeAnnouncement but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Announcement, that1.Announcement) {
+		return fmt.Errorf("Announcement this(%v) Not Equal that(%v)", this.Announcement, that1.Announcement)
+	}
+	if !bytes.Equal(this.Signer, that1.Signer) {
+		return fmt.Errorf("Signer this(%v) Not Equal that(%v)", this.Signer, that1.Signer)
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	return nil
+}
+func (this *PeerSample) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*PeerSample)
+	if !ok {
+		that2, ok := that.(PeerSample)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *PeerSample")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *PeerSample but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *PeerSample but is not nil && this == nil")
+	}
+	if !this.Id.Equal(that1.Id) {
+		return fmt.Errorf("Id this(%v) Not Equal that(%v)", this.Id, that1.Id)
+	}
+	if this.Age != that1.Age {
+		return fmt.Errorf("Age this(%v) Not Equal that(%v)", this.Age, that1.Age)
+	}
+	return nil
+}
+func (this *ShuffleRequest) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ShuffleRequest)
+	if !ok {
+		that2, ok := that.(ShuffleRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ShuffleRequest")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ShuffleRequest but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ShuffleRequest but is not nil && this == nil")
+	}
+	if len(this.Samples) != len(that1.Samples) {
+		return fmt.Errorf("Samples this(%v) Not Equal that(%v)", len(this.Samples), len(that1.Samples))
+	}
+	for i := range this.Samples {
+		if !this.Samples[i].Equal(that1.Samples[i]) {
+			return fmt.Errorf("Samples this[%v](%v) Not Equal that[%v](%v)", i, this.Samples[i], i, that1.Samples[i])
+		}
+	}
+	return nil
+}
+func (this *ShuffleResponse) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*ShuffleResponse)
+	if !ok {
+		that2, ok := that.(ShuffleResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *ShuffleResponse")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *ShuffleResponse but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *ShuffleResponse but is not nil && this == nil")
+	}
+	if len(this.Samples) != len(that1.Samples) {
+		return fmt.Errorf("Samples this(%v) Not Equal that(%v)", len(this.Samples), len(that1.Samples))
+	}
+	for i := range this.Samples {
+		if !this.Samples[i].Equal(that1.Samples[i]) {
+			return fmt.Errorf("Samples this[%v](%v) Not Equal that[%v](%v)", i, this.Samples[i], i, that1.Samples[i])
+		}
+	}
+	return nil
+}
+func (this *DiagnosticsRequest) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*DiagnosticsRequest)
+	if !ok {
+		that2, ok := that.(DiagnosticsRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *DiagnosticsRequest")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *DiagnosticsRequest but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *DiagnosticsRequest but is not nil && this == nil")
+	}
+	if this.SentAt != that1.SentAt {
+		return fmt.Errorf("SentAt this(%v) Not Equal that(%v)", this.SentAt, that1.SentAt)
+	}
+	return nil
+}
+func (this *DiagnosticsReport) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*DiagnosticsReport)
+	if !ok {
+		that2, ok := that.(DiagnosticsReport)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *DiagnosticsReport")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *DiagnosticsReport but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *DiagnosticsReport but is not nil && this == nil")
+	}
+	if this.Refused != that1.Refused {
+		return fmt.Errorf("Refused this(%v) Not Equal that(%v)", this.Refused, that1.Refused)
+	}
+	if this.Rtt != that1.Rtt {
+		return fmt.Errorf("Rtt this(%v) Not Equal that(%v)", this.Rtt, that1.Rtt)
+	}
+	if this.ObservedAddress != that1.ObservedAddress {
+		return fmt.Errorf("ObservedAddress this(%v) Not Equal that(%v)", this.ObservedAddress, that1.ObservedAddress)
+	}
+	if this.MessagesSent != that1.MessagesSent {
+		return fmt.Errorf("MessagesSent this(%v) Not Equal that(%v)", this.MessagesSent, that1.MessagesSent)
+	}
+	if this.MessagesReceived != that1.MessagesReceived {
+		return fmt.Errorf("MessagesReceived this(%v) Not Equal that(%v)", this.MessagesReceived, that1.MessagesReceived)
+	}
+	if this.BytesSent != that1.BytesSent {
+		return fmt.Errorf("BytesSent this(%v) Not Equal that(%v)", this.BytesSent, that1.BytesSent)
+	}
+	if this.BytesReceived != that1.BytesReceived {
+		return fmt.Errorf("BytesReceived this(%v) Not Equal that(%v)", this.BytesReceived, that1.BytesReceived)
+	}
+	if this.Violations != that1.Violations {
+		return fmt.Errorf("Violations this(%v) Not Equal that(%v)", this.Violations, that1.Violations)
+	}
+	if this.Quarantined != that1.Quarantined {
+		return fmt.Errorf("Quarantined this(%v) Not Equal that(%v)", this.Quarantined, that1.Quarantined)
+	}
+	if this.Time != that1.Time {
+		return fmt.Errorf("Time this(%v) Not Equal that(%v)", this.Time, that1.Time)
+	}
+	return nil
+}
+func (this *Goodbye) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Goodbye)
+	if !ok {
+		that2, ok := that.(Goodbye)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Goodbye")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Goodbye but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Goodbye but is not nil && this == nil")
+	}
+	if this.RetryAfter != that1.RetryAfter {
+		return fmt.Errorf("RetryAfter this(%v) Not Equal that(%v)", this.RetryAfter, that1.RetryAfter)
+	}
+	return nil
+}
+func (this *HandoverSession) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandoverSession)
+	if !ok {
+		that2, ok := that.(HandoverSession)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandoverSession")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandoverSession but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandoverSession but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Token, that1.Token) {
+		return fmt.Errorf("Token this(%v) Not Equal that(%v)", this.Token, that1.Token)
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if this.Version != that1.Version {
+		return fmt.Errorf("Version this(%v) Not Equal that(%v)", this.Version, that1.Version)
+	}
+	if !this.Offer.Equal(that1.Offer) {
+		return fmt.Errorf("Offer this(%v) Not Equal that(%v)", this.Offer, that1.Offer)
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return fmt.Errorf("MessageNonce this(%v) Not Equal that(%v)", this.MessageNonce, that1.MessageNonce)
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return fmt.Errorf("RequestNonce this(%v) Not Equal that(%v)", this.RequestNonce, that1.RequestNonce)
+	}
+	if this.Expiry != that1.Expiry {
+		return fmt.Errorf("Expiry this(%v) Not Equal that(%v)", this.Expiry, that1.Expiry)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if !bytes.Equal(this.Resumption, that1.Resumption) {
+		return fmt.Errorf("Resumption this(%v) Not Equal that(%v)", this.Resumption, that1.Resumption)
+	}
+	return nil
+}
+func (this *HandoverAddress) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandoverAddress)
+	if !ok {
+		that2, ok := that.(HandoverAddress)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandoverAddress")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandoverAddress but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandoverAddress but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if this.Status != that1.Status {
+		return fmt.Errorf("Status this(%v) Not Equal that(%v)", this.Status, that1.Status)
+	}
+	if this.Expiry != that1.Expiry {
+		return fmt.Errorf("Expiry this(%v) Not Equal that(%v)", this.Expiry, that1.Expiry)
+	}
+	return nil
+}
+func (this *HandoverPlugin) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandoverPlugin)
+	if !ok {
+		that2, ok := that.(HandoverPlugin)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandoverPlugin")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandoverPlugin but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandoverPlugin but is not nil && this == nil")
+	}
+	if this.Name != that1.Name {
+		return fmt.Errorf("Name this(%v) Not Equal that(%v)", this.Name, that1.Name)
+	}
+	if !bytes.Equal(this.State, that1.State) {
+		return fmt.Errorf("State this(%v) Not Equal that(%v)", this.State, that1.State)
+	}
+	return nil
+}
+func (this *HandoverState) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandoverState)
+	if !ok {
+		that2, ok := that.(HandoverState)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandoverState")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandoverState but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandoverState but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return fmt.Errorf("PublicKey this(%v) Not Equal that(%v)", this.PublicKey, that1.PublicKey)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if this.CreatedAt != that1.CreatedAt {
+		return fmt.Errorf("CreatedAt this(%v) Not Equal that(%v)", this.CreatedAt, that1.CreatedAt)
+	}
+	if len(this.Peers) != len(that1.Peers) {
+		return fmt.Errorf("Peers this(%v) Not Equal that(%v)", len(this.Peers), len(that1.Peers))
+	}
+	for i := range this.Peers {
+		if !this.Peers[i].Equal(that1.Peers[i]) {
+			return fmt.Errorf("Peers this[%v](%v) Not Equal that[%v](%v)", i, this.Peers[i], i, that1.Peers[i])
+		}
+	}
+	if len(this.Issued) != len(that1.Issued) {
+		return fmt.Errorf("Issued this(%v) Not Equal that(%v)", len(this.Issued), len(that1.Issued))
+	}
+	for i := range this.Issued {
+		if !this.Issued[i].Equal(that1.Issued[i]) {
+			return fmt.Errorf("Issued this[%v](%v) Not Equal that[%v](%v)", i, this.Issued[i], i, that1.Issued[i])
+		}
+	}
+	if len(this.Held) != len(that1.Held) {
+		return fmt.Errorf("Held this(%v) Not Equal that(%v)", len(this.Held), len(that1.Held))
+	}
+	for i := range this.Held {
+		if !this.Held[i].Equal(that1.Held[i]) {
+			return fmt.Errorf("Held this[%v](%v) Not Equal that[%v](%v)", i, this.Held[i], i, that1.Held[i])
+		}
+	}
+	if len(this.Addresses) != len(that1.Addresses) {
+		return fmt.Errorf("Addresses this(%v) Not Equal that(%v)", len(this.Addresses), len(that1.Addresses))
+	}
+	for i := range this.Addresses {
+		if !this.Addresses[i].Equal(that1.Addresses[i]) {
+			return fmt.Errorf("Addresses this[%v](%v) Not Equal that[%v](%v)", i, this.Addresses[i], i, that1.Addresses[i])
+		}
+	}
+	if this.Reachability != that1.Reachability {
+		return fmt.Errorf("Reachability this(%v) Not Equal that(%v)", this.Reachability, that1.Reachability)
+	}
+	if len(this.ReachabilityOutcomes) != len(that1.ReachabilityOutcomes) {
+		return fmt.Errorf("ReachabilityOutcomes this(%v) Not Equal that(%v)", len(this.ReachabilityOutcomes), len(that1.ReachabilityOutcomes))
+	}
+	for i := range this.ReachabilityOutcomes {
+		if this.ReachabilityOutcomes[i] != that1.ReachabilityOutcomes[i] {
+			return fmt.Errorf("ReachabilityOutcomes this[%v](%v) Not Equal that[%v](%v)", i, this.ReachabilityOutcomes[i], i, that1.ReachabilityOutcomes[i])
+		}
+	}
+	if this.ReachabilityChecked != that1.ReachabilityChecked {
+		return fmt.Errorf("ReachabilityChecked this(%v) Not Equal that(%v)", this.ReachabilityChecked, that1.ReachabilityChecked)
+	}
+	if len(this.Plugins) != len(that1.Plugins) {
+		return fmt.Errorf("Plugins this(%v) Not Equal that(%v)", len(this.Plugins), len(that1.Plugins))
+	}
+	for i := range this.Plugins {
+		if !this.Plugins[i].Equal(that1.Plugins[i]) {
+			return fmt.Errorf("Plugins this[%v](%v) Not Equal that[%v](%v)", i, this.Plugins[i], i, that1.Plugins[i])
+		}
+	}
+	return nil
+}
+func (this *JournalEntry) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*JournalEntry)
+	if !ok {
+		that2, ok := that.(JournalEntry)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *JournalEntry")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *JournalEntry but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *JournalEntry but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Frame, that1.Frame) {
+		return fmt.Errorf("Frame this(%v) Not Equal that(%v)", this.Frame, that1.Frame)
+	}
+	if !this.Payload.Equal(that1.Payload) {
+		return fmt.Errorf("Payload this(%v) Not Equal that(%v)", this.Payload, that1.Payload)
+	}
+	if this.ReceivedAt != that1.ReceivedAt {
+		return fmt.Errorf("ReceivedAt this(%v) Not Equal that(%v)", this.ReceivedAt, that1.ReceivedAt)
+	}
+	return nil
+}
+func (this *HandshakeExtension) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandshakeExtension)
+	if !ok {
+		that2, ok := that.(HandshakeExtension)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandshakeExtension")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandshakeExtension but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandshakeExtension but is not nil && this == nil")
+	}
+	if this.Name != that1.Name {
+		return fmt.Errorf("Name this(%v) Not Equal that(%v)", this.Name, that1.Name)
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return fmt.Errorf("Payload this(%v) Not Equal that(%v)", this.Payload, that1.Payload)
+	}
+	return nil
+}
+func (this *HandshakeExtensions) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandshakeExtensions)
+	if !ok {
+		that2, ok := that.(HandshakeExtensions)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandshakeExtensions")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandshakeExtensions but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandshakeExtensions but is not nil && this == nil")
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return fmt.Errorf("Sender this(%v) Not Equal that(%v)", this.Sender, that1.Sender)
+	}
+	if len(this.Extensions) != len(that1.Extensions) {
+		return fmt.Errorf("Extensions this(%v) Not Equal that(%v)", len(this.Extensions), len(that1.Extensions))
+	}
+	for i := range this.Extensions {
+		if !this.Extensions[i].Equal(that1.Extensions[i]) {
+			return fmt.Errorf("Extensions this[%v](%v) Not Equal that[%v](%v)", i, this.Extensions[i], i, that1.Extensions[i])
+		}
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return fmt.Errorf("Signature this(%v) Not Equal that(%v)", this.Signature, that1.Signature)
+	}
+	return nil
+}
+func (this *Affinity) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Affinity)
+	if !ok {
+		that2, ok := that.(Affinity)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Affinity")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Affinity but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Affinity but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Token, that1.Token) {
+		return fmt.Errorf("Token this(%v) Not Equal that(%v)", this.Token, that1.Token)
+	}
+	if this.Address != that1.Address {
+		return fmt.Errorf("Address this(%v) Not Equal that(%v)", this.Address, that1.Address)
+	}
+	if this.Lifetime != that1.Lifetime {
+		return fmt.Errorf("Lifetime this(%v) Not Equal that(%v)", this.Lifetime, that1.Lifetime)
+	}
+	return nil
+}
+func (this *AffinityRevoked) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*AffinityRevoked)
+	if !ok {
+		that2, ok := that.(AffinityRevoked)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *AffinityRevoked")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *AffinityRevoked but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *AffinityRevoked but is not nil && this == nil")
+	}
+	if len(this.Tokens) != len(that1.Tokens) {
+		return fmt.Errorf("Tokens this(%v) Not Equal that(%v)", len(this.Tokens), len(that1.Tokens))
+	}
+	for i := range this.Tokens {
+		if !bytes.Equal(this.Tokens[i], that1.Tokens[i]) {
+			return fmt.Errorf("Tokens this[%v](%v) Not Equal that[%v](%v)", i, this.Tokens[i], i, that1.Tokens[i])
+		}
+	}
+	return nil
+}
+func (this *HandshakePuzzle) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*HandshakePuzzle)
+	if !ok {
+		that2, ok := that.(HandshakePuzzle)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *HandshakePuzzle")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *HandshakePuzzle but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *HandshakePuzzle but is not nil && this == nil")
+	}
+	if !bytes.Equal(this.Seed, that1.Seed) {
+		return fmt.Errorf("Seed this(%v) Not Equal that(%v)", this.Seed, that1.Seed)
+	}
+	if this.Difficulty != that1.Difficulty {
+		return fmt.Errorf("Difficulty this(%v) Not Equal that(%v)", this.Difficulty, that1.Difficulty)
+	}
+	return nil
+}
+func (this *Chunk) VerboseEqual(that interface{}) error {
+	if that == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that == nil && this != nil")
+	}
+
+	that1, ok := that.(*Chunk)
+	if !ok {
+		that2, ok := that.(Chunk)
+		if ok {
+			that1 = &that2
+		} else {
+			return fmt.Errorf("that is not of type *Chunk")
+		}
+	}
+	if that1 == nil {
+		if this == nil {
+			return nil
+		}
+		return fmt.Errorf("that is type *Chunk but is nil && this != nil")
+	} else if this == nil {
+		return fmt.Errorf("that is type *Chunk but is not nil && this == nil")
+	}
+	if this.Transfer != that1.Transfer {
+		return fmt.Errorf("Transfer this(%v) Not Equal that(%v)", this.Transfer, that1.Transfer)
+	}
+	if this.Index != that1.Index {
+		return fmt.Errorf("Index this(%v) Not Equal that(%v)", this.Index, that1.Index)
+	}
+	if this.Count != that1.Count {
+		return fmt.Errorf("Count this(%v) Not Equal that(%v)", this.Count, that1.Count)
+	}
+	if this.Size_ != that1.Size_ {
+		return fmt.Errorf("Size_ this(%v) Not Equal that(%v)", this.Size_, that1.Size_)
+	}
+	if !bytes.Equal(this.Data, that1.Data) {
+		return fmt.Errorf("Data this(%v) Not Equal that(%v)", this.Data, that1.Data)
+	}
+	return nil
+}
+func (this *SignedPeerBundle) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SignedPeerBundle)
+	if !ok {
+		that2, ok := that.(SignedPeerBundle)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Bundle, that1.Bundle) {
+		return false
+	}
+	if !bytes.Equal(this.Signer, that1.Signer) {
+		return false
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	return true
+}
+
+func (this *ServiceRecord) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ServiceRecord)
+	if !ok {
+		that2, ok := that.(ServiceRecord)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Name != that1.Name {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if len(this.Metadata) != len(that1.Metadata) {
+		return false
+	}
+	for i := range this.Metadata {
+		if !this.Metadata[i].Equal(that1.Metadata[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *ServiceRecords) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ServiceRecords)
+	if !ok {
+		that2, ok := that.(ServiceRecords)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Records) != len(that1.Records) {
+		return false
+	}
+	for i := range this.Records {
+		if !this.Records[i].Equal(that1.Records[i]) {
+			return false
+		}
+	}
+	if this.Version != that1.Version {
+		return false
+	}
+	return true
+}
+func (this *Rejection) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Rejection)
+	if !ok {
+		that2, ok := that.(Rejection)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return false
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return false
+	}
+	if !bytes.Equal(this.Hash, that1.Hash) {
+		return false
+	}
+	if this.Reason != that1.Reason {
+		return false
+	}
+	return true
+}
+func (this *Routed) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Routed)
+	if !ok {
+		that2, ok := that.(Routed)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Key, that1.Key) {
+		return false
+	}
+	if this.Hops != that1.Hops {
+		return false
+	}
+	if this.MaxHops != that1.MaxHops {
+		return false
+	}
+	if !this.Message.Equal(that1.Message) {
+		return false
+	}
+	return true
+}
+func (this *Hint) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Hint)
+	if !ok {
+		that2, ok := that.(Hint)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Key != that1.Key {
+		return false
+	}
+	if !bytes.Equal(this.Value, that1.Value) {
+		return false
+	}
+	return true
+}
+func (this *ReachabilityProbe) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ReachabilityProbe)
+	if !ok {
+		that2, ok := that.(ReachabilityProbe)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	return true
+}
+func (this *ReachabilityResult) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ReachabilityResult)
+	if !ok {
+		that2, ok := that.(ReachabilityResult)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Reachable != that1.Reachable {
+		return false
+	}
+	if this.Refused != that1.Refused {
+		return false
+	}
+	return true
+}
+func (this *UpgradeAnnouncement) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*UpgradeAnnouncement)
+	if !ok {
+		that2, ok := that.(UpgradeAnnouncement)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Version != that1.Version {
+		return false
+	}
+	if this.Activation != that1.Activation {
+		return false
+	}
+	if this.Note != that1.Note {
+		return false
+	}
+	if this.IssuedAt != that1.IssuedAt {
+		return false
+	}
+	return true
+}
+func (this *SignedUpgradeAnnouncement) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SignedUpgradeAnnouncement)
+	if !ok {
+		that2, ok := that.(SignedUpgradeAnnouncement)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Announcement, that1.Announcement) {
+		return false
+	}
+	if !bytes.Equal(this.Signer, that1.Signer) {
+		return false
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	return true
+}
+func (this *PeerSample) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*PeerSample)
+	if !ok {
+		that2, ok := that.(PeerSample)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Id.Equal(that1.Id) {
+		return false
+	}
+	if this.Age != that1.Age {
+		return false
+	}
+	return true
+}
+func (this *ShuffleRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ShuffleRequest)
+	if !ok {
+		that2, ok := that.(ShuffleRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Samples) != len(that1.Samples) {
+		return false
+	}
+	for i := range this.Samples {
+		if !this.Samples[i].Equal(that1.Samples[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *ShuffleResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ShuffleResponse)
+	if !ok {
+		that2, ok := that.(ShuffleResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Samples) != len(that1.Samples) {
+		return false
+	}
+	for i := range this.Samples {
+		if !this.Samples[i].Equal(that1.Samples[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *DiagnosticsRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*DiagnosticsRequest)
+	if !ok {
+		that2, ok := that.(DiagnosticsRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.SentAt != that1.SentAt {
+		return false
+	}
+	return true
+}
+func (this *DiagnosticsReport) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*DiagnosticsReport)
+	if !ok {
+		that2, ok := that.(DiagnosticsReport)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Refused != that1.Refused {
+		return false
+	}
+	if this.Rtt != that1.Rtt {
+		return false
+	}
+	if this.ObservedAddress != that1.ObservedAddress {
+		return false
+	}
+	if this.MessagesSent != that1.MessagesSent {
+		return false
+	}
+	if this.MessagesReceived != that1.MessagesReceived {
+		return false
+	}
+	if this.BytesSent != that1.BytesSent {
+		return false
+	}
+	if this.BytesReceived != that1.BytesReceived {
+		return false
+	}
+	if this.Violations != that1.Violations {
+		return false
+	}
+	if this.Quarantined != that1.Quarantined {
+		return false
+	}
+	if this.Time != that1.Time {
+		return false
+	}
+	return true
+}
+func (this *Goodbye) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Goodbye)
+	if !ok {
+		that2, ok := that.(Goodbye)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.RetryAfter != that1.RetryAfter {
+		return false
+	}
+	return true
+}
+func (this *HandoverSession) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandoverSession)
+	if !ok {
+		that2, ok := that.(HandoverSession)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Token, that1.Token) {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if this.Version != that1.Version {
+		return false
+	}
+	if !this.Offer.Equal(that1.Offer) {
+		return false
+	}
+	if this.MessageNonce != that1.MessageNonce {
+		return false
+	}
+	if this.RequestNonce != that1.RequestNonce {
+		return false
+	}
+	if this.Expiry != that1.Expiry {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if !bytes.Equal(this.Resumption, that1.Resumption) {
+		return false
+	}
+	return true
+}
+func (this *HandoverAddress) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandoverAddress)
+	if !ok {
+		that2, ok := that.(HandoverAddress)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if this.Status != that1.Status {
+		return false
+	}
+	if this.Expiry != that1.Expiry {
+		return false
+	}
+	return true
+}
+func (this *HandoverPlugin) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandoverPlugin)
+	if !ok {
+		that2, ok := that.(HandoverPlugin)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Name != that1.Name {
+		return false
+	}
+	if !bytes.Equal(this.State, that1.State) {
+		return false
+	}
+	return true
+}
+func (this *HandoverState) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandoverState)
+	if !ok {
+		that2, ok := that.(HandoverState)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.PublicKey, that1.PublicKey) {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if this.CreatedAt != that1.CreatedAt {
+		return false
+	}
+	if len(this.Peers) != len(that1.Peers) {
+		return false
+	}
+	for i := range this.Peers {
+		if !this.Peers[i].Equal(that1.Peers[i]) {
+			return false
+		}
+	}
+	if len(this.Issued) != len(that1.Issued) {
+		return false
+	}
+	for i := range this.Issued {
+		if !this.Issued[i].Equal(that1.Issued[i]) {
+			return false
+		}
+	}
+	if len(this.Held) != len(that1.Held) {
+		return false
+	}
+	for i := range this.Held {
+		if !this.Held[i].Equal(that1.Held[i]) {
+			return false
+		}
+	}
+	if len(this.Addresses) != len(that1.Addresses) {
+		return false
+	}
+	for i := range this.Addresses {
+		if !this.Addresses[i].Equal(that1.Addresses[i]) {
+			return false
+		}
+	}
+	if this.Reachability != that1.Reachability {
+		return false
+	}
+	if len(this.ReachabilityOutcomes) != len(that1.ReachabilityOutcomes) {
+		return false
+	}
+	for i := range this.ReachabilityOutcomes {
+		if this.ReachabilityOutcomes[i] != that1.ReachabilityOutcomes[i] {
+			return false
+		}
+	}
+	if this.ReachabilityChecked != that1.ReachabilityChecked {
+		return false
+	}
+	if len(this.Plugins) != len(that1.Plugins) {
+		return false
+	}
+	for i := range this.Plugins {
+		if !this.Plugins[i].Equal(that1.Plugins[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *JournalEntry) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*JournalEntry)
+	if !ok {
+		that2, ok := that.(JournalEntry)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Frame, that1.Frame) {
+		return false
+	}
+	if !this.Payload.Equal(that1.Payload) {
+		return false
+	}
+	if this.ReceivedAt != that1.ReceivedAt {
+		return false
+	}
+	return true
+}
+func (this *HandshakeExtension) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandshakeExtension)
+	if !ok {
+		that2, ok := that.(HandshakeExtension)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Name != that1.Name {
+		return false
+	}
+	if !bytes.Equal(this.Payload, that1.Payload) {
+		return false
+	}
+	return true
+}
+func (this *HandshakeExtensions) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandshakeExtensions)
+	if !ok {
+		that2, ok := that.(HandshakeExtensions)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.Sender.Equal(that1.Sender) {
+		return false
+	}
+	if len(this.Extensions) != len(that1.Extensions) {
+		return false
+	}
+	for i := range this.Extensions {
+		if !this.Extensions[i].Equal(that1.Extensions[i]) {
+			return false
+		}
+	}
+	if !bytes.Equal(this.Signature, that1.Signature) {
+		return false
+	}
+	return true
+}
+func (this *Affinity) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Affinity)
+	if !ok {
+		that2, ok := that.(Affinity)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Token, that1.Token) {
+		return false
+	}
+	if this.Address != that1.Address {
+		return false
+	}
+	if this.Lifetime != that1.Lifetime {
+		return false
+	}
+	return true
+}
+func (this *AffinityRevoked) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*AffinityRevoked)
+	if !ok {
+		that2, ok := that.(AffinityRevoked)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Tokens) != len(that1.Tokens) {
+		return false
+	}
+	for i := range this.Tokens {
+		if !bytes.Equal(this.Tokens[i], that1.Tokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *HandshakePuzzle) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*HandshakePuzzle)
+	if !ok {
+		that2, ok := that.(HandshakePuzzle)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !bytes.Equal(this.Seed, that1.Seed) {
+		return false
+	}
+	if this.Difficulty != that1.Difficulty {
+		return false
+	}
+	return true
+}
+func (this *Chunk) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*Chunk)
+	if !ok {
+		that2, ok := that.(Chunk)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Transfer != that1.Transfer {
+		return false
+	}
+	if this.Index != that1.Index {
+		return false
+	}
+	if this.Count != that1.Count {
+		return false
+	}
+	if this.Size_ != that1.Size_ {
+		return false
+	}
+	if !bytes.Equal(this.Data, that1.Data) {
+		return false
+	}
+	return true
+}
+func (this *ID) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.ID{")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	s = append(s, "Id: "+fmt.Sprintf("%#v", this.Id)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Message) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 19)
+	s = append(s, "&protobuf.Message{")
+	if this.Message != nil {
+		s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
+	}
+	if this.Sender != nil {
+		s = append(s, "Sender: "+fmt.Sprintf("%#v", this.Sender)+",\n")
+	}
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "RequestNonce: "+fmt.Sprintf("%#v", this.RequestNonce)+",\n")
+	s = append(s, "MessageNonce: "+fmt.Sprintf("%#v", this.MessageNonce)+",\n")
+	s = append(s, "ReplyFlag: "+fmt.Sprintf("%#v", this.ReplyFlag)+",\n")
+	keysForMetadata := make([]string, 0, len(this.Metadata))
+	for k, _ := range this.Metadata {
+		keysForMetadata = append(keysForMetadata, k)
+	}
+	sortkeys.Strings(keysForMetadata)
+	mapStringForMetadata := "map[string]string{"
+	for _, k := range keysForMetadata {
+		mapStringForMetadata += fmt.Sprintf("%#v: %#v,", k, this.Metadata[k])
+	}
+	mapStringForMetadata += "}"
+	if this.Metadata != nil {
+		s = append(s, "Metadata: "+mapStringForMetadata+",\n")
+	}
+	if this.Signatures != nil {
+		s = append(s, "Signatures: "+fmt.Sprintf("%#v", this.Signatures)+",\n")
+	}
+	s = append(s, "CriticalExtensions: "+fmt.Sprintf("%#v", this.CriticalExtensions)+",\n")
+	s = append(s, "Protocol: "+fmt.Sprintf("%#v", this.Protocol)+",\n")
+	s = append(s, "BudgetMs: "+fmt.Sprintf("%#v", this.BudgetMs)+",\n")
+	if this.Hints != nil {
+		s = append(s, "Hints: "+fmt.Sprintf("%#v", this.Hints)+",\n")
+	}
+	s = append(s, "Padding: "+fmt.Sprintf("%#v", this.Padding)+",\n")
+	s = append(s, "ReplayNonce: "+fmt.Sprintf("%#v", this.ReplayNonce)+",\n")
+	s = append(s, "SignatureScheme: "+fmt.Sprintf("%#v", this.SignatureScheme)+",\n")
+	s = append(s, "ReplayStampMs: "+fmt.Sprintf("%#v", this.ReplayStampMs)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Signature) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.Signature{")
+	s = append(s, "Scheme: "+fmt.Sprintf("%#v", this.Scheme)+",\n")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Ping) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&protobuf.Ping{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Batch) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.Batch{")
+	if this.Payloads != nil {
+		s = append(s, "Payloads: "+fmt.Sprintf("%#v", this.Payloads)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Pong) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&protobuf.Pong{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Keepalive) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.Keepalive{")
+	s = append(s, "Payload: "+fmt.Sprintf("%#v", this.Payload)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *KeepaliveAck) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.KeepaliveAck{")
+	s = append(s, "Payload: "+fmt.Sprintf("%#v", this.Payload)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LookupNodeRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.LookupNodeRequest{")
+	if this.Target != nil {
+		s = append(s, "Target: "+fmt.Sprintf("%#v", this.Target)+",\n")
+	}
+	s = append(s, "Known: "+fmt.Sprintf("%#v", this.Known)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LookupNodeResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.LookupNodeResponse{")
+	if this.Peers != nil {
+		s = append(s, "Peers: "+fmt.Sprintf("%#v", this.Peers)+",\n")
+	}
+	if this.Compact != nil {
+		s = append(s, "Compact: "+fmt.Sprintf("%#v", this.Compact)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CompactPeers) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 9)
+	s = append(s, "&protobuf.CompactPeers{")
+	s = append(s, "PublicKeys: "+fmt.Sprintf("%#v", this.PublicKeys)+",\n")
+	s = append(s, "KeySize: "+fmt.Sprintf("%#v", this.KeySize)+",\n")
+	s = append(s, "Prefixes: "+fmt.Sprintf("%#v", this.Prefixes)+",\n")
+	s = append(s, "PrefixIndices: "+fmt.Sprintf("%#v", this.PrefixIndices)+",\n")
+	s = append(s, "Ports: "+fmt.Sprintf("%#v", this.Ports)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Bytes) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.Bytes{")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandshakeOffer) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&protobuf.HandshakeOffer{")
+	s = append(s, "Versions: "+fmt.Sprintf("%#v", this.Versions)+",\n")
+	s = append(s, "Capabilities: "+fmt.Sprintf("%#v", this.Capabilities)+",\n")
+	if this.Metadata != nil {
+		s = append(s, "Metadata: "+fmt.Sprintf("%#v", this.Metadata)+",\n")
+	}
+	if this.Services != nil {
+		s = append(s, "Services: "+fmt.Sprintf("%#v", this.Services)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandshakeMetadata) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.HandshakeMetadata{")
+	s = append(s, "Key: "+fmt.Sprintf("%#v", this.Key)+",\n")
+	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Handshake) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 19)
+	s = append(s, "&protobuf.Handshake{")
+	if this.Sender != nil {
+		s = append(s, "Sender: "+fmt.Sprintf("%#v", this.Sender)+",\n")
+	}
+	if this.Offer != nil {
+		s = append(s, "Offer: "+fmt.Sprintf("%#v", this.Offer)+",\n")
+	}
+	if this.Echo != nil {
+		s = append(s, "Echo: "+fmt.Sprintf("%#v", this.Echo)+",\n")
+	}
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "SessionToken: "+fmt.Sprintf("%#v", this.SessionToken)+",\n")
+	s = append(s, "Resumed: "+fmt.Sprintf("%#v", this.Resumed)+",\n")
+	s = append(s, "Control: "+fmt.Sprintf("%#v", this.Control)+",\n")
+	if this.Affinity != nil {
+		s = append(s, "Affinity: "+fmt.Sprintf("%#v", this.Affinity)+",\n")
+	}
+	s = append(s, "AffinityToken: "+fmt.Sprintf("%#v", this.AffinityToken)+",\n")
+	if this.Puzzle != nil {
+		s = append(s, "Puzzle: "+fmt.Sprintf("%#v", this.Puzzle)+",\n")
+	}
+	s = append(s, "PuzzleSolution: "+fmt.Sprintf("%#v", this.PuzzleSolution)+",\n")
+	s = append(s, "EphemeralKey: "+fmt.Sprintf("%#v", this.EphemeralKey)+",\n")
+	s = append(s, "SignatureScheme: "+fmt.Sprintf("%#v", this.SignatureScheme)+",\n")
+	s = append(s, "RatchetRecords: "+fmt.Sprintf("%#v", this.RatchetRecords)+",\n")
+	s = append(s, "RatchetIntervalMs: "+fmt.Sprintf("%#v", this.RatchetIntervalMs)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *PeerRecord) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 13)
+	s = append(s, "&protobuf.PeerRecord{")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Addresses: "+fmt.Sprintf("%#v", this.Addresses)+",\n")
+	s = append(s, "LastSeen: "+fmt.Sprintf("%#v", this.LastSeen)+",\n")
+	s = append(s, "Tags: "+fmt.Sprintf("%#v", this.Tags)+",\n")
+	s = append(s, "LastConnected: "+fmt.Sprintf("%#v", this.LastConnected)+",\n")
+	s = append(s, "Connects: "+fmt.Sprintf("%#v", this.Connects)+",\n")
+	s = append(s, "Failures: "+fmt.Sprintf("%#v", this.Failures)+",\n")
+	s = append(s, "FailureStreak: "+fmt.Sprintf("%#v", this.FailureStreak)+",\n")
+	s = append(s, "Rtt: "+fmt.Sprintf("%#v", this.Rtt)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *PeerBundle) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.PeerBundle{")
+	if this.Records != nil {
+		s = append(s, "Records: "+fmt.Sprintf("%#v", this.Records)+",\n")
+	}
+	s = append(s, "CreatedAt: "+fmt.Sprintf("%#v", this.CreatedAt)+",\n")
+	s = append(s, "MaxAge: "+fmt.Sprintf("%#v", this.MaxAge)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SignedPeerBundle) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.SignedPeerBundle{")
+	s = append(s, "Bundle: "+fmt.Sprintf("%#v", this.Bundle)+",\n")
+	s = append(s, "Signer: "+fmt.Sprintf("%#v", this.Signer)+",\n")
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+
+func (this *ServiceRecord) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.ServiceRecord{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	if this.Metadata != nil {
+		s = append(s, "Metadata: "+fmt.Sprintf("%#v", this.Metadata)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+
+func (this *ServiceRecords) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.ServiceRecords{")
+	if this.Records != nil {
+		s = append(s, "Records: "+fmt.Sprintf("%#v", this.Records)+",\n")
+	}
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Rejection) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&protobuf.Rejection{")
+	s = append(s, "RequestNonce: "+fmt.Sprintf("%#v", this.RequestNonce)+",\n")
+	s = append(s, "MessageNonce: "+fmt.Sprintf("%#v", this.MessageNonce)+",\n")
+	s = append(s, "Hash: "+fmt.Sprintf("%#v", this.Hash)+",\n")
+	s = append(s, "Reason: "+fmt.Sprintf("%#v", this.Reason)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Routed) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&protobuf.Routed{")
+	s = append(s, "Key: "+fmt.Sprintf("%#v", this.Key)+",\n")
+	s = append(s, "Hops: "+fmt.Sprintf("%#v", this.Hops)+",\n")
+	s = append(s, "MaxHops: "+fmt.Sprintf("%#v", this.MaxHops)+",\n")
+	if this.Message != nil {
+		s = append(s, "Message: "+fmt.Sprintf("%#v", this.Message)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Hint) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.Hint{")
+	s = append(s, "Key: "+fmt.Sprintf("%#v", this.Key)+",\n")
+	s = append(s, "Value: "+fmt.Sprintf("%#v", this.Value)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReachabilityProbe) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&protobuf.ReachabilityProbe{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReachabilityResult) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.ReachabilityResult{")
+	s = append(s, "Reachable: "+fmt.Sprintf("%#v", this.Reachable)+",\n")
+	s = append(s, "Refused: "+fmt.Sprintf("%#v", this.Refused)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *UpgradeAnnouncement) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&protobuf.UpgradeAnnouncement{")
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	s = append(s, "Activation: "+fmt.Sprintf("%#v", this.Activation)+",\n")
+	s = append(s, "Note: "+fmt.Sprintf("%#v", this.Note)+",\n")
+	s = append(s, "IssuedAt: "+fmt.Sprintf("%#v", this.IssuedAt)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SignedUpgradeAnnouncement) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.SignedUpgradeAnnouncement{")
+	s = append(s, "Announcement: "+fmt.Sprintf("%#v", this.Announcement)+",\n")
+	s = append(s, "Signer: "+fmt.Sprintf("%#v", this.Signer)+",\n")
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *PeerSample) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.PeerSample{")
+	if this.Id != nil {
+		s = append(s, "Id: "+fmt.Sprintf("%#v", this.Id)+",\n")
+	}
+	s = append(s, "Age: "+fmt.Sprintf("%#v", this.Age)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ShuffleRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.ShuffleRequest{")
+	if this.Samples != nil {
+		s = append(s, "Samples: "+fmt.Sprintf("%#v", this.Samples)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ShuffleResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.ShuffleResponse{")
+	if this.Samples != nil {
+		s = append(s, "Samples: "+fmt.Sprintf("%#v", this.Samples)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *DiagnosticsRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.DiagnosticsRequest{")
+	s = append(s, "SentAt: "+fmt.Sprintf("%#v", this.SentAt)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *DiagnosticsReport) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 14)
+	s = append(s, "&protobuf.DiagnosticsReport{")
+	s = append(s, "Refused: "+fmt.Sprintf("%#v", this.Refused)+",\n")
+	s = append(s, "Rtt: "+fmt.Sprintf("%#v", this.Rtt)+",\n")
+	s = append(s, "ObservedAddress: "+fmt.Sprintf("%#v", this.ObservedAddress)+",\n")
+	s = append(s, "MessagesSent: "+fmt.Sprintf("%#v", this.MessagesSent)+",\n")
+	s = append(s, "MessagesReceived: "+fmt.Sprintf("%#v", this.MessagesReceived)+",\n")
+	s = append(s, "BytesSent: "+fmt.Sprintf("%#v", this.BytesSent)+",\n")
+	s = append(s, "BytesReceived: "+fmt.Sprintf("%#v", this.BytesReceived)+",\n")
+	s = append(s, "Violations: "+fmt.Sprintf("%#v", this.Violations)+",\n")
+	s = append(s, "Quarantined: "+fmt.Sprintf("%#v", this.Quarantined)+",\n")
+	s = append(s, "Time: "+fmt.Sprintf("%#v", this.Time)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Goodbye) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.Goodbye{")
+	s = append(s, "RetryAfter: "+fmt.Sprintf("%#v", this.RetryAfter)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandoverSession) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 13)
+	s = append(s, "&protobuf.HandoverSession{")
+	s = append(s, "Token: "+fmt.Sprintf("%#v", this.Token)+",\n")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Version: "+fmt.Sprintf("%#v", this.Version)+",\n")
+	if this.Offer != nil {
+		s = append(s, "Offer: "+fmt.Sprintf("%#v", this.Offer)+",\n")
+	}
+	s = append(s, "MessageNonce: "+fmt.Sprintf("%#v", this.MessageNonce)+",\n")
+	s = append(s, "RequestNonce: "+fmt.Sprintf("%#v", this.RequestNonce)+",\n")
+	s = append(s, "Expiry: "+fmt.Sprintf("%#v", this.Expiry)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	s = append(s, "Resumption: "+fmt.Sprintf("%#v", this.Resumption)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandoverAddress) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&protobuf.HandoverAddress{")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	s = append(s, "Status: "+fmt.Sprintf("%#v", this.Status)+",\n")
+	s = append(s, "Expiry: "+fmt.Sprintf("%#v", this.Expiry)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandoverPlugin) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.HandoverPlugin{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "State: "+fmt.Sprintf("%#v", this.State)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandoverState) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 15)
+	s = append(s, "&protobuf.HandoverState{")
+	s = append(s, "PublicKey: "+fmt.Sprintf("%#v", this.PublicKey)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	s = append(s, "CreatedAt: "+fmt.Sprintf("%#v", this.CreatedAt)+",\n")
+	if this.Peers != nil {
+		s = append(s, "Peers: "+fmt.Sprintf("%#v", this.Peers)+",\n")
+	}
+	if this.Issued != nil {
+		s = append(s, "Issued: "+fmt.Sprintf("%#v", this.Issued)+",\n")
+	}
+	if this.Held != nil {
+		s = append(s, "Held: "+fmt.Sprintf("%#v", this.Held)+",\n")
+	}
+	if this.Addresses != nil {
+		s = append(s, "Addresses: "+fmt.Sprintf("%#v", this.Addresses)+",\n")
+	}
+	s = append(s, "Reachability: "+fmt.Sprintf("%#v", this.Reachability)+",\n")
+	s = append(s, "ReachabilityOutcomes: "+fmt.Sprintf("%#v", this.ReachabilityOutcomes)+",\n")
+	s = append(s, "ReachabilityChecked: "+fmt.Sprintf("%#v", this.ReachabilityChecked)+",\n")
+	if this.Plugins != nil {
+		s = append(s, "Plugins: "+fmt.Sprintf("%#v", this.Plugins)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *JournalEntry) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.JournalEntry{")
+	s = append(s, "Frame: "+fmt.Sprintf("%#v", this.Frame)+",\n")
+	if this.Payload != nil {
+		s = append(s, "Payload: "+fmt.Sprintf("%#v", this.Payload)+",\n")
+	}
+	s = append(s, "ReceivedAt: "+fmt.Sprintf("%#v", this.ReceivedAt)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandshakeExtension) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.HandshakeExtension{")
+	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
+	s = append(s, "Payload: "+fmt.Sprintf("%#v", this.Payload)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandshakeExtensions) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.HandshakeExtensions{")
+	if this.Sender != nil {
+		s = append(s, "Sender: "+fmt.Sprintf("%#v", this.Sender)+",\n")
+	}
+	if this.Extensions != nil {
+		s = append(s, "Extensions: "+fmt.Sprintf("%#v", this.Extensions)+",\n")
+	}
+	s = append(s, "Signature: "+fmt.Sprintf("%#v", this.Signature)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Affinity) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&protobuf.Affinity{")
+	s = append(s, "Token: "+fmt.Sprintf("%#v", this.Token)+",\n")
+	s = append(s, "Address: "+fmt.Sprintf("%#v", this.Address)+",\n")
+	s = append(s, "Lifetime: "+fmt.Sprintf("%#v", this.Lifetime)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *AffinityRevoked) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&protobuf.AffinityRevoked{")
+	s = append(s, "Tokens: "+fmt.Sprintf("%#v", this.Tokens)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *HandshakePuzzle) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&protobuf.HandshakePuzzle{")
+	s = append(s, "Seed: "+fmt.Sprintf("%#v", this.Seed)+",\n")
+	s = append(s, "Difficulty: "+fmt.Sprintf("%#v", this.Difficulty)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *Chunk) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 9)
+	s = append(s, "&protobuf.Chunk{")
+	s = append(s, "Transfer: "+fmt.Sprintf("%#v", this.Transfer)+",\n")
+	s = append(s, "Index: "+fmt.Sprintf("%#v", this.Index)+",\n")
+	s = append(s, "Count: "+fmt.Sprintf("%#v", this.Count)+",\n")
+	s = append(s, "Size_: "+fmt.Sprintf("%#v", this.Size_)+",\n")
+	s = append(s, "Data: "+fmt.Sprintf("%#v", this.Data)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringStream(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+func (m *ID) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ID) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if len(m.Id) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Id)))
+		i += copy(dAtA[i:], m.Id)
+	}
+	return i, nil
+}
+
+func (m *Message) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Message != nil {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Message.Size()))
+		n1, err := m.Message.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n1
+	}
+	if m.Sender != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Sender.Size()))
+		n2, err := m.Sender.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n2
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	if m.RequestNonce != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RequestNonce))
+	}
+	if m.MessageNonce != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MessageNonce))
+	}
+	if m.ReplyFlag {
+		dAtA[i] = 0x30
+		i++
+		if m.ReplyFlag {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Metadata) > 0 {
+		for k, _ := range m.Metadata {
+			dAtA[i] = 0x3a
+			i++
+			v := m.Metadata[k]
+			mapSize := 1 + len(k) + sovStream(uint64(len(k))) + 1 + len(v) + sovStream(uint64(len(v)))
+			i = encodeVarintStream(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.Signatures) > 0 {
+		for _, msg := range m.Signatures {
+			dAtA[i] = 0x42
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.CriticalExtensions) > 0 {
+		dAtA4 := make([]byte, len(m.CriticalExtensions)*10)
+		var j3 int
+		for _, num := range m.CriticalExtensions {
+			for num >= 1<<7 {
+				dAtA4[j3] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j3++
+			}
+			dAtA4[j3] = uint8(num)
+			j3++
+		}
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(j3))
+		i += copy(dAtA[i:], dAtA4[:j3])
+	}
+	if len(m.Protocol) > 0 {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Protocol)))
+		i += copy(dAtA[i:], m.Protocol)
+	}
+	if m.BudgetMs != 0 {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.BudgetMs))
+	}
+	if len(m.Hints) > 0 {
+		for _, msg := range m.Hints {
+			dAtA[i] = 0x62
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Padding) > 0 {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Padding)))
+		i += copy(dAtA[i:], m.Padding)
+	}
+	if m.ReplayNonce != 0 {
+		dAtA[i] = 0x70
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.ReplayNonce))
+	}
+	if len(m.SignatureScheme) > 0 {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.SignatureScheme)))
+		i += copy(dAtA[i:], m.SignatureScheme)
+	}
+	if m.ReplayStampMs != 0 {
+		dAtA[i] = 0x80
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.ReplayStampMs))
+	}
+	return i, nil
+}
+
+func (m *Signature) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Signature) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Scheme) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Scheme)))
+		i += copy(dAtA[i:], m.Scheme)
+	}
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+
+func (m *Ping) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Ping) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	return i, nil
+}
+
+func (m *Batch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Batch) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Payloads) > 0 {
+		for _, msg := range m.Payloads {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *Pong) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Pong) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	return i, nil
+}
+
+func (m *Keepalive) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Keepalive) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Payload) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Payload)))
+		i += copy(dAtA[i:], m.Payload)
+	}
+	return i, nil
+}
+
+func (m *KeepaliveAck) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *KeepaliveAck) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Payload) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Payload)))
+		i += copy(dAtA[i:], m.Payload)
+	}
+	return i, nil
+}
+
+func (m *LookupNodeRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LookupNodeRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Target != nil {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Target.Size()))
+		n5, err := m.Target.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n5
+	}
+	if len(m.Known) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Known)))
+		i += copy(dAtA[i:], m.Known)
+	}
+	return i, nil
+}
+
+func (m *LookupNodeResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LookupNodeResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Peers) > 0 {
+		for _, msg := range m.Peers {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.Compact != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Compact.Size()))
+		n6, err := m.Compact.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n6
+	}
+	return i, nil
+}
+
+func (m *CompactPeers) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CompactPeers) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PublicKeys) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKeys)))
+		i += copy(dAtA[i:], m.PublicKeys)
+	}
+	if m.KeySize != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.KeySize))
+	}
+	if len(m.Prefixes) > 0 {
+		for _, s := range m.Prefixes {
+			dAtA[i] = 0x1a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.PrefixIndices) > 0 {
+		dAtA8 := make([]byte, len(m.PrefixIndices)*10)
+		var j7 int
+		for _, num := range m.PrefixIndices {
+			for num >= 1<<7 {
+				dAtA8[j7] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j7++
+			}
+			dAtA8[j7] = uint8(num)
+			j7++
+		}
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(j7))
+		i += copy(dAtA[i:], dAtA8[:j7])
+	}
+	if len(m.Ports) > 0 {
+		dAtA10 := make([]byte, len(m.Ports)*10)
+		var j9 int
+		for _, num := range m.Ports {
+			for num >= 1<<7 {
+				dAtA10[j9] = uint8(uint64(num)&0x7f | 0x80)
+				num >>= 7
+				j9++
+			}
+			dAtA10[j9] = uint8(num)
+			j9++
+		}
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(j9))
+		i += copy(dAtA[i:], dAtA10[:j9])
+	}
+	return i, nil
+}
+
+func (m *Bytes) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Bytes) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Data) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Data)))
+		i += copy(dAtA[i:], m.Data)
+	}
+	return i, nil
+}
+
+func (m *HandshakeOffer) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *HandshakeOffer) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Versions) > 0 {
+		for _, s := range m.Versions {
+			dAtA[i] = 0xa
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.Capabilities) > 0 {
+		for _, s := range m.Capabilities {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.Metadata) > 0 {
+		for _, msg := range m.Metadata {
+			dAtA[i] = 0x1a
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.Services != nil {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Services.Size()))
+		n11, err := m.Services.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n11
+	}
+	return i, nil
+}
+
+func (m *HandshakeMetadata) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *HandshakeMetadata) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Key) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if len(m.Value) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Value)))
+		i += copy(dAtA[i:], m.Value)
+	}
+	return i, nil
+}
+
+func (m *Handshake) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Handshake) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Sender != nil {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Sender.Size()))
+		n12, err := m.Sender.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n12
+	}
+	if m.Offer != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Offer.Size()))
+		n13, err := m.Offer.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n13
+	}
+	if m.Echo != nil {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Echo.Size()))
+		n14, err := m.Echo.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n14
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	if len(m.SessionToken) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.SessionToken)))
+		i += copy(dAtA[i:], m.SessionToken)
+	}
+	if m.Resumed {
+		dAtA[i] = 0x30
+		i++
+		if m.Resumed {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Control {
+		dAtA[i] = 0x38
+		i++
+		if m.Control {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Affinity != nil {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Affinity.Size()))
+		n15, err := m.Affinity.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n15
+	}
+	if len(m.AffinityToken) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.AffinityToken)))
+		i += copy(dAtA[i:], m.AffinityToken)
+	}
+	if m.Puzzle != nil {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Puzzle.Size()))
+		n16, err := m.Puzzle.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n16
+	}
+	if len(m.PuzzleSolution) > 0 {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PuzzleSolution)))
+		i += copy(dAtA[i:], m.PuzzleSolution)
+	}
+	if len(m.EphemeralKey) > 0 {
+		dAtA[i] = 0x62
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.EphemeralKey)))
+		i += copy(dAtA[i:], m.EphemeralKey)
+	}
+	if len(m.SignatureScheme) > 0 {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.SignatureScheme)))
+		i += copy(dAtA[i:], m.SignatureScheme)
+	}
+	if m.RatchetRecords != 0 {
+		dAtA[i] = 0x70
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RatchetRecords))
+	}
+	if m.RatchetIntervalMs != 0 {
+		dAtA[i] = 0x78
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RatchetIntervalMs))
+	}
+	return i, nil
+}
+
+func (m *PeerRecord) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PeerRecord) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Addresses) > 0 {
+		for _, s := range m.Addresses {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.LastSeen != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.LastSeen))
+	}
+	if len(m.Tags) > 0 {
+		for _, s := range m.Tags {
+			dAtA[i] = 0x22
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.LastConnected != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.LastConnected))
+	}
+	if m.Connects != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Connects))
+	}
+	if m.Failures != 0 {
+		dAtA[i] = 0x38
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Failures))
+	}
+	if m.FailureStreak != 0 {
+		dAtA[i] = 0x40
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.FailureStreak))
+	}
+	if m.Rtt != 0 {
+		dAtA[i] = 0x48
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Rtt))
+	}
+	return i, nil
+}
+
+func (m *PeerBundle) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PeerBundle) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Records) > 0 {
+		for _, msg := range m.Records {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.CreatedAt != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.CreatedAt))
+	}
+	if m.MaxAge != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MaxAge))
+	}
+	return i, nil
+}
+
+func (m *SignedPeerBundle) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ServiceRecord) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ServiceRecords) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *Rejection) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *Routed) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *Hint) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *ReachabilityProbe) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *ReachabilityResult) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *UpgradeAnnouncement) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *SignedUpgradeAnnouncement) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *PeerSample) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *ShuffleRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *ShuffleResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *DiagnosticsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *DiagnosticsReport) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *Goodbye) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandoverSession) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandoverAddress) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandoverPlugin) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandoverState) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *JournalEntry) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandshakeExtension) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandshakeExtensions) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *Affinity) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *AffinityRevoked) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+func (m *HandshakePuzzle) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Chunk) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SignedPeerBundle) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Bundle) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Bundle)))
+		i += copy(dAtA[i:], m.Bundle)
+	}
+	if len(m.Signer) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signer)))
+		i += copy(dAtA[i:], m.Signer)
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+
+func (m *ServiceRecord) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if len(m.Metadata) > 0 {
+		for _, msg := range m.Metadata {
+			dAtA[i] = 0x1a
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *ServiceRecords) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Records) > 0 {
+		for _, msg := range m.Records {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.Version != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Version))
+	}
+	return i, nil
+}
+func (m *Rejection) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.RequestNonce != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RequestNonce))
+	}
+	if m.MessageNonce != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MessageNonce))
+	}
+	if len(m.Hash) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Hash)))
+		i += copy(dAtA[i:], m.Hash)
+	}
+	if m.Reason != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Reason))
+	}
+	return i, nil
+}
+func (m *Routed) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Key) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if m.Hops != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Hops))
+	}
+	if m.MaxHops != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MaxHops))
+	}
+	if m.Message != nil {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Message.Size()))
+		n15, err := m.Message.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n15
+	}
+	return i, nil
+}
+func (m *Hint) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Key) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if len(m.Value) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Value)))
+		i += copy(dAtA[i:], m.Value)
+	}
+	return i, nil
+}
+func (m *ReachabilityProbe) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	return i, nil
+}
+func (m *ReachabilityResult) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Reachable {
+		dAtA[i] = 0x8
+		i++
+		if m.Reachable {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Refused {
+		dAtA[i] = 0x10
+		i++
+		if m.Refused {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	return i, nil
+}
+func (m *UpgradeAnnouncement) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Version != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Version))
+	}
+	if m.Activation != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Activation))
+	}
+	if len(m.Note) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Note)))
+		i += copy(dAtA[i:], m.Note)
+	}
+	if m.IssuedAt != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.IssuedAt))
+	}
+	return i, nil
+}
+func (m *SignedUpgradeAnnouncement) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Announcement) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Announcement)))
+		i += copy(dAtA[i:], m.Announcement)
+	}
+	if len(m.Signer) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signer)))
+		i += copy(dAtA[i:], m.Signer)
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+func (m *PeerSample) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Id != nil {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Id.Size()))
+		n16, err := m.Id.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n16
+	}
+	if m.Age != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Age))
+	}
+	return i, nil
+}
+func (m *ShuffleRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Samples) > 0 {
+		for _, msg := range m.Samples {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+func (m *ShuffleResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Samples) > 0 {
+		for _, msg := range m.Samples {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+func (m *DiagnosticsRequest) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.SentAt != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.SentAt))
+	}
+	return i, nil
+}
+func (m *DiagnosticsReport) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Refused {
+		dAtA[i] = 0x8
+		i++
+		if m.Refused {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Rtt != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Rtt))
+	}
+	if len(m.ObservedAddress) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.ObservedAddress)))
+		i += copy(dAtA[i:], m.ObservedAddress)
+	}
+	if m.MessagesSent != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MessagesSent))
+	}
+	if m.MessagesReceived != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MessagesReceived))
+	}
+	if m.BytesSent != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.BytesSent))
+	}
+	if m.BytesReceived != 0 {
+		dAtA[i] = 0x38
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.BytesReceived))
+	}
+	if m.Violations != 0 {
+		dAtA[i] = 0x40
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Violations))
+	}
+	if m.Quarantined {
+		dAtA[i] = 0x48
+		i++
+		if m.Quarantined {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.Time != 0 {
+		dAtA[i] = 0x50
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Time))
+	}
+	return i, nil
+}
+func (m *Goodbye) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.RetryAfter != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RetryAfter))
+	}
+	return i, nil
+}
+func (m *HandoverSession) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Token) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Token)))
+		i += copy(dAtA[i:], m.Token)
+	}
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Version) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Version)))
+		i += copy(dAtA[i:], m.Version)
+	}
+	if m.Offer != nil {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Offer.Size()))
+		n17, err := m.Offer.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n17
+	}
+	if m.MessageNonce != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.MessageNonce))
+	}
+	if m.RequestNonce != 0 {
+		dAtA[i] = 0x30
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.RequestNonce))
+	}
+	if m.Expiry != 0 {
+		dAtA[i] = 0x38
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Expiry))
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if len(m.Resumption) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Resumption)))
+		i += copy(dAtA[i:], m.Resumption)
+	}
+	return i, nil
+}
+func (m *HandoverAddress) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if m.Status != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Status))
+	}
+	if m.Expiry != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Expiry))
+	}
+	return i, nil
+}
+func (m *HandoverPlugin) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.State) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.State)))
+		i += copy(dAtA[i:], m.State)
+	}
+	return i, nil
+}
+func (m *HandoverState) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.PublicKey) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.PublicKey)))
+		i += copy(dAtA[i:], m.PublicKey)
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if m.CreatedAt != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.CreatedAt))
+	}
+	if len(m.Peers) > 0 {
+		for _, msg := range m.Peers {
+			dAtA[i] = 0x22
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Issued) > 0 {
+		for _, msg := range m.Issued {
+			dAtA[i] = 0x2a
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Held) > 0 {
+		for _, msg := range m.Held {
+			dAtA[i] = 0x32
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Addresses) > 0 {
+		for _, msg := range m.Addresses {
+			dAtA[i] = 0x3a
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if m.Reachability != 0 {
+		dAtA[i] = 0x40
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Reachability))
+	}
+	if len(m.ReachabilityOutcomes) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.ReachabilityOutcomes)))
+		for _, b := range m.ReachabilityOutcomes {
+			if b {
+				dAtA[i] = 1
+			} else {
+				dAtA[i] = 0
+			}
+			i++
+		}
+	}
+	if m.ReachabilityChecked != 0 {
+		dAtA[i] = 0x50
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.ReachabilityChecked))
+	}
+	if len(m.Plugins) > 0 {
+		for _, msg := range m.Plugins {
+			dAtA[i] = 0x5a
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+func (m *JournalEntry) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Frame) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Frame)))
+		i += copy(dAtA[i:], m.Frame)
+	}
+	if m.Payload != nil {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Payload.Size()))
+		n18, err := m.Payload.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n18
+	}
+	if m.ReceivedAt != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.ReceivedAt))
+	}
+	return i, nil
+}
+func (m *HandshakeExtension) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Name) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if len(m.Payload) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Payload)))
+		i += copy(dAtA[i:], m.Payload)
+	}
+	return i, nil
+}
+func (m *HandshakeExtensions) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Sender != nil {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Sender.Size()))
+		n19, err := m.Sender.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n19
+	}
+	if len(m.Extensions) > 0 {
+		for _, msg := range m.Extensions {
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+func (m *Affinity) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Token) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Token)))
+		i += copy(dAtA[i:], m.Token)
+	}
+	if len(m.Address) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Address)))
+		i += copy(dAtA[i:], m.Address)
+	}
+	if m.Lifetime != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Lifetime))
+	}
+	return i, nil
+}
+func (m *AffinityRevoked) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Tokens) > 0 {
+		for _, b := range m.Tokens {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintStream(dAtA, i, uint64(len(b)))
+			i += copy(dAtA[i:], b)
+		}
+	}
+	return i, nil
+}
+func (m *HandshakePuzzle) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Seed) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Seed)))
+		i += copy(dAtA[i:], m.Seed)
+	}
+	if m.Difficulty != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Difficulty))
+	}
+	return i, nil
+}
+
+func (m *Chunk) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.Transfer != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Transfer))
+	}
+	if m.Index != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Index))
+	}
+	if m.Count != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Count))
+	}
+	if m.Size_ != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(m.Size_))
+	}
+	if len(m.Data) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintStream(dAtA, i, uint64(len(m.Data)))
+		i += copy(dAtA[i:], m.Data)
+	}
+	return i, nil
+}
+
+func encodeVarintStream(dAtA []byte, offset int, v uint64) int {
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return offset + 1
+}
+func (m *ID) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *Message) Size() (n int) {
+	var l int
+	_ = l
+	if m.Message != nil {
+		l = m.Message.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Sender != nil {
+		l = m.Sender.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.RequestNonce != 0 {
+		n += 1 + sovStream(uint64(m.RequestNonce))
+	}
+	if m.MessageNonce != 0 {
+		n += 1 + sovStream(uint64(m.MessageNonce))
+	}
+	if m.ReplyFlag {
+		n += 2
+	}
+	if len(m.Metadata) > 0 {
+		for k, v := range m.Metadata {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovStream(uint64(len(k))) + 1 + len(v) + sovStream(uint64(len(v)))
+			n += mapEntrySize + 1 + sovStream(uint64(mapEntrySize))
+		}
+	}
+	if len(m.Signatures) > 0 {
+		for _, e := range m.Signatures {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.CriticalExtensions) > 0 {
+		l = 0
+		for _, e := range m.CriticalExtensions {
+			l += sovStream(uint64(e))
+		}
+		n += 1 + sovStream(uint64(l)) + l
+	}
+	l = len(m.Protocol)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.BudgetMs != 0 {
+		n += 1 + sovStream(uint64(m.BudgetMs))
+	}
+	if len(m.Hints) > 0 {
+		for _, e := range m.Hints {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	l = len(m.Padding)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.ReplayNonce != 0 {
+		n += 1 + sovStream(uint64(m.ReplayNonce))
+	}
+	l = len(m.SignatureScheme)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.ReplayStampMs != 0 {
+		n += 2 + sovStream(uint64(m.ReplayStampMs))
+	}
+	return n
+}
+
+func (m *Signature) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Scheme)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *Ping) Size() (n int) {
+	var l int
+	_ = l
+	return n
+}
+
+func (m *Batch) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Payloads) > 0 {
+		for _, e := range m.Payloads {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *Pong) Size() (n int) {
+	var l int
+	_ = l
+	return n
+}
+
+func (m *Keepalive) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Payload)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *KeepaliveAck) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Payload)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *LookupNodeRequest) Size() (n int) {
+	var l int
+	_ = l
+	if m.Target != nil {
+		l = m.Target.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Known)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *LookupNodeResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Peers) > 0 {
+		for _, e := range m.Peers {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.Compact != nil {
+		l = m.Compact.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *CompactPeers) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.PublicKeys)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.KeySize != 0 {
+		n += 1 + sovStream(uint64(m.KeySize))
+	}
+	if len(m.Prefixes) > 0 {
+		for _, s := range m.Prefixes {
+			l = len(s)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.PrefixIndices) > 0 {
+		l = 0
+		for _, e := range m.PrefixIndices {
+			l += sovStream(uint64(e))
+		}
+		n += 1 + sovStream(uint64(l)) + l
+	}
+	if len(m.Ports) > 0 {
+		l = 0
+		for _, e := range m.Ports {
+			l += sovStream(uint64(e))
+		}
+		n += 1 + sovStream(uint64(l)) + l
+	}
+	return n
+}
+
+func (m *Bytes) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *HandshakeOffer) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Versions) > 0 {
+		for _, s := range m.Versions {
+			l = len(s)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.Capabilities) > 0 {
+		for _, s := range m.Capabilities {
+			l = len(s)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.Metadata) > 0 {
+		for _, e := range m.Metadata {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.Services != nil {
+		l = m.Services.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *HandshakeMetadata) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *Handshake) Size() (n int) {
+	var l int
+	_ = l
+	if m.Sender != nil {
+		l = m.Sender.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Offer != nil {
+		l = m.Offer.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Echo != nil {
+		l = m.Echo.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.SessionToken)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Resumed {
+		n += 2
+	}
+	if m.Control {
+		n += 2
+	}
+	if m.Affinity != nil {
+		l = m.Affinity.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.AffinityToken)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Puzzle != nil {
+		l = m.Puzzle.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.PuzzleSolution)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.EphemeralKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.SignatureScheme)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.RatchetRecords != 0 {
+		n += 1 + sovStream(uint64(m.RatchetRecords))
+	}
+	if m.RatchetIntervalMs != 0 {
+		n += 1 + sovStream(uint64(m.RatchetIntervalMs))
+	}
+	return n
+}
+
+func (m *PeerRecord) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if len(m.Addresses) > 0 {
+		for _, s := range m.Addresses {
+			l = len(s)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.LastSeen != 0 {
+		n += 1 + sovStream(uint64(m.LastSeen))
+	}
+	if len(m.Tags) > 0 {
+		for _, s := range m.Tags {
+			l = len(s)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.LastConnected != 0 {
+		n += 1 + sovStream(uint64(m.LastConnected))
+	}
+	if m.Connects != 0 {
+		n += 1 + sovStream(uint64(m.Connects))
+	}
+	if m.Failures != 0 {
+		n += 1 + sovStream(uint64(m.Failures))
+	}
+	if m.FailureStreak != 0 {
+		n += 1 + sovStream(uint64(m.FailureStreak))
+	}
+	if m.Rtt != 0 {
+		n += 1 + sovStream(uint64(m.Rtt))
+	}
+	return n
+}
+
+func (m *PeerBundle) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Records) > 0 {
+		for _, e := range m.Records {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.CreatedAt != 0 {
+		n += 1 + sovStream(uint64(m.CreatedAt))
+	}
+	if m.MaxAge != 0 {
+		n += 1 + sovStream(uint64(m.MaxAge))
+	}
+	return n
+}
+
+func (m *SignedPeerBundle) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Bundle)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signer)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func (m *ServiceRecord) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if len(m.Metadata) > 0 {
+		for _, e := range m.Metadata {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ServiceRecords) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Records) > 0 {
+		for _, e := range m.Records {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.Version != 0 {
+		n += 1 + sovStream(uint64(m.Version))
+	}
+	return n
+}
+func (m *Rejection) Size() (n int) {
+	var l int
+	_ = l
+	if m.RequestNonce != 0 {
+		n += 1 + sovStream(uint64(m.RequestNonce))
+	}
+	if m.MessageNonce != 0 {
+		n += 1 + sovStream(uint64(m.MessageNonce))
+	}
+	l = len(m.Hash)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Reason != 0 {
+		n += 1 + sovStream(uint64(m.Reason))
+	}
+	return n
+}
+func (m *Routed) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Hops != 0 {
+		n += 1 + sovStream(uint64(m.Hops))
+	}
+	if m.MaxHops != 0 {
+		n += 1 + sovStream(uint64(m.MaxHops))
+	}
+	if m.Message != nil {
+		l = m.Message.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *Hint) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *ReachabilityProbe) Size() (n int) {
+	var l int
+	_ = l
+	return n
+}
+func (m *ReachabilityResult) Size() (n int) {
+	var l int
+	_ = l
+	if m.Reachable {
+		n += 2
+	}
+	if m.Refused {
+		n += 2
+	}
+	return n
+}
+func (m *UpgradeAnnouncement) Size() (n int) {
+	var l int
+	_ = l
+	if m.Version != 0 {
+		n += 1 + sovStream(uint64(m.Version))
+	}
+	if m.Activation != 0 {
+		n += 1 + sovStream(uint64(m.Activation))
+	}
+	l = len(m.Note)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.IssuedAt != 0 {
+		n += 1 + sovStream(uint64(m.IssuedAt))
+	}
+	return n
+}
+func (m *SignedUpgradeAnnouncement) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Announcement)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signer)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *PeerSample) Size() (n int) {
+	var l int
+	_ = l
+	if m.Id != nil {
+		l = m.Id.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Age != 0 {
+		n += 1 + sovStream(uint64(m.Age))
+	}
+	return n
+}
+func (m *ShuffleRequest) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Samples) > 0 {
+		for _, e := range m.Samples {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+func (m *ShuffleResponse) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Samples) > 0 {
+		for _, e := range m.Samples {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+func (m *DiagnosticsRequest) Size() (n int) {
+	var l int
+	_ = l
+	if m.SentAt != 0 {
+		n += 1 + sovStream(uint64(m.SentAt))
+	}
+	return n
+}
+func (m *DiagnosticsReport) Size() (n int) {
+	var l int
+	_ = l
+	if m.Refused {
+		n += 2
+	}
+	if m.Rtt != 0 {
+		n += 1 + sovStream(uint64(m.Rtt))
+	}
+	l = len(m.ObservedAddress)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.MessagesSent != 0 {
+		n += 1 + sovStream(uint64(m.MessagesSent))
+	}
+	if m.MessagesReceived != 0 {
+		n += 1 + sovStream(uint64(m.MessagesReceived))
+	}
+	if m.BytesSent != 0 {
+		n += 1 + sovStream(uint64(m.BytesSent))
+	}
+	if m.BytesReceived != 0 {
+		n += 1 + sovStream(uint64(m.BytesReceived))
+	}
+	if m.Violations != 0 {
+		n += 1 + sovStream(uint64(m.Violations))
+	}
+	if m.Quarantined {
+		n += 2
+	}
+	if m.Time != 0 {
+		n += 1 + sovStream(uint64(m.Time))
+	}
+	return n
+}
+func (m *Goodbye) Size() (n int) {
+	var l int
+	_ = l
+	if m.RetryAfter != 0 {
+		n += 1 + sovStream(uint64(m.RetryAfter))
+	}
+	return n
+}
+func (m *HandoverSession) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Token)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Version)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Offer != nil {
+		l = m.Offer.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.MessageNonce != 0 {
+		n += 1 + sovStream(uint64(m.MessageNonce))
+	}
+	if m.RequestNonce != 0 {
+		n += 1 + sovStream(uint64(m.RequestNonce))
+	}
+	if m.Expiry != 0 {
+		n += 1 + sovStream(uint64(m.Expiry))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Resumption)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *HandoverAddress) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Status != 0 {
+		n += 1 + sovStream(uint64(m.Status))
+	}
+	if m.Expiry != 0 {
+		n += 1 + sovStream(uint64(m.Expiry))
+	}
+	return n
+}
+func (m *HandoverPlugin) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.State)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *HandoverState) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.PublicKey)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.CreatedAt != 0 {
+		n += 1 + sovStream(uint64(m.CreatedAt))
+	}
+	if len(m.Peers) > 0 {
+		for _, e := range m.Peers {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.Issued) > 0 {
+		for _, e := range m.Issued {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.Held) > 0 {
+		for _, e := range m.Held {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if len(m.Addresses) > 0 {
+		for _, e := range m.Addresses {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	if m.Reachability != 0 {
+		n += 1 + sovStream(uint64(m.Reachability))
+	}
+	if len(m.ReachabilityOutcomes) > 0 {
+		n += 1 + sovStream(uint64(len(m.ReachabilityOutcomes))) + len(m.ReachabilityOutcomes)*1
+	}
+	if m.ReachabilityChecked != 0 {
+		n += 1 + sovStream(uint64(m.ReachabilityChecked))
+	}
+	if len(m.Plugins) > 0 {
+		for _, e := range m.Plugins {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+func (m *JournalEntry) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Frame)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Payload != nil {
+		l = m.Payload.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.ReceivedAt != 0 {
+		n += 1 + sovStream(uint64(m.ReceivedAt))
+	}
+	return n
+}
+func (m *HandshakeExtension) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Payload)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *HandshakeExtensions) Size() (n int) {
+	var l int
+	_ = l
+	if m.Sender != nil {
+		l = m.Sender.Size()
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if len(m.Extensions) > 0 {
+		for _, e := range m.Extensions {
+			l = e.Size()
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+func (m *Affinity) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Token)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	l = len(m.Address)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Lifetime != 0 {
+		n += 1 + sovStream(uint64(m.Lifetime))
+	}
+	return n
+}
+func (m *AffinityRevoked) Size() (n int) {
+	var l int
+	_ = l
+	if len(m.Tokens) > 0 {
+		for _, b := range m.Tokens {
+			l = len(b)
+			n += 1 + l + sovStream(uint64(l))
+		}
+	}
+	return n
+}
+func (m *HandshakePuzzle) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Seed)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	if m.Difficulty != 0 {
+		n += 1 + sovStream(uint64(m.Difficulty))
+	}
+	return n
+}
+
+func (m *Chunk) Size() (n int) {
+	var l int
+	_ = l
+	if m.Transfer != 0 {
+		n += 1 + sovStream(uint64(m.Transfer))
+	}
+	if m.Index != 0 {
+		n += 1 + sovStream(uint64(m.Index))
+	}
+	if m.Count != 0 {
+		n += 1 + sovStream(uint64(m.Count))
+	}
+	if m.Size_ != 0 {
+		n += 1 + sovStream(uint64(m.Size_))
+	}
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovStream(uint64(l))
+	}
+	return n
+}
+
+func sovStream(x uint64) (n int) {
+	for {
+		n++
+		x >>= 7
+		if x == 0 {
+			break
+		}
+	}
+	return n
+}
+func sozStream(x uint64) (n int) {
+	return sovStream(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *ID) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ID{`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`Id:` + fmt.Sprintf("%v", this.Id) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Message) String() string {
+	if this == nil {
+		return "nil"
+	}
+	keysForMetadata := make([]string, 0, len(this.Metadata))
+	for k, _ := range this.Metadata {
+		keysForMetadata = append(keysForMetadata, k)
+	}
+	sortkeys.Strings(keysForMetadata)
+	mapStringForMetadata := "map[string]string{"
+	for _, k := range keysForMetadata {
+		mapStringForMetadata += fmt.Sprintf("%v: %v,", k, this.Metadata[k])
+	}
+	mapStringForMetadata += "}"
+	s := strings.Join([]string{`&Message{`,
+		`Message:` + strings.Replace(fmt.Sprintf("%v", this.Message), "Any", "google_protobuf.Any", 1) + `,`,
+		`Sender:` + strings.Replace(fmt.Sprintf("%v", this.Sender), "ID", "ID", 1) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`RequestNonce:` + fmt.Sprintf("%v", this.RequestNonce) + `,`,
+		`MessageNonce:` + fmt.Sprintf("%v", this.MessageNonce) + `,`,
+		`ReplyFlag:` + fmt.Sprintf("%v", this.ReplyFlag) + `,`,
+		`Metadata:` + mapStringForMetadata + `,`,
+		`Signatures:` + strings.Replace(fmt.Sprintf("%v", this.Signatures), "Signature", "Signature", 1) + `,`,
+		`CriticalExtensions:` + fmt.Sprintf("%v", this.CriticalExtensions) + `,`,
+		`Protocol:` + fmt.Sprintf("%v", this.Protocol) + `,`,
+		`BudgetMs:` + fmt.Sprintf("%v", this.BudgetMs) + `,`,
+		`Hints:` + strings.Replace(fmt.Sprintf("%v", this.Hints), "Hint", "Hint", 1) + `,`,
+		`Padding:` + fmt.Sprintf("%v", this.Padding) + `,`,
+		`ReplayNonce:` + fmt.Sprintf("%v", this.ReplayNonce) + `,`,
+		`SignatureScheme:` + fmt.Sprintf("%v", this.SignatureScheme) + `,`,
+		`ReplayStampMs:` + fmt.Sprintf("%v", this.ReplayStampMs) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Signature) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Signature{`,
+		`Scheme:` + fmt.Sprintf("%v", this.Scheme) + `,`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Ping) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Ping{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Batch) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Batch{`,
+		`Payloads:` + strings.Replace(fmt.Sprintf("%v", this.Payloads), "Any", "google_protobuf.Any", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Pong) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Pong{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Keepalive) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Keepalive{`,
+		`Payload:` + fmt.Sprintf("%v", this.Payload) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *KeepaliveAck) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&KeepaliveAck{`,
+		`Payload:` + fmt.Sprintf("%v", this.Payload) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LookupNodeRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LookupNodeRequest{`,
+		`Target:` + strings.Replace(fmt.Sprintf("%v", this.Target), "ID", "ID", 1) + `,`,
+		`Known:` + fmt.Sprintf("%v", this.Known) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LookupNodeResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LookupNodeResponse{`,
+		`Peers:` + strings.Replace(fmt.Sprintf("%v", this.Peers), "ID", "ID", 1) + `,`,
+		`Compact:` + strings.Replace(fmt.Sprintf("%v", this.Compact), "CompactPeers", "CompactPeers", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CompactPeers) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CompactPeers{`,
+		`PublicKeys:` + fmt.Sprintf("%v", this.PublicKeys) + `,`,
+		`KeySize:` + fmt.Sprintf("%v", this.KeySize) + `,`,
+		`Prefixes:` + fmt.Sprintf("%v", this.Prefixes) + `,`,
+		`PrefixIndices:` + fmt.Sprintf("%v", this.PrefixIndices) + `,`,
+		`Ports:` + fmt.Sprintf("%v", this.Ports) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Bytes) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Bytes{`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandshakeOffer) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandshakeOffer{`,
+		`Versions:` + fmt.Sprintf("%v", this.Versions) + `,`,
+		`Capabilities:` + fmt.Sprintf("%v", this.Capabilities) + `,`,
+		`Metadata:` + strings.Replace(fmt.Sprintf("%v", this.Metadata), "HandshakeMetadata", "HandshakeMetadata", 1) + `,`,
+		`Services:` + strings.Replace(fmt.Sprintf("%v", this.Services), "ServiceRecords", "ServiceRecords", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandshakeMetadata) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandshakeMetadata{`,
+		`Key:` + fmt.Sprintf("%v", this.Key) + `,`,
+		`Value:` + fmt.Sprintf("%v", this.Value) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Handshake) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Handshake{`,
+		`Sender:` + strings.Replace(fmt.Sprintf("%v", this.Sender), "ID", "ID", 1) + `,`,
+		`Offer:` + strings.Replace(fmt.Sprintf("%v", this.Offer), "HandshakeOffer", "HandshakeOffer", 1) + `,`,
+		`Echo:` + strings.Replace(fmt.Sprintf("%v", this.Echo), "HandshakeOffer", "HandshakeOffer", 1) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`SessionToken:` + fmt.Sprintf("%v", this.SessionToken) + `,`,
+		`Resumed:` + fmt.Sprintf("%v", this.Resumed) + `,`,
+		`Control:` + fmt.Sprintf("%v", this.Control) + `,`,
+		`Affinity:` + strings.Replace(fmt.Sprintf("%v", this.Affinity), "Affinity", "Affinity", 1) + `,`,
+		`AffinityToken:` + fmt.Sprintf("%v", this.AffinityToken) + `,`,
+		`Puzzle:` + strings.Replace(fmt.Sprintf("%v", this.Puzzle), "HandshakePuzzle", "HandshakePuzzle", 1) + `,`,
+		`PuzzleSolution:` + fmt.Sprintf("%v", this.PuzzleSolution) + `,`,
+		`EphemeralKey:` + fmt.Sprintf("%v", this.EphemeralKey) + `,`,
+		`SignatureScheme:` + fmt.Sprintf("%v", this.SignatureScheme) + `,`,
+		`RatchetRecords:` + fmt.Sprintf("%v", this.RatchetRecords) + `,`,
+		`RatchetIntervalMs:` + fmt.Sprintf("%v", this.RatchetIntervalMs) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *PeerRecord) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&PeerRecord{`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Addresses:` + fmt.Sprintf("%v", this.Addresses) + `,`,
+		`LastSeen:` + fmt.Sprintf("%v", this.LastSeen) + `,`,
+		`Tags:` + fmt.Sprintf("%v", this.Tags) + `,`,
+		`LastConnected:` + fmt.Sprintf("%v", this.LastConnected) + `,`,
+		`Connects:` + fmt.Sprintf("%v", this.Connects) + `,`,
+		`Failures:` + fmt.Sprintf("%v", this.Failures) + `,`,
+		`FailureStreak:` + fmt.Sprintf("%v", this.FailureStreak) + `,`,
+		`Rtt:` + fmt.Sprintf("%v", this.Rtt) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *PeerBundle) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&PeerBundle{`,
+		`Records:` + strings.Replace(fmt.Sprintf("%v", this.Records), "PeerRecord", "PeerRecord", 1) + `,`,
+		`CreatedAt:` + fmt.Sprintf("%v", this.CreatedAt) + `,`,
+		`MaxAge:` + fmt.Sprintf("%v", this.MaxAge) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SignedPeerBundle) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SignedPeerBundle{`,
+		`Bundle:` + fmt.Sprintf("%v", this.Bundle) + `,`,
+		`Signer:` + fmt.Sprintf("%v", this.Signer) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+
+func (this *ServiceRecord) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ServiceRecord{`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`Metadata:` + strings.Replace(fmt.Sprintf("%v", this.Metadata), "HandshakeMetadata", "HandshakeMetadata", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+
+func (this *ServiceRecords) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ServiceRecords{`,
+		`Records:` + strings.Replace(fmt.Sprintf("%v", this.Records), "ServiceRecord", "ServiceRecord", 1) + `,`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Rejection) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Rejection{`,
+		`RequestNonce:` + fmt.Sprintf("%v", this.RequestNonce) + `,`,
+		`MessageNonce:` + fmt.Sprintf("%v", this.MessageNonce) + `,`,
+		`Hash:` + fmt.Sprintf("%v", this.Hash) + `,`,
+		`Reason:` + fmt.Sprintf("%v", this.Reason) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Routed) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Routed{`,
+		`Key:` + fmt.Sprintf("%v", this.Key) + `,`,
+		`Hops:` + fmt.Sprintf("%v", this.Hops) + `,`,
+		`MaxHops:` + fmt.Sprintf("%v", this.MaxHops) + `,`,
+		`Message:` + strings.Replace(fmt.Sprintf("%v", this.Message), "Any", "google_protobuf.Any", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Hint) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Hint{`,
+		`Key:` + fmt.Sprintf("%v", this.Key) + `,`,
+		`Value:` + fmt.Sprintf("%v", this.Value) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReachabilityProbe) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReachabilityProbe{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReachabilityResult) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ReachabilityResult{`,
+		`Reachable:` + fmt.Sprintf("%v", this.Reachable) + `,`,
+		`Refused:` + fmt.Sprintf("%v", this.Refused) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *UpgradeAnnouncement) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&UpgradeAnnouncement{`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`Activation:` + fmt.Sprintf("%v", this.Activation) + `,`,
+		`Note:` + fmt.Sprintf("%v", this.Note) + `,`,
+		`IssuedAt:` + fmt.Sprintf("%v", this.IssuedAt) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SignedUpgradeAnnouncement) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SignedUpgradeAnnouncement{`,
+		`Announcement:` + fmt.Sprintf("%v", this.Announcement) + `,`,
+		`Signer:` + fmt.Sprintf("%v", this.Signer) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *PeerSample) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&PeerSample{`,
+		`Id:` + strings.Replace(fmt.Sprintf("%v", this.Id), "ID", "ID", 1) + `,`,
+		`Age:` + fmt.Sprintf("%v", this.Age) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ShuffleRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ShuffleRequest{`,
+		`Samples:` + strings.Replace(fmt.Sprintf("%v", this.Samples), "PeerSample", "PeerSample", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ShuffleResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ShuffleResponse{`,
+		`Samples:` + strings.Replace(fmt.Sprintf("%v", this.Samples), "PeerSample", "PeerSample", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *DiagnosticsRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&DiagnosticsRequest{`,
+		`SentAt:` + fmt.Sprintf("%v", this.SentAt) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *DiagnosticsReport) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&DiagnosticsReport{`,
+		`Refused:` + fmt.Sprintf("%v", this.Refused) + `,`,
+		`Rtt:` + fmt.Sprintf("%v", this.Rtt) + `,`,
+		`ObservedAddress:` + fmt.Sprintf("%v", this.ObservedAddress) + `,`,
+		`MessagesSent:` + fmt.Sprintf("%v", this.MessagesSent) + `,`,
+		`MessagesReceived:` + fmt.Sprintf("%v", this.MessagesReceived) + `,`,
+		`BytesSent:` + fmt.Sprintf("%v", this.BytesSent) + `,`,
+		`BytesReceived:` + fmt.Sprintf("%v", this.BytesReceived) + `,`,
+		`Violations:` + fmt.Sprintf("%v", this.Violations) + `,`,
+		`Quarantined:` + fmt.Sprintf("%v", this.Quarantined) + `,`,
+		`Time:` + fmt.Sprintf("%v", this.Time) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Goodbye) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Goodbye{`,
+		`RetryAfter:` + fmt.Sprintf("%v", this.RetryAfter) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandoverSession) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandoverSession{`,
+		`Token:` + fmt.Sprintf("%v", this.Token) + `,`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Version:` + fmt.Sprintf("%v", this.Version) + `,`,
+		`Offer:` + strings.Replace(fmt.Sprintf("%v", this.Offer), "HandshakeOffer", "HandshakeOffer", 1) + `,`,
+		`MessageNonce:` + fmt.Sprintf("%v", this.MessageNonce) + `,`,
+		`RequestNonce:` + fmt.Sprintf("%v", this.RequestNonce) + `,`,
+		`Expiry:` + fmt.Sprintf("%v", this.Expiry) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`Resumption:` + fmt.Sprintf("%v", this.Resumption) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandoverAddress) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandoverAddress{`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`Status:` + fmt.Sprintf("%v", this.Status) + `,`,
+		`Expiry:` + fmt.Sprintf("%v", this.Expiry) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandoverPlugin) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandoverPlugin{`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`State:` + fmt.Sprintf("%v", this.State) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandoverState) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandoverState{`,
+		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`CreatedAt:` + fmt.Sprintf("%v", this.CreatedAt) + `,`,
+		`Peers:` + strings.Replace(fmt.Sprintf("%v", this.Peers), "PeerRecord", "PeerRecord", 1) + `,`,
+		`Issued:` + strings.Replace(fmt.Sprintf("%v", this.Issued), "HandoverSession", "HandoverSession", 1) + `,`,
+		`Held:` + strings.Replace(fmt.Sprintf("%v", this.Held), "HandoverSession", "HandoverSession", 1) + `,`,
+		`Addresses:` + strings.Replace(fmt.Sprintf("%v", this.Addresses), "HandoverAddress", "HandoverAddress", 1) + `,`,
+		`Reachability:` + fmt.Sprintf("%v", this.Reachability) + `,`,
+		`ReachabilityOutcomes:` + fmt.Sprintf("%v", this.ReachabilityOutcomes) + `,`,
+		`ReachabilityChecked:` + fmt.Sprintf("%v", this.ReachabilityChecked) + `,`,
+		`Plugins:` + strings.Replace(fmt.Sprintf("%v", this.Plugins), "HandoverPlugin", "HandoverPlugin", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *JournalEntry) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&JournalEntry{`,
+		`Frame:` + fmt.Sprintf("%v", this.Frame) + `,`,
+		`Payload:` + strings.Replace(fmt.Sprintf("%v", this.Payload), "Any", "google_protobuf.Any", 1) + `,`,
+		`ReceivedAt:` + fmt.Sprintf("%v", this.ReceivedAt) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandshakeExtension) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandshakeExtension{`,
+		`Name:` + fmt.Sprintf("%v", this.Name) + `,`,
+		`Payload:` + fmt.Sprintf("%v", this.Payload) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandshakeExtensions) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandshakeExtensions{`,
+		`Sender:` + strings.Replace(fmt.Sprintf("%v", this.Sender), "ID", "ID", 1) + `,`,
+		`Extensions:` + strings.Replace(fmt.Sprintf("%v", this.Extensions), "HandshakeExtension", "HandshakeExtension", 1) + `,`,
+		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Affinity) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Affinity{`,
+		`Token:` + fmt.Sprintf("%v", this.Token) + `,`,
+		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
+		`Lifetime:` + fmt.Sprintf("%v", this.Lifetime) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *AffinityRevoked) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&AffinityRevoked{`,
+		`Tokens:` + fmt.Sprintf("%v", this.Tokens) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *HandshakePuzzle) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&HandshakePuzzle{`,
+		`Seed:` + fmt.Sprintf("%v", this.Seed) + `,`,
+		`Difficulty:` + fmt.Sprintf("%v", this.Difficulty) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *Chunk) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&Chunk{`,
+		`Transfer:` + fmt.Sprintf("%v", this.Transfer) + `,`,
+		`Index:` + fmt.Sprintf("%v", this.Index) + `,`,
+		`Count:` + fmt.Sprintf("%v", this.Count) + `,`,
+		`Size_:` + fmt.Sprintf("%v", this.Size_) + `,`,
+		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringStream(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *ID) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ID: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ID: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Id = append(m.Id[:0], dAtA[iNdEx:postIndex]...)
+			if m.Id == nil {
+				m.Id = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Message) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Message: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Message: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Message == nil {
+				m.Message = &google_protobuf.Any{}
+			}
+			if err := m.Message.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sender", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Sender == nil {
+				m.Sender = &ID{}
+			}
+			if err := m.Sender.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestNonce", wireType)
+			}
+			m.RequestNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequestNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageNonce", wireType)
+			}
+			m.MessageNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MessageNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplyFlag", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ReplyFlag = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthStream
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthStream
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipStream(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthStream
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Metadata[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signatures", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signatures = append(m.Signatures, &Signature{})
+			if err := m.Signatures[len(m.Signatures)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint32(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.CriticalExtensions = append(m.CriticalExtensions, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthStream
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint32(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.CriticalExtensions = append(m.CriticalExtensions, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field CriticalExtensions", wireType)
+			}
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Protocol", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Protocol = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BudgetMs", wireType)
+			}
+			m.BudgetMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BudgetMs |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hints", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hints = append(m.Hints, &Hint{})
+			if err := m.Hints[len(m.Hints)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Padding", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Padding = append(m.Padding[:0], dAtA[iNdEx:postIndex]...)
+			if m.Padding == nil {
+				m.Padding = []byte{}
+			}
+			iNdEx = postIndex
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplayNonce", wireType)
+			}
+			m.ReplayNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReplayNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SignatureScheme", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SignatureScheme = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplayStampMs", wireType)
+			}
+			m.ReplayStampMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReplayStampMs |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Signature) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Signature: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Signature: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Scheme", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Scheme = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Ping) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Ping: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Ping: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Batch) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Batch: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Batch: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Payloads", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Payloads = append(m.Payloads, &google_protobuf.Any{})
+			if err := m.Payloads[len(m.Payloads)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Pong) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Pong: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Pong: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Keepalive) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Keepalive: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Keepalive: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
+			if m.Payload == nil {
+				m.Payload = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *KeepaliveAck) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: KeepaliveAck: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: KeepaliveAck: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
+			if m.Payload == nil {
+				m.Payload = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LookupNodeRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LookupNodeRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LookupNodeRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Target", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Target == nil {
+				m.Target = &ID{}
+			}
+			if err := m.Target.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Known", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Known = append(m.Known[:0], dAtA[iNdEx:postIndex]...)
+			if m.Known == nil {
+				m.Known = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LookupNodeResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LookupNodeResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LookupNodeResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Peers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Peers = append(m.Peers, &ID{})
+			if err := m.Peers[len(m.Peers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Compact", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Compact == nil {
+				m.Compact = &CompactPeers{}
+			}
+			if err := m.Compact.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CompactPeers) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CompactPeers: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CompactPeers: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKeys", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKeys = append(m.PublicKeys[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKeys == nil {
+				m.PublicKeys = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KeySize", wireType)
+			}
+			m.KeySize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KeySize |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Prefixes", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Prefixes = append(m.Prefixes, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 4:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint32(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.PrefixIndices = append(m.PrefixIndices, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthStream
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint32(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.PrefixIndices = append(m.PrefixIndices, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field PrefixIndices", wireType)
+			}
+		case 5:
+			if wireType == 0 {
+				var v uint32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (uint32(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.Ports = append(m.Ports, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthStream
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v uint32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (uint32(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.Ports = append(m.Ports, v)
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ports", wireType)
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Bytes) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Bytes: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Bytes: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandshakeOffer) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandshakeOffer: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandshakeOffer: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Versions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Versions = append(m.Versions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Capabilities = append(m.Capabilities, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metadata = append(m.Metadata, &HandshakeMetadata{})
+			if err := m.Metadata[len(m.Metadata)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Services", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Services == nil {
+				m.Services = &ServiceRecords{}
+			}
+			if err := m.Services.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandshakeMetadata) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandshakeMetadata: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandshakeMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Handshake) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Handshake: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Handshake: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sender", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Sender == nil {
+				m.Sender = &ID{}
+			}
+			if err := m.Sender.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offer", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Offer == nil {
+				m.Offer = &HandshakeOffer{}
+			}
+			if err := m.Offer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Echo", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Echo == nil {
+				m.Echo = &HandshakeOffer{}
+			}
+			if err := m.Echo.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SessionToken", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SessionToken = append(m.SessionToken[:0], dAtA[iNdEx:postIndex]...)
+			if m.SessionToken == nil {
+				m.SessionToken = []byte{}
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Resumed", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Resumed = bool(v != 0)
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Control", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Control = bool(v != 0)
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Affinity", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Affinity == nil {
+				m.Affinity = &Affinity{}
+			}
+			if err := m.Affinity.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AffinityToken", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AffinityToken = append(m.AffinityToken[:0], dAtA[iNdEx:postIndex]...)
+			if m.AffinityToken == nil {
+				m.AffinityToken = []byte{}
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Puzzle", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Puzzle == nil {
+				m.Puzzle = &HandshakePuzzle{}
+			}
+			if err := m.Puzzle.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PuzzleSolution", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PuzzleSolution = append(m.PuzzleSolution[:0], dAtA[iNdEx:postIndex]...)
+			if m.PuzzleSolution == nil {
+				m.PuzzleSolution = []byte{}
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EphemeralKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EphemeralKey = append(m.EphemeralKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.EphemeralKey == nil {
+				m.EphemeralKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SignatureScheme", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SignatureScheme = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RatchetRecords", wireType)
+			}
+			m.RatchetRecords = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RatchetRecords |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RatchetIntervalMs", wireType)
+			}
+			m.RatchetIntervalMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RatchetIntervalMs |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PeerRecord) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PeerRecord: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PeerRecord: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Addresses", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Addresses = append(m.Addresses, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LastSeen", wireType)
+			}
+			m.LastSeen = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LastSeen |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Tags = append(m.Tags, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LastConnected", wireType)
+			}
+			m.LastConnected = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LastConnected |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Connects", wireType)
+			}
+			m.Connects = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Connects |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Failures", wireType)
+			}
+			m.Failures = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Failures |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FailureStreak", wireType)
+			}
+			m.FailureStreak = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FailureStreak |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rtt", wireType)
+			}
+			m.Rtt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Rtt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PeerBundle) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PeerBundle: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PeerBundle: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Records", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Records = append(m.Records, &PeerRecord{})
+			if err := m.Records[len(m.Records)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+			}
+			m.CreatedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CreatedAt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxAge", wireType)
+			}
+			m.MaxAge = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxAge |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SignedPeerBundle) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SignedPeerBundle: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SignedPeerBundle: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Bundle", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Bundle = append(m.Bundle[:0], dAtA[iNdEx:postIndex]...)
+			if m.Bundle == nil {
+				m.Bundle = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signer", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signer = append(m.Signer[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signer == nil {
+				m.Signer = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (m *ServiceRecord) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ServiceRecord: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ServiceRecord: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metadata = append(m.Metadata, &HandshakeMetadata{})
+			if err := m.Metadata[len(m.Metadata)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (m *ServiceRecords) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ServiceRecords: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ServiceRecords: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Records", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Records = append(m.Records, &ServiceRecord{})
+			if err := m.Records[len(m.Records)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Rejection) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Rejection: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Rejection: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestNonce", wireType)
+			}
+			m.RequestNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequestNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageNonce", wireType)
+			}
+			m.MessageNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MessageNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			m.Reason = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Reason |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Routed) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Routed: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Routed: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hops", wireType)
+			}
+			m.Hops = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Hops |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxHops", wireType)
+			}
+			m.MaxHops = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxHops |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Message == nil {
+				m.Message = &google_protobuf.Any{}
+			}
+			if err := m.Message.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Hint) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Hint: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Hint: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ReachabilityProbe) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReachabilityProbe: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReachabilityProbe: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ReachabilityResult) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReachabilityResult: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReachabilityResult: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reachable", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reachable = bool(v != 0)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Refused", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Refused = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *UpgradeAnnouncement) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: UpgradeAnnouncement: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: UpgradeAnnouncement: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			m.Version = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Version |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Activation", wireType)
+			}
+			m.Activation = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Activation |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Note", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Note = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IssuedAt", wireType)
+			}
+			m.IssuedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IssuedAt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SignedUpgradeAnnouncement) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SignedUpgradeAnnouncement: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SignedUpgradeAnnouncement: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Announcement", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Announcement = append(m.Announcement[:0], dAtA[iNdEx:postIndex]...)
+			if m.Announcement == nil {
+				m.Announcement = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signer", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signer = append(m.Signer[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signer == nil {
+				m.Signer = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PeerSample) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PeerSample: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PeerSample: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Id == nil {
+				m.Id = &ID{}
+			}
+			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Age", wireType)
+			}
+			m.Age = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Age |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ShuffleRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ShuffleRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ShuffleRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Samples", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Samples = append(m.Samples, &PeerSample{})
+			if err := m.Samples[len(m.Samples)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ShuffleResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ShuffleResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ShuffleResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Samples", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Samples = append(m.Samples, &PeerSample{})
+			if err := m.Samples[len(m.Samples)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DiagnosticsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DiagnosticsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DiagnosticsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SentAt", wireType)
+			}
+			m.SentAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SentAt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DiagnosticsReport) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DiagnosticsReport: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DiagnosticsReport: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Refused", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Refused = bool(v != 0)
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rtt", wireType)
+			}
+			m.Rtt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Rtt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ObservedAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ObservedAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessagesSent", wireType)
+			}
+			m.MessagesSent = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MessagesSent |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessagesReceived", wireType)
+			}
+			m.MessagesReceived = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MessagesReceived |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BytesSent", wireType)
+			}
+			m.BytesSent = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BytesSent |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BytesReceived", wireType)
+			}
+			m.BytesReceived = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.BytesReceived |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Violations", wireType)
+			}
+			m.Violations = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Violations |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Quarantined", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Quarantined = bool(v != 0)
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Time", wireType)
+			}
+			m.Time = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Time |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Goodbye) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Goodbye: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Goodbye: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RetryAfter", wireType)
+			}
+			m.RetryAfter = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RetryAfter |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandoverSession) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandoverSession: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandoverSession: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Token", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Token = append(m.Token[:0], dAtA[iNdEx:postIndex]...)
+			if m.Token == nil {
+				m.Token = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Version = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offer", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Offer == nil {
+				m.Offer = &HandshakeOffer{}
+			}
+			if err := m.Offer.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageNonce", wireType)
+			}
+			m.MessageNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MessageNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequestNonce", wireType)
+			}
+			m.RequestNonce = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RequestNonce |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Expiry", wireType)
+			}
+			m.Expiry = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Expiry |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Resumption", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Resumption = append(m.Resumption[:0], dAtA[iNdEx:postIndex]...)
+			if m.Resumption == nil {
+				m.Resumption = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandoverAddress) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandoverAddress: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandoverAddress: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			m.Status = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Status |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Expiry", wireType)
+			}
+			m.Expiry = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Expiry |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandoverPlugin) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandoverPlugin: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandoverPlugin: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.State = append(m.State[:0], dAtA[iNdEx:postIndex]...)
+			if m.State == nil {
+				m.State = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandoverState) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandoverState: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandoverState: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
+			if m.PublicKey == nil {
+				m.PublicKey = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+			}
+			m.CreatedAt = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CreatedAt |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Peers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Peers = append(m.Peers, &PeerRecord{})
+			if err := m.Peers[len(m.Peers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Issued", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Issued = append(m.Issued, &HandoverSession{})
+			if err := m.Issued[len(m.Issued)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Held", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Held = append(m.Held, &HandoverSession{})
+			if err := m.Held[len(m.Held)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Addresses", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Addresses = append(m.Addresses, &HandoverAddress{})
+			if err := m.Addresses[len(m.Addresses)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reachability", wireType)
+			}
+			m.Reachability = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Reachability |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType == 0 {
+				var v int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				m.ReachabilityOutcomes = append(m.ReachabilityOutcomes, bool(v != 0))
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowStream
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthStream
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
+					return io.ErrUnexpectedEOF
+				}
+				for iNdEx < postIndex {
+					var v int
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowStream
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (int(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.ReachabilityOutcomes = append(m.ReachabilityOutcomes, bool(v != 0))
+				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReachabilityOutcomes", wireType)
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReachabilityChecked", wireType)
+			}
+			m.ReachabilityChecked = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ReachabilityChecked |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Plugins", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Plugins = append(m.Plugins, &HandoverPlugin{})
+			if err := m.Plugins[len(m.Plugins)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
 		}
 	}
-	return n
-}
-func sozStream(x uint64) (n int) {
-	return sovStream(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (this *ID) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&ID{`,
-		`PublicKey:` + fmt.Sprintf("%v", this.PublicKey) + `,`,
-		`Address:` + fmt.Sprintf("%v", this.Address) + `,`,
-		`Id:` + fmt.Sprintf("%v", this.Id) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *Message) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&Message{`,
-		`Message:` + strings.Replace(fmt.Sprintf("%v", this.Message), "Any", "google_protobuf.Any", 1) + `,`,
-		`Sender:` + strings.Replace(fmt.Sprintf("%v", this.Sender), "ID", "ID", 1) + `,`,
-		`Signature:` + fmt.Sprintf("%v", this.Signature) + `,`,
-		`RequestNonce:` + fmt.Sprintf("%v", this.RequestNonce) + `,`,
-		`MessageNonce:` + fmt.Sprintf("%v", this.MessageNonce) + `,`,
-		`ReplyFlag:` + fmt.Sprintf("%v", this.ReplyFlag) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *Ping) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&Ping{`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *Pong) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&Pong{`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *LookupNodeRequest) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&LookupNodeRequest{`,
-		`Target:` + strings.Replace(fmt.Sprintf("%v", this.Target), "ID", "ID", 1) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *LookupNodeResponse) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&LookupNodeResponse{`,
-		`Peers:` + strings.Replace(fmt.Sprintf("%v", this.Peers), "ID", "ID", 1) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *Bytes) String() string {
-	if this == nil {
-		return "nil"
-	}
-	s := strings.Join([]string{`&Bytes{`,
-		`Data:` + fmt.Sprintf("%v", this.Data) + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func valueToStringStream(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
-		return "nil"
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
+	return nil
 }
-func (m *ID) Unmarshal(dAtA []byte) error {
+func (m *JournalEntry) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1145,15 +14847,15 @@ func (m *ID) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ID: wiretype end group for non-group")
+			return fmt.Errorf("proto: JournalEntry: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ID: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: JournalEntry: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Frame", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1177,16 +14879,16 @@ func (m *ID) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.PublicKey = append(m.PublicKey[:0], dAtA[iNdEx:postIndex]...)
-			if m.PublicKey == nil {
-				m.PublicKey = []byte{}
+			m.Frame = append(m.Frame[:0], dAtA[iNdEx:postIndex]...)
+			if m.Frame == nil {
+				m.Frame = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1196,26 +14898,30 @@ func (m *ID) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Address = string(dAtA[iNdEx:postIndex])
+			if m.Payload == nil {
+				m.Payload = &google_protobuf.Any{}
+			}
+			if err := m.Payload.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ReceivedAt", wireType)
 			}
-			var byteLen int
+			m.ReceivedAt = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1225,23 +14931,11 @@ func (m *ID) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				m.ReceivedAt |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthStream
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Id = append(m.Id[:0], dAtA[iNdEx:postIndex]...)
-			if m.Id == nil {
-				m.Id = []byte{}
-			}
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStream(dAtA[iNdEx:])
@@ -1263,7 +14957,7 @@ func (m *ID) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Message) Unmarshal(dAtA []byte) error {
+func (m *HandshakeExtension) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1286,17 +14980,17 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Message: wiretype end group for non-group")
+			return fmt.Errorf("proto: HandshakeExtension: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Message: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: HandshakeExtension: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1306,30 +15000,26 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Message == nil {
-				m.Message = &google_protobuf.Any{}
-			}
-			if err := m.Message.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Name = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Sender", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1339,30 +15029,78 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Sender == nil {
-				m.Sender = &ID{}
+			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
+			if m.Payload == nil {
+				m.Payload = []byte{}
 			}
-			if err := m.Sender.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipStream(dAtA[iNdEx:])
+			if err != nil {
 				return err
 			}
-			iNdEx = postIndex
-		case 3:
+			if skippy < 0 {
+				return ErrInvalidLengthStream
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HandshakeExtensions) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowStream
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HandshakeExtensions: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HandshakeExtensions: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Sender", wireType)
 			}
-			var byteLen int
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1372,47 +15110,30 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
-			if m.Signature == nil {
-				m.Signature = []byte{}
-			}
-			iNdEx = postIndex
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RequestNonce", wireType)
+			if m.Sender == nil {
+				m.Sender = &ID{}
 			}
-			m.RequestNonce = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowStream
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.RequestNonce |= (uint64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			if err := m.Sender.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MessageNonce", wireType)
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Extensions", wireType)
 			}
-			m.MessageNonce = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1422,16 +15143,28 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.MessageNonce |= (uint64(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 6:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ReplyFlag", wireType)
+			if msglen < 0 {
+				return ErrInvalidLengthStream
 			}
-			var v int
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Extensions = append(m.Extensions, &HandshakeExtension{})
+			if err := m.Extensions[len(m.Extensions)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1441,12 +15174,23 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.ReplyFlag = bool(v != 0)
+			if byteLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStream(dAtA[iNdEx:])
@@ -1468,7 +15212,7 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Ping) Unmarshal(dAtA []byte) error {
+func (m *Affinity) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1491,62 +15235,91 @@ func (m *Ping) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Ping: wiretype end group for non-group")
+			return fmt.Errorf("proto: Affinity: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Ping: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Affinity: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		default:
-			iNdEx = preIndex
-			skippy, err := skipStream(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Token", wireType)
 			}
-			if skippy < 0 {
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
 				return ErrInvalidLengthStream
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *Pong) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowStream
+			m.Token = append(m.Token[:0], dAtA[iNdEx:postIndex]...)
+			if m.Token == nil {
+				m.Token = []byte{}
 			}
-			if iNdEx >= l {
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthStream
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			m.Address = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lifetime", wireType)
+			}
+			m.Lifetime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Lifetime |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Pong: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Pong: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStream(dAtA[iNdEx:])
@@ -1568,7 +15341,7 @@ func (m *Pong) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *LookupNodeRequest) Unmarshal(dAtA []byte) error {
+func (m *AffinityRevoked) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1591,17 +15364,17 @@ func (m *LookupNodeRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: LookupNodeRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: AffinityRevoked: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LookupNodeRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: AffinityRevoked: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Target", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Tokens", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1611,24 +15384,20 @@ func (m *LookupNodeRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Target == nil {
-				m.Target = &ID{}
-			}
-			if err := m.Target.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Tokens = append(m.Tokens, make([]byte, postIndex-iNdEx))
+			copy(m.Tokens[len(m.Tokens)-1], dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1651,7 +15420,7 @@ func (m *LookupNodeRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *LookupNodeResponse) Unmarshal(dAtA []byte) error {
+func (m *HandshakePuzzle) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1674,17 +15443,17 @@ func (m *LookupNodeResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: LookupNodeResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: HandshakePuzzle: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: LookupNodeResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: HandshakePuzzle: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Peers", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Seed", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowStream
@@ -1694,23 +15463,42 @@ func (m *LookupNodeResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthStream
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Peers = append(m.Peers, &ID{})
-			if err := m.Peers[len(m.Peers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Seed = append(m.Seed[:0], dAtA[iNdEx:postIndex]...)
+			if m.Seed == nil {
+				m.Seed = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Difficulty", wireType)
+			}
+			m.Difficulty = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Difficulty |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStream(dAtA[iNdEx:])
@@ -1732,7 +15520,7 @@ func (m *LookupNodeResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Bytes) Unmarshal(dAtA []byte) error {
+func (m *Chunk) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1755,13 +15543,89 @@ func (m *Bytes) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Bytes: wiretype end group for non-group")
+			return fmt.Errorf("proto: Chunk: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Bytes: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Chunk: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Transfer", wireType)
+			}
+			m.Transfer = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Transfer |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
+			}
+			m.Index = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Index |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size_", wireType)
+			}
+			m.Size_ = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStream
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size_ |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
 			}
@@ -1921,32 +15785,104 @@ var (
 func init() { proto.RegisterFile("internal/protobuf/stream.proto", fileDescriptorStream) }
 
 var fileDescriptorStream = []byte{
-	// 431 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x91, 0xcf, 0x6e, 0xd4, 0x30,
-	0x10, 0xc6, 0xeb, 0xed, 0xfe, 0xe9, 0x4e, 0x17, 0x24, 0x2c, 0x84, 0x42, 0xa1, 0x56, 0x14, 0x38,
-	0xec, 0x29, 0x95, 0xca, 0x05, 0x8e, 0xac, 0x2a, 0xa4, 0x02, 0x5d, 0xad, 0xf2, 0x02, 0x2b, 0x6f,
-	0x33, 0xb5, 0xac, 0xa6, 0x76, 0xb0, 0x9d, 0x43, 0x6e, 0x3c, 0x02, 0x8f, 0xc1, 0xa3, 0x70, 0xe4,
-	0xc8, 0xb1, 0x1b, 0x24, 0xce, 0x3c, 0x02, 0x8a, 0xed, 0xd5, 0x22, 0x38, 0x65, 0xbe, 0x6f, 0x7e,
-	0x93, 0xcf, 0xf6, 0x00, 0x93, 0xca, 0xa1, 0x51, 0xbc, 0x3a, 0xab, 0x8d, 0x76, 0x7a, 0xd3, 0xdc,
-	0x9c, 0x59, 0x67, 0x90, 0xdf, 0xe5, 0x5e, 0xd3, 0xa3, 0x9d, 0x7d, 0xf2, 0x54, 0x68, 0x2d, 0x2a,
-	0xdc, 0x73, 0x5c, 0xb5, 0x01, 0x3a, 0xc9, 0x84, 0x16, 0x7a, 0xdf, 0xe8, 0x95, 0x17, 0xbe, 0x0a,
-	0x4c, 0x76, 0x05, 0x83, 0xcb, 0x0b, 0x7a, 0x0a, 0x50, 0x37, 0x9b, 0x4a, 0x5e, 0xaf, 0x6f, 0xb1,
-	0x4d, 0x48, 0x4a, 0xe6, 0xb3, 0x62, 0x1a, 0x9c, 0x0f, 0xd8, 0xd2, 0x04, 0x26, 0xbc, 0x2c, 0x0d,
-	0x5a, 0x9b, 0x0c, 0x52, 0x32, 0x9f, 0x16, 0x3b, 0x49, 0x1f, 0xc2, 0x40, 0x96, 0xc9, 0xa1, 0x1f,
-	0x18, 0xc8, 0x32, 0xfb, 0x45, 0x60, 0x72, 0x85, 0xd6, 0x72, 0x81, 0x34, 0x87, 0xc9, 0x5d, 0x28,
-	0xfd, 0x1f, 0x8f, 0xcf, 0x1f, 0xe7, 0xe1, 0xac, 0xf9, 0xee, 0x48, 0xf9, 0x5b, 0xd5, 0x16, 0x3b,
-	0x88, 0xbe, 0x84, 0xb1, 0x45, 0x55, 0xa2, 0xf1, 0x21, 0xc7, 0xe7, 0xb3, 0x3d, 0x77, 0x79, 0x51,
-	0xc4, 0x1e, 0x7d, 0x0e, 0x53, 0x2b, 0x85, 0xe2, 0xae, 0x31, 0x18, 0x83, 0xf7, 0x06, 0x7d, 0x01,
-	0x0f, 0x0c, 0x7e, 0x6a, 0xd0, 0xba, 0xb5, 0xd2, 0xea, 0x1a, 0x93, 0x61, 0x4a, 0xe6, 0xc3, 0x62,
-	0x16, 0xcd, 0x65, 0xef, 0xf5, 0x50, 0xcc, 0x8c, 0xd0, 0x28, 0x40, 0xd1, 0x0c, 0xd0, 0x29, 0x80,
-	0xc1, 0xba, 0x6a, 0xd7, 0x37, 0x15, 0x17, 0xc9, 0x38, 0x25, 0xf3, 0xa3, 0x62, 0xea, 0x9d, 0x77,
-	0x15, 0x17, 0xd9, 0x18, 0x86, 0x2b, 0xa9, 0xc2, 0x57, 0x2b, 0x91, 0xbd, 0x81, 0x47, 0x1f, 0xb5,
-	0xbe, 0x6d, 0xea, 0xa5, 0x2e, 0xb1, 0x08, 0x69, 0xfd, 0x8d, 0x1c, 0x37, 0x02, 0x5d, 0x7c, 0x80,
-	0x7f, 0x6e, 0x14, 0x7a, 0xd9, 0x6b, 0xa0, 0x7f, 0x8f, 0xda, 0x5a, 0x2b, 0x8b, 0x34, 0x83, 0x51,
-	0x8d, 0x68, 0x6c, 0x42, 0xd2, 0xc3, 0xff, 0x46, 0x43, 0x2b, 0x7b, 0x06, 0xa3, 0x45, 0xeb, 0xd0,
-	0x52, 0x0a, 0xc3, 0x92, 0x3b, 0x1e, 0x37, 0xe7, 0xeb, 0xc5, 0xfb, 0x1f, 0x5b, 0x76, 0x70, 0xbf,
-	0x65, 0xe4, 0xf7, 0x96, 0x91, 0xcf, 0x1d, 0x23, 0x5f, 0x3b, 0x46, 0xbe, 0x75, 0x8c, 0x7c, 0xef,
-	0x18, 0xb9, 0xef, 0x18, 0xf9, 0xf2, 0x93, 0x1d, 0xc0, 0x13, 0x6d, 0x44, 0x5e, 0xa3, 0xa9, 0xa4,
-	0xca, 0x95, 0x96, 0x36, 0x2e, 0x67, 0x01, 0xcb, 0x5e, 0xac, 0xfa, 0x7a, 0x45, 0x36, 0x63, 0x6f,
-	0xbe, 0xfa, 0x13, 0x00, 0x00, 0xff, 0xff, 0xcf, 0x17, 0xa1, 0x82, 0x97, 0x02, 0x00, 0x00,
+	// 1574 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xcd, 0x92, 0x1b, 0xb7,
+	0x11, 0xf6, 0x90, 0x5c, 0x92, 0xd3, 0xcb, 0x5d, 0x6b, 0x21, 0x97, 0x34, 0xfa, 0x31, 0xcd, 0x9a,
+	0x58, 0x55, 0x9b, 0x4a, 0x42, 0x39, 0x72, 0xaa, 0xf2, 0xa3, 0x1c, 0xc2, 0x8d, 0x9c, 0x92, 0x62,
+	0x4b, 0xde, 0xc2, 0x26, 0xc7, 0x14, 0x0d, 0xce, 0xf4, 0x0e, 0xe1, 0x1d, 0x02, 0xf4, 0x00, 0xc3,
+	0x2c, 0x75, 0x72, 0x2e, 0x39, 0xe7, 0x1d, 0x52, 0xa9, 0xca, 0x5b, 0xe4, 0x9a, 0x63, 0x8e, 0x3e,
+	0x5a, 0x9b, 0x17, 0xc8, 0x23, 0xa4, 0xf0, 0x33, 0xc3, 0xa1, 0xbc, 0xd2, 0xca, 0xba, 0xa1, 0xbf,
+	0xfe, 0x06, 0x68, 0xa0, 0xbf, 0x6e, 0x60, 0x60, 0xc8, 0x85, 0xc6, 0x42, 0xb0, 0xfc, 0xfe, 0xb2,
+	0x90, 0x5a, 0xce, 0xca, 0xd3, 0xfb, 0x4a, 0x17, 0xc8, 0x16, 0x63, 0x6b, 0x93, 0x7e, 0x05, 0xdf,
+	0xbe, 0x95, 0x49, 0x99, 0xe5, 0xb8, 0xe1, 0x31, 0xb1, 0x76, 0xa4, 0xdb, 0x71, 0x26, 0x33, 0xb9,
+	0x71, 0x18, 0xcb, 0x1a, 0x76, 0xe4, 0x38, 0xf1, 0x53, 0x68, 0x3d, 0x79, 0x44, 0xde, 0x07, 0x58,
+	0x96, 0xb3, 0x9c, 0x27, 0xd3, 0x33, 0x5c, 0x47, 0xc1, 0x28, 0x38, 0x1c, 0xd0, 0xd0, 0x21, 0x9f,
+	0xe2, 0x9a, 0x44, 0xd0, 0x63, 0x69, 0x5a, 0xa0, 0x52, 0x51, 0x6b, 0x14, 0x1c, 0x86, 0xb4, 0x32,
+	0xc9, 0x3e, 0xb4, 0x78, 0x1a, 0xb5, 0xed, 0x07, 0x2d, 0x9e, 0xc6, 0xff, 0xe8, 0x40, 0xef, 0x29,
+	0x2a, 0xc5, 0x32, 0x24, 0x63, 0xe8, 0x2d, 0xdc, 0xd0, 0xce, 0xb8, 0xfb, 0xe0, 0xbd, 0xb1, 0x8b,
+	0x75, 0x5c, 0x85, 0x34, 0x9e, 0x88, 0x35, 0xad, 0x48, 0xe4, 0x43, 0xe8, 0x2a, 0x14, 0x29, 0x16,
+	0x76, 0x91, 0xdd, 0x07, 0x83, 0x0d, 0xef, 0xc9, 0x23, 0xea, 0x7d, 0xe4, 0x2e, 0x84, 0x8a, 0x67,
+	0x82, 0xe9, 0xb2, 0x40, 0xbf, 0xf0, 0x06, 0x20, 0x3f, 0x80, 0xbd, 0x02, 0xbf, 0x2a, 0x51, 0xe9,
+	0xa9, 0x90, 0x22, 0xc1, 0xa8, 0x33, 0x0a, 0x0e, 0x3b, 0x74, 0xe0, 0xc1, 0x67, 0x06, 0x33, 0x24,
+	0xbf, 0xa6, 0x27, 0xed, 0x38, 0x92, 0x07, 0x1d, 0xe9, 0x7d, 0x80, 0x02, 0x97, 0xf9, 0x7a, 0x7a,
+	0x9a, 0xb3, 0x2c, 0xea, 0x8e, 0x82, 0xc3, 0x3e, 0x0d, 0x2d, 0xf2, 0xbb, 0x9c, 0x65, 0xe4, 0x21,
+	0xf4, 0x17, 0xa8, 0x59, 0xca, 0x34, 0x8b, 0x7a, 0xa3, 0xf6, 0xe1, 0xee, 0x83, 0x0f, 0x36, 0xe1,
+	0xfa, 0x13, 0x18, 0x3f, 0xf5, 0x8c, 0x4f, 0x84, 0x2e, 0xd6, 0xb4, 0xfe, 0x80, 0x7c, 0x0c, 0x50,
+	0x87, 0xac, 0xa2, 0xbe, 0xfd, 0xfc, 0xfa, 0xe6, 0xf3, 0x93, 0xca, 0x47, 0x1b, 0x34, 0x72, 0x1f,
+	0xae, 0x27, 0x05, 0xd7, 0x3c, 0x61, 0xf9, 0x14, 0xcf, 0x35, 0x0a, 0xc5, 0xa5, 0x50, 0x51, 0x38,
+	0x6a, 0x1f, 0xee, 0x51, 0x52, 0xb9, 0x3e, 0xa9, 0x3d, 0xe4, 0x36, 0x38, 0x95, 0x24, 0x32, 0x8f,
+	0xc0, 0xa6, 0xad, 0xb6, 0xc9, 0x1d, 0x08, 0x67, 0x65, 0x9a, 0xa1, 0x9e, 0x2e, 0x54, 0xb4, 0x6b,
+	0xb7, 0xdf, 0x77, 0xc0, 0x53, 0x45, 0x3e, 0x84, 0x9d, 0x39, 0x17, 0x5a, 0x45, 0x03, 0x1b, 0xd9,
+	0xfe, 0x26, 0xb2, 0xc7, 0x5c, 0x68, 0xea, 0x9c, 0xb7, 0x1f, 0xc2, 0xde, 0xd6, 0xfe, 0xc8, 0x35,
+	0x68, 0x57, 0xea, 0x09, 0xa9, 0x19, 0x92, 0xf7, 0x60, 0x67, 0xc5, 0xf2, 0x12, 0xbd, 0x6a, 0x9c,
+	0xf1, 0xab, 0xd6, 0x2f, 0x82, 0xf8, 0x0b, 0x08, 0xeb, 0x5d, 0x92, 0x1b, 0xd0, 0x55, 0xc9, 0x1c,
+	0x17, 0xe8, 0xbf, 0xf5, 0xd6, 0x4b, 0xaa, 0x6c, 0xbd, 0xac, 0xca, 0xd7, 0x2a, 0x21, 0xee, 0x42,
+	0xe7, 0x98, 0x8b, 0x2c, 0xfe, 0x25, 0xec, 0x1c, 0x31, 0x9d, 0xcc, 0xc9, 0x47, 0xd0, 0x5f, 0xb2,
+	0x75, 0x2e, 0x59, 0xaa, 0xa2, 0x60, 0xd4, 0x7e, 0xa5, 0x1e, 0x6b, 0x96, 0x9d, 0x42, 0x8a, 0x2c,
+	0xbe, 0x07, 0xe1, 0xa7, 0x88, 0x4b, 0x96, 0xf3, 0x15, 0x9a, 0x5a, 0xf0, 0x04, 0x5f, 0x27, 0x95,
+	0x19, 0x1f, 0xc2, 0xa0, 0xa6, 0x4d, 0x92, 0xb3, 0xd7, 0x30, 0x3f, 0x87, 0x83, 0xcf, 0xa4, 0x3c,
+	0x2b, 0x97, 0xcf, 0x64, 0x8a, 0xd4, 0x49, 0xd3, 0xc8, 0x5f, 0xb3, 0x22, 0x43, 0x1d, 0x05, 0x97,
+	0xc9, 0xdf, 0xf9, 0xcc, 0x91, 0x9e, 0x09, 0xf9, 0x67, 0xe1, 0x8f, 0xc3, 0x19, 0xf1, 0x97, 0x40,
+	0x9a, 0x13, 0xaa, 0xa5, 0x14, 0x0a, 0x49, 0x0c, 0x3b, 0x4b, 0xc4, 0xa2, 0xda, 0xee, 0xf6, 0x84,
+	0xce, 0x45, 0x3e, 0x82, 0x5e, 0x22, 0x17, 0x4b, 0x96, 0x68, 0x5f, 0x75, 0x37, 0x36, 0xac, 0xdf,
+	0x3a, 0xc7, 0xb1, 0x21, 0xd2, 0x8a, 0x16, 0xff, 0x3d, 0x80, 0x41, 0xd3, 0x43, 0x3e, 0x80, 0xdd,
+	0x4d, 0x9a, 0x94, 0xdf, 0x2b, 0xd4, 0x79, 0x52, 0xe4, 0x16, 0xf4, 0xcf, 0x70, 0x3d, 0x55, 0xfc,
+	0xb9, 0x53, 0xc2, 0x1e, 0xed, 0x9d, 0xe1, 0xfa, 0x84, 0x3f, 0x47, 0xa7, 0x51, 0x3c, 0xe5, 0xe7,
+	0xa8, 0xa2, 0xf6, 0xa8, 0xed, 0x34, 0xea, 0x6c, 0x72, 0x0f, 0xf6, 0xdd, 0x78, 0xca, 0x45, 0xca,
+	0x13, 0x54, 0x51, 0xc7, 0x6a, 0x7d, 0xcf, 0xa1, 0x4f, 0x1c, 0x68, 0x4e, 0x64, 0x29, 0x0b, 0xad,
+	0xa2, 0x1d, 0xeb, 0x75, 0x46, 0x7c, 0x07, 0x76, 0x8e, 0xd6, 0x1a, 0x15, 0x21, 0xd0, 0xb1, 0x45,
+	0xea, 0xc2, 0xb2, 0xe3, 0xf8, 0x5f, 0x01, 0xec, 0x3f, 0x66, 0x22, 0x55, 0x73, 0x76, 0x86, 0x9f,
+	0x9f, 0x9e, 0x62, 0x61, 0x02, 0x59, 0x61, 0xe1, 0x4a, 0x2a, 0x70, 0x81, 0x54, 0x36, 0x89, 0x61,
+	0x90, 0xb0, 0x25, 0x9b, 0xf1, 0x9c, 0x6b, 0x8e, 0xa6, 0x07, 0x1a, 0xff, 0x16, 0x46, 0x7e, 0xde,
+	0xe8, 0x07, 0x6d, 0x7b, 0xdc, 0x77, 0x1a, 0x65, 0x53, 0xad, 0x55, 0x15, 0x4c, 0xa3, 0x17, 0xfc,
+	0x0c, 0xfa, 0x0a, 0x8b, 0x95, 0xdf, 0x9f, 0xc9, 0x40, 0xd4, 0xe8, 0x04, 0xce, 0x43, 0x31, 0x91,
+	0x45, 0xaa, 0x68, 0xcd, 0x8c, 0x1f, 0xc2, 0xc1, 0x77, 0x26, 0xbd, 0xaa, 0x00, 0x07, 0xbe, 0x00,
+	0xe3, 0xbf, 0xb6, 0x20, 0xac, 0xbf, 0x6e, 0xb4, 0xdd, 0xe0, 0x35, 0x6d, 0x77, 0x0c, 0x3b, 0xd2,
+	0x1c, 0x54, 0xd4, 0x7a, 0x39, 0xc6, 0xed, 0x83, 0xa4, 0x8e, 0x46, 0x7e, 0x0c, 0x1d, 0x4c, 0xe6,
+	0x32, 0x6a, 0x5f, 0x41, 0xb7, 0xac, 0xed, 0x52, 0xee, 0x5c, 0xd2, 0xd4, 0x15, 0x2a, 0x93, 0x8b,
+	0xa9, 0x96, 0x67, 0x28, 0x6c, 0xbf, 0x1e, 0xd0, 0x81, 0x07, 0xff, 0x60, 0x30, 0x53, 0x6d, 0x05,
+	0xaa, 0x72, 0x81, 0xa9, 0x6f, 0xd6, 0x95, 0x69, 0x3c, 0x89, 0x14, 0xba, 0x90, 0x79, 0xd4, 0x73,
+	0x1e, 0x6f, 0xc6, 0xcf, 0x01, 0x8c, 0x84, 0xdd, 0xf1, 0x5e, 0x75, 0x09, 0xde, 0x85, 0xd0, 0xdf,
+	0x7a, 0xb5, 0x04, 0x36, 0x80, 0x69, 0xa8, 0x39, 0x53, 0x7a, 0xaa, 0x10, 0x85, 0xdd, 0x74, 0x9b,
+	0xf6, 0x0d, 0x70, 0x82, 0x28, 0x8c, 0x06, 0x35, 0xcb, 0x9c, 0x7e, 0x43, 0x6a, 0xc7, 0xb1, 0x76,
+	0x6b, 0x1f, 0x95, 0x22, 0xcd, 0xed, 0x5d, 0x59, 0xb8, 0x24, 0xd7, 0xbd, 0xa9, 0x3e, 0xb1, 0x4d,
+	0x88, 0xb4, 0x22, 0x99, 0x58, 0x93, 0x02, 0x99, 0xc6, 0x74, 0xca, 0x5c, 0xe5, 0xb6, 0x69, 0xe8,
+	0x91, 0x89, 0x26, 0x37, 0xa1, 0xb7, 0x60, 0xe7, 0x53, 0x73, 0xf5, 0xba, 0x58, 0xba, 0x0b, 0x76,
+	0x3e, 0xc9, 0x30, 0xfe, 0x02, 0xae, 0x99, 0xbe, 0x8b, 0x69, 0x63, 0xed, 0x1b, 0xd0, 0x9d, 0xd9,
+	0x91, 0xdf, 0x73, 0x77, 0x56, 0xe3, 0x26, 0x07, 0x3e, 0xe7, 0x03, 0xea, 0xad, 0x2b, 0xfa, 0xee,
+	0x0a, 0xf6, 0xb6, 0x54, 0x6b, 0x36, 0x2f, 0x58, 0xdd, 0xdb, 0xed, 0xf8, 0x35, 0x0f, 0x8a, 0xb7,
+	0xad, 0xa3, 0xf8, 0x4f, 0xb0, 0xbf, 0x5d, 0x2d, 0xe4, 0xa7, 0x2f, 0x9f, 0xe9, 0xcd, 0x57, 0x14,
+	0xd6, 0xe6, 0x58, 0x23, 0xe8, 0xf9, 0xaa, 0xb7, 0x71, 0x75, 0x68, 0x65, 0xc6, 0x7f, 0x09, 0x20,
+	0xa4, 0xf8, 0x25, 0x26, 0x9a, 0x4b, 0xf1, 0xdd, 0x67, 0x46, 0xf0, 0x26, 0xcf, 0x8c, 0xd6, 0x25,
+	0xcf, 0x0c, 0x02, 0x9d, 0x39, 0x53, 0x73, 0x7f, 0x8e, 0x76, 0x6c, 0x0e, 0xbe, 0x40, 0xa6, 0xa4,
+	0xb0, 0xa5, 0xb0, 0x47, 0xbd, 0x15, 0xaf, 0xa1, 0x4b, 0x65, 0xa9, 0x31, 0x6d, 0x56, 0xfa, 0xc0,
+	0x55, 0xba, 0x99, 0x47, 0x2e, 0x95, 0xef, 0xaf, 0x76, 0x6c, 0xfa, 0xae, 0x51, 0x81, 0xc5, 0xdb,
+	0xae, 0xef, 0x2e, 0xd8, 0xf9, 0x63, 0xe3, 0x6a, 0xbc, 0xcd, 0x3a, 0x6f, 0xf0, 0x36, 0x8b, 0xc7,
+	0xd0, 0x31, 0x77, 0xff, 0x1b, 0xb7, 0x98, 0xeb, 0x70, 0x40, 0x91, 0x25, 0x73, 0xd7, 0x20, 0xd7,
+	0xc7, 0x85, 0x9c, 0x61, 0xfc, 0x19, 0x90, 0x26, 0x48, 0x51, 0x95, 0xb9, 0x36, 0x72, 0x2a, 0x1c,
+	0xea, 0x15, 0xd8, 0xa7, 0x1b, 0xc0, 0x95, 0xf5, 0x69, 0xa9, 0x30, 0x8d, 0x5a, 0x55, 0x59, 0x5b,
+	0x33, 0xfe, 0x3a, 0x80, 0xeb, 0x7f, 0x5c, 0x66, 0x05, 0x4b, 0x71, 0x22, 0x84, 0x2c, 0x45, 0x82,
+	0x0b, 0x14, 0xba, 0x99, 0xc3, 0xc0, 0x6d, 0xda, 0x9b, 0x64, 0x08, 0xc0, 0x12, 0xcd, 0x57, 0x4c,
+	0x57, 0x09, 0x6e, 0xd3, 0x06, 0x62, 0x95, 0x2a, 0xb5, 0xd3, 0xb4, 0x51, 0xaa, 0xd4, 0x68, 0xea,
+	0x9a, 0x2b, 0x55, 0xba, 0x3a, 0xeb, 0xb8, 0xba, 0x76, 0xc0, 0x44, 0xc7, 0x25, 0xdc, 0x72, 0xd5,
+	0x74, 0x59, 0x1c, 0x31, 0x0c, 0x58, 0xc3, 0xf6, 0xc9, 0xda, 0xc2, 0xde, 0xb2, 0xc4, 0x7e, 0xed,
+	0x5a, 0xc7, 0x09, 0x5b, 0x2c, 0x73, 0x24, 0x77, 0xed, 0x13, 0xfc, 0xb2, 0xde, 0xdd, 0xe2, 0x56,
+	0x29, 0x26, 0xc9, 0x4e, 0x16, 0x66, 0x18, 0xff, 0x06, 0xf6, 0x4f, 0xe6, 0xe5, 0xe9, 0x69, 0x5e,
+	0xbf, 0x3c, 0xc6, 0xd0, 0x53, 0x76, 0xae, 0x57, 0x34, 0x1f, 0xb7, 0x10, 0xad, 0x48, 0xf1, 0x04,
+	0xde, 0xad, 0x67, 0xf0, 0x4f, 0x8d, 0xef, 0x3b, 0xc5, 0x4f, 0x80, 0x3c, 0xe2, 0x2c, 0x13, 0x52,
+	0x69, 0x9e, 0xa8, 0x2a, 0x90, 0x9b, 0xd0, 0x53, 0x28, 0xb4, 0x39, 0xea, 0xc0, 0xb5, 0x2d, 0x63,
+	0x4e, 0x74, 0xfc, 0x4d, 0x0b, 0x0e, 0xb6, 0xf8, 0xe6, 0x92, 0x6f, 0x6a, 0x23, 0xd8, 0xd2, 0x86,
+	0xd9, 0x75, 0xa1, 0xab, 0xbe, 0x68, 0x86, 0xe4, 0x87, 0x70, 0x4d, 0xce, 0xcc, 0xf5, 0x69, 0x32,
+	0xe9, 0x5b, 0x8f, 0xcb, 0xf3, 0xbb, 0x15, 0x3e, 0x71, 0x70, 0xa3, 0x6e, 0xd5, 0xd4, 0xac, 0x5f,
+	0xfd, 0x43, 0x54, 0xe0, 0x89, 0xc9, 0xdc, 0x8f, 0xe0, 0xa0, 0x26, 0x15, 0x98, 0x20, 0x5f, 0x61,
+	0xea, 0xff, 0x23, 0xae, 0x55, 0x0e, 0xea, 0x71, 0xd3, 0xad, 0x67, 0x6b, 0x5d, 0x4d, 0xd7, 0xb5,
+	0xac, 0xd0, 0x22, 0x76, 0xae, 0x7b, 0xb0, 0xef, 0xdc, 0xf5, 0x44, 0x3d, 0x4b, 0xd9, 0xb3, 0x68,
+	0x3d, 0xcb, 0x10, 0x60, 0xc5, 0x65, 0x6e, 0xb5, 0x6a, 0xfe, 0x1a, 0x0c, 0xa5, 0x81, 0x90, 0x11,
+	0xec, 0x7e, 0x55, 0xb2, 0x82, 0x09, 0xcd, 0x05, 0xa6, 0x51, 0x68, 0x8f, 0xa4, 0x09, 0xd9, 0x7b,
+	0x88, 0x2f, 0xd0, 0xfe, 0x0d, 0xb4, 0xa9, 0x1d, 0x1f, 0xfd, 0xfe, 0x9b, 0x17, 0xc3, 0x77, 0xbe,
+	0x7d, 0x31, 0x0c, 0xfe, 0xf7, 0x62, 0x18, 0x7c, 0x7d, 0x31, 0x0c, 0xfe, 0x79, 0x31, 0x0c, 0xfe,
+	0x7d, 0x31, 0x0c, 0xfe, 0x73, 0x31, 0x0c, 0xbe, 0xbd, 0x18, 0x06, 0x7f, 0xfb, 0xef, 0xf0, 0x1d,
+	0xb8, 0x21, 0x8b, 0x6c, 0xbc, 0xc4, 0x22, 0xe7, 0x62, 0x2c, 0x24, 0x57, 0xbe, 0x4f, 0x1c, 0xc1,
+	0x33, 0x63, 0x1c, 0x9b, 0xf1, 0x71, 0x30, 0xeb, 0x5a, 0xf0, 0xe3, 0xff, 0x0f, 0x00, 0x77, 0x93,
+	0x37, 0xd0, 0xbe, 0x0e, 0x00, 0x00,
 }
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/perlin-network/noise/network"
+)
+
+// Divergence is a captured frame which replayed differently than it was
+// handled when captured.
+type Divergence struct {
+	// Index is the position of the frame within the capture.
+	Index int
+	// Frame is the captured frame.
+	Frame *network.CapturedFrame
+	// Err describes how the replay diverged.
+	Err error
+}
+
+func (d Divergence) Error() string {
+	return fmt.Sprintf("frame %d received from %s: %v", d.Index, d.Frame.Peer, d.Err)
+}
+
+// ReplayReport summarizes the replay of a capture.
+type ReplayReport struct {
+	// Frames is the number of received frames replayed.
+	Frames int
+	// Delivered is the number of payloads handed to plugins.
+	Delivered int
+	// Divergences lists the frames which verified differently than they did
+	// when captured, and the errors plugins returned or panicked with.
+	Divergences []Divergence
+}
+
+// Replay feeds all frames a node received within a capture through the
+// decoding, verification and dispatch of n, without any sockets, so that the
+// plugins of n may be debugged against recorded traffic in isolation. Frames
+// the node sent are skipped.
+func Replay(n *network.Network, r io.Reader) (*ReplayReport, error) {
+	reader, err := network.NewCaptureReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(ReplayReport)
+
+	for index := 0; ; index++ {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+
+		if frame.Direction != network.DirectionInbound {
+			continue
+		}
+		report.Frames++
+
+		result, err := n.ReplayFrame(frame.Frame)
+		if err != nil {
+			report.Divergences = append(report.Divergences, Divergence{Index: index, Frame: frame, Err: err})
+			continue
+		}
+
+		if result.Verified != frame.Verified {
+			report.Divergences = append(report.Divergences, Divergence{
+				Index: index,
+				Frame: frame,
+				Err:   fmt.Errorf("frame verified as %t, though was captured as %t", result.Verified, frame.Verified),
+			})
+		}
+
+		report.Delivered += result.Delivered
+		for _, err := range result.Errors {
+			report.Divergences = append(report.Divergences, Divergence{Index: index, Frame: frame, Err: err})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorderPlugin records the payloads of all test messages received, and
+// optionally fails handling some of them.
+type recorderPlugin struct {
+	*network.Plugin
+
+	mutex    sync.Mutex
+	received []string
+	fail     string
+}
+
+func (p *recorderPlugin) Receive(ctx *network.PluginContext) error {
+	msg, ok := ctx.Message().(*protobuf.TestMessage)
+	if !ok {
+		return nil
+	}
+
+	p.mutex.Lock()
+	p.received = append(p.received, msg.Message)
+	p.mutex.Unlock()
+
+	if msg.Message == p.fail {
+		return errors.New("refusing " + msg.Message)
+	}
+	return nil
+}
+
+func (p *recorderPlugin) messages() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append([]string(nil), p.received...)
+}
+
+func buildNode(t *testing.T, plugin *recorderPlugin, listen bool) *network.Network {
+	builder := network.NewBuilder()
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+	builder.AddPlugin(plugin)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	if listen {
+		go node.Listen()
+		<-node.Ready()
+	}
+	return node
+}
+
+func TestReplayCapturedExchange(t *testing.T) {
+	t.Parallel()
+
+	alicePlugin, bobPlugin := new(recorderPlugin), new(recorderPlugin)
+
+	alice := buildNode(t, alicePlugin, true)
+	defer alice.Close()
+	bob := buildNode(t, bobPlugin, true)
+	defer bob.Close()
+
+	var aliceCapture, bobCapture bytes.Buffer
+	assert.Nil(t, alice.StartCapture(&aliceCapture, 0))
+	assert.Nil(t, bob.StartCapture(&bobCapture, 0))
+
+	toBob, err := alice.Client(bob.Address)
+	assert.Nil(t, err)
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		assert.Nil(t, toBob.Tell(&protobuf.TestMessage{Message: fmt.Sprintf("ping %d", i)}))
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(bobPlugin.messages()) < count && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	toAlice, err := bob.Client(alice.Address)
+	assert.Nil(t, err)
+
+	for i := 0; i < count; i++ {
+		assert.Nil(t, toAlice.Tell(&protobuf.TestMessage{Message: fmt.Sprintf("pong %d", i)}))
+	}
+
+	for len(alicePlugin.messages()) < count && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Nil(t, alice.StopCapture())
+	assert.Nil(t, bob.StopCapture())
+
+	assert.Len(t, bobPlugin.messages(), count)
+	assert.Len(t, alicePlugin.messages(), count)
+	assert.True(t, bob.CaptureStats().Frames >= 2*count)
+
+	// Handlers replaying either capture observe the same payloads as the
+	// handlers of the node which captured it, which ran concurrently.
+	for _, replay := range []struct {
+		capture *bytes.Buffer
+		live    *recorderPlugin
+	}{{&bobCapture, bobPlugin}, {&aliceCapture, alicePlugin}} {
+		replayPlugin := new(recorderPlugin)
+
+		node := buildNode(t, replayPlugin, false)
+		defer node.Close()
+
+		report, err := Replay(node, bytes.NewReader(replay.capture.Bytes()))
+		assert.Nil(t, err)
+		assert.Empty(t, report.Divergences)
+		assert.Equal(t, count, report.Delivered)
+		assert.ElementsMatch(t, replay.live.messages(), replayPlugin.messages())
+	}
+
+	// Handler errors and frames which no longer verify are reported.
+	failing := &recorderPlugin{fail: "ping 2"}
+	node := buildNode(t, failing, false)
+	defer node.Close()
+
+	report, err := Replay(node, bytes.NewReader(bobCapture.Bytes()))
+	assert.Nil(t, err)
+	if assert.Len(t, report.Divergences, 1) {
+		assert.EqualError(t, report.Divergences[0].Err, "refusing ping 2")
+	}
+
+	tampered := bytes.Replace(bobCapture.Bytes(), []byte("ping 3"), []byte("ping 9"), 1)
+	report, err = Replay(node, bytes.NewReader(tampered))
+	assert.Nil(t, err)
+	assert.Equal(t, count-1, report.Delivered)
+	if assert.Len(t, report.Divergences, 2) {
+		assert.Contains(t, report.Divergences[1].Err.Error(), "frame verified as false")
+	}
+}
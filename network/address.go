@@ -17,10 +17,16 @@ type AddressInfo struct {
 	Protocol string
 	Host     string
 	Port     uint16
+
+	// Path is the socket file of Unix domain socket addresses, which have
+	// neither a host nor a port.
+	Path string
 }
 
 const (
 	networkClientName = "noise"
+
+	unixProtocol = "unix"
 )
 
 // Errors
@@ -48,6 +54,10 @@ func (info *AddressInfo) Network() string {
 // String prints out either the URL representation of the address info, or
 // solely just a joined host and port should a network scheme not be defined.
 func (info *AddressInfo) String() string {
+	if info.Path != "" {
+		return unixProtocol + "://" + info.Path
+	}
+
 	address := net.JoinHostPort(info.Host, strconv.Itoa(int(info.Port)))
 	if len(info.Protocol) > 0 {
 		address = info.Protocol + "://" + address
@@ -55,8 +65,12 @@ func (info *AddressInfo) String() string {
 	return address
 }
 
-// HostPort returns the address wihout protocol, in the format `host:port`.
+// HostPort returns the address wihout protocol, in the format `host:port`,
+// or the socket file of Unix domain socket addresses.
 func (info *AddressInfo) HostPort() string {
+	if info.Path != "" {
+		return info.Path
+	}
 	return net.JoinHostPort(info.Host, strconv.Itoa(int(info.Port)))
 }
 
@@ -65,14 +79,28 @@ func FormatAddress(protocol string, host string, port uint16) string {
 	return NewAddressInfo(protocol, host, port).String()
 }
 
+// FormatUnixAddress marshals the socket file of a Unix domain socket into an
+// address, such as `unix:///tmp/noise.sock`.
+func FormatUnixAddress(path string) string {
+	return (&AddressInfo{Protocol: unixProtocol, Path: path}).String()
+}
+
 // ParseAddress derives a network scheme, host and port of a destinations
-// information. Errors should the provided destination address be malformed.
+// information, or the socket file of Unix domain socket addresses. Errors
+// should the provided destination address be malformed.
 func ParseAddress(address string) (*AddressInfo, error) {
 	urlInfo, err := url.Parse(address)
 	if err != nil {
 		return nil, err
 	}
 
+	if urlInfo.Scheme == unixProtocol {
+		if urlInfo.Host != "" || urlInfo.Path == "" {
+			return nil, errors.Errorf("%s: %q does not denote a socket file", ErrStrInvalidAddress, address)
+		}
+		return &AddressInfo{Protocol: unixProtocol, Path: urlInfo.Path}, nil
+	}
+
 	host, rawPort, err := net.SplitHostPort(urlInfo.Host)
 	if err != nil {
 		return nil, err
@@ -133,6 +161,11 @@ func ToUnifiedAddress(address string) (string, error) {
 		return "", err
 	}
 
+	// Socket files have no host to resolve.
+	if info.Path != "" {
+		return info.String(), nil
+	}
+
 	info.Host, err = ToUnifiedHost(info.Host)
 	if err != nil {
 		return "", err
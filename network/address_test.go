@@ -118,6 +118,8 @@ func TestParseAddress(t *testing.T) {
 		{"https://[2b01:e34:ef40:7730:8e70:5aff:fefe:edac]:foo/foo", "url.Parse fails"},
 		{"tcp://", "empty url error not triggered"},
 		{"tcp://host:k", "port url error not triggered"},
+		{"unix://", "missing socket file not triggered"},
+		{"unix://host/tmp/noise.sock", "unix host not triggered"},
 	}
 	for _, tt := range testCases {
 		_, err := ParseAddress(tt.address)
@@ -127,6 +129,28 @@ func TestParseAddress(t *testing.T) {
 	}
 }
 
+func TestUnixAddress(t *testing.T) {
+	t.Parallel()
+
+	address := FormatUnixAddress("/tmp/noise.sock")
+	if address != "unix:///tmp/noise.sock" {
+		t.Errorf("FormatUnixAddress() = %s, expected unix:///tmp/noise.sock", address)
+	}
+
+	info, err := ParseAddress(address)
+	if err != nil {
+		t.Fatalf("ParseAddress() = %+v, expected <nil>", err)
+	}
+	if info.Protocol != "unix" || info.HostPort() != "/tmp/noise.sock" || info.String() != address {
+		t.Errorf("ParseAddress() = %+v, expected the socket file of %s", info, address)
+	}
+
+	unified, err := ToUnifiedAddress(address)
+	if err != nil || unified != address {
+		t.Errorf("ToUnifiedAddress() = %s, %+v, expected %s", unified, err, address)
+	}
+}
+
 func BenchmarkParseAddress(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, err := ParseAddress("tcp://127.0.0.1:3000")
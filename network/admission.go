@@ -0,0 +1,251 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrShedOverload is the cause of AdmissionErrors for writes shed as the
+	// send queues filled up past what their priority is admitted up to.
+	ErrShedOverload = errors.New("network: write shed due to overload")
+	// ErrPeerDead is the cause of AdmissionErrors for writes shed as their
+	// peer is dead, such as should its circuit be open.
+	ErrPeerDead = errors.New("network: write shed as peer is dead")
+)
+
+// Priority ranks messages written to peers, so that admission control sheds
+// the least important ones first under overload.
+type Priority int
+
+const (
+	// PriorityLow is for messages which may be dropped freely, such as
+	// telemetry.
+	PriorityLow Priority = iota
+	// PriorityNormal is the priority of messages written without one.
+	PriorityNormal
+	// PriorityHigh is for messages which must get through for as long as
+	// possible, such as consensus messages.
+	PriorityHigh
+
+	priorityLevels = int(PriorityHigh) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// clamp returns the priority within the known ones closest to p.
+func (p Priority) clamp() Priority {
+	switch {
+	case p < PriorityLow:
+		return PriorityLow
+	case p > PriorityHigh:
+		return PriorityHigh
+	default:
+		return p
+	}
+}
+
+// WithPriority returns a WriteOption that sets the priority admission control
+// weighs a write by (default: PriorityNormal).
+func WithPriority(priority Priority) WriteOption {
+	return func(o *writeOptions) {
+		o.priority = priority
+	}
+}
+
+// Admission is what admission control decides whether to admit a write on.
+type Admission struct {
+	// Address is the address of the peer written to.
+	Address string
+	// Priority is the priority of the write.
+	Priority Priority
+	// Circuit is the state of the circuit to the peer, being CircuitOpen only
+	// for as long as writes to the peer fail right away.
+	Circuit CircuitState
+	// Occupancy is the number of messages queued across the send queues of
+	// all peers, as a fraction of the capacity set through AdmissionControl.
+	Occupancy float64
+}
+
+// AdmissionPolicy decides whether writes are admitted ahead of the send
+// queues. Policies are consulted on every write, and must not block.
+type AdmissionPolicy interface {
+	// Admit returns nil to admit a write, or either ErrShedOverload or
+	// ErrPeerDead to shed it.
+	Admit(admission Admission) error
+}
+
+// ThresholdPolicy is the default AdmissionPolicy. It sheds writes once the
+// send queues fill up past the threshold of their priority, lowered for peers
+// whose circuit is half-open, and sheds writes to peers whose circuit is open
+// outright.
+type ThresholdPolicy struct {
+	// Thresholds are the occupancies writes are shed at, by priority. Writes
+	// of priorities without a threshold are never shed due to overload.
+	Thresholds map[Priority]float64
+	// Degraded scales thresholds for peers whose circuit is half-open.
+	Degraded float64
+}
+
+// DefaultAdmissionPolicy returns the policy admission control applies unless
+// given another: writes of low, normal and high priority are shed once the
+// send queues are half, 80% and entirely full, and at half of that for peers
+// whose circuit is half-open.
+func DefaultAdmissionPolicy() *ThresholdPolicy {
+	return &ThresholdPolicy{
+		Thresholds: map[Priority]float64{
+			PriorityLow:    0.5,
+			PriorityNormal: 0.8,
+			PriorityHigh:   1,
+		},
+		Degraded: 0.5,
+	}
+}
+
+// Admit implements AdmissionPolicy.
+func (p *ThresholdPolicy) Admit(admission Admission) error {
+	if admission.Circuit == CircuitOpen {
+		return ErrPeerDead
+	}
+
+	threshold, exists := p.Thresholds[admission.Priority]
+	if !exists {
+		return nil
+	}
+	if admission.Circuit == CircuitHalfOpen {
+		threshold *= p.Degraded
+	}
+
+	if admission.Occupancy >= threshold {
+		return ErrShedOverload
+	}
+	return nil
+}
+
+// AdmissionError is returned by writes admission control shed.
+type AdmissionError struct {
+	Admission
+	// Reason is why the write was shed, being either ErrShedOverload or
+	// ErrPeerDead.
+	Reason error
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("%v [priority=%s, occupancy=%.2f, circuit=%s]", e.Reason, e.Priority, e.Occupancy, e.Circuit)
+}
+
+// Cause returns why the write was shed.
+func (e *AdmissionError) Cause() error {
+	return e.Reason
+}
+
+// PriorityAdmissions counts the admission decisions taken on writes of a
+// priority.
+type PriorityAdmissions struct {
+	// Admitted is the number of writes admitted.
+	Admitted uint64
+	// ShedOverload is the number of writes shed with ErrShedOverload.
+	ShedOverload uint64
+	// ShedPeerDead is the number of writes shed with ErrPeerDead.
+	ShedPeerDead uint64
+}
+
+// AdmissionStats describes the send queues, and the admission decisions
+// taken on writes to them.
+type AdmissionStats struct {
+	// Queued is the number of messages queued across the send queues of all
+	// peers.
+	Queued int64
+	// Occupancy is Queued as a fraction of the capacity set through
+	// AdmissionControl, being zero without admission control.
+	Occupancy float64
+	// Priorities counts the admission decisions taken, by priority.
+	Priorities map[Priority]PriorityAdmissions
+}
+
+// admissionCounters count admission decisions by priority.
+type admissionCounters [priorityLevels]struct {
+	admitted     uint64 // for atomic ops
+	shedOverload uint64 // for atomic ops
+	shedPeerDead uint64 // for atomic ops
+}
+
+// admit decides whether to admit a write of a priority to an address ahead
+// of its send queue, should admission control be enabled.
+func (n *Network) admit(address string, priority Priority) error {
+	if n.opts.admissionCapacity <= 0 {
+		return nil
+	}
+
+	admission := Admission{
+		Address:   address,
+		Priority:  priority.clamp(),
+		Circuit:   n.circuitState(address),
+		Occupancy: n.sendOccupancy(),
+	}
+
+	// Circuits whose cooldown passed let a probe through.
+	if admission.Circuit == CircuitOpen && !n.circuitOpen(address) {
+		admission.Circuit = CircuitHalfOpen
+	}
+
+	policy := n.opts.admissionPolicy
+	if policy == nil {
+		policy = DefaultAdmissionPolicy()
+	}
+
+	counters := &n.admissions[admission.Priority]
+
+	switch err := policy.Admit(admission); err {
+	case nil:
+		atomic.AddUint64(&counters.admitted, 1)
+		return nil
+	case ErrPeerDead:
+		atomic.AddUint64(&counters.shedPeerDead, 1)
+		return &AdmissionError{Admission: admission, Reason: err}
+	default:
+		atomic.AddUint64(&counters.shedOverload, 1)
+		return &AdmissionError{Admission: admission, Reason: ErrShedOverload}
+	}
+}
+
+// sendOccupancy returns the number of messages queued across the send queues
+// of all peers as a fraction of the admission capacity.
+func (n *Network) sendOccupancy() float64 {
+	if n.opts.admissionCapacity <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&n.sendsQueued)) / float64(n.opts.admissionCapacity)
+}
+
+// AdmissionStats returns how many messages are queued to be sent, and how
+// many writes were admitted or shed by priority.
+func (n *Network) AdmissionStats() AdmissionStats {
+	stats := AdmissionStats{
+		Queued:     atomic.LoadInt64(&n.sendsQueued),
+		Occupancy:  n.sendOccupancy(),
+		Priorities: make(map[Priority]PriorityAdmissions, priorityLevels),
+	}
+
+	for i := range n.admissions {
+		counters := &n.admissions[i]
+		stats.Priorities[Priority(i)] = PriorityAdmissions{
+			Admitted:     atomic.LoadUint64(&counters.admitted),
+			ShedOverload: atomic.LoadUint64(&counters.shedOverload),
+			ShedPeerDead: atomic.LoadUint64(&counters.shedPeerDead),
+		}
+	}
+
+	return stats
+}
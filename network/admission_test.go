@@ -0,0 +1,97 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := DefaultAdmissionPolicy()
+
+	for _, test := range []struct {
+		admission Admission
+		err       error
+	}{
+		{Admission{Priority: PriorityLow, Occupancy: 0.4}, nil},
+		{Admission{Priority: PriorityLow, Occupancy: 0.5}, ErrShedOverload},
+		{Admission{Priority: PriorityNormal, Occupancy: 0.7}, nil},
+		{Admission{Priority: PriorityNormal, Occupancy: 0.8}, ErrShedOverload},
+		{Admission{Priority: PriorityHigh, Occupancy: 0.99}, nil},
+		{Admission{Priority: PriorityHigh, Occupancy: 1}, ErrShedOverload},
+
+		// Peers recovering from failures are given half as much room.
+		{Admission{Priority: PriorityLow, Circuit: CircuitHalfOpen, Occupancy: 0.25}, ErrShedOverload},
+		{Admission{Priority: PriorityHigh, Circuit: CircuitHalfOpen, Occupancy: 0.4}, nil},
+
+		// Dead peers are written to at no priority.
+		{Admission{Priority: PriorityHigh, Circuit: CircuitOpen}, ErrPeerDead},
+	} {
+		assert.Equal(t, test.err, policy.Admit(test.admission), "%+v", test.admission)
+	}
+}
+
+func TestAdmissionShedsLowPriorityToDeadPeers(t *testing.T) {
+	t.Parallel()
+
+	healthy := buildListeningNode(t)
+	defer healthy.Close()
+	dead := buildListeningNode(t)
+	defer dead.Close()
+
+	sender := buildListeningNode(t, AdmissionControl(10, nil), CircuitBreaker(1, time.Minute, time.Minute))
+	defer sender.Close()
+
+	_, err := sender.Client(healthy.Address)
+	assert.Nil(t, err)
+	_, err = sender.Client(dead.Address)
+	assert.Nil(t, err)
+
+	sender.markWritten(dead.Address, errWriteTimedOut)
+
+	// Hold up writes to the healthy peer, so that messages written to it
+	// back up in its send queue.
+	state, ok := sender.ConnectionState(healthy.Address)
+	assert.True(t, ok)
+	state.writerMutex.Lock()
+
+	write := func(address string, priority Priority) error {
+		_, err := sender.WriteAsync(address, signedTestMessage(t, sender, "queued"), WithPriority(priority))
+		return err
+	}
+
+	// The first message is taken off the queue by the send worker, which
+	// stalls on the writer.
+	assert.Nil(t, write(healthy.Address, PriorityHigh))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.AdmissionStats().Queued == 0 }))
+
+	for i := 0; i < 9; i++ {
+		assert.Nil(t, write(healthy.Address, PriorityHigh))
+	}
+	assert.Equal(t, 0.9, sender.AdmissionStats().Occupancy)
+
+	err = write(dead.Address, PriorityLow)
+	assert.Equal(t, ErrPeerDead, errors.Cause(err))
+	if admissionErr, ok := err.(*AdmissionError); assert.True(t, ok) {
+		assert.Equal(t, CircuitOpen, admissionErr.Circuit)
+		assert.Equal(t, PriorityLow, admissionErr.Priority)
+	}
+
+	assert.Equal(t, ErrShedOverload, errors.Cause(write(healthy.Address, PriorityLow)))
+	assert.Equal(t, ErrShedOverload, errors.Cause(write(healthy.Address, PriorityNormal)))
+	assert.Nil(t, write(healthy.Address, PriorityHigh))
+
+	state.writerMutex.Unlock()
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.AdmissionStats().Queued == 0 }), "queue never drained")
+
+	assert.Equal(t, map[Priority]PriorityAdmissions{
+		PriorityLow:    {ShedOverload: 1, ShedPeerDead: 1},
+		PriorityNormal: {ShedOverload: 1},
+		PriorityHigh:   {Admitted: 11},
+	}, sender.AdmissionStats().Priorities)
+}
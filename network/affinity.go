@@ -0,0 +1,281 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const affinityTokenSize = 16
+
+var affinityRevokedName = proto.MessageName((*protobuf.AffinityRevoked)(nil))
+
+// AffinityStats counts affinity tokens issued to peers which dialed us, and
+// reconnects made using affinity tokens peers issued us.
+type AffinityStats struct {
+	// Issued is the number of affinity tokens issued or renewed.
+	Issued uint64
+	// Echoed is the number of affinity tokens peers echoed back to us, ours
+	// or not.
+	Echoed uint64
+	// Revoked is the number of affinity tokens we revoked.
+	Revoked uint64
+	// Direct is the number of peers reconnected to at the direct address they
+	// preferred.
+	Direct uint64
+	// Fallbacks is the number of peers reconnected to at the address dialed,
+	// as their direct address could not be reached.
+	Fallbacks uint64
+}
+
+// issuedAffinity is an affinity token issued to a peer which dialed us.
+type issuedAffinity struct {
+	publicKey []byte
+	expiry    time.Time
+}
+
+// heldAffinity is an affinity token a peer we dialed issued us, alongside the
+// address it prefers being reconnected to at.
+type heldAffinity struct {
+	token     []byte
+	address   string
+	publicKey []byte
+	expiry    time.Time
+}
+
+// affinityStore keeps track of affinity tokens issued to peers which dialed
+// us, and held for peers we dialed by the address we dialed.
+type affinityStore struct {
+	sync.Mutex
+
+	issued   map[string]*issuedAffinity // token -> affinity
+	held     map[string]*heldAffinity   // address -> affinity
+	draining bool
+
+	issuedCount  uint64 // for atomic ops
+	echoedCount  uint64 // for atomic ops
+	revokedCount uint64 // for atomic ops
+	direct       uint64 // for atomic ops
+	fallbacks    uint64 // for atomic ops
+}
+
+func newAffinityStore() *affinityStore {
+	return &affinityStore{
+		issued: make(map[string]*issuedAffinity),
+		held:   make(map[string]*heldAffinity),
+	}
+}
+
+// AffinityStats returns the number of affinity tokens issued, echoed and
+// revoked, and of reconnects made using them so far.
+func (n *Network) AffinityStats() AffinityStats {
+	return AffinityStats{
+		Issued:    atomic.LoadUint64(&n.affinities.issuedCount),
+		Echoed:    atomic.LoadUint64(&n.affinities.echoedCount),
+		Revoked:   atomic.LoadUint64(&n.affinities.revokedCount),
+		Direct:    atomic.LoadUint64(&n.affinities.direct),
+		Fallbacks: atomic.LoadUint64(&n.affinities.fallbacks),
+	}
+}
+
+// issueAffinity returns the affinity handed to a peer which dialed us, should
+// affinity hints be enabled and us not be draining. A token the peer echoed
+// which we issued it and which has not expired is renewed rather than
+// replaced.
+func (n *Network) issueAffinity(hello *protobuf.Handshake) *protobuf.Affinity {
+	s := n.affinities
+
+	var ours bool
+	if len(hello.AffinityToken) > 0 {
+		atomic.AddUint64(&s.echoedCount, 1)
+
+		s.Lock()
+		issued, exists := s.issued[string(hello.AffinityToken)]
+		ours = exists && bytes.Equal(issued.publicKey, hello.Sender.PublicKey) && time.Now().Before(issued.expiry)
+		s.Unlock()
+
+		if n.opts.onAffinityEchoed != nil {
+			n.opts.onAffinityEchoed(hello.Sender.Address, hello.AffinityToken, ours)
+		}
+	}
+
+	if n.opts.affinityLifetime <= 0 {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.draining {
+		return nil
+	}
+
+	token := hello.AffinityToken
+	if !ours {
+		token = make([]byte, affinityTokenSize)
+		if _, err := rand.Read(token); err != nil {
+			panic(err)
+		}
+	}
+
+	now := time.Now()
+	for t, issued := range s.issued {
+		if now.After(issued.expiry) {
+			delete(s.issued, t)
+		}
+	}
+
+	s.issued[string(token)] = &issuedAffinity{publicKey: hello.Sender.PublicKey, expiry: now.Add(n.opts.affinityLifetime)}
+	atomic.AddUint64(&s.issuedCount, 1)
+
+	address := n.opts.affinityAddress
+	if address == "" {
+		address = n.Address
+	}
+
+	return &protobuf.Affinity{Token: token, Address: address, Lifetime: int64(n.opts.affinityLifetime)}
+}
+
+// RevokeAffinity stops handing out affinity tokens, such as when we are being
+// drained, and tells every connected peer holding one we issued to stop using
+// it, returning the number of tokens revoked. Peers reconnect at the address
+// they first dialed from then on.
+func (n *Network) RevokeAffinity() int {
+	s := n.affinities
+
+	s.Lock()
+	s.draining = true
+	revoked := make(map[string][][]byte)
+	for token, issued := range s.issued {
+		revoked[string(issued.publicKey)] = append(revoked[string(issued.publicKey)], []byte(token))
+	}
+	count := len(s.issued)
+	s.issued = make(map[string]*issuedAffinity)
+	s.Unlock()
+
+	atomic.AddUint64(&s.revokedCount, uint64(count))
+
+	n.eachPeer(func(client *PeerClient) bool {
+		id := client.id()
+		if id == nil {
+			return true
+		}
+
+		tokens, exists := revoked[string(id.PublicKey)]
+		if !exists {
+			return true
+		}
+		delete(revoked, string(id.PublicKey))
+
+		if err := client.Tell(&protobuf.AffinityRevoked{Tokens: tokens}); err != nil {
+			glog.Warningf("failed to revoke the affinity of %s: %v", client.Address, err)
+		}
+		return true
+	})
+
+	return count
+}
+
+// holdAffinity remembers the affinity a peer we dialed at an address handed
+// us, or forgets the one held for the address should the peer have handed us
+// none.
+func (n *Network) holdAffinity(address string, publicKey []byte, affinity *protobuf.Affinity) {
+	s := n.affinities
+
+	s.Lock()
+	defer s.Unlock()
+
+	if affinity == nil || len(affinity.Token) == 0 || affinity.Address == "" || affinity.Lifetime <= 0 {
+		delete(s.held, address)
+		return
+	}
+
+	s.held[address] = &heldAffinity{
+		token:     affinity.Token,
+		address:   affinity.Address,
+		publicKey: publicKey,
+		expiry:    time.Now().Add(time.Duration(affinity.Lifetime)),
+	}
+}
+
+// heldAffinity returns the affinity held for an address, unless it expired.
+func (n *Network) heldAffinity(address string) *heldAffinity {
+	s := n.affinities
+
+	s.Lock()
+	defer s.Unlock()
+
+	held, exists := s.held[address]
+	if !exists {
+		return nil
+	}
+
+	if time.Now().After(held.expiry) {
+		delete(s.held, address)
+		return nil
+	}
+
+	return held
+}
+
+// handleAffinityRevoked forgets the affinity tokens a peer revoked.
+func (n *Network) handleAffinityRevoked(client *PeerClient, name string, payload *types.Any) bool {
+	if name != affinityRevokedName {
+		return false
+	}
+
+	var msg protobuf.AffinityRevoked
+	if err := types.UnmarshalAny(payload, &msg); err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	s := n.affinities
+
+	s.Lock()
+	defer s.Unlock()
+
+	for address, held := range s.held {
+		for _, token := range msg.Tokens {
+			if bytes.Equal(held.token, token) {
+				delete(s.held, address)
+				break
+			}
+		}
+	}
+
+	return true
+}
+
+// dialAffinity dials the direct address a peer at an address prefers being
+// reconnected to at, should we hold an affinity for the address. Connections
+// reaching any other peer than the one which issued the affinity are closed.
+func (n *Network) dialAffinity(address string, run func(conn net.Conn) (*handshakeResult, error)) (net.Conn, *handshakeResult, bool) {
+	held := n.heldAffinity(address)
+	if held == nil || held.address == address {
+		return nil, nil, false
+	}
+
+	conn, result, err := n.dialWith(held.address, run)
+	if err == nil && !bytes.Equal(result.remote.PublicKey, held.publicKey) {
+		conn.Close()
+		err = errors.New("reached another peer than the one which issued the affinity")
+	}
+	if err != nil {
+		glog.Warningf("failed to reconnect to %s at %s, falling back to dialing it: %v", address, held.address, err)
+		atomic.AddUint64(&n.affinities.fallbacks, 1)
+		return nil, nil, false
+	}
+
+	atomic.AddUint64(&n.affinities.direct, 1)
+	return conn, result, true
+}
@@ -0,0 +1,216 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPool forwards every connection to whichever node it is pointed at, as
+// a load balancer in front of a pool of nodes sharing a name would.
+type testPool struct {
+	Address string
+
+	listener net.Listener
+	backend  atomic.Value // host:port (string)
+}
+
+func newTestPool(t *testing.T, backend *Network) *testPool {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, err := ToUnifiedAddress(FormatAddress("tcp", "localhost", uint16(listener.Addr().(*net.TCPAddr).Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := &testPool{Address: address, listener: listener}
+	pool.pointAt(backend)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				upstream, err := net.Dial("tcp", pool.backend.Load().(string))
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return pool
+}
+
+func (p *testPool) pointAt(backend *Network) {
+	info, _ := ParseAddress(backend.Address)
+	p.backend.Store(info.HostPort())
+}
+
+func (p *testPool) Close() {
+	p.listener.Close()
+}
+
+// reachedThrough returns true if the node is connected to the peer it dialed
+// at an address, and the peer holds a public key.
+func reachedThrough(node *Network, address string, publicKey []byte) bool {
+	client, exists := node.peers.Load(address)
+	return exists && client.(*PeerClient).IsOutgoingReady() && bytes.Equal(client.(*PeerClient).publicKey, publicKey)
+}
+
+// disconnectFrom closes the connection a node dialed to an address, and waits
+// until it is gone.
+func disconnectFrom(t *testing.T, node *Network, address string) {
+	if client, exists := node.peers.Load(address); exists {
+		client.(*PeerClient).Close()
+	}
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		_, exists := node.peers.Load(address)
+		return !exists
+	}), "never disconnected from %s", address)
+}
+
+func TestAffinityReconnectsDirectly(t *testing.T) {
+	t.Parallel()
+
+	a := buildListeningNode(t, AffinityHints("", time.Minute))
+	defer a.Close()
+	b := buildListeningNode(t, AffinityHints("", time.Minute))
+	defer b.Close()
+
+	pool := newTestPool(t, a)
+	defer pool.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	_, err := node.Client(pool.Address)
+	assert.Nil(t, err)
+	assert.True(t, reachedThrough(node, pool.Address, a.keys.PublicKey))
+
+	// The pool now sends new connections elsewhere, yet we reconnect to the
+	// node we first reached directly, echoing its token.
+	disconnectFrom(t, node, pool.Address)
+	pool.pointAt(b)
+
+	_, err = node.Client(pool.Address)
+	assert.Nil(t, err)
+	assert.True(t, reachedThrough(node, pool.Address, a.keys.PublicKey))
+
+	assert.Equal(t, AffinityStats{Direct: 1}, node.AffinityStats())
+	assert.Equal(t, AffinityStats{Issued: 2, Echoed: 1}, a.AffinityStats())
+}
+
+func TestAffinityFallsBackToPool(t *testing.T) {
+	t.Parallel()
+
+	a := buildListeningNode(t, AffinityHints("", time.Minute))
+	b := buildListeningNode(t, AffinityHints("", time.Minute))
+	defer b.Close()
+
+	pool := newTestPool(t, a)
+	defer pool.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	_, err := node.Client(pool.Address)
+	assert.Nil(t, err)
+
+	disconnectFrom(t, node, pool.Address)
+
+	// The node we first reached dies, and the pool sends us elsewhere.
+	a.Close()
+	pool.pointAt(b)
+
+	_, err = node.Client(pool.Address)
+	assert.Nil(t, err)
+	assert.True(t, reachedThrough(node, pool.Address, b.keys.PublicKey))
+
+	assert.Equal(t, AffinityStats{Fallbacks: 1}, node.AffinityStats())
+
+	// From then on, we stick to the node we fell back to.
+	held := node.heldAffinity(pool.Address)
+	if assert.NotNil(t, held) {
+		assert.Equal(t, b.Address, held.address)
+	}
+}
+
+func TestRevokedAffinityIsNoLongerUsed(t *testing.T) {
+	t.Parallel()
+
+	echoed := make(chan bool, 4)
+	var received int32
+
+	builder := NewBuilderWithOptions(AffinityHints("", time.Minute), OnAffinityEchoed(func(address string, token []byte, ours bool) {
+		echoed <- ours
+	}))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		atomic.AddInt32(&received, 1)
+	}})
+
+	a, err := builder.Build()
+	assert.Nil(t, err)
+	go a.Listen()
+	<-a.Ready()
+	defer a.Close()
+	b := buildListeningNode(t)
+	defer b.Close()
+
+	pool := newTestPool(t, a)
+	defer pool.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	_, err = node.Client(pool.Address)
+	assert.Nil(t, err)
+
+	// Only peers which wrote to us are told affinity was revoked.
+	assert.Nil(t, node.Write(pool.Address, signedTestMessage(t, node, "hello")))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return atomic.LoadInt32(&received) == 1 }), "node never wrote to us")
+
+	assert.Equal(t, 1, a.RevokeAffinity())
+	assert.True(t, waitUntil(3*time.Second, func() bool { return node.heldAffinity(pool.Address) == nil }), "affinity was never revoked")
+
+	// We reconnect through the pool, without echoing any token, and are
+	// handed none while the node drains.
+	disconnectFrom(t, node, pool.Address)
+	pool.pointAt(b)
+
+	_, err = node.Client(pool.Address)
+	assert.Nil(t, err)
+	assert.True(t, reachedThrough(node, pool.Address, b.keys.PublicKey))
+	assert.Equal(t, AffinityStats{}, node.AffinityStats())
+
+	disconnectFrom(t, node, pool.Address)
+	pool.pointAt(a)
+
+	_, err = node.Client(pool.Address)
+	assert.Nil(t, err)
+	assert.True(t, reachedThrough(node, pool.Address, a.keys.PublicKey))
+	assert.Nil(t, node.heldAffinity(pool.Address))
+
+	assert.Equal(t, AffinityStats{Issued: 1, Revoked: 1}, a.AffinityStats())
+	assert.Empty(t, echoed)
+}
@@ -1,3 +1,7 @@
+// Jittered durations are checked against fixed bounds, which only hold for the
+// sequence math/rand produced before it was seeded at random.
+//go:debug randautoseed=0
+
 package backoff
 
 import (
@@ -65,7 +65,8 @@ func defaultOptions() PluginOption {
 }
 
 var (
-	_ network.PluginInterface = (*Plugin)(nil)
+	_ network.PluginInterface      = (*Plugin)(nil)
+	_ network.BulkDisconnectPlugin = (*Plugin)(nil)
 	// PluginID is used to check existence of the backoff plugin
 	PluginID = (*Plugin)(nil)
 )
@@ -89,12 +90,22 @@ func (p *Plugin) Startup(net *network.Network) {
 
 // PeerDisconnect implements the plugin callback
 func (p *Plugin) PeerDisconnect(client *network.PeerClient) {
-	go p.startBackoff(client.Address)
+	go p.startBackoff(client.Address, p.initialDelay)
+}
+
+// PeersDisconnect implements the bulk plugin callback, spreading reconnects to
+// peers which disconnected during a storm over the network's reconnect smear
+func (p *Plugin) PeersDisconnect(clients []*network.PeerClient) {
+	smear := p.net.ReconnectSmear()
+	for i, client := range clients {
+		go p.startBackoff(client.Address, p.initialDelay+smear*time.Duration(i)/time.Duration(len(clients)))
+	}
 }
 
 // startBackoff uses an exponentially increasing timer to try to reconnect to a given address
-func (p *Plugin) startBackoff(addr string) {
-	time.Sleep(p.initialDelay)
+// after an initial delay
+func (p *Plugin) startBackoff(addr string, delay time.Duration) {
+	time.Sleep(delay)
 
 	if _, exists := p.backoffs.Load(addr); exists {
 		// don't activate if backoff is already active
@@ -1,7 +1,11 @@
 package backoff
 
 import (
+	"context"
 	"flag"
+	"net"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +14,7 @@ import (
 	"github.com/perlin-network/noise/examples/basic/messages"
 	"github.com/perlin-network/noise/network"
 	"github.com/perlin-network/noise/network/discovery"
+	"github.com/perlin-network/noise/network/transport"
 	"github.com/pkg/errors"
 )
 
@@ -134,7 +139,14 @@ func TestPlugin(t *testing.T) {
 	}
 
 	// Wait for all nodes to finish discovering other peers.
-	time.Sleep(1 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, node := range nodes {
+		if err := node.WaitForPeers(ctx, numNodes-1); err != nil {
+			t.Fatal(err)
+		}
+	}
 
 	// chack that broadcasts are working
 	if err := broadcastAndCheck(nodes, plugins); err != nil {
@@ -168,3 +180,199 @@ func TestPlugin(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// pipeTransport connects nodes through in-memory pipes, keyed by port alone.
+type pipeTransport struct {
+	sync.Mutex
+	listeners map[string]chan net.Conn
+}
+
+func (p *pipeTransport) Listen(port int) (net.Listener, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	l := &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+	p.listeners[strconv.Itoa(port)] = l.conns
+	return l, nil
+}
+
+func (p *pipeTransport) Dial(address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	conns, exists := p.listeners[port]
+	p.Unlock()
+
+	if !exists {
+		return nil, errors.Errorf("connection refused: %s", address)
+	}
+
+	client, server := net.Pipe()
+	select {
+	case conns <- server:
+		return client, nil
+	case <-time.After(time.Second):
+		return nil, errors.Errorf("connection refused: %s", address)
+	}
+}
+
+// countingPipes records when dials were made through it once counting.
+type countingPipes struct {
+	*pipeTransport
+
+	mutex    sync.Mutex
+	counting bool
+	dials    []time.Time
+}
+
+func (c *countingPipes) Dial(address string) (net.Conn, error) {
+	c.mutex.Lock()
+	if c.counting {
+		c.dials = append(c.dials, time.Now())
+	}
+	c.mutex.Unlock()
+
+	return c.pipeTransport.Dial(address)
+}
+
+func (c *countingPipes) countDials() {
+	c.mutex.Lock()
+	c.counting = true
+	c.mutex.Unlock()
+}
+
+func (c *countingPipes) dialTimes() []time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Time(nil), c.dials...)
+}
+
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+// TestPluginSmearsStormReconnects disconnects a node from all its peers at
+// once, and checks reconnects to them are spread out over the smear window.
+func TestPluginSmearsStormReconnects(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("skipping backoff plugin test in short mode")
+	}
+
+	const (
+		numPeers = 200
+		smear    = 2 * time.Second
+	)
+
+	pipes := &pipeTransport{listeners: make(map[string]chan net.Conn)}
+
+	counting := &countingPipes{pipeTransport: pipes}
+
+	// Ports are made up, as pipes are never bound to any.
+	port := uint16(10000)
+
+	listen := func(builder *network.Builder, layer transport.Layer) *network.Network {
+		port++
+		builder.SetAddress(network.FormatAddress(protocol, host, port))
+		builder.ClearTransportLayers()
+		builder.RegisterTransportLayer(protocol, layer)
+
+		node, err := builder.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go node.Listen()
+		<-node.Ready()
+		return node
+	}
+
+	degraded := make(chan network.NetworkDegraded, 1)
+
+	builder := network.NewBuilderWithOptions(
+		network.DampDisconnectStorms(10, time.Second, smear),
+		network.OnNetworkDegraded(func(event network.NetworkDegraded) { degraded <- event }),
+	)
+	builder.AddPlugin(New(WithInitialDelay(0)))
+	node := listen(builder, counting)
+	defer node.Close()
+
+	var clients []*network.PeerClient
+	for i := 0; i < numPeers; i++ {
+		peer := listen(network.NewBuilder(), pipes)
+		defer peer.Close()
+
+		client, err := node.Client(peer.Address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+	}
+
+	counting.countDials()
+
+	for _, client := range clients {
+		go client.Close()
+	}
+
+	select {
+	case <-degraded:
+	case <-time.After(time.Second):
+		t.Fatal("storm was not detected")
+	}
+
+	deadline := time.Now().Add(smear + 10*time.Second)
+	for len(counting.dialTimes()) < numPeers && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	dials := counting.dialTimes()
+	if len(dials) < numPeers {
+		t.Fatalf("expected %d peers to be redialed, got %d", numPeers, len(dials))
+	}
+
+	// Dials fall into buckets of a tenth of the smear window; were they made
+	// all at once, they would fall into one or two.
+	first := dials[0]
+	buckets := make(map[int]int)
+	for _, dial := range dials[:numPeers] {
+		if dial.Before(first) {
+			first = dial
+		}
+	}
+	for _, dial := range dials[:numPeers] {
+		buckets[int(dial.Sub(first)/(smear/10))]++
+	}
+
+	if len(buckets) < 8 {
+		t.Fatalf("expected dials to be spread over the smear window, got %v", buckets)
+	}
+	for bucket, count := range buckets {
+		if count > numPeers/4 {
+			t.Fatalf("expected dials to be spread over the smear window, got %d in bucket %d", count, bucket)
+		}
+	}
+}
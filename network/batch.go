@@ -0,0 +1,170 @@
+package network
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// BatchCapability is advertised by nodes which unpack batches of payloads
+// sent under a single envelope.
+const BatchCapability = "noise/batch"
+
+// supportsBatches returns true if the peer at an address advertised that it
+// unpacks batches.
+func (n *Network) supportsBatches(address string) bool {
+	if c, exists := n.peers.Load(address); exists {
+		return c.(*PeerClient).HasCapability(BatchCapability)
+	}
+	return false
+}
+
+// WriteBatch sends a list of messages to a denoted target address under a
+// single signed envelope. Peers which do not support batches are sent each
+// message individually instead.
+func (n *Network) WriteBatch(address string, messages []proto.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if !n.supportsBatches(address) {
+		for _, message := range messages {
+			signed, err := n.PrepareMessage(message)
+			if err != nil {
+				return err
+			}
+			if err := n.Write(address, signed); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batch := &protobuf.Batch{Payloads: make([]*types.Any, 0, len(messages))}
+	for _, message := range messages {
+		if message == nil {
+			return errors.New("network: message is null")
+		}
+
+		payload, err := types.MarshalAny(message)
+		if err != nil {
+			return err
+		}
+		batch.Payloads = append(batch.Payloads, payload)
+	}
+
+	signed, err := n.PrepareMessage(batch)
+	if err != nil {
+		return err
+	}
+
+	return n.Write(address, signed)
+}
+
+// batchable returns true if a message may be packed into a batch. Requests,
+// replies and messages carrying metadata or hints or signed by another node
+// are sent as they are.
+func (n *Network) batchable(message *protobuf.Message) bool {
+	return message.RequestNonce == 0 && !message.ReplyFlag && len(message.Metadata) == 0 && len(message.Hints) == 0 && len(message.Protocol) == 0 &&
+		message.Sender != nil && bytes.Equal(message.Sender.PublicKey, n.keys.PublicKey) &&
+		message.Message.Size() <= n.opts.batchBytes
+}
+
+// fillBatch waits for up to the batch delay for more messages to be queued up
+// behind those already popped off a send queue.
+func (n *Network) fillBatch(q *sendQueue, batch []*SendFuture) []*SendFuture {
+	if n.opts.batchDelay <= 0 || len(batch) >= n.opts.batchMessages {
+		return batch
+	}
+
+	timer := time.NewTimer(n.opts.batchDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-n.kill:
+	}
+
+	return append(batch, q.drain()...)
+}
+
+// writeBatched writes out queued messages, packing runs of small messages
+// into batches, save for legacy peers, which know no batches.
+func (n *Network) writeBatched(address string, q *sendQueue, futures []*SendFuture) {
+	var run []*SendFuture
+	size := 0
+
+	state, ok := n.ConnectionState(address)
+	legacy := ok && state.legacy
+
+	flush := func() {
+		switch len(run) {
+		case 0:
+		case 1:
+			n.writeQueued(address, q, run[0])
+		default:
+			n.writeRun(address, q, run)
+		}
+		run, size = nil, 0
+	}
+
+	for _, f := range futures {
+		if f.prepared != nil || legacy || !n.batchable(f.message) {
+			flush()
+			n.writeQueued(address, q, f)
+			continue
+		}
+
+		payloadSize := f.message.Message.Size()
+		if len(run) == n.opts.batchMessages || size+payloadSize > n.opts.batchBytes {
+			flush()
+		}
+
+		run = append(run, f)
+		size += payloadSize
+	}
+
+	flush()
+}
+
+// writeRun packs a run of queued messages into a single batch, and writes it out.
+func (n *Network) writeRun(address string, q *sendQueue, run []*SendFuture) {
+	batch := &protobuf.Batch{Payloads: make([]*types.Any, 0, len(run))}
+	for _, f := range run {
+		batch.Payloads = append(batch.Payloads, f.message.Message)
+	}
+
+	signed, err := n.PrepareMessage(batch)
+	if err == nil {
+		err = n.write(address, signed, run...)
+	}
+
+	for _, f := range run {
+		q.flow.untrack(f)
+		if err != nil {
+			f.resolve(err)
+		} else {
+			q.written(f)
+		}
+	}
+}
+
+// writeQueued writes out a single queued message.
+func (n *Network) writeQueued(address string, q *sendQueue, f *SendFuture) {
+	if f.prepared != nil {
+		n.writePrepared(address, q, f)
+		return
+	}
+
+	defer q.flow.untrack(f)
+
+	if err := n.write(address, f.message, f); err != nil {
+		f.resolve(err)
+		return
+	}
+	q.written(f)
+}
@@ -0,0 +1,285 @@
+package network
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingPlugin fails to handle test messages reading "error".
+type failingPlugin struct {
+	*Plugin
+}
+
+func (p *failingPlugin) Receive(ctx *PluginContext) error {
+	if msg, ok := ctx.Message().(*testpb.TestMessage); ok && msg.Message == "error" {
+		return errors.New("failed to handle message")
+	}
+	return nil
+}
+
+// testMessages wraps a list of strings into test messages.
+func testMessages(texts ...string) []proto.Message {
+	var messages []proto.Message
+	for _, text := range texts {
+		messages = append(messages, &testpb.TestMessage{Message: text})
+	}
+	return messages
+}
+
+// tailFrames collects the types of all inbound frames a node receives.
+func tailFrames(n *Network) (func() []string, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tail := n.TailMessages(ctx, TailFilter{Directions: []ConnDirection{DirectionInbound}, BufferSize: 1024})
+
+	return func() []string {
+		var types []string
+		for {
+			select {
+			case summary := <-tail.C:
+				types = append(types, summary.Type)
+			case <-time.After(100 * time.Millisecond):
+				return types
+			}
+		}
+	}, cancel
+}
+
+func TestWriteBatchPartialHandlerFailures(t *testing.T) {
+	t.Parallel()
+
+	panics := make(chan *HandlerPanic, 1)
+	received := make(chan string, 8)
+
+	builder := NewBuilderWithOptions(
+		OrderedHandlers(&testpb.TestMessage{}),
+		OnHandlerPanic(func(client *PeerClient, p *HandlerPanic) { panics <- p }),
+	)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(new(failingPlugin))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		msg := ctx.Message().(*testpb.TestMessage)
+		if msg.Message == "panic" {
+			panic("handler blew up")
+		}
+		received <- msg.Message
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	frames, cancel := tailFrames(receiver)
+	defer cancel()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	assert.Nil(t, sender.WriteBatch(receiver.Address, testMessages("a", "error", "panic", "b")))
+
+	for _, expected := range []string{"a", "error", "b"} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, expected, msg)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("never received %q", expected)
+		}
+	}
+
+	select {
+	case p := <-panics:
+		assert.Equal(t, "panic", p.Message.(*testpb.TestMessage).Message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("panic was never reported")
+	}
+
+	assert.Equal(t, []string{proto.MessageName(&protobuf.Batch{})}, frames(), "a batch should arrive as a single verified frame")
+
+	assert.Nil(t, sender.WriteBatch(receiver.Address, testMessages("c")))
+	select {
+	case msg := <-received:
+		assert.Equal(t, "c", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("peer should stay connected after a partially failed batch")
+	}
+}
+
+func TestWriteBatchToPeerWithoutBatches(t *testing.T) {
+	t.Parallel()
+
+	receiverPlugin := new(countingPlugin)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(receiverPlugin)
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	// Pretend to be a node predating batches.
+	receiver.opts.capabilities = nil
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	frames, cancel := tailFrames(receiver)
+	defer cancel()
+
+	sender := buildListeningNode(t, MessageBatching(64, 1<<14, 5*time.Millisecond))
+	defer sender.Close()
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	assert.Nil(t, sender.WriteBatch(receiver.Address, testMessages("a", "b", "c")))
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: "d"})
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := sender.WriteAsync(receiver.Address, message)
+		assert.Nil(t, err)
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return receiverPlugin.received.Load() == 6 }))
+
+	types := frames()
+	assert.Equal(t, 6, len(types))
+	for _, typ := range types {
+		assert.Equal(t, proto.MessageName(&testpb.TestMessage{}), typ)
+	}
+}
+
+func TestWriteAsyncBatching(t *testing.T) {
+	t.Parallel()
+
+	const numWrites = 200
+
+	receiverPlugin := new(countingPlugin)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(receiverPlugin)
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	frames, cancel := tailFrames(receiver)
+	defer cancel()
+
+	sender := buildListeningNode(t, MessageBatching(64, 1<<14, 5*time.Millisecond))
+	defer sender.Close()
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: strings.Repeat("x", 100)})
+	assert.Nil(t, err)
+
+	var futures []*SendFuture
+	for i := 0; i < numWrites; i++ {
+		f, err := sender.WriteAsync(receiver.Address, message)
+		assert.Nil(t, err)
+		futures = append(futures, f)
+	}
+
+	for _, f := range futures {
+		select {
+		case <-f.Done():
+			assert.Nil(t, f.Err())
+		case <-time.After(3 * time.Second):
+			t.Fatal("future was never resolved")
+		}
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return receiverPlugin.received.Load() == numWrites }))
+
+	types := frames()
+	assert.True(t, len(types) < numWrites/10, "expected writes to be batched, got %d frames", len(types))
+	assert.Equal(t, proto.MessageName(&protobuf.Batch{}), types[0])
+}
+
+// benchmarkWrites sends 10k 100-byte messages to a peer per iteration through
+// send, and waits for all of them to be handled.
+func benchmarkWrites(b *testing.B, send func(sender *Network, address string, messages []proto.Message) error) {
+	const numMessages = 10000
+
+	build := func(plugin PluginInterface) *Network {
+		builder := NewBuilder()
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(plugin)
+		node, err := builder.Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+		go node.Listen()
+		<-node.Ready()
+		return node
+	}
+
+	receiverPlugin := new(countingPlugin)
+	receiver := build(receiverPlugin)
+	defer receiver.Close()
+
+	sender := build(new(Plugin))
+	defer sender.Close()
+
+	if _, err := sender.Client(receiver.Address); err != nil {
+		b.Fatal(err)
+	}
+
+	var messages []proto.Message
+	for i := 0; i < numMessages; i++ {
+		messages = append(messages, &testpb.TestMessage{Message: strings.Repeat("x", 100)})
+	}
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		if err := send(sender, receiver.Address, messages); err != nil {
+			b.Fatal(err)
+		}
+		if !waitUntil(time.Minute, func() bool { return receiverPlugin.received.Load() == int32(i*numMessages) }) {
+			b.Fatalf("received %d messages", receiverPlugin.received.Load())
+		}
+	}
+}
+
+func BenchmarkWriteIndividually(b *testing.B) {
+	benchmarkWrites(b, func(sender *Network, address string, messages []proto.Message) error {
+		for _, message := range messages {
+			signed, err := sender.PrepareMessage(message)
+			if err != nil {
+				return err
+			}
+			if err := sender.Write(address, signed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkWriteBatch(b *testing.B) {
+	benchmarkWrites(b, func(sender *Network, address string, messages []proto.Message) error {
+		for i := 0; i < len(messages); i += 100 {
+			if err := sender.WriteBatch(address, messages[i:i+100]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
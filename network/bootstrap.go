@@ -0,0 +1,275 @@
+package network
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultCandidateExploration is the fraction of candidates dialed which
+	// are picked at random from those never dialed before.
+	defaultCandidateExploration = 0.1
+
+	// defaultCandidateWave is the number of candidates dialed at once should
+	// dials be unbounded.
+	defaultCandidateWave = 8
+
+	// candidateRecency is how long after a peer was last connected its
+	// recency is worth half as much.
+	candidateRecency = 24 * time.Hour
+)
+
+// ErrCandidatesExhausted is returned by BootstrapCandidates should every
+// candidate have been dialed before enough peers were connected.
+var ErrCandidatesExhausted = errors.New("network: ran out of candidates to dial")
+
+// CandidateOrder orders candidates to dial, best first, as of now. It may
+// reorder candidates in place.
+type CandidateOrder func(candidates []PeerRecord, now time.Time) []PeerRecord
+
+// BootstrapResult describes how a node bootstrapped off its candidates.
+type BootstrapResult struct {
+	// Dialed is the number of candidates dialed.
+	Dialed int
+	// Connected is the number of candidates dialed successfully.
+	Connected int
+}
+
+// CandidateScore rates a candidate between 0 and 1 by its dial history. Peers
+// connected recently, which were mostly reached and quickly so, score highest,
+// while dials failing in a row divide a peer's score by one more than their
+// number. Peers never dialed score 0.25.
+func CandidateScore(record PeerRecord, now time.Time) float64 {
+	var recency float64
+	if !record.LastConnected.IsZero() {
+		age := now.Sub(record.LastConnected)
+		if age < 0 {
+			age = 0
+		}
+		recency = 1 / (1 + float64(age)/float64(candidateRecency))
+	}
+
+	uptime := 0.5
+	if record.hasHistory() {
+		uptime = float64(record.Connects) / float64(record.Connects+record.Failures)
+	}
+
+	latency := 1 / (1 + record.RTT.Seconds())
+
+	return (recency + uptime) / 2 * latency / float64(1+record.FailureStreak)
+}
+
+// HistoryOrder returns a CandidateOrder ordering candidates by CandidateScore,
+// though every so often picking a candidate never dialed before at random in
+// place of the next best one, so that a fraction of candidates dialed are new
+// ones as set by exploration.
+func HistoryOrder(exploration float64) CandidateOrder {
+	every := 0
+	if exploration > 0 {
+		every = int(math.Round(1 / exploration))
+		if every < 1 {
+			every = 1
+		}
+	}
+
+	return func(candidates []PeerRecord, now time.Time) []PeerRecord {
+		scores := make(map[string]float64, len(candidates))
+		for _, record := range candidates {
+			scores[string(record.PublicKey)] = CandidateScore(record, now)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return scores[string(candidates[i].PublicKey)] > scores[string(candidates[j].PublicKey)]
+		})
+
+		var unknown []int
+		for i := range candidates {
+			if !candidates[i].hasHistory() {
+				unknown = append(unknown, i)
+			}
+		}
+		rand.Shuffle(len(unknown), func(i, j int) { unknown[i], unknown[j] = unknown[j], unknown[i] })
+
+		ordered := make([]PeerRecord, 0, len(candidates))
+		placed := make([]bool, len(candidates))
+		next := 0
+
+		for len(ordered) < len(candidates) {
+			if every > 0 && (len(ordered)+1)%every == 0 {
+				for len(unknown) > 0 && placed[unknown[0]] {
+					unknown = unknown[1:]
+				}
+				if len(unknown) > 0 {
+					placed[unknown[0]] = true
+					ordered = append(ordered, candidates[unknown[0]])
+					unknown = unknown[1:]
+					continue
+				}
+			}
+
+			for placed[next] {
+				next++
+			}
+			placed[next] = true
+			ordered = append(ordered, candidates[next])
+		}
+
+		return ordered
+	}
+}
+
+// recordDial records the outcome of a dial to an address into the history of
+// every candidate reachable at it.
+func (b *addressBook) recordDial(address string, now time.Time, rtt time.Duration, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	for key, record := range b.records {
+		if !containsString(record.Addresses, address) {
+			continue
+		}
+
+		if err != nil {
+			record.Failures++
+			record.FailureStreak++
+		} else {
+			record.Connects++
+			record.FailureStreak = 0
+			record.LastConnected = now
+			record.LastSeen = now
+			record.RTT = rtt
+		}
+
+		b.records[key] = record
+	}
+}
+
+// BootstrapCandidates dials candidates until the node is connected to at least
+// target peers, in waves as large as MaxConcurrentDials allows yet no larger
+// than the number of peers missing. Before every wave, the candidates left are
+// ordered by the network's CandidateOrder by their dial history, which dials
+// failing during the run count toward, and addresses already dialed during
+// the run are skipped. It returns ErrCandidatesExhausted should candidates run
+// out first.
+func (n *Network) BootstrapCandidates(ctx context.Context, target int) (BootstrapResult, error) {
+	var result BootstrapResult
+
+	order := n.opts.candidateOrder
+	if order == nil {
+		order = HistoryOrder(defaultCandidateExploration)
+	}
+
+	wave := n.opts.maxDials
+	if wave <= 0 {
+		wave = defaultCandidateWave
+	}
+
+	dialed := make(map[string]struct{})
+
+	// Connections are recorded some time after dials to them succeed, so
+	// peers dialed successfully are counted as connected right away rather
+	// than dialing more of them than are missing.
+	initial := n.connectedPeers()
+
+	for {
+		connected := n.connectedPeers()
+		if atLeast := initial + result.Connected; atLeast > connected {
+			connected = atLeast
+		}
+
+		missing := target - connected
+		if missing <= 0 {
+			return result, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if n.isClosed() {
+			return result, ErrNetworkClosed
+		}
+
+		candidates := n.pendingCandidates(dialed)
+		if len(candidates) == 0 {
+			return result, errors.Wrapf(ErrCandidatesExhausted, "connected to %d of %d peers", target-missing, target)
+		}
+		candidates = order(candidates, n.now())
+
+		size := wave
+		if size > missing {
+			size = missing
+		}
+		if size > len(candidates) {
+			size = len(candidates)
+		}
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+
+		for _, record := range candidates[:size] {
+			address := record.Addresses[0]
+			dialed[address] = struct{}{}
+			result.Dialed++
+
+			wg.Add(1)
+			n.spawn(func() {
+				defer wg.Done()
+
+				if _, err := n.Client(address); err == nil {
+					mutex.Lock()
+					result.Connected++
+					mutex.Unlock()
+				}
+			})
+		}
+		wg.Wait()
+	}
+}
+
+// pendingCandidates returns the candidates yet to be connected to, each with
+// only the addresses not yet dialed.
+func (n *Network) pendingCandidates(dialed map[string]struct{}) []PeerRecord {
+	var pending []PeerRecord
+
+	for _, record := range n.candidates.list() {
+		var addresses []string
+		connected := false
+
+		for _, address := range record.Addresses {
+			unified, err := ToUnifiedAddress(address)
+			if err != nil {
+				continue
+			}
+			if _, exists := n.peers.Load(unified); exists {
+				connected = true
+				break
+			}
+			if _, exists := dialed[unified]; !exists {
+				addresses = append(addresses, unified)
+			}
+		}
+
+		if !connected && len(addresses) > 0 {
+			record.Addresses = addresses
+			pending = append(pending, record)
+		}
+	}
+
+	return pending
+}
+
+// connectedPeers returns the number of peers connected to.
+func (n *Network) connectedPeers() int {
+	connected := 0
+	n.eachPeer(func(client *PeerClient) bool {
+		if n.ConnectionStateExists(client.Address) {
+			connected++
+		}
+		return true
+	})
+	return connected
+}
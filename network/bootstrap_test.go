@@ -0,0 +1,184 @@
+package network
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// deadAddress returns an address nothing listens at.
+func deadAddress(t *testing.T) string {
+	address, err := ToUnifiedAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// seedCandidates fills a node's address book with records of peers alive and
+// dead, as a node restarting would find it.
+func seedCandidates(t *testing.T, node *Network, alive []*Network, dead []string) {
+	now := time.Now()
+
+	for _, peer := range alive {
+		node.candidates.merge(PeerRecord{
+			PublicKey:     peer.keys.PublicKey,
+			Addresses:     []string{peer.Address},
+			LastSeen:      now.Add(-time.Hour),
+			LastConnected: now.Add(-time.Hour),
+			Connects:      20,
+			Failures:      1,
+			RTT:           5 * time.Millisecond,
+		})
+	}
+
+	for i, address := range dead {
+		record := PeerRecord{
+			PublicKey: ed25519.RandomKeyPair().PublicKey,
+			Addresses: []string{address},
+			LastSeen:  now.Add(-21 * 24 * time.Hour),
+		}
+
+		// Most dead peers were last reached weeks ago, and have failed to be
+		// dialed ever since. The rest were never dialed.
+		if i%4 != 0 {
+			record.LastConnected = record.LastSeen
+			record.Connects = 3
+			record.Failures = 8
+			record.FailureStreak = 8
+		}
+
+		node.candidates.merge(record)
+	}
+}
+
+func TestBootstrapCandidatesPrefersHistory(t *testing.T) {
+	t.Parallel()
+
+	var alive []*Network
+	for i := 0; i < 6; i++ {
+		peer := buildListeningNode(t)
+		defer peer.Close()
+		alive = append(alive, peer)
+	}
+
+	var dead []string
+	for i := 0; i < 32; i++ {
+		dead = append(dead, deadAddress(t))
+	}
+
+	bootstrap := func(opts ...BuilderOption) BootstrapResult {
+		node := buildListeningNode(t, opts...)
+		defer node.Close()
+
+		seedCandidates(t, node, alive, dead)
+
+		result, err := node.BootstrapCandidates(context.Background(), 4)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, result.Connected)
+		assert.True(t, node.connectedPeers() >= 4)
+		return result
+	}
+
+	byHistory := bootstrap(MaxConcurrentDials(4))
+	assert.True(t, byHistory.Dialed <= 6, "dialed %d candidates", byHistory.Dialed)
+
+	// Random order burns through dead candidates before reaching as many
+	// peers, on average.
+	random := CandidateOrdering(func(candidates []PeerRecord, now time.Time) []PeerRecord {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		return candidates
+	})
+
+	const trials = 5
+
+	dialed := 0
+	for i := 0; i < trials; i++ {
+		dialed += bootstrap(MaxConcurrentDials(4), random).Dialed
+	}
+	assert.True(t, float64(dialed)/trials >= 2*float64(byHistory.Dialed), "dialed %.1f candidates in random order against %d", float64(dialed)/trials, byHistory.Dialed)
+}
+
+func TestBootstrapCandidatesDemotesFailures(t *testing.T) {
+	t.Parallel()
+
+	alive := buildListeningNode(t)
+	defer alive.Close()
+
+	// A peer which used to be reached reliably went away since.
+	gone := buildListeningNode(t)
+	goneKey, goneAddress := gone.keys.PublicKey, gone.Address
+	gone.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	seedCandidates(t, node, []*Network{alive}, nil)
+	node.candidates.merge(PeerRecord{
+		PublicKey:     goneKey,
+		Addresses:     []string{goneAddress},
+		LastSeen:      time.Now(),
+		LastConnected: time.Now(),
+		Connects:      50,
+	})
+
+	result, err := node.BootstrapCandidates(context.Background(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, BootstrapResult{Dialed: 2, Connected: 1}, result)
+
+	// The failure is recorded in real time, so that it is ranked below peers
+	// reached since.
+	for _, record := range node.Candidates() {
+		if string(record.PublicKey) == string(goneKey) {
+			assert.Equal(t, uint64(1), record.FailureStreak)
+			assert.Equal(t, uint64(1), record.Failures)
+		} else {
+			assert.Equal(t, uint64(21), record.Connects)
+			assert.Zero(t, record.FailureStreak)
+		}
+	}
+
+	// Candidates failing during a run are not dialed again within it, and
+	// running out of candidates fails.
+	result, err = node.BootstrapCandidates(context.Background(), 2)
+	assert.Equal(t, ErrCandidatesExhausted, errors.Cause(err))
+	assert.Equal(t, BootstrapResult{Dialed: 1}, result)
+}
+
+func TestHistoryOrderExplores(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	var candidates []PeerRecord
+	for i := 0; i < 20; i++ {
+		record := PeerRecord{PublicKey: []byte{byte(i)}}
+		if i < 15 {
+			record.LastConnected = now.Add(-time.Duration(i) * time.Hour)
+			record.Connects = 10
+		}
+		candidates = append(candidates, record)
+	}
+
+	ordered := HistoryOrder(0.2)(candidates, now)
+	assert.Len(t, ordered, 20)
+
+	// Every fifth candidate is one never dialed, the rest being the best
+	// scored first.
+	best := 0
+	for i, record := range ordered {
+		if (i+1)%5 == 0 {
+			assert.False(t, record.hasHistory(), "candidate %d was dialed before", i)
+			continue
+		}
+		if record.hasHistory() {
+			assert.Equal(t, byte(best), record.PublicKey[0])
+			best++
+		}
+	}
+}
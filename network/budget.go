@@ -0,0 +1,209 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReceiveBudgetStats describes how much of the receive memory budget is in use.
+type ReceiveBudgetStats struct {
+	// Capacity is the size of the budget in bytes.
+	Capacity int
+	// InUse is the number of bytes held by received messages yet to be handled.
+	InUse int
+	// Peak is the largest number of bytes ever in use at once.
+	Peak int
+	// Throttled is the total time reads spent paused waiting for the budget.
+	Throttled time.Duration
+}
+
+// receiveBudget bounds the memory held by received messages across all peers.
+// Reads block until enough of the budget is free rather than failing.
+type receiveBudget struct {
+	sync.Mutex
+	cond *sync.Cond
+
+	capacity  int
+	inUse     int
+	peak      int
+	throttled time.Duration
+	closed    bool
+
+	watermark   int
+	above       bool
+	onWatermark func(stats ReceiveBudgetStats, above bool)
+}
+
+func newReceiveBudget(o options) *receiveBudget {
+	b := &receiveBudget{
+		capacity:    o.receiveMemoryBudget,
+		watermark:   int(float64(o.receiveMemoryBudget) * o.receiveWatermark),
+		onWatermark: o.onReceiveWatermark,
+	}
+	b.cond = sync.NewCond(b)
+	return b
+}
+
+// reserve blocks until size bytes of the budget are free, and reserves them.
+// Messages larger than the whole budget wait for all of it. It returns the
+// number of bytes reserved, which must be handed back to release.
+func (b *receiveBudget) reserve(size int) int {
+	if b.capacity <= 0 {
+		return 0
+	}
+
+	if size > b.capacity {
+		size = b.capacity
+	}
+
+	b.Lock()
+
+	if b.inUse+size > b.capacity && !b.closed {
+		start := time.Now()
+		for b.inUse+size > b.capacity && !b.closed {
+			b.cond.Wait()
+		}
+		b.throttled += time.Since(start)
+	}
+
+	if b.closed {
+		b.Unlock()
+		return 0
+	}
+
+	b.inUse += size
+	if b.inUse > b.peak {
+		b.peak = b.inUse
+	}
+
+	notify := b.crossed()
+	stats := b.statsLocked()
+
+	b.Unlock()
+
+	if notify {
+		b.onWatermark(stats, true)
+	}
+
+	return size
+}
+
+// tryReserve reserves size bytes of the budget should they be free, rather
+// than waiting for them. It returns the number of bytes reserved, which must
+// be handed back to release, and false should they not be free.
+func (b *receiveBudget) tryReserve(size int) (int, bool) {
+	if b.capacity <= 0 {
+		return 0, true
+	}
+
+	b.Lock()
+
+	if b.closed || b.inUse+size > b.capacity {
+		b.Unlock()
+		return 0, false
+	}
+
+	b.inUse += size
+	if b.inUse > b.peak {
+		b.peak = b.inUse
+	}
+
+	notify := b.crossed()
+	stats := b.statsLocked()
+
+	b.Unlock()
+
+	if notify {
+		b.onWatermark(stats, true)
+	}
+
+	return size, true
+}
+
+// release hands reserved bytes back to the budget, waking up paused reads.
+func (b *receiveBudget) release(size int) {
+	if size == 0 {
+		return
+	}
+
+	b.Lock()
+
+	b.inUse -= size
+	b.cond.Broadcast()
+
+	notify := b.crossed()
+	stats := b.statsLocked()
+
+	b.Unlock()
+
+	if notify {
+		b.onWatermark(stats, false)
+	}
+}
+
+// crossed returns true if the bytes in use just crossed the watermark in
+// either direction.
+func (b *receiveBudget) crossed() bool {
+	if b.onWatermark == nil || b.watermark <= 0 {
+		return false
+	}
+
+	above := b.inUse >= b.watermark
+	if above == b.above {
+		return false
+	}
+
+	b.above = above
+	return true
+}
+
+// close lets all paused reads carry on without reserving memory, so that
+// they notice the network shutting down.
+func (b *receiveBudget) close() {
+	b.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.Unlock()
+}
+
+func (b *receiveBudget) stats() ReceiveBudgetStats {
+	b.Lock()
+	defer b.Unlock()
+	return b.statsLocked()
+}
+
+func (b *receiveBudget) statsLocked() ReceiveBudgetStats {
+	return ReceiveBudgetStats{
+		Capacity:  b.capacity,
+		InUse:     b.inUse,
+		Peak:      b.peak,
+		Throttled: b.throttled,
+	}
+}
+
+// ReceiveBudgetStats returns how much of the receive memory budget is in use,
+// and how long reads were throttled for so far.
+func (n *Network) ReceiveBudgetStats() ReceiveBudgetStats {
+	return n.budget.stats()
+}
+
+// hold keeps the memory reserved for a received message until a matching
+// call to done.
+func (m *receivedMessage) hold() {
+	m.own.check()
+	atomic.AddInt32(&m.refs, 1)
+}
+
+// done releases the memory reserved for a received message once it is no
+// longer held by the receive path nor any handler.
+func (m *receivedMessage) done() {
+	switch refs := atomic.AddInt32(&m.refs, -1); {
+	case refs == 0:
+		m.own.release()
+		m.budget.release(m.reserved)
+	case refs < 0:
+		// Released once too often, which only panics while auditing.
+		m.own.release()
+	}
+}
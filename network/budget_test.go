@@ -0,0 +1,108 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestReceiveBudgetBoundsMemory(t *testing.T) {
+	t.Parallel()
+
+	const budget, numSenders, numMessages = 64 * 1024, 4, 10
+
+	var received atomic.Int32
+	var watermarks []bool
+	var watermarksMutex sync.Mutex
+
+	receiver, idle, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		// Handle messages slower than they arrive, so that memory piles up.
+		time.Sleep(10 * time.Millisecond)
+		received.Inc()
+	},
+		ReceiveMemoryBudget(budget),
+		OnReceiveWatermark(0.5, func(stats ReceiveBudgetStats, above bool) {
+			watermarksMutex.Lock()
+			watermarks = append(watermarks, above)
+			watermarksMutex.Unlock()
+		}),
+	)
+	defer receiver.Close()
+	defer idle.Close()
+
+	large := strings.Repeat("x", 30*1024)
+
+	var wg sync.WaitGroup
+	for i := 0; i < numSenders; i++ {
+		sender := buildListeningNode(t)
+		defer sender.Close()
+
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// One sender batches its messages, to account for batches too.
+			if i == 0 {
+				var batch []proto.Message
+				for j := 0; j < numMessages; j++ {
+					batch = append(batch, &testpb.TestMessage{Message: large[:3*1024]})
+				}
+				assert.Nil(t, sender.WriteBatch(receiver.Address, batch))
+				return
+			}
+
+			for j := 0; j < numMessages; j++ {
+				assert.Nil(t, client.Tell(&testpb.TestMessage{Message: large}))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.True(t, waitUntil(20*time.Second, func() bool { return received.Load() == numSenders*numMessages }),
+		"received %d messages", received.Load())
+	assert.True(t, waitUntil(3*time.Second, func() bool { return receiver.ReceiveBudgetStats().InUse == 0 }),
+		"all reserved memory should be handed back")
+
+	stats := receiver.ReceiveBudgetStats()
+	assert.Equal(t, budget, stats.Capacity)
+	assert.True(t, stats.Peak <= budget, "peak of %d bytes exceeds the budget", stats.Peak)
+	assert.True(t, stats.Peak > budget/2)
+	assert.True(t, stats.Throttled > 0, "reads should have been throttled")
+
+	watermarksMutex.Lock()
+	defer watermarksMutex.Unlock()
+	assert.True(t, len(watermarks) >= 2)
+	assert.True(t, watermarks[0])
+	assert.False(t, watermarks[len(watermarks)-1])
+}
+
+func TestReceiveBudgetOversizedMessage(t *testing.T) {
+	budget := newReceiveBudget(options{receiveMemoryBudget: 100})
+
+	reserved := budget.reserve(1000)
+	assert.Equal(t, 100, reserved, "messages larger than the budget should wait for all of it")
+
+	done := make(chan int)
+	go func() { done <- budget.reserve(10) }()
+
+	select {
+	case <-done:
+		t.Fatal("reservation should block while the budget is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	budget.release(reserved)
+	assert.Equal(t, 10, <-done)
+
+	budget.close()
+	assert.Equal(t, 0, budget.reserve(100), "reservations should not block once closed")
+}
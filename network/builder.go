@@ -1,7 +1,10 @@
 package network
 
 import (
+	"math"
+	"os"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,111 +13,1472 @@ import (
 	"github.com/perlin-network/noise/crypto/ed25519"
 	"github.com/perlin-network/noise/network/transport"
 	"github.com/perlin-network/noise/peer"
+
+	"github.com/gogo/protobuf/proto"
 	"github.com/pkg/errors"
 )
 
-const (
-	defaultAddress = "tcp://localhost:8588"
-)
+const (
+	defaultAddress = "tcp://localhost:8588"
+)
+
+var (
+	// ErrStrDuplicatePlugin returns if the plugin has already been registered
+	// with the builder
+	ErrStrDuplicatePlugin = "builder: plugin %s is already registered"
+	// ErrStrNoAddress returns if no address was given to the builder
+	ErrStrNoAddress = "builder: network requires public server IP for peers to connect to"
+	// ErrStrNoKeyPair returns if no keypair was given to the builder
+	ErrStrNoKeyPair = "builder: cryptography keys not provided to Network; cannot create node ID"
+	// ErrStrKeyPairMismatch returns if the keypair given to the builder was not generated under the signature policy
+	ErrStrKeyPairMismatch = "builder: cryptography keys do not fit the signature policy; generate them under it"
+)
+
+// Builder is a Address->processors struct
+type Builder struct {
+	opts options
+
+	keys    *crypto.KeyPair
+	address string
+
+	plugins     *PluginList
+	pluginCount int
+
+	// Plugins handling messages sent under a protocol tag, by tag.
+	protocols map[string]*PluginList
+
+	outboundHooks []outboundHook
+	frameFilters  []FrameFilter
+
+	handshakeExtensions []*handshakeExtension
+
+	transports *sync.Map
+}
+
+var defaultBuilderOptions = options{
+	connectionTimeout: defaultConnectionTimeout,
+	signaturePolicy:   ed25519.New(),
+	hashPolicy:        blake2b.New(),
+	recvWindowSize:    defaultReceiveWindowSize,
+	sendWindowSize:    defaultSendWindowSize,
+	writeBufferSize:   defaultWriteBufferSize,
+	writeFlushLatency: defaultWriteFlushLatency,
+	writeTimeout:      defaultWriteTimeout,
+	handshakeTimeout:  defaultHandshakeTimeout,
+	protocolVersions:  []string{DefaultProtocolVersion},
+
+	receiveMemoryBudget: defaultReceiveMemoryBudget,
+	maxFrameSize:        defaultMaxFrameSize,
+
+	chunkSize:       defaultChunkSize,
+	maxTransferSize: defaultMaxTransferSize,
+	transferTimeout: defaultTransferTimeout,
+
+	verificationCacheSize: defaultVerificationCacheSize,
+	verificationCacheTTL:  defaultVerificationCacheTTL,
+
+	pingRate:  defaultPingRate,
+	pingBurst: defaultPingBurst,
+
+	reachabilityTimeout: defaultReachabilityTimeout,
+
+	peerSamplingInterval: defaultPeerSamplingInterval,
+	peerSamplingHealing:  defaultPeerSamplingHealing,
+	peerSamplingSwap:     defaultPeerSamplingSwap,
+
+	idempotencyWindow: defaultIdempotencyWindow,
+	idempotencySize:   defaultIdempotencySize,
+
+	statsInterval:  defaultStatsInterval,
+	statsRetention: defaultStatsRetention,
+
+	executor: goroutineExecutor{},
+
+	peerBundleMaxAge: defaultPeerBundleMaxAge,
+
+	deadlineCeiling: defaultDeadlineCeiling,
+
+	maxPendingRequests: defaultMaxPendingRequests,
+
+	peerSessionLimit: defaultPeerSessionLimit,
+
+	puzzleDifficulty:    defaultPuzzleDifficulty,
+	puzzleMaxDifficulty: defaultMaxPuzzleDifficulty,
+
+	eventReplaySize: defaultEventReplaySize,
+
+	replayHorizon: defaultReplayHorizon,
+
+	ratchetRecords:  defaultRatchetRecords,
+	ratchetInterval: defaultRatchetInterval,
+
+	compactionBudget: defaultCompactionBudget,
+
+	defaultHandlerResult: HandlerResult{Outcome: HandlerIgnore},
+	handlerPanicResult:   HandlerResult{Outcome: HandlerPenalize, Weight: 1},
+}
+
+// A BuilderOption sets options such as connection timeout and cryptographic // policies for the network
+type BuilderOption func(*options)
+
+// ConnectionTimeout returns a NetworkOption that sets the timeout for
+// establishing new connections (default: 60 seconds).
+func ConnectionTimeout(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.connectionTimeout = d
+	}
+}
+
+// SignaturePolicy returns a BuilderOption that sets the signature policy
+// for the network (default: ed25519).
+func SignaturePolicy(policy crypto.SignaturePolicy) BuilderOption {
+	return func(o *options) {
+		o.signaturePolicy = policy
+	}
+}
+
+// SignatureTransition returns a BuilderOption that migrates to a new
+// signature scheme. Messages are signed under both the primary scheme and the
+// new one, and are accepted if a signature under either verifies. Signatures
+// under the new scheme are trusted once a peer demonstrated its public key
+// under it alongside a verified primary signature. Once the transition window
+// ends at until, StrictSignatures rejects messages only signed under the
+// primary scheme (default: disabled).
+func SignatureTransition(scheme SignatureScheme, until time.Time) BuilderOption {
+	return func(o *options) {
+		o.signatureSchemes = append(o.signatureSchemes, scheme)
+		o.signatureTransitionEnd = until
+	}
+}
+
+// StrictSignatures returns a BuilderOption that rejects messages only signed
+// under the primary signature scheme once the window set by
+// SignatureTransition ends (default: false).
+func StrictSignatures() BuilderOption {
+	return func(o *options) {
+		o.strictSignatures = true
+	}
+}
+
+// HashPolicy returns a BuilderOption that sets the hash policy for the network
+// (default: blake2b).
+func HashPolicy(policy crypto.HashPolicy) BuilderOption {
+	return func(o *options) {
+		o.hashPolicy = policy
+	}
+}
+
+// RecvWindowSize returns a BuilderOption that sets the receive buffer window
+// size (default: 4096).
+func RecvWindowSize(recvWindowSize int) BuilderOption {
+	return func(o *options) {
+		o.recvWindowSize = recvWindowSize
+	}
+}
+
+// SendWindowSize returns a BuilderOption that sets the send buffer window
+// size, being the number of messages to a single peer written through
+// WriteAsync which may be pending at once (default: 4096).
+func SendWindowSize(sendWindowSize int) BuilderOption {
+	return func(o *options) {
+		o.sendWindowSize = sendWindowSize
+	}
+}
+
+// WriteBufferSize returns a BuilderOption that sets the write buffer size
+// (default: 4096 bytes).
+func WriteBufferSize(byteSize int) BuilderOption {
+	return func(o *options) {
+		o.writeBufferSize = byteSize
+	}
+}
+
+// WriteFlushLatency returns a BuilderOption that sets the write flush interval
+// (default: 50ms).
+func WriteFlushLatency(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.writeFlushLatency = d
+	}
+}
+
+// WriteTimeout returns a BuilderOption that sets the write timeout
+// (default: 4096).
+func WriteTimeout(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// AdaptiveWriteTimeout returns a BuilderOption that adapts the deadline of
+// each write to the throughput observed writing to the peer: base plus the
+// time the message's size takes at that throughput, bounded by floor and
+// ceiling. A ceiling of zero leaves deadlines unbounded. Until enough writes
+// to a peer were observed, or after it went idle for long, the write timeout
+// applies (default: disabled).
+func AdaptiveWriteTimeout(base, floor, ceiling time.Duration) BuilderOption {
+	return func(o *options) {
+		o.adaptiveWrites = true
+		o.adaptiveWriteBase = base
+		o.adaptiveWriteFloor = floor
+		o.adaptiveWriteCeiling = ceiling
+	}
+}
+
+// ReadIdleTimeout returns a BuilderOption that sets how long a connection may
+// go without the header of a new message arriving before it is closed
+// (default: 0, waiting indefinitely).
+func ReadIdleTimeout(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.readIdleTimeout = d
+	}
+}
+
+// ReadBodyTimeout returns a BuilderOption that sets the deadline for reading
+// the body of a message once its header arrived: base plus the time the
+// message's size takes at minRate bytes per second, so that peers trickling
+// bodies in are cut off without waiting on the idle timeout. A minRate of 0
+// does not scale deadlines by size, and a base of 0 disables the deadline
+// (default: disabled).
+func ReadBodyTimeout(base time.Duration, minRate int) BuilderOption {
+	return func(o *options) {
+		o.readBodyTimeout = base
+		o.readBodyMinRate = minRate
+	}
+}
+
+// MaxPeers returns a BuilderOption that caps the total number of connected
+// peers. Inbound and outbound quotas not set explicitly are derived from it
+// (default: 0, unlimited).
+func MaxPeers(n int) BuilderOption {
+	return func(o *options) {
+		o.maxPeers = n
+	}
+}
+
+// MaxInboundPeers returns a BuilderOption that sets the number of slots for
+// peers that dial us (default: derived from MaxPeers).
+func MaxInboundPeers(n int) BuilderOption {
+	return func(o *options) {
+		o.maxInboundPeers = n
+	}
+}
+
+// MaxOutboundPeers returns a BuilderOption that sets the number of slots for
+// peers we dial (default: derived from MaxPeers).
+func MaxOutboundPeers(n int) BuilderOption {
+	return func(o *options) {
+		o.maxOutboundPeers = n
+	}
+}
+
+// ReservedPeers returns a BuilderOption that sets the number of extra slots
+// usable only by pinned peers and DHT lookups once the inbound or outbound
+// quota is exhausted (default: a tenth of MaxPeers).
+func ReservedPeers(n int) BuilderOption {
+	return func(o *options) {
+		o.reservedPeers = n
+	}
+}
+
+// PinnedPeers returns a BuilderOption that marks a set of peer addresses as
+// static peers which may occupy reserved slots.
+func PinnedPeers(addresses ...string) BuilderOption {
+	return func(o *options) {
+		o.pinnedPeers = append(o.pinnedPeers, addresses...)
+	}
+}
+
+// MaxConcurrentDials returns a BuilderOption that bounds how many peers may
+// be dialed at once. Further dials wait for one in progress to complete
+// (default: 0, unbounded).
+func MaxConcurrentDials(n int) BuilderOption {
+	return func(o *options) {
+		o.maxDials = n
+	}
+}
+
+// WarmUpPeers returns a BuilderOption that sets peers to dial and handshake
+// with in the background once the network starts listening. See Network.Warm.
+func WarmUpPeers(addresses ...string) BuilderOption {
+	return func(o *options) {
+		o.warmUpPeers = append(o.warmUpPeers, addresses...)
+	}
+}
+
+// OnDialFailed returns a BuilderOption that registers a callback invoked
+// whenever connecting to a peer fails, including peers being warmed up.
+func OnDialFailed(fn func(address string, err error)) BuilderOption {
+	return func(o *options) {
+		o.onDialFailed = fn
+	}
+}
+
+// CandidateOrdering returns a BuilderOption that sets the order in which
+// BootstrapCandidates dials candidates (default: HistoryOrder with a tenth of
+// candidates dialed being new ones).
+func CandidateOrdering(order CandidateOrder) BuilderOption {
+	return func(o *options) {
+		o.candidateOrder = order
+	}
+}
+
+// AdoptListener returns a BuilderOption that has Listen accept peers over a
+// listener inherited from another process, such as one handed over through
+// ListenerFile, rather than binding one of its own. The listener must be a TCP
+// listener bound to the port of the network's address. The file is closed once
+// adopted.
+func AdoptListener(file *os.File) BuilderOption {
+	return func(o *options) {
+		o.listenerFile = file
+	}
+}
+
+// PeerMetadata returns a BuilderOption that registers a provider of metadata,
+// such as a node's role, presented to peers during every handshake and
+// covered by its signature. The metadata may be at most 4KB in total.
+func PeerMetadata(fn func() map[string][]byte) BuilderOption {
+	return func(o *options) {
+		o.peerMetadata = fn
+	}
+}
+
+// ValidatePeerMetadata returns a BuilderOption that registers a hook invoked
+// with the metadata every peer presents during its handshake, before the peer
+// is admitted. Should it return an error, the connection is closed. Peers
+// which present no metadata are validated against nil metadata.
+func ValidatePeerMetadata(fn func(info PeerInfo, metadata map[string][]byte) error) BuilderOption {
+	return func(o *options) {
+		o.validatePeerMetadata = fn
+	}
+}
+
+// VerifyAddresses returns a BuilderOption that dials peers back at the
+// addresses they advertise, and only considers an address verified once the
+// peer proves possession of its public key there. Plugins should not relay
+// unverified addresses to other peers. At most one dial-back is made per
+// interval (default: disabled).
+func VerifyAddresses(interval time.Duration) BuilderOption {
+	return func(o *options) {
+		o.verifyAddresses = true
+		o.verifyAddressInterval = interval
+	}
+}
+
+// PinnedPeerIDs returns a BuilderOption that marks a set of peers as static
+// peers which may occupy reserved slots, regardless of their address. Such
+// peers are only recognized once they complete their handshake.
+func PinnedPeerIDs(ids ...PeerID) BuilderOption {
+	return func(o *options) {
+		o.pinnedPeerIDs = append(o.pinnedPeerIDs, ids...)
+	}
+}
+
+// WriteReadinessPolicy returns a BuilderOption that decides what happens to
+// writes issued before the network is ready (default: QueueUntilReady).
+func WriteReadinessPolicy(policy ReadinessPolicy) BuilderOption {
+	return func(o *options) {
+		o.readinessPolicy = policy
+	}
+}
+
+// DialOnWrite returns a BuilderOption that decides what happens to writes to
+// addresses we are not connected to (default: DialNever). The policy may be
+// overridden for a single write through WriteContext.
+func DialOnWrite(policy DialPolicy) BuilderOption {
+	return func(o *options) {
+		o.dialOnWrite = policy
+	}
+}
+
+// HandshakeTimeout returns a BuilderOption that sets the deadline for a new
+// connection to complete its handshake before it is closed (default: 5 seconds).
+func HandshakeTimeout(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.handshakeTimeout = d
+	}
+}
+
+// ProtocolVersions returns a BuilderOption that sets the protocol versions
+// offered during handshakes, most preferred first (default: noise/1).
+func ProtocolVersions(versions ...string) BuilderOption {
+	return func(o *options) {
+		o.protocolVersions = versions
+	}
+}
+
+// Capabilities returns a BuilderOption that sets the capabilities advertised
+// during handshakes.
+func Capabilities(capabilities ...string) BuilderOption {
+	return func(o *options) {
+		o.capabilities = capabilities
+	}
+}
+
+// MessageBatching returns a BuilderOption that packs up to maxMessages
+// messages written through WriteAsync, totalling at most maxBytes, into a
+// single signed envelope for peers which support batches. The send worker
+// waits up to delay for a batch to fill up (default: disabled).
+func MessageBatching(maxMessages int, maxBytes int, delay time.Duration) BuilderOption {
+	return func(o *options) {
+		o.batchMessages = maxMessages
+		o.batchBytes = maxBytes
+		o.batchDelay = delay
+	}
+}
+
+// ReceiveMemoryBudget returns a BuilderOption that bounds the memory held by
+// received messages yet to be handled across all peers. Reading off of peers
+// pauses while the budget is exhausted (default: 32 times the 4MB maximum
+// message size; 0 disables the budget).
+func ReceiveMemoryBudget(bytes int) BuilderOption {
+	return func(o *options) {
+		o.receiveMemoryBudget = bytes
+	}
+}
+
+// OnReceiveWatermark returns a BuilderOption that registers a callback invoked
+// whenever the memory held by received messages rises above, or falls back
+// below, a fraction of the receive memory budget. The callback must not block.
+func OnReceiveWatermark(watermark float64, fn func(stats ReceiveBudgetStats, above bool)) BuilderOption {
+	return func(o *options) {
+		o.receiveWatermark = watermark
+		o.onReceiveWatermark = fn
+	}
+}
+
+// OnSendWatermark returns a BuilderOption that registers a callback invoked
+// whenever the bytes queued for a peer, as reported by PeerSendBudget, rise to
+// at least high bytes, and once they then fall back to at most low bytes. The
+// callback is told whether sending to the peer is congested, and must not
+// block.
+func OnSendWatermark(high, low int, fn func(address string, congested bool)) BuilderOption {
+	return func(o *options) {
+		o.sendHighWatermark = high
+		o.sendLowWatermark = low
+		o.onSendWatermark = fn
+	}
+}
+
+// Roaming returns a BuilderOption that decides what happens when a known peer
+// connects from a new address (default: RoamingDisabled).
+func Roaming(policy RoamingPolicy) BuilderOption {
+	return func(o *options) {
+		o.roamingPolicy = policy
+	}
+}
+
+// SessionResumption returns a BuilderOption that lets peers which reconnect
+// within lifetime of disconnecting resume their session without a full
+// handshake, carrying on with the same sequence numbers (default: 0, disabled).
+func SessionResumption(lifetime time.Duration) BuilderOption {
+	return func(o *options) {
+		o.sessionLifetime = lifetime
+	}
+}
+
+// PeerSessionLimit returns a BuilderOption that bounds how many bytes the
+// values held in a peer's PeerSession may take up, as estimated (default:
+// 64KB). A limit of zero leaves sessions unbounded.
+func PeerSessionLimit(limit int) BuilderOption {
+	return func(o *options) {
+		o.peerSessionLimit = limit
+	}
+}
+
+// SessionCarryOver returns a BuilderOption that retains the values of a
+// peer's PeerSession fn picks as the peer disconnects, by the name of their
+// key, for as long as its session may be resumed. Sessions which are resumed
+// start out holding them. It only takes effect with SessionResumption.
+func SessionCarryOver(fn func(client *PeerClient, name string, value interface{}) bool) BuilderOption {
+	return func(o *options) {
+		o.sessionCarryOver = fn
+	}
+}
+
+// AffinityHints returns a BuilderOption that hands peers which dial us an
+// affinity token alongside a direct address to reconnect to us at, valid for
+// lifetime (default: 0, disabled). Peers dialing a name shared by a pool of
+// nodes thus reconnect to the same node, falling back to the name should the
+// direct address be unreachable. The direct address defaults to our own.
+func AffinityHints(address string, lifetime time.Duration) BuilderOption {
+	return func(o *options) {
+		o.affinityAddress = address
+		o.affinityLifetime = lifetime
+	}
+}
+
+// OnAffinityEchoed returns a BuilderOption that registers a callback invoked
+// whenever a peer dialing us echoes an affinity token, ours or not, such that
+// pools forwarding connections themselves may route the peer to the node
+// which issued the token.
+func OnAffinityEchoed(fn func(address string, token []byte, ours bool)) BuilderOption {
+	return func(o *options) {
+		o.onAffinityEchoed = fn
+	}
+}
+
+// QuarantinePeriod returns a BuilderOption that puts newly connected peers
+// other than pinned peers under quarantine for a given duration, during which
+// they are left out of broadcast fanout and routing tables (default: 0, disabled).
+func QuarantinePeriod(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.quarantinePeriod = d
+	}
+}
+
+// QuarantineMessages returns a BuilderOption that keeps newly connected peers
+// under quarantine until they have sent a given number of messages (default:
+// 0, disabled).
+func QuarantineMessages(count int) BuilderOption {
+	return func(o *options) {
+		o.quarantineMessages = count
+	}
+}
+
+// OnPeerGraduated returns a BuilderOption that registers a callback invoked
+// whenever a peer graduates from quarantine.
+func OnPeerGraduated(fn func(client *PeerClient)) BuilderOption {
+	return func(o *options) {
+		o.onPeerGraduated = fn
+	}
+}
+
+// HandlerConcurrency returns a BuilderOption that bounds how many messages of
+// the same type as message may be handled by plugins at once (default: unbounded).
+func HandlerConcurrency(message proto.Message, limit int) BuilderOption {
+	return func(o *options) {
+		if o.handlerConcurrency == nil {
+			o.handlerConcurrency = make(map[string]int)
+		}
+		o.handlerConcurrency[proto.MessageName(message)] = limit
+	}
+}
+
+// DedupeWindow returns a BuilderOption that drops messages of the same type as
+// message whose payload was delivered within window, remembering the hashes of
+// up to size payloads under the network's HashPolicy (default: disabled).
+// Only the first occurrence of a payload is handed to plugins, whichever peer
+// it came from. Messages of the type under a protocol tag are deduplicated
+// apart, by their name qualified with the tag.
+func DedupeWindow(message proto.Message, window time.Duration, size int) BuilderOption {
+	return func(o *options) {
+		if o.dedupeWindows == nil {
+			o.dedupeWindows = make(map[string]dedupeWindow)
+		}
+		o.dedupeWindows[proto.MessageName(message)] = dedupeWindow{window: window, size: size}
+	}
+}
+
+// ProtocolConcurrency returns a BuilderOption that bounds how many messages
+// sent under a protocol tag may be handled by plugins at once, regardless of
+// their type (default: unbounded). Messages of a single type under a tag are
+// bounded through HandlerConcurrency by their name qualified with the tag, as
+// returned by ProtocolMessageName.
+func ProtocolConcurrency(tag string, limit int) BuilderOption {
+	return func(o *options) {
+		if o.protocolConcurrency == nil {
+			o.protocolConcurrency = make(map[string]int)
+		}
+		o.protocolConcurrency[tag] = limit
+	}
+}
+
+// OrderedHandlers returns a BuilderOption that marks message types whose
+// messages from a single peer must be handled one at a time, in the order they
+// were sent. Other message types are never queued behind them. It has no
+// effect alongside DispatchWorkers, which orders messages by their ordering key.
+func OrderedHandlers(messages ...proto.Message) BuilderOption {
+	return func(o *options) {
+		if o.orderedHandlers == nil {
+			o.orderedHandlers = make(map[string]struct{})
+		}
+		for _, message := range messages {
+			o.orderedHandlers[proto.MessageName(message)] = struct{}{}
+		}
+	}
+}
+
+// DispatchWorkers returns a BuilderOption that hands received messages over
+// to a fixed pool of workers, each with a queue of a given size (default: 0,
+// a goroutine per message). Messages sharing an ordering key, by default
+// those sent by the same peer, are handled in the order they were received,
+// while messages of differing keys are handled in parallel.
+func DispatchWorkers(workers, queueSize int) BuilderOption {
+	return func(o *options) {
+		o.dispatchWorkers = workers
+		o.dispatchQueueSize = queueSize
+	}
+}
+
+// DispatchOrderingKey returns a BuilderOption that overrides the ordering key
+// of messages of the same type as message. Messages for which key returns nil
+// are handled by any dispatch worker in parallel. It only has effect
+// alongside DispatchWorkers.
+func DispatchOrderingKey(message proto.Message, key func(ctx *PluginContext) []byte) BuilderOption {
+	return func(o *options) {
+		if o.dispatchKeys == nil {
+			o.dispatchKeys = make(map[string]func(ctx *PluginContext) []byte)
+		}
+		o.dispatchKeys[proto.MessageName(message)] = key
+	}
+}
+
+// UnorderedDispatch returns a BuilderOption that lets messages of the given
+// types be handled by any dispatch worker in parallel, for message types whose
+// handlers commute. It only has effect alongside DispatchWorkers.
+func UnorderedDispatch(messages ...proto.Message) BuilderOption {
+	return func(o *options) {
+		for _, message := range messages {
+			DispatchOrderingKey(message, unorderedKey)(o)
+		}
+	}
+}
+
+// SendWorkers returns a BuilderOption that signs and serializes messages sent
+// through SendAsync and Broadcast on a pool of workers (default: 0, signed on
+// the sending goroutine), leaving peers' send loops to only write them out.
+// Messages sent to a peer are written out in the order they were sent.
+func SendWorkers(workers int) BuilderOption {
+	return func(o *options) {
+		o.sendWorkers = workers
+	}
+}
+
+// StatsRollup returns a BuilderOption that sets how often message counts are
+// rolled up into snapshots, and how long snapshots are kept for computing
+// rates over recent windows of time (default: every 10 seconds, kept for 15
+// minutes).
+func StatsRollup(interval, retention time.Duration) BuilderOption {
+	return func(o *options) {
+		o.statsInterval = interval
+		o.statsRetention = retention
+	}
+}
+
+// BandwidthLimit returns a BuilderOption that limits outbound bandwidth
+// across all peers to bytesPerSecond, allowing bursts of up to burst bytes
+// (default: 0, unlimited). Writes beyond the limit are delayed. The limit may
+// be changed at runtime through Network.SetBandwidthLimit.
+func BandwidthLimit(bytesPerSecond, burst int) BuilderOption {
+	return func(o *options) {
+		o.bandwidth = bytesPerSecond
+		o.bandwidthBurst = burst
+	}
+}
+
+// PeerBandwidthLimit returns a BuilderOption that limits outbound bandwidth
+// to every peer to bytesPerSecond, allowing bursts of up to burst bytes
+// (default: 0, unlimited). The limit may be changed at runtime through
+// Network.SetPeerBandwidthLimit.
+func PeerBandwidthLimit(bytesPerSecond, burst int) BuilderOption {
+	return func(o *options) {
+		o.peerBandwidth = bytesPerSecond
+		o.peerBandwidthBurst = burst
+	}
+}
+
+// ExemptFromShaping returns a BuilderOption that exempts messages of the
+// given types from bandwidth limits, alongside pings and pongs which are
+// always exempt.
+func ExemptFromShaping(messages ...proto.Message) BuilderOption {
+	return func(o *options) {
+		for _, message := range messages {
+			o.shapingExempt = append(o.shapingExempt, proto.MessageName(message))
+		}
+	}
+}
+
+// PaddingBuckets returns a BuilderOption that pads frames written to peers
+// advertising PaddingCapability up to the smallest of the given sizes they
+// fit within, so that the size of frames reveals little of the messages they
+// carry (default: none, frames are not padded). Sizes count the 4-byte length
+// prefix of frames, and must be given in ascending order. Frames larger than
+// the largest size, and frames written over control connections, are written
+// out as is. Padding is filled with pseudo-random bytes, and the bandwidth it
+// takes up is reported by Network.PaddingStats.
+func PaddingBuckets(sizes ...int) BuilderOption {
+	return func(o *options) {
+		o.paddingBuckets = append([]int(nil), sizes...)
+	}
+}
+
+// FixedPadding returns a BuilderOption that pads frames carrying messages of
+// a given type to size bytes, length prefix included, rather than to one of
+// PaddingBuckets. Frames larger than size are padded as any other.
+func FixedPadding(message proto.Message, size int) BuilderOption {
+	return func(o *options) {
+		if o.paddingFixed == nil {
+			o.paddingFixed = make(map[string]int)
+		}
+		o.paddingFixed[proto.MessageName(message)] = size
+	}
+}
+
+// SplitControlPlane returns a BuilderOption that opens a second connection to
+// every peer also advertising ControlPlaneCapability, reserved for control
+// messages: pings, keepalives, node lookups, service record refreshes and
+// rejections, alongside messages of the given types. Control messages bypass bandwidth
+// limits and write buffering, so that they never queue up behind data sent
+// to the peer. Peers not advertising the capability are sent control messages
+// over the data connection.
+func SplitControlPlane(messages ...proto.Message) BuilderOption {
+	return func(o *options) {
+		o.splitControlPlane = true
+		for _, message := range messages {
+			o.controlMessages = append(o.controlMessages, proto.MessageName(message))
+		}
+	}
+}
+
+// AllowLegacyPeers returns a BuilderOption that sets whether peers speaking
+// the wire protocol of upstream noise nodes are talked to (default: false).
+// Legacy peers are recognized by the shape of the first frame they send us,
+// and peers we dial by hanging up on our handshake, in which case they are
+// probed with a legacy ping and expected to connect back to us. Sessions with
+// legacy peers carry none of the features legacy peers lack: see
+// LegacyProtocolVersion.
+func AllowLegacyPeers(allow bool) BuilderOption {
+	return func(o *options) {
+		o.allowLegacyPeers = allow
+	}
+}
+
+// Encryption returns a BuilderOption that decides whether connections to
+// peers are encrypted (default: EncryptionDisabled). Encrypted connections
+// carry everything sent past the handshake sealed with AES-GCM, under keys
+// derived from ephemeral X25519 keys exchanged in signed handshake steps.
+// Legacy peers never encrypt connections, and so may not be talked to should
+// encryption be required.
+func Encryption(policy EncryptionPolicy) BuilderOption {
+	return func(o *options) {
+		o.encryption = policy
+	}
+}
+
+// KeyRatchet returns a BuilderOption that sets how many records, and for how
+// long, the keys of encrypted connections seal records at most before they
+// are ratcheted to the next key through a one-way step (default: 65536
+// records, 10 minutes). Both sides ratchet on whichever of their schedules is
+// stricter. A bound of 0 is left out.
+func KeyRatchet(records int, interval time.Duration) BuilderOption {
+	return func(o *options) {
+		o.ratchetRecords = records
+		o.ratchetInterval = interval
+	}
+}
+
+// ReplayProtection returns a BuilderOption that stamps every envelope this
+// node signs with a replay nonce, increasing by one from one envelope to the
+// next, and the time it was stamped at. Envelopes received are dropped should
+// they carry a nonce seen from their sender before, trail the highest nonce
+// seen from it by window nonces or more, or have been stamped longer ago than
+// the replay horizon (default: 0, disabled). The window is a count of nonces,
+// so it bounds how far envelopes may be reordered on their way, whatever the
+// rate they are sent at. Envelopes carrying no nonce are dropped too should
+// nonces be required. Peers unaware of replay nonces reject envelopes stamped
+// with them.
+func ReplayProtection(window int, required bool) BuilderOption {
+	return func(o *options) {
+		o.replayWindow = window
+		o.requireReplayNonces = required
+	}
+}
+
+// ReplayHorizon returns a BuilderOption that sets how long ago replay nonces
+// may have been stamped before envelopes carrying them are dropped, should
+// replay protection be enabled (default: 5 minutes). Windows of nonces are
+// only held in memory, so envelopes stamped within the horizon may be
+// replayed once more after a restart; the horizon should exceed the clock
+// skew between peers. A horizon of 0 accepts nonces however old.
+func ReplayHorizon(horizon time.Duration) BuilderOption {
+	return func(o *options) {
+		o.replayHorizon = horizon
+	}
+}
+
+// VerificationCache returns a BuilderOption that sets how many received
+// messages, and for how long, the results of checking their signatures are
+// remembered for, so that copies of a message received from many peers are
+// only checked once (default: 8192 messages for 5 minutes). A size of 0
+// disables the cache.
+func VerificationCache(size int, ttl time.Duration) BuilderOption {
+	return func(o *options) {
+		o.verificationCacheSize = size
+		o.verificationCacheTTL = ttl
+	}
+}
+
+// IdempotencyWindow returns a BuilderOption that sets for how long, and for
+// how many requests, the idempotency keys of requests handled are remembered,
+// so that retries of them sent through WithRetry are dropped rather than
+// handled again (default: 4096 requests for 1 minute). A window or size of 0
+// disables deduplicating retries.
+func IdempotencyWindow(window time.Duration, size int) BuilderOption {
+	return func(o *options) {
+		o.idempotencyWindow = window
+		o.idempotencySize = size
+	}
+}
+
+// VerifyAlways returns a BuilderOption that has the signatures of messages of
+// the given types checked every time they are received, bypassing the
+// verification cache.
+func VerifyAlways(messages ...proto.Message) BuilderOption {
+	return func(o *options) {
+		if o.verifyAlways == nil {
+			o.verifyAlways = make(map[string]struct{})
+		}
+		for _, message := range messages {
+			o.verifyAlways[proto.MessageName(message)] = struct{}{}
+		}
+	}
+}
+
+// GateConnections returns a BuilderOption that vetoes connections through
+// gaters, consulted before dialing, before handshaking with accepted
+// connections, and once handshakes identify peers. A connection is allowed
+// only if every gater allows it.
+func GateConnections(gaters ...ConnectionGater) BuilderOption {
+	return func(o *options) {
+		o.gaters = append(o.gaters, gaters...)
+	}
+}
+
+// AllowNetworks returns a BuilderOption that only allows connections to and
+// from addresses within the given CIDR ranges (default: all addresses).
+func AllowNetworks(cidrs ...string) BuilderOption {
+	return func(o *options) {
+		o.allowNetworks = append(o.allowNetworks, cidrs...)
+	}
+}
+
+// DenyNetworks returns a BuilderOption that denies connections to and from
+// addresses within the given CIDR ranges, taking precedence over
+// AllowNetworks.
+func DenyNetworks(cidrs ...string) BuilderOption {
+	return func(o *options) {
+		o.denyNetworks = append(o.denyNetworks, cidrs...)
+	}
+}
+
+// HandshakePuzzles returns a BuilderOption that hands dialers a proof-of-work
+// puzzle to solve within the handshake deadline once more than pending
+// inbound handshakes are underway at once, or once more than perSecond
+// connections were accepted over the last second. Either threshold being 0 is
+// ignored (default: disabled). Peers pinned by ID, or by the address they
+// connect from, are never handed puzzles.
+func HandshakePuzzles(pending, perSecond int) BuilderOption {
+	return func(o *options) {
+		o.puzzlePending = pending
+		o.puzzleRate = perSecond
+	}
+}
+
+// PuzzleDifficulty returns a BuilderOption that sets the difficulty of the
+// puzzles handed to dialers past the thresholds set through HandshakePuzzles,
+// in leading zero bits of a hash, growing by a bit with every doubling of the
+// load up to max (default: 12 bits, up to 20). Solving a puzzle takes
+// 2^difficulty hashes on average, while checking a solution takes one.
+func PuzzleDifficulty(difficulty, max int) BuilderOption {
+	return func(o *options) {
+		o.puzzleDifficulty = difficulty
+		o.puzzleMaxDifficulty = max
+	}
+}
+
+// OnHandshakeFailed returns a BuilderOption that registers a callback invoked
+// whenever a handshake fails, such as should a handshake extension reject a
+// peer.
+func OnHandshakeFailed(fn func(failure HandshakeFailure)) BuilderOption {
+	return func(o *options) {
+		o.onHandshakeFailed = fn
+	}
+}
+
+// EventReplay returns a BuilderOption that sets the number of recent events
+// held for subscriptions to be replayed through ReplayEvents (default: 64).
+func EventReplay(size int) BuilderOption {
+	return func(o *options) {
+		o.eventReplaySize = size
+	}
+}
+
+// HookEvent returns a BuilderOption that registers a hook consulted on events
+// of a kind before the network proceeds with what they announce, vetoing it
+// should the hook return an error. Hooks taking longer than timeout are given
+// up on, and the network proceeds regardless. Only EventPeerConnected may be
+// hooked, vetoing the admission of peers.
+func HookEvent(kind EventKind, timeout time.Duration, hook EventHook) BuilderOption {
+	return func(o *options) {
+		o.eventHooks = append(o.eventHooks, eventHook{kind: kind, timeout: timeout, fn: hook})
+	}
+}
+
+// Compaction returns a BuilderOption that sets how often the internal state of
+// the network, such as candidates never connected to, failures of circuits,
+// results of dial-backs, payload hashes remembered to drop duplicates and
+// chunks of messages which never completed, is swept of entries which
+// expired, and how many entries a sweep examines at most (default: 4096).
+// Internal state is never swept unless interval is set.
+func Compaction(interval time.Duration, budget int) BuilderOption {
+	return func(o *options) {
+		o.compactionInterval = interval
+		o.compactionBudget = budget
+	}
+}
+
+// OnConnectionGated returns a BuilderOption that registers a callback invoked
+// whenever a connection is vetoed, with the reason it was vetoed for.
+func OnConnectionGated(fn func(stage GateStage, address string, reason string)) BuilderOption {
+	return func(o *options) {
+		o.onConnectionGated = fn
+	}
+}
+
+// ReapUnresponsive returns a BuilderOption that disconnects peers judged
+// dead. Peers silent for interval are sent a keepalive, and another every
+// interval they stay silent; peers leaving probes keepalives unanswered, or
+// missing writeFailures write deadlines in a row, are disconnected with
+// DisconnectUnresponsive as the reason (default: 0, disabled). A writeFailures
+// of 0 ignores missed write deadlines.
+func ReapUnresponsive(interval time.Duration, probes int, writeFailures int) BuilderOption {
+	return func(o *options) {
+		o.reapInterval = interval
+		o.reapProbes = probes
+		o.reapWriteFailures = writeFailures
+	}
+}
+
+// Watchdog returns a BuilderOption that watches the network's long-lived
+// loops for any stuck on the same piece of work for threshold or longer
+// (default: 0, disabled). Stuck loops are reported through OnNetworkStalled,
+// and the peer a stuck loop serves is disconnected with DisconnectStalled as
+// the reason and dialed anew.
+func Watchdog(threshold time.Duration) BuilderOption {
+	return func(o *options) {
+		o.watchdogThreshold = threshold
+	}
+}
+
+// OnNetworkStalled returns a BuilderOption that registers a callback invoked
+// whenever the watchdog finds a loop stuck, with the stack trace of the loop.
+func OnNetworkStalled(fn func(stall NetworkStall)) BuilderOption {
+	return func(o *options) {
+		o.onNetworkStalled = fn
+	}
+}
+
+// ResourceBudget returns a BuilderOption that bounds the goroutines and file
+// descriptors a subsystem such as SubsystemPeers may hold at once, zero
+// leaving a resource unbounded (default: unbounded). Connections and tasks
+// which would take a subsystem over budget are refused with a BudgetError,
+// and reported through OnBudgetExceeded.
+func ResourceBudget(subsystem string, goroutines int, fds int) BuilderOption {
+	return func(o *options) {
+		if o.resourceBudgets == nil {
+			o.resourceBudgets = make(map[string]Budget)
+		}
+		o.resourceBudgets[subsystem] = Budget{Goroutines: goroutines, FDs: fds}
+	}
+}
+
+// OnBudgetExceeded returns a BuilderOption that registers a callback invoked
+// whenever a subsystem is refused a resource for going over budget.
+func OnBudgetExceeded(fn func(err *BudgetError)) BuilderOption {
+	return func(o *options) {
+		o.onBudgetExceeded = fn
+	}
+}
+
+// MirrorQueueSize returns a BuilderOption that sets the number of frames which
+// may be queued up to be mirrored to the sinks of mirror rules, past which
+// further frames are dropped rather than holding up the data path (default:
+// 1024).
+func MirrorQueueSize(size int) BuilderOption {
+	return func(o *options) {
+		o.mirrorQueueSize = size
+	}
+}
+
+// KeepalivePayload returns a BuilderOption that registers a provider of small
+// application payloads piggybacking on the keepalives sent to silent peers, and
+// on their acknowledgements. Payloads are only sent to peers advertising
+// KeepalivePayloadCapability; a provider returning more than
+// MaxKeepalivePayload bytes has its payload dropped, the keepalive being sent
+// without one. It has no effect unless peers are reaped through
+// ReapUnresponsive.
+func KeepalivePayload(provider func(peer PeerInfo) []byte) BuilderOption {
+	return func(o *options) {
+		o.keepalivePayload = provider
+	}
+}
+
+// OnKeepalivePayload returns a BuilderOption that registers a callback invoked
+// with payloads peers piggyback on keepalives and their acknowledgements,
+// alongside the latest round trip measured to the peer. The network advertises
+// KeepalivePayloadCapability so that peers send it payloads.
+func OnKeepalivePayload(fn func(peer PeerInfo, payload []byte, rtt time.Duration)) BuilderOption {
+	return func(o *options) {
+		o.onKeepalivePayload = fn
+	}
+}
+
+// OnPeerServices returns a BuilderOption that registers a callback invoked
+// whenever the services a peer advertises change, with the records which
+// replaced those held before. It is first invoked once the peer's services
+// are learned from its handshake, should it advertise any.
+func OnPeerServices(fn func(peer PeerInfo, services map[string]ServiceRecord)) BuilderOption {
+	return func(o *options) {
+		o.onPeerServices = fn
+	}
+}
+
+// TaskExecutor returns a BuilderOption that sets what runs the tasks the
+// network spawns internally (default: a goroutine per task).
+func TaskExecutor(executor Executor) BuilderOption {
+	return func(o *options) {
+		o.executor = executor
+	}
+}
+
+// PeerBundleMaxAge returns a BuilderOption that sets for how long peer bundles
+// exported by the network may be imported (default: 24 hours).
+func PeerBundleMaxAge(d time.Duration) BuilderOption {
+	return func(o *options) {
+		o.peerBundleMaxAge = d
+	}
+}
+
+// DampDisconnectStorms returns a BuilderOption that detects storms of at least
+// threshold peers disconnecting within window. During a storm, plugins are
+// notified of disconnects all at once every window rather than one by one,
+// and reconnects should be spread out over smear (default: 0, disabled).
+func DampDisconnectStorms(threshold int, window time.Duration, smear time.Duration) BuilderOption {
+	return func(o *options) {
+		o.stormThreshold = threshold
+		o.stormWindow = window
+		o.stormSmear = smear
+	}
+}
+
+// OnNetworkDegraded returns a BuilderOption that registers a callback invoked
+// whenever a disconnect storm is detected, as set by DampDisconnectStorms.
+func OnNetworkDegraded(fn func(event NetworkDegraded)) BuilderOption {
+	return func(o *options) {
+		o.onNetworkDegraded = fn
+	}
+}
+
+// SpillOver returns a BuilderOption that spills messages queued up for a peer
+// through QueueMessage over to disk, under a directory of dir per peer, once
+// more than memoryBytes of them are held in memory (default: "", disabled).
+// Spilled messages are deleted once written out, and once their peer's queue
+// is dropped or the network is closed.
+func SpillOver(dir string, memoryBytes int) BuilderOption {
+	return func(o *options) {
+		o.spillDir = dir
+		o.spillMemoryBytes = memoryBytes
+	}
+}
+
+// InboundJournal returns a BuilderOption that appends every message bound for
+// plugins to a write-ahead journal before handling it, so that messages a
+// crash interrupts the handling of are handled again once a network is built
+// over the same store and starts listening, ahead of any message received
+// anew. Entries are consumed once every plugin returns from handling their
+// message without error, and at most maxBytes of them may be pending at once
+// (default: nil, disabled). Messages failing to be journaled are dropped and
+// rejected with ErrJournalFull, or RejectUnknown should the store fail.
+//
+// Messages may hence be handled more than once, and plugins should handle
+// them idempotently, or have copies dropped through DedupeWindow.
+func InboundJournal(store JournalStore, maxBytes int) BuilderOption {
+	return func(o *options) {
+		o.journalStore = store
+		o.journalBytes = maxBytes
+	}
+}
+
+// RequestDeadlines returns a BuilderOption that bounds the deadlines requests
+// propagate to the handlers of their receiver. Requests embed their timeout,
+// capped at ceiling (default: 1 minute), and handlers are given at least floor
+// to reply however little time a request had left (default: 0). A ceiling of 0
+// leaves timeouts uncapped.
+func RequestDeadlines(ceiling time.Duration, floor time.Duration) BuilderOption {
+	return func(o *options) {
+		o.deadlineCeiling = ceiling
+		o.deadlineFloor = floor
+	}
+}
+
+// OnHandlerPanic returns a BuilderOption that registers a callback invoked
+// whenever a plugin panics while handling a message. Panics are recovered, and
+// the peer is treated as set by HandlerPanicResult.
+func OnHandlerPanic(fn func(client *PeerClient, p *HandlerPanic)) BuilderOption {
+	return func(o *options) {
+		o.onHandlerPanic = fn
+	}
+}
+
+// HandlerErrorResult returns a BuilderOption that sets how peers are treated
+// should a plugin fail a message of the same type as message with a plain
+// error rather than a HandlerResult (default: as set by DefaultHandlerResult).
+// Messages of the type under a protocol tag are set apart, by their name
+// qualified with the tag.
+func HandlerErrorResult(message proto.Message, result HandlerResult) BuilderOption {
+	return func(o *options) {
+		if o.handlerErrorResults == nil {
+			o.handlerErrorResults = make(map[string]HandlerResult)
+		}
+		o.handlerErrorResults[proto.MessageName(message)] = result
+	}
+}
+
+// DefaultHandlerResult returns a BuilderOption that sets how peers are treated
+// should a plugin fail a message with a plain error, unless set otherwise for
+// its type by HandlerErrorResult (default: HandlerIgnore).
+func DefaultHandlerResult(result HandlerResult) BuilderOption {
+	return func(o *options) {
+		o.defaultHandlerResult = result
+	}
+}
+
+// HandlerPanicResult returns a BuilderOption that sets how peers are treated
+// should a plugin panic while handling their message (default: HandlerPenalize
+// with a weight of 1).
+func HandlerPanicResult(result HandlerResult) BuilderOption {
+	return func(o *options) {
+		o.handlerPanicResult = result
+	}
+}
+
+// OnViolation returns a BuilderOption that registers a callback invoked
+// whenever a peer's message is rejected for breaching the protocol, such as by
+// carrying an unknown critical extension, or penalized by a plugin handling
+// its message. The peer stays connected unless a plugin disconnects it.
+func OnViolation(fn func(client *PeerClient, err error)) BuilderOption {
+	return func(o *options) {
+		o.onViolation = fn
+	}
+}
+
+// MaxPendingRequests returns a BuilderOption that caps how many requests may
+// await replies at once across all peers, failing the oldest of them with
+// ErrRequestEvicted to make room for newer ones (default: 65536).
+func MaxPendingRequests(size int) BuilderOption {
+	return func(o *options) {
+		o.maxPendingRequests = size
+	}
+}
+
+// RequestLeakCheck returns a BuilderOption that logs, and counts in
+// RequestTableStats, requests found awaiting replies for more than factor
+// times as long as they should have, which would be a bug (default: 0,
+// disabled). Checks go through every pending request once a second, and are
+// meant for debugging.
+func RequestLeakCheck(factor int) BuilderOption {
+	return func(o *options) {
+		o.requestLeakFactor = factor
+	}
+}
+
+// CircuitBreaker returns a BuilderOption that opens the circuit to a peer
+// after failures consecutive failures to send to it or to dial it, failing
+// writes to it right away with ErrCircuitOpen rather than queueing them up
+// (default: 0, disabled). Once cooldown passes, a single write is let through
+// to probe whether the peer recovered: should it succeed the circuit closes,
+// and should it fail the circuit opens again for twice as long, for up to
+// maxCooldown. Reconnecting to the peer closes its circuit.
+func CircuitBreaker(failures int, cooldown, maxCooldown time.Duration) BuilderOption {
+	return func(o *options) {
+		o.circuitFailures = failures
+		o.circuitCooldown = cooldown
+		o.circuitMaxCooldown = maxCooldown
+	}
+}
+
+// AdmissionControl returns a BuilderOption that decides whether to admit
+// writes ahead of the send queues under a policy, given their priority, the
+// circuit of their peer and how many messages are queued across the send
+// queues of all peers as a fraction of capacity (default: disabled). Writes
+// shed fail with an AdmissionError. A nil policy applies
+// DefaultAdmissionPolicy.
+func AdmissionControl(capacity int, policy AdmissionPolicy) BuilderOption {
+	return func(o *options) {
+		o.admissionCapacity = capacity
+		o.admissionPolicy = policy
+	}
+}
+
+// OnCircuitChanged returns a BuilderOption that registers a callback invoked
+// whenever the circuit breaker of a peer changes state.
+func OnCircuitChanged(fn func(address string, state CircuitState)) BuilderOption {
+	return func(o *options) {
+		o.onCircuitChanged = fn
+	}
+}
+
+// ProtectTags returns a BuilderOption that protects peers holding any of the
+// given tags from eviction. Should slots run out, a peer holding a protected
+// tag evicts the least active peer connected in the same direction which is
+// neither pinned, reserved nor protected.
+func ProtectTags(tags ...string) BuilderOption {
+	return func(o *options) {
+		o.protectedTags = append(o.protectedTags, tags...)
+	}
+}
+
+// TagRateLimit returns a BuilderOption that overrides the limit on the rate
+// of messages read from every peer tagged with tag. Peers holding several
+// overridden tags are limited by the loosest of them.
+func TagRateLimit(tag string, limit RateLimit) BuilderOption {
+	return func(o *options) {
+		if o.tagRateLimits == nil {
+			o.tagRateLimits = make(map[string]RateLimit)
+		}
+		o.tagRateLimits[tag] = limit
+	}
+}
+
+// TagBandwidthLimit returns a BuilderOption that overrides the outbound
+// bandwidth limit to every peer tagged with tag to bytesPerSecond, allowing
+// bursts of up to burst bytes. A rate of 0 lifts the limit. Peers holding
+// several overridden tags are limited by the loosest of them.
+func TagBandwidthLimit(tag string, bytesPerSecond, burst int) BuilderOption {
+	return func(o *options) {
+		if o.tagBandwidth == nil {
+			o.tagBandwidth = make(map[string]bandwidthLimit)
+		}
+		o.tagBandwidth[tag] = bandwidthLimit{rate: bytesPerSecond, burst: burst}
+	}
+}
+
+// OnPeerTagged returns a BuilderOption that registers a callback invoked
+// whenever a peer is tagged, or untagged, with a tag.
+func OnPeerTagged(fn func(id PeerID, tag string, tagged bool)) BuilderOption {
+	return func(o *options) {
+		o.onPeerTagged = fn
+	}
+}
 
-var (
-	// ErrStrDuplicatePlugin returns if the plugin has already been registered
-	// with the builder
-	ErrStrDuplicatePlugin = "builder: plugin %s is already registered"
-	// ErrStrNoAddress returns if no address was given to the builder
-	ErrStrNoAddress = "builder: network requires public server IP for peers to connect to"
-	// ErrStrNoKeyPair returns if no keypair was given to the builder
-	ErrStrNoKeyPair = "builder: cryptography keys not provided to Network; cannot create node ID"
-)
+// PeerGroupMetadata returns a BuilderOption that assigns peers to the group
+// named by the value of a key of the metadata they present during their
+// handshake, unless tagged with a GroupTag (default: "", by tags alone).
+func PeerGroupMetadata(key string) BuilderOption {
+	return func(o *options) {
+		o.groupMetadataKey = key
+	}
+}
 
-// Builder is a Address->processors struct
-type Builder struct {
-	opts options
+// OnGroupEmptied returns a BuilderOption that registers a callback invoked
+// whenever the last healthy peer of a group disconnects, or has its circuit
+// open.
+func OnGroupEmptied(fn func(group string)) BuilderOption {
+	return func(o *options) {
+		o.onGroupEmptied = fn
+	}
+}
 
-	keys    *crypto.KeyPair
-	address string
+// OnGroupRecovered returns a BuilderOption that registers a callback invoked
+// whenever a group which was emptied holds healthy peers again.
+func OnGroupRecovered(fn func(group string, health GroupHealth)) BuilderOption {
+	return func(o *options) {
+		o.onGroupRecovered = fn
+	}
+}
 
-	plugins     *PluginList
-	pluginCount int
+// MaxFrameSize returns a BuilderOption that bounds the size of the frames
+// read off the wire, closing connections announcing a larger frame (default:
+// 4MB). Raising it lets applications send larger messages at the expense of
+// the memory a single frame may take up, up to the receive memory budget.
+func MaxFrameSize(size int) BuilderOption {
+	return func(o *options) {
+		o.maxFrameSize = size
+	}
+}
 
-	transports *sync.Map
+// Chunking returns a BuilderOption that sets the size of the chunks messages
+// written with WriteLarge are split into (default: 1MB), the largest message
+// reassembled from the chunks peers send (default: 64MB), and how long the
+// next chunk of a message may take to arrive before those received so far
+// are dropped (default: 30s). Chunks held while a message is reassembled are
+// charged to the receive memory budget, and messages whose chunks would
+// overrun it are dropped.
+func Chunking(chunkSize int, maxTransferSize int, timeout time.Duration) BuilderOption {
+	return func(o *options) {
+		o.chunkSize = chunkSize
+		o.maxTransferSize = maxTransferSize
+		o.transferTimeout = timeout
+	}
 }
 
-var defaultBuilderOptions = options{
-	connectionTimeout: defaultConnectionTimeout,
-	signaturePolicy:   ed25519.New(),
-	hashPolicy:        blake2b.New(),
-	recvWindowSize:    defaultReceiveWindowSize,
-	sendWindowSize:    defaultSendWindowSize,
-	writeBufferSize:   defaultWriteBufferSize,
-	writeFlushLatency: defaultWriteFlushLatency,
-	writeTimeout:      defaultWriteTimeout,
+// MaxMessageSize returns a BuilderOption that drops messages larger than size
+// bytes once they are authenticated, rejecting them with ErrMessageTooLarge
+// (default: 0, only bounded by the maximum frame size). Padding the
+// sender added is not counted.
+func MaxMessageSize(size int) BuilderOption {
+	return func(o *options) {
+		o.maxMessageSize = size
+	}
 }
 
-// A BuilderOption sets options such as connection timeout and cryptographic // policies for the network
-type BuilderOption func(*options)
+// SendRejections returns a BuilderOption that notifies peers advertising
+// RejectionCapability of the messages they sent which were dropped once
+// authenticated, such as for being too large or being sent under an
+// unsupported protocol. Up to perSecond rejections are sent to every peer,
+// allowing bursts of up to burst rejections (default: 0, rejections are not
+// sent). Requests rejected by a peer fail with a *Rejection, whose cause is
+// the error the peer dropped them with.
+func SendRejections(perSecond, burst int) BuilderOption {
+	return func(o *options) {
+		o.rejectRate = perSecond
+		o.rejectBurst = burst
+	}
+}
 
-// ConnectionTimeout returns a NetworkOption that sets the timeout for
-// establishing new connections (default: 60 seconds).
-func ConnectionTimeout(d time.Duration) BuilderOption {
+// PingRate returns a BuilderOption that bounds how many pings Ping sends to a
+// peer every second, allowing bursts of up to burst pings (default: 10 pings a
+// second, in bursts of up to 5). A rate of 0 leaves pings unbounded. Pings
+// beyond the rate fail with ErrPingRateLimited.
+func PingRate(perSecond, burst int) BuilderOption {
 	return func(o *options) {
-		o.connectionTimeout = d
+		o.pingRate = perSecond
+		o.pingBurst = burst
 	}
 }
 
-// SignaturePolicy returns a BuilderOption that sets the signature policy
-// for the network (default: ed25519).
-func SignaturePolicy(policy crypto.SignaturePolicy) BuilderOption {
+// PingDialOnDemand returns a BuilderOption that has Ping dial peers we are not
+// connected to, rather than failing with ErrNotConnected.
+func PingDialOnDemand() BuilderOption {
 	return func(o *options) {
-		o.signaturePolicy = policy
+		o.pingDialOnDemand = true
 	}
 }
 
-// HashPolicy returns a BuilderOption that sets the hash policy for the network
-// (default: blake2b).
-func HashPolicy(policy crypto.HashPolicy) BuilderOption {
+// ReachabilityCheck returns a BuilderOption that has the first few peers we
+// connect to dial us back at the address we advertise, and judges from the
+// outcome of the latest probes whether we are reachable through Reachability.
+// Probes wait for up to timeout for the peer to answer (default: disabled,
+// and 10 seconds once enabled).
+func ReachabilityCheck(probes int, timeout time.Duration) BuilderOption {
 	return func(o *options) {
-		o.hashPolicy = policy
+		o.reachabilityProbes = probes
+		o.reachabilityTimeout = timeout
 	}
 }
 
-// RecvWindowSize returns a BuilderOption that sets the receive buffer window
-// size (default: 4096).
-func RecvWindowSize(recvWindowSize int) BuilderOption {
+// OnReachabilityChanged returns a BuilderOption that registers a callback
+// invoked whenever the reachability judged by ReachabilityCheck is first
+// determined, and whenever it changes afterwards.
+func OnReachabilityChanged(fn func(previous, current Reachability)) BuilderOption {
 	return func(o *options) {
-		o.recvWindowSize = recvWindowSize
+		o.onReachabilityChanged = fn
 	}
 }
 
-// SendWindowSize returns a BuilderOption that sets the send buffer window
-// size (default: 4096).
-func SendWindowSize(sendWindowSize int) BuilderOption {
+// EnforceUpgrade returns a BuilderOption that disconnects peers lagging behind
+// a protocol version from activation onward, with DisconnectOutdated: peers
+// which announced an upgrade to an older version through AnnounceUpgrade, and
+// peers which announced none within 10 seconds of connecting (default:
+// disabled, upgrade announcements being informational).
+func EnforceUpgrade(version uint32, activation time.Time) BuilderOption {
 	return func(o *options) {
-		o.sendWindowSize = sendWindowSize
+		o.enforceUpgradeVersion = version
+		o.enforceUpgradeActivation = activation
 	}
 }
 
-// WriteBufferSize returns a BuilderOption that sets the write buffer size
-// (default: 4096 bytes).
-func WriteBufferSize(byteSize int) BuilderOption {
+// PeerSampling returns a BuilderOption that maintains a partial view of up to
+// viewSize nodes across the whole network, shuffling half of it every interval
+// with the node held in it the longest, from which SamplePeers picks nodes.
+// Every shuffle we start, up to healing of the oldest nodes in the view are
+// dropped in favor of nodes received, as are up to swap of the nodes sent away
+// (default: disabled, and once enabled every 10 seconds, with a healing of 1
+// and a swap of 1). Views hold at most 128 nodes, and healing and swap may add
+// up to at most half a view.
+func PeerSampling(viewSize int, interval time.Duration, healing, swap int) BuilderOption {
 	return func(o *options) {
-		o.writeBufferSize = byteSize
+		o.peerSamplingView = viewSize
+		o.peerSamplingInterval = interval
+		o.peerSamplingHealing = healing
+		o.peerSamplingSwap = swap
 	}
 }
 
-// WriteFlushLatency returns a BuilderOption that sets the write flush interval
-// (default: 50ms).
-func WriteFlushLatency(d time.Duration) BuilderOption {
+// EnvelopeSigning returns a BuilderOption that decides which preimage of the
+// envelopes we send is signed (default: SigningLegacy). Envelopes signed in
+// either form are verified regardless, unless under SigningCanonical. Networks
+// are rolled over to canonical signing by moving every node to
+// SigningNegotiated, and then to SigningCanonical once no node signs over the
+// legacy preimage anymore.
+func EnvelopeSigning(form SigningForm) BuilderOption {
 	return func(o *options) {
-		o.writeFlushLatency = d
+		o.signingForm = form
 	}
 }
 
-// WriteTimeout returns a BuilderOption that sets the write timeout
-// (default: 4096).
-func WriteTimeout(d time.Duration) BuilderOption {
+// ShareDiagnostics returns a BuilderOption that answers diagnostics requested
+// by the given peers through RequestPeerDiagnostics, disclosing what we
+// measured of our session with them (default: diagnostics are shared with no
+// one). Each peer may request diagnostics once a second, in bursts of up to 2.
+func ShareDiagnostics(ids ...PeerID) BuilderOption {
 	return func(o *options) {
-		o.writeTimeout = d
+		o.shareDiagnostics = append(o.shareDiagnostics, ids...)
+	}
+}
+
+// OnRejection returns a BuilderOption that registers a callback invoked
+// whenever a peer rejects a message which was not a pending request, such as
+// one sent through Tell.
+func OnRejection(fn func(client *PeerClient, r *Rejection)) BuilderOption {
+	return func(o *options) {
+		o.onRejection = fn
+	}
+}
+
+// InboundRateLimits returns a BuilderOption that limits the rate of messages
+// read from peers under a hierarchy of token buckets: one across all peers,
+// one for every IP prefix peers connect from, and one for every peer
+// (default: unlimited). Reads from a peer pause until every bucket it draws
+// from allows for another message, and peers sharing a bucket take turns at
+// it. The limits may be changed at runtime through Network.SetRateLimits.
+func InboundRateLimits(limits RateLimits) BuilderOption {
+	return func(o *options) {
+		o.rateLimits = limits
+	}
+}
+
+// OnRateLimited returns a BuilderOption that registers a callback invoked
+// whenever reads from a peer are paused by the rate limits, alongside the most
+// specific level exhausted and how long reads are paused for.
+func OnRateLimited(fn func(client *PeerClient, level RateLimitLevel, delay time.Duration)) BuilderOption {
+	return func(o *options) {
+		o.onRateLimited = fn
+	}
+}
+
+// LightMode returns a BuilderOption that runs the node as a light client of
+// the few peers it connects to, such as for tools which send a handful of
+// messages and exit. Light nodes run no background timers: frames are flushed
+// out as they are written rather than by a flusher, and peers are never
+// reaped. They advertise ClientCapability rather than RoutingCapability, so
+// that full nodes neither add them to their routing tables nor route messages
+// through them, and plugins skip discovery and relaying on them.
+func LightMode() BuilderOption {
+	return func(o *options) {
+		o.light = true
+	}
+}
+
+// Ephemeral returns a BuilderOption that runs the node without a listenable
+// address, such as behind a NAT it may not punch through. Ephemeral nodes
+// advertise EphemeralCapability and no address, and are only reached over the
+// connections they dial, which replies and requests from peers are read off.
+// They take no part in routing, as with LightMode, and Listen only starts
+// their plugins.
+func Ephemeral() BuilderOption {
+	return func(o *options) {
+		o.ephemeral = true
 	}
 }
 
@@ -130,6 +1494,8 @@ func NewBuilder() *Builder {
 	// Register default transport layers.
 	builder.RegisterTransportLayer("tcp", transport.NewTCP())
 	builder.RegisterTransportLayer("kcp", transport.NewKCP())
+	builder.RegisterTransportLayer("ws", transport.NewWebSocket())
+	builder.RegisterTransportLayer("unix", transport.NewUnix())
 
 	return builder
 }
@@ -178,6 +1544,47 @@ func (builder *Builder) AddPlugin(plugin PluginInterface) error {
 	return err
 }
 
+// AddOutboundHook registers a hook invoked on every outgoing message for each
+// peer it is sent to, right before it is signed. Hooks run in the order they
+// were registered.
+func (builder *Builder) AddOutboundHook(hook OutboundHook) {
+	builder.outboundHooks = append(builder.outboundHooks, outboundHook{fn: hook})
+}
+
+// AddPeerIndependentOutboundHook registers an outbound hook whose changes do not
+// depend on the peer a message is sent to. It runs once per message before any
+// per-peer hooks, and is handed an empty PeerInfo.
+func (builder *Builder) AddPeerIndependentOutboundHook(hook OutboundHook) {
+	builder.outboundHooks = append(builder.outboundHooks, outboundHook{fn: hook, peerIndependent: true})
+}
+
+// AddFrameFilter registers a filter screening every frame received before its
+// signature is verified, so that unwanted frames are dropped as cheaply as
+// possible. Filters run in the order they were registered, after the network's
+// own screening.
+func (builder *Builder) AddFrameFilter(filter FrameFilter) {
+	builder.frameFilters = append(builder.frameFilters, filter)
+}
+
+// AddHandshakeExtension registers a handshake extension under a name, which
+// it is advertised to peers under. Peers which do not advertise an extension
+// are rejected should it be required, and otherwise handshake without it.
+// Extensions run in the order they were registered.
+func (builder *Builder) AddHandshakeExtension(name string, required bool, extension HandshakeExtension) error {
+	if len(name) == 0 {
+		return errors.New("handshake extensions must be named")
+	}
+
+	for _, ext := range builder.handshakeExtensions {
+		if ext.name == name {
+			return errors.Errorf("handshake extension %q already registered", name)
+		}
+	}
+
+	builder.handshakeExtensions = append(builder.handshakeExtensions, &handshakeExtension{name: name, required: required, extension: extension})
+	return nil
+}
+
 // RegisterTransportLayer registers a transport layer to the network keyed by its name.
 //
 // Example: builder.RegisterTransportLayer("kcp", transport.NewKCP())
@@ -197,6 +1604,11 @@ func (builder *Builder) Build() (*Network, error) {
 		return nil, errors.New(ErrStrNoKeyPair)
 	}
 
+	policy := builder.opts.signaturePolicy
+	if len(builder.keys.PrivateKey) != policy.PrivateKeySize() || len(builder.keys.PublicKey) != policy.PublicKeySize() {
+		return nil, errors.New(ErrStrKeyPairMismatch)
+	}
+
 	if len(builder.address) == 0 {
 		return nil, errors.New(ErrStrNoAddress)
 	}
@@ -207,29 +1619,431 @@ func (builder *Builder) Build() (*Network, error) {
 	} else {
 		builder.plugins.SortByPriority()
 	}
+	for _, plugins := range builder.protocols {
+		plugins.SortByPriority()
+	}
+
+	if err := builder.opts.rateLimits.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := builder.opts.validateProfile(); err != nil {
+		return nil, err
+	}
+	profile := builder.Profile()
 
 	unifiedAddress, err := ToUnifiedAddress(builder.address)
 	if err != nil {
 		return nil, err
 	}
 
+	// Ephemeral nodes are identified purely by their public keys.
 	id := peer.CreateID(unifiedAddress, builder.keys.PublicKey)
+	if builder.opts.ephemeral {
+		id.Address = ""
+	}
+
+	pinned := make(map[string]struct{})
+	for _, address := range builder.opts.pinnedPeers {
+		resolved, err := ToUnifiedAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		pinned[resolved] = struct{}{}
+	}
+
+	pinnedIDs := make(map[PeerID]struct{})
+	for _, id := range builder.opts.pinnedPeerIDs {
+		if err := id.Validate(); err != nil {
+			return nil, err
+		}
+		pinnedIDs[id] = struct{}{}
+	}
+
+	schemes := make([]SignatureScheme, 0, len(builder.opts.signatureSchemes))
+	for _, scheme := range builder.opts.signatureSchemes {
+		if scheme.Policy == nil || len(scheme.Name) == 0 || scheme.Name == PrimarySignatureScheme {
+			return nil, errors.Errorf("invalid signature scheme %q", scheme.Name)
+		}
+		if scheme.Keys == nil {
+			scheme.Keys = scheme.Policy.RandomKeyPair()
+		}
+		schemes = append(schemes, scheme)
+	}
+	builder.opts.signatureSchemes = schemes
+
+	var gaters []ConnectionGater
+	if len(builder.opts.allowNetworks) > 0 || len(builder.opts.denyNetworks) > 0 {
+		gater, err := NewNetworkGater(builder.opts.allowNetworks, builder.opts.denyNetworks)
+		if err != nil {
+			return nil, err
+		}
+		gaters = append(gaters, gater)
+	}
+	gaters = append(gaters, builder.opts.gaters...)
+
+	if builder.opts.statsInterval <= 0 || builder.opts.statsRetention < builder.opts.statsInterval {
+		return nil, errors.Errorf("invalid stats rollup interval %s and retention %s", builder.opts.statsInterval, builder.opts.statsRetention)
+	}
+
+	if builder.opts.executor == nil {
+		return nil, errors.New("network: executor must not be nil")
+	}
+
+	if builder.opts.deadlineCeiling < 0 || builder.opts.deadlineFloor < 0 {
+		return nil, errors.Errorf("invalid request deadline ceiling %s and floor %s", builder.opts.deadlineCeiling, builder.opts.deadlineFloor)
+	}
+
+	if builder.opts.journalStore != nil && builder.opts.journalBytes <= 0 {
+		return nil, errors.Errorf("invalid inbound journal size of %d bytes", builder.opts.journalBytes)
+	}
+
+	if builder.opts.maxPendingRequests <= 0 || builder.opts.requestLeakFactor < 0 {
+		return nil, errors.Errorf("invalid pending request table of %d requests checked for leaks past %dx their expiry", builder.opts.maxPendingRequests, builder.opts.requestLeakFactor)
+	}
+
+	if builder.opts.admissionCapacity < 0 {
+		return nil, errors.Errorf("invalid admission capacity of %d messages", builder.opts.admissionCapacity)
+	}
+
+	if builder.opts.puzzlePending < 0 || builder.opts.puzzleRate < 0 || builder.opts.puzzleDifficulty < 1 || builder.opts.puzzleMaxDifficulty < builder.opts.puzzleDifficulty || builder.opts.puzzleMaxDifficulty > maxPuzzleDifficulty {
+		return nil, errors.Errorf("invalid handshake puzzles past %d pending handshakes or %d accepts per second, of difficulty %d up to %d", builder.opts.puzzlePending, builder.opts.puzzleRate, builder.opts.puzzleDifficulty, builder.opts.puzzleMaxDifficulty)
+	}
+
+	if builder.opts.eventReplaySize < 0 {
+		return nil, errors.Errorf("invalid event replay of %d events", builder.opts.eventReplaySize)
+	}
+	for _, hook := range builder.opts.eventHooks {
+		if !hook.kind.hookable() || hook.timeout <= 0 || hook.fn == nil {
+			return nil, errors.Errorf("invalid hook on %s events timing out after %s", hook.kind, hook.timeout)
+		}
+	}
+
+	if builder.opts.compactionInterval < 0 || builder.opts.compactionBudget <= 0 {
+		return nil, errors.Errorf("invalid compaction every %s of %d entries", builder.opts.compactionInterval, builder.opts.compactionBudget)
+	}
+
+	if builder.opts.circuitFailures < 0 || (builder.opts.circuitFailures > 0 && (builder.opts.circuitCooldown <= 0 || builder.opts.circuitMaxCooldown < builder.opts.circuitCooldown)) {
+		return nil, errors.Errorf("invalid circuit breaker opening after %d failures for %s up to %s", builder.opts.circuitFailures, builder.opts.circuitCooldown, builder.opts.circuitMaxCooldown)
+	}
+
+	protectedTags := make(map[string]struct{})
+	for _, tag := range builder.opts.protectedTags {
+		if tag == "" {
+			return nil, ErrEmptyTag
+		}
+		protectedTags[tag] = struct{}{}
+	}
+	for tag, limit := range builder.opts.tagRateLimits {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return nil, errors.Errorf("invalid rate limit of %d messages per second for peers tagged %q", limit.Rate, tag)
+		}
+	}
+	for tag, limit := range builder.opts.tagBandwidth {
+		if limit.rate < 0 || limit.burst < 0 {
+			return nil, errors.Errorf("invalid bandwidth limit of %d bytes per second for peers tagged %q", limit.rate, tag)
+		}
+	}
+
+	if builder.opts.maxFrameSize <= 0 || uint64(builder.opts.maxFrameSize) > math.MaxUint32 || builder.opts.maxMessageSize > builder.opts.maxFrameSize {
+		return nil, errors.Errorf("invalid frames of at most %d bytes carrying messages of at most %d bytes", builder.opts.maxFrameSize, builder.opts.maxMessageSize)
+	}
+
+	if builder.opts.chunkSize <= 0 || builder.opts.chunkSize > builder.opts.maxFrameSize/2 || builder.opts.maxTransferSize <= 0 || builder.opts.transferTimeout <= 0 {
+		return nil, errors.Errorf("invalid chunks of %d bytes making up messages of at most %d bytes arriving within %s", builder.opts.chunkSize, builder.opts.maxTransferSize, builder.opts.transferTimeout)
+	}
+
+	if err := checkPadding(builder.opts.paddingBuckets, builder.opts.paddingFixed, builder.opts.maxFrameSize); err != nil {
+		return nil, err
+	}
+
+	if builder.opts.spillMemoryBytes < 0 {
+		return nil, errors.Errorf("invalid spill over memory threshold %d", builder.opts.spillMemoryBytes)
+	}
+
+	if builder.opts.stormThreshold < 0 || (builder.opts.stormThreshold > 0 && builder.opts.stormWindow <= 0) || builder.opts.stormSmear < 0 {
+		return nil, errors.Errorf("invalid disconnect storm threshold %d within %s smeared over %s", builder.opts.stormThreshold, builder.opts.stormWindow, builder.opts.stormSmear)
+	}
+
+	if builder.opts.onSendWatermark != nil && (builder.opts.sendLowWatermark < 0 || builder.opts.sendLowWatermark >= builder.opts.sendHighWatermark) {
+		return nil, errors.Errorf("invalid send watermarks of %d and %d bytes", builder.opts.sendHighWatermark, builder.opts.sendLowWatermark)
+	}
+
+	if builder.opts.peerBundleMaxAge <= 0 {
+		return nil, errors.Errorf("invalid peer bundle max age %s", builder.opts.peerBundleMaxAge)
+	}
+
+	if builder.opts.reapInterval < 0 || builder.opts.reapProbes < 0 || builder.opts.reapWriteFailures < 0 {
+		return nil, errors.Errorf("invalid reaping interval %s with %d probes and %d write failures", builder.opts.reapInterval, builder.opts.reapProbes, builder.opts.reapWriteFailures)
+	}
+
+	if builder.opts.reachabilityProbes < 0 || (builder.opts.reachabilityProbes > 0 && builder.opts.reachabilityTimeout <= 0) {
+		return nil, errors.Errorf("invalid reachability check of %d probes timing out after %s", builder.opts.reachabilityProbes, builder.opts.reachabilityTimeout)
+	}
+
+	if v := builder.opts.peerSamplingView; v < 0 || v > maxPeerSamplingView || (v > 0 && (v < 2 || builder.opts.peerSamplingInterval <= 0 ||
+		builder.opts.peerSamplingHealing < 0 || builder.opts.peerSamplingSwap < 0 || builder.opts.peerSamplingHealing+builder.opts.peerSamplingSwap > v/2)) {
+		return nil, errors.Errorf("invalid peer sampling view of %d nodes shuffled every %s with a healing of %d and a swap of %d",
+			v, builder.opts.peerSamplingInterval, builder.opts.peerSamplingHealing, builder.opts.peerSamplingSwap)
+	}
+
+	if builder.opts.affinityLifetime < 0 {
+		return nil, errors.Errorf("invalid affinity lifetime %s", builder.opts.affinityLifetime)
+	}
+
+	if builder.opts.watchdogThreshold < 0 {
+		return nil, errors.Errorf("invalid watchdog threshold %s", builder.opts.watchdogThreshold)
+	}
+
+	for subsystem, budget := range builder.opts.resourceBudgets {
+		if !containsString(subsystems, subsystem) {
+			return nil, errors.Errorf("unknown subsystem %q to budget resources of", subsystem)
+		}
+		if budget.Goroutines < 0 || budget.FDs < 0 {
+			return nil, errors.Errorf("invalid %s budget of %d goroutines and %d fds", subsystem, budget.Goroutines, budget.FDs)
+		}
+	}
+
+	if builder.opts.light && builder.opts.reapInterval > 0 {
+		return nil, errors.New("light nodes do not reap peers")
+	}
+
+	if builder.opts.ephemeral && builder.opts.peerSamplingView > 0 {
+		return nil, errors.New("ephemeral nodes have no address to be sampled at")
+	}
+	if builder.opts.ephemeral && builder.opts.splitControlPlane {
+		return nil, errors.New("ephemeral nodes send control messages over their data connections")
+	}
+	if builder.opts.encryption == EncryptionRequired && builder.opts.allowLegacyPeers {
+		return nil, errors.New("legacy peers do not encrypt connections, though encryption is required")
+	}
+
+	if builder.opts.replayWindow < 0 {
+		return nil, errors.Errorf("invalid replay window of %d nonces", builder.opts.replayWindow)
+	}
+	if builder.opts.ratchetRecords < 0 {
+		return nil, errors.Errorf("invalid key ratchet of %d records", builder.opts.ratchetRecords)
+	}
+	if builder.opts.ratchetInterval < 0 {
+		return nil, errors.Errorf("invalid key ratchet interval of %s", builder.opts.ratchetInterval)
+	}
+	if builder.opts.replayHorizon < 0 {
+		return nil, errors.Errorf("invalid replay horizon of %s", builder.opts.replayHorizon)
+	}
+	if builder.opts.requireReplayNonces && builder.opts.replayWindow == 0 {
+		return nil, errors.New("replay nonces may only be required with a replay window")
+	}
+	if builder.opts.requireReplayNonces && builder.opts.allowLegacyPeers {
+		return nil, errors.New("legacy peers do not stamp replay nonces, though they are required")
+	}
+
+	// Every node unpacks batches, regardless of whether it sends them.
+	capabilities := append([]string(nil), builder.opts.capabilities...)
+	if !containsString(capabilities, BatchCapability) {
+		capabilities = append(capabilities, BatchCapability)
+	}
+	if !containsString(capabilities, ChunkingCapability) {
+		capabilities = append(capabilities, ChunkingCapability)
+	}
+	if !containsString(capabilities, ServiceRecordsCapability) {
+		capabilities = append(capabilities, ServiceRecordsCapability)
+	}
+	if builder.opts.onKeepalivePayload != nil && !containsString(capabilities, KeepalivePayloadCapability) {
+		capabilities = append(capabilities, KeepalivePayloadCapability)
+	}
+	// Light and ephemeral nodes take no part in routing messages toward keys.
+	if builder.opts.light || builder.opts.ephemeral {
+		if !containsString(capabilities, ClientCapability) {
+			capabilities = append(capabilities, ClientCapability)
+		}
+	} else if !containsString(capabilities, RoutingCapability) {
+		capabilities = append(capabilities, RoutingCapability)
+	}
+	for _, ext := range builder.handshakeExtensions {
+		if !containsString(capabilities, ExtensionCapability(ext.name)) {
+			capabilities = append(capabilities, ExtensionCapability(ext.name))
+		}
+	}
+	if !containsString(capabilities, RejectionCapability) {
+		capabilities = append(capabilities, RejectionCapability)
+	}
+	if !containsString(capabilities, ReachabilityCapability) {
+		capabilities = append(capabilities, ReachabilityCapability)
+	}
+	if !containsString(capabilities, UpgradeCapability) {
+		capabilities = append(capabilities, UpgradeCapability)
+	}
+	if !containsString(capabilities, CanonicalSigningCapability) {
+		capabilities = append(capabilities, CanonicalSigningCapability)
+	}
+	if !containsString(capabilities, GoodbyeCapability) {
+		capabilities = append(capabilities, GoodbyeCapability)
+	}
+	if !containsString(capabilities, PaddingCapability) {
+		capabilities = append(capabilities, PaddingCapability)
+	}
+	if builder.opts.ephemeral && !containsString(capabilities, EphemeralCapability) {
+		capabilities = append(capabilities, EphemeralCapability)
+	}
+	if builder.opts.peerSamplingView > 0 && !containsString(capabilities, PeerSamplingCapability) {
+		capabilities = append(capabilities, PeerSamplingCapability)
+	}
+	if builder.opts.splitControlPlane && !containsString(capabilities, ControlPlaneCapability) {
+		capabilities = append(capabilities, ControlPlaneCapability)
+	}
+
+	// Advertise capabilities on behalf of plugins.
+	advertise := func(plugin PluginInterface) {
+		if advertiser, ok := plugin.(CapabilityAdvertiser); ok {
+			for _, capability := range advertiser.Capabilities() {
+				if !containsString(capabilities, capability) {
+					capabilities = append(capabilities, capability)
+				}
+			}
+		}
+	}
+	builder.plugins.Each(advertise)
+
+	// Advertise protocol tags, sorted so that the handshake is deterministic.
+	tags := make([]string, 0, len(builder.protocols))
+	for tag := range builder.protocols {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		if !containsString(capabilities, ProtocolCapability(tag)) {
+			capabilities = append(capabilities, ProtocolCapability(tag))
+		}
+		builder.protocols[tag].Each(advertise)
+	}
+	builder.opts.capabilities = capabilities
+
+	handlerSlots := make(map[string]chan struct{})
+	for name, limit := range builder.opts.handlerConcurrency {
+		if limit > 0 {
+			handlerSlots[name] = make(chan struct{}, limit)
+		}
+	}
+
+	protocolSlots := make(map[string]chan struct{})
+	for tag, limit := range builder.opts.protocolConcurrency {
+		if limit > 0 {
+			protocolSlots[tag] = make(chan struct{}, limit)
+		}
+	}
+
+	dedupes := make(map[string]*dedupeSet)
+	for name, window := range builder.opts.dedupeWindows {
+		if window.window > 0 && window.size > 0 {
+			dedupes[name] = newDedupeSet(window.window, window.size)
+		}
+	}
+
+	var idempotency *dedupeSet
+	if builder.opts.idempotencyWindow > 0 && builder.opts.idempotencySize > 0 {
+		idempotency = newDedupeSet(builder.opts.idempotencyWindow, builder.opts.idempotencySize)
+	}
+
+	var journal *inboundJournal
+	if builder.opts.journalStore != nil {
+		if journal, err = openInboundJournal(builder.opts.journalStore, builder.opts.journalBytes); err != nil {
+			return nil, err
+		}
+	}
+
+	var dialSlots chan struct{}
+	if builder.opts.maxDials > 0 {
+		dialSlots = make(chan struct{}, builder.opts.maxDials)
+	}
+
+	var watch *watchdog
+	if builder.opts.watchdogThreshold > 0 {
+		watch = newWatchdog()
+	}
 
 	net := &Network{
 		opts:    builder.opts,
+		profile: profile,
 		ID:      id,
 		keys:    builder.keys,
 		Address: unifiedAddress,
 
 		plugins:    builder.plugins,
+		protocols:  builder.protocols,
 		transports: builder.transports,
 
+		outboundHooks: builder.outboundHooks,
+		frameFilters:  builder.frameFilters,
+		handlerSlots:  handlerSlots,
+		protocolSlots: protocolSlots,
+		dedupes:       dedupes,
+		idempotency:   idempotency,
+		journal:       journal,
+		dialSlots:     dialSlots,
+		sessions:      newSessionStore(builder.opts.sessionLifetime),
+		affinities:    newAffinityStore(),
+		replayNonce:   uint64(time.Now().UnixNano()),
+		replays:       newReplayFilter(builder.opts.replayWindow),
+		budget:        newReceiveBudget(builder.opts),
+		now:           time.Now,
+		after:         time.After,
+
+		handshakeExtensions: builder.handshakeExtensions,
+		watchdog:            watch,
+		resources:           newResourceRegistry(builder.opts.resourceBudgets, builder.opts.onBudgetExceeded),
+
+		bandwidth:          newTokenBucket(builder.opts.bandwidth, builder.opts.bandwidthBurst),
+		peerBandwidth:      builder.opts.peerBandwidth,
+		peerBandwidthBurst: builder.opts.peerBandwidthBurst,
+		shapingExempt:      shapingExemptions(builder.opts.shapingExempt),
+		controlMessages:    controlMessageNames(builder.opts.controlMessages),
+		limiter:            newRateLimiter(builder.opts.rateLimits),
+		verifications:      newVerificationCache(builder.opts.verificationCacheSize, builder.opts.verificationCacheTTL),
+		probeAnswers:       newTokenBucket(reachabilityAnswerRate, reachabilityAnswerBurst),
+		sampler:            newPeerSampler(),
+
+		slots:     newPeerSlots(builder.opts),
+		pinned:    pinned,
+		pinnedIDs: pinnedIDs,
+
+		protectedTags: protectedTags,
+
 		peers:       new(sync.Map),
 		connections: new(sync.Map),
+		incoming:    new(sync.Map),
+
+		listeningCh:  make(chan struct{}),
+		peersChanged: make(chan struct{}),
+		kill:         make(chan struct{}),
+	}
+
+	if builder.opts.dispatchWorkers > 0 {
+		net.dispatch = newDispatchPool(builder.opts.dispatchWorkers, builder.opts.dispatchQueueSize, net.kill)
+	}
 
-		listeningCh: make(chan struct{}),
-		kill:        make(chan struct{}),
+	mirrorQueueSize := builder.opts.mirrorQueueSize
+	if mirrorQueueSize <= 0 {
+		mirrorQueueSize = defaultMirrorQueueSize
 	}
+	net.mirrors.queue = make(chan mirroredFrame, mirrorQueueSize)
+
+	if len(gaters) > 0 {
+		net.gater = ComposeGaters(gaters...)
+	}
+
+	if len(builder.opts.tagRateLimits) > 0 {
+		net.limiter.peerLimit = net.tagRateLimit
+	}
+
+	net.stats = newStats(func() time.Time { return net.now() }, builder.opts.statsInterval, builder.opts.statsRetention, net.kill, builder.opts.executor)
+	net.stats.resources = net.resources
+
+	if builder.opts.sendWorkers > 0 {
+		net.pipeline = newSendPipeline(builder.opts.sendWorkers, net.kill)
+	}
+
+	net.registerCompactors()
 
 	net.Init()
 
@@ -0,0 +1,167 @@
+package network
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// CanonicalSigningCapability is advertised by nodes which verify envelopes
+// signed over their canonical preimage.
+const CanonicalSigningCapability = "noise/canonical-signing"
+
+// canonicalDomain prefixes canonical preimages, so that they may never be
+// mistaken for a legacy preimage or for any other signed payload.
+const canonicalDomain = "noise/envelope/v1"
+
+// SigningForm decides which preimage of the envelopes we send is signed.
+type SigningForm int
+
+const (
+	// SigningLegacy signs envelopes over their legacy preimage, which every
+	// node verifies.
+	SigningLegacy SigningForm = iota
+	// SigningNegotiated signs envelopes over their canonical preimage while
+	// every peer we are connected to advertises CanonicalSigningCapability,
+	// and over their legacy preimage otherwise.
+	SigningNegotiated
+	// SigningCanonical always signs envelopes over their canonical preimage,
+	// and rejects envelopes signed over their legacy preimage.
+	SigningCanonical
+)
+
+// CanonicalPreimage returns the bytes the signatures of an envelope cover once
+// it is signed canonically, as marked by ExtensionCanonicalSigning. The layout
+// depends on no protobuf encoder. Every integer is a little-endian uint32, and
+// every byte string is prefixed with its length as such an integer. Fields are
+// always present, empty or not, in this order:
+//
+//	domain               "noise/envelope/v1"
+//	sender public key    byte string
+//	sender ID            byte string
+//	sender address       byte string
+//	payload type URL     byte string
+//	payload value        byte string
+//	protocol tag         byte string
+//	signature keys       count, then a scheme and a public key byte string
+//	                     for every additional signature, in envelope order
+//	critical extensions  count, then every extension, in envelope order
+//	metadata             count, then a key and a value byte string for every
+//	                     entry, sorted by key bytewise
+//	hints                count, then a key and a value byte string for every
+//	                     hint, in envelope order
+//	replay nonce         little-endian uint64, followed by the little-endian
+//	                     uint64 time in milliseconds it was stamped at, only
+//	                     present should the envelope carry one, as marked by
+//	                     ExtensionReplayNonce
+//	signature scheme     byte string, only present should the envelope carry
+//	                     one, as marked by ExtensionSignatureScheme
+//
+// Request and message nonces, the reply flag and the signatures themselves
+// are not covered.
+func CanonicalPreimage(msg *Envelope) []byte {
+	var (
+		preimage []byte
+		word     [4]byte
+	)
+
+	putUint32 := func(value uint32) {
+		binary.LittleEndian.PutUint32(word[:], value)
+		preimage = append(preimage, word[:]...)
+	}
+	putBytes := func(field []byte) {
+		putUint32(uint32(len(field)))
+		preimage = append(preimage, field...)
+	}
+
+	putBytes([]byte(canonicalDomain))
+
+	if msg.Sender != nil {
+		putBytes(msg.Sender.PublicKey)
+		putBytes(msg.Sender.Id)
+		putBytes([]byte(msg.Sender.Address))
+	} else {
+		putBytes(nil)
+		putBytes(nil)
+		putBytes(nil)
+	}
+
+	if msg.Message != nil {
+		putBytes([]byte(msg.Message.TypeUrl))
+		putBytes(msg.Message.Value)
+	} else {
+		putBytes(nil)
+		putBytes(nil)
+	}
+
+	putBytes([]byte(msg.Protocol))
+
+	putUint32(uint32(len(msg.Signatures)))
+	for _, signature := range msg.Signatures {
+		putBytes([]byte(signature.Scheme))
+		putBytes(signature.PublicKey)
+	}
+
+	putUint32(uint32(len(msg.CriticalExtensions)))
+	for _, extension := range msg.CriticalExtensions {
+		putUint32(extension)
+	}
+
+	keys := make([]string, 0, len(msg.Metadata))
+	for key := range msg.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	putUint32(uint32(len(keys)))
+	for _, key := range keys {
+		putBytes([]byte(key))
+		putBytes([]byte(msg.Metadata[key]))
+	}
+
+	putUint32(uint32(len(msg.Hints)))
+	for _, hint := range msg.Hints {
+		putBytes([]byte(hint.Key))
+		putBytes(hint.Value)
+	}
+
+	return serializeSignatureScheme(serializeReplayNonce(preimage, msg.ReplayNonce, msg.ReplayStampMs), msg.SignatureScheme)
+}
+
+// signedCanonically returns true if an envelope is marked as signed over its
+// canonical preimage.
+func signedCanonically(msg *Envelope) bool {
+	for _, extension := range msg.CriticalExtensions {
+		if Extension(extension) == ExtensionCanonicalSigning {
+			return true
+		}
+	}
+	return false
+}
+
+// signingPreimage returns the bytes the signatures of an envelope cover,
+// depending on the form it is marked as signed in.
+func signingPreimage(msg *Envelope) []byte {
+	if signedCanonically(msg) {
+		return CanonicalPreimage(msg)
+	}
+	return serializeEnvelope(msg)
+}
+
+// signsCanonically returns true if envelopes we are about to sign should be
+// signed over their canonical preimage. Under SigningNegotiated, peers are
+// looked over for every envelope signed, as they may connect at any time.
+func (n *Network) signsCanonically() bool {
+	switch n.opts.signingForm {
+	case SigningCanonical:
+		return true
+	case SigningNegotiated:
+		canonical := true
+		n.eachPeer(func(client *PeerClient) bool {
+			canonical = client.HasCapability(CanonicalSigningCapability)
+			return canonical
+		})
+		return canonical
+	default:
+		return false
+	}
+}
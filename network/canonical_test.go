@@ -0,0 +1,120 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalPreimageCoversEveryField(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, EnvelopeSigning(SigningCanonical))
+	defer node.Close()
+
+	msg, err := node.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+	assert.True(t, signedCanonically(msg))
+
+	// The preimage does not depend on how the envelope was encoded.
+	msg.Metadata = map[string]string{"b": "2", "a": "1", "c": "3"}
+	encoded, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+
+	var decoded protobuf.Message
+	assert.Nil(t, proto.Unmarshal(encoded, &decoded))
+	assert.Equal(t, CanonicalPreimage(msg), CanonicalPreimage(&decoded))
+
+	// Unlike the legacy preimage, the canonical one covers the payload's type.
+	retyped := proto.Clone(msg).(*protobuf.Message)
+	retyped.Message.TypeUrl = "type.googleapis.com/unknown.Message"
+	assert.Equal(t, serializeEnvelope(msg), serializeEnvelope(retyped))
+	assert.NotEqual(t, CanonicalPreimage(msg), CanonicalPreimage(retyped))
+
+	// Bytes may not be moved from one field to the next.
+	shifted := proto.Clone(msg).(*protobuf.Message)
+	shifted.Metadata = map[string]string{"b": "2", "a": "", "c": "3"}
+	shifted.Protocol = "1"
+	assert.NotEqual(t, CanonicalPreimage(msg), CanonicalPreimage(shifted))
+}
+
+func TestSigningFormIsNegotiated(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 4)
+
+	builder := NewBuilderWithOptions(EnvelopeSigning(SigningCanonical))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	}})
+
+	canonical, err := builder.Build()
+	assert.Nil(t, err)
+	defer canonical.Close()
+
+	go canonical.Listen()
+	<-canonical.Ready()
+
+	negotiated := buildListeningNode(t, EnvelopeSigning(SigningNegotiated))
+	defer negotiated.Close()
+
+	legacy := buildListeningNode(t)
+	defer legacy.Close()
+
+	// Connected to peers which all verify canonical signatures, envelopes
+	// are signed canonically.
+	toCanonical, err := negotiated.Client(canonical.Address)
+	assert.Nil(t, err)
+
+	msg, err := negotiated.PrepareMessage(&testpb.TestMessage{Message: "canonical"})
+	assert.Nil(t, err)
+	assert.True(t, signedCanonically(msg))
+
+	assert.Nil(t, toCanonical.Tell(&testpb.TestMessage{Message: "canonical"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "canonical", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("canonically signed message was never delivered")
+	}
+
+	// Once a peer which only verifies legacy signatures connects, envelopes
+	// fall back to the legacy form. The peer's offer is stripped of the
+	// capability, as a node predating canonical signing would not list it.
+	toLegacy, err := negotiated.Client(legacy.Address)
+	assert.Nil(t, err)
+
+	offer := *toLegacy.offer
+	offer.Capabilities = nil
+	for _, capability := range toLegacy.offer.Capabilities {
+		if capability != CanonicalSigningCapability {
+			offer.Capabilities = append(offer.Capabilities, capability)
+		}
+	}
+	toLegacy.offer = &offer
+
+	msg, err = negotiated.PrepareMessage(&testpb.TestMessage{Message: "legacy"})
+	assert.Nil(t, err)
+	assert.False(t, signedCanonically(msg))
+
+	// Nodes which require canonical signatures reject legacy ones.
+	fromLegacy, err := legacy.Client(canonical.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, fromLegacy.Tell(&testpb.TestMessage{Message: "legacy"}))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return canonical.ValidationStats().Rejected > 0
+	}))
+
+	select {
+	case msg := <-received:
+		t.Fatalf("legacy signed message %q was delivered", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,443 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// captureMagic starts every capture, followed by captureVersion.
+	captureMagic   = "NOISECAP"
+	captureVersion = 1
+
+	// captureHeaderSize is the size of the fixed part of a captured record's
+	// header: its length, direction, verification outcome and timestamp, all
+	// little endian.
+	captureHeaderSize = 4 + 1 + 1 + 8
+
+	// defaultCaptureFileBytes is the size past which capture files rotate.
+	defaultCaptureFileBytes = 64 * 1024 * 1024
+)
+
+var (
+	// ErrCaptureActive is returned should a capture be started while another
+	// one is still running.
+	ErrCaptureActive = errors.New("network: a capture is already running")
+	// ErrCaptureCorrupted is returned when reading back a malformed capture.
+	ErrCaptureCorrupted = errors.New("network: capture is corrupted")
+
+	errCaptureLimit = errors.New("network: frame exceeds capture limit")
+)
+
+// CaptureStats counts frames written to captures.
+type CaptureStats struct {
+	// Active is true while a capture is running.
+	Active bool
+	// Frames is the number of frames captured.
+	Frames uint64
+	// Bytes is the number of bytes written to captures, headers included.
+	Bytes uint64
+	// Dropped is the number of frames not captured for exceeding the size
+	// limit of a capture, or for the capture having failed to write.
+	Dropped uint64
+}
+
+// CaptureLimits bound the files a capture writes to.
+type CaptureLimits struct {
+	// FileBytes is the size past which a capture starts writing to a new
+	// file (default: 64MB).
+	FileBytes int64
+	// MaxFiles is the number of capture files kept, the oldest ones being
+	// deleted past it. Zero keeps all files.
+	MaxFiles int
+}
+
+// CapturedFrame is a single frame sent or received by a node, as read back
+// from a capture.
+type CapturedFrame struct {
+	// Direction is DirectionInbound for received frames, and DirectionOutbound for sent ones.
+	Direction ConnDirection
+	// Peer is the address of the peer the frame was sent to or received from.
+	Peer string
+	// PeerKey is the public key of the peer.
+	PeerKey []byte
+	// Time is when the frame was sent or received.
+	Time time.Time
+	// Verified is false if the frame's signature failed verification.
+	Verified bool
+	// Frame is the signed message exactly as written to the wire, excluding
+	// its length prefix.
+	Frame []byte
+}
+
+// capture writes frames to a writer, or to a directory of rotated files.
+//
+// Every capture and capture file starts with captureMagic and captureVersion,
+// followed by one record per frame: the length of the rest of the record, the
+// direction of the frame, whether it verified, its timestamp in Unix
+// nanoseconds, the peer's public key and address each prefixed by a 16-bit
+// length, and finally the frame itself.
+type capture struct {
+	w       *bufio.Writer
+	written int64
+
+	// maxBytes stops a capture to a writer, or rotates a capture to files,
+	// once exceeded. Zero is unbounded.
+	maxBytes int64
+
+	// Capture files written to, should the capture rotate files within dir.
+	dir      string
+	maxFiles int
+	files    []string
+	nextID   uint64
+	file     *os.File
+
+	// err is the first write error, past which all frames are dropped.
+	err error
+}
+
+// StartCapture starts writing every frame the node sends and receives to w,
+// alongside the direction, peer, timestamp and verification outcome of each.
+// Frames which would take the capture past maxBytes are dropped; zero leaves
+// the capture unbounded. Captures are read back with NewCaptureReader.
+func (n *Network) StartCapture(w io.Writer, maxBytes int64) error {
+	return n.startCapture(&capture{w: bufio.NewWriter(w), maxBytes: maxBytes})
+}
+
+// StartCaptureFiles starts writing every frame the node sends and receives to
+// files within dir, rotating files according to limits. Each file may be
+// read back on its own.
+func (n *Network) StartCaptureFiles(dir string, limits CaptureLimits) error {
+	if limits.FileBytes <= 0 {
+		limits.FileBytes = defaultCaptureFileBytes
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "failed to create capture directory")
+	}
+
+	return n.startCapture(&capture{dir: dir, maxBytes: limits.FileBytes, maxFiles: limits.MaxFiles})
+}
+
+func (n *Network) startCapture(c *capture) error {
+	n.captureMutex.Lock()
+	defer n.captureMutex.Unlock()
+
+	if n.capture != nil {
+		return ErrCaptureActive
+	}
+
+	if err := c.begin(); err != nil {
+		c.close()
+		return err
+	}
+
+	n.capture = c
+	atomic.StoreUint32(&n.capturing, 1)
+
+	return nil
+}
+
+// StopCapture stops the capture running, if any, flushing out all frames
+// captured so far.
+func (n *Network) StopCapture() error {
+	n.captureMutex.Lock()
+	defer n.captureMutex.Unlock()
+
+	if n.capture == nil {
+		return nil
+	}
+
+	atomic.StoreUint32(&n.capturing, 0)
+	err := n.capture.close()
+	n.capture = nil
+
+	return err
+}
+
+// CaptureStats returns the number of frames captured so far.
+func (n *Network) CaptureStats() CaptureStats {
+	return CaptureStats{
+		Active:  atomic.LoadUint32(&n.capturing) == 1,
+		Frames:  atomic.LoadUint64(&n.capturedFrames),
+		Bytes:   atomic.LoadUint64(&n.capturedBytes),
+		Dropped: atomic.LoadUint64(&n.captureDropped),
+	}
+}
+
+// captureFrame writes a frame sent or received to the capture running, if
+// any. The frame is copied once into the capture's buffer.
+func (n *Network) captureFrame(direction ConnDirection, peer string, key []byte, frame []byte, verified bool, at time.Time) {
+	if atomic.LoadUint32(&n.capturing) == 0 {
+		return
+	}
+
+	n.captureMutex.Lock()
+	defer n.captureMutex.Unlock()
+
+	if n.capture == nil {
+		return
+	}
+
+	written, err := n.capture.record(direction, peer, key, frame, verified, at)
+	if err != nil {
+		atomic.AddUint64(&n.captureDropped, 1)
+		return
+	}
+
+	atomic.AddUint64(&n.capturedFrames, 1)
+	atomic.AddUint64(&n.capturedBytes, uint64(written))
+}
+
+// captureOutbound captures a frame sent to a peer, possibly written out in
+// parts, looking up the peer's public key only while capturing.
+func (n *Network) captureOutbound(address string, parts ...[]byte) {
+	if atomic.LoadUint32(&n.capturing) == 0 {
+		return
+	}
+
+	// Frames written out in parts are only joined up while capturing.
+	frame := parts[0]
+	if len(parts) > 1 {
+		frame = bytes.Join(parts, nil)
+	}
+
+	var key []byte
+	if client, ok := n.peers.Load(address); ok {
+		if id := client.(*PeerClient).id(); id != nil {
+			key = id.PublicKey
+		}
+	}
+
+	n.captureFrame(DirectionOutbound, address, key, frame, true, time.Now())
+}
+
+// flushCapture flushes the frames buffered by the capture running, if any.
+func (n *Network) flushCapture() {
+	if atomic.LoadUint32(&n.capturing) == 0 {
+		return
+	}
+
+	n.captureMutex.Lock()
+	defer n.captureMutex.Unlock()
+
+	if n.capture != nil && n.capture.err == nil {
+		n.capture.err = n.capture.w.Flush()
+	}
+}
+
+// begin opens the first capture file, or starts a capture to a writer.
+func (c *capture) begin() error {
+	if c.dir != "" {
+		return c.rotate()
+	}
+
+	c.writeMagic()
+	return nil
+}
+
+// writeMagic starts a capture or capture file.
+func (c *capture) writeMagic() {
+	c.w.WriteString(captureMagic)
+	c.w.WriteByte(captureVersion)
+	c.written = int64(len(captureMagic) + 1)
+}
+
+// record writes a single record, returning its size. Records are dropped
+// should they not fit within the capture, or in a fresh file should the
+// capture rotate files.
+func (c *capture) record(direction ConnDirection, peer string, key []byte, frame []byte, verified bool, at time.Time) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if len(key) > 0xffff || len(peer) > 0xffff {
+		return 0, errCaptureLimit
+	}
+
+	size := captureHeaderSize + 2 + len(key) + 2 + len(peer) + len(frame)
+
+	if c.maxBytes > 0 && c.written+int64(size) > c.maxBytes {
+		if c.dir == "" || int64(len(captureMagic)+1+size) > c.maxBytes {
+			return 0, errCaptureLimit
+		}
+		if c.err = c.rotate(); c.err != nil {
+			return 0, c.err
+		}
+	}
+
+	var header [captureHeaderSize + 2]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(size-4))
+	header[4] = byte(direction)
+	if verified {
+		header[5] = 1
+	}
+	binary.LittleEndian.PutUint64(header[6:14], uint64(at.UnixNano()))
+	binary.LittleEndian.PutUint16(header[14:16], uint16(len(key)))
+
+	c.w.Write(header[:])
+	c.w.Write(key)
+
+	binary.LittleEndian.PutUint16(header[0:2], uint16(len(peer)))
+	c.w.Write(header[:2])
+	c.w.WriteString(peer)
+
+	if _, c.err = c.w.Write(frame); c.err != nil {
+		return 0, c.err
+	}
+
+	c.written += int64(size)
+
+	return size, nil
+}
+
+// rotate starts writing to a new capture file, deleting the oldest files past
+// the number of files kept.
+func (c *capture) rotate() error {
+	if c.file != nil {
+		if err := c.w.Flush(); err != nil {
+			return errors.Wrap(err, "failed to flush capture file")
+		}
+		c.file.Close()
+		c.file = nil
+	}
+
+	path := filepath.Join(c.dir, fmt.Sprintf("%08d.ncap", c.nextID))
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create capture file")
+	}
+
+	c.file = file
+	c.nextID++
+	c.files = append(c.files, path)
+
+	if c.w == nil {
+		c.w = bufio.NewWriter(file)
+	} else {
+		c.w.Reset(file)
+	}
+
+	for c.maxFiles > 0 && len(c.files) > c.maxFiles {
+		os.Remove(c.files[0])
+		c.files = c.files[1:]
+	}
+
+	c.writeMagic()
+	return nil
+}
+
+// close flushes out all frames captured, and closes the capture file open.
+func (c *capture) close() error {
+	var err error
+	if c.w != nil {
+		err = c.w.Flush()
+	}
+
+	if c.file != nil {
+		if closeErr := c.file.Close(); err == nil {
+			err = closeErr
+		}
+		c.file = nil
+	}
+
+	return errors.Wrap(err, "failed to flush capture")
+}
+
+// CaptureReader reads back the frames of a capture.
+type CaptureReader struct {
+	r *bufio.Reader
+}
+
+// NewCaptureReader starts reading a capture, checking that it was written by
+// a compatible version.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	reader := &CaptureReader{r: bufio.NewReader(r)}
+
+	var magic [len(captureMagic) + 1]byte
+	if _, err := io.ReadFull(reader.r, magic[:]); err != nil {
+		return nil, errors.Wrap(ErrCaptureCorrupted, "missing header")
+	}
+
+	if string(magic[:len(captureMagic)]) != captureMagic {
+		return nil, errors.Wrap(ErrCaptureCorrupted, "not a capture")
+	}
+
+	if magic[len(captureMagic)] != captureVersion {
+		return nil, errors.Errorf("network: capture has unsupported version %d", magic[len(captureMagic)])
+	}
+
+	return reader, nil
+}
+
+// Next returns the next frame of the capture, or io.EOF once all frames were
+// read back.
+func (r *CaptureReader) Next() (*CapturedFrame, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(ErrCaptureCorrupted, "truncated record")
+	}
+
+	size := binary.LittleEndian.Uint32(length[:])
+	if size < captureHeaderSize-4+2+2 {
+		return nil, errors.Wrapf(ErrCaptureCorrupted, "record has length of %d", size)
+	}
+
+	// Records hold frames as large as the capturing node was configured to
+	// accept, so they are only bounded by their length field, and read in as
+	// they arrive rather than allocated up front off a corrupted length.
+	var buffer bytes.Buffer
+	if _, err := io.CopyN(&buffer, r.r, int64(size)); err != nil {
+		return nil, errors.Wrap(ErrCaptureCorrupted, "truncated record")
+	}
+	record := buffer.Bytes()
+
+	frame := &CapturedFrame{
+		Direction: ConnDirection(record[0]),
+		Verified:  record[1] == 1,
+		Time:      time.Unix(0, int64(binary.LittleEndian.Uint64(record[2:10]))),
+	}
+	record = record[10:]
+
+	key, record, ok := splitCaptureField(record)
+	if !ok {
+		return nil, errors.Wrap(ErrCaptureCorrupted, "truncated peer key")
+	}
+	peer, record, ok := splitCaptureField(record)
+	if !ok {
+		return nil, errors.Wrap(ErrCaptureCorrupted, "truncated peer address")
+	}
+
+	frame.PeerKey = key
+	frame.Peer = string(peer)
+	frame.Frame = record
+
+	return frame, nil
+}
+
+// splitCaptureField splits a field prefixed by its 16-bit length off a record.
+func splitCaptureField(record []byte) (field []byte, rest []byte, ok bool) {
+	if len(record) < 2 {
+		return nil, nil, false
+	}
+
+	length := int(binary.LittleEndian.Uint16(record[0:2]))
+	if len(record) < 2+length {
+		return nil, nil, false
+	}
+
+	return record[2 : 2+length], record[2+length:], true
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func readCapture(t *testing.T, r io.Reader) []*CapturedFrame {
+	reader, err := NewCaptureReader(r)
+	assert.Nil(t, err)
+
+	var frames []*CapturedFrame
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return frames
+		}
+		assert.Nil(t, err)
+		frames = append(frames, frame)
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	n := &Network{}
+	at := time.Unix(0, 1234567890)
+
+	// Frames are only captured while a capture runs.
+	n.captureFrame(DirectionInbound, "tcp://peer:1", []byte("key"), []byte("dropped"), true, at)
+
+	var buffer bytes.Buffer
+	assert.Nil(t, n.StartCapture(&buffer, 0))
+	assert.Equal(t, ErrCaptureActive, n.StartCapture(&buffer, 0))
+
+	n.captureFrame(DirectionInbound, "tcp://peer:1", []byte("key"), []byte("first"), false, at)
+	n.captureFrame(DirectionOutbound, "tcp://peer:2", nil, []byte("second"), true, at)
+	assert.Nil(t, n.StopCapture())
+
+	n.captureFrame(DirectionInbound, "tcp://peer:1", []byte("key"), []byte("dropped"), true, at)
+
+	stats := n.CaptureStats()
+	assert.False(t, stats.Active)
+	assert.Equal(t, uint64(2), stats.Frames)
+	assert.Equal(t, uint64(buffer.Len()-len(captureMagic)-1), stats.Bytes)
+
+	frames := readCapture(t, &buffer)
+	if assert.Len(t, frames, 2) {
+		assert.Equal(t, &CapturedFrame{Direction: DirectionInbound, Peer: "tcp://peer:1", PeerKey: []byte("key"), Time: at, Verified: false, Frame: []byte("first")}, frames[0])
+		assert.Equal(t, &CapturedFrame{Direction: DirectionOutbound, Peer: "tcp://peer:2", PeerKey: []byte{}, Time: at, Verified: true, Frame: []byte("second")}, frames[1])
+	}
+
+	// Truncated captures are reported as corrupted.
+	var truncated bytes.Buffer
+	assert.Nil(t, n.StartCapture(&truncated, 0))
+	n.captureFrame(DirectionInbound, "tcp://peer:1", []byte("key"), []byte("frame"), true, at)
+	assert.Nil(t, n.StopCapture())
+
+	reader, err := NewCaptureReader(bytes.NewReader(truncated.Bytes()[:truncated.Len()-1]))
+	assert.Nil(t, err)
+	_, err = reader.Next()
+	assert.Contains(t, err.Error(), ErrCaptureCorrupted.Error())
+}
+
+func TestCaptureLimits(t *testing.T) {
+	t.Parallel()
+
+	n := &Network{}
+	frame := make([]byte, 100)
+
+	// Captures to a writer drop frames past their limit.
+	var buffer bytes.Buffer
+	assert.Nil(t, n.StartCapture(&buffer, 350))
+	for i := 0; i < 5; i++ {
+		n.captureFrame(DirectionInbound, "tcp://peer:1", nil, frame, true, time.Now())
+	}
+	assert.Nil(t, n.StopCapture())
+
+	assert.Len(t, readCapture(t, &buffer), 2)
+	assert.Equal(t, uint64(3), n.CaptureStats().Dropped)
+
+	// Captures to files rotate, keeping the newest files.
+	dir, err := ioutil.TempDir("", "capture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, n.StartCaptureFiles(dir, CaptureLimits{FileBytes: 350, MaxFiles: 2}))
+	for i := 0; i < 7; i++ {
+		frame[0] = byte(i)
+		n.captureFrame(DirectionInbound, "tcp://peer:1", nil, frame, true, time.Now())
+	}
+	assert.Nil(t, n.StopCapture())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ncap"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "00000002.ncap"), filepath.Join(dir, "00000003.ncap")}, files)
+
+	var first []byte
+	for i, path := range files {
+		file, err := os.Open(path)
+		assert.Nil(t, err)
+
+		frames := readCapture(t, file)
+		file.Close()
+
+		if i == 0 {
+			assert.Len(t, frames, 2)
+			first = frames[0].Frame
+		} else {
+			assert.Len(t, frames, 1)
+		}
+	}
+	assert.Equal(t, byte(4), first[0])
+}
+
+func TestCaptureRaisedMaxFrameSize(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("l", defaultMaxFrameSize+0x30000)
+
+	receiver, sender, client, arrivals := connectPadded(t, []BuilderOption{MaxFrameSize(2 * defaultMaxFrameSize)})
+	defer receiver.Close()
+	defer sender.Close()
+
+	var buffer bytes.Buffer
+	assert.Nil(t, receiver.StartCapture(&buffer, 0))
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: large}))
+	assert.Equal(t, large, receivePadded(t, arrivals).message)
+	assert.Nil(t, receiver.StopCapture())
+
+	// Frames larger than the default limit are read back from the capture.
+	var captured *CapturedFrame
+	for _, frame := range readCapture(t, &buffer) {
+		if len(frame.Frame) > defaultMaxFrameSize {
+			captured = frame
+		}
+	}
+	if assert.NotNil(t, captured) {
+		assert.Equal(t, DirectionInbound, captured.Direction)
+		assert.True(t, captured.Verified)
+	}
+}
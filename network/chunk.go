@@ -0,0 +1,372 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// ChunkingCapability is advertised by nodes which reassemble messages sent to
+// them in chunks.
+const ChunkingCapability = "noise/chunk"
+
+const (
+	defaultChunkSize       = 1024 * 1024
+	defaultMaxTransferSize = 64 * 1024 * 1024
+	defaultTransferTimeout = 30 * time.Second
+
+	// maxTransfersPerPeer is how many chunked messages a peer may have
+	// underway at once.
+	maxTransfersPerPeer = 8
+
+	// maxChunksPerTransfer is how many chunks a message may be split into.
+	maxChunksPerTransfer = 64 * 1024
+)
+
+var (
+	// ErrChunkingUnsupported is returned when writing a message too large
+	// for a single chunk to a peer which does not reassemble chunks.
+	ErrChunkingUnsupported = errors.New("network: peer does not reassemble chunked messages")
+	// ErrTransferRejected is the error chunks of a message are dropped with
+	// should the message be larger than accepted, too many messages be
+	// underway from the same peer, or the chunks contradict each other.
+	ErrTransferRejected = errors.New("network: chunked message rejected")
+)
+
+var chunkName = proto.MessageName((*protobuf.Chunk)(nil))
+
+// TransferStats describes the messages sent and received in chunks.
+type TransferStats struct {
+	// Sent is the number of messages sent in chunks.
+	Sent uint64
+	// Reassembled is the number of messages reassembled from their chunks.
+	Reassembled uint64
+	// Rejected is the number of messages whose chunks were dropped for being
+	// malformed, too large, or for too many messages being underway.
+	Rejected uint64
+	// Expired is the number of messages whose chunks were dropped for the
+	// rest of them not arriving within the transfer timeout.
+	Expired uint64
+}
+
+// transfer is a message being reassembled from its chunks. The chunks held
+// are charged to the receive memory budget until the transfer completes or is
+// dropped.
+type transfer struct {
+	size     uint64
+	count    uint32
+	received uint64
+	chunks   map[uint32][]byte
+	expires  time.Time
+
+	budget   *receiveBudget
+	reserved int
+}
+
+// release hands the memory held by the chunks of a transfer back to the
+// receive budget.
+func (t *transfer) release() {
+	t.budget.release(t.reserved)
+	t.reserved = 0
+}
+
+// transferTable holds the messages being reassembled from the chunks peers
+// sent, by peer address and transfer ID.
+type transferTable struct {
+	sync.Mutex
+
+	peers map[string]map[uint64]*transfer
+	peak  peakSize
+
+	reassembled uint64
+	rejected    uint64
+	expired     uint64
+}
+
+// supportsChunking returns true if the peer at an address advertised that it
+// reassembles chunked messages.
+func (n *Network) supportsChunking(address string) bool {
+	if c, exists := n.peers.Load(address); exists {
+		return c.(*PeerClient).HasCapability(ChunkingCapability)
+	}
+	return false
+}
+
+// WriteLarge sends a message to a denoted target address, split into ordered
+// chunks of at most ChunkSize bytes should it be larger, which the peer
+// reassembles before handing the message to its plugins as any other. The
+// message is signed chunk by chunk, and so may not be sent as a request.
+// Peers which do not reassemble chunks may only be sent messages fitting in
+// a single chunk, which are sent as they are.
+func (n *Network) WriteLarge(address string, message proto.Message) error {
+	if message == nil {
+		return errors.New("network: message is null")
+	}
+
+	payload, err := types.MarshalAny(message)
+	if err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal message")
+	}
+
+	chunkSize := n.opts.chunkSize
+	if len(data) <= chunkSize {
+		signed, err := n.PrepareMessage(message)
+		if err != nil {
+			return err
+		}
+		return n.Write(address, signed)
+	}
+
+	if !n.supportsChunking(address) {
+		return errors.Wrapf(ErrChunkingUnsupported, "message of %d bytes to %s", len(data), address)
+	}
+
+	id := atomic.AddUint64(&n.transferSeq, 1)
+	count := (len(data) + chunkSize - 1) / chunkSize
+	if count > maxChunksPerTransfer {
+		return errors.Errorf("network: message of %d bytes would be split into over %d chunks", len(data), maxChunksPerTransfer)
+	}
+
+	for index := 0; index < count; index++ {
+		start := index * chunkSize
+		end := start + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunk := &protobuf.Chunk{
+			Transfer: id,
+			Index:    uint32(index),
+			Count:    uint32(count),
+			Size_:    uint64(len(data)),
+			Data:     data[start:end],
+		}
+
+		signed, err := n.PrepareMessage(chunk)
+		if err != nil {
+			return err
+		}
+		if err := n.Write(address, signed); err != nil {
+			return errors.Wrapf(err, "failed to write chunk %d of %d", index+1, count)
+		}
+	}
+
+	atomic.AddUint64(&n.transfersSent, 1)
+
+	return nil
+}
+
+// reassemble records a chunk a peer sent in a frame, returning the message it
+// completes once all of its chunks were received. The memory held by the
+// chunks is charged to the receive budget, and handed over to the frame
+// completing the message, so that it is released once the message is handled.
+// Transfers which would overrun the budget are rejected rather than waited on,
+// as the chunks they wait for could never be read.
+func (n *Network) reassemble(client *PeerClient, frame *receivedMessage, payload *types.Any) (*types.Any, error) {
+	var chunk protobuf.Chunk
+	if err := types.UnmarshalAny(payload, &chunk); err != nil {
+		return nil, err
+	}
+
+	t := &n.transfers
+	t.Lock()
+	defer t.Unlock()
+
+	reject := func(format string, args ...interface{}) error {
+		t.rejected++
+		t.forget(client.Address, chunk.Transfer)
+		return errors.Wrapf(ErrTransferRejected, format, args...)
+	}
+
+	if chunk.Count < 2 || chunk.Count > maxChunksPerTransfer || chunk.Index >= chunk.Count || uint64(chunk.Count) > chunk.Size_ || len(chunk.Data) == 0 {
+		return nil, reject("chunk %d of %d of a message of %d bytes", chunk.Index, chunk.Count, chunk.Size_)
+	}
+
+	// Every chunk but the last is as large as the message split in as many
+	// chunks calls for.
+	if size := uint64(len(chunk.Data)); chunk.Index < chunk.Count-1 && (uint64(chunk.Count-1)*size >= chunk.Size_ || uint64(chunk.Count)*size < chunk.Size_) {
+		return nil, reject("chunk %d of %d of %d bytes of a message of %d bytes", chunk.Index, chunk.Count, size, chunk.Size_)
+	}
+
+	if chunk.Size_ > uint64(n.opts.maxTransferSize) {
+		return nil, reject("message of %d bytes", chunk.Size_)
+	}
+
+	now := n.now()
+
+	if t.peers == nil {
+		t.peers = make(map[string]map[uint64]*transfer)
+	}
+	transfers := t.peers[client.Address]
+
+	current, exists := transfers[chunk.Transfer]
+	if !exists {
+		t.expire(client.Address, now)
+		transfers = t.peers[client.Address]
+
+		if len(transfers) >= maxTransfersPerPeer {
+			return nil, reject("over %d messages underway", maxTransfersPerPeer)
+		}
+
+		if transfers == nil {
+			transfers = make(map[uint64]*transfer)
+			t.peers[client.Address] = transfers
+		}
+
+		current = &transfer{size: chunk.Size_, count: chunk.Count, chunks: make(map[uint32][]byte), budget: n.budget}
+		transfers[chunk.Transfer] = current
+	}
+
+	if current.size != chunk.Size_ || current.count != chunk.Count || current.received+uint64(len(chunk.Data)) > current.size {
+		return nil, reject("chunk %d of %d contradicting those received before", chunk.Index, chunk.Count)
+	}
+
+	// Chunks received twice are ignored.
+	if _, received := current.chunks[chunk.Index]; received {
+		return nil, nil
+	}
+
+	reserved, ok := n.budget.tryReserve(len(chunk.Data))
+	if !ok {
+		return nil, reject("chunk %d of %d overrunning the receive memory budget", chunk.Index, chunk.Count)
+	}
+
+	current.chunks[chunk.Index] = chunk.Data
+	current.reserved += reserved
+	current.received += uint64(len(chunk.Data))
+	current.expires = now.Add(n.opts.transferTimeout)
+
+	if len(current.chunks) < int(current.count) {
+		return nil, nil
+	}
+
+	// The reassembled message takes the place of its chunks, and is held
+	// for as long as the frame completing it.
+	if frame != nil {
+		frame.reserved += current.reserved
+		current.reserved = 0
+	}
+	t.forget(client.Address, chunk.Transfer)
+
+	if current.received != current.size {
+		t.rejected++
+		return nil, errors.Wrapf(ErrTransferRejected, "message of %d bytes made up of %d bytes of chunks", current.size, current.received)
+	}
+
+	data := make([]byte, 0, current.size)
+	for index := uint32(0); index < current.count; index++ {
+		data = append(data, current.chunks[index]...)
+	}
+
+	message := new(types.Any)
+	if err := proto.Unmarshal(data, message); err != nil {
+		t.rejected++
+		return nil, errors.Wrap(err, "failed to unmarshal chunked message")
+	}
+
+	t.reassembled++
+
+	return message, nil
+}
+
+// forget drops a transfer from a peer, releasing the memory its chunks held.
+// t must be locked.
+func (t *transferTable) forget(address string, id uint64) {
+	transfers, exists := t.peers[address]
+	if !exists {
+		return
+	}
+
+	if current, exists := transfers[id]; exists {
+		current.release()
+	}
+
+	delete(transfers, id)
+	if len(transfers) == 0 {
+		delete(t.peers, address)
+	}
+}
+
+// expire drops the transfers from a peer which timed out as of now. t must be
+// locked.
+func (t *transferTable) expire(address string, now time.Time) int {
+	transfers := t.peers[address]
+
+	examined := 0
+	for id, current := range transfers {
+		examined++
+		if now.After(current.expires) {
+			t.expired++
+			t.forget(address, id)
+		}
+	}
+
+	return examined
+}
+
+// forgetPeer drops every transfer from a peer, such as once it disconnected.
+func (t *transferTable) forgetPeer(address string) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, current := range t.peers[address] {
+		current.release()
+	}
+	delete(t.peers, address)
+}
+
+// compact drops the transfers which timed out, examining at most budget of
+// them.
+func (t *transferTable) compact(now time.Time, budget int) compaction {
+	t.Lock()
+	defer t.Unlock()
+
+	var result compaction
+
+	before := len(t.peers)
+	for address := range t.peers {
+		if result.examined >= budget {
+			break
+		}
+
+		expired := t.expired
+		result.examined += t.expire(address, now)
+		result.reclaimed += int(t.expired - expired)
+	}
+
+	for _, transfers := range t.peers {
+		result.live += len(transfers)
+	}
+
+	if t.peak.sparse(before, len(t.peers), budget-result.examined) {
+		t.peers = rebuildMap(t.peers)
+		result.examined += len(t.peers)
+		result.rebuilds++
+	}
+
+	return result
+}
+
+// TransferStats returns how many messages were sent and received in chunks.
+func (n *Network) TransferStats() TransferStats {
+	t := &n.transfers
+	t.Lock()
+	defer t.Unlock()
+
+	return TransferStats{
+		Sent:        atomic.LoadUint64(&n.transfersSent),
+		Reassembled: t.reassembled,
+		Rejected:    t.rejected,
+		Expired:     t.expired,
+	}
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLarge(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, _, arrivals := connectPadded(t, nil, Chunking(64*1024, defaultMaxTransferSize, defaultTransferTimeout))
+	defer receiver.Close()
+	defer sender.Close()
+
+	// Messages larger than a frame may carry are reassembled from chunks.
+	large := strings.Repeat("c", defaultMaxFrameSize+1024)
+	assert.Nil(t, sender.WriteLarge(receiver.Address, &testpb.TestMessage{Message: large}))
+
+	select {
+	case arrival := <-arrivals:
+		assert.Equal(t, large, arrival.message)
+	case <-time.After(10 * time.Second):
+		t.Fatal("chunked message was never received")
+	}
+
+	// Messages fitting in a single chunk are sent as they are.
+	assert.Nil(t, sender.WriteLarge(receiver.Address, &testpb.TestMessage{Message: "small"}))
+	assert.Equal(t, "small", receivePadded(t, arrivals).message)
+
+	assert.Equal(t, TransferStats{Sent: 1}, sender.TransferStats())
+	assert.Equal(t, TransferStats{Reassembled: 1}, receiver.TransferStats())
+}
+
+func TestTransferLargerThanAcceptedIsRejected(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, _, arrivals := connectPadded(t, []BuilderOption{Chunking(defaultChunkSize, 128*1024, defaultTransferTimeout)}, Chunking(64*1024, defaultMaxTransferSize, defaultTransferTimeout))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, sender.WriteLarge(receiver.Address, &testpb.TestMessage{Message: strings.Repeat("r", 256*1024)}))
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return receiver.TransferStats().Rejected > 0
+	}), "transfer was never rejected")
+	assert.Len(t, arrivals, 0)
+}
+
+func TestTransferExpires(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, Chunking(defaultChunkSize, defaultMaxTransferSize, time.Minute))
+	defer node.Close()
+
+	client := &PeerClient{Address: "tcp://localhost:1"}
+
+	chunk := func(transfer uint64, index uint32, data string) *types.Any {
+		payload, err := types.MarshalAny(&protobuf.Chunk{Transfer: transfer, Index: index, Count: 2, Size_: 8, Data: []byte(data)})
+		assert.Nil(t, err)
+		return payload
+	}
+
+	message, err := node.reassemble(client, nil, chunk(1, 0, "part"))
+	assert.Nil(t, err)
+	assert.Nil(t, message)
+
+	// Chunks contradicting those received before drop the transfer.
+	_, err = node.reassemble(client, nil, chunk(1, 1, "too long"))
+	assert.Equal(t, ErrTransferRejected, errors.Cause(err))
+
+	_, err = node.reassemble(client, nil, chunk(2, 0, "part"))
+	assert.Nil(t, err)
+
+	node.compact(clock.Now())
+	assert.Equal(t, 1, node.CompactionStats().Components[CompactTransfers].Entries)
+
+	clock.Advance(2 * time.Minute)
+	node.compact(clock.Now())
+	assert.Equal(t, 0, node.CompactionStats().Components[CompactTransfers].Entries)
+	assert.Equal(t, TransferStats{Rejected: 1, Expired: 1}, node.TransferStats())
+
+	// Peers may only have so many transfers underway at once.
+	for i := 0; i < maxTransfersPerPeer; i++ {
+		_, err = node.reassemble(client, nil, chunk(uint64(10+i), 0, "part"))
+		assert.Nil(t, err)
+	}
+	_, err = node.reassemble(client, nil, chunk(100, 0, "part"))
+	assert.Equal(t, ErrTransferRejected, errors.Cause(err))
+}
+
+func TestTransferChunkCountIsBounded(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {})
+	defer node.Close()
+
+	client := &PeerClient{Address: "tcp://localhost:1"}
+
+	for _, chunk := range []*protobuf.Chunk{
+		// A single small chunk may not claim to be one of millions.
+		{Transfer: 1, Index: 0, Count: 1 << 24, Size_: defaultMaxTransferSize, Data: []byte("tiny")},
+		// Chunks other than the last must be as large as their count calls for.
+		{Transfer: 2, Index: 0, Count: 1000, Size_: 64 * 1024, Data: []byte("tiny")},
+	} {
+		payload, err := types.MarshalAny(chunk)
+		assert.Nil(t, err)
+
+		_, err = node.reassemble(client, nil, payload)
+		assert.Equal(t, ErrTransferRejected, errors.Cause(err))
+	}
+
+	assert.Equal(t, TransferStats{Rejected: 2}, node.TransferStats())
+}
+
+func TestTransferChargesReceiveBudget(t *testing.T) {
+	t.Parallel()
+
+	payload, err := types.MarshalAny(&testpb.TestMessage{Message: strings.Repeat("b", 64)})
+	assert.Nil(t, err)
+	data, err := proto.Marshal(payload)
+	assert.Nil(t, err)
+
+	half := (len(data) + 1) / 2
+
+	clock := &fakeClock{now: time.Now()}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, ReceiveMemoryBudget(4*half))
+	defer node.Close()
+
+	client := &PeerClient{Address: "tcp://localhost:1"}
+
+	chunk := func(transfer uint64, index uint32) *types.Any {
+		part := data[:half]
+		if index == 1 {
+			part = data[half:]
+		}
+		payload, err := types.MarshalAny(&protobuf.Chunk{Transfer: transfer, Index: index, Count: 2, Size_: uint64(len(data)), Data: part})
+		assert.Nil(t, err)
+		return payload
+	}
+
+	// Chunks held are charged to the budget until their transfer is dropped.
+	for i := 0; i < 4; i++ {
+		_, err = node.reassemble(client, nil, chunk(uint64(i), 0))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 4*half, node.ReceiveBudgetStats().InUse)
+
+	// Transfers overrunning the budget are rejected rather than waited on.
+	_, err = node.reassemble(client, nil, chunk(10, 0))
+	assert.Equal(t, ErrTransferRejected, errors.Cause(err))
+
+	node.transfers.forgetPeer(client.Address)
+	assert.Equal(t, 0, node.ReceiveBudgetStats().InUse)
+
+	// The memory of a reassembled message is handed over to the frame
+	// completing it, and released once the frame is handled.
+	_, err = node.reassemble(client, nil, chunk(20, 0))
+	assert.Nil(t, err)
+
+	frame := &receivedMessage{budget: node.budget, refs: 1}
+	message, err := node.reassemble(client, frame, chunk(20, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, payload.TypeUrl, message.TypeUrl)
+	assert.Equal(t, len(data), frame.reserved)
+	assert.Equal(t, len(data), node.ReceiveBudgetStats().InUse)
+
+	frame.done()
+	assert.Equal(t, 0, node.ReceiveBudgetStats().InUse)
+}
@@ -0,0 +1,224 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned by writes to a peer whose circuit breaker opened
+// after consecutive failures to send to it, until a write probing whether the
+// peer recovered succeeds.
+var ErrCircuitOpen = errors.New("network: circuit to peer is open")
+
+// CircuitState is the state of the circuit breaker guarding writes to a peer.
+type CircuitState int
+
+const (
+	// CircuitClosed lets writes through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails writes right away until its cooldown passes.
+	CircuitOpen
+	// CircuitHalfOpen lets a single write through to probe whether the peer
+	// recovered, closing the circuit should it succeed and opening it again
+	// for twice as long should it fail.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuit counts the consecutive failures to send to a peer.
+type circuit struct {
+	state    CircuitState
+	failures int
+	cooldown time.Duration
+	// retryAt is when the next write probing the peer is let through.
+	retryAt time.Time
+	// failed is when sending to the peer last failed.
+	failed time.Time
+}
+
+// circuitBreakers holds the circuits of peers which failed to be sent to
+// since they were last sent to successfully.
+type circuitBreakers struct {
+	sync.Mutex
+	circuits map[string]*circuit
+	peak     peakSize
+}
+
+// allowWrite fails with ErrCircuitOpen should the circuit to an address be
+// open. Once its cooldown passes, a single write is let through to probe the
+// peer, another one being let through should it never complete.
+func (n *Network) allowWrite(address string) error {
+	if n.opts.circuitFailures <= 0 {
+		return nil
+	}
+
+	n.circuits.Lock()
+
+	c, exists := n.circuits.circuits[address]
+	if !exists || c.state == CircuitClosed {
+		n.circuits.Unlock()
+		return nil
+	}
+
+	now := n.now()
+	if now.Before(c.retryAt) {
+		n.circuits.Unlock()
+		return ErrCircuitOpen
+	}
+
+	c.retryAt = now.Add(c.cooldown)
+	changed := c.state != CircuitHalfOpen
+	c.state = CircuitHalfOpen
+
+	n.circuits.Unlock()
+
+	if changed {
+		n.circuitChanged(address, CircuitHalfOpen)
+	}
+
+	return nil
+}
+
+// circuitOpen returns true if writes to an address would fail right away,
+// without letting a probe through.
+func (n *Network) circuitOpen(address string) bool {
+	if n.opts.circuitFailures <= 0 {
+		return false
+	}
+
+	n.circuits.Lock()
+	defer n.circuits.Unlock()
+
+	c, exists := n.circuits.circuits[address]
+	return exists && c.state != CircuitClosed && n.now().Before(c.retryAt)
+}
+
+// recordSend records whether sending to an address succeeded, closing its
+// circuit should it have, and opening it should enough consecutive sends have
+// failed or should a probe have failed.
+func (n *Network) recordSend(address string, err error) {
+	if n.opts.circuitFailures <= 0 {
+		return
+	}
+
+	if err == nil {
+		n.closeCircuit(address)
+		return
+	}
+
+	n.circuits.Lock()
+
+	if n.circuits.circuits == nil {
+		n.circuits.circuits = make(map[string]*circuit)
+	}
+
+	c, exists := n.circuits.circuits[address]
+	if !exists {
+		c = &circuit{}
+		n.circuits.circuits[address] = c
+	}
+	c.failures++
+	c.failed = n.now()
+
+	opened := false
+
+	switch {
+	case c.state == CircuitHalfOpen:
+		c.cooldown *= 2
+		if c.cooldown > n.opts.circuitMaxCooldown {
+			c.cooldown = n.opts.circuitMaxCooldown
+		}
+		opened = true
+	case c.state == CircuitClosed && c.failures >= n.opts.circuitFailures:
+		c.cooldown = n.opts.circuitCooldown
+		opened = true
+	}
+
+	if opened {
+		c.state = CircuitOpen
+		c.retryAt = n.now().Add(c.cooldown)
+	}
+
+	n.circuits.Unlock()
+
+	if opened {
+		n.circuitChanged(address, CircuitOpen)
+	}
+}
+
+// closeCircuit closes the circuit to an address, forgetting about its
+// failures, as when the peer was sent to or reconnected to.
+func (n *Network) closeCircuit(address string) {
+	if n.opts.circuitFailures <= 0 {
+		return
+	}
+
+	n.circuits.Lock()
+	c, exists := n.circuits.circuits[address]
+	if exists {
+		delete(n.circuits.circuits, address)
+	}
+	n.circuits.Unlock()
+
+	if exists && c.state != CircuitClosed {
+		n.circuitChanged(address, CircuitClosed)
+	}
+}
+
+// compactCircuits forgets the failures of peers which have not failed to be
+// sent to for longer than the maximum cooldown, and whose circuits are not
+// holding writes back, examining at most budget of them.
+func (n *Network) compactCircuits(now time.Time, budget int) compaction {
+	var closed []string
+
+	n.circuits.Lock()
+	result := compactMap(&n.circuits.circuits, &n.circuits.peak, budget, func(address string, c *circuit) bool {
+		if now.Sub(c.failed) <= n.opts.circuitMaxCooldown || now.Before(c.retryAt) {
+			return false
+		}
+		if c.state != CircuitClosed {
+			closed = append(closed, address)
+		}
+		return true
+	})
+	n.circuits.Unlock()
+
+	for _, address := range closed {
+		n.circuitChanged(address, CircuitClosed)
+	}
+
+	return result
+}
+
+// circuitState returns the state of the circuit to an address.
+func (n *Network) circuitState(address string) CircuitState {
+	n.circuits.Lock()
+	defer n.circuits.Unlock()
+
+	if c, exists := n.circuits.circuits[address]; exists {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+func (n *Network) circuitChanged(address string, state CircuitState) {
+	if n.opts.onCircuitChanged != nil {
+		n.opts.onCircuitChanged(address, state)
+	}
+
+	n.publishEvent(&CircuitChangedEvent{EventHeader: n.eventHeader(PeerID{}, address), State: state})
+
+	n.refreshGroupHealth()
+}
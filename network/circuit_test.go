@@ -0,0 +1,157 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var errWriteTimedOut = errors.New("write timed out")
+
+// circuitEvents records the states circuits changed to.
+type circuitEvents struct {
+	sync.Mutex
+	states []CircuitState
+}
+
+func (e *circuitEvents) record(address string, state CircuitState) {
+	e.Lock()
+	defer e.Unlock()
+	e.states = append(e.states, state)
+}
+
+func (e *circuitEvents) take() []CircuitState {
+	e.Lock()
+	defer e.Unlock()
+	states := e.states
+	e.states = nil
+	return states
+}
+
+// connectBreaking connects a sender opening circuits after 3 failures for a
+// second, and up to 4 seconds, to a receiver, both on a fake clock.
+func connectBreaking(t *testing.T, clock *fakeClock, events *circuitEvents) (*Network, *Network, *PeerClient, chan string) {
+	received := make(chan string, 8)
+	receiver := buildClockedNode(t, clock, func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	})
+
+	sender := buildClockedNode(t, clock, func(ctx *PluginContext) {}, CircuitBreaker(3, time.Second, 4*time.Second), OnCircuitChanged(events.record))
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	return receiver, sender, client, received
+}
+
+func TestCircuitOpensAndCloses(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	events := &circuitEvents{}
+
+	receiver, sender, client, received := connectBreaking(t, clock, events)
+	defer receiver.Close()
+	defer sender.Close()
+
+	// Failures short of the threshold leave the circuit closed.
+	sender.markWritten(receiver.Address, errWriteTimedOut)
+	sender.markWritten(receiver.Address, errWriteTimedOut)
+	assert.Equal(t, CircuitClosed, client.Info().Circuit)
+
+	sender.markWritten(receiver.Address, errWriteTimedOut)
+	assert.Equal(t, CircuitOpen, client.Info().Circuit)
+	assert.Equal(t, []CircuitState{CircuitOpen}, events.take())
+
+	// Writes fail right away while the circuit is open.
+	assert.Equal(t, ErrCircuitOpen, errors.Cause(client.Tell(&testpb.TestMessage{Message: "dropped"})))
+	_, err := sender.WriteAsync(receiver.Address, &Envelope{})
+	assert.Equal(t, ErrCircuitOpen, err)
+
+	// Once the cooldown passes, a write probing the peer closes the circuit.
+	clock.Advance(time.Second)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "probe"}))
+	assert.Equal(t, []CircuitState{CircuitHalfOpen, CircuitClosed}, events.take())
+	assert.Equal(t, CircuitClosed, client.Info().Circuit)
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "probe", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("probe was never received")
+	}
+}
+
+func TestCircuitReopensWithLongerCooldown(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	events := &circuitEvents{}
+
+	receiver, sender, client, _ := connectBreaking(t, clock, events)
+	defer receiver.Close()
+	defer sender.Close()
+
+	for i := 0; i < 3; i++ {
+		sender.markWritten(receiver.Address, errWriteTimedOut)
+	}
+
+	for _, cooldown := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		clock.Advance(cooldown - time.Millisecond)
+		assert.Equal(t, ErrCircuitOpen, sender.allowWrite(receiver.Address))
+
+		// A single probe is let through once the cooldown passes.
+		clock.Advance(time.Millisecond)
+		assert.Nil(t, sender.allowWrite(receiver.Address))
+		assert.Equal(t, ErrCircuitOpen, sender.allowWrite(receiver.Address))
+		assert.Equal(t, CircuitHalfOpen, client.Info().Circuit)
+
+		sender.markWritten(receiver.Address, errWriteTimedOut)
+		assert.Equal(t, CircuitOpen, client.Info().Circuit)
+	}
+
+	assert.Equal(t, []CircuitState{
+		CircuitOpen,
+		CircuitHalfOpen, CircuitOpen,
+		CircuitHalfOpen, CircuitOpen,
+		CircuitHalfOpen, CircuitOpen,
+		CircuitHalfOpen, CircuitOpen,
+	}, events.take())
+}
+
+func TestReconnectClosesCircuit(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	events := &circuitEvents{}
+
+	receiver, sender, client, _ := connectBreaking(t, clock, events)
+	defer receiver.Close()
+	defer sender.Close()
+
+	for i := 0; i < 3; i++ {
+		sender.markWritten(receiver.Address, errWriteTimedOut)
+	}
+	assert.Equal(t, []CircuitState{CircuitOpen}, events.take())
+
+	assert.Nil(t, client.Close())
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return !sender.ConnectionStateExists(receiver.Address)
+	}), "peer was never disconnected")
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Equal(t, CircuitClosed, client.Info().Circuit)
+	assert.Equal(t, []CircuitState{CircuitClosed}, events.take())
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "reconnected"}))
+
+	_, err = NewBuilderWithOptions(CircuitBreaker(3, time.Second, time.Millisecond)).Build()
+	assert.NotNil(t, err)
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -18,19 +19,109 @@ import (
 type PeerClient struct {
 	Network *Network
 
+	// ID is set once the peer's first message is received, and is read
+	// through id by anything other than the goroutine receiving it.
+	idMutex sync.RWMutex
 	ID      *peer.ID
 	Address string
 
-	Requests     sync.Map // uint64 -> *RequestState
 	RequestNonce uint64
 
 	stream StreamState
 
+	// Which side dialed first, whether the peer occupies a reserved slot, and
+	// whether it still holds its slot. The first two are set while the client
+	// is dialed, and are only read by others once it is ready for outgoing
+	// messages.
+	direction ConnDirection
+	reserved  bool
+	slotHeld  uint32 // for atomic ops
+
 	outgoingReady chan struct{}
 	incomingReady chan struct{}
 
+	// Messages may first arrive over either the data or the control connection.
+	incomingOnce sync.Once
+
 	jobs chan func()
 
+	// Probation the peer is put under when it first connects.
+	quarantine quarantine
+
+	// Versions and capabilities the peer offered during its handshake.
+	offer *protobuf.HandshakeOffer
+
+	// Whether the peer speaks the wire protocol of upstream noise nodes.
+	legacy bool
+
+	// Whether messages are written to the peer over an encrypted connection.
+	encrypted bool
+
+	// Services the peer advertises, replaced as a whole on every refresh.
+	servicesMutex sync.Mutex
+	services      *serviceRecords
+
+	// Session held for resuming the connection we dialed, if any.
+	session *session
+
+	// State shared by the plugins handling the peer's messages.
+	state *PeerSession
+
+	// Public key the peer proved possession of when we dialed it.
+	publicKey []byte
+
+	// Address the peer last connected to us from (string), if it dialed us.
+	source atomic.Value
+
+	// When the peer connected by the network's clock, in Unix nanoseconds.
+	connectedAt int64 // for atomic ops
+
+	// When the peer last shuffled its view with ours by the network's clock,
+	// in Unix nanoseconds.
+	shuffledAt int64 // for atomic ops
+
+	// Rate at which writes to the peer make it onto the wire, tracked when
+	// write deadlines adapt to it.
+	throughput throughputEstimator
+
+	// Queues of messages (chan func()) which must be handled in order, keyed by message type.
+	orderedQueues sync.Map
+
+	// Signals the peer is judged dead or alive by, tracked when unresponsive
+	// peers are reaped.
+	liveness liveness
+
+	// Rate at which the peer is notified of the messages it sent we dropped,
+	// if it is at all.
+	rejections *tokenBucket
+
+	// Rate at which the peer may be pinged on demand, if it is limited.
+	pings *tokenBucket
+
+	// Rate at which the peer may request diagnostics of us.
+	diagnostics *tokenBucket
+
+	// Messages sent to and received from the peer, and how many bytes they
+	// occupied on the wire.
+	traffic messageCounter
+
+	// How many times the peer was reported for misbehaving.
+	violations uint64 // for atomic ops
+	penalty    uint64 // for atomic ops
+
+	// Client the peer's session was merged into once a session of its own
+	// displaced it. Queued jobs are handed over under the write lock, so that
+	// jobs submitted afterwards queue up behind them.
+	mergeMutex sync.RWMutex
+	successor  *PeerClient
+
+	// Why the client was closed, set before closeSignal is closed.
+	disconnectReason string
+
+	// How long the peer asked for before being reconnected to, should it
+	// have said goodbye.
+	retryAfter int64 // for atomic ops
+
 	closed      uint32 // for atomic ops
 	closeSignal chan struct{}
 }
@@ -48,9 +139,19 @@ type StreamState struct {
 // RequestState represents a state of a request.
 type RequestState struct {
 	data        chan proto.Message
+	rejected    chan *Rejection
+	failed      chan error
 	closeSignal chan struct{}
 }
 
+// fail fails the request, unless it failed already.
+func (s *RequestState) fail(err error) {
+	select {
+	case s.failed <- err:
+	default:
+	}
+}
+
 // createPeerClient creates a stub peer client.
 func createPeerClient(network *Network, address string) (*PeerClient, error) {
 	// Ensure the address is valid.
@@ -58,6 +159,12 @@ func createPeerClient(network *Network, address string) (*PeerClient, error) {
 		return nil, err
 	}
 
+	return newPeerClient(network, address), nil
+}
+
+// newPeerClient creates a stub peer client without validating its address,
+// which ephemeral peers have none of.
+func newPeerClient(network *Network, address string) *PeerClient {
 	client := &PeerClient{
 		Network:      network,
 		Address:      address,
@@ -73,17 +180,38 @@ func createPeerClient(network *Network, address string) (*PeerClient, error) {
 
 		jobs:        make(chan func(), 128),
 		closeSignal: make(chan struct{}),
+
+		state: newPeerSession(network.opts.peerSessionLimit),
 	}
 
-	return client, nil
+	if network.opts.rejectRate > 0 {
+		client.rejections = newTokenBucket(network.opts.rejectRate, network.opts.rejectBurst)
+	}
+
+	if network.opts.pingRate > 0 {
+		client.pings = newTokenBucket(network.opts.pingRate, network.opts.pingBurst)
+	}
+
+	if len(network.opts.shareDiagnostics) > 0 {
+		client.diagnostics = newTokenBucket(diagnosticsRate, diagnosticsBurst)
+	}
+
+	return client
 }
 
 // Init initialize a client's pluging and starts executing a jobs.
 func (c *PeerClient) Init() {
-	c.Network.plugins.Each(func(plugin PluginInterface) {
+	c.Network.eachPlugin(func(plugin PluginInterface) {
 		plugin.PeerConnect(c)
 	})
-	go c.executeJobs()
+	c.Network.spawn(c.executeJobs)
+}
+
+// publishConnected announces that the peer connected. It is only called once
+// the client is ready for outgoing messages, so that subscribers reaching for
+// the peer's client find it connected rather than still being dialed.
+func (c *PeerClient) publishConnected() {
+	c.Network.publishEvent(&PeerConnectedEvent{EventHeader: c.Network.eventHeader(c.PeerID(), c.Address), Info: c.info()})
 }
 
 func (c *PeerClient) executeJobs() {
@@ -92,6 +220,19 @@ func (c *PeerClient) executeJobs() {
 		case job := <-c.jobs:
 			job()
 		case <-c.closeSignal:
+			c.drainJobs()
+			return
+		}
+	}
+}
+
+// drainJobs runs all jobs left queued up once the client closes.
+func (c *PeerClient) drainJobs() {
+	for {
+		select {
+		case job := <-c.jobs:
+			job()
+		default:
 			return
 		}
 	}
@@ -99,45 +240,241 @@ func (c *PeerClient) executeJobs() {
 
 // Submit adds a job to the execution queue.
 func (c *PeerClient) Submit(job func()) {
+	c.submit(job)
+}
+
+// submit adds a job to the execution queue, returning false if the client
+// closed before the job could be queued.
+func (c *PeerClient) submit(job func()) bool {
+	c.mergeMutex.RLock()
+	if successor := c.successor; successor != nil {
+		c.mergeMutex.RUnlock()
+		return successor.submit(job)
+	}
+	defer c.mergeMutex.RUnlock()
+
 	select {
 	case c.jobs <- job:
+		// The job may have been queued up after the queue was drained.
+		if c.isClosed() {
+			c.drainJobs()
+		}
+		return true
 	case <-c.closeSignal:
+		return false
+	}
+}
+
+// merged returns the client the peer's session was last merged into, or the
+// client itself should it not have been merged.
+func (c *PeerClient) merged() *PeerClient {
+	for successor := c.successorOf(); successor != nil; successor = c.successorOf() {
+		c = successor
 	}
+	return c
+}
+
+// successorOf returns the client the peer's session was merged into, if any.
+func (c *PeerClient) successorOf() *PeerClient {
+	c.mergeMutex.RLock()
+	defer c.mergeMutex.RUnlock()
+
+	return c.successor
+}
+
+// isClosed returns true once the client has been closed.
+func (c *PeerClient) isClosed() bool {
+	return atomic.LoadUint32(&c.closed) == 1
 }
 
 // Close stops all sessions/streams and cleans up the nodes in routing table.
 func (c *PeerClient) Close() error {
+	return c.close("")
+}
+
+// close closes the client, recording why for plugins notified of the disconnect.
+func (c *PeerClient) close(reason string) error {
 	if atomic.SwapUint32(&c.closed, 1) == 1 {
 		return nil
 	}
 
+	c.disconnectReason = reason
 	close(c.closeSignal)
 
 	c.stream.Lock()
 	c.stream.isClosed = true
 	c.stream.Unlock()
 
-	c.Network.plugins.Each(func(plugin PluginInterface) {
-		plugin.PeerDisconnect(c)
-	})
+	c.Network.peerDisconnected(c)
+
+	// Clients closed while being dialed have their slot released once the
+	// dial is through.
+	if c.outgoingReadyNow() {
+		c.releaseSlot()
+	}
+
+	c.Network.requests.fail(c, c.Network.abortError())
+
+	c.Network.transfers.forgetPeer(c.Address)
+
+	carried := c.endSession()
 
 	// Remove entries from node's network.
-	if c.ID != nil {
+	if state, ok := c.Network.ConnectionState(c.Address); ok {
 		// close out connections
-		if state, ok := c.Network.ConnectionState(c.ID.Address); ok {
-			state.conn.Close()
+		state.conn.Close()
+		state.closeControl()
+
+		// Fail all messages written asynchronously which have yet to make it out.
+		if c.Network.isClosed() {
+			state.sends.close(ErrNetworkClosed)
+		} else {
+			state.sends.close(errConnectionClosed)
 		}
 
-		c.Network.peers.Delete(c.ID.Address)
-		c.Network.connections.Delete(c.ID.Address)
+		if c.session != nil {
+			c.Network.sessions.retainHeld(c.Address, c.session, atomic.LoadUint64(&state.messageNonce), atomic.LoadUint64(&c.RequestNonce), carried)
+		}
 	}
 
+	// Remember where a peer roamed from only for as long as its session may be resumed.
+	if c.session == nil {
+		c.Network.identities.unbind(c.publicKey, c.Address)
+	}
+
+	c.Network.peers.Delete(c.Address)
+	c.Network.connections.Delete(c.Address)
+
+	c.Network.notifyPeersChanged()
+
+	c.Network.publishEvent(&PeerDisconnectedEvent{EventHeader: c.Network.eventHeader(c.PeerID(), c.Address), Reason: reason})
+
 	return nil
 }
 
+// DisconnectReason returns why the client was closed, such as
+// DisconnectUnresponsive, being empty if it is still open or was closed
+// without a reason.
+func (c *PeerClient) DisconnectReason() string {
+	select {
+	case <-c.closeSignal:
+		return c.disconnectReason
+	default:
+		return ""
+	}
+}
+
+// RetryAfter returns how long the peer asked for before being reconnected to
+// when it said goodbye, being zero if it did not.
+func (c *PeerClient) RetryAfter() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.retryAfter))
+}
+
+// Direction returns whether this peer dialed us, or we dialed them.
+func (c *PeerClient) Direction() ConnDirection {
+	return c.direction
+}
+
+// Encrypted returns true if messages are written to the peer over a
+// connection encrypted with keys agreed upon during its handshake.
+func (c *PeerClient) Encrypted() bool {
+	return c.encrypted
+}
+
+// Capabilities returns the capabilities the peer advertised during its handshake.
+func (c *PeerClient) Capabilities() []string {
+	if c.offer == nil {
+		return nil
+	}
+	return c.offer.Capabilities
+}
+
+// HasCapability returns true if the peer advertised a given capability
+// during its handshake.
+func (c *PeerClient) HasCapability(capability string) bool {
+	for _, advertised := range c.Capabilities() {
+		if advertised == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// setID records the ID the peer signs its messages with.
+func (c *PeerClient) setID(id *peer.ID) {
+	c.idMutex.Lock()
+	c.ID = id
+	c.idMutex.Unlock()
+}
+
+// id returns the ID the peer signs its messages with, or nil should none of
+// its messages have been received yet.
+func (c *PeerClient) id() *peer.ID {
+	c.idMutex.RLock()
+	defer c.idMutex.RUnlock()
+	return c.ID
+}
+
+// info returns a snapshot of what we know about this peer.
+func (c *PeerClient) info() PeerInfo {
+	info := PeerInfo{
+		Address:     c.Address,
+		Direction:   c.direction,
+		Reserved:    c.reserved,
+		Quarantined: c.Quarantined(),
+		PeerID:      c.PeerID(),
+		Connected:   true,
+		Metadata:    c.Metadata(),
+		Services:    c.Services(),
+		RTT:         c.liveness.lastRTT(),
+		Traffic:     c.traffic.counts(),
+		Violations:  atomic.LoadUint64(&c.violations),
+		Penalty:     atomic.LoadUint64(&c.penalty),
+		Circuit:     c.Network.circuitState(c.Address),
+		Session:     c.state,
+		LegacyPeer:  c.legacy,
+	}
+	info.Tags = c.Network.tags.of(info.PeerID)
+	info.Group = c.Network.groupOf(c)
+	if source, ok := c.source.Load().(string); ok {
+		info.Source = source
+	}
+	if schemes := c.Network.signatureBindings.schemes(info.PeerID.PublicKey()); len(schemes) > 0 {
+		info.SignatureSchemes = schemes
+	}
+	if rate, ok := c.throughput.estimate(); ok {
+		info.WriteThroughput = rate
+	}
+	if id := c.id(); id != nil {
+		copied := *id
+		info.ID = &copied
+	}
+	return info
+}
+
+// Info returns a snapshot of what we know about this peer.
+func (c *PeerClient) Info() PeerInfo {
+	return c.info()
+}
+
 // Tell will asynchronously emit a message to a given peer.
 func (c *PeerClient) Tell(message proto.Message) error {
-	signed, err := c.Network.PrepareMessage(message)
+	return c.tell("", message)
+}
+
+// tell emits a message to the peer under a protocol tag.
+func (c *PeerClient) tell(protocol string, message proto.Message) error {
+	return c.tellEnvelope(protocol, message, envelopeFields{})
+}
+
+// tellEnvelope sends a message to the peer under a protocol tag, in an
+// envelope carrying the given fields.
+func (c *PeerClient) tellEnvelope(protocol string, message proto.Message, fields envelopeFields) error {
+	if !c.SupportsProtocol(protocol) {
+		return errors.Wrapf(ErrProtocolUnsupported, "failed to send message to %s under %q", c.Address, protocol)
+	}
+
+	signed, err := c.Network.prepareEnvelope(protocol, message, fields)
 	if err != nil {
 		return errors.Wrap(err, "failed to sign message")
 	}
@@ -152,42 +489,34 @@ func (c *PeerClient) Tell(message proto.Message) error {
 
 // Request requests for a response for a request sent to a given peer.
 func (c *PeerClient) Request(req *rpc.Request) (proto.Message, error) {
-	signed, err := c.Network.PrepareMessage(req.Message)
-	if err != nil {
-		return nil, err
-	}
+	return c.request("", req)
+}
 
-	signed.RequestNonce = atomic.AddUint64(&c.RequestNonce, 1)
+// request sends a request to the peer under a protocol tag.
+func (c *PeerClient) request(protocol string, req *rpc.Request) (proto.Message, error) {
+	if !c.SupportsProtocol(protocol) {
+		return nil, errors.Wrapf(ErrProtocolUnsupported, "failed to send request to %s under %q", c.Address, protocol)
+	}
 
-	err = c.Network.Write(c.Address, signed)
+	signed, err := c.Network.prepareMessage(protocol, req.Message)
 	if err != nil {
 		return nil, err
 	}
 
-	// Start tracking the request.
-	channel := make(chan proto.Message, 1)
-	closeSignal := make(chan struct{})
-
-	c.Requests.Store(signed.RequestNonce, &RequestState{
-		data:        channel,
-		closeSignal: closeSignal,
-	})
+	r := c.trackRequest()
+	defer r.close()
 
-	// Stop tracking the request.
-	defer close(closeSignal)
-	defer c.Requests.Delete(signed.RequestNonce)
-
-	select {
-	case res := <-channel:
-		return res, nil
-	case <-time.After(req.Timeout):
-		return nil, errors.New("request timed out")
-	}
+	return r.attempt(context.Background(), signed, req.Timeout)
 }
 
 // Reply is equivalent to Write() with an appended nonce to signal a reply.
 func (c *PeerClient) Reply(nonce uint64, message proto.Message) error {
-	signed, err := c.Network.PrepareMessage(message)
+	return c.reply("", nonce, message)
+}
+
+// reply sends back a reply to a request received under a protocol tag.
+func (c *PeerClient) reply(protocol string, nonce uint64, message proto.Message) error {
+	signed, err := c.Network.prepareMessage(protocol, message)
 	if err != nil {
 		return err
 	}
@@ -307,7 +636,7 @@ func (c *PeerClient) SetWriteDeadline(t time.Time) error {
 
 // setIncomingReady sets a client state to ready for the incomming requests.
 func (c *PeerClient) setIncomingReady() {
-	close(c.incomingReady)
+	c.incomingOnce.Do(func() { close(c.incomingReady) })
 }
 
 // setOutgoingReady sets a client state to ready for the outgoing requests.
@@ -325,6 +654,30 @@ func (c *PeerClient) IsIncomingReady() bool {
 	}
 }
 
+// holdSlot records that the client occupies the peer slot it acquired.
+func (c *PeerClient) holdSlot() {
+	atomic.StoreUint32(&c.slotHeld, 1)
+}
+
+// releaseSlot returns the peer slot the client occupies, should it still
+// occupy one.
+func (c *PeerClient) releaseSlot() {
+	if atomic.CompareAndSwapUint32(&c.slotHeld, 1, 0) {
+		c.Network.slots.release(c.direction, c.reserved)
+	}
+}
+
+// outgoingReadyNow returns true, without waiting, if the client has an
+// outgoing socket established, after which what its handshake set may be read.
+func (c *PeerClient) outgoingReadyNow() bool {
+	select {
+	case <-c.outgoingReady:
+		return true
+	default:
+		return false
+	}
+}
+
 // IsOutgoingReady returns true if the client has an outgoing socket established.
 func (c *PeerClient) IsOutgoingReady() bool {
 	select {
@@ -0,0 +1,126 @@
+package network
+
+import (
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// countOpenFDs returns the number of file descriptors held by this process,
+// or -1 should it not be supported by the platform.
+func countOpenFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+// waitUntil polls a condition until it holds, or a timeout elapses.
+func waitUntil(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestCloseIdempotent(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	plugin := new(MockPlugin)
+	builder.AddPlugin(plugin)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	first := node.Close()
+	assert.Equal(t, first, node.Close())
+	assert.Equal(t, int32(1), plugin.cleanup.Load(), "cleanup hooks should only be called once")
+
+	_, err = node.Client("tcp://127.0.0.1:1")
+	assert.Equal(t, ErrNetworkClosed, err)
+	assert.Equal(t, ErrNetworkClosed, node.Write("tcp://127.0.0.1:1", &protobuf.Message{}))
+}
+
+func TestCloseAbortsPendingRequests(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	client, err := node.Client(other.Address)
+	assert.Nil(t, err)
+
+	result := make(chan error, 1)
+	go func() {
+		request := new(rpc.Request)
+		request.SetMessage(&protobuf.Bytes{})
+		request.SetTimeout(10 * time.Second)
+
+		_, err := client.Request(request)
+		result <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	node.Close()
+
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrNetworkClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("pending request was not aborted by Close()")
+	}
+}
+
+func TestCloseReleasesResources(t *testing.T) {
+	if testing.Short() {
+		t.Skipf("skipping %s in short mode", t.Name())
+	}
+
+	goroutinesBefore := runtime.NumGoroutine()
+	fdsBefore := countOpenFDs()
+
+	for i := 0; i < 50; i++ {
+		a := buildListeningNode(t)
+		b := buildListeningNode(t)
+
+		b.Bootstrap(a.Address)
+		waitForPeers(t, a, 1)
+
+		client, err := a.Client(b.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&protobuf.Ping{}))
+
+		a.Close()
+		b.Close()
+	}
+
+	// Allow for a few goroutines belonging to the runtime or other tests.
+	const slack = 5
+
+	if !waitUntil(5*time.Second, func() bool { return runtime.NumGoroutine() <= goroutinesBefore+slack }) {
+		buf := make([]byte, 1<<20)
+		t.Fatalf("leaked goroutines: before %d, after %d\n%s", goroutinesBefore, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+	}
+
+	if fdsBefore >= 0 {
+		if !waitUntil(5*time.Second, func() bool { return countOpenFDs() <= fdsBefore+slack }) {
+			t.Fatalf("leaked file descriptors: before %d, after %d", fdsBefore, countOpenFDs())
+		}
+	}
+}
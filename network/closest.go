@@ -0,0 +1,55 @@
+package network
+
+import (
+	"context"
+
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// ErrNoPeerFinder is returned by FindClosestPeers should no registered plugin
+// be able to look up peers.
+var ErrNoPeerFinder = errors.New("network: no plugin looks up peers")
+
+// PeerFinder is implemented by plugins which look up the peers closest to an
+// arbitrary key across the network, such as the discovery plugin.
+type PeerFinder interface {
+	FindClosestPeers(ctx context.Context, key []byte, k int) ([]peer.ID, error)
+}
+
+// FindClosestPeers looks up the k peers closest by XOR distance to an
+// arbitrary key the size of a peer ID's hash across the network, returning
+// them closest first alongside whether each is currently connected.
+func (n *Network) FindClosestPeers(ctx context.Context, key []byte, k int) ([]PeerInfo, error) {
+	var finder PeerFinder
+	n.eachPlugin(func(plugin PluginInterface) {
+		if f, ok := plugin.(PeerFinder); ok && finder == nil {
+			finder = f
+		}
+	})
+
+	if finder == nil {
+		return nil, ErrNoPeerFinder
+	}
+
+	ids, err := finder.FindClosestPeers(ctx, key, k)
+
+	peers := make([]PeerInfo, 0, len(ids))
+	for _, id := range ids {
+		id := id
+
+		if c, exists := n.peers.Load(id.Address); exists {
+			info := c.(*PeerClient).info()
+			peers = append(peers, info)
+			continue
+		}
+
+		peers = append(peers, PeerInfo{
+			ID:      &id,
+			PeerID:  PeerID{publicKey: string(id.PublicKey)},
+			Address: id.Address,
+		})
+	}
+
+	return peers, err
+}
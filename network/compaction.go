@@ -0,0 +1,255 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCompactionBudget is how many entries are examined per sweep
+	// across every component.
+	defaultCompactionBudget = 4096
+
+	// compactionMinRebuild is how many entries a map must have held at its
+	// peak before it is ever rebuilt.
+	compactionMinRebuild = 64
+	// compactionRebuildRatio is how many times more entries a map must have
+	// held at its peak than it holds now before it is rebuilt.
+	compactionRebuildRatio = 4
+)
+
+// Components of the network whose internal state is swept of expired entries.
+const (
+	// CompactAddressBook drops candidates imported from peer bundles which
+	// were never connected to, once they were last seen longer ago than
+	// PeerBundleMaxAge.
+	CompactAddressBook = "address-book"
+	// CompactCircuits forgets the failures of peers which have not failed to
+	// be sent to for longer than the circuit breaker's maximum cooldown,
+	// closing their circuits.
+	CompactCircuits = "circuits"
+	// CompactAddressResults drops the expired results of dialing peers back
+	// at the addresses they advertise.
+	CompactAddressResults = "address-results"
+	// CompactDedupe drops the expired payload hashes of messages and
+	// idempotency keys of requests remembered to drop duplicates.
+	CompactDedupe = "dedupe"
+	// CompactVerifications drops the expired results of checking the
+	// signatures of messages.
+	CompactVerifications = "verifications"
+	// CompactTransfers drops the chunks received of messages whose next
+	// chunk did not arrive within the transfer timeout.
+	CompactTransfers = "transfers"
+)
+
+// CompactionStats describes the sweeps of internal state made so far.
+type CompactionStats struct {
+	// Sweeps is the number of sweeps made.
+	Sweeps uint64
+	// Components describes the sweeps of every component by name.
+	Components map[string]ComponentCompaction
+}
+
+// ComponentCompaction describes the sweeps of the internal state of a
+// component.
+type ComponentCompaction struct {
+	// Entries is the number of entries the component held as of its latest
+	// sweep.
+	Entries int
+	// Reclaimed is the number of expired entries dropped.
+	Reclaimed uint64
+	// Rebuilds is the number of times the maps holding the entries were
+	// rebuilt to release the memory Go never releases from maps by itself.
+	Rebuilds uint64
+}
+
+// compaction is the outcome of sweeping a component.
+type compaction struct {
+	// examined is the number of entries examined, which only falls short of
+	// the budget should the component have been swept through.
+	examined  int
+	reclaimed int
+	live      int
+	rebuilds  int
+}
+
+func (c *compaction) add(o compaction) {
+	c.examined += o.examined
+	c.reclaimed += o.reclaimed
+	c.live += o.live
+	c.rebuilds += o.rebuilds
+}
+
+// compactor sweeps a component of expired entries as of now, examining at
+// most budget entries.
+type compactor struct {
+	name    string
+	compact func(now time.Time, budget int) compaction
+}
+
+// sweeper sweeps the components registered with it in turn.
+type sweeper struct {
+	sync.Mutex
+
+	compactors []compactor
+	// next is the component the next sweep starts at, being the one which
+	// exhausted the budget of the last sweep.
+	next int
+
+	sweeps     uint64
+	components map[string]ComponentCompaction
+}
+
+// peakSize tracks the most entries a map held since it was last rebuilt.
+type peakSize int
+
+// sparse records the entries a map held before and after a sweep, and
+// returns true should it have shrunk enough since its peak for rebuilding it
+// to be worth it, provided that copying the entries left fits in budget.
+func (p *peakSize) sparse(before, live, budget int) bool {
+	if before > int(*p) {
+		*p = peakSize(before)
+	}
+	if int(*p) < compactionMinRebuild || live*compactionRebuildRatio > int(*p) || live > budget {
+		return false
+	}
+	*p = peakSize(live)
+	return true
+}
+
+// compactMap drops the entries of a map which expired, examining at most
+// budget entries from wherever Go's map iteration happens to start, so that
+// successive sweeps cover the entire map. The map is rebuilt should it have
+// become sparse, copying the entries left counting towards the budget.
+func compactMap[K comparable, V any](m *map[K]V, peak *peakSize, budget int, expired func(key K, value V) bool) compaction {
+	var result compaction
+
+	before := len(*m)
+	for key, value := range *m {
+		if result.examined >= budget {
+			break
+		}
+		result.examined++
+
+		if expired(key, value) {
+			delete(*m, key)
+			result.reclaimed++
+		}
+	}
+	result.live = len(*m)
+
+	if peak.sparse(before, result.live, budget-result.examined) {
+		*m = rebuildMap(*m)
+		result.examined += result.live
+		result.rebuilds++
+	}
+
+	return result
+}
+
+// rebuildMap copies the entries of a map into a map sized for them alone.
+func rebuildMap[K comparable, V any](m map[K]V) map[K]V {
+	rebuilt := make(map[K]V, len(m))
+	for key, value := range m {
+		rebuilt[key] = value
+	}
+	return rebuilt
+}
+
+// registerCompactor registers a component to be swept of expired entries.
+func (n *Network) registerCompactor(name string, compact func(now time.Time, budget int) compaction) {
+	n.sweeper.compactors = append(n.sweeper.compactors, compactor{name: name, compact: compact})
+}
+
+// registerCompactors registers every component holding state which expires.
+func (n *Network) registerCompactors() {
+	n.registerCompactor(CompactAddressBook, func(now time.Time, budget int) compaction {
+		return n.candidates.compact(now, n.opts.peerBundleMaxAge, budget)
+	})
+
+	if n.opts.circuitFailures > 0 {
+		n.registerCompactor(CompactCircuits, n.compactCircuits)
+	}
+
+	if n.opts.verifyAddresses {
+		n.registerCompactor(CompactAddressResults, n.addresses.compact)
+	}
+
+	if len(n.dedupes) > 0 || n.idempotency != nil {
+		n.registerCompactor(CompactDedupe, n.compactDedupes)
+	}
+
+	if n.verifications != nil {
+		n.registerCompactor(CompactVerifications, n.verifications.compact)
+	}
+
+	n.registerCompactor(CompactTransfers, n.transfers.compact)
+}
+
+// compactionLoop sweeps internal state every CompactionInterval, as told by
+// the network's clock, once the network listens and until it is closed.
+func (n *Network) compactionLoop() {
+	select {
+	case <-n.listeningCh:
+	case <-n.kill:
+		return
+	}
+
+	for {
+		select {
+		case <-n.kill:
+			return
+		case <-n.after(n.opts.compactionInterval):
+			n.compact(n.now())
+		}
+	}
+}
+
+// compact sweeps the registered components of expired entries, examining at
+// most CompactionBudget entries across all of them so that a sweep never
+// holds any component's lock for long. Components not swept through resume
+// from where they were left off by the next sweep.
+func (n *Network) compact(now time.Time) {
+	s := &n.sweeper
+	s.Lock()
+	defer s.Unlock()
+
+	s.sweeps++
+	if s.components == nil {
+		s.components = make(map[string]ComponentCompaction, len(s.compactors))
+	}
+
+	budget := n.opts.compactionBudget
+
+	for i := 0; i < len(s.compactors) && budget > 0; i++ {
+		index := (s.next + i) % len(s.compactors)
+		c := s.compactors[index]
+
+		result := c.compact(now, budget)
+		budget -= result.examined
+
+		stats := s.components[c.name]
+		stats.Entries = result.live
+		stats.Reclaimed += uint64(result.reclaimed)
+		stats.Rebuilds += uint64(result.rebuilds)
+		s.components[c.name] = stats
+
+		if budget <= 0 {
+			s.next = index
+		}
+	}
+}
+
+// CompactionStats returns how many expired entries were swept from the
+// internal state of every component so far.
+func (n *Network) CompactionStats() CompactionStats {
+	s := &n.sweeper
+	s.Lock()
+	defer s.Unlock()
+
+	stats := CompactionStats{Sweeps: s.sweeps, Components: make(map[string]ComponentCompaction, len(s.components))}
+	for name, component := range s.components {
+		stats.Components[name] = component
+	}
+	return stats
+}
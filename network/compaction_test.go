@@ -0,0 +1,124 @@
+package network
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactionBoundedPerSweep(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, Compaction(0, 10), PeerBundleMaxAge(time.Hour))
+	defer node.Close()
+
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		node.candidates.merge(PeerRecord{PublicKey: []byte(fmt.Sprintf("stale-%d", i)), LastSeen: now.Add(-2 * time.Hour)})
+	}
+
+	// Every sweep examines no more entries than its budget.
+	for sweep := 1; sweep <= 10; sweep++ {
+		node.compact(now)
+
+		stats := node.CompactionStats().Components[CompactAddressBook]
+		assert.Equal(t, uint64(10*sweep), stats.Reclaimed)
+		assert.Equal(t, 100-10*sweep, stats.Entries)
+	}
+	assert.Equal(t, uint64(1), node.CompactionStats().Components[CompactAddressBook].Rebuilds)
+
+	// Candidates which were connected to, or seen recently, are kept.
+	node.candidates.merge(PeerRecord{PublicKey: []byte("connected"), LastSeen: now.Add(-2 * time.Hour), Connects: 1})
+	node.candidates.merge(PeerRecord{PublicKey: []byte("recent"), LastSeen: now.Add(-time.Minute)})
+
+	node.compact(now)
+	assert.Equal(t, 2, node.CompactionStats().Components[CompactAddressBook].Entries)
+	assert.Len(t, node.Candidates(), 2)
+	assert.Equal(t, uint64(11), node.CompactionStats().Sweeps)
+}
+
+func TestCompactionSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+
+	clock := &fakeClock{now: time.Now()}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {},
+		Compaction(time.Hour, 16384),
+		CircuitBreaker(1, time.Minute, 10*time.Minute),
+		VerifyAddresses(time.Second),
+		VerificationCache(1<<20, time.Minute),
+		DedupeWindow(&testpb.TestMessage{}, time.Minute, 1<<20),
+		IdempotencyWindow(time.Minute, 1<<20),
+	)
+	defer node.Close()
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return clock.Waiters() == 1 }), "sweeper never started")
+
+	var serial int
+	churn := func(now time.Time) {
+		for i := 0; i < 200; i++ {
+			serial++
+			key := []byte(fmt.Sprintf("peer-%d", serial))
+			address := fmt.Sprintf("tcp://10.0.%d.%d:3000", serial/250%250, serial%250)
+
+			node.candidates.merge(PeerRecord{PublicKey: key, Addresses: []string{address}, LastSeen: now})
+			node.recordSend(address, errors.New("failed"))
+			node.recordAddress(key, address, AddressFailed)
+			node.verifications.put(sha256.Sum256(key), &verificationResult{}, now)
+			node.idempotency.seen(string(key), now)
+			for _, set := range node.dedupes {
+				set.seen(string(key), now)
+			}
+		}
+	}
+
+	heap := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	var weekOne CompactionStats
+	var weekOneHeap uint64
+
+	// Churn peers every hour for two simulated weeks.
+	for hour := 1; hour <= 14*24; hour++ {
+		churn(clock.Now())
+		clock.Advance(time.Hour)
+
+		assert.True(t, waitUntil(3*time.Second, func() bool {
+			return node.CompactionStats().Sweeps == uint64(hour) && clock.Waiters() == 1
+		}), "sweep %d never happened", hour)
+
+		if hour == 7*24 {
+			weekOne = node.CompactionStats()
+			weekOneHeap = heap()
+		}
+	}
+
+	stats := node.CompactionStats()
+	for _, name := range []string{CompactAddressBook, CompactCircuits, CompactAddressResults, CompactDedupe, CompactVerifications} {
+		component, exists := stats.Components[name]
+		if !assert.True(t, exists, name) {
+			continue
+		}
+		assert.Equal(t, weekOne.Components[name].Entries, component.Entries, name)
+		assert.True(t, component.Reclaimed > weekOne.Components[name].Reclaimed, name)
+	}
+
+	// Candidates seen within PeerBundleMaxAge are all that is left.
+	assert.Equal(t, 24*200, stats.Components[CompactAddressBook].Entries)
+	assert.True(t, stats.Components[CompactAddressBook].Rebuilds == 0)
+	assert.True(t, stats.Components[CompactDedupe].Rebuilds > 0)
+
+	assert.True(t, heap() < weekOneHeap+8<<20, "heap grew from %d to %d bytes", weekOneHeap, heap())
+}
@@ -0,0 +1,1242 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/crypto/mldsa"
+	noop "github.com/perlin-network/noise/crypto/noop"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/pkg/errors"
+)
+
+// Duration is a time.Duration which serializes as a string such as "1m30s".
+type Duration time.Duration
+
+// MarshalJSON encodes a duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration from a string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(err, "durations must be strings such as \"1m30s\"")
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// SignatureSchemeConfig names a signature scheme being migrated to, and the
+// signature policy it signs under. Keys are generated when building.
+type SignatureSchemeConfig struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+}
+
+// DedupeWindowConfig is a serializable form of a DedupeWindow.
+type DedupeWindowConfig struct {
+	Window Duration `json:"window"`
+	Size   int      `json:"size"`
+}
+
+// ResourceBudgetConfig is a serializable form of a Budget.
+type ResourceBudgetConfig struct {
+	Goroutines int `json:"goroutines"`
+	FDs        int `json:"fds"`
+}
+
+// Config is a serializable form of every builder option which is not a
+// callback, hook, gater or plugin. Keys are never part of a config; KeyFile
+// instead references a file holding the hex-encoded private key.
+type Config struct {
+	Address    string   `json:"address"`
+	KeyFile    string   `json:"key_file,omitempty"`
+	Transports []string `json:"transports"`
+
+	SignaturePolicy string `json:"signature_policy"`
+	HashPolicy      string `json:"hash_policy"`
+
+	ConnectionTimeout Duration `json:"connection_timeout"`
+	HandshakeTimeout  Duration `json:"handshake_timeout"`
+	WriteTimeout      Duration `json:"write_timeout"`
+	WriteFlushLatency Duration `json:"write_flush_latency"`
+	WriteBufferSize   int      `json:"write_buffer_size"`
+	RecvWindowSize    int      `json:"recv_window_size"`
+	SendWindowSize    int      `json:"send_window_size"`
+
+	MaxPeers         int      `json:"max_peers"`
+	MaxInboundPeers  int      `json:"max_inbound_peers"`
+	MaxOutboundPeers int      `json:"max_outbound_peers"`
+	ReservedPeers    int      `json:"reserved_peers"`
+	PinnedPeers      []string `json:"pinned_peers"`
+	PinnedPeerIDs    []string `json:"pinned_peer_ids"`
+	ProtectedTags    []string `json:"protected_tags"`
+
+	PeerGroupMetadata string `json:"peer_group_metadata"`
+
+	ReadinessPolicy  string   `json:"readiness_policy"`
+	DialOnWrite      string   `json:"dial_on_write"`
+	RoamingPolicy    string   `json:"roaming_policy"`
+	ProtocolVersions []string `json:"protocol_versions"`
+	Capabilities     []string `json:"capabilities"`
+	SessionLifetime  Duration `json:"session_lifetime"`
+	PeerSessionLimit int      `json:"peer_session_limit"`
+
+	AffinityAddress  string   `json:"affinity_address"`
+	AffinityLifetime Duration `json:"affinity_lifetime"`
+
+	PuzzlePending       int `json:"puzzle_pending"`
+	PuzzleRate          int `json:"puzzle_rate"`
+	PuzzleDifficulty    int `json:"puzzle_difficulty"`
+	PuzzleMaxDifficulty int `json:"puzzle_max_difficulty"`
+
+	EventReplay int `json:"event_replay"`
+
+	CompactionInterval Duration `json:"compaction_interval"`
+	CompactionBudget   int      `json:"compaction_budget"`
+
+	MaxConcurrentDials int      `json:"max_concurrent_dials"`
+	WarmUpPeers        []string `json:"warm_up_peers"`
+
+	AdaptiveWrites       bool     `json:"adaptive_writes"`
+	AdaptiveWriteBase    Duration `json:"adaptive_write_base"`
+	AdaptiveWriteFloor   Duration `json:"adaptive_write_floor"`
+	AdaptiveWriteCeiling Duration `json:"adaptive_write_ceiling"`
+
+	ReadIdleTimeout Duration `json:"read_idle_timeout"`
+	ReadBodyTimeout Duration `json:"read_body_timeout"`
+	ReadBodyMinRate int      `json:"read_body_min_rate"`
+
+	BatchMessages int      `json:"batch_messages"`
+	BatchBytes    int      `json:"batch_bytes"`
+	BatchDelay    Duration `json:"batch_delay"`
+
+	ReceiveMemoryBudget int     `json:"receive_memory_budget"`
+	ReceiveWatermark    float64 `json:"receive_watermark"`
+
+	SendHighWatermark int `json:"send_high_watermark"`
+	SendLowWatermark  int `json:"send_low_watermark"`
+
+	QuarantinePeriod   Duration `json:"quarantine_period"`
+	QuarantineMessages int      `json:"quarantine_messages"`
+
+	HandlerConcurrency  map[string]int `json:"handler_concurrency"`
+	ProtocolConcurrency map[string]int `json:"protocol_concurrency"`
+	OrderedHandlers     []string       `json:"ordered_handlers"`
+
+	DedupeWindows map[string]DedupeWindowConfig `json:"dedupe_windows"`
+
+	DispatchWorkers   int `json:"dispatch_workers"`
+	DispatchQueueSize int `json:"dispatch_queue_size"`
+	SendWorkers       int `json:"send_workers"`
+
+	StatsInterval  Duration `json:"stats_interval"`
+	StatsRetention Duration `json:"stats_retention"`
+
+	Bandwidth          int      `json:"bandwidth"`
+	BandwidthBurst     int      `json:"bandwidth_burst"`
+	PeerBandwidth      int      `json:"peer_bandwidth"`
+	PeerBandwidthBurst int      `json:"peer_bandwidth_burst"`
+	ShapingExempt      []string `json:"shaping_exempt"`
+
+	PaddingBuckets []int          `json:"padding_buckets"`
+	FixedPadding   map[string]int `json:"fixed_padding"`
+
+	SplitControlPlane bool     `json:"split_control_plane"`
+	ControlMessages   []string `json:"control_messages"`
+
+	AllowLegacyPeers bool `json:"allow_legacy_peers"`
+
+	Encryption      string   `json:"encryption"`
+	RatchetRecords  int      `json:"ratchet_records"`
+	RatchetInterval Duration `json:"ratchet_interval"`
+
+	ReplayWindow        int      `json:"replay_window"`
+	RequireReplayNonces bool     `json:"require_replay_nonces"`
+	ReplayHorizon       Duration `json:"replay_horizon"`
+
+	CircuitFailures    int      `json:"circuit_failures"`
+	CircuitCooldown    Duration `json:"circuit_cooldown"`
+	CircuitMaxCooldown Duration `json:"circuit_max_cooldown"`
+
+	MaxFrameSize   int `json:"max_frame_size"`
+	MaxMessageSize int `json:"max_message_size"`
+	RejectRate     int `json:"reject_rate"`
+	RejectBurst    int `json:"reject_burst"`
+
+	ChunkSize       int      `json:"chunk_size"`
+	MaxTransferSize int      `json:"max_transfer_size"`
+	TransferTimeout Duration `json:"transfer_timeout"`
+
+	PingRate         int  `json:"ping_rate"`
+	PingBurst        int  `json:"ping_burst"`
+	PingDialOnDemand bool `json:"ping_dial_on_demand"`
+
+	ReachabilityProbes  int      `json:"reachability_probes"`
+	ReachabilityTimeout Duration `json:"reachability_timeout"`
+
+	EnforceUpgradeVersion    uint32    `json:"enforce_upgrade_version"`
+	EnforceUpgradeActivation time.Time `json:"enforce_upgrade_activation"`
+
+	PeerSamplingView     int      `json:"peer_sampling_view"`
+	PeerSamplingInterval Duration `json:"peer_sampling_interval"`
+	PeerSamplingHealing  int      `json:"peer_sampling_healing"`
+	PeerSamplingSwap     int      `json:"peer_sampling_swap"`
+
+	ShareDiagnosticsWith []string `json:"share_diagnostics_with"`
+
+	SigningForm string `json:"signing_form"`
+
+	GlobalRateLimit   int `json:"global_rate_limit"`
+	GlobalRateBurst   int `json:"global_rate_burst"`
+	PrefixRateLimit   int `json:"prefix_rate_limit"`
+	PrefixRateBurst   int `json:"prefix_rate_burst"`
+	PeerRateLimit     int `json:"peer_rate_limit"`
+	PeerRateBurst     int `json:"peer_rate_burst"`
+	RateLimitPrefixV4 int `json:"rate_limit_prefix_v4"`
+	RateLimitPrefixV6 int `json:"rate_limit_prefix_v6"`
+
+	Light     bool `json:"light"`
+	Ephemeral bool `json:"ephemeral"`
+
+	VerificationCacheSize int      `json:"verification_cache_size"`
+	VerificationCacheTTL  Duration `json:"verification_cache_ttl"`
+	VerifyAlways          []string `json:"verify_always"`
+
+	IdempotencyWindow Duration `json:"idempotency_window"`
+	IdempotencySize   int      `json:"idempotency_size"`
+
+	MaxPendingRequests int `json:"max_pending_requests"`
+	RequestLeakCheck   int `json:"request_leak_check"`
+
+	AdmissionCapacity int `json:"admission_capacity"`
+
+	AllowNetworks []string `json:"allow_networks"`
+	DenyNetworks  []string `json:"deny_networks"`
+
+	SignatureSchemes       []SignatureSchemeConfig `json:"signature_schemes"`
+	SignatureTransitionEnd time.Time               `json:"signature_transition_end"`
+	StrictSignatures       bool                    `json:"strict_signatures"`
+
+	VerifyAddresses       bool     `json:"verify_addresses"`
+	VerifyAddressInterval Duration `json:"verify_address_interval"`
+
+	ReapInterval      Duration `json:"reap_interval"`
+	ReapProbes        int      `json:"reap_probes"`
+	ReapWriteFailures int      `json:"reap_write_failures"`
+
+	WatchdogThreshold Duration `json:"watchdog_threshold"`
+
+	ResourceBudgets map[string]ResourceBudgetConfig `json:"resource_budgets"`
+
+	MirrorQueueSize int `json:"mirror_queue_size"`
+
+	PeerBundleMaxAge Duration `json:"peer_bundle_max_age"`
+
+	StormThreshold int      `json:"storm_threshold"`
+	StormWindow    Duration `json:"storm_window"`
+	StormSmear     Duration `json:"storm_smear"`
+
+	SpillDir         string `json:"spill_dir"`
+	SpillMemoryBytes int    `json:"spill_memory_bytes"`
+
+	DeadlineCeiling Duration `json:"deadline_ceiling"`
+	DeadlineFloor   Duration `json:"deadline_floor"`
+}
+
+// ConfigError lists every invalid field of a config.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	signaturePolicies = map[string]func() crypto.SignaturePolicy{
+		"ed25519":   func() crypto.SignaturePolicy { return ed25519.New() },
+		"ml-dsa-65": func() crypto.SignaturePolicy { return mldsa.New() },
+	}
+	hashPolicies = map[string]func() crypto.HashPolicy{
+		"blake2b": func() crypto.HashPolicy { return blake2b.New() },
+		"noop":    func() crypto.HashPolicy { return noop.New() },
+	}
+	transportLayers = map[string]func() transport.Layer{
+		"tcp":  func() transport.Layer { return transport.NewTCP() },
+		"kcp":  func() transport.Layer { return transport.NewKCP() },
+		"ws":   func() transport.Layer { return transport.NewWebSocket() },
+		"unix": func() transport.Layer { return transport.NewUnix() },
+	}
+	readinessPolicies = map[string]ReadinessPolicy{
+		"queue": QueueUntilReady,
+		"fail":  FailUntilReady,
+	}
+	dialPolicies = map[string]DialPolicy{
+		"never":      DialNever,
+		"on_demand":  DialOnDemand,
+		"background": DialInBackground,
+	}
+	roamingPolicies = map[string]RoamingPolicy{
+		"disabled": RoamingDisabled,
+		"migrate":  RoamingMigrate,
+	}
+	encryptionPolicies = map[string]EncryptionPolicy{
+		"disabled":  EncryptionDisabled,
+		"preferred": EncryptionPreferred,
+		"required":  EncryptionRequired,
+	}
+	signingForms = map[string]SigningForm{
+		"legacy":     SigningLegacy,
+		"negotiated": SigningNegotiated,
+		"canonical":  SigningCanonical,
+	}
+)
+
+// signaturePolicyName returns the name a signature policy is configured by,
+// or its type should it not be a built-in policy.
+func signaturePolicyName(policy crypto.SignaturePolicy) string {
+	switch policy.(type) {
+	case *ed25519.Ed25519:
+		return "ed25519"
+	case *mldsa.MLDSA:
+		return "ml-dsa-65"
+	default:
+		return fmt.Sprintf("%T", policy)
+	}
+}
+
+// hashPolicyName returns the name a hash policy is configured by, or its type
+// should it not be a built-in policy.
+func hashPolicyName(policy crypto.HashPolicy) string {
+	switch policy.(type) {
+	case *blake2b.Blake2b:
+		return "blake2b"
+	case *noop.Noop:
+		return "noop"
+	default:
+		return fmt.Sprintf("%T", policy)
+	}
+}
+
+func readinessPolicyName(policy ReadinessPolicy) string {
+	for name, p := range readinessPolicies {
+		if p == policy {
+			return name
+		}
+	}
+	return ""
+}
+
+func dialPolicyName(policy DialPolicy) string {
+	for name, p := range dialPolicies {
+		if p == policy {
+			return name
+		}
+	}
+	return ""
+}
+
+func roamingPolicyName(policy RoamingPolicy) string {
+	for name, p := range roamingPolicies {
+		if p == policy {
+			return name
+		}
+	}
+	return ""
+}
+
+func encryptionPolicyName(policy EncryptionPolicy) string {
+	for name, p := range encryptionPolicies {
+		if p == policy {
+			return name
+		}
+	}
+	return ""
+}
+
+func signingFormName(form SigningForm) string {
+	for name, f := range signingForms {
+		if f == form {
+			return name
+		}
+	}
+	return ""
+}
+
+func sortedNames(set map[string]struct{}) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks every field of a config, reporting all invalid fields at
+// once as a *ConfigError.
+func (c Config) Validate() error {
+	var problems []string
+
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := ToUnifiedAddress(c.Address); err != nil {
+		invalid("address %q is invalid: %v", c.Address, err)
+	}
+
+	if len(c.Transports) == 0 {
+		invalid("transports must not be empty")
+	}
+	for _, name := range c.Transports {
+		if _, exists := transportLayers[name]; !exists {
+			invalid("transport %q is unknown", name)
+		}
+	}
+
+	if _, exists := signaturePolicies[c.SignaturePolicy]; !exists {
+		invalid("signature_policy %q is unknown", c.SignaturePolicy)
+	}
+	if _, exists := hashPolicies[c.HashPolicy]; !exists {
+		invalid("hash_policy %q is unknown", c.HashPolicy)
+	}
+
+	positive := map[string]Duration{
+		"connection_timeout":  c.ConnectionTimeout,
+		"handshake_timeout":   c.HandshakeTimeout,
+		"write_timeout":       c.WriteTimeout,
+		"write_flush_latency": c.WriteFlushLatency,
+		"stats_interval":      c.StatsInterval,
+		"peer_bundle_max_age": c.PeerBundleMaxAge,
+		"transfer_timeout":    c.TransferTimeout,
+	}
+	nonNegative := map[string]Duration{
+		"session_lifetime":        c.SessionLifetime,
+		"affinity_lifetime":       c.AffinityLifetime,
+		"adaptive_write_base":     c.AdaptiveWriteBase,
+		"adaptive_write_floor":    c.AdaptiveWriteFloor,
+		"adaptive_write_ceiling":  c.AdaptiveWriteCeiling,
+		"read_idle_timeout":       c.ReadIdleTimeout,
+		"read_body_timeout":       c.ReadBodyTimeout,
+		"batch_delay":             c.BatchDelay,
+		"quarantine_period":       c.QuarantinePeriod,
+		"replay_horizon":          c.ReplayHorizon,
+		"ratchet_interval":        c.RatchetInterval,
+		"verification_cache_ttl":  c.VerificationCacheTTL,
+		"idempotency_window":      c.IdempotencyWindow,
+		"verify_address_interval": c.VerifyAddressInterval,
+		"reap_interval":           c.ReapInterval,
+		"storm_window":            c.StormWindow,
+		"storm_smear":             c.StormSmear,
+		"deadline_ceiling":        c.DeadlineCeiling,
+		"deadline_floor":          c.DeadlineFloor,
+		"reachability_timeout":    c.ReachabilityTimeout,
+		"peer_sampling_interval":  c.PeerSamplingInterval,
+		"circuit_cooldown":        c.CircuitCooldown,
+		"circuit_max_cooldown":    c.CircuitMaxCooldown,
+		"watchdog_threshold":      c.WatchdogThreshold,
+		"compaction_interval":     c.CompactionInterval,
+	}
+	for _, name := range sortedDurationNames(positive) {
+		if positive[name] <= 0 {
+			invalid("%s must be positive", name)
+		}
+	}
+	for _, name := range sortedDurationNames(nonNegative) {
+		if nonNegative[name] < 0 {
+			invalid("%s must not be negative", name)
+		}
+	}
+
+	if c.StatsRetention < c.StatsInterval {
+		invalid("stats_retention must be at least stats_interval")
+	}
+
+	sizes := []struct {
+		name  string
+		value int
+		min   int
+	}{
+		{"write_buffer_size", c.WriteBufferSize, 1},
+		{"recv_window_size", c.RecvWindowSize, 1},
+		{"send_window_size", c.SendWindowSize, 1},
+		{"max_peers", c.MaxPeers, 0},
+		{"max_inbound_peers", c.MaxInboundPeers, 0},
+		{"max_outbound_peers", c.MaxOutboundPeers, 0},
+		{"reserved_peers", c.ReservedPeers, 0},
+		{"max_concurrent_dials", c.MaxConcurrentDials, 0},
+		{"puzzle_pending", c.PuzzlePending, 0},
+		{"puzzle_rate", c.PuzzleRate, 0},
+		{"puzzle_difficulty", c.PuzzleDifficulty, 1},
+		{"event_replay", c.EventReplay, 0},
+		{"compaction_budget", c.CompactionBudget, 1},
+		{"peer_session_limit", c.PeerSessionLimit, 0},
+		{"read_body_min_rate", c.ReadBodyMinRate, 0},
+		{"batch_messages", c.BatchMessages, 0},
+		{"batch_bytes", c.BatchBytes, 0},
+		{"receive_memory_budget", c.ReceiveMemoryBudget, 0},
+		{"send_high_watermark", c.SendHighWatermark, 0},
+		{"send_low_watermark", c.SendLowWatermark, 0},
+		{"quarantine_messages", c.QuarantineMessages, 0},
+		{"dispatch_workers", c.DispatchWorkers, 0},
+		{"dispatch_queue_size", c.DispatchQueueSize, 0},
+		{"send_workers", c.SendWorkers, 0},
+		{"bandwidth", c.Bandwidth, 0},
+		{"bandwidth_burst", c.BandwidthBurst, 0},
+		{"peer_bandwidth", c.PeerBandwidth, 0},
+		{"peer_bandwidth_burst", c.PeerBandwidthBurst, 0},
+		{"verification_cache_size", c.VerificationCacheSize, 0},
+		{"idempotency_size", c.IdempotencySize, 0},
+		{"max_pending_requests", c.MaxPendingRequests, 1},
+		{"request_leak_check", c.RequestLeakCheck, 0},
+		{"admission_capacity", c.AdmissionCapacity, 0},
+		{"reap_probes", c.ReapProbes, 0},
+		{"reap_write_failures", c.ReapWriteFailures, 0},
+		{"storm_threshold", c.StormThreshold, 0},
+		{"spill_memory_bytes", c.SpillMemoryBytes, 0},
+		{"circuit_failures", c.CircuitFailures, 0},
+		{"max_frame_size", c.MaxFrameSize, 1},
+		{"max_message_size", c.MaxMessageSize, 0},
+		{"chunk_size", c.ChunkSize, 1},
+		{"max_transfer_size", c.MaxTransferSize, 1},
+		{"reject_rate", c.RejectRate, 0},
+		{"reject_burst", c.RejectBurst, 0},
+		{"ping_rate", c.PingRate, 0},
+		{"ping_burst", c.PingBurst, 0},
+		{"reachability_probes", c.ReachabilityProbes, 0},
+		{"peer_sampling_view", c.PeerSamplingView, 0},
+		{"peer_sampling_healing", c.PeerSamplingHealing, 0},
+		{"peer_sampling_swap", c.PeerSamplingSwap, 0},
+		{"global_rate_limit", c.GlobalRateLimit, 0},
+		{"global_rate_burst", c.GlobalRateBurst, 0},
+		{"prefix_rate_limit", c.PrefixRateLimit, 0},
+		{"prefix_rate_burst", c.PrefixRateBurst, 0},
+		{"peer_rate_limit", c.PeerRateLimit, 0},
+		{"peer_rate_burst", c.PeerRateBurst, 0},
+		{"mirror_queue_size", c.MirrorQueueSize, 0},
+		{"replay_window", c.ReplayWindow, 0},
+		{"ratchet_records", c.RatchetRecords, 0},
+	}
+	for _, size := range sizes {
+		if size.value < size.min {
+			invalid("%s must be at least %d", size.name, size.min)
+		}
+	}
+
+	if c.ReceiveWatermark < 0 || c.ReceiveWatermark > 1 {
+		invalid("receive_watermark must be between 0 and 1")
+	}
+
+	if c.SendHighWatermark > 0 && c.SendLowWatermark >= c.SendHighWatermark {
+		invalid("send_low_watermark must be below send_high_watermark")
+	}
+
+	if c.RateLimitPrefixV4 < 0 || c.RateLimitPrefixV4 > 32 {
+		invalid("rate_limit_prefix_v4 must be between 0 and 32")
+	}
+	if c.RateLimitPrefixV6 < 0 || c.RateLimitPrefixV6 > 128 {
+		invalid("rate_limit_prefix_v6 must be between 0 and 128")
+	}
+
+	for _, address := range c.PinnedPeers {
+		if _, err := ToUnifiedAddress(address); err != nil {
+			invalid("pinned peer %q is invalid: %v", address, err)
+		}
+	}
+	for _, id := range c.PinnedPeerIDs {
+		if _, err := ParsePeerID(id); err != nil {
+			invalid("pinned peer ID %q is invalid: %v", id, err)
+		}
+	}
+	for _, tag := range c.ProtectedTags {
+		if tag == "" {
+			invalid("protected_tags must not hold empty tags")
+		}
+	}
+	for _, id := range c.ShareDiagnosticsWith {
+		if _, err := ParsePeerID(id); err != nil {
+			invalid("peer ID %q to share diagnostics with is invalid: %v", id, err)
+		}
+	}
+
+	if c.PuzzleMaxDifficulty < c.PuzzleDifficulty || c.PuzzleMaxDifficulty > maxPuzzleDifficulty {
+		invalid("puzzle_max_difficulty must be between puzzle_difficulty and %d", maxPuzzleDifficulty)
+	}
+
+	if c.StormThreshold > 0 && c.StormWindow <= 0 {
+		invalid("storm_window must be positive when storm_threshold is set")
+	}
+
+	if c.CircuitFailures > 0 && c.CircuitCooldown <= 0 {
+		invalid("circuit_cooldown must be positive when circuit_failures is set")
+	}
+	if c.CircuitFailures > 0 && c.CircuitMaxCooldown < c.CircuitCooldown {
+		invalid("circuit_max_cooldown must be at least circuit_cooldown")
+	}
+
+	if _, exists := readinessPolicies[c.ReadinessPolicy]; !exists {
+		invalid("readiness_policy %q is unknown", c.ReadinessPolicy)
+	}
+	if _, exists := dialPolicies[c.DialOnWrite]; !exists {
+		invalid("dial_on_write %q is unknown", c.DialOnWrite)
+	}
+	if _, exists := roamingPolicies[c.RoamingPolicy]; !exists {
+		invalid("roaming_policy %q is unknown", c.RoamingPolicy)
+	}
+	if _, exists := encryptionPolicies[c.Encryption]; !exists {
+		invalid("encryption %q is unknown", c.Encryption)
+	}
+	if c.Encryption == "required" && c.AllowLegacyPeers {
+		invalid("allow_legacy_peers may not be set when encryption is required")
+	}
+	if c.RequireReplayNonces && c.ReplayWindow == 0 {
+		invalid("require_replay_nonces may only be set with a replay_window")
+	}
+	if c.RequireReplayNonces && c.AllowLegacyPeers {
+		invalid("allow_legacy_peers may not be set when replay nonces are required")
+	}
+	if _, exists := signingForms[c.SigningForm]; !exists {
+		invalid("signing_form %q is unknown", c.SigningForm)
+	}
+
+	if len(c.ProtocolVersions) == 0 {
+		invalid("protocol_versions must not be empty")
+	}
+
+	for name, limit := range c.HandlerConcurrency {
+		if limit < 1 {
+			invalid("handler_concurrency of %s must be at least 1", name)
+		}
+	}
+
+	for tag, limit := range c.ProtocolConcurrency {
+		if limit < 1 {
+			invalid("protocol_concurrency of %s must be at least 1", tag)
+		}
+	}
+
+	if c.MaxMessageSize > c.MaxFrameSize {
+		invalid("max_message_size must be at most max_frame_size")
+	}
+
+	if c.ChunkSize > c.MaxFrameSize/2 {
+		invalid("chunk_size must be at most half of max_frame_size")
+	}
+
+	if err := checkPadding(c.PaddingBuckets, c.FixedPadding, c.MaxFrameSize); err != nil {
+		invalid("%v", err)
+	}
+
+	for name, window := range c.DedupeWindows {
+		if window.Window <= 0 {
+			invalid("dedupe_windows window of %s must be positive", name)
+		}
+		if window.Size < 1 {
+			invalid("dedupe_windows size of %s must be at least 1", name)
+		}
+	}
+
+	for _, cidr := range append(append([]string(nil), c.AllowNetworks...), c.DenyNetworks...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			invalid("network %q is invalid", cidr)
+		}
+	}
+
+	for subsystem, budget := range c.ResourceBudgets {
+		if !containsString(subsystems, subsystem) {
+			invalid("resource_budgets subsystem %q is unknown", subsystem)
+		}
+		if budget.Goroutines < 0 || budget.FDs < 0 {
+			invalid("resource_budgets of %s must not be negative", subsystem)
+		}
+	}
+
+	for _, scheme := range c.SignatureSchemes {
+		if len(scheme.Name) == 0 || scheme.Name == PrimarySignatureScheme {
+			invalid("signature scheme name %q is invalid", scheme.Name)
+		}
+		if _, exists := signaturePolicies[scheme.Policy]; !exists {
+			invalid("signature scheme %q has unknown policy %q", scheme.Name, scheme.Policy)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func sortedDurationNames(durations map[string]Duration) []string {
+	names := make([]string, 0, len(durations))
+	for name := range durations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseConfig decodes a config from JSON. Fields this version does not know
+// of are warned about and ignored, so that configs exported by newer versions
+// still load.
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, errors.Wrap(err, "failed to parse config")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Config{}, errors.Wrap(err, "failed to parse config")
+	}
+
+	known := make(map[string]struct{})
+	t := reflect.TypeOf(cfg)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		known[name] = struct{}{}
+	}
+
+	for name := range fields {
+		if _, exists := known[name]; !exists {
+			glog.Warningf("ignoring unknown config field %q", name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// NewBuilderFromConfig returns a builder configured by a config, failing
+// should any of its fields be invalid.
+func NewBuilderFromConfig(cfg Config) (*Builder, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	builder := NewBuilder()
+	builder.SetAddress(cfg.Address)
+
+	builder.ClearTransportLayers()
+	for _, name := range cfg.Transports {
+		builder.RegisterTransportLayer(name, transportLayers[name]())
+	}
+
+	o := &builder.opts
+
+	o.signaturePolicy = signaturePolicies[cfg.SignaturePolicy]()
+	o.hashPolicy = hashPolicies[cfg.HashPolicy]()
+
+	if len(cfg.KeyFile) > 0 {
+		contents, err := ioutil.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read key file")
+		}
+
+		keys, err := crypto.FromPrivateKey(o.signaturePolicy, strings.TrimSpace(string(contents)))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load keys from key file")
+		}
+		builder.SetKeys(keys)
+	} else {
+		builder.SetKeys(o.signaturePolicy.RandomKeyPair())
+	}
+
+	o.connectionTimeout = time.Duration(cfg.ConnectionTimeout)
+	o.handshakeTimeout = time.Duration(cfg.HandshakeTimeout)
+	o.writeTimeout = time.Duration(cfg.WriteTimeout)
+	o.writeFlushLatency = time.Duration(cfg.WriteFlushLatency)
+	o.writeBufferSize = cfg.WriteBufferSize
+	o.recvWindowSize = cfg.RecvWindowSize
+	o.sendWindowSize = cfg.SendWindowSize
+
+	o.maxPeers = cfg.MaxPeers
+	o.maxInboundPeers = cfg.MaxInboundPeers
+	o.maxOutboundPeers = cfg.MaxOutboundPeers
+	o.reservedPeers = cfg.ReservedPeers
+	o.pinnedPeers = append([]string(nil), cfg.PinnedPeers...)
+	for _, id := range cfg.PinnedPeerIDs {
+		parsed, _ := ParsePeerID(id)
+		o.pinnedPeerIDs = append(o.pinnedPeerIDs, parsed)
+	}
+	o.protectedTags = append([]string(nil), cfg.ProtectedTags...)
+	o.groupMetadataKey = cfg.PeerGroupMetadata
+
+	o.readinessPolicy = readinessPolicies[cfg.ReadinessPolicy]
+	o.dialOnWrite = dialPolicies[cfg.DialOnWrite]
+	o.roamingPolicy = roamingPolicies[cfg.RoamingPolicy]
+	o.signingForm = signingForms[cfg.SigningForm]
+	o.protocolVersions = append([]string(nil), cfg.ProtocolVersions...)
+	o.capabilities = append([]string(nil), cfg.Capabilities...)
+	o.sessionLifetime = time.Duration(cfg.SessionLifetime)
+	o.peerSessionLimit = cfg.PeerSessionLimit
+
+	o.affinityAddress = cfg.AffinityAddress
+	o.affinityLifetime = time.Duration(cfg.AffinityLifetime)
+
+	o.puzzlePending = cfg.PuzzlePending
+	o.puzzleRate = cfg.PuzzleRate
+	o.puzzleDifficulty = cfg.PuzzleDifficulty
+	o.puzzleMaxDifficulty = cfg.PuzzleMaxDifficulty
+
+	o.eventReplaySize = cfg.EventReplay
+
+	o.compactionInterval = time.Duration(cfg.CompactionInterval)
+	o.compactionBudget = cfg.CompactionBudget
+
+	o.maxDials = cfg.MaxConcurrentDials
+	o.warmUpPeers = append([]string(nil), cfg.WarmUpPeers...)
+
+	o.adaptiveWrites = cfg.AdaptiveWrites
+	o.adaptiveWriteBase = time.Duration(cfg.AdaptiveWriteBase)
+	o.adaptiveWriteFloor = time.Duration(cfg.AdaptiveWriteFloor)
+	o.adaptiveWriteCeiling = time.Duration(cfg.AdaptiveWriteCeiling)
+
+	o.readIdleTimeout = time.Duration(cfg.ReadIdleTimeout)
+	o.readBodyTimeout = time.Duration(cfg.ReadBodyTimeout)
+	o.readBodyMinRate = cfg.ReadBodyMinRate
+
+	o.batchMessages = cfg.BatchMessages
+	o.batchBytes = cfg.BatchBytes
+	o.batchDelay = time.Duration(cfg.BatchDelay)
+
+	o.receiveMemoryBudget = cfg.ReceiveMemoryBudget
+	o.receiveWatermark = cfg.ReceiveWatermark
+
+	o.sendHighWatermark = cfg.SendHighWatermark
+	o.sendLowWatermark = cfg.SendLowWatermark
+
+	o.quarantinePeriod = time.Duration(cfg.QuarantinePeriod)
+	o.quarantineMessages = cfg.QuarantineMessages
+
+	o.handlerConcurrency = nil
+	for name, limit := range cfg.HandlerConcurrency {
+		if o.handlerConcurrency == nil {
+			o.handlerConcurrency = make(map[string]int)
+		}
+		o.handlerConcurrency[name] = limit
+	}
+
+	o.protocolConcurrency = nil
+	for tag, limit := range cfg.ProtocolConcurrency {
+		if o.protocolConcurrency == nil {
+			o.protocolConcurrency = make(map[string]int)
+		}
+		o.protocolConcurrency[tag] = limit
+	}
+
+	o.orderedHandlers = nil
+	for _, name := range cfg.OrderedHandlers {
+		if o.orderedHandlers == nil {
+			o.orderedHandlers = make(map[string]struct{})
+		}
+		o.orderedHandlers[name] = struct{}{}
+	}
+
+	o.dedupeWindows = nil
+	for name, window := range cfg.DedupeWindows {
+		if o.dedupeWindows == nil {
+			o.dedupeWindows = make(map[string]dedupeWindow)
+		}
+		o.dedupeWindows[name] = dedupeWindow{window: time.Duration(window.Window), size: window.Size}
+	}
+
+	o.dispatchWorkers = cfg.DispatchWorkers
+	o.dispatchQueueSize = cfg.DispatchQueueSize
+	o.sendWorkers = cfg.SendWorkers
+
+	o.statsInterval = time.Duration(cfg.StatsInterval)
+	o.statsRetention = time.Duration(cfg.StatsRetention)
+
+	o.bandwidth = cfg.Bandwidth
+	o.bandwidthBurst = cfg.BandwidthBurst
+	o.peerBandwidth = cfg.PeerBandwidth
+	o.peerBandwidthBurst = cfg.PeerBandwidthBurst
+	o.shapingExempt = append([]string(nil), cfg.ShapingExempt...)
+
+	o.paddingBuckets = append([]int(nil), cfg.PaddingBuckets...)
+	o.paddingFixed = nil
+	for name, size := range cfg.FixedPadding {
+		if o.paddingFixed == nil {
+			o.paddingFixed = make(map[string]int)
+		}
+		o.paddingFixed[name] = size
+	}
+
+	o.splitControlPlane = cfg.SplitControlPlane
+	o.allowLegacyPeers = cfg.AllowLegacyPeers
+	o.encryption = encryptionPolicies[cfg.Encryption]
+	o.ratchetRecords = cfg.RatchetRecords
+	o.ratchetInterval = time.Duration(cfg.RatchetInterval)
+	o.replayWindow = cfg.ReplayWindow
+	o.requireReplayNonces = cfg.RequireReplayNonces
+	o.replayHorizon = time.Duration(cfg.ReplayHorizon)
+	o.controlMessages = append([]string(nil), cfg.ControlMessages...)
+
+	o.circuitFailures = cfg.CircuitFailures
+	o.circuitCooldown = time.Duration(cfg.CircuitCooldown)
+	o.circuitMaxCooldown = time.Duration(cfg.CircuitMaxCooldown)
+
+	o.maxFrameSize = cfg.MaxFrameSize
+	o.maxMessageSize = cfg.MaxMessageSize
+	o.rejectRate = cfg.RejectRate
+	o.rejectBurst = cfg.RejectBurst
+
+	o.chunkSize = cfg.ChunkSize
+	o.maxTransferSize = cfg.MaxTransferSize
+	o.transferTimeout = time.Duration(cfg.TransferTimeout)
+
+	o.pingRate = cfg.PingRate
+	o.pingBurst = cfg.PingBurst
+	o.pingDialOnDemand = cfg.PingDialOnDemand
+
+	o.reachabilityProbes = cfg.ReachabilityProbes
+	o.reachabilityTimeout = time.Duration(cfg.ReachabilityTimeout)
+
+	o.enforceUpgradeVersion = cfg.EnforceUpgradeVersion
+	o.enforceUpgradeActivation = cfg.EnforceUpgradeActivation
+
+	o.peerSamplingView = cfg.PeerSamplingView
+	o.peerSamplingInterval = time.Duration(cfg.PeerSamplingInterval)
+	o.peerSamplingHealing = cfg.PeerSamplingHealing
+	o.peerSamplingSwap = cfg.PeerSamplingSwap
+	for _, id := range cfg.ShareDiagnosticsWith {
+		parsed, _ := ParsePeerID(id)
+		o.shareDiagnostics = append(o.shareDiagnostics, parsed)
+	}
+
+	o.rateLimits = RateLimits{
+		Global:   RateLimit{Rate: cfg.GlobalRateLimit, Burst: cfg.GlobalRateBurst},
+		Prefix:   RateLimit{Rate: cfg.PrefixRateLimit, Burst: cfg.PrefixRateBurst},
+		Peer:     RateLimit{Rate: cfg.PeerRateLimit, Burst: cfg.PeerRateBurst},
+		PrefixV4: cfg.RateLimitPrefixV4,
+		PrefixV6: cfg.RateLimitPrefixV6,
+	}
+
+	o.light = cfg.Light
+	o.ephemeral = cfg.Ephemeral
+
+	o.verificationCacheSize = cfg.VerificationCacheSize
+	o.verificationCacheTTL = time.Duration(cfg.VerificationCacheTTL)
+
+	o.idempotencyWindow = time.Duration(cfg.IdempotencyWindow)
+	o.idempotencySize = cfg.IdempotencySize
+
+	o.maxPendingRequests = cfg.MaxPendingRequests
+	o.requestLeakFactor = cfg.RequestLeakCheck
+	o.admissionCapacity = cfg.AdmissionCapacity
+
+	o.verifyAlways = nil
+	for _, name := range cfg.VerifyAlways {
+		if o.verifyAlways == nil {
+			o.verifyAlways = make(map[string]struct{})
+		}
+		o.verifyAlways[name] = struct{}{}
+	}
+
+	o.allowNetworks = append([]string(nil), cfg.AllowNetworks...)
+	o.denyNetworks = append([]string(nil), cfg.DenyNetworks...)
+
+	o.signatureSchemes = nil
+	for _, scheme := range cfg.SignatureSchemes {
+		o.signatureSchemes = append(o.signatureSchemes, SignatureScheme{
+			Name:   scheme.Name,
+			Policy: signaturePolicies[scheme.Policy](),
+		})
+	}
+	o.signatureTransitionEnd = cfg.SignatureTransitionEnd
+	o.strictSignatures = cfg.StrictSignatures
+
+	o.verifyAddresses = cfg.VerifyAddresses
+	o.verifyAddressInterval = time.Duration(cfg.VerifyAddressInterval)
+
+	o.reapInterval = time.Duration(cfg.ReapInterval)
+	o.reapProbes = cfg.ReapProbes
+	o.reapWriteFailures = cfg.ReapWriteFailures
+
+	o.watchdogThreshold = time.Duration(cfg.WatchdogThreshold)
+
+	o.resourceBudgets = nil
+	for subsystem, budget := range cfg.ResourceBudgets {
+		if o.resourceBudgets == nil {
+			o.resourceBudgets = make(map[string]Budget)
+		}
+		o.resourceBudgets[subsystem] = Budget{Goroutines: budget.Goroutines, FDs: budget.FDs}
+	}
+
+	o.mirrorQueueSize = cfg.MirrorQueueSize
+
+	o.peerBundleMaxAge = time.Duration(cfg.PeerBundleMaxAge)
+
+	o.stormThreshold = cfg.StormThreshold
+	o.stormWindow = time.Duration(cfg.StormWindow)
+	o.stormSmear = time.Duration(cfg.StormSmear)
+
+	o.spillDir = cfg.SpillDir
+	o.spillMemoryBytes = cfg.SpillMemoryBytes
+
+	o.deadlineCeiling = time.Duration(cfg.DeadlineCeiling)
+	o.deadlineFloor = time.Duration(cfg.DeadlineFloor)
+
+	return builder, nil
+}
+
+// Config returns the effective config of a builder, including defaults.
+// Callbacks, hooks, gaters, plugins and keys are not part of it.
+func (builder *Builder) Config() Config {
+	o := builder.opts
+	rateLimits := o.rateLimits.withDefaults()
+
+	cfg := Config{
+		Address: builder.address,
+
+		SignaturePolicy: signaturePolicyName(o.signaturePolicy),
+		HashPolicy:      hashPolicyName(o.hashPolicy),
+
+		ConnectionTimeout: Duration(o.connectionTimeout),
+		HandshakeTimeout:  Duration(o.handshakeTimeout),
+		WriteTimeout:      Duration(o.writeTimeout),
+		WriteFlushLatency: Duration(o.writeFlushLatency),
+		WriteBufferSize:   o.writeBufferSize,
+		RecvWindowSize:    o.recvWindowSize,
+		SendWindowSize:    o.sendWindowSize,
+
+		MaxPeers:         o.maxPeers,
+		MaxInboundPeers:  o.maxInboundPeers,
+		MaxOutboundPeers: o.maxOutboundPeers,
+		ReservedPeers:    o.reservedPeers,
+		PinnedPeers:      append([]string{}, o.pinnedPeers...),
+		PinnedPeerIDs:    []string{},
+		ProtectedTags:    append([]string{}, o.protectedTags...),
+
+		PeerGroupMetadata: o.groupMetadataKey,
+
+		ReadinessPolicy:  readinessPolicyName(o.readinessPolicy),
+		DialOnWrite:      dialPolicyName(o.dialOnWrite),
+		RoamingPolicy:    roamingPolicyName(o.roamingPolicy),
+		ProtocolVersions: append([]string{}, o.protocolVersions...),
+		Capabilities:     append([]string{}, o.capabilities...),
+		SessionLifetime:  Duration(o.sessionLifetime),
+		PeerSessionLimit: o.peerSessionLimit,
+
+		AffinityAddress:  o.affinityAddress,
+		AffinityLifetime: Duration(o.affinityLifetime),
+
+		PuzzlePending:       o.puzzlePending,
+		PuzzleRate:          o.puzzleRate,
+		PuzzleDifficulty:    o.puzzleDifficulty,
+		PuzzleMaxDifficulty: o.puzzleMaxDifficulty,
+
+		EventReplay: o.eventReplaySize,
+
+		CompactionInterval: Duration(o.compactionInterval),
+		CompactionBudget:   o.compactionBudget,
+
+		MaxConcurrentDials: o.maxDials,
+		WarmUpPeers:        append([]string{}, o.warmUpPeers...),
+
+		AdaptiveWrites:       o.adaptiveWrites,
+		AdaptiveWriteBase:    Duration(o.adaptiveWriteBase),
+		AdaptiveWriteFloor:   Duration(o.adaptiveWriteFloor),
+		AdaptiveWriteCeiling: Duration(o.adaptiveWriteCeiling),
+
+		ReadIdleTimeout: Duration(o.readIdleTimeout),
+		ReadBodyTimeout: Duration(o.readBodyTimeout),
+		ReadBodyMinRate: o.readBodyMinRate,
+
+		BatchMessages: o.batchMessages,
+		BatchBytes:    o.batchBytes,
+		BatchDelay:    Duration(o.batchDelay),
+
+		ReceiveMemoryBudget: o.receiveMemoryBudget,
+		ReceiveWatermark:    o.receiveWatermark,
+
+		SendHighWatermark: o.sendHighWatermark,
+		SendLowWatermark:  o.sendLowWatermark,
+
+		QuarantinePeriod:   Duration(o.quarantinePeriod),
+		QuarantineMessages: o.quarantineMessages,
+
+		HandlerConcurrency:  make(map[string]int, len(o.handlerConcurrency)),
+		ProtocolConcurrency: make(map[string]int, len(o.protocolConcurrency)),
+		OrderedHandlers:     sortedNames(o.orderedHandlers),
+
+		DedupeWindows: make(map[string]DedupeWindowConfig, len(o.dedupeWindows)),
+
+		DispatchWorkers:   o.dispatchWorkers,
+		DispatchQueueSize: o.dispatchQueueSize,
+		SendWorkers:       o.sendWorkers,
+
+		StatsInterval:  Duration(o.statsInterval),
+		StatsRetention: Duration(o.statsRetention),
+
+		Bandwidth:          o.bandwidth,
+		BandwidthBurst:     o.bandwidthBurst,
+		PeerBandwidth:      o.peerBandwidth,
+		PeerBandwidthBurst: o.peerBandwidthBurst,
+		ShapingExempt:      append([]string{}, o.shapingExempt...),
+
+		PaddingBuckets: append([]int{}, o.paddingBuckets...),
+		FixedPadding:   make(map[string]int, len(o.paddingFixed)),
+
+		SplitControlPlane: o.splitControlPlane,
+		ControlMessages:   append([]string{}, o.controlMessages...),
+
+		AllowLegacyPeers: o.allowLegacyPeers,
+
+		Encryption:      encryptionPolicyName(o.encryption),
+		RatchetRecords:  o.ratchetRecords,
+		RatchetInterval: Duration(o.ratchetInterval),
+
+		ReplayWindow:        o.replayWindow,
+		RequireReplayNonces: o.requireReplayNonces,
+		ReplayHorizon:       Duration(o.replayHorizon),
+
+		CircuitFailures:    o.circuitFailures,
+		CircuitCooldown:    Duration(o.circuitCooldown),
+		CircuitMaxCooldown: Duration(o.circuitMaxCooldown),
+
+		MaxFrameSize:   o.maxFrameSize,
+		MaxMessageSize: o.maxMessageSize,
+		RejectRate:     o.rejectRate,
+		RejectBurst:    o.rejectBurst,
+
+		ChunkSize:       o.chunkSize,
+		MaxTransferSize: o.maxTransferSize,
+		TransferTimeout: Duration(o.transferTimeout),
+
+		PingRate:         o.pingRate,
+		PingBurst:        o.pingBurst,
+		PingDialOnDemand: o.pingDialOnDemand,
+
+		ReachabilityProbes:  o.reachabilityProbes,
+		ReachabilityTimeout: Duration(o.reachabilityTimeout),
+
+		EnforceUpgradeVersion:    o.enforceUpgradeVersion,
+		EnforceUpgradeActivation: o.enforceUpgradeActivation,
+
+		PeerSamplingView:     o.peerSamplingView,
+		PeerSamplingInterval: Duration(o.peerSamplingInterval),
+		PeerSamplingHealing:  o.peerSamplingHealing,
+		PeerSamplingSwap:     o.peerSamplingSwap,
+
+		ShareDiagnosticsWith: []string{},
+
+		SigningForm: signingFormName(o.signingForm),
+
+		GlobalRateLimit:   rateLimits.Global.Rate,
+		GlobalRateBurst:   rateLimits.Global.Burst,
+		PrefixRateLimit:   rateLimits.Prefix.Rate,
+		PrefixRateBurst:   rateLimits.Prefix.Burst,
+		PeerRateLimit:     rateLimits.Peer.Rate,
+		PeerRateBurst:     rateLimits.Peer.Burst,
+		RateLimitPrefixV4: rateLimits.PrefixV4,
+		RateLimitPrefixV6: rateLimits.PrefixV6,
+
+		Light:     o.light,
+		Ephemeral: o.ephemeral,
+
+		VerificationCacheSize: o.verificationCacheSize,
+		VerificationCacheTTL:  Duration(o.verificationCacheTTL),
+		VerifyAlways:          sortedNames(o.verifyAlways),
+
+		IdempotencyWindow: Duration(o.idempotencyWindow),
+		IdempotencySize:   o.idempotencySize,
+
+		MaxPendingRequests: o.maxPendingRequests,
+		RequestLeakCheck:   o.requestLeakFactor,
+
+		AdmissionCapacity: o.admissionCapacity,
+
+		AllowNetworks: append([]string{}, o.allowNetworks...),
+		DenyNetworks:  append([]string{}, o.denyNetworks...),
+
+		SignatureSchemes:       []SignatureSchemeConfig{},
+		SignatureTransitionEnd: o.signatureTransitionEnd,
+		StrictSignatures:       o.strictSignatures,
+
+		VerifyAddresses:       o.verifyAddresses,
+		VerifyAddressInterval: Duration(o.verifyAddressInterval),
+
+		ReapInterval:      Duration(o.reapInterval),
+		ReapProbes:        o.reapProbes,
+		ReapWriteFailures: o.reapWriteFailures,
+
+		WatchdogThreshold: Duration(o.watchdogThreshold),
+
+		ResourceBudgets: make(map[string]ResourceBudgetConfig, len(o.resourceBudgets)),
+
+		MirrorQueueSize: o.mirrorQueueSize,
+
+		PeerBundleMaxAge: Duration(o.peerBundleMaxAge),
+
+		StormThreshold: o.stormThreshold,
+		StormWindow:    Duration(o.stormWindow),
+		StormSmear:     Duration(o.stormSmear),
+
+		SpillDir:         o.spillDir,
+		SpillMemoryBytes: o.spillMemoryBytes,
+
+		DeadlineCeiling: Duration(o.deadlineCeiling),
+		DeadlineFloor:   Duration(o.deadlineFloor),
+	}
+
+	builder.transports.Range(func(key, value interface{}) bool {
+		cfg.Transports = append(cfg.Transports, key.(string))
+		return true
+	})
+	sort.Strings(cfg.Transports)
+
+	for _, id := range o.pinnedPeerIDs {
+		cfg.PinnedPeerIDs = append(cfg.PinnedPeerIDs, id.String())
+	}
+	for _, id := range o.shareDiagnostics {
+		cfg.ShareDiagnosticsWith = append(cfg.ShareDiagnosticsWith, id.String())
+	}
+
+	for name, limit := range o.handlerConcurrency {
+		cfg.HandlerConcurrency[name] = limit
+	}
+
+	for tag, limit := range o.protocolConcurrency {
+		cfg.ProtocolConcurrency[tag] = limit
+	}
+
+	for name, window := range o.dedupeWindows {
+		cfg.DedupeWindows[name] = DedupeWindowConfig{Window: Duration(window.window), Size: window.size}
+	}
+
+	for name, size := range o.paddingFixed {
+		cfg.FixedPadding[name] = size
+	}
+
+	for subsystem, budget := range o.resourceBudgets {
+		cfg.ResourceBudgets[subsystem] = ResourceBudgetConfig{Goroutines: budget.Goroutines, FDs: budget.FDs}
+	}
+
+	for _, scheme := range o.signatureSchemes {
+		cfg.SignatureSchemes = append(cfg.SignatureSchemes, SignatureSchemeConfig{
+			Name:   scheme.Name,
+			Policy: signaturePolicyName(scheme.Policy),
+		})
+	}
+
+	return cfg
+}
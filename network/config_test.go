@@ -0,0 +1,215 @@
+package network
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/crypto/mldsa"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func TestConfigGolden(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(
+		QuarantinePeriod(time.Minute),
+		HandlerConcurrency(&testpb.TestMessage{}, 4),
+		DedupeWindow(&testpb.TestMessage{}, time.Minute, 1024),
+		VerifyAlways(&testpb.TestMessage{}),
+		DenyNetworks("10.0.0.0/8"),
+		BandwidthLimit(1024*1024, 64*1024),
+		SignatureTransition(SignatureScheme{Name: "next", Policy: ed25519.New()}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	)
+
+	exported, err := json.MarshalIndent(builder.Config(), "", "  ")
+	assert.Nil(t, err)
+
+	golden := filepath.Join("testdata", "config.golden.json")
+	if *updateGolden {
+		assert.Nil(t, ioutil.WriteFile(golden, append(exported, '\n'), 0644))
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(exported)+"\n")
+
+	// Loading and exporting the golden config again yields the same bytes.
+	cfg, err := ParseConfig(expected)
+	assert.Nil(t, err)
+
+	loaded, err := NewBuilderFromConfig(cfg)
+	assert.Nil(t, err)
+
+	reexported, err := json.MarshalIndent(loaded.Config(), "", "  ")
+	assert.Nil(t, err)
+	assert.Equal(t, string(exported), string(reexported))
+}
+
+func TestConfigValidateReportsEveryField(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewBuilder().Config()
+	cfg.Transports = []string{"carrier-pigeon"}
+	cfg.HashPolicy = "md5"
+	cfg.WriteTimeout = 0
+	cfg.SendWindowSize = -1
+	cfg.ReceiveWatermark = 2
+	cfg.ReadinessPolicy = "eventually"
+	cfg.DenyNetworks = []string{"not a network"}
+
+	_, err := NewBuilderFromConfig(cfg)
+
+	configErr, ok := err.(*ConfigError)
+	if assert.True(t, ok, "expected a *ConfigError, got %v", err) {
+		assert.Equal(t, 7, len(configErr.Problems), "%v", configErr.Problems)
+	}
+
+	assert.Nil(t, NewBuilder().Config().Validate(), "default configs should be valid")
+}
+
+func TestConfigIgnoresUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	exported, err := json.Marshal(NewBuilder().Config())
+	assert.Nil(t, err)
+
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(exported, &fields))
+	fields["teleport_timeout"] = "5s"
+	fields["write_timeout"] = "7s"
+
+	withUnknown, err := json.Marshal(fields)
+	assert.Nil(t, err)
+
+	cfg, err := ParseConfig(withUnknown)
+	assert.Nil(t, err)
+	assert.Equal(t, Duration(7*time.Second), cfg.WriteTimeout)
+	assert.Nil(t, cfg.Validate())
+
+	_, err = ParseConfig([]byte(`{"write_timeout": 7}`))
+	assert.NotNil(t, err, "durations must be strings")
+}
+
+func TestConfigKeyFile(t *testing.T) {
+	t.Parallel()
+
+	keys := ed25519.RandomKeyPair()
+
+	dir, err := ioutil.TempDir("", "noise-config")
+	assert.Nil(t, err)
+
+	path := filepath.Join(dir, "key")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(keys.PrivateKeyHex()+"\n"), 0600))
+
+	cfg := NewBuilder().Config()
+	cfg.KeyFile = path
+
+	builder, err := NewBuilderFromConfig(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, keys.PublicKey, builder.keys.PublicKey)
+
+	exported, err := json.Marshal(builder.Config())
+	assert.Nil(t, err)
+	assert.NotContains(t, string(exported), keys.PrivateKeyHex(), "keys must never be exported")
+}
+
+func TestConfigSignaturePolicy(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewBuilder().Config()
+	cfg.SignaturePolicy = "ml-dsa-65"
+
+	builder, err := NewBuilderFromConfig(cfg)
+	assert.Nil(t, err)
+	assert.Len(t, builder.keys.PublicKey, mldsa.PublicKeySize, "keys should be generated under the configured policy")
+	assert.Equal(t, "ml-dsa-65", builder.Config().SignaturePolicy)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	node.Close()
+}
+
+// configBehavior is what a network built from a config was observed doing.
+type configBehavior struct {
+	handshakeTimedOut bool
+	handshakeWithin   bool
+	quarantined       []bool
+}
+
+func observeConfig(t *testing.T, cfg Config) configBehavior {
+	var behavior configBehavior
+
+	cfg.Address = FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort()))
+
+	builder, err := NewBuilderFromConfig(cfg)
+	assert.Nil(t, err)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+	node.after = clock.After
+
+	go node.Listen()
+	<-node.Ready()
+
+	// Peers which never complete their handshake are cut off in time.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer silent.Close()
+
+	start := time.Now()
+	_, err = node.Dial("tcp://" + silent.Addr().String())
+	elapsed := time.Since(start)
+
+	behavior.handshakeTimedOut = errors.Cause(err) == ErrHandshakeTimeout
+	behavior.handshakeWithin = elapsed >= time.Duration(cfg.HandshakeTimeout) && elapsed < 2*time.Duration(cfg.HandshakeTimeout)
+
+	// New peers serve quarantine.
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	client, err := peer.Client(node.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	waitForPeers(t, node, 1)
+
+	for _, step := range []time.Duration{0, 59 * time.Second, time.Second} {
+		clock.Advance(step)
+		// Peers graduate in the background once their quarantine elapses.
+		waitUntil(500*time.Millisecond, func() bool { return !node.Peers()[0].Quarantined })
+		behavior.quarantined = append(behavior.quarantined, node.Peers()[0].Quarantined)
+	}
+
+	return behavior
+}
+
+func TestConfigDifferential(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(QuarantinePeriod(time.Minute))
+	builder.opts.handshakeTimeout = 300 * time.Millisecond
+
+	exported, err := json.Marshal(builder.Config())
+	assert.Nil(t, err)
+
+	cfg, err := ParseConfig(exported)
+	assert.Nil(t, err)
+
+	first, second := observeConfig(t, cfg), observeConfig(t, cfg)
+
+	assert.Equal(t, configBehavior{handshakeTimedOut: true, handshakeWithin: true, quarantined: []bool{true, true, false}}, first)
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,149 @@
+package network
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// ControlPlaneCapability is advertised by nodes which accept a second
+// connection from their peers reserved for control messages.
+const ControlPlaneCapability = "noise/control"
+
+// ControlPlaneStats counts peers and messages on the control plane.
+type ControlPlaneStats struct {
+	// Peers is the number of connected peers sent control messages over a
+	// connection of their own.
+	Peers int
+	// Sent is the number of control messages sent over control connections.
+	Sent uint64
+	// Received is the number of messages received over control connections.
+	Received uint64
+}
+
+// ControlPlaneStats returns how many peers are sent control messages over a
+// connection of their own, and how many messages were exchanged over them.
+func (n *Network) ControlPlaneStats() ControlPlaneStats {
+	stats := ControlPlaneStats{
+		Sent:     atomic.LoadUint64(&n.controlSent),
+		Received: atomic.LoadUint64(&n.controlReceived),
+	}
+
+	n.eachPeer(func(client *PeerClient) bool {
+		if state, ok := n.ConnectionState(client.Address); ok && state.control != nil {
+			stats.Peers++
+		}
+		return true
+	})
+
+	return stats
+}
+
+// controlMessageNames returns the names of messages sent over control
+// connections. Pings, pongs, keepalives, node lookups, service record
+// refreshes, rejections, reachability probes, upgrade announcements, shuffles
+// and diagnostics always are, so that liveness checks and routing table
+// maintenance never queue up behind data.
+func controlMessageNames(names []string) map[string]struct{} {
+	control := map[string]struct{}{
+		proto.MessageName(&protobuf.Ping{}): {},
+		proto.MessageName(&protobuf.Pong{}): {},
+
+		proto.MessageName(&protobuf.Keepalive{}):    {},
+		proto.MessageName(&protobuf.KeepaliveAck{}): {},
+
+		proto.MessageName(&protobuf.LookupNodeRequest{}):  {},
+		proto.MessageName(&protobuf.LookupNodeResponse{}): {},
+
+		proto.MessageName(&protobuf.ServiceRecords{}): {},
+		proto.MessageName(&protobuf.Rejection{}):      {},
+
+		proto.MessageName(&protobuf.ReachabilityProbe{}):  {},
+		proto.MessageName(&protobuf.ReachabilityResult{}): {},
+
+		proto.MessageName(&protobuf.SignedUpgradeAnnouncement{}): {},
+
+		proto.MessageName(&protobuf.ShuffleRequest{}):  {},
+		proto.MessageName(&protobuf.ShuffleResponse{}): {},
+
+		proto.MessageName(&protobuf.DiagnosticsRequest{}): {},
+		proto.MessageName(&protobuf.DiagnosticsReport{}):  {},
+	}
+	for _, name := range names {
+		control[name] = struct{}{}
+	}
+	return control
+}
+
+// isControlMessage returns true if a message is sent over control connections.
+func (n *Network) isControlMessage(message *protobuf.Message) bool {
+	if message.Message == nil {
+		return false
+	}
+
+	name, err := types.AnyMessageName(message.Message)
+	if err != nil {
+		return false
+	}
+
+	_, control := n.controlMessages[name]
+	return control
+}
+
+// splitsControlPlane returns true if control messages are to be sent to a
+// peer over a connection of their own, given the offer it handshook with.
+func (n *Network) splitsControlPlane(offer *protobuf.HandshakeOffer) bool {
+	return n.opts.splitControlPlane && offer != nil && containsString(offer.Capabilities, ControlPlaneCapability)
+}
+
+// dialControl opens a control connection to a peer we are connected to.
+func (n *Network) dialControl(address string) (*ConnState, error) {
+	conn, _, err := n.dialWith(address, func(conn net.Conn) (*handshakeResult, error) {
+		return n.handshakeDialer(conn, address, true, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConnState{
+		conn:        conn,
+		writer:      bufio.NewWriter(conn),
+		writerMutex: new(sync.Mutex),
+	}, nil
+}
+
+// writeControl writes a control message over a peer's control connection.
+// Control messages bypass bandwidth limits, and are flushed out right away
+// rather than being buffered up alongside other messages.
+func (n *Network) writeControl(address string, state *ConnState, message *protobuf.Message) error {
+	message.MessageNonce = atomic.AddUint64(&state.messageNonce, 1)
+
+	state.conn.SetWriteDeadline(time.Now().Add(n.writeTimeout(address, message.Size()+4)))
+
+	frame, err := n.sendMessage(state.writer, message, state.writerMutex, state.flow, nil)
+	if err == nil {
+		state.writerMutex.Lock()
+		err = errors.Wrap(state.writer.Flush(), "failed to flush control message")
+		state.writerMutex.Unlock()
+	}
+
+	n.markWritten(address, err)
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&n.controlSent, 1)
+
+	n.tailMessage(DirectionOutbound, address, message, message.Size()+4, true)
+	n.captureOutbound(address, frame)
+	n.mirrorOutbound(address, message, frame)
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlPlaneStaysResponsiveUnderSaturation(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate     = 256 * 1024
+		messages = 3
+		interval = 250 * time.Millisecond
+	)
+
+	opts := []BuilderOption{SplitControlPlane(), ReapUnresponsive(interval, 2, 0), WriteTimeout(10 * time.Second)}
+
+	received := make(chan int, messages)
+	receiver, _, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		switch msg := ctx.Message().(type) {
+		case *protobuf.Ping:
+			ctx.Reply(&protobuf.Pong{})
+		case *testpb.TestMessage:
+			received <- len(msg.Message)
+		}
+	}, opts...)
+	defer receiver.Close()
+
+	// Every connection the sender dials is throttled.
+	link := &slowLink{TCP: transport.NewTCP(), rate: rate}
+	sender := buildNodeOverLink(t, link, opts...)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	// Saturate the data plane for a few seconds.
+	payload := strings.Repeat("x", rate)
+
+	var wg sync.WaitGroup
+	for i := 0; i < messages; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, client.Tell(&testpb.TestMessage{Message: payload}))
+		}()
+	}
+
+	// Pings sent over the control plane do not queue up behind data.
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		start := time.Now()
+		_, err := client.Request(&rpc.Request{Message: &protobuf.Ping{}, Timeout: 3 * time.Second})
+		assert.Nil(t, err)
+
+		rtt := time.Since(start)
+		assert.True(t, rtt < interval, "control round trip took %s", rtt)
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Once pings stop, the receiver sends the sender keepalives, which are
+	// acknowledged over the control plane before the sender is judged dead.
+	for i := 0; i < messages; i++ {
+		select {
+		case size := <-received:
+			assert.Equal(t, rate, size)
+		case <-time.After(10 * time.Second):
+			t.Fatal("saturating messages were not delivered")
+		}
+	}
+	wg.Wait()
+
+	assert.False(t, client.isClosed(), "receiver was disconnected: %s", client.DisconnectReason())
+
+	senderID, err := PeerIDFromPublicKey(sender.ID.PublicKey)
+	assert.Nil(t, err)
+
+	peer, ok := receiver.PeerByID(senderID)
+	if assert.True(t, ok) {
+		assert.False(t, peer.isClosed(), "sender was disconnected: %s", peer.DisconnectReason())
+
+		rtt := peer.liveness.lastRTT()
+		assert.True(t, rtt > 0 && rtt < interval, "keepalive round trip took %s", rtt)
+	}
+
+	assert.Equal(t, 1, sender.ControlPlaneStats().Peers)
+	assert.Equal(t, 1, receiver.ControlPlaneStats().Peers)
+	assert.True(t, receiver.ControlPlaneStats().Received >= 10)
+}
+
+func TestControlPlaneFallsBackToSingleConnection(t *testing.T) {
+	t.Parallel()
+
+	pongs := make(chan struct{}, 1)
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		if _, ok := ctx.Message().(*protobuf.Ping); ok {
+			ctx.Reply(&protobuf.Pong{})
+		}
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	// A peer not splitting its control plane is sent control messages over
+	// the data connection.
+	split := buildListeningNode(t, SplitControlPlane())
+	defer split.Close()
+
+	client, err := split.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	go func() {
+		if _, err := client.Request(&rpc.Request{Message: &protobuf.Ping{}, Timeout: 3 * time.Second}); err == nil {
+			pongs <- struct{}{}
+		}
+	}()
+
+	select {
+	case <-pongs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping sent to a peer not splitting its control plane went unanswered")
+	}
+
+	assert.Equal(t, ControlPlaneStats{}, split.ControlPlaneStats())
+	assert.Equal(t, ControlPlaneStats{}, receiver.ControlPlaneStats())
+}
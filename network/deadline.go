@@ -0,0 +1,93 @@
+package network
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDeadlineCeiling is the longest budget requests embed for the
+// receiver's handlers, regardless of their timeout.
+const defaultDeadlineCeiling = time.Minute
+
+// DeadlineStats counts replies skipped as the requester already gave up on
+// them.
+type DeadlineStats struct {
+	// SkippedReplies is the number of replies not sent as their request's
+	// deadline had passed.
+	SkippedReplies uint64
+}
+
+// requestBudget returns the budget, in milliseconds, a request sent with a
+// timeout embeds for the receiver's handlers, being zero without a timeout.
+func (n *Network) requestBudget(timeout time.Duration) uint64 {
+	if timeout <= 0 {
+		return 0
+	}
+
+	if n.opts.deadlineCeiling > 0 && timeout > n.opts.deadlineCeiling {
+		timeout = n.opts.deadlineCeiling
+	}
+
+	if timeout < time.Millisecond {
+		return 1
+	}
+	return uint64(timeout / time.Millisecond)
+}
+
+// handlerDeadline returns the deadline handlers of a request received from a
+// peer with a budget are given, being zero should it have none. Budgets are
+// relative so as not to depend on the clocks of both peers agreeing, and half
+// the latest round trip measured to the peer is taken off them for the time
+// the request spent in transit.
+func (n *Network) handlerDeadline(client *PeerClient, budgetMs uint64) time.Time {
+	if budgetMs == 0 {
+		return time.Time{}
+	}
+
+	budget := time.Duration(budgetMs)*time.Millisecond - client.liveness.lastRTT()/2
+	if budget < n.opts.deadlineFloor {
+		budget = n.opts.deadlineFloor
+	}
+
+	return n.now().Add(budget)
+}
+
+// expired returns true, counting a skipped reply, should the requester of a
+// message have given up on a reply to it.
+func (n *Network) expired(deadline time.Time) bool {
+	if deadline.IsZero() || n.now().Before(deadline) {
+		return false
+	}
+
+	atomic.AddUint64(&n.skippedReplies, 1)
+	return true
+}
+
+// Context returns the context handlers of the message should run under. It
+// expires once the requester stops waiting for a reply should the message be
+// a request sent with a timeout, and is the background context otherwise.
+func (ctx *PluginContext) Context() context.Context {
+	ctx.own.check()
+	if ctx.deadline.IsZero() {
+		return context.Background()
+	}
+
+	if ctx.handlerCtx == nil {
+		ctx.handlerCtx, ctx.cancel = context.WithDeadline(context.Background(), ctx.deadline)
+	}
+	return ctx.handlerCtx
+}
+
+// Deadline returns when the requester stops waiting for a reply to the
+// message, and false should it not be a request sent with a timeout.
+func (ctx *PluginContext) Deadline() (time.Time, bool) {
+	ctx.own.check()
+	return ctx.deadline, !ctx.deadline.IsZero()
+}
+
+// DeadlineStats returns how many replies were skipped as their request's
+// deadline had passed.
+func (n *Network) DeadlineStats() DeadlineStats {
+	return DeadlineStats{SkippedReplies: atomic.LoadUint64(&n.skippedReplies)}
+}
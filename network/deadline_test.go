@@ -0,0 +1,97 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildClockedNode builds a listening node on a fake clock, running fn on
+// every test message received.
+func buildClockedNode(t *testing.T, clock *fakeClock, fn func(ctx *PluginContext), opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: fn})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	node.now = clock.Now
+	node.after = clock.After
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestRequestDeadlinePropagatesToHandlers(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	deadlines := make(chan time.Time, 4)
+	receiver := buildClockedNode(t, clock, func(ctx *PluginContext) {
+		if _, ok := ctx.Message().(*testpb.TestMessage); !ok {
+			return
+		}
+
+		deadline, _ := ctx.Context().Deadline()
+		deadlines <- deadline
+		ctx.Reply(&testpb.TestMessage{Message: "reply"})
+	})
+	defer receiver.Close()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	_, err = client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: "request"}, Timeout: 2 * time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Now().Add(2*time.Second), <-deadlines)
+
+	// Budgets are capped by the sender.
+	_, err = client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: "request"}, Timeout: 2 * time.Minute})
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Now().Add(time.Minute), <-deadlines)
+
+	// Messages which are not requests run under the background context.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "tell"}))
+	assert.True(t, (<-deadlines).IsZero())
+
+	assert.Equal(t, uint64(0), receiver.DeadlineStats().SkippedReplies)
+}
+
+func TestExpiredRequestIsNotReplied(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	handled := make(chan error, 1)
+	receiver := buildClockedNode(t, clock, func(ctx *PluginContext) {
+		if _, ok := ctx.Message().(*testpb.TestMessage); !ok {
+			return
+		}
+
+		// The handler takes longer than the requester is willing to wait.
+		clock.Advance(time.Second)
+		handled <- ctx.Reply(&testpb.TestMessage{Message: "reply"})
+	})
+	defer receiver.Close()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	_, err = client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: "request"}, Timeout: 200 * time.Millisecond})
+	assert.NotNil(t, err)
+
+	assert.Nil(t, <-handled)
+	assert.Equal(t, uint64(1), receiver.DeadlineStats().SkippedReplies)
+}
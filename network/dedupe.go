@@ -0,0 +1,199 @@
+package network
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// DedupeStats counts messages dropped for being copies of messages of the
+// same type delivered recently.
+type DedupeStats struct {
+	// Duplicates is the number of messages dropped as duplicates, including
+	// retries of requests handled already.
+	Duplicates uint64
+	// Remembered is the number of payload hashes currently remembered
+	// across all message types, and of idempotency keys of requests.
+	Remembered int
+}
+
+// dedupeWindow is how long, and how many, payload hashes are remembered for
+// a message type.
+type dedupeWindow struct {
+	window time.Duration
+	size   int
+}
+
+type dedupeEntry struct {
+	key     string
+	expires time.Time
+}
+
+// dedupeSet remembers the payload hashes of the messages of a type delivered
+// recently, evicting the oldest beyond size entries, and entries older than
+// window.
+type dedupeSet struct {
+	sync.Mutex
+
+	window time.Duration
+	size   int
+
+	order   *list.List
+	entries map[string]*list.Element
+	peak    peakSize
+}
+
+func newDedupeSet(window time.Duration, size int) *dedupeSet {
+	return &dedupeSet{
+		window:  window,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// seen remembers a payload hash, and returns true should it have been seen
+// within the window. Duplicates do not extend the window of the first
+// occurrence.
+func (s *dedupeSet) seen(key string, now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	// Entries are ordered by when they expire, oldest at the back.
+	for s.order.Len() > 0 {
+		oldest := s.order.Back().Value.(*dedupeEntry)
+		if now.Before(oldest.expires) {
+			break
+		}
+		s.order.Remove(s.order.Back())
+		delete(s.entries, oldest.key)
+	}
+
+	if _, exists := s.entries[key]; exists {
+		return true
+	}
+
+	for s.order.Len() >= s.size {
+		evicted := s.order.Remove(s.order.Back()).(*dedupeEntry)
+		delete(s.entries, evicted.key)
+	}
+
+	s.entries[key] = s.order.PushFront(&dedupeEntry{key: key, expires: now.Add(s.window)})
+	return false
+}
+
+// contains returns true should a payload hash have been seen within the
+// window, without remembering it otherwise.
+func (s *dedupeSet) contains(key string, now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	element, exists := s.entries[key]
+	return exists && now.Before(element.Value.(*dedupeEntry).expires)
+}
+
+// compact forgets the payload hashes remembered for longer than the window,
+// examining at most budget of them, oldest first.
+func (s *dedupeSet) compact(now time.Time, budget int) compaction {
+	s.Lock()
+	defer s.Unlock()
+
+	var result compaction
+
+	before := len(s.entries)
+	for s.order.Len() > 0 && result.examined < budget {
+		result.examined++
+
+		oldest := s.order.Back().Value.(*dedupeEntry)
+		if now.Before(oldest.expires) {
+			break
+		}
+		s.order.Remove(s.order.Back())
+		delete(s.entries, oldest.key)
+		result.reclaimed++
+	}
+	result.live = len(s.entries)
+
+	if s.peak.sparse(before, result.live, budget-result.examined) {
+		s.entries = rebuildMap(s.entries)
+		result.examined += result.live
+		result.rebuilds++
+	}
+
+	return result
+}
+
+func (s *dedupeSet) flush() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.order.Init()
+	s.entries = make(map[string]*list.Element)
+}
+
+// isDuplicate returns true should a message be a copy of a message of the same
+// type delivered within the type's DedupeWindow.
+func (n *Network) isDuplicate(name string, payload []byte) bool {
+	set, exists := n.dedupes[name]
+	if !exists {
+		return false
+	}
+
+	if !set.seen(string(n.opts.hashPolicy.HashBytes(payload)), n.now()) {
+		return false
+	}
+
+	atomic.AddUint64(&n.duplicates, 1)
+	return true
+}
+
+// compactDedupes forgets the payload hashes of messages and idempotency keys
+// of requests remembered for longer than their windows, examining at most
+// budget of them across every message type.
+func (n *Network) compactDedupes(now time.Time, budget int) compaction {
+	var result compaction
+
+	for _, set := range n.dedupes {
+		result.add(set.compact(now, budget-result.examined))
+	}
+	if n.idempotency != nil {
+		result.add(n.idempotency.compact(now, budget-result.examined))
+	}
+
+	return result
+}
+
+// FlushDedupe forgets every message of the same type as message delivered so
+// far, under any protocol tag, so that copies of them are delivered again.
+func (n *Network) FlushDedupe(message proto.Message) {
+	name := proto.MessageName(message)
+
+	for qualified, set := range n.dedupes {
+		if qualified == name || strings.HasSuffix(qualified, "/"+name) {
+			set.flush()
+		}
+	}
+}
+
+// DedupeStats returns how many messages were dropped as duplicates.
+func (n *Network) DedupeStats() DedupeStats {
+	stats := DedupeStats{Duplicates: atomic.LoadUint64(&n.duplicates)}
+
+	for _, set := range n.dedupes {
+		set.Lock()
+		stats.Remembered += len(set.entries)
+		set.Unlock()
+	}
+
+	if n.idempotency != nil {
+		n.idempotency.Lock()
+		stats.Remembered += len(n.idempotency.entries)
+		n.idempotency.Unlock()
+	}
+
+	return stats
+}
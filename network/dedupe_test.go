@@ -0,0 +1,80 @@
+package network
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupeWindowDeliversFirstOccurrences(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	var delivered int32
+	receiver := buildClockedNode(t, clock, func(ctx *PluginContext) {
+		if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+			atomic.AddInt32(&delivered, 1)
+		}
+	}, DedupeWindow(&testpb.TestMessage{}, time.Minute, 16))
+	defer receiver.Close()
+
+	var senders []*Network
+	for i := 0; i < 2; i++ {
+		sender := buildListeningNode(t)
+		defer sender.Close()
+		senders = append(senders, sender)
+	}
+
+	// tell sends a message from a sender, and waits until the receiver
+	// delivered or dropped as many messages as expected.
+	tell := func(sender *Network, message string, delivers int32, duplicates uint64) {
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: message}))
+
+		assert.True(t, waitUntil(3*time.Second, func() bool {
+			return atomic.LoadInt32(&delivered) == delivers && receiver.DedupeStats().Duplicates == duplicates
+		}), "delivered %d and dropped %d messages", atomic.LoadInt32(&delivered), receiver.DedupeStats().Duplicates)
+	}
+
+	// The same payload from two different peers is delivered once.
+	tell(senders[0], "block", 1, 0)
+	tell(senders[1], "block", 1, 1)
+
+	// A different payload is delivered.
+	tell(senders[1], "attestation", 2, 1)
+
+	// Copies are delivered again once the window passed since the first
+	// occurrence.
+	clock.Advance(time.Minute)
+	tell(senders[0], "block", 3, 1)
+	tell(senders[1], "block", 3, 2)
+
+	// Flushing forgets all payloads delivered so far. The expired payload
+	// was forgotten already.
+	assert.Equal(t, 1, receiver.DedupeStats().Remembered)
+	receiver.FlushDedupe(&testpb.TestMessage{})
+	assert.Equal(t, 0, receiver.DedupeStats().Remembered)
+	tell(senders[0], "block", 4, 2)
+}
+
+func TestDedupeSetIsBounded(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	set := newDedupeSet(time.Hour, 2)
+
+	assert.False(t, set.seen("a", now))
+	assert.False(t, set.seen("b", now))
+	assert.True(t, set.seen("a", now))
+
+	// The oldest payload is forgotten past the size of the set.
+	assert.False(t, set.seen("c", now))
+	assert.Len(t, set.entries, 2)
+	assert.False(t, set.seen("a", now))
+	assert.True(t, set.seen("c", now))
+}
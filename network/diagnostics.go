@@ -0,0 +1,147 @@
+package network
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultDiagnosticsTimeout is how long diagnostics requested under a
+	// context without a deadline wait for their report.
+	defaultDiagnosticsTimeout = 5 * time.Second
+
+	// diagnosticsRate and diagnosticsBurst bound how many diagnostics requests
+	// of every peer are answered every second.
+	diagnosticsRate  = 1
+	diagnosticsBurst = 2
+)
+
+// ErrDiagnosticsRefused is returned when a peer refuses to share diagnostics
+// with us, as it does not share them with us at all or as we requested them
+// too often.
+var ErrDiagnosticsRefused = errors.New("network: peer refused to share diagnostics")
+
+var diagnosticsRequestName = proto.MessageName((*protobuf.DiagnosticsRequest)(nil))
+
+// PeerDiagnostics is what a peer measured of its session with us, as reported
+// through RequestPeerDiagnostics. Sent and received counts are from the peer's
+// point of view.
+type PeerDiagnostics struct {
+	// RTT is the smoothed round trip the peer measured to us, being zero
+	// should it have measured none.
+	RTT time.Duration
+	// ObservedAddress is the address the peer last saw us connect to it from,
+	// being empty should we never have dialed it.
+	ObservedAddress string
+	// Traffic counts the messages the peer sent to and received from us.
+	Traffic MessageCounts
+	// Violations is how many times the peer reported us for misbehaving.
+	Violations uint64
+	// Quarantined is true if the peer still has us in quarantine.
+	Quarantined bool
+	// ClockOffset is how far ahead of our clock the peer's clock is,
+	// estimated assuming the round trip of the request was symmetric.
+	ClockOffset time.Duration
+}
+
+// RequestPeerDiagnostics asks a peer we are connected to for what it measured
+// of its session with us, failing with ErrDiagnosticsRefused unless the peer
+// shares diagnostics with us through ShareDiagnostics. Requests wait for up
+// to 5 seconds under a context without a deadline.
+func (n *Network) RequestPeerDiagnostics(ctx context.Context, address string) (PeerDiagnostics, error) {
+	client, exists := n.peers.Load(address)
+	if !exists {
+		return PeerDiagnostics{}, errors.Wrapf(ErrNotConnected, "failed to request diagnostics of %s", address)
+	}
+
+	start := n.now()
+
+	signed, err := n.prepareMessage("", &protobuf.DiagnosticsRequest{SentAt: start.UnixNano()})
+	if err != nil {
+		return PeerDiagnostics{}, err
+	}
+
+	timeout := defaultDiagnosticsTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(start)
+	}
+
+	r := client.(*PeerClient).trackRequest()
+	defer r.close()
+
+	res, err := r.attempt(ctx, signed, timeout)
+	if err != nil {
+		return PeerDiagnostics{}, errors.Wrapf(err, "failed to request diagnostics of %s", address)
+	}
+	rtt := n.now().Sub(start)
+
+	report, ok := res.(*protobuf.DiagnosticsReport)
+	if !ok {
+		return PeerDiagnostics{}, errors.Errorf("network: %s answered diagnostics with a %T", address, res)
+	}
+	if report.Refused {
+		return PeerDiagnostics{}, errors.Wrapf(ErrDiagnosticsRefused, "failed to request diagnostics of %s", address)
+	}
+
+	return PeerDiagnostics{
+		RTT:             time.Duration(report.Rtt),
+		ObservedAddress: report.ObservedAddress,
+		Traffic: MessageCounts{
+			Sent:          report.MessagesSent,
+			Received:      report.MessagesReceived,
+			BytesSent:     report.BytesSent,
+			BytesReceived: report.BytesReceived,
+		},
+		Violations:  report.Violations,
+		Quarantined: report.Quarantined,
+		ClockOffset: time.Unix(0, report.Time).Sub(start.Add(rtt / 2)),
+	}, nil
+}
+
+// handleDiagnosticsRequest answers diagnostics requested by a peer with what
+// we measured of our session with it, returning true if a message was a
+// diagnostics request. Peers we do not share diagnostics with, and peers
+// requesting them too often, are refused.
+func (n *Network) handleDiagnosticsRequest(client *PeerClient, name string, message *protobuf.Message) bool {
+	if name != diagnosticsRequestName || message.RequestNonce == 0 || message.ReplyFlag {
+		return false
+	}
+
+	report := &protobuf.DiagnosticsReport{Refused: true}
+
+	if n.sharesDiagnosticsWith(client.PeerID()) && client.diagnostics.take(time.Now()) {
+		info := client.info()
+
+		report = &protobuf.DiagnosticsReport{
+			Rtt:              int64(info.RTT),
+			ObservedAddress:  info.Source,
+			MessagesSent:     info.Traffic.Sent,
+			MessagesReceived: info.Traffic.Received,
+			BytesSent:        info.Traffic.BytesSent,
+			BytesReceived:    info.Traffic.BytesReceived,
+			Violations:       info.Violations,
+			Quarantined:      info.Quarantined,
+			Time:             n.now().UnixNano(),
+		}
+	}
+
+	if err := client.Reply(message.RequestNonce, report); err != nil {
+		glog.Warningf("failed to answer diagnostics request from %s: %v", client.Address, err)
+	}
+	return true
+}
+
+// sharesDiagnosticsWith returns true if diagnostics are shared with a peer.
+func (n *Network) sharesDiagnosticsWith(id PeerID) bool {
+	for _, allowed := range n.opts.shareDiagnostics {
+		if allowed == id {
+			return true
+		}
+	}
+	return false
+}
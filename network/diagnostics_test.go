@@ -0,0 +1,126 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildSharingNode(t *testing.T, keys *crypto.KeyPair, share ...*crypto.KeyPair) *Network {
+	var ids []PeerID
+	for _, peer := range share {
+		id, err := PeerIDFromPublicKey(peer.PublicKey)
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	builder := NewBuilderWithOptions(ShareDiagnostics(ids...))
+	builder.SetKeys(keys)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	node, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+// assertSameTraffic checks that what a node counted of its session with a peer
+// matches what the peer reported, but for the messages which were in flight.
+func assertSameTraffic(t *testing.T, local MessageCounts, reported MessageCounts) {
+	const messages, bytes = 2, 1024
+
+	within := func(a, b, tolerance uint64) bool {
+		return a <= b+tolerance && b <= a+tolerance
+	}
+
+	assert.True(t, within(local.Sent, reported.Received, messages), "sent %d, peer received %d", local.Sent, reported.Received)
+	assert.True(t, within(local.Received, reported.Sent, messages), "received %d, peer sent %d", local.Received, reported.Sent)
+	assert.True(t, within(local.BytesSent, reported.BytesReceived, bytes), "sent %d bytes, peer received %d", local.BytesSent, reported.BytesReceived)
+	assert.True(t, within(local.BytesReceived, reported.BytesSent, bytes), "received %d bytes, peer sent %d", local.BytesReceived, reported.BytesSent)
+}
+
+func TestPeerDiagnosticsMatchLocalCounters(t *testing.T) {
+	t.Parallel()
+
+	aliceKeys, bobKeys := ed25519.RandomKeyPair(), ed25519.RandomKeyPair()
+
+	alice := buildSharingNode(t, aliceKeys, bobKeys)
+	defer alice.Close()
+
+	bob := buildSharingNode(t, bobKeys, aliceKeys)
+	defer bob.Close()
+
+	toBob, err := alice.Client(bob.Address)
+	assert.Nil(t, err)
+	toAlice, err := bob.Client(alice.Address)
+	assert.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, toBob.Tell(&testpb.TestMessage{Message: "to bob"}))
+	}
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, toAlice.Tell(&testpb.TestMessage{Message: "to alice"}))
+	}
+
+	ctx := context.Background()
+
+	_, err = alice.Ping(ctx, bob.Address)
+	assert.Nil(t, err)
+	_, err = bob.Ping(ctx, alice.Address)
+	assert.Nil(t, err)
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return toBob.Info().Traffic.Received >= 11 && toAlice.Info().Traffic.Received >= 21
+	}))
+
+	fromBob, err := alice.RequestPeerDiagnostics(ctx, bob.Address)
+	assert.Nil(t, err)
+	assertSameTraffic(t, toBob.Info().Traffic, fromBob.Traffic)
+
+	fromAlice, err := bob.RequestPeerDiagnostics(ctx, alice.Address)
+	assert.Nil(t, err)
+	assertSameTraffic(t, toAlice.Info().Traffic, fromAlice.Traffic)
+
+	for _, report := range []PeerDiagnostics{fromBob, fromAlice} {
+		assert.True(t, report.RTT > 0)
+		assert.NotEmpty(t, report.ObservedAddress)
+		assert.Zero(t, report.Violations)
+		assert.True(t, report.ClockOffset < time.Second && report.ClockOffset > -time.Second)
+	}
+
+	// Requests beyond the burst are refused.
+	_, err = alice.RequestPeerDiagnostics(ctx, bob.Address)
+	assert.Nil(t, err)
+	_, err = alice.RequestPeerDiagnostics(ctx, bob.Address)
+	assert.Equal(t, ErrDiagnosticsRefused, errors.Cause(err))
+}
+
+func TestPeerDiagnosticsRequireAllowList(t *testing.T) {
+	t.Parallel()
+
+	bob := buildSharingNode(t, ed25519.RandomKeyPair(), ed25519.RandomKeyPair())
+	defer bob.Close()
+
+	stranger := buildListeningNode(t)
+	defer stranger.Close()
+
+	_, err := stranger.RequestPeerDiagnostics(context.Background(), bob.Address)
+	assert.Equal(t, ErrNotConnected, errors.Cause(err))
+
+	_, err = stranger.Client(bob.Address)
+	assert.Nil(t, err)
+
+	_, err = stranger.RequestPeerDiagnostics(context.Background(), bob.Address)
+	assert.Equal(t, ErrDiagnosticsRefused, errors.Cause(err))
+}
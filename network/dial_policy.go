@@ -0,0 +1,149 @@
+package network
+
+import (
+	"context"
+	"sync"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// DialPolicy decides what happens to writes to addresses we are not
+// connected to.
+type DialPolicy int
+
+const (
+	// DialNever fails writes to peers we are not connected to with
+	// ErrNotConnected.
+	DialNever DialPolicy = iota
+	// DialOnDemand dials peers we are not connected to, sending once
+	// connected. Dialing counts against the context of the write, which
+	// gives up on the dial once it is done, though the dial carries on for
+	// writes after it.
+	DialOnDemand
+	// DialInBackground fails writes to peers we are not connected to with
+	// ErrNotConnected, though dials them in the background so that writes
+	// after them succeed.
+	DialInBackground
+)
+
+// WriteOption configures a message written through WriteContext, WriteAsync
+// or SendAsync.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	dial     DialPolicy
+	priority Priority
+}
+
+func newWriteOptions(n *Network, opts []WriteOption) writeOptions {
+	o := writeOptions{dial: n.opts.dialOnWrite, priority: PriorityNormal}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithDialPolicy returns a WriteOption that overrides the policy set through
+// DialOnWrite for a single write.
+func WithDialPolicy(policy DialPolicy) WriteOption {
+	return func(o *writeOptions) {
+		o.dial = policy
+	}
+}
+
+// pendingDial is a dial in progress shared by all writes waiting on it.
+type pendingDial struct {
+	done chan struct{}
+	err  error
+}
+
+// writeDials holds the dials in progress on behalf of writes, by address, so
+// that concurrent writes to the same address dial it only once.
+type writeDials struct {
+	sync.Mutex
+	pending map[string]*pendingDial
+}
+
+// WriteContext sends a message to a denoted target address, dialing it
+// should we not be connected to it as decided by the policy set through
+// DialOnWrite, unless overridden. Writes give up once ctx is done, and may be
+// shed by admission control.
+func (n *Network) WriteContext(ctx context.Context, address string, message *protobuf.Message, opts ...WriteOption) error {
+	o := newWriteOptions(n, opts)
+
+	if o.dial != DialNever && !n.ConnectionStateExists(address) && !n.isClosed() {
+		unified, err := ToUnifiedAddress(address)
+		if err != nil {
+			return err
+		}
+		address = unified
+
+		if !n.ConnectionStateExists(address) {
+			if err := n.dialForWrite(ctx, address, o.dial); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrapf(err, "failed to write to %s", address)
+	}
+
+	if err := n.admit(address, o.priority); err != nil {
+		return err
+	}
+
+	return n.write(address, message)
+}
+
+// dialForWrite dials an address we are not connected to on behalf of a
+// write, as decided by a dial policy.
+func (n *Network) dialForWrite(ctx context.Context, address string, policy DialPolicy) error {
+	switch policy {
+	case DialOnDemand:
+		dial := n.dialShared(address)
+
+		select {
+		case <-dial.done:
+			return dial.err
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "gave up on dialing %s", address)
+		}
+	case DialInBackground:
+		n.dialShared(address)
+	}
+
+	return errors.Wrapf(ErrNotConnected, "failed to write to %s", address)
+}
+
+// dialShared dials an address in the background, unless a dial to it on
+// behalf of another write is in progress already, returning the dial.
+func (n *Network) dialShared(address string) *pendingDial {
+	n.writeDials.Lock()
+	defer n.writeDials.Unlock()
+
+	if dial, exists := n.writeDials.pending[address]; exists {
+		return dial
+	}
+
+	if n.writeDials.pending == nil {
+		n.writeDials.pending = make(map[string]*pendingDial)
+	}
+
+	dial := &pendingDial{done: make(chan struct{})}
+	n.writeDials.pending[address] = dial
+
+	n.spawn(func() {
+		// Failures to dial are reported by client itself.
+		_, dial.err = n.Client(address)
+
+		n.writeDials.Lock()
+		delete(n.writeDials.pending, address)
+		n.writeDials.Unlock()
+
+		close(dial.done)
+	})
+
+	return dial
+}
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildCountingNode builds a listening node counting the test messages it
+// receives.
+func buildCountingNode(t *testing.T, received *int32) *Network {
+	builder := NewBuilder()
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+			atomic.AddInt32(received, 1)
+		}
+	}})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func signedTestMessage(t *testing.T, n *Network, text string) *protobuf.Message {
+	signed, err := n.prepareMessage("", &testpb.TestMessage{Message: text})
+	assert.Nil(t, err)
+	return signed
+}
+
+func TestWriteNeverDials(t *testing.T) {
+	t.Parallel()
+
+	var received int32
+	peer := buildCountingNode(t, &received)
+	defer peer.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	err := node.Write(peer.Address, signedTestMessage(t, node, "cold"))
+	assert.Equal(t, ErrNotConnected, errors.Cause(err))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, node.Peers())
+	assert.Empty(t, peer.Peers())
+}
+
+func TestWriteDialsInBackground(t *testing.T) {
+	t.Parallel()
+
+	var received int32
+	peer := buildCountingNode(t, &received)
+	defer peer.Close()
+
+	node := buildListeningNode(t, DialOnWrite(DialInBackground))
+	defer node.Close()
+
+	// The first write fails fast, though has the peer dialed for the next.
+	err := node.Write(peer.Address, signedTestMessage(t, node, "cold"))
+	assert.Equal(t, ErrNotConnected, errors.Cause(err))
+
+	waitForPeers(t, node, 1)
+
+	assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "warm")))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return atomic.LoadInt32(&received) == 1 }))
+}
+
+func TestConcurrentWritesDialOnce(t *testing.T) {
+	t.Parallel()
+
+	var received int32
+	peer := buildCountingNode(t, &received)
+	defer peer.Close()
+
+	node := buildListeningNode(t, DialOnWrite(DialOnDemand))
+	defer node.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	const writes = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writes)
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- node.WriteContext(ctx, peer.Address, signedTestMessage(t, node, "cold"))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return atomic.LoadInt32(&received) == writes }))
+	assert.Len(t, node.Peers(), 1)
+	assert.Len(t, peer.Peers(), 1)
+}
+
+func TestWriteDialCountsAgainstContext(t *testing.T) {
+	t.Parallel()
+
+	// A peer which takes connections but never completes a handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	node := buildListeningNode(t, HandshakeTimeout(500*time.Millisecond))
+	defer node.Close()
+
+	address := "tcp://" + listener.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = node.WriteContext(ctx, address, signedTestMessage(t, node, "stalled"), WithDialPolicy(DialOnDemand))
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.True(t, time.Since(start) < time.Second, "write waited %s on the dial", time.Since(start))
+
+	// Writes overriding the policy fail right away, as the dial carries on.
+	err = node.WriteContext(context.Background(), address, signedTestMessage(t, node, "stalled"), WithDialPolicy(DialNever))
+	assert.Equal(t, ErrNotConnected, errors.Cause(err))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		node.writeDials.Lock()
+		defer node.writeDials.Unlock()
+		return len(node.writeDials.pending) == 0
+	}), "dial never gave up")
+}
@@ -0,0 +1,203 @@
+package network
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// AddressStatus describes whether a peer was proven to be reachable at the
+// address it advertises.
+type AddressStatus int
+
+const (
+	// AddressUnverified marks an address yet to be dialed back.
+	AddressUnverified AddressStatus = iota
+	// AddressVerified marks an address dialed back by this node, where the
+	// peer proved possession of its public key.
+	AddressVerified
+	// AddressFailed marks an address which could not be dialed back, or where
+	// a different peer answered.
+	AddressFailed
+)
+
+const (
+	// verifiedAddressTTL is how long a verified address stays verified.
+	verifiedAddressTTL = 1 * time.Hour
+	// failedAddressTTL is how long until an address which failed verification
+	// may be dialed back again.
+	failedAddressTTL = 1 * time.Minute
+)
+
+type addressResult struct {
+	status  AddressStatus
+	expires time.Time
+}
+
+// addressVerifier caches the results of dial-backs by public key and address,
+// and limits how often dial-backs are made.
+type addressVerifier struct {
+	sync.Mutex
+
+	results map[string]addressResult // public key + address -> result
+	pending map[string]struct{}
+	last    time.Time
+	peak    peakSize
+}
+
+func addressKey(publicKey []byte, address string) string {
+	return string(publicKey) + "|" + address
+}
+
+// AddressStatus returns whether a peer was proven to be reachable at an
+// address. Every address counts as verified unless VerifyAddresses is set.
+func (n *Network) AddressStatus(publicKey []byte, address string) AddressStatus {
+	if !n.opts.verifyAddresses {
+		return AddressVerified
+	}
+
+	if unified, err := ToUnifiedAddress(address); err == nil {
+		address = unified
+	}
+
+	v := &n.addresses
+	v.Lock()
+	defer v.Unlock()
+
+	result, exists := v.results[addressKey(publicKey, address)]
+	if !exists || n.now().After(result.expires) {
+		return AddressUnverified
+	}
+	return result.status
+}
+
+// AddressVerified returns true if a peer was proven to be reachable at an
+// address, and the address may thus be relayed to other peers.
+func (n *Network) AddressVerified(publicKey []byte, address string) bool {
+	return n.AddressStatus(publicKey, address) == AddressVerified
+}
+
+// VerifyAddress asynchronously dials a peer back at an address it advertises,
+// and marks the address verified should the peer prove possession of its
+// public key there. Addresses with a cached result or a dial-back in progress
+// are skipped, as are dial-backs beyond the rate set by VerifyAddresses.
+func (n *Network) VerifyAddress(publicKey []byte, address string) {
+	if !n.opts.verifyAddresses || n.isClosed() {
+		return
+	}
+
+	address, err := ToUnifiedAddress(address)
+	if err != nil || address == n.Address {
+		return
+	}
+
+	key := addressKey(publicKey, address)
+	now := n.now()
+
+	v := &n.addresses
+	v.Lock()
+
+	if result, exists := v.results[key]; exists && now.Before(result.expires) {
+		v.Unlock()
+		return
+	}
+
+	if _, pending := v.pending[key]; pending {
+		v.Unlock()
+		return
+	}
+
+	if now.Sub(v.last) < n.opts.verifyAddressInterval {
+		v.Unlock()
+		return
+	}
+	v.last = now
+
+	if v.pending == nil {
+		v.pending = make(map[string]struct{})
+	}
+	v.pending[key] = struct{}{}
+
+	v.Unlock()
+
+	n.spawn(func() { n.dialBack(publicKey, address) })
+}
+
+// dialBack makes a short-lived connection to an address, expecting the peer
+// answering to prove possession of a public key.
+func (n *Network) dialBack(publicKey []byte, address string) {
+	status := AddressFailed
+	if n.answersAt(publicKey, address) {
+		status = AddressVerified
+	}
+
+	n.recordAddress(publicKey, address, status)
+}
+
+// answersAt returns true if the peer answering at an address proves
+// possession of a public key, over a short-lived connection.
+func (n *Network) answersAt(publicKey []byte, address string) bool {
+	conn, handshake, err := n.dial(address, true)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return bytes.Equal(handshake.remote.PublicKey, publicKey)
+}
+
+// recordAddress caches the result of verifying a peer's address.
+func (n *Network) recordAddress(publicKey []byte, address string, status AddressStatus) {
+	ttl := verifiedAddressTTL
+	if status != AddressVerified {
+		ttl = failedAddressTTL
+	}
+
+	key := addressKey(publicKey, address)
+
+	v := &n.addresses
+	v.Lock()
+	defer v.Unlock()
+
+	if v.results == nil {
+		v.results = make(map[string]addressResult)
+	}
+	v.results[key] = addressResult{status: status, expires: n.now().Add(ttl)}
+	delete(v.pending, key)
+}
+
+// verifyInbound verifies the address a peer which dialed us advertises. Should
+// we have dialed the address already, the peer proved its public key there
+// during our handshake with it.
+func (n *Network) verifyInbound(client *PeerClient, remote []byte, source string) {
+	if !n.opts.verifyAddresses {
+		return
+	}
+
+	advertised, err := ParseAddress(client.Address)
+	if err != nil || advertised.HostPort() == source {
+		return
+	}
+
+	if client.publicKey != nil {
+		status := AddressFailed
+		if bytes.Equal(client.publicKey, remote) {
+			status = AddressVerified
+		}
+		n.recordAddress(remote, client.Address, status)
+		return
+	}
+
+	n.VerifyAddress(remote, client.Address)
+}
+
+// compact drops the results of dial-backs which expired, examining at most
+// budget of them.
+func (v *addressVerifier) compact(now time.Time, budget int) compaction {
+	v.Lock()
+	defer v.Unlock()
+
+	return compactMap(&v.results, &v.peak, budget, func(_ string, result addressResult) bool {
+		return now.After(result.expires)
+	})
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForStatus(n *Network, publicKey []byte, address string, status AddressStatus) bool {
+	return waitUntil(3*time.Second, func() bool { return n.AddressStatus(publicKey, address) == status })
+}
+
+func TestVerifyInboundAddress(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, VerifyAddresses(0))
+	defer node.Close()
+
+	honest := buildListeningNode(t)
+	defer honest.Close()
+
+	assert.Equal(t, AddressUnverified, node.AddressStatus(honest.ID.PublicKey, honest.Address))
+
+	client, err := honest.Client(node.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+	assert.True(t, waitForStatus(node, honest.ID.PublicKey, honest.Address, AddressVerified))
+	assert.True(t, node.AddressVerified(honest.ID.PublicKey, honest.Address))
+}
+
+func TestVerifyAddress(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, VerifyAddresses(0))
+	defer node.Close()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	dead := FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort()))
+	key := ed25519.RandomKeyPair().PublicKey
+
+	node.VerifyAddress(key, dead)
+	assert.True(t, waitForStatus(node, key, dead, AddressFailed), "dead addresses should fail verification")
+
+	node.VerifyAddress(key, other.Address)
+	assert.True(t, waitForStatus(node, key, other.Address, AddressFailed), "addresses answered by another peer should fail verification")
+
+	node.VerifyAddress(other.ID.PublicKey, other.Address)
+	assert.True(t, waitForStatus(node, other.ID.PublicKey, other.Address, AddressVerified))
+
+	// Probing an address does not connect to the peer there.
+	_, connected := node.ConnectionState(other.Address)
+	assert.False(t, connected)
+}
+
+func TestVerifyAddressRateLimited(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, VerifyAddresses(time.Hour))
+	defer node.Close()
+
+	first, second := buildListeningNode(t), buildListeningNode(t)
+	defer first.Close()
+	defer second.Close()
+
+	node.VerifyAddress(first.ID.PublicKey, first.Address)
+	node.VerifyAddress(second.ID.PublicKey, second.Address)
+
+	assert.True(t, waitForStatus(node, first.ID.PublicKey, first.Address, AddressVerified))
+	assert.Equal(t, AddressUnverified, node.AddressStatus(second.ID.PublicKey, second.Address),
+		"dial-backs beyond the rate limit should be skipped")
+}
+
+func TestAddressesVerifiedWhenDisabled(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	assert.True(t, node.AddressVerified(ed25519.RandomKeyPair().PublicKey, "tcp://localhost:1"))
+}
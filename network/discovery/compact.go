@@ -0,0 +1,122 @@
+package discovery
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// CompactPeersCapability is advertised by nodes which accept peer lists in
+// compact form in response to lookups.
+const CompactPeersCapability = "discovery/compact-peers"
+
+// knownPrefixSize is the number of leading bytes of a peer ID used to tell
+// a responder which peers the requester already knows.
+const knownPrefixSize = 4
+
+// encodeCompactPeers packs a list of peers, sharing address prefixes amongst
+// them and storing public keys back to back. Peer IDs are left out, as they
+// are derived from public keys.
+func encodeCompactPeers(peers []*protobuf.ID) (*protobuf.CompactPeers, error) {
+	compact := new(protobuf.CompactPeers)
+	indices := make(map[string]uint32)
+
+	for i, id := range peers {
+		if i == 0 {
+			compact.KeySize = uint32(len(id.PublicKey))
+		} else if uint32(len(id.PublicKey)) != compact.KeySize {
+			return nil, errors.New("discovery: peers have public keys of differing sizes")
+		}
+		compact.PublicKeys = append(compact.PublicKeys, id.PublicKey...)
+
+		prefix, port := splitPort(id.Address)
+
+		index, exists := indices[prefix]
+		if !exists {
+			index = uint32(len(compact.Prefixes))
+			indices[prefix] = index
+			compact.Prefixes = append(compact.Prefixes, prefix)
+		}
+
+		compact.PrefixIndices = append(compact.PrefixIndices, index)
+		compact.Ports = append(compact.Ports, port)
+	}
+
+	return compact, nil
+}
+
+// decodeCompactPeers unpacks a list of peers packed by encodeCompactPeers.
+func decodeCompactPeers(compact *protobuf.CompactPeers) ([]*protobuf.ID, error) {
+	count := len(compact.PrefixIndices)
+
+	if len(compact.Ports) != count || uint64(len(compact.PublicKeys)) != uint64(count)*uint64(compact.KeySize) {
+		return nil, errors.New("discovery: malformed compact peer list")
+	}
+
+	peers := make([]*protobuf.ID, 0, count)
+
+	for i := 0; i < count; i++ {
+		index := compact.PrefixIndices[i]
+		if index >= uint32(len(compact.Prefixes)) {
+			return nil, errors.New("discovery: malformed compact peer list")
+		}
+
+		address := compact.Prefixes[index]
+		if port := compact.Ports[i]; port != 0 {
+			address += ":" + strconv.FormatUint(uint64(port), 10)
+		}
+
+		publicKey := make([]byte, compact.KeySize)
+		copy(publicKey, compact.PublicKeys[uint32(i)*compact.KeySize:])
+
+		id := protobuf.ID(peer.CreateID(address, publicKey))
+		peers = append(peers, &id)
+	}
+
+	return peers, nil
+}
+
+// splitPort splits the port off an address. Addresses without a port are
+// returned whole alongside port zero.
+func splitPort(address string) (string, uint32) {
+	i := strings.LastIndexByte(address, ':')
+	if i < 0 {
+		return address, 0
+	}
+
+	port, err := strconv.ParseUint(address[i+1:], 10, 16)
+	if err != nil || port == 0 {
+		return address, 0
+	}
+
+	return address[:i], uint32(port)
+}
+
+// encodeKnown packs the prefixes of the IDs of a list of known peers.
+func encodeKnown(ids []peer.ID) []byte {
+	known := make([]byte, 0, len(ids)*knownPrefixSize)
+	for _, id := range ids {
+		if len(id.Id) >= knownPrefixSize {
+			known = append(known, id.Id[:knownPrefixSize]...)
+		}
+	}
+	return known
+}
+
+// isKnown returns true if the prefix of an ID is amongst a list of known prefixes.
+func isKnown(known []byte, id []byte) bool {
+	if len(id) < knownPrefixSize {
+		return false
+	}
+
+	for i := 0; i+knownPrefixSize <= len(known); i += knownPrefixSize {
+		if bytes.Equal(known[i:i+knownPrefixSize], id[:knownPrefixSize]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/peer"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPeers generates a list of peers spread out over a handful of hosts.
+func testPeers(count int) []*protobuf.ID {
+	var peers []*protobuf.ID
+	for i := 0; i < count; i++ {
+		address := fmt.Sprintf("tcp://10.0.0.%d:%d", i%4+1, 3000+i)
+		id := protobuf.ID(peer.CreateID(address, ed25519.RandomKeyPair().PublicKey))
+		peers = append(peers, &id)
+	}
+	return peers
+}
+
+func TestCompactPeersRoundTrip(t *testing.T) {
+	peers := testPeers(16)
+	peers = append(peers, &protobuf.ID{PublicKey: peers[0].PublicKey, Address: "kcp://example.com"})
+	peers[16].Id = peer.CreateID(peers[16].Address, peers[16].PublicKey).Id
+
+	compact, err := encodeCompactPeers(peers)
+	assert.Nil(t, err)
+
+	raw, err := proto.Marshal(&protobuf.LookupNodeResponse{Compact: compact})
+	assert.Nil(t, err)
+
+	response := new(protobuf.LookupNodeResponse)
+	assert.Nil(t, proto.Unmarshal(raw, response))
+
+	decoded, err := decodeCompactPeers(response.Compact)
+	assert.Nil(t, err)
+	assert.Equal(t, peers, decoded)
+
+	empty, err := encodeCompactPeers(nil)
+	assert.Nil(t, err)
+	decoded, err = decodeCompactPeers(empty)
+	assert.Nil(t, err)
+	assert.Empty(t, decoded)
+
+	compact.PrefixIndices[0] = uint32(len(compact.Prefixes))
+	_, err = decodeCompactPeers(compact)
+	assert.NotNil(t, err, "out of range prefix indices should be rejected")
+}
+
+func TestKnownPeers(t *testing.T) {
+	peers := testPeers(8)
+
+	var ids []peer.ID
+	for _, id := range peers[:4] {
+		ids = append(ids, peer.ID(*id))
+	}
+	known := encodeKnown(ids)
+
+	for i, id := range peers {
+		assert.Equal(t, i < 4, isKnown(known, id.Id))
+	}
+}
+
+func BenchmarkCompactPeers(b *testing.B) {
+	peers := testPeers(16)
+
+	verbose, err := proto.Marshal(&protobuf.LookupNodeResponse{Peers: peers})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var compactSize int
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compact, err := encodeCompactPeers(peers)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		raw, err := proto.Marshal(&protobuf.LookupNodeResponse{Compact: compact})
+		if err != nil {
+			b.Fatal(err)
+		}
+		compactSize = len(raw)
+	}
+
+	b.ReportMetric(float64(len(verbose)), "verbose-bytes")
+	b.ReportMetric(float64(compactSize), "compact-bytes")
+}
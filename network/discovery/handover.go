@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"github.com/perlin-network/noise/dht"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/perlin-network/noise/peer"
+)
+
+// ExportHandover hands the peers in the routing table over to a successor.
+func (state *Plugin) ExportHandover(net *network.Network) ([]byte, error) {
+	if state.Routes == nil {
+		return nil, nil
+	}
+
+	var ids []*protobuf.ID
+	for _, id := range state.Routes.GetPeers() {
+		id := protobuf.ID(id)
+		ids = append(ids, &id)
+	}
+
+	compact, err := encodeCompactPeers(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return compact.Marshal()
+}
+
+// ImportHandover populates the routing table with the peers a predecessor
+// handed over, ahead of Startup.
+func (state *Plugin) ImportHandover(net *network.Network, handover []byte) error {
+	compact := new(protobuf.CompactPeers)
+	if err := compact.Unmarshal(handover); err != nil {
+		return err
+	}
+
+	ids, err := decodeCompactPeers(compact)
+	if err != nil {
+		return err
+	}
+
+	if state.Routes == nil {
+		state.Routes = dht.CreateRoutingTable(net.ID)
+	}
+	for _, id := range ids {
+		state.Routes.Update(peer.ID(*id))
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/dht"
+	"github.com/perlin-network/noise/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingTableHandover(t *testing.T) {
+	self := peer.CreateID("tcp://10.0.0.9:3000", ed25519.RandomKeyPair().PublicKey)
+
+	predecessor := &Plugin{Routes: dht.CreateRoutingTable(self)}
+	for _, id := range testPeers(16) {
+		predecessor.Routes.Update(peer.ID(*id))
+	}
+
+	state, err := predecessor.ExportHandover(nil)
+	assert.Nil(t, err)
+
+	successor := &Plugin{Routes: dht.CreateRoutingTable(self)}
+	assert.Nil(t, successor.ImportHandover(nil, state))
+
+	assert.ElementsMatch(t, predecessor.Routes.GetPeers(), successor.Routes.GetPeers())
+	assert.NotNil(t, successor.ImportHandover(nil, []byte{0xff}))
+}
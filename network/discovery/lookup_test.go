@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/dht"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/perlin-network/noise/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulatedNetwork is a set of nodes with fully populated routing tables,
+// queried directly instead of over the wire.
+type simulatedNetwork struct {
+	ids    []peer.ID
+	tables map[string]*dht.RoutingTable
+}
+
+func newSimulatedNetwork(size int) *simulatedNetwork {
+	sim := &simulatedNetwork{tables: make(map[string]*dht.RoutingTable)}
+
+	for i := 0; i < size; i++ {
+		sim.ids = append(sim.ids, peer.CreateID(fmt.Sprintf("tcp://10.0.%d.%d:3000", i/256, i%256), ed25519.RandomKeyPair().PublicKey))
+	}
+
+	// Every node learns of all others in a random order, leaving buckets to
+	// fill up as they would.
+	for _, id := range sim.ids {
+		table := dht.CreateRoutingTable(id)
+		for _, i := range mrand.Perm(size) {
+			table.Update(sim.ids[i])
+		}
+		sim.tables[id.PublicKeyHex()] = table
+	}
+
+	return sim
+}
+
+// query answers a lookup request the way a node's discovery plugin would.
+func (sim *simulatedNetwork) query(targetID peer.ID) queryFunc {
+	return func(id peer.ID, known []byte) []*protobuf.ID {
+		var peers []*protobuf.ID
+		for _, peerID := range sim.tables[id.PublicKeyHex()].Closest(targetID.Id, dht.BucketSize) {
+			if !isKnown(known, peerID.Id) {
+				peerID := protobuf.ID(peerID)
+				peers = append(peers, &peerID)
+			}
+		}
+		return peers
+	}
+}
+
+// closest returns the ground truth set of peers closest to a target.
+func (sim *simulatedNetwork) closest(targetID peer.ID, k int) []peer.ID {
+	ids := append([]peer.ID(nil), sim.ids...)
+	sortByDistance(ids, targetID)
+	return ids[:k]
+}
+
+func TestLookupClosestSimulated(t *testing.T) {
+	const size, k, alpha, disjointPaths, numLookups = 200, dht.BucketSize, 3, 2, 20
+
+	sim := newSimulatedNetwork(size)
+
+	found, total := 0, 0
+
+	for i := 0; i < numLookups; i++ {
+		key := make([]byte, len(sim.ids[0].Id))
+		_, err := rand.Read(key)
+		assert.Nil(t, err)
+
+		targetID := peer.ID{Id: key}
+		origin := sim.tables[sim.ids[mrand.Intn(size)].PublicKeyHex()]
+
+		results := lookupClosest(context.Background(), origin.Closest(key, alpha), sim.query(targetID), targetID, alpha, disjointPaths)
+		if len(results) > k {
+			results = results[:k]
+		}
+
+		for j := 1; j < len(results); j++ {
+			assert.True(t, results[j-1].XorID(targetID).Less(results[j].XorID(targetID)), "results should be sorted by distance")
+		}
+
+		expected := make(map[string]struct{})
+		for _, id := range sim.closest(targetID, k) {
+			expected[id.PublicKeyHex()] = struct{}{}
+		}
+
+		for _, id := range results {
+			if _, ok := expected[id.PublicKeyHex()]; ok {
+				found++
+			}
+		}
+		total += k
+	}
+
+	accuracy := float64(found) / float64(total)
+	assert.True(t, accuracy >= 0.9, "found %.1f%% of the closest peers", accuracy*100)
+}
+
+func TestLookupClosestCancelled(t *testing.T) {
+	sim := newSimulatedNetwork(50)
+
+	key := make([]byte, len(sim.ids[0].Id))
+	targetID := peer.ID{Id: key}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	origin := sim.tables[sim.ids[0].PublicKeyHex()]
+	seeds := origin.Closest(key, 3)
+
+	results := lookupClosest(ctx, seeds, sim.query(targetID), targetID, 3, 1)
+	assert.Equal(t, len(seeds), len(results), "a cancelled lookup should not query further peers")
+}
+
+func TestFindClosestPeers(t *testing.T) {
+	const size = 8
+
+	var nodes []*network.Network
+	for i := 0; i < size; i++ {
+		builder := network.NewBuilder()
+		builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+		builder.AddPlugin(new(Plugin))
+
+		node, err := builder.Build()
+		assert.Nil(t, err)
+		defer node.Close()
+
+		go node.Listen()
+		<-node.Ready()
+
+		nodes = append(nodes, node)
+	}
+
+	for _, node := range nodes[1:] {
+		node.Bootstrap(nodes[0].Address)
+	}
+
+	origin := nodes[size-1]
+	plugin, _ := origin.Plugin(PluginID)
+	routes := plugin.(*Plugin).Routes
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(routes.GetPeers()) < size-1 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	key := make([]byte, len(origin.ID.Id))
+	_, err := rand.Read(key)
+	assert.Nil(t, err)
+
+	_, err = origin.FindClosestPeers(context.Background(), key[:4], 4)
+	assert.NotNil(t, err, "keys of the wrong size should be rejected")
+
+	peers, err := origin.FindClosestPeers(context.Background(), key, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(peers))
+
+	var ids []peer.ID
+	for _, node := range nodes[:size-1] {
+		ids = append(ids, node.ID)
+	}
+	sortByDistance(ids, peer.ID{Id: key})
+
+	for i, info := range peers {
+		assert.Equal(t, ids[i].Address, info.Address)
+		_, connected := origin.ConnectionState(info.Address)
+		assert.Equal(t, connected, info.Connected)
+	}
+}
+
+func TestLookupExcludesUnverifiedAddresses(t *testing.T) {
+	build := func(opts ...network.BuilderOption) *network.Network {
+		builder := network.NewBuilderWithOptions(opts...)
+		builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+		builder.AddPlugin(new(Plugin))
+
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		go node.Listen()
+		<-node.Ready()
+
+		return node
+	}
+
+	node := build(network.VerifyAddresses(0))
+	defer node.Close()
+
+	honest := build()
+	defer honest.Close()
+
+	requester := build()
+	defer requester.Close()
+
+	honest.Bootstrap(node.Address)
+
+	assert.True(t, waitUntilTrue(3*time.Second, func() bool {
+		return node.AddressVerified(honest.ID.PublicKey, honest.Address)
+	}), "honest peers should have their address verified")
+
+	// A peer advertising an address it can't be reached at.
+	dead := peer.CreateID(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())), ed25519.RandomKeyPair().PublicKey)
+
+	plugin, _ := node.Plugin(PluginID)
+	routes := plugin.(*Plugin).Routes
+	routes.Update(dead)
+	node.VerifyAddress(dead.PublicKey, dead.Address)
+
+	assert.True(t, waitUntilTrue(3*time.Second, func() bool {
+		return node.AddressStatus(dead.PublicKey, dead.Address) == network.AddressFailed
+	}))
+	assert.True(t, routes.PeerExists(dead), "unverified peers should be kept")
+
+	var addresses []string
+	for _, id := range queryPeerByID(requester, node.ID, dead, nil) {
+		addresses = append(addresses, id.Address)
+	}
+
+	assert.Contains(t, addresses, honest.Address)
+	assert.NotContains(t, addresses, dead.Address, "unverified peers should never be relayed")
+}
+
+func waitUntilTrue(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
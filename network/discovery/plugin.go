@@ -1,13 +1,16 @@
 package discovery
 
 import (
+	"context"
 	"strings"
+	"sync"
 
 	"github.com/golang/glog"
 	"github.com/perlin-network/noise/dht"
 	"github.com/perlin-network/noise/internal/protobuf"
 	"github.com/perlin-network/noise/network"
 	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
 )
 
 type Plugin struct {
@@ -17,22 +20,55 @@ type Plugin struct {
 	DisablePong   bool
 	DisableLookup bool
 
+	// LookupAlpha is the number of peers queried at once per disjoint path by
+	// FindClosestPeers (default: dht.BucketSize).
+	LookupAlpha int
+	// LookupDisjointPaths is the number of disjoint paths FindClosestPeers
+	// searches along in parallel (default: 8).
+	LookupDisjointPaths int
+
 	Routes *dht.RoutingTable
+
+	net *network.Network
+
+	// Peers under quarantine (address -> peer.ID), kept out of the routing
+	// table until they graduate.
+	probation sync.Map
 }
 
 var (
-	PluginID                         = (*Plugin)(nil)
-	_        network.PluginInterface = (*Plugin)(nil)
+	PluginID                              = (*Plugin)(nil)
+	_        network.PluginInterface      = (*Plugin)(nil)
+	_        network.BulkDisconnectPlugin = (*Plugin)(nil)
+	_        network.HandoverParticipant  = (*Plugin)(nil)
 )
 
+// Capabilities advertises support for compact peer lists.
+func (state *Plugin) Capabilities() []string {
+	return []string{CompactPeersCapability}
+}
+
 func (state *Plugin) Startup(net *network.Network) {
-	// Create routing table.
-	state.Routes = dht.CreateRoutingTable(net.ID)
+	state.net = net
+
+	// Create routing table, unless one was handed over.
+	if state.Routes == nil {
+		state.Routes = dht.CreateRoutingTable(net.ID)
+	}
 }
 
 func (state *Plugin) Receive(ctx *network.PluginContext) error {
-	// Update routing for every incoming message.
-	state.Routes.Update(ctx.Sender())
+	// Update routing for every incoming message, holding peers under
+	// quarantine back in probation. Light nodes keep no routing table, and
+	// are kept out of ours.
+	if !state.net.IsLight() && !ctx.Client().HasCapability(network.ClientCapability) {
+		if ctx.Client().Quarantined() {
+			state.probation.Store(ctx.Sender().Address, ctx.Sender())
+		} else {
+			state.probation.Delete(ctx.Sender().Address)
+			state.Routes.Update(ctx.Sender())
+		}
+	}
 
 	// Handle RPC.
 	switch msg := ctx.Message().(type) {
@@ -48,15 +84,17 @@ func (state *Plugin) Receive(ctx *network.PluginContext) error {
 			return err
 		}
 	case *protobuf.Pong:
-		if state.DisablePong {
+		if state.DisablePong || state.net.IsLight() {
 			break
 		}
 
 		peers := FindNode(ctx.Network(), ctx.Sender(), dht.BucketSize, 8)
 
-		// Update routing table w/ closest peers to self.
+		// Update routing table w/ closest peers to self, verifying the
+		// addresses of those relayed to us before relaying them any further.
 		for _, peerID := range peers {
 			state.Routes.Update(peerID)
+			state.net.VerifyAddress(peerID.PublicKey, peerID.Address)
 		}
 
 		glog.Infof("bootstrapped w/ peer(s): %s.", strings.Join(state.Routes.GetPeerAddresses(), ", "))
@@ -65,15 +103,31 @@ func (state *Plugin) Receive(ctx *network.PluginContext) error {
 			break
 		}
 
-		// Prepare response.
+		// Prepare response, which light nodes leave empty.
 		response := &protobuf.LookupNodeResponse{}
+		if state.net.IsLight() {
+			return ctx.Reply(response)
+		}
+
+		// Respond back with closest peers to a provided target, leaving out
+		// those the requester already knows and those with unverified addresses.
+		for _, peerID := range state.Routes.Closest(msg.Target.Id, dht.BucketSize) {
+			if isKnown(msg.Known, peerID.Id) || !ctx.Network().AddressVerified(peerID.PublicKey, peerID.Address) {
+				continue
+			}
 
-		// Respond back with closest peers to a provided target.
-		for _, peerID := range state.Routes.FindClosestPeers(peer.ID(*msg.Target), dht.BucketSize) {
 			id := protobuf.ID(peerID)
 			response.Peers = append(response.Peers, &id)
 		}
 
+		if ctx.Client().HasCapability(CompactPeersCapability) {
+			compact, err := encodeCompactPeers(response.Peers)
+			if err != nil {
+				return err
+			}
+			response = &protobuf.LookupNodeResponse{Compact: compact}
+		}
+
 		err := ctx.Reply(response)
 		if err != nil {
 			return err
@@ -85,6 +139,44 @@ func (state *Plugin) Receive(ctx *network.PluginContext) error {
 	return nil
 }
 
+// FindClosestPeers runs an iterative lookup across the network for the k
+// peers closest by XorID distance to an arbitrary key the size of a peer ID,
+// returning them closest first. Should ctx be done before the lookup
+// completes, the closest peers found so far are returned alongside ctx.Err().
+func (state *Plugin) FindClosestPeers(ctx context.Context, key []byte, k int) ([]peer.ID, error) {
+	self := state.Routes.Self()
+	if len(key) != len(self.Id) {
+		return nil, errors.Errorf("discovery: key must be %d bytes long", len(self.Id))
+	}
+
+	alpha, disjointPaths := state.LookupAlpha, state.LookupDisjointPaths
+	if alpha <= 0 {
+		alpha = dht.BucketSize
+	}
+	if disjointPaths <= 0 {
+		disjointPaths = 8
+	}
+
+	targetID := peer.ID{Id: key}
+
+	query := func(id peer.ID, known []byte) []*protobuf.ID {
+		return queryPeerByID(state.net, id, targetID, known)
+	}
+
+	var results []peer.ID
+	for _, peerID := range lookupClosest(ctx, state.Routes.Closest(key, alpha), query, targetID, alpha, disjointPaths) {
+		if !peerID.Equals(self) {
+			results = append(results, peerID)
+		}
+	}
+
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	return results, ctx.Err()
+}
+
 func (state *Plugin) Cleanup(net *network.Network) {
 	// TODO: Save routing table?
 }
@@ -92,6 +184,8 @@ func (state *Plugin) Cleanup(net *network.Network) {
 func (state *Plugin) PeerDisconnect(client *network.PeerClient) {
 	// Delete peer if in routing table.
 	if client.ID != nil {
+		state.probation.Delete(client.ID.Address)
+
 		if state.Routes.PeerExists(*client.ID) {
 			state.Routes.RemovePeer(*client.ID)
 
@@ -99,3 +193,23 @@ func (state *Plugin) PeerDisconnect(client *network.PeerClient) {
 		}
 	}
 }
+
+// PeersDisconnect removes all peers which disconnected during a storm from
+// the routing table in one go.
+func (state *Plugin) PeersDisconnect(clients []*network.PeerClient) {
+	removed := 0
+	for _, client := range clients {
+		if client.ID == nil {
+			continue
+		}
+
+		state.probation.Delete(client.ID.Address)
+
+		if state.Routes.PeerExists(*client.ID) {
+			state.Routes.RemovePeer(*client.ID)
+			removed++
+		}
+	}
+
+	glog.Infof("%d peers have disconnected from %s.", removed, state.net.ID.Address)
+}
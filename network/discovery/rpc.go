@@ -1,6 +1,7 @@
 package discovery
 
 import (
+	"context"
 	"sort"
 	"sync"
 	"time"
@@ -12,31 +13,44 @@ import (
 	"github.com/perlin-network/noise/peer"
 )
 
-func queryPeerByID(net *network.Network, peerID peer.ID, targetID peer.ID, responses chan []*protobuf.ID) {
-	client, err := net.Client(peerID.Address)
+// maxKnownPeers caps the number of known peers a lookup request lists.
+const maxKnownPeers = 64
+
+// queryFunc asks a peer for the peers it believes are closest to a lookup's
+// target, leaving out those already known.
+type queryFunc func(id peer.ID, known []byte) []*protobuf.ID
+
+func queryPeerByID(net *network.Network, peerID peer.ID, targetID peer.ID, known []byte) []*protobuf.ID {
+	client, err := net.ReservedClient(peerID.Address)
 	if err != nil {
-		responses <- []*protobuf.ID{}
-		return
+		return []*protobuf.ID{}
 	}
 
 	targetProtoID := protobuf.ID(targetID)
 
 	request := new(rpc.Request)
-	request.SetMessage(&protobuf.LookupNodeRequest{Target: &targetProtoID})
+	request.SetMessage(&protobuf.LookupNodeRequest{Target: &targetProtoID, Known: known})
 	request.SetTimeout(3 * time.Second)
 
 	response, err := client.Request(request)
 
 	if err != nil {
-		responses <- []*protobuf.ID{}
-		return
+		return []*protobuf.ID{}
 	}
 
 	if response, ok := response.(*protobuf.LookupNodeResponse); ok {
-		responses <- response.Peers
-	} else {
-		responses <- []*protobuf.ID{}
+		if response.Compact == nil {
+			return response.Peers
+		}
+
+		peers, err := decodeCompactPeers(response.Compact)
+		if err != nil {
+			return []*protobuf.ID{}
+		}
+		return peers
 	}
+
+	return []*protobuf.ID{}
 }
 
 type lookupBucket struct {
@@ -44,14 +58,22 @@ type lookupBucket struct {
 	queue   []peer.ID
 }
 
-func (lookup *lookupBucket) performLookup(net *network.Network, targetID peer.ID, alpha int, visited *sync.Map) (results []peer.ID) {
-	responses := make(chan []*protobuf.ID)
+func (lookup *lookupBucket) performLookup(ctx context.Context, query queryFunc, targetID peer.ID, alpha int, visited *sync.Map) (results []peer.ID) {
+	// Buffered so that queries still pending once the lookup is cancelled never block.
+	responses := make(chan []*protobuf.ID, alpha)
+
+	issue := func(id peer.ID) {
+		known := knownPeers(visited)
+		go func() {
+			responses <- query(id, known)
+		}()
+	}
 
 	// Go through every peer in the entire queue and queue up what peers believe
 	// is closest to a target ID.
 
-	for ; lookup.pending < alpha && len(lookup.queue) > 0; lookup.pending++ {
-		go queryPeerByID(net, lookup.queue[0], targetID, responses)
+	for ; lookup.pending < alpha && len(lookup.queue) > 0 && ctx.Err() == nil; lookup.pending++ {
+		issue(lookup.queue[0])
 
 		results = append(results, lookup.queue[0])
 		lookup.queue = lookup.queue[1:]
@@ -62,7 +84,13 @@ func (lookup *lookupBucket) performLookup(net *network.Network, targetID peer.ID
 
 	// Asynchronous breadth-first search.
 	for lookup.pending > 0 {
-		response := <-responses
+		var response []*protobuf.ID
+
+		select {
+		case response = <-responses:
+		case <-ctx.Done():
+			return
+		}
 
 		lookup.pending--
 
@@ -70,7 +98,7 @@ func (lookup *lookupBucket) performLookup(net *network.Network, targetID peer.ID
 		for _, id := range response {
 			peerID := peer.ID(*id)
 
-			if _, seen := visited.LoadOrStore(peerID.PublicKeyHex(), struct{}{}); !seen {
+			if _, seen := visited.LoadOrStore(peerID.PublicKeyHex(), peerID); !seen {
 				// Append new peer to be queued by the routing table.
 				results = append(results, peerID)
 				lookup.queue = append(lookup.queue, peerID)
@@ -78,8 +106,10 @@ func (lookup *lookupBucket) performLookup(net *network.Network, targetID peer.ID
 		}
 
 		// Queue and request for #ALPHA closest peers to target ID from expanded results.
-		for ; lookup.pending < alpha && len(lookup.queue) > 0; lookup.pending++ {
-			go queryPeerByID(net, lookup.queue[0], targetID, responses)
+		sortByDistance(lookup.queue, targetID)
+
+		for ; lookup.pending < alpha && len(lookup.queue) > 0 && ctx.Err() == nil; lookup.pending++ {
+			issue(lookup.queue[0])
 			lookup.queue = lookup.queue[1:]
 		}
 
@@ -90,28 +120,38 @@ func (lookup *lookupBucket) performLookup(net *network.Network, targetID peer.ID
 	return
 }
 
-// FindNode queries all peers this current node acknowledges for the closest peers
-// to a specified target ID.
-//
-// All lookups are done under a number of disjoint lookups in parallel.
-//
-// Queries at most #ALPHA nodes at a time per lookup, and returns all peer IDs closest to a target peer ID.
-func FindNode(net *network.Network, targetID peer.ID, alpha int, disjointPaths int) (results []peer.ID) {
-	plugin, exists := net.Plugin(PluginID)
+// knownPeers packs the IDs of peers visited so far by a lookup, so that
+// peers queried may leave them out of their responses.
+func knownPeers(visited *sync.Map) []byte {
+	var ids []peer.ID
 
-	// Discovery plugin was not registered. Fail.
-	if !exists {
-		return
-	}
+	visited.Range(func(_, value interface{}) bool {
+		ids = append(ids, value.(peer.ID))
+		return len(ids) < maxKnownPeers
+	})
 
+	return encodeKnown(ids)
+}
+
+// sortByDistance sorts peers by their XorID distance to a target ID, closest first.
+func sortByDistance(peers []peer.ID, targetID peer.ID) {
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].XorID(targetID).Less(peers[j].XorID(targetID))
+	})
+}
+
+// lookupClosest searches for the peers closest to a target ID starting from a
+// set of seed peers, running a number of disjoint lookups in parallel. It
+// returns all distinct peers found, sorted by XorID distance.
+func lookupClosest(ctx context.Context, seeds []peer.ID, query queryFunc, targetID peer.ID, alpha int, disjointPaths int) (results []peer.ID) {
 	visited := new(sync.Map)
 
 	var lookups []*lookupBucket
 
 	// Start searching for target from #ALPHA peers closest to target by queuing
 	// them up and marking them as visited.
-	for i, peerID := range plugin.(*Plugin).Routes.FindClosestPeers(targetID, alpha) {
-		visited.Store(peerID.PublicKeyHex(), struct{}{})
+	for i, peerID := range seeds {
+		visited.Store(peerID.PublicKeyHex(), peerID)
 
 		if len(lookups) < disjointPaths {
 			lookups = append(lookups, new(lookupBucket))
@@ -119,16 +159,16 @@ func FindNode(net *network.Network, targetID peer.ID, alpha int, disjointPaths i
 
 		lookup := lookups[i%disjointPaths]
 		lookup.queue = append(lookup.queue, peerID)
-
-		results = append(results, peerID)
 	}
 
 	wait, mutex := &sync.WaitGroup{}, &sync.Mutex{}
 
 	for _, lookup := range lookups {
 		go func(lookup *lookupBucket) {
+			found := lookup.performLookup(ctx, query, targetID, alpha, visited)
+
 			mutex.Lock()
-			results = append(results, lookup.performLookup(net, targetID, alpha, visited)...)
+			results = append(results, found...)
 			mutex.Unlock()
 
 			wait.Done()
@@ -140,12 +180,43 @@ func FindNode(net *network.Network, targetID peer.ID, alpha int, disjointPaths i
 	// Wait until all #D parallel lookups have been completed.
 	wait.Wait()
 
-	// Sort resulting peers by XOR distance.
-	sort.Slice(results, func(i, j int) bool {
-		left := results[i].Xor(targetID)
-		right := results[j].Xor(targetID)
-		return left.Less(right)
-	})
+	// Seeds which were never queried count towards the results all the same.
+	results = append(results, seeds...)
+
+	distinct := make(map[string]struct{}, len(results))
+	unique := results[:0]
+	for _, peerID := range results {
+		if _, seen := distinct[peerID.PublicKeyHex()]; !seen {
+			distinct[peerID.PublicKeyHex()] = struct{}{}
+			unique = append(unique, peerID)
+		}
+	}
+
+	sortByDistance(unique, targetID)
+
+	return unique
+}
+
+// FindNode queries all peers this current node acknowledges for the closest peers
+// to a specified target ID.
+//
+// All lookups are done under a number of disjoint lookups in parallel.
+//
+// Queries at most #ALPHA nodes at a time per lookup, and returns all peer IDs closest to a target peer ID.
+func FindNode(net *network.Network, targetID peer.ID, alpha int, disjointPaths int) (results []peer.ID) {
+	plugin, exists := net.Plugin(PluginID)
+
+	// Discovery plugin was not registered. Fail.
+	if !exists {
+		return
+	}
+
+	query := func(id peer.ID, known []byte) []*protobuf.ID {
+		return queryPeerByID(net, id, targetID, known)
+	}
+
+	seeds := plugin.(*Plugin).Routes.FindClosestPeers(targetID, alpha)
+	results = lookupClosest(context.Background(), seeds, query, targetID, alpha, disjointPaths)
 
 	// Cut off list of results to only have the routing table focus on the
 	// #dht.BucketSize closest peers to the current node.
@@ -0,0 +1,179 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+)
+
+// orderedQueueSize is the number of ordered messages of a single type which
+// may be buffered per peer before the peer's receive loop blocks.
+const orderedQueueSize = 1024
+
+// HandlerPanic describes a panic recovered from a plugin's Receive callback.
+type HandlerPanic struct {
+	// Plugin is the type name of the plugin which panicked.
+	Plugin string
+	// Message is the message being handled.
+	Message proto.Message
+	// Value is the value passed to panic().
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (p *HandlerPanic) Error() string {
+	return fmt.Sprintf("plugin %s panicked handling %s: %v\n%s", p.Plugin, proto.MessageName(p.Message), p.Value, p.Stack)
+}
+
+// handleMessage runs the Receive callbacks of all plugins registered under a
+// message's protocol tag, bounded by the concurrency limits of the tag and of
+// the message's type qualified with the tag. It returns whether every plugin
+// handled the message without error.
+func (n *Network) handleMessage(ctx *PluginContext, name string) bool {
+	// Drop messages still queued up once the peer disconnects, unless its
+	// session was merged into another one which carries on handling them.
+	if ctx.client.isClosed() {
+		merged := ctx.client.merged()
+		if merged.isClosed() {
+			return false
+		}
+		ctx.client = merged
+	}
+
+	if slots, limited := n.protocolSlots[ctx.protocol]; limited {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.client.closeSignal:
+			return false
+		}
+	}
+
+	if slots, limited := n.handlerSlots[name]; limited {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.client.closeSignal:
+			return false
+		}
+	}
+
+	plugins, _ := n.protocolPlugins(ctx.protocol)
+
+	// Execute 'on receive message' callback for all plugins.
+	handled := true
+	plugins.Each(func(plugin PluginInterface) {
+		if !n.receive(plugin, ctx, name) {
+			handled = false
+		}
+	})
+
+	return handled
+}
+
+// receive invokes a single plugin's Receive callback, recovering from and
+// reporting any panic so that the peer's session stays alive, and treats the
+// peer as the plugin's handling of the message says. It returns whether the
+// plugin returned without error.
+func (n *Network) receive(plugin PluginInterface, ctx *PluginContext, name string) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+			p := &HandlerPanic{
+				Plugin:  reflect.TypeOf(plugin).String(),
+				Message: ctx.Message(),
+				Value:   r,
+				Stack:   debug.Stack(),
+			}
+
+			glog.Error(p)
+
+			if n.opts.onHandlerPanic != nil {
+				n.opts.onHandlerPanic(ctx.client, p)
+			}
+
+			result := n.opts.handlerPanicResult
+			result.Err = p
+			n.applyHandlerResult(ctx.client, &result)
+		}
+	}()
+
+	err := plugin.Receive(ctx)
+	if err == nil {
+		return true
+	}
+
+	result := handlerResultOf(err)
+	if result == nil {
+		result = n.defaultHandlerResult(ctx, name, err)
+	}
+	n.applyHandlerResult(ctx.client, result)
+
+	return result.Outcome == HandlerOk
+}
+
+// isOrdered returns true if messages of a given type must be handled one at a
+// time in the order they were sent.
+func (n *Network) isOrdered(name string) bool {
+	_, ordered := n.opts.orderedHandlers[name]
+	return ordered
+}
+
+// submitOrdered queues up a job on the peer's ordered queue for a given
+// message type, spawning a worker for the queue should it not exist.
+func (c *PeerClient) submitOrdered(name string, job func()) {
+	queue, exists := c.orderedQueues.Load(name)
+	if !exists {
+		var loaded bool
+		queue, loaded = c.orderedQueues.LoadOrStore(name, make(chan func(), orderedQueueSize))
+		if !loaded {
+			ordered := queue.(chan func())
+			c.Network.spawn(func() { c.executeOrdered(ordered) })
+		}
+	}
+
+	// Jobs submitted once the client closes still run, though only to clean up.
+	select {
+	case queue.(chan func()) <- job:
+		// The job may have been queued up after the queue was drained.
+		if c.isClosed() {
+			drainOrdered(queue.(chan func()))
+		}
+	case <-c.closeSignal:
+		job()
+	}
+}
+
+func (c *PeerClient) executeOrdered(queue chan func()) {
+	for {
+		select {
+		case job := <-queue:
+			job()
+		case <-c.closeSignal:
+			// Queues handed over to the session the peer's session was merged
+			// into carry on being served in order.
+			if merged := c.merged(); merged != c {
+				c = merged
+				continue
+			}
+			drainOrdered(queue)
+			return
+		}
+	}
+}
+
+// drainOrdered runs all jobs left queued up on an ordered queue.
+func drainOrdered(queue chan func()) {
+	for {
+		select {
+		case job := <-queue:
+			job()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package network
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// defaultDispatchQueueSize is the number of messages which may be queued up
+// per dispatch worker before dispatching blocks.
+const defaultDispatchQueueSize = 1024
+
+// DispatchStats describes the load on the dispatch worker pool.
+type DispatchStats struct {
+	// Workers is the number of dispatch workers.
+	Workers int
+	// Queued is the number of messages waiting for a worker.
+	Queued int
+	// Overflows is the number of times a message found its worker's queue
+	// full, and had to wait for room.
+	Overflows uint64
+}
+
+// dispatchPool hands received messages over to a fixed set of workers. Messages
+// sharing an ordering key always go to the same worker, and are thus handled
+// in the order they were received, while messages of differing keys are
+// handled in parallel.
+type dispatchPool struct {
+	overflows uint64 // for atomic ops
+	next      uint32 // for atomic ops
+
+	queues []chan func()
+	kill   chan struct{}
+}
+
+func newDispatchPool(workers, queueSize int, kill chan struct{}) *dispatchPool {
+	if queueSize <= 0 {
+		queueSize = defaultDispatchQueueSize
+	}
+
+	p := &dispatchPool{
+		queues: make([]chan func(), workers),
+		kill:   kill,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(), queueSize)
+	}
+	return p
+}
+
+func (p *dispatchPool) start(exec Executor, w *watchdog) {
+	for _, queue := range p.queues {
+		queue := queue
+		exec.Go(func() { p.work(queue, w) })
+	}
+}
+
+func (p *dispatchPool) work(queue chan func(), w *watchdog) {
+	h := w.watch(ComponentDispatch, "", nil)
+	defer w.unwatch(h)
+
+	for {
+		select {
+		case job := <-queue:
+			h.beat()
+			job()
+			h.idle()
+		case <-p.kill:
+			drainOrdered(queue)
+			return
+		}
+	}
+}
+
+// submit queues up a job on the worker its ordering key hashes to, or the
+// next worker in turn should it have no key. It blocks while the worker's
+// queue is full.
+func (p *dispatchPool) submit(key []byte, job func()) {
+	var queue chan func()
+	if key == nil {
+		queue = p.queues[atomic.AddUint32(&p.next, 1)%uint32(len(p.queues))]
+	} else {
+		h := fnv.New32a()
+		h.Write(key)
+		queue = p.queues[h.Sum32()%uint32(len(p.queues))]
+	}
+
+	select {
+	case queue <- job:
+	default:
+		atomic.AddUint64(&p.overflows, 1)
+
+		// Jobs submitted once the network shuts down still run, though only to clean up.
+		select {
+		case queue <- job:
+		case <-p.kill:
+			job()
+			return
+		}
+	}
+
+	// The job may have been queued up after the queue was drained.
+	select {
+	case <-p.kill:
+		drainOrdered(queue)
+	default:
+	}
+}
+
+func (p *dispatchPool) stats() DispatchStats {
+	stats := DispatchStats{
+		Workers:   len(p.queues),
+		Overflows: atomic.LoadUint64(&p.overflows),
+	}
+	for _, queue := range p.queues {
+		stats.Queued += len(queue)
+	}
+	return stats
+}
+
+// DispatchStats returns the load on the dispatch worker pool, being the zero
+// value should the pool be disabled.
+func (n *Network) DispatchStats() DispatchStats {
+	if n.dispatch == nil {
+		return DispatchStats{}
+	}
+	return n.dispatch.stats()
+}
+
+// dispatchKey returns the ordering key of a received message, which defaults
+// to its sender's public key.
+func (n *Network) dispatchKey(ctx *PluginContext, name string) []byte {
+	if key, overridden := n.opts.dispatchKeys[name]; overridden {
+		return key(ctx)
+	}
+	return ctx.client.PeerID().PublicKey()
+}
+
+// unorderedKey spreads messages across all dispatch workers.
+func unorderedKey(ctx *PluginContext) []byte {
+	return nil
+}
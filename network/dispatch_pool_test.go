@@ -0,0 +1,176 @@
+package network
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestDispatchPoolPreservesOrderPerPeer(t *testing.T) {
+	t.Parallel()
+
+	const numSenders, numMessages = 3, 100
+
+	var received atomic.Int32
+	var sequencesMutex sync.Mutex
+	sequences := make(map[string][]int)
+
+	receiver, idle, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		i, err := strconv.Atoi(ctx.Message().(*testpb.TestMessage).Message)
+		assert.Nil(t, err)
+
+		// Jitter handlers so that reordering would show.
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+		sequencesMutex.Lock()
+		sequences[ctx.Client().Address] = append(sequences[ctx.Client().Address], i)
+		sequencesMutex.Unlock()
+
+		received.Inc()
+	}, DispatchWorkers(4, 8))
+	defer receiver.Close()
+	defer idle.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numSenders; i++ {
+		sender := buildListeningNode(t)
+		defer sender.Close()
+
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numMessages; j++ {
+				assert.Nil(t, client.Tell(&testpb.TestMessage{Message: fmt.Sprint(j)}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, waitUntil(10*time.Second, func() bool { return received.Load() == numSenders*numMessages }),
+		"received %d messages", received.Load())
+
+	sequencesMutex.Lock()
+	defer sequencesMutex.Unlock()
+
+	assert.Equal(t, numSenders, len(sequences))
+	for address, sequence := range sequences {
+		for i, j := range sequence {
+			if !assert.Equal(t, i, j, "messages from %s were handled out of order", address) {
+				break
+			}
+		}
+	}
+
+	stats := receiver.DispatchStats()
+	assert.Equal(t, 4, stats.Workers)
+	assert.Equal(t, 0, stats.Queued)
+	assert.True(t, stats.Overflows > 0, "queues of 8 messages should have overflowed")
+}
+
+// peakConcurrency returns how many handlers ran at once handling a burst of
+// slow messages from a single peer.
+func peakConcurrency(t *testing.T, opts ...BuilderOption) int32 {
+	const numMessages = 16
+
+	var running, peak, received atomic.Int32
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		now := running.Inc()
+		for {
+			current := peak.Load()
+			if now <= current || peak.CAS(current, now) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		running.Dec()
+		received.Inc()
+	}, opts...)
+	defer receiver.Close()
+	defer sender.Close()
+
+	for i := 0; i < numMessages; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: fmt.Sprint(i)}))
+	}
+
+	assert.True(t, waitUntil(5*time.Second, func() bool { return received.Load() == numMessages }))
+
+	return peak.Load()
+}
+
+func TestDispatchPoolUnorderedMessages(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int32(1), peakConcurrency(t, DispatchWorkers(4, 0)),
+		"messages from a single peer should be handled one at a time by default")
+	assert.True(t, peakConcurrency(t, DispatchWorkers(4, 0), UnorderedDispatch(&testpb.TestMessage{})) > 1,
+		"unordered messages should be handled in parallel")
+}
+
+func benchmarkDispatch(b *testing.B, opts ...BuilderOption) {
+	const numMessages = 256
+
+	var received atomic.Int32
+
+	builder := NewBuilderWithOptions(append(opts, DispatchWorkers(runtime.NumCPU(), 0))...)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		// Burn CPU as an expensive handler would.
+		sum := sha256.Sum256([]byte(ctx.Message().(*testpb.TestMessage).Message))
+		for i := 0; i < 5000; i++ {
+			sum = sha256.Sum256(sum[:])
+		}
+		received.Inc()
+	}})
+
+	receiver, err := builder.Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(b)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		for j := 0; j < numMessages; j++ {
+			if err := client.Tell(&testpb.TestMessage{Message: fmt.Sprint(j)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if !waitUntil(time.Minute, func() bool { return received.Load() == int32(i*numMessages) }) {
+			b.Fatalf("received %d messages", received.Load())
+		}
+	}
+}
+
+func BenchmarkDispatchOrdered(b *testing.B) {
+	benchmarkDispatch(b)
+}
+
+func BenchmarkDispatchUnordered(b *testing.B) {
+	benchmarkDispatch(b, UnorderedDispatch(&testpb.TestMessage{}))
+}
@@ -0,0 +1,143 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// handlerPlugin calls fn for every test message and ping it receives.
+type handlerPlugin struct {
+	*Plugin
+	fn func(ctx *PluginContext)
+}
+
+func (p *handlerPlugin) Receive(ctx *PluginContext) error {
+	switch ctx.Message().(type) {
+	case *testpb.TestMessage, *protobuf.Ping:
+		p.fn(ctx)
+	}
+	return nil
+}
+
+// connectWithHandler builds a receiving node running fn on every message, and
+// returns it alongside a client connected to it from a second node.
+func connectWithHandler(t *testing.T, fn func(ctx *PluginContext), opts ...BuilderOption) (*Network, *Network, *PeerClient) {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: fn})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t)
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	return receiver, sender, client
+}
+
+func TestHandlerPanicKeepsPeerConnected(t *testing.T) {
+	t.Parallel()
+
+	panics := make(chan *HandlerPanic, 1)
+	received := make(chan string, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		msg := ctx.Message().(*testpb.TestMessage)
+		if msg.Message == "panic" {
+			panic("handler blew up")
+		}
+		received <- msg.Message
+	}, OnHandlerPanic(func(client *PeerClient, p *HandlerPanic) {
+		panics <- p
+	}))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "panic"}))
+
+	select {
+	case p := <-panics:
+		assert.Equal(t, "handler blew up", p.Value)
+		assert.NotEmpty(t, p.Stack)
+	case <-time.After(3 * time.Second):
+		t.Fatal("panic was never reported")
+	}
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "still alive"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "still alive", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("peer stopped handling messages after a panic")
+	}
+
+	assert.Equal(t, 1, len(receiver.Peers()))
+}
+
+func TestSlowHandlerDoesNotDelayOtherTypes(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	pinged := make(chan struct{}, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		switch ctx.Message().(type) {
+		case *testpb.TestMessage:
+			<-release
+		case *protobuf.Ping:
+			pinged <- struct{}{}
+		}
+	}, HandlerConcurrency(&testpb.TestMessage{}, 1), OrderedHandlers(&testpb.TestMessage{}))
+	defer receiver.Close()
+	defer sender.Close()
+	defer close(release)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "slow"}))
+	}
+	assert.Nil(t, client.Tell(&protobuf.Ping{}))
+
+	select {
+	case <-pinged:
+	case <-time.After(3 * time.Second):
+		t.Fatal("ping was queued behind a slow handler")
+	}
+}
+
+func TestOrderedHandlersPreserveOrder(t *testing.T) {
+	t.Parallel()
+
+	const count = 50
+	received := make(chan string, count)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	}, OrderedHandlers(&testpb.TestMessage{}))
+	defer receiver.Close()
+	defer sender.Close()
+
+	for i := 0; i < count; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: fmt.Sprint(i)}))
+	}
+
+	for i := 0; i < count; i++ {
+		select {
+		case msg := <-received:
+			assert.Equal(t, fmt.Sprint(i), msg)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+}
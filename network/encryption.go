@@ -0,0 +1,480 @@
+package network
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// EncryptionPolicy decides whether connections to peers are encrypted.
+type EncryptionPolicy int
+
+const (
+	// EncryptionDisabled sends messages in plaintext, though signed.
+	EncryptionDisabled EncryptionPolicy = iota
+	// EncryptionPreferred encrypts connections to peers which also encrypt
+	// them, and talks to every other peer in plaintext.
+	EncryptionPreferred
+	// EncryptionRequired encrypts every connection, rejecting peers which do
+	// not encrypt them.
+	EncryptionRequired
+)
+
+// ErrEncryptionRequired is returned by handshakes with peers which do not
+// encrypt the connection though encryption is required.
+var ErrEncryptionRequired = errors.New("network: peer does not encrypt the connection")
+
+const (
+	// maxRecordSize bounds the plaintext sealed into a single record.
+	maxRecordSize = 16 * 1024
+
+	// recordHeaderSize is the size of the length prefix of records, followed
+	// by the epoch of the key they were sealed under.
+	recordHeaderSize = 8
+
+	// ratchetWindow is how many of the most recent keys records received
+	// may be opened under, older keys being overwritten once the window
+	// slides past them.
+	ratchetWindow = 2
+
+	// defaultRatchetRecords and defaultRatchetInterval are how many records,
+	// and for how long, keys seal records at most before they are ratcheted.
+	defaultRatchetRecords  = 1 << 16
+	defaultRatchetInterval = 10 * time.Minute
+)
+
+const (
+	// sessionKeyInfo binds session keys to their use.
+	sessionKeyInfo = "noise/encrypt/1"
+	// ratchetKeyInfo binds the keys ratcheted from session keys to their use.
+	ratchetKeyInfo = "noise/ratchet/1"
+)
+
+// sessionKeys are the keys a connection is encrypted with, one for each
+// direction.
+type sessionKeys struct {
+	send    []byte
+	receive []byte
+
+	// resumption is chained into the keys of the connection resuming the
+	// session established alongside these keys, so that compromising the
+	// keys of either connection alone does not expose the other.
+	resumption []byte
+
+	// ratchet is the schedule both directions ratchet their keys on.
+	ratchet ratchetSchedule
+}
+
+// resumptionSecret returns the secret the keys of a connection resuming the
+// session are chained onto, should the connection have been encrypted.
+func (k *sessionKeys) resumptionSecret() []byte {
+	if k == nil {
+		return nil
+	}
+	return k.resumption
+}
+
+// ratchetSchedule bounds how many records, and for how long, a key seals
+// records before it is ratcheted. Either bound is left out should it be 0.
+type ratchetSchedule struct {
+	records  uint64
+	interval time.Duration
+}
+
+// stricter returns the schedule ratcheting keys as soon as either of two
+// schedules would.
+func (s ratchetSchedule) stricter(other ratchetSchedule) ratchetSchedule {
+	if other.records > 0 && (s.records == 0 || other.records < s.records) {
+		s.records = other.records
+	}
+	if other.interval > 0 && (s.interval == 0 || other.interval < s.interval) {
+		s.interval = other.interval
+	}
+	return s
+}
+
+// due returns true if a key which sealed a number of records for a while is
+// to be ratcheted.
+func (s ratchetSchedule) due(records uint64, elapsed time.Duration) bool {
+	return (s.records > 0 && records >= s.records) || (s.interval > 0 && elapsed >= s.interval)
+}
+
+// encrypts returns true if connections are to be encrypted with peers which
+// encrypt them too.
+func (n *Network) encrypts() bool {
+	return n.opts.encryption != EncryptionDisabled
+}
+
+// ephemeralKey returns a fresh X25519 key to encrypt a connection with, or nil
+// should connections not be encrypted.
+func (n *Network) ephemeralKey() (*ecdh.PrivateKey, error) {
+	if !n.encrypts() {
+		return nil, nil
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate ephemeral key")
+	}
+	return key, nil
+}
+
+// offerEphemeralKey adds our ephemeral key to a handshake step, alongside
+// the schedule we would have keys ratcheted on, should we encrypt the
+// connection.
+func (n *Network) offerEphemeralKey(step *protobuf.Handshake, ephemeral *ecdh.PrivateKey) {
+	if ephemeral == nil {
+		return
+	}
+
+	step.EphemeralKey = ephemeral.PublicKey().Bytes()
+	step.RatchetRecords = uint64(n.opts.ratchetRecords)
+	step.RatchetIntervalMs = uint64(n.opts.ratchetInterval / time.Millisecond)
+}
+
+// agreeSessionKeys derives the keys a connection is encrypted with, should
+// both sides encrypt it, from our ephemeral key and the handshake step the
+// peer sent. Keys of a connection resuming a session are chained onto those
+// of the connection the session was established over, and keys are ratcheted
+// on whichever of our schedule and the peer's is stricter.
+func (n *Network) agreeSessionKeys(local *ecdh.PrivateKey, remote *protobuf.Handshake, dialer bool, resumed *session) (*sessionKeys, error) {
+	if local == nil || len(remote.EphemeralKey) == 0 {
+		return nil, nil
+	}
+
+	var chained []byte
+	if resumed != nil {
+		chained = resumed.resumption
+	}
+
+	keys, err := deriveSessionKeys(local, remote.EphemeralKey, dialer, n.keys.PublicKey, remote.Sender.PublicKey, chained)
+	if err != nil {
+		return nil, err
+	}
+
+	ours := ratchetSchedule{records: uint64(n.opts.ratchetRecords), interval: n.opts.ratchetInterval}
+	keys.ratchet = ours.stricter(ratchetSchedule{
+		records:  remote.RatchetRecords,
+		interval: time.Duration(remote.RatchetIntervalMs) * time.Millisecond,
+	})
+	return keys, nil
+}
+
+// deriveSessionKeys derives the keys a connection is encrypted with from our
+// ephemeral key and the one the peer sent, binding them to the ephemeral and
+// identity keys of both the dialer and the acceptor, and chaining them onto
+// the resumption secret of the session the connection resumes, if any.
+//
+// The ephemeral keys are sent in signed handshake steps, so that a peer which
+// substituted either of them fails verification, and the session keys are
+// authenticated by the identity keys of both sides.
+func deriveSessionKeys(local *ecdh.PrivateKey, remote []byte, dialer bool, localID, remoteID []byte, chained []byte) (*sessionKeys, error) {
+	public, err := ecdh.X25519().NewPublicKey(remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "peer sent a malformed ephemeral key")
+	}
+
+	secret, err := local.ECDH(public)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to agree on a session secret")
+	}
+
+	dialerKey, acceptorKey := local.PublicKey().Bytes(), remote
+	dialerID, acceptorID := localID, remoteID
+	if !dialer {
+		dialerKey, acceptorKey = acceptorKey, dialerKey
+		dialerID, acceptorID = acceptorID, dialerID
+	}
+
+	salt := bytes.Join([][]byte{dialerKey, acceptorKey, dialerID, acceptorID}, nil)
+
+	material, err := hkdf.Key(sha256.New, append(secret, chained...), salt, sessionKeyInfo, 96)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive session keys")
+	}
+
+	keys := &sessionKeys{send: material[:32], receive: material[32:64], resumption: material[64:]}
+	if !dialer {
+		keys.send, keys.receive = keys.receive, keys.send
+	}
+	return keys, nil
+}
+
+// checkEncryption rejects peers which did not encrypt the connection though
+// encryption is required.
+func (n *Network) checkEncryption(result *handshakeResult) error {
+	if n.opts.encryption == EncryptionRequired && result.keys == nil {
+		return ErrEncryptionRequired
+	}
+	return nil
+}
+
+// secure wraps a connection which handshook into one encrypting everything
+// written to and read from it, should the handshake have agreed on session
+// keys. The connection takes the session keys over, overwriting them once
+// they are ratcheted past, and tells when keys are due to be ratcheted by the
+// given clock.
+func secure(conn net.Conn, result *handshakeResult, now func() time.Time) (net.Conn, error) {
+	if result.keys == nil {
+		return conn, nil
+	}
+
+	sealer, err := newRecordCipher(result.keys.send, 0)
+	if err != nil {
+		return nil, err
+	}
+	opener, err := newRecordCipher(result.keys.receive, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secureConn{
+		Conn:     conn,
+		schedule: result.keys.ratchet,
+		now:      now,
+		sealer:   sealer,
+		since:    now(),
+		openers:  []*recordCipher{opener},
+	}, nil
+}
+
+// recordCipher seals or opens the records sent in one direction of a
+// connection under one key, each under the next nonce in sequence.
+type recordCipher struct {
+	epoch uint32
+	key   []byte
+	aead  cipher.AEAD
+	nonce []byte
+	seq   uint64
+}
+
+func newRecordCipher(key []byte, epoch uint32) (*recordCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordCipher{epoch: epoch, key: key, aead: aead, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+// ratchet returns the cipher of the key following this one, derived from it
+// through a one-way step so that it tells nothing of the keys before it.
+func (c *recordCipher) ratchet() (*recordCipher, error) {
+	if c.epoch == ^uint32(0) {
+		return nil, errors.New("secure: key epochs exhausted")
+	}
+
+	key, err := hkdf.Key(sha256.New, c.key, nil, ratchetKeyInfo, len(c.key))
+	if err != nil {
+		return nil, errors.Wrap(err, "secure: failed to ratchet key")
+	}
+	return newRecordCipher(key, c.epoch+1)
+}
+
+// zeroize overwrites the key. The round keys AES expanded it into are out of
+// reach, and are only dropped along with the cipher.
+func (c *recordCipher) zeroize() {
+	for i := range c.key {
+		c.key[i] = 0
+	}
+}
+
+// next returns the nonce of the next record, failing should the sequence of
+// nonces be exhausted rather than reuse any of them.
+func (c *recordCipher) next() ([]byte, error) {
+	if c.seq == ^uint64(0) {
+		return nil, errors.New("secure: nonces exhausted")
+	}
+
+	binary.BigEndian.PutUint64(c.nonce[len(c.nonce)-8:], c.seq)
+	c.seq++
+	return c.nonce, nil
+}
+
+// secureConn is a connection whose stream is split into records, each sealed
+// with AES-GCM and prefixed with its sealed size and the epoch of the key it
+// was sealed under. Records are numbered by their nonces, so that records
+// dropped, reordered or replayed fail to open.
+//
+// Keys are ratcheted on a schedule, the writer moving on to the next epoch
+// and the reader following once it opens a record of that epoch. Readers keep
+// the keys of the last few epochs for records sealed under them to still be
+// opened, and overwrite the keys of older epochs.
+type secureConn struct {
+	net.Conn
+
+	schedule ratchetSchedule
+	now      func() time.Time
+
+	writeMutex sync.Mutex
+	sealer     *recordCipher
+	since      time.Time
+	record     []byte
+
+	readMutex sync.Mutex
+	openers   []*recordCipher // oldest first
+	header    [recordHeaderSize]byte
+	sealed    []byte
+	plaintext []byte
+}
+
+// Write seals a buffer into as many records as it takes, and writes them out.
+func (c *secureConn) Write(buffer []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	written := 0
+	for written < len(buffer) {
+		size := len(buffer) - written
+		if size > maxRecordSize {
+			size = maxRecordSize
+		}
+
+		if c.schedule.due(c.sealer.seq, c.now().Sub(c.since)) {
+			if err := c.ratchetSealer(); err != nil {
+				return written, err
+			}
+		}
+
+		nonce, err := c.sealer.next()
+		if err != nil {
+			return written, err
+		}
+
+		var epoch [4]byte
+		binary.BigEndian.PutUint32(epoch[:], c.sealer.epoch)
+
+		record := append(c.record[:0], 0, 0, 0, 0)
+		record = append(record, epoch[:]...)
+		record = c.sealer.aead.Seal(record, nonce, buffer[written:written+size], epoch[:])
+		binary.BigEndian.PutUint32(record, uint32(len(record)-recordHeaderSize))
+		c.record = record
+
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+		written += size
+	}
+
+	return written, nil
+}
+
+// ratchetSealer moves records written on to the next key, overwriting the
+// one they were sealed under so far.
+func (c *secureConn) ratchetSealer() error {
+	next, err := c.sealer.ratchet()
+	if err != nil {
+		return err
+	}
+
+	c.sealer.zeroize()
+	c.sealer = next
+	c.since = c.now()
+	return nil
+}
+
+// Read reads out what remains of the last record opened, opening the next
+// record should none remain.
+func (c *secureConn) Read(buffer []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	for len(c.plaintext) == 0 {
+		if err := c.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	read := copy(buffer, c.plaintext)
+	c.plaintext = c.plaintext[read:]
+	return read, nil
+}
+
+// open reads and opens the next record.
+func (c *secureConn) open() error {
+	if _, err := io.ReadFull(c.Conn, c.header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(c.header[:4])
+	opener, err := c.opener(binary.BigEndian.Uint32(c.header[4:]))
+	if err != nil {
+		return err
+	}
+
+	if size < uint32(opener.aead.Overhead()) || size > uint32(maxRecordSize+opener.aead.Overhead()) {
+		return errors.Errorf("secure: record has length of %d which is either broken or too large", size)
+	}
+
+	if cap(c.sealed) < int(size) {
+		c.sealed = make([]byte, maxRecordSize+opener.aead.Overhead())
+	}
+	sealed := c.sealed[:size]
+
+	if _, err := io.ReadFull(c.Conn, sealed); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	nonce, err := opener.next()
+	if err != nil {
+		return err
+	}
+
+	// Records are opened in place, as their plaintext is read out before the
+	// next record is read over it.
+	c.plaintext, err = opener.aead.Open(sealed[:0], nonce, sealed, c.header[4:])
+	if err != nil {
+		return errors.New("secure: record failed authentication")
+	}
+	return nil
+}
+
+// opener returns the cipher records sealed under the key of an epoch are
+// opened with, ratcheting on to the epoch should it follow the latest one.
+func (c *secureConn) opener(epoch uint32) (*recordCipher, error) {
+	latest := c.openers[len(c.openers)-1]
+	if epoch > latest.epoch {
+		if epoch != latest.epoch+1 {
+			return nil, errors.Errorf("secure: record sealed under key epoch %d, skipping past %d", epoch, latest.epoch+1)
+		}
+
+		next, err := latest.ratchet()
+		if err != nil {
+			return nil, err
+		}
+
+		c.openers = append(c.openers, next)
+		if len(c.openers) > ratchetWindow {
+			c.openers[0].zeroize()
+			c.openers = append(c.openers[:0], c.openers[1:]...)
+		}
+		return next, nil
+	}
+
+	for _, opener := range c.openers {
+		if opener.epoch == epoch {
+			return opener, nil
+		}
+	}
+	return nil, errors.Errorf("secure: record sealed under key epoch %d, older than the ratchet window", epoch)
+}
@@ -0,0 +1,340 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLayer records everything written over the connections it dials.
+type recordingLayer struct {
+	transport.Layer
+
+	sync.Mutex
+	written bytes.Buffer
+}
+
+func (l *recordingLayer) Dial(address string) (net.Conn, error) {
+	conn, err := l.Layer.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: conn, layer: l}, nil
+}
+
+func (l *recordingLayer) contains(data []byte) bool {
+	l.Lock()
+	defer l.Unlock()
+	return bytes.Contains(l.written.Bytes(), data)
+}
+
+type recordingConn struct {
+	net.Conn
+	layer *recordingLayer
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.layer.Lock()
+	c.layer.written.Write(b)
+	c.layer.Unlock()
+	return c.Conn.Write(b)
+}
+
+// bufferConn is a connection reading back what was written to it.
+type bufferConn struct {
+	net.Conn
+	bytes.Buffer
+}
+
+func (c *bufferConn) Read(b []byte) (int, error) {
+	return c.Buffer.Read(b)
+}
+
+func (c *bufferConn) Write(b []byte) (int, error) {
+	return c.Buffer.Write(b)
+}
+
+func TestEncryptedSession(t *testing.T) {
+	t.Parallel()
+
+	arrivals := make(chan string, 4)
+
+	builder := NewBuilderWithOptions(Encryption(EncryptionRequired))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			arrivals <- msg.Message
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	layer := &recordingLayer{Layer: transport.NewTCP()}
+
+	builder = NewBuilderWithOptions(Encryption(EncryptionPreferred))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("tcp", layer)
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.True(t, client.Encrypted())
+
+	// Messages spanning many records are reassembled whole.
+	for _, message := range []string{"confidential", strings.Repeat("s", 3*maxRecordSize+100)} {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: message}))
+
+		select {
+		case arrival := <-arrivals:
+			assert.Equal(t, message, arrival)
+		case <-time.After(3 * time.Second):
+			t.Fatal("message was never received")
+		}
+	}
+
+	assert.False(t, layer.contains([]byte("confidential")))
+	assert.False(t, layer.contains([]byte(strings.Repeat("s", 64))))
+}
+
+func TestEncryptionIsNegotiated(t *testing.T) {
+	t.Parallel()
+
+	// Peers which do not encrypt are talked to in plaintext, unless
+	// encryption is required.
+	receiver, sender, client, arrivals := connectPadded(t, nil, Encryption(EncryptionPreferred))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.False(t, client.Encrypted())
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "plaintext"}))
+	assert.Equal(t, "plaintext", receivePadded(t, arrivals).message)
+
+	required := buildListeningNode(t, Encryption(EncryptionRequired))
+	defer required.Close()
+
+	plaintext := buildListeningNode(t)
+	defer plaintext.Close()
+
+	_, err := plaintext.Client(required.Address)
+	assert.NotNil(t, err)
+
+	_, err = required.Client(plaintext.Address)
+	assert.Equal(t, ErrEncryptionRequired, errors.Cause(err))
+
+	_, err = NewBuilderWithOptions(Encryption(EncryptionRequired), AllowLegacyPeers(true)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestSecureConn(t *testing.T) {
+	t.Parallel()
+
+	dialer, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	acceptor, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	dialerKeys, err := deriveSessionKeys(dialer, acceptor.PublicKey().Bytes(), true, []byte("dialer"), []byte("acceptor"), nil)
+	assert.Nil(t, err)
+	acceptorKeys, err := deriveSessionKeys(acceptor, dialer.PublicKey().Bytes(), false, []byte("acceptor"), []byte("dialer"), nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, dialerKeys.send, acceptorKeys.receive)
+	assert.Equal(t, dialerKeys.receive, acceptorKeys.send)
+	assert.NotEqual(t, dialerKeys.send, dialerKeys.receive)
+
+	_, err = deriveSessionKeys(dialer, []byte("short"), true, nil, nil, nil)
+	assert.NotNil(t, err)
+
+	wire := new(bufferConn)
+	writer, err := secure(wire, &handshakeResult{keys: dialerKeys}, time.Now)
+	assert.Nil(t, err)
+	reader, err := secure(wire, &handshakeResult{keys: acceptorKeys}, time.Now)
+	assert.Nil(t, err)
+
+	message := bytes.Repeat([]byte("m"), 2*maxRecordSize+1)
+	written, err := writer.Write(message)
+	assert.Nil(t, err)
+	assert.Equal(t, len(message), written)
+	assert.False(t, bytes.Contains(wire.Bytes(), message[:64]))
+
+	read := make([]byte, len(message))
+	_, err = io.ReadFull(reader, read)
+	assert.Nil(t, err)
+	assert.Equal(t, message, read)
+
+	// Records replayed fail to open, as do records tampered with.
+	_, err = writer.Write([]byte("once"))
+	assert.Nil(t, err)
+	record := append([]byte(nil), wire.Bytes()...)
+	wire.Write(record)
+
+	_, err = io.ReadFull(reader, read[:4])
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("once"), read[:4])
+
+	_, err = reader.Read(read)
+	assert.NotNil(t, err)
+
+	writer, _ = secure(wire, &handshakeResult{keys: dialerKeys}, time.Now)
+	reader, _ = secure(wire, &handshakeResult{keys: acceptorKeys}, time.Now)
+	wire.Reset()
+
+	_, err = writer.Write([]byte("tampered"))
+	assert.Nil(t, err)
+	wire.Bytes()[recordHeaderSize] ^= 1
+
+	_, err = reader.Read(read)
+	assert.NotNil(t, err)
+}
+
+func TestKeyRatchet(t *testing.T) {
+	t.Parallel()
+
+	dialer, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	acceptor, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	dialerKeys, err := deriveSessionKeys(dialer, acceptor.PublicKey().Bytes(), true, []byte("dialer"), []byte("acceptor"), nil)
+	assert.Nil(t, err)
+	acceptorKeys, err := deriveSessionKeys(acceptor, dialer.PublicKey().Bytes(), false, []byte("acceptor"), []byte("dialer"), nil)
+	assert.Nil(t, err)
+
+	stale := append([]byte(nil), dialerKeys.send...)
+
+	// Keys are ratcheted after every other record.
+	dialerKeys.ratchet = ratchetSchedule{records: 2}
+	acceptorKeys.ratchet = dialerKeys.ratchet
+
+	wire := new(bufferConn)
+	writer, err := secure(wire, &handshakeResult{keys: dialerKeys}, time.Now)
+	assert.Nil(t, err)
+	reader, err := secure(wire, &handshakeResult{keys: acceptorKeys}, time.Now)
+	assert.Nil(t, err)
+
+	sealed := writer.(*secureConn).sealer.key
+
+	messages := []string{"zero", "one", "two", "three", "four"}
+	for _, message := range messages {
+		_, err = writer.Write([]byte(message))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, uint32(2), writer.(*secureConn).sealer.epoch)
+
+	// Keys ratcheted past are overwritten.
+	assert.Equal(t, make([]byte, len(sealed)), sealed)
+
+	for _, message := range messages {
+		read := make([]byte, len(message))
+		_, err = io.ReadFull(reader, read)
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(read))
+	}
+
+	// Readers only keep the keys of the last few epochs.
+	openers := reader.(*secureConn).openers
+	assert.Len(t, openers, ratchetWindow)
+	assert.Equal(t, uint32(1), openers[0].epoch)
+
+	// Records sealed under keys older than that are rejected.
+	old, err := secure(wire, &handshakeResult{keys: &sessionKeys{send: stale, receive: append([]byte(nil), stale...)}}, time.Now)
+	assert.Nil(t, err)
+	_, err = old.Write([]byte("stale"))
+	assert.Nil(t, err)
+
+	_, err = reader.Read(make([]byte, 8))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "older than the ratchet window")
+	}
+
+	// Keys sealing records for long enough are ratcheted too.
+	clock := &fakeClock{now: time.Now()}
+	timed, err := secure(new(bufferConn), &handshakeResult{keys: &sessionKeys{
+		send:    append([]byte(nil), stale...),
+		receive: append([]byte(nil), stale...),
+		ratchet: ratchetSchedule{interval: time.Minute},
+	}}, clock.Now)
+	assert.Nil(t, err)
+
+	_, err = timed.Write([]byte("now"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), timed.(*secureConn).sealer.epoch)
+
+	clock.Advance(time.Minute)
+	_, err = timed.Write([]byte("later"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), timed.(*secureConn).sealer.epoch)
+
+	// Peers ratchet keys on whichever schedule is stricter.
+	negotiated := ratchetSchedule{records: 100}.stricter(ratchetSchedule{records: 1000, interval: time.Minute})
+	assert.Equal(t, ratchetSchedule{records: 100, interval: time.Minute}, negotiated)
+}
+
+func TestKeyRatchetOverNetwork(t *testing.T) {
+	t.Parallel()
+
+	arrivals := make(chan string, 8)
+
+	builder := NewBuilderWithOptions(Encryption(EncryptionRequired), KeyRatchet(2, 0), SessionResumption(time.Minute), OrderedHandlers(&testpb.TestMessage{}))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			arrivals <- msg.Message
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t, Encryption(EncryptionPreferred), SessionResumption(time.Minute))
+	defer sender.Close()
+
+	// Messages keep arriving across the keys the receiver had ratcheted, and
+	// over the encrypted connections of resumed sessions alike.
+	for round := 0; round < 2; round++ {
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+		assert.True(t, client.Encrypted())
+
+		for i := 0; i < 5; i++ {
+			message := fmt.Sprintf("message %d of round %d", i, round)
+			assert.Nil(t, client.Tell(&testpb.TestMessage{Message: message}))
+
+			select {
+			case arrival := <-arrivals:
+				assert.Equal(t, message, arrival)
+			case <-time.After(3 * time.Second):
+				t.Fatal("message was never received")
+			}
+		}
+
+		client.Close()
+		assert.True(t, waitUntil(5*time.Second, func() bool {
+			return len(sender.Peers()) == 0 && len(receiver.Peers()) == 0
+		}), "peers never disconnected")
+	}
+}
@@ -0,0 +1,121 @@
+package network
+
+import (
+	"encoding/hex"
+	"net"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// EphemeralCapability is advertised by ephemeral nodes, which have no
+// listenable address: they are identified purely by their public keys, and
+// reached only over the connections they dial.
+const EphemeralCapability = "noise/ephemeral"
+
+// ephemeralScheme prefixes the addresses ephemeral peers are keyed by, which
+// are never dialed.
+const ephemeralScheme = "ephemeral://"
+
+// ephemeralAddress returns the address an ephemeral peer is keyed by.
+func ephemeralAddress(publicKey []byte) string {
+	return ephemeralScheme + hex.EncodeToString(publicKey)
+}
+
+// isEphemeralOffer returns true if a peer handshook as an ephemeral peer.
+func isEphemeralOffer(offer *protobuf.HandshakeOffer) bool {
+	return offer != nil && containsString(offer.Capabilities, EphemeralCapability)
+}
+
+// validSenderAddress returns true if a sender advertises an address, unless
+// it is ephemeral, in which case it must advertise none.
+func validSenderAddress(sender *protobuf.ID, offer *protobuf.HandshakeOffer) bool {
+	return (len(sender.Address) == 0) == isEphemeralOffer(offer)
+}
+
+// IsEphemeral returns true should the node run without a listenable address.
+func (n *Network) IsEphemeral() bool {
+	return n.opts.ephemeral
+}
+
+// ephemeralClient creates the client of an ephemeral peer which connected to
+// us, keyed by its public key. Rather than being dialed back, messages are
+// written to the peer over the connection it dialed, which replaces any
+// connection it dialed before.
+func (n *Network) ephemeralClient(conn net.Conn, handshake *handshakeResult) (*PeerClient, error) {
+	if n.isClosed() {
+		return nil, ErrNetworkClosed
+	}
+
+	address := ephemeralAddress(handshake.remote.PublicKey)
+
+	// The peer lost the connection it dialed before, should it have dialed
+	// us anew.
+	if stale, exists := n.peers.Load(address); exists {
+		stale.(*PeerClient).Close()
+	}
+
+	client := newPeerClient(n, address)
+	connected := false
+	defer func() {
+		client.setOutgoingReady()
+		if client.isClosed() {
+			client.releaseSlot()
+		}
+		if connected {
+			client.publishConnected()
+		}
+	}()
+
+	if _, exists := n.peers.LoadOrStore(address, client); exists {
+		return nil, errors.New("network: ephemeral peer connected twice at once")
+	}
+
+	var err error
+	client.direction = DirectionInbound
+	client.reserved, err = n.slots.acquire(DirectionInbound, false)
+	if err != nil {
+		n.peers.Delete(address)
+		return nil, err
+	}
+	client.holdSlot()
+
+	client.publicKey = handshake.remote.PublicKey
+	client.encrypted = handshake.keys != nil
+	client.offer = handshake.offer
+	n.adoptOfferedServices(client, handshake.services)
+
+	state := n.newConnState(client, address, conn, handshake.remote.PublicKey)
+
+	n.connections.Store(address, state)
+
+	// Close() may have gone through peers before the connection was stored.
+	if n.isClosed() || client.isClosed() {
+		client.Close()
+		n.connections.Delete(address)
+		state.sends.close(ErrNetworkClosed)
+		return nil, ErrNetworkClosed
+	}
+	n.spawnIn(SubsystemPeers, func() { n.sendLoop(address, state.sends) })
+
+	n.startQuarantine(client)
+	n.roam(client, state, handshake.remote.PublicKey)
+
+	client.Init()
+	connected = true
+	n.announceUpgradeTo(client)
+
+	n.notifyPeersChanged()
+	n.resumeOutbox(address)
+
+	return client, nil
+}
+
+// detachedClient creates a stub client of the sender of a message, such as
+// one replayed, keyed by its public key should the sender be ephemeral.
+func (n *Network) detachedClient(sender *protobuf.ID) (*PeerClient, error) {
+	if len(sender.Address) == 0 {
+		return newPeerClient(n, ephemeralAddress(sender.PublicKey)), nil
+	}
+	return createPeerClient(n, sender.Address)
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEphemeralPeerGetsRepliesOverItsSession(t *testing.T) {
+	t.Parallel()
+
+	server, _, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			assert.Nil(t, ctx.Reply(&testpb.TestMessage{Message: "re: " + msg.Message}))
+		}
+	})
+	defer server.Close()
+
+	// The client binds no listener, and could not be dialed back if it did.
+	builder := NewBuilderWithOptions(Ephemeral())
+	builder.SetKeys(ed25519.RandomKeyPair())
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	node.Listen()
+	<-node.Ready()
+
+	assert.True(t, node.IsEphemeral())
+	assert.Empty(t, node.ID.Address)
+
+	client, err := node.Client(server.Address)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	reply, err := client.RequestContext(ctx, &testpb.TestMessage{Message: "hello"})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "re: hello", reply.(*testpb.TestMessage).Message)
+	}
+
+	// The client is known by its public key alone, and was never dialed back.
+	address := ephemeralAddress(node.GetKeys().PublicKey)
+
+	var ephemeral *PeerInfo
+	for _, info := range server.Peers() {
+		info := info
+		if info.Address == address {
+			ephemeral = &info
+		}
+	}
+	if assert.NotNil(t, ephemeral) {
+		assert.Equal(t, DirectionInbound, ephemeral.Direction)
+	}
+	assert.Equal(t, 0, countPeers(server, DirectionOutbound))
+
+	// Nor is it shared with, or routed through by, other peers.
+	peerClient, connected := server.PeerByID(peerIDOfNode(t, node))
+	if assert.True(t, connected) {
+		assert.True(t, peerClient.HasCapability(ClientCapability))
+		assert.False(t, peerClient.HasCapability(RoutingCapability))
+	}
+	for _, record := range server.knownPeers(time.Now()) {
+		assert.NotEqual(t, node.GetKeys().PublicKey, record.PublicKey)
+	}
+
+	// Disconnecting simply forgets the client.
+	node.Close()
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return !server.ConnectionStateExists(address)
+	}), "ephemeral peer was never forgotten")
+}
+
+func TestSenderAddressMustMatchEphemerality(t *testing.T) {
+	t.Parallel()
+
+	ephemeral := &protobuf.HandshakeOffer{Capabilities: []string{EphemeralCapability}}
+	listening := &protobuf.HandshakeOffer{Capabilities: []string{RoutingCapability}}
+
+	assert.True(t, validSenderAddress(&protobuf.ID{}, ephemeral))
+	assert.False(t, validSenderAddress(&protobuf.ID{Address: "tcp://127.0.0.1:3000"}, ephemeral))
+	assert.True(t, validSenderAddress(&protobuf.ID{Address: "tcp://127.0.0.1:3000"}, listening))
+	assert.False(t, validSenderAddress(&protobuf.ID{}, listening))
+
+	_, err := NewBuilderWithOptions(Ephemeral(), PeerSampling(8, time.Second, 1, 1)).Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,501 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultEventReplaySize is the number of recent events held for
+	// subscribers to be replayed by default.
+	defaultEventReplaySize = 64
+	// defaultEventBufferSize is the number of events buffered for a
+	// subscriber before further ones are dropped by default.
+	defaultEventBufferSize = 64
+)
+
+// ErrEventVetoed is the cause of errors failing what an event announced, as
+// a hook consulted on it vetoed it.
+var ErrEventVetoed = errors.New("network: vetoed by event hook")
+
+// EventKind tells apart events published on the network's event bus.
+type EventKind int
+
+const (
+	// EventPeerConnected is published as a peer connects. It is the event
+	// hooks may veto, before the peer is admitted.
+	EventPeerConnected EventKind = iota
+	// EventPeerDisconnected is published as a peer disconnects.
+	EventPeerDisconnected
+	// EventDialFailed is published as connecting to a peer fails.
+	EventDialFailed
+	// EventHandshakeFailed is published as a handshake fails.
+	EventHandshakeFailed
+	// EventCircuitChanged is published as the circuit to a peer changes
+	// state.
+	EventCircuitChanged
+	// EventPeerTagged is published as a peer is tagged or untagged.
+	EventPeerTagged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPeerConnected:
+		return "peer connected"
+	case EventPeerDisconnected:
+		return "peer disconnected"
+	case EventDialFailed:
+		return "dial failed"
+	case EventHandshakeFailed:
+		return "handshake failed"
+	case EventCircuitChanged:
+		return "circuit changed"
+	case EventPeerTagged:
+		return "peer tagged"
+	default:
+		return "unknown"
+	}
+}
+
+// hookable returns true if hooks may be consulted on events of the kind.
+func (k EventKind) hookable() bool {
+	return k == EventPeerConnected
+}
+
+// Event is published on the network's event bus, being one of
+// *PeerConnectedEvent, *PeerDisconnectedEvent, *DialFailedEvent,
+// *HandshakeFailedEvent, *CircuitChangedEvent or *PeerTaggedEvent.
+type Event interface {
+	// Kind returns the kind of the event.
+	Kind() EventKind
+
+	header() *EventHeader
+}
+
+// EventHeader is what every event holds.
+type EventHeader struct {
+	// Seq orders events as they were published, starting from 1. Events
+	// hooks are consulted on are yet to be published, and hold none.
+	Seq uint64
+	// Time is when the event was published.
+	Time time.Time
+	// Peer is the peer the event is about, being zero should it not be known.
+	Peer PeerID
+	// Address is the address of the peer the event is about.
+	Address string
+}
+
+func (h *EventHeader) header() *EventHeader {
+	return h
+}
+
+// PeerConnectedEvent announces that a peer connected. Hooks are consulted
+// with it for every connection a handshake completed over, before the peer
+// is admitted, in which case Info holds what the handshake established of
+// the peer alone.
+type PeerConnectedEvent struct {
+	EventHeader
+	Info PeerInfo
+}
+
+// Kind implements Event.
+func (*PeerConnectedEvent) Kind() EventKind { return EventPeerConnected }
+
+// PeerDisconnectedEvent announces that a peer disconnected.
+type PeerDisconnectedEvent struct {
+	EventHeader
+	// Reason is why the peer was disconnected, if known.
+	Reason string
+}
+
+// Kind implements Event.
+func (*PeerDisconnectedEvent) Kind() EventKind { return EventPeerDisconnected }
+
+// DialFailedEvent announces that connecting to a peer failed.
+type DialFailedEvent struct {
+	EventHeader
+	Err error
+}
+
+// Kind implements Event.
+func (*DialFailedEvent) Kind() EventKind { return EventDialFailed }
+
+// HandshakeFailedEvent announces that a handshake failed.
+type HandshakeFailedEvent struct {
+	EventHeader
+	Failure HandshakeFailure
+}
+
+// Kind implements Event.
+func (*HandshakeFailedEvent) Kind() EventKind { return EventHandshakeFailed }
+
+// CircuitChangedEvent announces that the circuit to a peer changed state.
+type CircuitChangedEvent struct {
+	EventHeader
+	State CircuitState
+}
+
+// Kind implements Event.
+func (*CircuitChangedEvent) Kind() EventKind { return EventCircuitChanged }
+
+// PeerTaggedEvent announces that a peer was tagged or untagged.
+type PeerTaggedEvent struct {
+	EventHeader
+	Tag    string
+	Tagged bool
+}
+
+// Kind implements Event.
+func (*PeerTaggedEvent) Kind() EventKind { return EventPeerTagged }
+
+// EventFilter selects which events are delivered to a subscription. Empty
+// fields match everything.
+type EventFilter struct {
+	// Kinds limits the subscription to events of the given kinds.
+	Kinds []EventKind
+	// Peers limits the subscription to events about the given peers.
+	Peers []PeerID
+	// Tags limits the subscription to events about peers holding any of the
+	// given tags as the event was published, and to peers being tagged or
+	// untagged with any of them.
+	Tags []string
+}
+
+// publishedEvent is an event alongside the tags its peer held as it was
+// published.
+type publishedEvent struct {
+	event Event
+	tags  []string
+}
+
+func (f *EventFilter) matches(published publishedEvent) bool {
+	event := published.event
+
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, kind := range f.Kinds {
+			if kind == event.Kind() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Peers) > 0 {
+		found := false
+		for _, id := range f.Peers {
+			if id.Equal(event.header().Peer) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Tags) > 0 {
+		found := false
+		for _, tag := range published.tags {
+			if containsString(f.Tags, tag) {
+				found = true
+				break
+			}
+		}
+		if tagged, ok := event.(*PeerTaggedEvent); ok && containsString(f.Tags, tagged.Tag) {
+			found = true
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SubscribeOption configures a subscription to the event bus.
+type SubscribeOption func(o *subscribeOptions)
+
+type subscribeOptions struct {
+	replay     bool
+	bufferSize int
+}
+
+// ReplayEvents returns a SubscribeOption that delivers the recent events held
+// through EventReplay matching the subscription's filter ahead of any
+// published afterwards.
+func ReplayEvents() SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.replay = true
+	}
+}
+
+// EventBuffer returns a SubscribeOption that sets the number of events
+// buffered for the subscription before further ones are dropped (default:
+// 64).
+func EventBuffer(size int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.bufferSize = size
+	}
+}
+
+// EventSubscription is a live stream of events matching a filter.
+type EventSubscription struct {
+	// C delivers events, and is closed once the subscription is
+	// unsubscribed, or the network closed.
+	C <-chan Event
+
+	ch      chan Event
+	filter  EventFilter
+	dropped uint64 // for atomic ops
+}
+
+// Dropped returns the number of events dropped for not being consumed in
+// time.
+func (s *EventSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// deliver hands an event to the subscription, should it match its filter and
+// fit in its buffer.
+func (s *EventSubscription) deliver(published publishedEvent) {
+	if !s.filter.matches(published) {
+		return
+	}
+
+	select {
+	case s.ch <- published.event:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// eventHook is a hook consulted on events of a kind.
+type eventHook struct {
+	kind    EventKind
+	timeout time.Duration
+	fn      EventHook
+}
+
+// EventHook is consulted on an event before the network proceeds with what
+// it announces, vetoing it by returning an error. The context expires once
+// the hook's timeout elapses, after which the network proceeds regardless.
+type EventHook func(ctx context.Context, event Event) error
+
+// EventStats describes the event bus.
+type EventStats struct {
+	// Published is the number of events published.
+	Published uint64
+	// Subscribers is the number of subscriptions to the bus.
+	Subscribers int
+	// Dropped is the number of events dropped across all subscriptions for
+	// not being consumed in time.
+	Dropped uint64
+	// Vetoes is the number of events hooks vetoed.
+	Vetoes uint64
+	// HookTimeouts is the number of times the network proceeded with an
+	// event as a hook consulted on it timed out.
+	HookTimeouts uint64
+}
+
+// eventBus keeps the recent events published, and every subscription to
+// the events published.
+type eventBus struct {
+	sync.Mutex
+
+	seq    uint64
+	recent []publishedEvent
+	next   int
+	subs   map[*EventSubscription]struct{}
+
+	// dropped counts the events dropped by subscriptions since unsubscribed.
+	dropped uint64
+
+	vetoes       uint64
+	hookTimeouts uint64
+}
+
+// SubscribeEvents subscribes to events published on the network's event bus
+// matching a filter, returning the subscription alongside a function which
+// unsubscribes it. Subscriptions end once the network is closed. A slow
+// subscriber never holds up the network; events which do not fit in its
+// buffer are dropped and counted instead.
+func (n *Network) SubscribeEvents(filter EventFilter, opts ...SubscribeOption) (*EventSubscription, func()) {
+	o := subscribeOptions{bufferSize: defaultEventBufferSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.bufferSize <= 0 {
+		o.bufferSize = defaultEventBufferSize
+	}
+
+	ch := make(chan Event, o.bufferSize)
+	sub := &EventSubscription{C: ch, ch: ch, filter: filter}
+
+	n.events.Lock()
+	if o.replay {
+		n.eachRecentEvent(sub.deliver)
+	}
+	if n.events.subs == nil {
+		n.events.subs = make(map[*EventSubscription]struct{})
+	}
+	n.events.subs[sub] = struct{}{}
+	n.events.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			n.events.Lock()
+			delete(n.events.subs, sub)
+			n.events.dropped += sub.Dropped()
+			close(sub.ch)
+			n.events.Unlock()
+		})
+	}
+
+	n.spawn(func() {
+		<-n.kill
+		unsubscribe()
+	})
+
+	return sub, unsubscribe
+}
+
+// eachRecentEvent calls fn with the recent events held, oldest first. The
+// bus must be locked.
+func (n *Network) eachRecentEvent(fn func(published publishedEvent)) {
+	size := len(n.events.recent)
+	if size < n.opts.eventReplaySize {
+		for _, published := range n.events.recent {
+			fn(published)
+		}
+		return
+	}
+
+	for i := 0; i < size; i++ {
+		fn(n.events.recent[(n.events.next+i)%size])
+	}
+}
+
+// EventStats returns how many events were published, delivered and vetoed.
+func (n *Network) EventStats() EventStats {
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	stats := EventStats{
+		Published:    n.events.seq,
+		Subscribers:  len(n.events.subs),
+		Dropped:      n.events.dropped,
+		Vetoes:       n.events.vetoes,
+		HookTimeouts: n.events.hookTimeouts,
+	}
+	for sub := range n.events.subs {
+		stats.Dropped += sub.Dropped()
+	}
+
+	return stats
+}
+
+// eventHeader returns the header of an event about a peer, looking up its ID
+// by its address should it not be known.
+func (n *Network) eventHeader(id PeerID, address string) EventHeader {
+	if id.IsZero() && address != "" {
+		if client, exists := n.peers.Load(address); exists {
+			id = client.(*PeerClient).PeerID()
+		}
+	}
+	return EventHeader{Peer: id, Address: address}
+}
+
+// publishEvent publishes an event, holding it among the recent events to be
+// replayed and delivering it to every subscription it matches.
+func (n *Network) publishEvent(event Event) {
+	published := publishedEvent{event: event}
+	if header := event.header(); !header.Peer.IsZero() {
+		published.tags = n.tags.of(header.Peer)
+	}
+
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	n.events.seq++
+	header := event.header()
+	header.Seq = n.events.seq
+	header.Time = n.now()
+
+	if n.opts.eventReplaySize > 0 {
+		if len(n.events.recent) < n.opts.eventReplaySize {
+			n.events.recent = append(n.events.recent, published)
+		} else {
+			n.events.recent[n.events.next] = published
+		}
+		n.events.next = (n.events.next + 1) % n.opts.eventReplaySize
+	}
+
+	for sub := range n.events.subs {
+		sub.deliver(published)
+	}
+}
+
+// hookEvent consults every hook registered on an event's kind in turn,
+// failing with ErrEventVetoed should any veto it. Hooks are given up on once
+// their timeout elapses, so that a stuck hook never holds up the network.
+func (n *Network) hookEvent(event Event) error {
+	for _, hook := range n.opts.eventHooks {
+		if hook.kind != event.Kind() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+		result := make(chan error, 1)
+
+		fn := hook.fn
+		n.spawn(func() {
+			result <- fn(ctx, event)
+		})
+
+		select {
+		case err := <-result:
+			cancel()
+			if err != nil {
+				n.events.Lock()
+				n.events.vetoes++
+				n.events.Unlock()
+				return errors.Wrapf(ErrEventVetoed, "%s: %v", event.Kind(), err)
+			}
+		case <-ctx.Done():
+			cancel()
+			n.events.Lock()
+			n.events.hookTimeouts++
+			n.events.Unlock()
+			glog.Warningf("event hook on %s timed out after %s; proceeding", event.Kind(), hook.timeout)
+		}
+	}
+
+	return nil
+}
+
+// hookPeerConnected consults the hooks registered on EventPeerConnected over
+// a connection a handshake completed over. Control connections are opened
+// alongside connections peers were already admitted over, and are left be.
+func (n *Network) hookPeerConnected(result *handshakeResult, direction ConnDirection) error {
+	if len(n.opts.eventHooks) == 0 || result.control {
+		return nil
+	}
+
+	info, err := handshakeInfo(result, direction)
+	if err != nil {
+		return err
+	}
+
+	event := &PeerConnectedEvent{EventHeader: EventHeader{Peer: info.PeerID, Address: info.Address, Time: n.now()}, Info: info}
+	return n.hookEvent(event)
+}
@@ -0,0 +1,212 @@
+package network
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// nextEvent returns the next event delivered to a subscription.
+func nextEvent(t *testing.T, sub *EventSubscription) Event {
+	select {
+	case event := <-sub.C:
+		return event
+	case <-time.After(3 * time.Second):
+		t.Fatal("event was never delivered")
+		return nil
+	}
+}
+
+func TestEventsFilteredByKindAndTag(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	watched := buildListeningNode(t)
+	defer watched.Close()
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	sub, unsubscribe := node.SubscribeEvents(EventFilter{Kinds: []EventKind{EventPeerDisconnected}, Tags: []string{"watched"}})
+	defer unsubscribe()
+
+	toWatched, err := node.Client(watched.Address)
+	assert.Nil(t, err)
+	toOther, err := node.Client(other.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, watched), "watched"))
+
+	// Only disconnects of tagged peers are delivered, so that the disconnect
+	// of the other peer is filtered out ahead of it.
+	toOther.Close()
+	toWatched.Close()
+
+	event, ok := nextEvent(t, sub).(*PeerDisconnectedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, peerIDOfNode(t, watched), event.Peer)
+		assert.Equal(t, watched.Address, event.Address)
+	}
+
+	// Peers tagged or untagged with a tag match, even should they no longer
+	// hold it.
+	tagged, unsubscribeTagged := node.SubscribeEvents(EventFilter{Tags: []string{"watched"}})
+	defer unsubscribeTagged()
+
+	node.UntagPeer(peerIDOfNode(t, watched), "watched")
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, other), "unwatched"))
+
+	retagged, ok := nextEvent(t, tagged).(*PeerTaggedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, "watched", retagged.Tag)
+		assert.False(t, retagged.Tagged)
+	}
+	assert.Empty(t, tagged.C)
+}
+
+func TestEventsReplayedToLateSubscribers(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, EventReplay(3))
+	defer node.Close()
+
+	for _, tag := range []string{"a", "b", "c", "d", "e"} {
+		assert.Nil(t, node.TagPeer(randomPeerID(t), tag))
+	}
+
+	// Only the most recent events are held, and replayed oldest first.
+	sub, unsubscribe := node.SubscribeEvents(EventFilter{}, ReplayEvents())
+	defer unsubscribe()
+
+	for i, tag := range []string{"c", "d", "e"} {
+		event := nextEvent(t, sub).(*PeerTaggedEvent)
+		assert.Equal(t, tag, event.Tag)
+		assert.Equal(t, uint64(i+3), event.Seq)
+	}
+
+	filtered, unsubscribeFiltered := node.SubscribeEvents(EventFilter{Tags: []string{"d"}}, ReplayEvents())
+	defer unsubscribeFiltered()
+	assert.Equal(t, "d", nextEvent(t, filtered).(*PeerTaggedEvent).Tag)
+
+	// Subscribers not asking for a replay only see events published
+	// afterwards.
+	live, unsubscribeLive := node.SubscribeEvents(EventFilter{})
+	defer unsubscribeLive()
+	assert.Empty(t, live.C)
+
+	assert.Nil(t, node.TagPeer(randomPeerID(t), "f"))
+	assert.Equal(t, uint64(6), nextEvent(t, live).(*PeerTaggedEvent).Seq)
+	assert.Equal(t, "f", nextEvent(t, sub).(*PeerTaggedEvent).Tag)
+}
+
+func TestEventsDroppedForSlowSubscribers(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	slow, unsubscribeSlow := node.SubscribeEvents(EventFilter{}, EventBuffer(2))
+	fast, unsubscribeFast := node.SubscribeEvents(EventFilter{})
+	defer unsubscribeFast()
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, node.TagPeer(randomPeerID(t), "slow"))
+	}
+
+	assert.Equal(t, uint64(3), slow.Dropped())
+	assert.Zero(t, fast.Dropped())
+	assert.Len(t, fast.C, 5)
+
+	stats := node.EventStats()
+	assert.Equal(t, uint64(5), stats.Published)
+	assert.Equal(t, 2, stats.Subscribers)
+	assert.Equal(t, uint64(3), stats.Dropped)
+
+	// Events dropped remain counted once their subscriber unsubscribes.
+	unsubscribeSlow()
+	unsubscribeSlow()
+
+	var delivered int
+	for range slow.C {
+		delivered++
+	}
+	assert.Equal(t, 2, delivered)
+
+	stats = node.EventStats()
+	assert.Equal(t, 1, stats.Subscribers)
+	assert.Equal(t, uint64(3), stats.Dropped)
+}
+
+func TestEventHookVetoesConnection(t *testing.T) {
+	t.Parallel()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	node := buildListeningNode(t, HookEvent(EventPeerConnected, 200*time.Millisecond, func(ctx context.Context, event Event) error {
+		switch string(event.(*PeerConnectedEvent).Info.Metadata["role"]) {
+		case "banned":
+			return errors.New("banned")
+		case "stuck":
+			<-stuck
+		}
+		return nil
+	}))
+	defer node.Close()
+
+	sub, unsubscribe := node.SubscribeEvents(EventFilter{Kinds: []EventKind{EventPeerConnected, EventHandshakeFailed}})
+	defer unsubscribe()
+
+	withRole := func(role string) BuilderOption {
+		return PeerMetadata(func() map[string][]byte {
+			return map[string][]byte{"role": []byte(role)}
+		})
+	}
+
+	// Peers the hook vetoes are never admitted.
+	banned := buildListeningNode(t, withRole("banned"))
+	defer banned.Close()
+
+	client, err := banned.Client(node.Address)
+	if err == nil {
+		client.Tell(&testpb.TestMessage{Message: "hello"})
+	}
+
+	failed, ok := nextEvent(t, sub).(*HandshakeFailedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, peerIDOfNode(t, banned), failed.Peer)
+		assert.True(t, strings.Contains(failed.Failure.Reason, "vetoed"), failed.Failure.Reason)
+	}
+	assert.Equal(t, uint64(1), node.EventStats().Vetoes)
+
+	// Peers the hook gets stuck on are admitted once it times out.
+	slow := buildListeningNode(t, withRole("stuck"))
+	defer slow.Close()
+
+	client, err = slow.Client(node.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+	connected, ok := nextEvent(t, sub).(*PeerConnectedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, peerIDOfNode(t, slow), connected.Peer)
+		assert.Equal(t, "stuck", string(connected.Info.Metadata["role"]))
+
+		// Peers are only announced once their clients are ready to be handed out.
+		c, exists := node.peers.Load(connected.Address)
+		if assert.True(t, exists) {
+			select {
+			case <-c.(*PeerClient).outgoingReady:
+			default:
+				t.Error("peer was announced while still being dialed")
+			}
+		}
+	}
+	assert.True(t, node.EventStats().HookTimeouts >= 1)
+	assert.Equal(t, uint64(1), node.EventStats().Vetoes)
+}
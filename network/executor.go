@@ -0,0 +1,36 @@
+package network
+
+// Executor runs the tasks the network spawns internally, such as receive
+// loops, send loops, dials and message handlers. Tasks may block for as long
+// as the network is open.
+//
+// The default executor runs every task on a goroutine of its own. Tests may
+// substitute one which runs tasks one at a time, in an order of its choosing,
+// to reproduce a specific interleaving.
+type Executor interface {
+	// Go runs a task.
+	Go(task func())
+
+	// Yield is called by running tasks at points where they may be suspended
+	// in favor of other tasks.
+	Yield()
+}
+
+// goroutineExecutor runs every task on a goroutine of its own.
+type goroutineExecutor struct{}
+
+func (goroutineExecutor) Go(task func()) {
+	go task()
+}
+
+func (goroutineExecutor) Yield() {}
+
+// spawn runs a task on the network's executor.
+func (n *Network) spawn(task func()) {
+	n.opts.executor.Go(task)
+}
+
+// yield lets the network's executor suspend the running task.
+func (n *Network) yield() {
+	n.opts.executor.Yield()
+}
@@ -0,0 +1,185 @@
+package network
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Extension identifies an optional feature which adds fields to message
+// envelopes. Receivers ignore fields they do not understand, unless the
+// sender lists the extension which produced them as critical.
+type Extension uint32
+
+const (
+	// ExtensionMetadata is carried by envelopes enriched with metadata by
+	// outbound hooks.
+	ExtensionMetadata Extension = 1
+	// ExtensionSignatureSchemes is carried by envelopes signed under schemes
+	// other than the primary one.
+	ExtensionSignatureSchemes Extension = 2
+	// ExtensionProtocol is carried by envelopes sent under a protocol tag,
+	// which peers unaware of tags must not hand over to their plugins.
+	ExtensionProtocol Extension = 3
+	// ExtensionHints is carried by envelopes carrying routing hints, which
+	// peers unaware of hints ignore.
+	ExtensionHints Extension = 4
+	// ExtensionCanonicalSigning is carried by envelopes signed over their
+	// canonical preimage, which peers unaware of it could not verify.
+	ExtensionCanonicalSigning Extension = 5
+	// ExtensionReplayNonce is carried by envelopes stamped with a replay
+	// nonce, which peers unaware of it could not verify.
+	ExtensionReplayNonce Extension = 6
+	// ExtensionSignatureScheme is carried by envelopes signed under a primary
+	// scheme other than ed25519, which peers unaware of it could not verify.
+	ExtensionSignatureScheme Extension = 7
+)
+
+// ErrUnknownCriticalExtension is the error a message is rejected with should
+// it carry a critical extension this node does not implement.
+var ErrUnknownCriticalExtension = errors.New("network: message carries an unknown critical extension")
+
+type extension struct {
+	name     string
+	critical bool
+}
+
+var extensions = struct {
+	sync.RWMutex
+	registered map[Extension]extension
+}{
+	registered: map[Extension]extension{
+		ExtensionMetadata:         {name: "metadata"},
+		ExtensionSignatureSchemes: {name: "signature-schemes"},
+		ExtensionProtocol:         {name: "protocol", critical: true},
+		ExtensionHints:            {name: "hints"},
+		ExtensionCanonicalSigning: {name: "canonical-signing", critical: true},
+		ExtensionReplayNonce:      {name: "replay-nonce", critical: true},
+		ExtensionSignatureScheme:  {name: "signature-scheme", critical: true},
+	},
+}
+
+// RegisterExtension registers an extension this node implements. Envelopes
+// marked with a critical extension list it as such, so that peers which do not
+// implement it reject them rather than misread them. Errors if the identifier
+// is already taken.
+func RegisterExtension(id Extension, name string, critical bool) error {
+	extensions.Lock()
+	defer extensions.Unlock()
+
+	if registered, exists := extensions.registered[id]; exists {
+		return errors.Errorf("network: extension %d is already registered as %q", id, registered.name)
+	}
+
+	extensions.registered[id] = extension{name: name, critical: critical}
+	return nil
+}
+
+// MarkExtension records that an extension produced fields of an envelope,
+// listing it as critical should it be registered as such. Outbound hooks
+// adding fields on behalf of an extension should mark them. Errors if the
+// extension is not registered.
+func MarkExtension(msg *Envelope, id Extension) error {
+	extensions.RLock()
+	registered, exists := extensions.registered[id]
+	extensions.RUnlock()
+
+	if !exists {
+		return errors.Errorf("network: extension %d is not registered", id)
+	}
+
+	if !registered.critical {
+		return nil
+	}
+
+	for _, critical := range msg.CriticalExtensions {
+		if Extension(critical) == id {
+			return nil
+		}
+	}
+	msg.CriticalExtensions = append(msg.CriticalExtensions, uint32(id))
+
+	return nil
+}
+
+// markExtensions marks the extensions which produced the fields of an
+// envelope about to be signed.
+func markExtensions(msg *Envelope) {
+	if len(msg.Metadata) > 0 {
+		MarkExtension(msg, ExtensionMetadata)
+	}
+	if len(msg.Signatures) > 0 {
+		MarkExtension(msg, ExtensionSignatureSchemes)
+	}
+	if len(msg.Protocol) > 0 {
+		MarkExtension(msg, ExtensionProtocol)
+	}
+	if len(msg.Hints) > 0 {
+		MarkExtension(msg, ExtensionHints)
+	}
+	if msg.ReplayNonce != 0 {
+		MarkExtension(msg, ExtensionReplayNonce)
+	}
+	if len(msg.SignatureScheme) > 0 {
+		MarkExtension(msg, ExtensionSignatureScheme)
+	}
+}
+
+// serializeCriticalExtensions appends the critical extensions of a message to
+// its serialized envelope, so that they may not be stripped in transit.
+func serializeCriticalExtensions(serialized []byte, critical []uint32) []byte {
+	var id [4]byte
+	for _, extension := range critical {
+		binary.LittleEndian.PutUint32(id[:], extension)
+		serialized = append(serialized, id[:]...)
+	}
+	return serialized
+}
+
+// serializeProtocol appends the protocol tag of a message to its serialized
+// envelope, so that a message may not be moved to another protocol in transit.
+func serializeProtocol(serialized []byte, protocol string) []byte {
+	if len(protocol) == 0 {
+		return serialized
+	}
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(protocol)))
+	serialized = append(serialized, size[:]...)
+	return append(serialized, protocol...)
+}
+
+// checkExtensions returns ErrUnknownCriticalExtension should a message carry a
+// critical extension which is not registered.
+func checkExtensions(msg *Envelope) error {
+	if len(msg.CriticalExtensions) == 0 {
+		return nil
+	}
+
+	extensions.RLock()
+	defer extensions.RUnlock()
+
+	for _, id := range msg.CriticalExtensions {
+		if _, exists := extensions.registered[Extension(id)]; !exists {
+			return errors.Wrapf(ErrUnknownCriticalExtension, "extension %d", id)
+		}
+	}
+
+	return nil
+}
+
+// reportViolation restarts the quarantine of a peer which sent a message in
+// breach of the protocol, and reports it.
+func (n *Network) reportViolation(client *PeerClient, err error) {
+	glog.Errorf("network: rejected message from %s: %v", client.Address, err)
+
+	atomic.AddUint64(&client.violations, 1)
+	n.restartQuarantine(client)
+
+	if n.opts.onViolation != nil {
+		n.opts.onViolation(client, err)
+	}
+}
@@ -0,0 +1,119 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// unknownExtension is an extension only newer nodes implement.
+const unknownExtension Extension = 1 << 30
+
+// TestRejectUnknownCriticalExtension has a newer node send messages carrying
+// an extension the receiver does not implement, which are only rejected once
+// the extension is marked critical.
+func TestRejectUnknownCriticalExtension(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 2)
+	violations := make(chan error, 2)
+
+	receiver, idle, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	}, OnViolation(func(client *PeerClient, err error) {
+		violations <- err
+	}))
+	defer receiver.Close()
+	defer idle.Close()
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPeerIndependentOutboundHook(func(peer PeerInfo, msg *Envelope) error {
+		var message testpb.TestMessage
+		if types.UnmarshalAny(msg.Message, &message) == nil && message.Message == "critical" {
+			msg.CriticalExtensions = append(msg.CriticalExtensions, uint32(unknownExtension))
+		}
+		return nil
+	})
+
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "critical"}))
+
+	select {
+	case err := <-violations:
+		assert.Equal(t, ErrUnknownCriticalExtension, errors.Cause(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("message carrying an unknown critical extension was not rejected")
+	}
+
+	// The same fields, left unmarked, are ignored as unknown fields.
+	state, ok := sender.ConnectionState(receiver.Address)
+	assert.True(t, ok)
+
+	msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: "non-critical"})
+	assert.Nil(t, err)
+	msg.MessageNonce = state.messageNonce + 1
+	state.messageNonce++
+
+	bytes, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+
+	// Field 100 of the envelope, as a varint.
+	bytes = append(bytes, 0xa0, 0x06, 0x01)
+	assert.Nil(t, sender.writeFrame(state.writer, bytes, state.writerMutex))
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "non-critical", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message carrying unknown non-critical fields was not delivered")
+	}
+
+	assert.Equal(t, 0, len(violations))
+	assert.Equal(t, 0, len(received), "rejected message should not be delivered")
+}
+
+func TestMarkCriticalExtension(t *testing.T) {
+	const critical, hint Extension = 1<<30 + 1, 1<<30 + 2
+
+	assert.Nil(t, RegisterExtension(critical, "test-critical", true))
+	assert.Nil(t, RegisterExtension(hint, "test-hint", false))
+	defer func() {
+		extensions.Lock()
+		delete(extensions.registered, critical)
+		delete(extensions.registered, hint)
+		extensions.Unlock()
+	}()
+
+	assert.NotNil(t, RegisterExtension(ExtensionMetadata, "metadata", false), "identifiers should not be taken twice")
+
+	env := &Envelope{Sender: new(protobuf.ID), Message: new(types.Any)}
+	assert.Nil(t, MarkExtension(env, hint))
+	assert.Nil(t, MarkExtension(env, critical))
+	assert.Nil(t, MarkExtension(env, critical))
+	assert.NotNil(t, MarkExtension(env, unknownExtension))
+	assert.Equal(t, []uint32{uint32(critical)}, env.CriticalExtensions)
+
+	// Critical extensions are covered by the sender's signature.
+	assert.NotEqual(t, serializeEnvelope(&Envelope{Sender: env.Sender, Message: env.Message}), serializeEnvelope(env))
+
+	assert.Nil(t, checkExtensions(env))
+
+	env.CriticalExtensions = append(env.CriticalExtensions, uint32(unknownExtension))
+	assert.Equal(t, ErrUnknownCriticalExtension, errors.Cause(checkExtensions(env)))
+}
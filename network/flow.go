@@ -0,0 +1,179 @@
+package network
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SendBudget describes how much data bound for a peer is backed up on our
+// side, and how much more may be sent before it is held back. Applications
+// pacing their own producers may read it as often as they like.
+type SendBudget struct {
+	// Queued is the number of bytes of messages accepted for the peer which
+	// have yet to be written into its connection's buffer, whether queued up
+	// through WriteAsync, SendAsync or Broadcast, or waiting on shaping or
+	// on other writes to the peer. Messages queued through SendAsync are only
+	// counted once signed.
+	Queued int64
+	// Buffered is the number of bytes written into the connection's buffer
+	// which have yet to be flushed out.
+	Buffered int64
+	// InFlight is the number of bytes being written out to the connection,
+	// whose write has yet to return. Transports acknowledge no bytes, so
+	// bytes handed over to the operating system are not counted.
+	InFlight int64
+	// WindowHeadroom is how many more messages may be queued up before
+	// further ones fail with ErrSendWindowFull, or -1 should the send window
+	// be unbounded.
+	WindowHeadroom int
+	// Shaped is true if writes to the peer are subject to bandwidth limits.
+	Shaped bool
+	// ShapingHeadroom is how many bytes may be written to the peer before
+	// shaping delays writes, being negative while writes are in debt. It is
+	// zero unless writes are shaped.
+	ShapingHeadroom int64
+}
+
+// sendFlow counts the bytes bound for a peer at every stage of being written
+// out, and notifies the callback registered through OnSendWatermark as queued
+// bytes cross its watermarks.
+type sendFlow struct {
+	queued   int64 // for atomic ops
+	buffered int64 // for atomic ops
+	inFlight int64 // for atomic ops
+
+	high, low int64
+	notify    func(congested bool)
+
+	// Whether queued bytes crossed the high watermark without dropping back
+	// below the low one since. Notifications are sent under the mutex, so
+	// that they are never reordered.
+	mutex     sync.Mutex
+	congested int32 // for atomic ops
+}
+
+func (n *Network) newSendFlow(address string) *sendFlow {
+	f := &sendFlow{}
+	if fn := n.opts.onSendWatermark; fn != nil {
+		f.high, f.low = int64(n.opts.sendHighWatermark), int64(n.opts.sendLowWatermark)
+		f.notify = func(congested bool) { fn(address, congested) }
+	}
+	return f
+}
+
+// add counts bytes queued for, or no longer queued for, the peer.
+func (f *sendFlow) add(delta int64) {
+	if f == nil || delta == 0 {
+		return
+	}
+
+	queued := atomic.AddInt64(&f.queued, delta)
+	if f.notify == nil {
+		return
+	}
+
+	congested := atomic.LoadInt32(&f.congested) == 1
+	if congested == (delta > 0) || (delta > 0 && queued < f.high) || (delta < 0 && queued > f.low) {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	queued = atomic.LoadInt64(&f.queued)
+	switch {
+	case queued >= f.high && atomic.CompareAndSwapInt32(&f.congested, 0, 1):
+		f.notify(true)
+	case queued <= f.low && atomic.CompareAndSwapInt32(&f.congested, 1, 0):
+		f.notify(false)
+	}
+}
+
+// track counts a future queued up as size bytes, unless it was already
+// written out.
+func (f *sendFlow) track(future *SendFuture, size int) {
+	if f != nil && atomic.CompareAndSwapInt64(&future.queuedBytes, 0, int64(size)) {
+		f.add(int64(size))
+	}
+}
+
+// untrack stops counting a future, once it was written out or dropped.
+func (f *sendFlow) untrack(future *SendFuture) {
+	f.add(-f.release(future))
+}
+
+// release stops counting futures as queued up, returning the bytes they were
+// counted as. The bytes remain counted as queued until subtracted through
+// add, so that handing them over to a write does not dip below the
+// watermarks.
+func (f *sendFlow) release(futures ...*SendFuture) int64 {
+	if f == nil {
+		return 0
+	}
+
+	var released int64
+	for _, future := range futures {
+		if size := atomic.SwapInt64(&future.queuedBytes, -1); size > 0 {
+			released += size
+		}
+	}
+	return released
+}
+
+// setBuffered records how many bytes the connection's buffer holds, under
+// the connection's writer lock.
+func (f *sendFlow) setBuffered(size int) {
+	if f != nil {
+		atomic.StoreInt64(&f.buffered, int64(size))
+	}
+}
+
+// flowWriter counts the bytes being written out to a connection.
+type flowWriter struct {
+	w    io.Writer
+	flow *sendFlow
+}
+
+func (w *flowWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.flow.inFlight, int64(len(p)))
+	defer atomic.AddInt64(&w.flow.inFlight, -int64(len(p)))
+
+	return w.w.Write(p)
+}
+
+// PeerSendBudget returns how much data bound for the peer at an address is
+// backed up on our side, and how much more may be sent before it is held
+// back, returning false should we not be connected to it. It is cheap enough
+// to be read before every message sent.
+func (n *Network) PeerSendBudget(address string) (SendBudget, bool) {
+	state, ok := n.ConnectionState(address)
+	if !ok {
+		return SendBudget{}, false
+	}
+
+	budget := SendBudget{WindowHeadroom: -1}
+
+	if state.flow != nil {
+		budget.Queued = atomic.LoadInt64(&state.flow.queued)
+		budget.Buffered = atomic.LoadInt64(&state.flow.buffered)
+		budget.InFlight = atomic.LoadInt64(&state.flow.inFlight)
+	}
+
+	if n.opts.sendWindowSize > 0 {
+		budget.WindowHeadroom = n.opts.sendWindowSize - int(atomic.LoadInt64(&state.sends.pending))
+		if budget.WindowHeadroom < 0 {
+			budget.WindowHeadroom = 0
+		}
+	}
+
+	now := time.Now()
+	for _, bucket := range []*tokenBucket{n.bandwidth, state.bandwidth} {
+		if headroom, limited := bucket.headroom(now); limited && (!budget.Shaped || headroom < budget.ShapingHeadroom) {
+			budget.Shaped, budget.ShapingHeadroom = true, headroom
+		}
+	}
+
+	return budget, true
+}
@@ -0,0 +1,101 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendWatermarksAgainstStalledReader(t *testing.T) {
+	t.Parallel()
+
+	const (
+		high = 64 * 1024
+		low  = 16 * 1024
+	)
+
+	congestion := make(chan bool, 4)
+
+	receiver, other, _ := connectWithHandler(t, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	defer other.Close()
+
+	link := &slowLink{TCP: transport.NewTCP(), rate: 64 * 1024 * 1024}
+	sender := buildNodeOverLink(t, link,
+		WriteTimeout(10*time.Second),
+		OnSendWatermark(high, low, func(address string, congested bool) {
+			assert.Equal(t, receiver.Address, address)
+			congestion <- congested
+		}),
+	)
+	defer sender.Close()
+
+	_, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	budget, ok := sender.PeerSendBudget(receiver.Address)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), budget.Queued)
+	assert.Equal(t, defaultSendWindowSize, budget.WindowHeadroom)
+	assert.False(t, budget.Shaped)
+
+	_, ok = sender.PeerSendBudget("tcp://localhost:1")
+	assert.False(t, ok)
+
+	link.stalled.Store(true)
+
+	payload := &testpb.TestMessage{Message: strings.Repeat("x", 4*1024)}
+
+	var futures []*SendFuture
+	for congested := false; !congested; {
+		if !assert.True(t, len(futures) < 64, "queueing up messages should cross the high watermark") {
+			return
+		}
+
+		message, err := sender.PrepareMessage(payload)
+		assert.Nil(t, err)
+
+		future, err := sender.WriteAsync(receiver.Address, message)
+		assert.Nil(t, err)
+		futures = append(futures, future)
+
+		select {
+		case congested = <-congestion:
+			assert.True(t, congested)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	budget, ok = sender.PeerSendBudget(receiver.Address)
+	assert.True(t, ok)
+	assert.True(t, budget.Queued >= high, "%d bytes queued", budget.Queued)
+	assert.True(t, budget.InFlight > 0)
+	assert.True(t, budget.WindowHeadroom < defaultSendWindowSize)
+
+	link.stalled.Store(false)
+
+	select {
+	case congested := <-congestion:
+		assert.False(t, congested)
+	case <-time.After(5 * time.Second):
+		t.Fatal("draining the queue should fall back below the low watermark")
+	}
+
+	budget, ok = sender.PeerSendBudget(receiver.Address)
+	assert.True(t, ok)
+	assert.True(t, budget.Queued <= low, "%d bytes queued", budget.Queued)
+
+	for _, future := range futures {
+		<-future.Done()
+		assert.Nil(t, future.Err())
+	}
+
+	assert.True(t, waitUntil(time.Second, func() bool {
+		budget, _ := sender.PeerSendBudget(receiver.Address)
+		return budget.Queued == 0 && budget.InFlight == 0
+	}))
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxFrameSize is the largest frame body accepted off the wire unless
+// configured otherwise. Should a larger message need be sent, consider
+// partitioning it into chunks.
+const defaultMaxFrameSize = 4e+6
+
+// frameCodec reads and writes the headers prefixing frames with the size of
+// their body: a big-endian uint32, or for legacy peers an uvarint zero-padded
+// to legacyHeaderSize bytes.
+type frameCodec struct {
+	legacy bool
+
+	// maxSize is the largest frame body a header read may announce.
+	maxSize uint64
+}
+
+// frameCodec returns the codec frames exchanged with peers, legacy or not,
+// are framed with.
+func (n *Network) frameCodec(legacy bool) frameCodec {
+	return frameCodec{legacy: legacy, maxSize: uint64(n.opts.maxFrameSize)}
+}
+
+// frameHeaderSize returns the size of the header prefixing frames, legacy or
+// not.
+func frameHeaderSize(legacy bool) int {
+	if legacy {
+		return legacyHeaderSize
+	}
+	return 4
+}
+
+// header returns the header prefixing a frame of a given size.
+func (c frameCodec) header(size int) []byte {
+	header := make([]byte, frameHeaderSize(c.legacy))
+	if c.legacy {
+		binary.PutUvarint(header, uint64(size))
+	} else {
+		binary.BigEndian.PutUint32(header, uint32(size))
+	}
+	return header
+}
+
+// size decodes the size held by a header, being zero should the header hold
+// none.
+func (c frameCodec) size(header []byte) uint64 {
+	if !c.legacy {
+		return uint64(binary.BigEndian.Uint32(header))
+	}
+
+	size, read := binary.Uvarint(header)
+	if read <= 0 {
+		return 0
+	}
+	return size
+}
+
+// readHeader reads the header of a frame in full, returning it alongside the
+// size of the frame's body. The size is decoded out of whatever was read
+// even should reading fail, a size of zero failing with errEmptyMsg.
+func (c frameCodec) readHeader(r io.Reader) ([]byte, uint64, error) {
+	header := make([]byte, frameHeaderSize(c.legacy))
+
+	_, err := io.ReadFull(r, header)
+	size := c.size(header)
+
+	if err != nil {
+		return header, size, errors.Wrap(err, "failed to read frame header")
+	}
+
+	if size == 0 {
+		return header, 0, errEmptyMsg
+	}
+
+	if size > c.maxSize {
+		return header, size, errors.Errorf("frame has length of %d which is either broken or too large", size)
+	}
+
+	return header, size, nil
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameCodec(t *testing.T) {
+	t.Parallel()
+
+	for _, legacy := range []bool{false, true} {
+		codec := frameCodec{legacy: legacy, maxSize: 1 << 20}
+
+		header := codec.header(300)
+		assert.Len(t, header, frameHeaderSize(legacy))
+
+		// Headers are read whole however short the reads of the stream are.
+		read, size, err := codec.readHeader(iotest.OneByteReader(bytes.NewReader(header)))
+		assert.Nil(t, err)
+		assert.Equal(t, header, read)
+		assert.Equal(t, uint64(300), size)
+
+		_, size, err = codec.readHeader(bytes.NewReader(header[:1]))
+		assert.NotNil(t, err)
+		assert.NotEqual(t, errEmptyMsg, err)
+
+		_, size, err = codec.readHeader(bytes.NewReader(make([]byte, frameHeaderSize(legacy))))
+		assert.Equal(t, errEmptyMsg, err)
+		assert.Zero(t, size)
+
+		_, size, err = codec.readHeader(bytes.NewReader(codec.header(1<<20 + 1)))
+		assert.NotNil(t, err)
+		assert.Equal(t, uint64(1<<20+1), size)
+	}
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("l", defaultMaxFrameSize+1024)
+
+	receiver, sender, client, arrivals := connectPadded(t, []BuilderOption{MaxFrameSize(2 * defaultMaxFrameSize)})
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: large}))
+	select {
+	case arrival := <-arrivals:
+		assert.Equal(t, large, arrival.message)
+	case <-time.After(10 * time.Second):
+		t.Fatal("large message was never received")
+	}
+
+	// Peers announcing frames larger than allowed are hung up on.
+	receiver, sender, client, arrivals = connectPadded(t, nil)
+	defer receiver.Close()
+	defer sender.Close()
+
+	client.Tell(&testpb.TestMessage{Message: large})
+	assert.True(t, waitUntil(10*time.Second, func() bool {
+		return !receiver.ConnectionStateExists(sender.Address)
+	}), "peer was never disconnected")
+	assert.Len(t, arrivals, 0)
+}
+
+func TestMaxFrameSizeIsValidated(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBuilderWithOptions(MaxFrameSize(0)).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewBuilderWithOptions(MaxFrameSize(1024), MaxMessageSize(2048)).Build()
+	assert.NotNil(t, err)
+
+	_, err = NewBuilderWithOptions(MaxFrameSize(1024), PaddingBuckets(2048)).Build()
+	assert.NotNil(t, err)
+}
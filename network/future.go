@@ -0,0 +1,307 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSendWindowFull is returned by WriteAsync should too many messages to a peer be pending.
+	ErrSendWindowFull = errors.New("network: send window full")
+	// errConnectionClosed resolves futures of messages which never made it out before their connection closed.
+	errConnectionClosed = errors.New("network: connection closed")
+)
+
+// SendFuture is the pending result of a message written asynchronously. It
+// is resolved exactly once, and may be safely abandoned.
+type SendFuture struct {
+	// Bytes the future is counted as queued for, or -1 once it no longer is.
+	// Kept first for 64-bit alignment.
+	queuedBytes int64 // for atomic ops
+
+	message *protobuf.Message
+
+	// prepared is closed once a message queued up through SendAsync or
+	// Broadcast was signed and serialized into body.
+	prepared   chan struct{}
+	body       []byte
+	prepareErr error
+
+	done chan struct{}
+	once sync.Once
+
+	mutex     sync.Mutex
+	err       error
+	callbacks []func(error)
+}
+
+func newSendFuture(message *protobuf.Message) *SendFuture {
+	return &SendFuture{
+		message: message,
+		done:    make(chan struct{}),
+	}
+}
+
+// Done returns a channel which is closed once the message was written out to
+// the peer's connection, or failed to be.
+func (f *SendFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns why the message failed to be written out, or nil should it
+// have been written out or still be pending.
+func (f *SendFuture) Err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// OnComplete registers a callback invoked with the result of the write once
+// the future is resolved, or right away should it already be. Callbacks are
+// invoked from the network's send workers, and must not block.
+func (f *SendFuture) OnComplete(fn func(error)) {
+	f.mutex.Lock()
+	select {
+	case <-f.done:
+		err := f.err
+		f.mutex.Unlock()
+		fn(err)
+		return
+	default:
+	}
+	f.callbacks = append(f.callbacks, fn)
+	f.mutex.Unlock()
+}
+
+func (f *SendFuture) resolve(err error) {
+	f.once.Do(func() {
+		f.mutex.Lock()
+		f.err = err
+		callbacks := f.callbacks
+		f.callbacks = nil
+		close(f.done)
+		f.mutex.Unlock()
+
+		for _, fn := range callbacks {
+			fn(err)
+		}
+	})
+}
+
+// sendQueue holds messages to a single peer written asynchronously, both
+// those yet to be written and those written but not yet flushed out.
+type sendQueue struct {
+	// Number of futures queued and flushed, kept for reading without locking.
+	// Kept first for 64-bit alignment.
+	pending int64 // for atomic ops
+
+	sync.Mutex
+	cond *sync.Cond
+
+	queued  []*SendFuture
+	flushed []*SendFuture
+
+	// Counts of bytes bound for the peer, if kept.
+	flow *sendFlow
+
+	// Number of futures queued and flushed across all peers, if kept.
+	total *int64
+
+	closed bool
+	err    error
+}
+
+// count updates the number of pending futures, under the queue's lock.
+func (q *sendQueue) count() {
+	pending := int64(len(q.queued) + len(q.flushed))
+	previous := atomic.SwapInt64(&q.pending, pending)
+	if q.total != nil {
+		atomic.AddInt64(q.total, pending-previous)
+	}
+}
+
+func newSendQueue() *sendQueue {
+	q := new(sendQueue)
+	q.cond = sync.NewCond(q)
+	return q
+}
+
+// push queues up a future, so long as the send window is not full.
+func (q *sendQueue) push(f *SendFuture, window int) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return q.err
+	}
+
+	if window > 0 && len(q.queued)+len(q.flushed) >= window {
+		return ErrSendWindowFull
+	}
+
+	// Messages yet to be signed are counted once prepared. Others are
+	// counted before the send loop may pick them up.
+	switch {
+	case f.body != nil:
+		q.flow.track(f, len(f.body)+4)
+	case f.message != nil && f.prepared == nil:
+		q.flow.track(f, f.message.Size()+4)
+	}
+
+	q.queued = append(q.queued, f)
+	q.count()
+	q.cond.Signal()
+
+	return nil
+}
+
+// pop waits for and takes all queued futures, returning false once the queue is closed.
+func (q *sendQueue) pop() ([]*SendFuture, bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	for len(q.queued) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return nil, false
+	}
+
+	batch := q.queued
+	q.queued = nil
+	q.count()
+
+	return batch, true
+}
+
+// drain takes all queued futures without waiting.
+func (q *sendQueue) drain() []*SendFuture {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return nil
+	}
+
+	queued := q.queued
+	q.queued = nil
+	q.count()
+
+	return queued
+}
+
+// written marks a future as awaiting the next flush of the connection.
+func (q *sendQueue) written(f *SendFuture) {
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		f.resolve(q.err)
+		return
+	}
+	q.flushed = append(q.flushed, f)
+	q.count()
+	q.Unlock()
+}
+
+// flush resolves all futures written before the connection was last flushed.
+func (q *sendQueue) flush(err error) {
+	q.Lock()
+	flushed := q.flushed
+	q.flushed = nil
+	q.count()
+	q.Unlock()
+
+	for _, f := range flushed {
+		f.resolve(err)
+	}
+}
+
+// close fails all pending futures, and stops the queue's worker.
+func (q *sendQueue) close(err error) {
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		return
+	}
+	q.closed = true
+	q.err = err
+
+	pending := append(q.queued, q.flushed...)
+	q.queued, q.flushed = nil, nil
+	q.count()
+
+	q.cond.Broadcast()
+	q.Unlock()
+
+	for _, f := range pending {
+		q.flow.untrack(f)
+		f.resolve(err)
+	}
+}
+
+// WriteAsync queues up a message to be sent to a denoted target address
+// without blocking, returning a future resolved once the message was written
+// out to the peer's connection. Writes may be shed by admission control. The
+// envelope is copied, so the caller keeps ownership of the message.
+func (n *Network) WriteAsync(address string, message *protobuf.Message, opts ...WriteOption) (*SendFuture, error) {
+	if n.isClosed() {
+		return nil, ErrNetworkClosed
+	}
+
+	state, ok := n.ConnectionState(address)
+	if !ok {
+		return nil, errors.New("network: connection does not exist")
+	}
+
+	if err := n.admit(address, newWriteOptions(n, opts).priority); err != nil {
+		return nil, err
+	}
+
+	if n.circuitOpen(address) {
+		return nil, ErrCircuitOpen
+	}
+
+	// The send loop stamps the envelope it writes out with a message nonce,
+	// so it is handed a copy the caller no longer holds.
+	envelope := *message
+	f := newSendFuture(&envelope)
+	if err := state.sends.push(f, n.opts.sendWindowSize); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// sendLoop writes out messages queued up for a peer one after another, in
+// the order they were queued.
+func (n *Network) sendLoop(address string, q *sendQueue) {
+	h := n.watchdog.watch(ComponentSend, address, nil)
+	defer n.watchdog.unwatch(h)
+
+	for {
+		h.idle()
+
+		batch, ok := q.pop()
+		if !ok {
+			return
+		}
+		h.beat()
+
+		if n.opts.batchMessages > 1 && n.supportsBatches(address) {
+			n.writeBatched(address, q, n.fillBatch(q, batch))
+		} else {
+			for _, f := range batch {
+				n.writeQueued(address, q, f)
+			}
+		}
+
+		// Frames written out by light nodes are flushed already.
+		if n.opts.light {
+			q.flush(nil)
+		}
+	}
+}
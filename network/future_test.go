@@ -0,0 +1,115 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestWriteAsyncResolvesEveryFuture(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	t.Parallel()
+
+	const numPeers, numWrites = 10, 100000
+
+	sender := buildListeningNode(t, SendWindowSize(numWrites))
+	defer sender.Close()
+
+	var addresses []string
+	for i := 0; i < numPeers; i++ {
+		receiver := buildListeningNode(t)
+		defer receiver.Close()
+
+		_, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+		addresses = append(addresses, receiver.Address)
+	}
+
+	var resolved, failed, callbacks atomic.Int32
+	var wg sync.WaitGroup
+
+	for i, address := range addresses {
+		message, err := sender.PrepareMessage(&testpb.TestMessage{Message: address})
+		assert.Nil(t, err)
+
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+
+			for j := i; j < numWrites; j += numPeers {
+				f, err := sender.WriteAsync(address, message)
+				if !assert.Nil(t, err) {
+					return
+				}
+				f.OnComplete(func(error) { callbacks.Inc() })
+
+				go func() {
+					<-f.Done()
+					resolved.Inc()
+					if f.Err() != nil {
+						failed.Inc()
+					}
+				}()
+			}
+		}(i, address)
+	}
+	wg.Wait()
+
+	assert.True(t, waitUntil(30*time.Second, func() bool { return resolved.Load() == numWrites }), "resolved %d futures", resolved.Load())
+	assert.Equal(t, int32(0), failed.Load())
+	assert.Equal(t, int32(numWrites), callbacks.Load())
+}
+
+func TestWriteAsyncFailsOnClose(t *testing.T) {
+	t.Parallel()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	sender := buildListeningNode(t, SendWindowSize(1))
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+
+	f, err := sender.WriteAsync(receiver.Address, message)
+	assert.Nil(t, err)
+
+	_, err = sender.WriteAsync(receiver.Address, message)
+	assert.Equal(t, ErrSendWindowFull, err)
+
+	<-f.Done()
+	assert.Nil(t, f.Err())
+
+	errs := make(chan error, 1)
+	f.OnComplete(func(err error) { errs <- err })
+	assert.Nil(t, <-errs, "callbacks on resolved futures should run right away")
+
+	// Hold up the send worker, so that the next write is still queued once the peer is closed.
+	state, _ := sender.ConnectionState(receiver.Address)
+	state.writerMutex.Lock()
+	f, err = sender.WriteAsync(receiver.Address, message)
+	assert.Nil(t, err)
+
+	client.Close()
+	state.writerMutex.Unlock()
+
+	select {
+	case <-f.Done():
+		assert.NotNil(t, f.Err())
+	case <-time.After(3 * time.Second):
+		t.Fatal("future was never resolved")
+	}
+
+	_, err = sender.WriteAsync(receiver.Address, message)
+	assert.NotNil(t, err)
+}
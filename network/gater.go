@@ -0,0 +1,228 @@
+package network
+
+import (
+	"net"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// ErrConnectionGated is returned should a connection be vetoed by a
+// ConnectionGater.
+var ErrConnectionGated = errors.New("network: connection gated")
+
+// GateStage denotes the point at which a ConnectionGater is consulted.
+type GateStage int
+
+const (
+	// GateDial is before dialing an address.
+	GateDial GateStage = iota
+	// GateAccept is before handshaking with a connection accepted by the listener.
+	GateAccept
+	// GateSecured is once a handshake identified the peer.
+	GateSecured
+)
+
+func (s GateStage) String() string {
+	switch s {
+	case GateDial:
+		return "dial"
+	case GateAccept:
+		return "accept"
+	case GateSecured:
+		return "secured"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnectionGater vetoes connections before resources are spent on them.
+// Every hook returns whether the connection is allowed, alongside a reason
+// should it not be.
+type ConnectionGater interface {
+	// InterceptDial is consulted before dialing an address.
+	InterceptDial(address string) (allow bool, reason string)
+
+	// InterceptAccept is consulted once the listener accepts a connection,
+	// before handshaking with it.
+	InterceptAccept(remoteAddr net.Addr) (allow bool, reason string)
+
+	// InterceptSecured is consulted once a handshake identified the peer,
+	// before it is admitted.
+	InterceptSecured(direction ConnDirection, id PeerID) (allow bool, reason string)
+}
+
+// composedGater allows a connection only if every one of its gaters does.
+type composedGater []ConnectionGater
+
+// ComposeGaters returns a ConnectionGater which allows a connection only if
+// every one of gaters does, reporting the reason of the first to veto it.
+func ComposeGaters(gaters ...ConnectionGater) ConnectionGater {
+	return composedGater(gaters)
+}
+
+func (c composedGater) InterceptDial(address string) (bool, string) {
+	for _, gater := range c {
+		if allow, reason := gater.InterceptDial(address); !allow {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+func (c composedGater) InterceptAccept(remoteAddr net.Addr) (bool, string) {
+	for _, gater := range c {
+		if allow, reason := gater.InterceptAccept(remoteAddr); !allow {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+func (c composedGater) InterceptSecured(direction ConnDirection, id PeerID) (bool, string) {
+	for _, gater := range c {
+		if allow, reason := gater.InterceptSecured(direction, id); !allow {
+			return false, reason
+		}
+	}
+	return true, ""
+}
+
+// NetworkGater allows or denies connections by the IP network of the remote
+// address. Addresses whose hosts are not IP addresses are resolved first.
+type NetworkGater struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewNetworkGater returns a NetworkGater which denies addresses within any of
+// the deny CIDR ranges, and should there be any allow ranges, addresses
+// outside all of them.
+func NewNetworkGater(allow, deny []string) (*NetworkGater, error) {
+	g := &NetworkGater{}
+
+	for _, cidr := range allow {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid allowed network %q", cidr)
+		}
+		g.allow = append(g.allow, network)
+	}
+
+	for _, cidr := range deny {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid denied network %q", cidr)
+		}
+		g.deny = append(g.deny, network)
+	}
+
+	return g, nil
+}
+
+func (g *NetworkGater) allowIP(ip net.IP) (bool, string) {
+	for _, network := range g.deny {
+		if network.Contains(ip) {
+			return false, "address " + ip.String() + " is within denied network " + network.String()
+		}
+	}
+
+	if len(g.allow) == 0 {
+		return true, ""
+	}
+
+	for _, network := range g.allow {
+		if network.Contains(ip) {
+			return true, ""
+		}
+	}
+	return false, "address " + ip.String() + " is outside all allowed networks"
+}
+
+// InterceptDial denies addresses outside the allowed networks.
+func (g *NetworkGater) InterceptDial(address string) (bool, string) {
+	info, err := ParseAddress(address)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	ip := net.ParseIP(info.Host)
+	if ip == nil {
+		ips, err := net.LookupIP(info.Host)
+		if err != nil || len(ips) == 0 {
+			return false, "failed to resolve " + info.Host
+		}
+		ip = ips[0]
+	}
+
+	return g.allowIP(ip)
+}
+
+// InterceptAccept denies connections from outside the allowed networks.
+func (g *NetworkGater) InterceptAccept(remoteAddr net.Addr) (bool, string) {
+	host, _, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		return false, err.Error()
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false, "remote address " + host + " is not an IP address"
+	}
+
+	return g.allowIP(ip)
+}
+
+// InterceptSecured allows every peer.
+func (g *NetworkGater) InterceptSecured(ConnDirection, PeerID) (bool, string) {
+	return true, ""
+}
+
+// gated reports a connection vetoed by the gater, returning the error to
+// abort the connection with.
+func (n *Network) gated(stage GateStage, address string, reason string) error {
+	glog.Warningf("connection gated before %s [address=%s, reason=%s]", stage, address, reason)
+
+	if n.opts.onConnectionGated != nil {
+		n.opts.onConnectionGated(stage, address, reason)
+	}
+
+	return errors.Wrapf(ErrConnectionGated, "%s of %s denied: %s", stage, address, reason)
+}
+
+// interceptDial consults the gater before dialing an address.
+func (n *Network) interceptDial(address string) error {
+	if n.gater == nil {
+		return nil
+	}
+
+	if allow, reason := n.gater.InterceptDial(address); !allow {
+		return n.gated(GateDial, address, reason)
+	}
+	return nil
+}
+
+// interceptAccept consults the gater before handshaking with an accepted
+// connection.
+func (n *Network) interceptAccept(conn net.Conn) error {
+	if n.gater == nil {
+		return nil
+	}
+
+	if allow, reason := n.gater.InterceptAccept(conn.RemoteAddr()); !allow {
+		return n.gated(GateAccept, conn.RemoteAddr().String(), reason)
+	}
+	return nil
+}
+
+// interceptSecured consults the gater once a handshake identified a peer.
+func (n *Network) interceptSecured(result *handshakeResult, direction ConnDirection) error {
+	if n.gater == nil {
+		return nil
+	}
+
+	if allow, reason := n.gater.InterceptSecured(direction, peerIDOf(result.remote)); !allow {
+		return n.gated(GateSecured, result.remote.Address, reason)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// countingTransport counts the sockets it opens.
+type countingTransport struct {
+	*transport.TCP
+	dials atomic.Int32
+}
+
+func (c *countingTransport) Dial(address string) (net.Conn, error) {
+	c.dials.Inc()
+	return c.TCP.Dial(address)
+}
+
+// funcGater vetoes connections through whichever hooks are set.
+type funcGater struct {
+	dial    func(address string) bool
+	accept  func(remoteAddr net.Addr) bool
+	secured func(direction ConnDirection, id PeerID) bool
+}
+
+func (g *funcGater) InterceptDial(address string) (bool, string) {
+	if g.dial != nil && !g.dial(address) {
+		return false, "dial denied"
+	}
+	return true, ""
+}
+
+func (g *funcGater) InterceptAccept(remoteAddr net.Addr) (bool, string) {
+	if g.accept != nil && !g.accept(remoteAddr) {
+		return false, "accept denied"
+	}
+	return true, ""
+}
+
+func (g *funcGater) InterceptSecured(direction ConnDirection, id PeerID) (bool, string) {
+	if g.secured != nil && !g.secured(direction, id) {
+		return false, "peer denied"
+	}
+	return true, ""
+}
+
+type gatedEvent struct {
+	stage  GateStage
+	reason string
+}
+
+func buildGatedNode(t *testing.T, opts ...BuilderOption) (*Network, *countingTransport, chan gatedEvent) {
+	events := make(chan gatedEvent, 16)
+	counting := &countingTransport{TCP: transport.NewTCP()}
+
+	builder := NewBuilderWithOptions(append(opts, OnConnectionGated(func(stage GateStage, address string, reason string) {
+		events <- gatedEvent{stage: stage, reason: reason}
+	}))...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("tcp", counting)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node, counting, events
+}
+
+func TestGaterVetoesDialBeforeSocket(t *testing.T) {
+	t.Parallel()
+
+	blocked := buildListeningNode(t)
+	defer blocked.Close()
+
+	allowed := buildListeningNode(t)
+	defer allowed.Close()
+
+	node, counting, events := buildGatedNode(t, GateConnections(&funcGater{dial: func(address string) bool {
+		return address != blocked.Address
+	}}))
+	defer node.Close()
+
+	_, err := node.Client(blocked.Address)
+	assert.Equal(t, ErrConnectionGated, errors.Cause(err))
+	assert.Equal(t, int32(0), counting.dials.Load(), "no socket should be opened for a vetoed dial")
+	assert.Equal(t, gatedEvent{stage: GateDial, reason: "dial denied"}, <-events)
+
+	_, err = node.Client(allowed.Address)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), counting.dials.Load())
+}
+
+func TestGaterVetoesAcceptBeforeHandshake(t *testing.T) {
+	t.Parallel()
+
+	node, _, events := buildGatedNode(t, GateConnections(&funcGater{accept: func(net.Addr) bool { return false }}))
+	defer node.Close()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	_, err := other.Client(node.Address)
+	assert.NotNil(t, err)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, GateAccept, event.stage)
+	case <-time.After(3 * time.Second):
+		t.Fatal("accept was never gated")
+	}
+
+	stats := node.HandshakeStats()
+	assert.Equal(t, uint64(0), stats.Sent, "no handshake should be run with a vetoed connection")
+	assert.Equal(t, uint64(0), stats.Failures)
+}
+
+func TestGaterVetoesSecuredPeer(t *testing.T) {
+	t.Parallel()
+
+	banned := buildListeningNode(t)
+	defer banned.Close()
+
+	bannedID, err := PeerIDFromPublicKey(banned.ID.PublicKey)
+	assert.Nil(t, err)
+
+	node, counting, events := buildGatedNode(t, GateConnections(&funcGater{secured: func(direction ConnDirection, id PeerID) bool {
+		return id != bannedID
+	}}))
+	defer node.Close()
+
+	_, err = node.Client(banned.Address)
+	assert.Equal(t, ErrConnectionGated, errors.Cause(err))
+	assert.Equal(t, int32(1), counting.dials.Load())
+	assert.Equal(t, gatedEvent{stage: GateSecured, reason: "peer denied"}, <-events)
+
+	// The peer is also refused should it dial us.
+	banned.Client(node.Address)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return len(events) > 0 }))
+	assert.Equal(t, 0, len(node.Peers()))
+}
+
+func TestNetworkGater(t *testing.T) {
+	t.Parallel()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	denied, counting, events := buildGatedNode(t, DenyNetworks("127.0.0.0/8"))
+	defer denied.Close()
+
+	_, err := denied.Client(other.Address)
+	assert.Equal(t, ErrConnectionGated, errors.Cause(err))
+	assert.Equal(t, int32(0), counting.dials.Load())
+	assert.Equal(t, GateDial, (<-events).stage)
+
+	outside, _, _ := buildGatedNode(t, AllowNetworks("10.0.0.0/8"))
+	defer outside.Close()
+
+	_, err = outside.Client(other.Address)
+	assert.Equal(t, ErrConnectionGated, errors.Cause(err))
+
+	allowed, _, _ := buildGatedNode(t, AllowNetworks("10.0.0.0/8", "127.0.0.0/8"), DenyNetworks("192.168.0.0/16"))
+	defer allowed.Close()
+
+	_, err = allowed.Client(other.Address)
+	assert.Nil(t, err)
+
+	builder := NewBuilderWithOptions(DenyNetworks("not a network"))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	_, err = builder.Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,189 @@
+package gossip
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultFanoutTargetDuplicateRatio = 0.75
+	defaultFanoutStep                 = 1
+	defaultFanoutWindow               = 10 * time.Second
+)
+
+// WithAdaptiveFanout has messages relayed to a number of peers picked at
+// random, adjusted per topic within min and max, rather than to all peers.
+// Each topic starts out relaying to max peers.
+func WithAdaptiveFanout(min, max int) PluginOption {
+	return func(o *Plugin) {
+		o.minFanout = min
+		o.maxFanout = max
+	}
+}
+
+// WithFanoutController specifies how adaptive fanout is adjusted. Once per
+// window, the fanout of a topic shrinks by step should more than the target
+// ratio of messages received have been duplicates, and grows by step should
+// any messages have been reported missed through ReportMisses.
+func WithFanoutController(targetDuplicateRatio float64, step int, window time.Duration) PluginOption {
+	return func(o *Plugin) {
+		o.fanoutTarget = targetDuplicateRatio
+		o.fanoutStep = step
+		o.fanoutWindow = window
+	}
+}
+
+// TopicStats describes the gossip of a topic.
+type TopicStats struct {
+	// Fanout is the number of peers messages are currently relayed to.
+	Fanout int
+	// DuplicateRatio is the fraction of messages received which were
+	// duplicates over the last window.
+	DuplicateRatio float64
+	// Received is the number of messages received, duplicates included.
+	Received uint64
+	// Duplicates is the number of messages received which were seen before.
+	Duplicates uint64
+	// Misses is the number of messages reported missed.
+	Misses uint64
+}
+
+// Stats describes the gossip of every topic with adaptive fanout.
+type Stats struct {
+	Topics map[string]TopicStats
+}
+
+// topicFanout adjusts the fanout of a topic based on the messages received
+// over a window.
+type topicFanout struct {
+	fanout      int
+	ratio       float64
+	windowStart time.Time
+
+	// Counts over the current window.
+	received, duplicates, misses uint64
+
+	// Counts since the topic was first gossiped.
+	stats TopicStats
+}
+
+// fanouts holds the adaptive fanout of every topic.
+type fanouts struct {
+	sync.Mutex
+	topics map[string]*topicFanout
+}
+
+// topicFanout returns the fanout of a topic, adjusting it first should its
+// window have elapsed. It must be called with the lock held.
+func (p *Plugin) topicFanout(topic string) *topicFanout {
+	now := p.now()
+
+	f, exists := p.fanouts.topics[topic]
+	if !exists {
+		if p.fanouts.topics == nil {
+			p.fanouts.topics = make(map[string]*topicFanout)
+		}
+		f = &topicFanout{fanout: p.maxFanout, windowStart: now}
+		p.fanouts.topics[topic] = f
+	}
+
+	if now.Sub(f.windowStart) >= p.fanoutWindow {
+		p.adjustFanout(f)
+		f.windowStart = now
+	}
+
+	return f
+}
+
+// adjustFanout grows the fanout of a topic should messages have been missed
+// over the window, or shrinks it should too many messages received have been
+// duplicates, and starts a new window.
+func (p *Plugin) adjustFanout(f *topicFanout) {
+	if f.received > 0 {
+		f.ratio = float64(f.duplicates) / float64(f.received)
+	}
+
+	switch {
+	case f.misses > 0:
+		f.fanout += p.fanoutStep
+	case f.received > 0 && f.ratio > p.fanoutTarget:
+		f.fanout -= p.fanoutStep
+	}
+
+	if f.fanout > p.maxFanout {
+		f.fanout = p.maxFanout
+	}
+	if f.fanout < p.minFanout {
+		f.fanout = p.minFanout
+	}
+
+	f.received, f.duplicates, f.misses = 0, 0, 0
+}
+
+// observe counts a message of a topic received, and whether it was a
+// duplicate.
+func (p *Plugin) observe(topic string, duplicate bool) {
+	if p.maxFanout <= 0 {
+		return
+	}
+
+	p.fanouts.Lock()
+	defer p.fanouts.Unlock()
+
+	f := p.topicFanout(topic)
+
+	f.received++
+	f.stats.Received++
+	if duplicate {
+		f.duplicates++
+		f.stats.Duplicates++
+	}
+}
+
+// fanout returns the number of peers messages of a topic are relayed to, zero
+// relaying them to all peers.
+func (p *Plugin) fanout(topic string) int {
+	if p.maxFanout <= 0 {
+		return 0
+	}
+
+	p.fanouts.Lock()
+	defer p.fanouts.Unlock()
+
+	return p.topicFanout(topic).fanout
+}
+
+// ReportMisses reports messages of a topic this node never received through
+// gossip, such as those found missing by comparing digests of recent messages
+// with peers, growing the topic's fanout once its window elapses.
+func (p *Plugin) ReportMisses(topic string, count int) {
+	if p.maxFanout <= 0 || count <= 0 {
+		return
+	}
+
+	p.fanouts.Lock()
+	defer p.fanouts.Unlock()
+
+	f := p.topicFanout(topic)
+
+	f.misses += uint64(count)
+	f.stats.Misses += uint64(count)
+}
+
+// Stats returns the current fanout of every topic gossiped, alongside counts
+// of messages received and missed. It is empty without adaptive fanout.
+func (p *Plugin) Stats() Stats {
+	p.fanouts.Lock()
+	defer p.fanouts.Unlock()
+
+	stats := Stats{Topics: make(map[string]TopicStats, len(p.fanouts.topics))}
+	for topic := range p.fanouts.topics {
+		f := p.topicFanout(topic)
+
+		s := f.stats
+		s.Fanout = f.fanout
+		s.DuplicateRatio = f.ratio
+		stats.Topics[topic] = s
+	}
+	return stats
+}
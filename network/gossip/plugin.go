@@ -0,0 +1,246 @@
+package gossip
+
+import (
+	"encoding/hex"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/perlin-network/noise/peer"
+	"github.com/perlin-network/noise/types/lru"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPluginSeenCacheSize = 4096
+)
+
+// Plugin rebroadcasts every received message to all peers, or to a number of
+// them adapted to how many duplicates are received, remembering which messages
+// it has already seen so that they are relayed at most once. Messages of
+// topics with a validator are relayed only once accepted.
+type Plugin struct {
+	*network.Plugin
+
+	// plugin options
+	// excludeOrigin skips relaying a message back to the peer it arrived from
+	excludeOrigin bool
+	// seenCacheSize specifies how many message hashes are remembered
+	seenCacheSize int
+	// filter decides which messages get relayed
+	filter func(proto.Message) bool
+	// validationConcurrency specifies how many messages may be validated at once
+	validationConcurrency int
+	// validationTimeout specifies how long validating a message may take
+	validationTimeout time.Duration
+	// deliver is handed accepted messages
+	deliver func(network.PeerInfo, proto.Message)
+	// protocol is the protocol tag messages originating from this node are gossiped under
+	protocol string
+	// minFanout and maxFanout bound the number of peers messages are relayed to, zero relaying to all peers
+	minFanout, maxFanout int
+	// fanoutTarget, fanoutStep and fanoutWindow specify how fanout is adjusted
+	fanoutTarget float64
+	fanoutStep   int
+	fanoutWindow time.Duration
+	// groups orders the groups of the peers messages are relayed to, if set
+	groups network.GroupPreference
+	// now returns the current time
+	now func() time.Time
+
+	hashPolicy *blake2b.Blake2b
+	seen       *lru.Cache
+
+	validators      validators
+	validationSlots chan struct{}
+	violations      sync.Map // address (string) -> *uint64 (for atomic ops)
+
+	fanouts fanouts
+}
+
+// PluginOption are configurable options for the gossip plugin
+type PluginOption func(*Plugin)
+
+// WithExcludeOrigin specifies whether relayed messages skip the peer they arrived from
+func WithExcludeOrigin(exclude bool) PluginOption {
+	return func(o *Plugin) {
+		o.excludeOrigin = exclude
+	}
+}
+
+// WithSeenCacheSize specifies how many recently relayed messages are remembered
+func WithSeenCacheSize(size int) PluginOption {
+	return func(o *Plugin) {
+		o.seenCacheSize = size
+	}
+}
+
+// WithFilter specifies which received messages are relayed
+func WithFilter(filter func(proto.Message) bool) PluginOption {
+	return func(o *Plugin) {
+		o.filter = filter
+	}
+}
+
+// WithProtocol specifies the protocol tag messages originating from this node
+// are gossiped under, which should match the tag the plugin is registered under
+// through Builder.AddProtocolPlugin. Received messages are always relayed under
+// the tag they arrived under.
+func WithProtocol(tag string) PluginOption {
+	return func(o *Plugin) {
+		o.protocol = tag
+	}
+}
+
+// WithGroupPreference specifies the groups of the peers messages are relayed
+// to in order, only healthy peers being relayed to. Messages are relayed to
+// every healthy peer of the first group holding any should fanout be
+// unbounded, and otherwise fill the fanout from later groups once earlier
+// ones run out
+func WithGroupPreference(groups ...string) PluginOption {
+	return func(o *Plugin) {
+		o.groups = network.PreferGroups(groups...)
+	}
+}
+
+func defaultOptions() PluginOption {
+	return func(o *Plugin) {
+		o.excludeOrigin = true
+		o.seenCacheSize = defaultPluginSeenCacheSize
+		o.filter = isApplicationMessage
+		o.validationConcurrency = defaultPluginValidationConcurrency
+		o.validationTimeout = defaultPluginValidationTimeout
+		o.fanoutTarget = defaultFanoutTargetDuplicateRatio
+		o.fanoutStep = defaultFanoutStep
+		o.fanoutWindow = defaultFanoutWindow
+		o.now = time.Now
+	}
+}
+
+var (
+	_ network.PluginInterface = (*Plugin)(nil)
+	// PluginID is used to check existence of the gossip plugin
+	PluginID = (*Plugin)(nil)
+)
+
+// New returns a new gossip plugin with specified options
+func New(opts ...PluginOption) *Plugin {
+	p := new(Plugin)
+	defaultOptions()(p)
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.hashPolicy = blake2b.New()
+	p.seen = lru.NewCache(p.seenCacheSize)
+	p.validationSlots = make(chan struct{}, p.validationConcurrency)
+
+	if p.minFanout < 1 {
+		p.minFanout = 1
+	}
+	if p.minFanout > p.maxFanout {
+		p.minFanout = p.maxFanout
+	}
+
+	return p
+}
+
+// Receive implements the plugin callback
+func (p *Plugin) Receive(ctx *network.PluginContext) error {
+	if !p.filter(ctx.Message()) {
+		return nil
+	}
+
+	fresh, err := p.markSeen(ctx.Message())
+	if err != nil {
+		return err
+	}
+
+	topic := Topic(ctx.Message())
+	p.observe(topic, !fresh)
+
+	if !fresh {
+		return nil
+	}
+
+	peer := ctx.Client().Info()
+
+	switch p.validate(peer, ctx.Message()) {
+	case Ignore:
+		return nil
+	case Reject:
+		p.addViolation(ctx.Client().Address)
+		return nil
+	}
+
+	if p.deliver != nil {
+		p.deliver(peer, ctx.Message())
+	}
+
+	// Light nodes only gossip messages originating from them.
+	if ctx.Network().IsLight() {
+		return nil
+	}
+
+	relay := ctx.Network().Protocol(ctx.Protocol())
+	if p.excludeOrigin {
+		p.relay(relay, ctx.Message(), p.fanout(topic), ctx.Origin())
+	} else {
+		p.relay(relay, ctx.Message(), p.fanout(topic))
+	}
+
+	return nil
+}
+
+// Broadcast gossips a message originating from this node to all of its peers.
+func (p *Plugin) Broadcast(net *network.Network, message proto.Message) error {
+	if _, err := p.markSeen(message); err != nil {
+		return err
+	}
+
+	p.relay(net.Protocol(p.protocol), message, p.fanout(Topic(message)))
+	return nil
+}
+
+// relay broadcasts a message to fanout peers, picked from the preferred groups
+// should there be any.
+func (p *Plugin) relay(protocol *network.Protocol, message proto.Message, fanout int, excluded ...peer.ID) {
+	if len(p.groups) > 0 {
+		protocol.BroadcastFanoutToGroups(p.groups, message, fanout, excluded...)
+	} else {
+		protocol.BroadcastFanout(message, fanout, excluded...)
+	}
+}
+
+// markSeen remembers a message, and returns true should it not have been seen before.
+func (p *Plugin) markSeen(message proto.Message) (bool, error) {
+	raw, err := proto.Marshal(message)
+	if err != nil {
+		return false, errors.Wrap(err, "gossip: failed to marshal message")
+	}
+
+	key := reflect.TypeOf(message).String() + ":" + hex.EncodeToString(p.hashPolicy.HashBytes(raw))
+
+	fresh := false
+	p.seen.Get(key, func() (interface{}, error) {
+		fresh = true
+		return struct{}{}, nil
+	})
+
+	return fresh, nil
+}
+
+// isApplicationMessage filters out messages used internally by noise.
+func isApplicationMessage(message proto.Message) bool {
+	switch message.(type) {
+	case *protobuf.Ping, *protobuf.Pong, *protobuf.LookupNodeRequest, *protobuf.LookupNodeResponse, *protobuf.Bytes:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,431 @@
+package gossip
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// countPlugin counts every test message received off the wire.
+type countPlugin struct {
+	*network.Plugin
+	count *atomic.Int32
+}
+
+func (p *countPlugin) Receive(ctx *network.PluginContext) error {
+	if _, ok := ctx.Message().(*protobuf.TestMessage); ok {
+		p.count.Inc()
+	}
+	return nil
+}
+
+// countWireMessages gossips a single message across a fully-connected mesh of
+// three nodes, and returns the total number of copies received by all nodes
+// alongside the plugin of every node.
+func countWireMessages(t *testing.T, opts ...PluginOption) (int32, []*Plugin) {
+	count := atomic.NewInt32(0)
+
+	var nodes []*network.Network
+	var plugins []*Plugin
+
+	for i := 0; i < 3; i++ {
+		builder := network.NewBuilder()
+		builder.SetKeys(ed25519.RandomKeyPair())
+		builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+
+		plugin := New(opts...)
+		builder.AddPlugin(plugin)
+		builder.AddPlugin(&countPlugin{count: count})
+
+		node, err := builder.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer node.Close()
+
+		go node.Listen()
+		<-node.Ready()
+
+		nodes = append(nodes, node)
+		plugins = append(plugins, plugin)
+	}
+
+	nodes[1].Bootstrap(nodes[0].Address)
+	nodes[2].Bootstrap(nodes[0].Address, nodes[1].Address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, node := range nodes {
+		if err := node.WaitForPeers(ctx, 2); err != nil {
+			t.Fatalf("node %s did not connect to all peers: %v", node.Address, err)
+		}
+	}
+
+	err := plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "block"})
+	assert.Nil(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	return count.Load(), plugins
+}
+
+func TestGossipExcludeOrigin(t *testing.T) {
+	without, _ := countWireMessages(t, WithExcludeOrigin(false))
+	with, _ := countWireMessages(t, WithExcludeOrigin(true))
+
+	// Every node relays to both of its peers, versus only to the peer the
+	// message did not arrive from.
+	assert.Equal(t, int32(6), without)
+	assert.Equal(t, int32(4), with)
+}
+
+func TestGossipAdaptiveFanout(t *testing.T) {
+	// Every node relays to a single peer, such that the message makes its way
+	// around the mesh once.
+	count, plugins := countWireMessages(t, WithAdaptiveFanout(1, 1))
+	assert.Equal(t, int32(3), count)
+
+	assert.Equal(t, Stats{Topics: map[string]TopicStats{
+		Topic(&protobuf.TestMessage{}): {Fanout: 1, Received: 1, Duplicates: 1},
+	}}, plugins[0].Stats())
+
+	assert.Empty(t, New().Stats().Topics, "fanout should only be tracked once adaptive")
+}
+
+// simulateGossip gossips messages from random origins across a simulated mesh
+// of 50 nodes running plugins built with opts, a message being gossiped every
+// 100ms. After every message, each node compares digests of all messages with
+// a random neighbour, pulling and reporting missed the messages it lacks. It
+// returns the number of messages sent, pulls included, and the plugins.
+func simulateGossip(t *testing.T, messages int, opts ...PluginOption) (int, []*Plugin) {
+	const nodes = 50
+
+	rng := rand.New(rand.NewSource(1))
+	clock := time.Unix(0, 0)
+	topic := Topic(&protobuf.TestMessage{})
+
+	plugins := make([]*Plugin, nodes)
+	neighbours := make([]map[int]struct{}, nodes)
+	seen := make([][]bool, nodes)
+
+	for i := range plugins {
+		plugins[i] = New(opts...)
+		plugins[i].now = func() time.Time { return clock }
+		neighbours[i] = make(map[int]struct{})
+		seen[i] = make([]bool, messages)
+	}
+
+	// Every node dials 8 others at random.
+	for i := range plugins {
+		for len(neighbours[i]) < 8 {
+			if j := rng.Intn(nodes); j != i {
+				neighbours[i][j] = struct{}{}
+				neighbours[j][i] = struct{}{}
+			}
+		}
+	}
+
+	// pick picks the peers a node relays to, as BroadcastFanout does.
+	pick := func(node, origin int) []int {
+		var peers []int
+		for peer := range neighbours[node] {
+			if peer != origin {
+				peers = append(peers, peer)
+			}
+		}
+		sort.Ints(peers)
+
+		if fanout := plugins[node].fanout(topic); fanout > 0 && len(peers) > fanout {
+			rng.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+			peers = peers[:fanout]
+		}
+		return peers
+	}
+
+	type send struct{ from, to int }
+
+	sent := 0
+
+	exchangeDigests := func(upTo int) (missed int) {
+		for node := range plugins {
+			peers := pick(node, -1)
+			peer := peers[rng.Intn(len(peers))]
+
+			misses := 0
+			for m := 0; m < upTo; m++ {
+				if seen[peer][m] && !seen[node][m] {
+					seen[node][m] = true
+					misses++
+				}
+			}
+
+			sent += misses
+			missed += misses
+			plugins[node].ReportMisses(topic, misses)
+		}
+		return missed
+	}
+
+	for m := 0; m < messages; m++ {
+		origin := rng.Intn(nodes)
+		seen[origin][m] = true
+
+		var queue []send
+		for _, peer := range pick(origin, -1) {
+			queue = append(queue, send{from: origin, to: peer})
+		}
+
+		for len(queue) > 0 {
+			s := queue[0]
+			queue = queue[1:]
+			sent++
+
+			plugins[s.to].observe(topic, seen[s.to][m])
+			if seen[s.to][m] {
+				continue
+			}
+			seen[s.to][m] = true
+
+			for _, peer := range pick(s.to, s.from) {
+				queue = append(queue, send{from: s.to, to: peer})
+			}
+		}
+
+		exchangeDigests(m + 1)
+		clock = clock.Add(100 * time.Millisecond)
+	}
+
+	// Messages still missing are eventually pulled.
+	for round := 0; exchangeDigests(messages) > 0; round++ {
+		if round == nodes {
+			t.Fatal("messages were never pulled")
+		}
+	}
+
+	for node := range plugins {
+		for m := 0; m < messages; m++ {
+			if !seen[node][m] {
+				t.Fatalf("node %d never received message %d", node, m)
+			}
+		}
+	}
+
+	return sent, plugins
+}
+
+func TestGossipAdaptiveFanoutSimulation(t *testing.T) {
+	t.Parallel()
+
+	fixed, _ := simulateGossip(t, 300)
+	adaptive, plugins := simulateGossip(t, 300, WithAdaptiveFanout(3, 8), WithFanoutController(0.75, 1, time.Second))
+
+	assert.True(t, adaptive < fixed/2, "adaptive fanout sent %d messages, versus %d with a fixed fanout", adaptive, fixed)
+
+	var fanouts int
+	var misses uint64
+	for _, plugin := range plugins {
+		stats := plugin.Stats().Topics[Topic(&protobuf.TestMessage{})]
+		assert.True(t, stats.Fanout >= 3 && stats.Fanout <= 8, "fanout %d out of bounds", stats.Fanout)
+		fanouts += stats.Fanout
+		misses += stats.Misses
+	}
+
+	// Fanout shrinks in the dense mesh, while hardly any messages are left to
+	// be pulled.
+	assert.True(t, fanouts < 6*len(plugins), "average fanout of %.1f never shrank", float64(fanouts)/float64(len(plugins)))
+	assert.True(t, misses < uint64(300*len(plugins)/100), "%d messages missed", misses)
+}
+
+func TestGossipFilter(t *testing.T) {
+	t.Parallel()
+
+	p := New()
+	assert.True(t, p.filter(&protobuf.TestMessage{}), "application messages should be gossiped by default")
+
+	fresh, err := p.markSeen(&protobuf.TestMessage{Message: "a"})
+	assert.Nil(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = p.markSeen(&protobuf.TestMessage{Message: "a"})
+	assert.Nil(t, err)
+	assert.False(t, fresh)
+}
+
+// buildLine connects three nodes in a line, with the middle node validating
+// gossip, and returns them alongside counts of test messages each received.
+func buildLine(t *testing.T, opts ...PluginOption) ([]*network.Network, []*Plugin, []*atomic.Int32) {
+	var nodes []*network.Network
+	var plugins []*Plugin
+	var counts []*atomic.Int32
+
+	for i := 0; i < 3; i++ {
+		builder := network.NewBuilder()
+		builder.SetKeys(ed25519.RandomKeyPair())
+		builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+
+		var plugin *Plugin
+		if i == 1 {
+			plugin = New(opts...)
+		} else {
+			plugin = New()
+		}
+		count := atomic.NewInt32(0)
+
+		builder.AddPlugin(plugin)
+		builder.AddPlugin(&countPlugin{count: count})
+
+		node, err := builder.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go node.Listen()
+		<-node.Ready()
+
+		nodes = append(nodes, node)
+		plugins = append(plugins, plugin)
+		counts = append(counts, count)
+	}
+
+	nodes[1].Bootstrap(nodes[0].Address, nodes[2].Address)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for i, expected := range []int{1, 2, 1} {
+		if err := nodes[i].WaitForPeers(ctx, expected); err != nil {
+			t.Fatalf("node %s did not connect to its peers: %v", nodes[i].Address, err)
+		}
+	}
+
+	return nodes, plugins, counts
+}
+
+func TestGossipValidatorReject(t *testing.T) {
+	t.Parallel()
+
+	nodes, plugins, counts := buildLine(t)
+	for _, node := range nodes {
+		defer node.Close()
+	}
+
+	plugins[1].RegisterValidator(Topic(&protobuf.TestMessage{}), func(peer network.PeerInfo, message proto.Message) ValidationResult {
+		if message.(*protobuf.TestMessage).Message == "garbage" {
+			return Reject
+		}
+		return Accept
+	})
+
+	assert.Nil(t, plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "garbage"}))
+	time.Sleep(500 * time.Millisecond)
+
+	assert.Equal(t, int32(1), counts[1].Load())
+	assert.Equal(t, int32(0), counts[2].Load(), "rejected messages should not be relayed")
+	assert.Equal(t, uint64(1), plugins[1].Violations(nodes[0].Address))
+
+	assert.Nil(t, plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "block"}))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return counts[2].Load() == 1 }), "accepted messages should be relayed")
+	assert.Equal(t, uint64(1), plugins[1].Violations(nodes[0].Address))
+}
+
+func TestGossipValidatorIgnoreAndTimeout(t *testing.T) {
+	t.Parallel()
+
+	var delivered []string
+	var mutex sync.Mutex
+
+	deliver := WithDeliver(func(peer network.PeerInfo, message proto.Message) {
+		mutex.Lock()
+		delivered = append(delivered, message.(*protobuf.TestMessage).Message)
+		mutex.Unlock()
+	})
+
+	nodes, plugins, counts := buildLine(t, deliver, WithValidationTimeout(100*time.Millisecond))
+	for _, node := range nodes {
+		defer node.Close()
+	}
+
+	plugins[1].RegisterValidator(Topic(&protobuf.TestMessage{}), func(peer network.PeerInfo, message proto.Message) ValidationResult {
+		switch message.(*protobuf.TestMessage).Message {
+		case "stale":
+			return Ignore
+		case "slow":
+			time.Sleep(time.Second)
+		}
+		return Accept
+	})
+
+	assert.Nil(t, plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "stale"}))
+	assert.Nil(t, plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "slow"}))
+	assert.Nil(t, plugins[0].Broadcast(nodes[0], &protobuf.TestMessage{Message: "block"}))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return counts[2].Load() == 1 }))
+	time.Sleep(500 * time.Millisecond)
+
+	assert.Equal(t, int32(1), counts[2].Load(), "ignored and timed out messages should not be relayed")
+	assert.Equal(t, uint64(0), plugins[1].Violations(nodes[0].Address))
+
+	mutex.Lock()
+	assert.Equal(t, []string{"block"}, delivered)
+	mutex.Unlock()
+}
+
+func TestGossipValidationConcurrency(t *testing.T) {
+	t.Parallel()
+
+	p := New(WithValidationConcurrency(2), WithValidationTimeout(time.Second))
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+
+	p.RegisterValidator(Topic(&protobuf.TestMessage{}), func(peer network.PeerInfo, message proto.Message) ValidationResult {
+		n := running.Inc()
+		for {
+			old := peak.Load()
+			if n <= old || peak.CAS(old, n) {
+				break
+			}
+		}
+		<-release
+		running.Dec()
+		return Accept
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, Accept, p.validate(network.PeerInfo{}, &protobuf.TestMessage{}))
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func waitUntil(d time.Duration, fn func() bool) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fn()
+}
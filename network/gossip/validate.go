@@ -0,0 +1,151 @@
+package gossip
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/network"
+)
+
+const (
+	defaultPluginValidationConcurrency = 64
+	defaultPluginValidationTimeout     = 5 * time.Second
+)
+
+// ValidationResult is the verdict of a validator on a gossiped message.
+type ValidationResult int
+
+const (
+	// Accept delivers and relays a message.
+	Accept ValidationResult = iota
+	// Ignore neither delivers nor relays a message, without penalizing the
+	// peer it arrived from.
+	Ignore
+	// Reject neither delivers nor relays a message, and counts a violation
+	// against the peer it arrived from.
+	Reject
+)
+
+func (r ValidationResult) String() string {
+	switch r {
+	case Accept:
+		return "accept"
+	case Ignore:
+		return "ignore"
+	case Reject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// Validator decides whether a gossiped message received from a peer is
+// delivered and relayed any further.
+type Validator func(peer network.PeerInfo, message proto.Message) ValidationResult
+
+// Topic returns the topic messages of the same type as message are gossiped
+// under, being their fully-qualified protobuf name.
+func Topic(message proto.Message) string {
+	return proto.MessageName(message)
+}
+
+// WithValidationConcurrency specifies how many messages may be validated at once
+func WithValidationConcurrency(limit int) PluginOption {
+	return func(o *Plugin) {
+		o.validationConcurrency = limit
+	}
+}
+
+// WithValidationTimeout specifies how long a validator may take before its message is ignored
+func WithValidationTimeout(d time.Duration) PluginOption {
+	return func(o *Plugin) {
+		o.validationTimeout = d
+	}
+}
+
+// WithDeliver specifies a callback handed every gossiped message which was
+// accepted, once. Plugins registered with the network still receive every
+// message, validated or not.
+func WithDeliver(deliver func(peer network.PeerInfo, message proto.Message)) PluginOption {
+	return func(o *Plugin) {
+		o.deliver = deliver
+	}
+}
+
+// validators holds the validators of every topic.
+type validators struct {
+	sync.RWMutex
+	topics map[string]Validator
+}
+
+// RegisterValidator has messages of a topic relayed only once a validator
+// accepts them, replacing any validator previously registered for the topic.
+// Messages of topics without a validator are always accepted.
+func (p *Plugin) RegisterValidator(topic string, validator Validator) {
+	p.validators.Lock()
+	defer p.validators.Unlock()
+
+	if p.validators.topics == nil {
+		p.validators.topics = make(map[string]Validator)
+	}
+	p.validators.topics[topic] = validator
+}
+
+// Violations returns the number of messages a peer relayed which were
+// rejected by validators.
+func (p *Plugin) Violations(address string) uint64 {
+	if count, exists := p.violations.Load(address); exists {
+		return atomic.LoadUint64(count.(*uint64))
+	}
+	return 0
+}
+
+func (p *Plugin) addViolation(address string) {
+	count, _ := p.violations.LoadOrStore(address, new(uint64))
+	atomic.AddUint64(count.(*uint64), 1)
+}
+
+// validate runs the validator of a message's topic, bounded by the number of
+// validations allowed at once. Validators which time out have their message
+// ignored.
+func (p *Plugin) validate(peer network.PeerInfo, message proto.Message) ValidationResult {
+	p.validators.RLock()
+	validator, exists := p.validators.topics[Topic(message)]
+	p.validators.RUnlock()
+
+	if !exists {
+		return Accept
+	}
+
+	timeout := time.NewTimer(p.validationTimeout)
+	defer timeout.Stop()
+
+	select {
+	case p.validationSlots <- struct{}{}:
+	case <-timeout.C:
+		return Ignore
+	}
+
+	result := make(chan ValidationResult, 1)
+	go func() {
+		defer func() { <-p.validationSlots }()
+		defer func() {
+			if r := recover(); r != nil {
+				glog.Errorf("gossip: validator of %s panicked: %v", Topic(message), r)
+				result <- Ignore
+			}
+		}()
+
+		result <- validator(peer, message)
+	}()
+
+	select {
+	case r := <-result:
+		return r
+	case <-timeout.C:
+		return Ignore
+	}
+}
@@ -0,0 +1,288 @@
+package network
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AnyGroup stands for every peer in a GroupPreference, whichever group
+	// it is in, if any.
+	AnyGroup = "*"
+
+	// groupTagPrefix prefixes the tags assigning peers to groups.
+	groupTagPrefix = "group:"
+)
+
+// ErrNoHealthyPeers is returned when none of the groups a write or request
+// prefers holds a healthy peer.
+var ErrNoHealthyPeers = errors.New("network: no healthy peers in any preferred group")
+
+// GroupPreference orders the groups peers are picked from, such as
+// PreferGroups("us-east", "us-west", AnyGroup). Peers are picked from the
+// first group holding healthy peers, falling through to later groups should
+// earlier ones hold none, or fewer than asked for. Healthy peers are those
+// connected whose circuit is not open.
+type GroupPreference []string
+
+// PreferGroups returns a GroupPreference for groups in order.
+func PreferGroups(groups ...string) GroupPreference {
+	return GroupPreference(groups)
+}
+
+// GroupTag returns the tag assigning peers to a group through TagPeer, which
+// takes precedence over the group they present in their metadata.
+func GroupTag(group string) string {
+	return groupTagPrefix + group
+}
+
+// GroupHealth summarizes the peers connected in a group.
+type GroupHealth struct {
+	// Peers is the number of peers connected in the group.
+	Peers int
+	// Healthy is the number of those whose circuit is not open.
+	Healthy int
+}
+
+// groupHealth holds the number of healthy peers of every group last seen
+// holding any, so that groups emptying and recovering are noticed.
+type groupHealth struct {
+	sync.Mutex
+	healthy map[string]int
+}
+
+// groupOf returns the group a peer is in, being empty should it be in none.
+func (n *Network) groupOf(client *PeerClient) string {
+	for _, tag := range n.tags.of(client.PeerID()) {
+		if strings.HasPrefix(tag, groupTagPrefix) {
+			return strings.TrimPrefix(tag, groupTagPrefix)
+		}
+	}
+
+	if n.opts.groupMetadataKey != "" {
+		return string(client.Metadata()[n.opts.groupMetadataKey])
+	}
+
+	return ""
+}
+
+// Group returns the group the peer is in, being empty should it be in none.
+func (c *PeerClient) Group() string {
+	return c.Network.groupOf(c)
+}
+
+// healthy returns true if a peer is connected, and its circuit is not open.
+func (n *Network) healthy(client *PeerClient) bool {
+	return !client.isClosed() && n.ConnectionStateExists(client.Address) && !n.circuitOpen(client.Address)
+}
+
+// GroupHealth returns a summary of the peers connected in every group, by
+// group. Peers in no group are summarized under the empty group.
+func (n *Network) GroupHealth() map[string]GroupHealth {
+	health := make(map[string]GroupHealth)
+
+	n.eachPeer(func(client *PeerClient) bool {
+		if !n.ConnectionStateExists(client.Address) {
+			return true
+		}
+
+		group := n.groupOf(client)
+		summary := health[group]
+		summary.Peers++
+		if n.healthy(client) {
+			summary.Healthy++
+		}
+		health[group] = summary
+		return true
+	})
+
+	return health
+}
+
+// refreshGroupHealth notices groups which held healthy peers and no longer
+// do, or which did not and do again, and notifies the application.
+func (n *Network) refreshGroupHealth() {
+	if n.opts.onGroupEmptied == nil && n.opts.onGroupRecovered == nil {
+		return
+	}
+
+	health := n.GroupHealth()
+
+	var emptied, recovered []string
+
+	n.groupHealth.Lock()
+	if n.groupHealth.healthy == nil {
+		n.groupHealth.healthy = make(map[string]int)
+	}
+	for group, healthy := range n.groupHealth.healthy {
+		if healthy > 0 && health[group].Healthy == 0 {
+			emptied = append(emptied, group)
+		}
+	}
+	for group, summary := range health {
+		if previous, seen := n.groupHealth.healthy[group]; seen && previous == 0 && summary.Healthy > 0 {
+			recovered = append(recovered, group)
+		}
+	}
+	for group := range n.groupHealth.healthy {
+		n.groupHealth.healthy[group] = 0
+	}
+	for group, summary := range health {
+		n.groupHealth.healthy[group] = summary.Healthy
+	}
+	n.groupHealth.Unlock()
+
+	sort.Strings(emptied)
+	sort.Strings(recovered)
+
+	for _, group := range emptied {
+		if n.opts.onGroupEmptied != nil {
+			n.opts.onGroupEmptied(group)
+		}
+	}
+	for _, group := range recovered {
+		if n.opts.onGroupRecovered != nil {
+			n.opts.onGroupRecovered(group, health[group])
+		}
+	}
+}
+
+// pickPreferred picks up to count healthy peers out of candidates, at random
+// from the groups of a preference in order, falling through to later groups
+// should earlier ones hold too few. A count of zero picks every healthy peer
+// of the first group holding any.
+func (n *Network) pickPreferred(preference GroupPreference, candidates []*PeerClient, count int) []*PeerClient {
+	byGroup := make(map[string][]*PeerClient)
+	var healthy []*PeerClient
+
+	for _, client := range candidates {
+		if !n.healthy(client) {
+			continue
+		}
+		group := n.groupOf(client)
+		byGroup[group] = append(byGroup[group], client)
+		healthy = append(healthy, client)
+	}
+
+	var picked []*PeerClient
+	seen := make(map[*PeerClient]struct{})
+
+	for _, group := range preference {
+		pool := byGroup[group]
+		if group == AnyGroup {
+			pool = healthy
+		}
+
+		var fresh []*PeerClient
+		for _, client := range pool {
+			if _, exists := seen[client]; !exists {
+				fresh = append(fresh, client)
+			}
+		}
+		rand.Shuffle(len(fresh), func(i, j int) { fresh[i], fresh[j] = fresh[j], fresh[i] })
+
+		for _, client := range fresh {
+			if count > 0 && len(picked) == count {
+				return picked
+			}
+			seen[client] = struct{}{}
+			picked = append(picked, client)
+		}
+
+		if count == 0 && len(picked) > 0 {
+			return picked
+		}
+	}
+
+	return picked
+}
+
+// connectedClients returns every peer client connected to.
+func (n *Network) connectedClients() []*PeerClient {
+	var clients []*PeerClient
+	n.eachPeer(func(client *PeerClient) bool {
+		clients = append(clients, client)
+		return true
+	})
+	return clients
+}
+
+// pickFromGroups picks a healthy peer from the first group of a preference
+// holding any.
+func (n *Network) pickFromGroups(preference GroupPreference) (*PeerClient, error) {
+	picked := n.pickPreferred(preference, n.connectedClients(), 1)
+	if len(picked) == 0 {
+		return nil, errors.Wrapf(ErrNoHealthyPeers, "preferring %s", strings.Join(preference, ", "))
+	}
+	return picked[0], nil
+}
+
+// WriteToGroups writes a message to a healthy peer picked at random from the
+// first group of a preference holding any, as WriteContext does, returning
+// the peer written to. It fails with ErrNoHealthyPeers should no group hold
+// any.
+func (n *Network) WriteToGroups(ctx context.Context, preference GroupPreference, message *protobuf.Message, opts ...WriteOption) (PeerInfo, error) {
+	client, err := n.pickFromGroups(preference)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+
+	return client.Info(), n.WriteContext(ctx, client.Address, message, opts...)
+}
+
+// RequestFromGroups sends a request to a healthy peer picked at random from
+// the first group of a preference holding any, as RequestContext does,
+// returning the reply alongside the peer which was requested. It fails with
+// ErrNoHealthyPeers should no group hold any.
+func (n *Network) RequestFromGroups(ctx context.Context, preference GroupPreference, message proto.Message, opts ...RequestOption) (proto.Message, PeerInfo, error) {
+	client, err := n.pickFromGroups(preference)
+	if err != nil {
+		return nil, PeerInfo{}, err
+	}
+
+	reply, err := client.RequestContext(ctx, message, opts...)
+	return reply, client.Info(), err
+}
+
+// BroadcastToGroups broadcasts a message to every healthy peer of the first
+// group of a preference holding any, returning how many peers it was sent to.
+func (n *Network) BroadcastToGroups(preference GroupPreference, message proto.Message) int {
+	return n.broadcastFanout("", message, 0, preference)
+}
+
+// BroadcastRandomlyToGroups broadcasts a message to up to K healthy peers
+// picked at random, from the groups of a preference in order, returning how
+// many peers it was sent to.
+func (n *Network) BroadcastRandomlyToGroups(preference GroupPreference, message proto.Message, K int) int {
+	if K <= 0 {
+		return 0
+	}
+
+	var candidates []*PeerClient
+	n.eachFanoutPeer(func(client *PeerClient) bool {
+		candidates = append(candidates, client)
+		return true
+	})
+
+	picked := n.pickPreferred(preference, candidates, K)
+	if len(picked) == 0 {
+		glog.Warningf("failed to broadcast %T: %v", message, ErrNoHealthyPeers)
+		return 0
+	}
+
+	addresses := make([]string, len(picked))
+	for i, client := range picked {
+		addresses[i] = client.Address
+	}
+	n.BroadcastByAddresses(message, addresses...)
+
+	return len(picked)
+}
@@ -0,0 +1,208 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// groupEvents records the groups emptied and recovered.
+type groupEvents struct {
+	sync.Mutex
+	events []string
+}
+
+func (e *groupEvents) emptied(group string) {
+	e.Lock()
+	defer e.Unlock()
+	e.events = append(e.events, "emptied "+group)
+}
+
+func (e *groupEvents) recovered(group string, health GroupHealth) {
+	e.Lock()
+	defer e.Unlock()
+	e.events = append(e.events, "recovered "+group)
+}
+
+func (e *groupEvents) take() []string {
+	e.Lock()
+	defer e.Unlock()
+	events := e.events
+	e.events = nil
+	return events
+}
+
+// buildRegion builds nodes named after a region, which reply to test
+// messages with their name, and tell test messages they receive apart on
+// received.
+func buildRegion(t *testing.T, clock *fakeClock, received chan string, names ...string) []*Network {
+	var nodes []*Network
+	for _, name := range names {
+		name := name
+		node := buildClockedNode(t, clock, func(ctx *PluginContext) {
+			msg, ok := ctx.Message().(*testpb.TestMessage)
+			if !ok {
+				return
+			}
+			if ctx.nonce > 0 {
+				ctx.Reply(&testpb.TestMessage{Message: name})
+				return
+			}
+			received <- name + ": " + msg.Message
+		}, PeerMetadata(func() map[string][]byte {
+			// Tags assigning eastern nodes to their group take precedence.
+			if name[:4] == "east" {
+				return map[string][]byte{"region": []byte("edge")}
+			}
+			return map[string][]byte{"region": []byte(name[:4])}
+		}))
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// connectRegions connects a node to others, tagging the first two as being
+// in the east, and leaving the others in the group their metadata names.
+func connectRegions(t *testing.T, node *Network, others []*Network) {
+	for i, other := range others {
+		_, err := node.Client(other.Address)
+		assert.Nil(t, err)
+
+		if i < 2 {
+			assert.Nil(t, node.TagPeer(peerIDOfNode(t, other), GroupTag("east")))
+		}
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		for _, other := range others {
+			if !node.ConnectionStateExists(other.Address) {
+				return false
+			}
+		}
+		return true
+	}), "never connected to all regions")
+}
+
+// requestFrom returns the name of the node a request preferring groups was
+// answered by.
+func requestFrom(t *testing.T, node *Network, preference GroupPreference) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	reply, info, err := node.RequestFromGroups(ctx, preference, &testpb.TestMessage{Message: "who"})
+	if !assert.Nil(t, err) {
+		return ""
+	}
+
+	name := reply.(*testpb.TestMessage).Message
+	assert.Equal(t, name[:4], info.Group)
+	return name
+}
+
+func TestRequestsFailOverAcrossGroups(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	events := &groupEvents{}
+
+	regions := buildRegion(t, clock, nil, "east-1", "east-2", "west-1")
+	for _, node := range regions {
+		defer node.Close()
+	}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {},
+		CircuitBreaker(3, time.Second, 4*time.Second),
+		PeerGroupMetadata("region"),
+		OnGroupEmptied(events.emptied),
+		OnGroupRecovered(events.recovered),
+	)
+	defer node.Close()
+
+	connectRegions(t, node, regions)
+
+	// Tagging eastern nodes moves them out of the group of their metadata.
+	events.take()
+
+	assert.Equal(t, map[string]GroupHealth{
+		"east": {Peers: 2, Healthy: 2},
+		"west": {Peers: 1, Healthy: 1},
+	}, node.GroupHealth())
+
+	preference := PreferGroups("east", "west", AnyGroup)
+
+	// Requests prefer the first group.
+	for i := 0; i < 5; i++ {
+		assert.Contains(t, []string{"east-1", "east-2"}, requestFrom(t, node, preference))
+	}
+	assert.Equal(t, "west-1", requestFrom(t, node, PreferGroups("west", "east")))
+
+	// Requests fail over once the circuits of all peers in the first group
+	// open.
+	node.markWritten(regions[0].Address, errWriteTimedOut)
+	for i := 0; i < 3; i++ {
+		node.markWritten(regions[1].Address, errWriteTimedOut)
+	}
+	assert.Contains(t, []string{"east-1", "east-2"}, requestFrom(t, node, preference))
+	assert.Empty(t, events.take())
+
+	for i := 0; i < 3; i++ {
+		node.markWritten(regions[0].Address, errWriteTimedOut)
+	}
+	assert.Equal(t, []string{"emptied east"}, events.take())
+	assert.Equal(t, GroupHealth{Peers: 2}, node.GroupHealth()["east"])
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "west-1", requestFrom(t, node, preference))
+	}
+
+	// Groups no peer is in are skipped, and no group holding healthy peers
+	// fails.
+	assert.Equal(t, "west-1", requestFrom(t, node, PreferGroups("north", AnyGroup)))
+	_, err := node.WriteToGroups(context.Background(), PreferGroups("east"), signedTestMessage(t, node, "dropped"))
+	assert.Equal(t, ErrNoHealthyPeers, errors.Cause(err))
+
+	// Requests fail back once the circuits let probes through, which close
+	// them.
+	clock.Advance(time.Second)
+	assert.Contains(t, []string{"east-1", "east-2"}, requestFrom(t, node, preference))
+	assert.Equal(t, []string{"recovered east"}, events.take())
+}
+
+func TestBroadcastRandomlyFillsFromLaterGroups(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	received := make(chan string, 8)
+
+	regions := buildRegion(t, clock, received, "east-1", "east-2", "west-1")
+	for _, node := range regions {
+		defer node.Close()
+	}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, PeerGroupMetadata("region"))
+	defer node.Close()
+
+	connectRegions(t, node, regions)
+
+	assert.Equal(t, 2, node.BroadcastRandomlyToGroups(PreferGroups("west", "east"), &testpb.TestMessage{Message: "hello"}, 2))
+	assert.Equal(t, 1, node.BroadcastToGroups(PreferGroups("west", "east"), &testpb.TestMessage{Message: "all"}))
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-received:
+			got = append(got, message)
+		case <-time.After(3 * time.Second):
+			t.Fatal("broadcast was never received")
+		}
+	}
+
+	assert.Contains(t, got, "west-1: hello")
+	assert.Contains(t, got, "west-1: all")
+	assert.True(t, containsString(got, "east-1: hello") != containsString(got, "east-2: hello"), "received %v", got)
+}
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+)
+
+// HandlerOutcome is how the peer which sent a message is treated once a
+// plugin handled the message.
+type HandlerOutcome uint8
+
+const (
+	// HandlerOk leaves the peer be.
+	HandlerOk HandlerOutcome = iota
+	// HandlerIgnore fails handling the message without holding it against
+	// the peer.
+	HandlerIgnore
+	// HandlerPenalize adds to the peer's penalty, and reports the peer as
+	// having committed a violation.
+	HandlerPenalize
+	// HandlerDisconnect reports the peer as having committed a violation, and
+	// disconnects it.
+	HandlerDisconnect
+)
+
+func (o HandlerOutcome) String() string {
+	switch o {
+	case HandlerOk:
+		return "ok"
+	case HandlerIgnore:
+		return "ignore"
+	case HandlerPenalize:
+		return "penalize"
+	case HandlerDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("outcome(%d)", uint8(o))
+	}
+}
+
+// HandlerResult is an error a plugin may return from Receive to have the peer
+// which sent the message treated as its outcome says. Plain errors are treated
+// as set by HandlerErrorResult and DefaultHandlerResult.
+type HandlerResult struct {
+	Outcome HandlerOutcome
+	// Weight is added to the peer's penalty under HandlerPenalize.
+	Weight uint64
+	// Reason is why the peer was disconnected under HandlerDisconnect, as
+	// returned by its DisconnectReason.
+	Reason string
+	// Err is the error the message failed to be handled with, if any.
+	Err error
+}
+
+func (r *HandlerResult) Error() string {
+	var msg string
+	switch r.Outcome {
+	case HandlerPenalize:
+		msg = fmt.Sprintf("handler penalized peer by %d", r.Weight)
+	case HandlerDisconnect:
+		msg = fmt.Sprintf("handler disconnected peer (%s)", r.Reason)
+	case HandlerIgnore:
+		msg = "handler ignored message"
+	default:
+		msg = "handler " + r.Outcome.String()
+	}
+	if r.Err != nil {
+		msg += ": " + r.Err.Error()
+	}
+	return msg
+}
+
+// Cause returns the error the message failed to be handled with.
+func (r *HandlerResult) Cause() error {
+	return r.Err
+}
+
+// IgnorePeer returns an error failing a message with err, without holding
+// it against the peer which sent it.
+func IgnorePeer(err error) error {
+	return &HandlerResult{Outcome: HandlerIgnore, Err: err}
+}
+
+// PenalizePeer returns an error failing a message with err, adding weight to
+// the penalty of the peer which sent it.
+func PenalizePeer(weight uint64, err error) error {
+	return &HandlerResult{Outcome: HandlerPenalize, Weight: weight, Err: err}
+}
+
+// DisconnectPeer returns an error failing a message, disconnecting the peer
+// which sent it for a reason.
+func DisconnectPeer(reason string) error {
+	return &HandlerResult{Outcome: HandlerDisconnect, Reason: reason}
+}
+
+// handlerResultOf returns the result a handler returned, be it wrapped or not.
+// Plain errors yield nil.
+func handlerResultOf(err error) *HandlerResult {
+	type causer interface {
+		Cause() error
+	}
+
+	for err != nil {
+		if result, ok := err.(*HandlerResult); ok {
+			return result
+		}
+		c, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+
+	return nil
+}
+
+// defaultHandlerResult returns how the sender of a message a plugin failed
+// with a plain error is treated, by the message's type.
+func (n *Network) defaultHandlerResult(ctx *PluginContext, name string, err error) *HandlerResult {
+	result, exists := n.opts.handlerErrorResults[name]
+	if !exists {
+		result, exists = n.opts.handlerErrorResults[proto.MessageName(ctx.Message())]
+	}
+	if !exists {
+		result = n.opts.defaultHandlerResult
+	}
+
+	result.Err = err
+	return &result
+}
+
+// applyHandlerResult treats the peer which sent a message as a plugin's
+// handling of the message says.
+func (n *Network) applyHandlerResult(client *PeerClient, result *HandlerResult) {
+	switch result.Outcome {
+	case HandlerOk:
+	case HandlerPenalize:
+		atomic.AddUint64(&client.penalty, result.Weight)
+		n.reportViolation(client, result)
+	case HandlerDisconnect:
+		n.reportViolation(client, result)
+		client.close(result.Reason)
+	default:
+		glog.Errorf("%+v", result)
+	}
+}
+
+// Penalty returns the sum of the weights of the penalties the peer was dealt
+// by plugins handling its messages.
+func (c *PeerClient) Penalty() uint64 {
+	return atomic.LoadUint64(&c.penalty)
+}
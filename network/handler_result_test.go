@@ -0,0 +1,215 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// resultPlugin returns the outcome of fn for every test message it receives.
+type resultPlugin struct {
+	*Plugin
+	fn func(text string) error
+}
+
+func (p *resultPlugin) Receive(ctx *PluginContext) error {
+	if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+		return p.fn(msg.Message)
+	}
+	return nil
+}
+
+// handlerOutcomes fails every test message as its text says.
+func handlerOutcomes(text string) error {
+	switch text {
+	case "ignore":
+		return IgnorePeer(errors.New("stale"))
+	case "penalize":
+		return PenalizePeer(3, errors.New("invalid"))
+	case "wrapped":
+		return errors.Wrap(PenalizePeer(2, nil), "while validating")
+	case "plain":
+		return errors.New("plain")
+	case "disconnect":
+		return DisconnectPeer("spamming")
+	case "panic":
+		panic("handler blew up")
+	}
+	return nil
+}
+
+// connectWithResults builds a receiving node whose plugin fails test messages
+// as their text says, handling them in order, and returns it alongside a
+// client connected to it from a second node and the client of the second
+// node on the receiving end.
+func connectWithResults(t *testing.T, violations chan error, opts ...BuilderOption) (*Network, *Network, *PeerClient, *PeerClient) {
+	opts = append(opts, OrderedHandlers(&testpb.TestMessage{}), OnViolation(func(client *PeerClient, err error) {
+		violations <- err
+	}))
+
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&resultPlugin{fn: handlerOutcomes})
+
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t)
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	// The receiving end of the connection only exists once a message made it.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "ok"}))
+
+	var peer *PeerClient
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		c, exists := receiver.peers.Load(sender.Address)
+		if exists {
+			peer = c.(*PeerClient)
+		}
+		return exists
+	}), "sender never became a peer")
+
+	return receiver, sender, client, peer
+}
+
+// nextViolation returns the next violation reported, failing should there be
+// none.
+func nextViolation(t *testing.T, violations chan error) error {
+	select {
+	case err := <-violations:
+		return err
+	case <-time.After(3 * time.Second):
+		t.Fatal("no violation was reported")
+		return nil
+	}
+}
+
+func TestHandlerResultsPenalizePeer(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 8)
+
+	receiver, sender, client, peer := connectWithResults(t, violations)
+	defer receiver.Close()
+	defer sender.Close()
+
+	// Ignored messages are not held against the peer.
+	for _, text := range []string{"ok", "ignore", "penalize"} {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: text}))
+	}
+
+	err := nextViolation(t, violations)
+	result, ok := err.(*HandlerResult)
+	if assert.True(t, ok, "violation %v is no handler result", err) {
+		assert.Equal(t, HandlerPenalize, result.Outcome)
+		assert.Equal(t, "invalid", result.Err.Error())
+	}
+	assert.Equal(t, uint64(3), peer.Penalty())
+	assert.Equal(t, uint64(1), peer.Info().Violations)
+
+	// Results wrapped in other errors still apply.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "wrapped"}))
+
+	nextViolation(t, violations)
+	assert.Equal(t, uint64(5), peer.Info().Penalty)
+	assert.Equal(t, uint64(2), peer.Info().Violations)
+
+	// Plain errors are ignored by default.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "plain"}))
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "penalize"}))
+
+	nextViolation(t, violations)
+	assert.Equal(t, uint64(8), peer.Penalty())
+	assert.Equal(t, uint64(3), peer.Info().Violations)
+	assert.False(t, peer.isClosed())
+}
+
+func TestHandlerErrorResultByType(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 8)
+
+	receiver, sender, client, peer := connectWithResults(t, violations,
+		HandlerErrorResult(&testpb.TestMessage{}, HandlerResult{Outcome: HandlerPenalize, Weight: 4}),
+	)
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "plain"}))
+
+	err := nextViolation(t, violations)
+	assert.Equal(t, "plain", errors.Cause(err).Error())
+	assert.Equal(t, uint64(4), peer.Penalty())
+
+	// Results returned by the handler take precedence over the mapping.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "ignore"}))
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "wrapped"}))
+
+	nextViolation(t, violations)
+	assert.Equal(t, uint64(6), peer.Penalty())
+	assert.Equal(t, uint64(2), peer.Info().Violations)
+}
+
+func TestHandlerResultDisconnectsPeer(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 8)
+
+	receiver, sender, client, peer := connectWithResults(t, violations)
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "disconnect"}))
+
+	nextViolation(t, violations)
+	assert.True(t, waitUntil(3*time.Second, peer.isClosed), "peer was never disconnected")
+	assert.Equal(t, "spamming", peer.DisconnectReason())
+	assert.Zero(t, peer.Penalty())
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		_, exists := receiver.peers.Load(sender.Address)
+		return !exists
+	}), "peer was never forgotten")
+}
+
+func TestHandlerPanicResult(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 8)
+
+	// Panics penalize peers rather than disconnecting them by default.
+	receiver, sender, client, peer := connectWithResults(t, violations)
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "panic"}))
+
+	err := nextViolation(t, violations)
+	_, ok := errors.Cause(err).(*HandlerPanic)
+	assert.True(t, ok, "violation %v is no panic", err)
+	assert.Equal(t, uint64(1), peer.Penalty())
+	assert.False(t, peer.isClosed())
+
+	// Panics may be mapped to any result.
+	receiver, sender, client, peer = connectWithResults(t, violations,
+		HandlerPanicResult(HandlerResult{Outcome: HandlerDisconnect, Reason: "panicked"}),
+	)
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "panic"}))
+
+	nextViolation(t, violations)
+	assert.True(t, waitUntil(3*time.Second, peer.isClosed), "peer was never disconnected")
+	assert.Equal(t, "panicked", peer.DisconnectReason())
+}
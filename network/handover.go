@@ -0,0 +1,420 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// GoodbyeCapability is advertised by nodes which understand being told a
+// node is going away, and when to reconnect to its successor.
+const GoodbyeCapability = "noise/goodbye"
+
+const (
+	// DisconnectHandover is the reason peers are disconnected with once a
+	// network hands its state over to a successor.
+	DisconnectHandover = "handover"
+	// DisconnectGoodbye is the reason peers which said goodbye are
+	// disconnected with.
+	DisconnectGoodbye = "goodbye"
+)
+
+const (
+	// maxRetryAfter bounds how long a peer saying goodbye may have us wait
+	// before reconnecting.
+	maxRetryAfter = 10 * time.Minute
+
+	// handoverDrainTimeout is how long peers are given to hang up on us once
+	// told goodbye, before their connections are closed on them.
+	handoverDrainTimeout = 5 * time.Second
+
+	// handoverDrainInterval is how often connections are checked for having
+	// drained.
+	handoverDrainInterval = 10 * time.Millisecond
+)
+
+var (
+	// ErrHandedOver is returned when exporting the state of a network which
+	// already handed it over.
+	ErrHandedOver = errors.New("network: already handed over")
+	// ErrHandoverMismatch is returned when importing the state of a network
+	// of another identity or address.
+	ErrHandoverMismatch = errors.New("network: handover state is of another node")
+	// ErrInvalidHandover is returned when importing handover state which is
+	// malformed, or which a plugin failed to import.
+	ErrInvalidHandover = errors.New("network: invalid handover state")
+	// ErrListenerNotInheritable is returned when asked for the file of a
+	// listener which cannot be passed on to another process.
+	ErrListenerNotInheritable = errors.New("network: listener cannot be handed over")
+)
+
+var goodbyeName = proto.MessageName((*protobuf.Goodbye)(nil))
+
+// HandoverParticipant may be implemented by plugins and connection gaters
+// which keep state worth handing over to a successor, such as routing tables
+// or ban lists. State is handed over between participants of the same type.
+type HandoverParticipant interface {
+	// ExportHandover serializes the participant's state.
+	ExportHandover(net *Network) ([]byte, error)
+	// ImportHandover restores state exported by a participant of the same
+	// type. It is called before the network starts listening.
+	ImportHandover(net *Network, state []byte) error
+}
+
+// handoverParticipants returns the plugins and gaters which take part in
+// handovers, by the name of their type.
+func (n *Network) handoverParticipants() map[string]HandoverParticipant {
+	participants := make(map[string]HandoverParticipant)
+
+	n.eachPlugin(func(plugin PluginInterface) {
+		if participant, ok := plugin.(HandoverParticipant); ok {
+			participants[reflect.TypeOf(plugin).String()] = participant
+		}
+	})
+	for _, gater := range n.opts.gaters {
+		if participant, ok := gater.(HandoverParticipant); ok {
+			participants[reflect.TypeOf(gater).String()] = participant
+		}
+	}
+
+	return participants
+}
+
+// ListenerFile returns a duplicate of the file descriptor of the listener
+// accepting peers, for a successor process to adopt with AdoptListener. The
+// listener keeps accepting peers until the network's state is exported.
+func (n *Network) ListenerFile() (*os.File, error) {
+	n.listenerMutex.Lock()
+	defer n.listenerMutex.Unlock()
+
+	if n.listener == nil {
+		return nil, errors.Wrap(ErrListenerNotInheritable, "not listening")
+	}
+
+	inheritable, ok := n.listener.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, ErrListenerNotInheritable
+	}
+
+	return inheritable.File()
+}
+
+// adoptListener adopts the listener set by AdoptListener in place of binding
+// one for an address.
+func (n *Network) adoptListener(addrInfo *AddressInfo) (net.Listener, error) {
+	file := n.opts.listenerFile
+	defer file.Close()
+
+	if addrInfo.Protocol != "tcp" {
+		return nil, errors.Errorf("cannot adopt a listener for protocol %s", addrInfo.Protocol)
+	}
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to adopt listener")
+	}
+
+	if addr, ok := listener.Addr().(*net.TCPAddr); !ok || addr.Port != int(addrInfo.Port) {
+		listener.Close()
+		return nil, errors.Errorf("adopted listener on %s does not listen on port %d", listener.Addr(), addrInfo.Port)
+	}
+
+	return listener, nil
+}
+
+// ExportHandoverState hands this node over to a successor process built with
+// the same keys and address. The network stops accepting peers, leaving its
+// listener to whoever adopted it, tells peers to reconnect after retryAfter,
+// and waits for their connections to drain. Its identity, peers, sessions,
+// verified addresses, reachability and the state of HandoverParticipants are
+// then serialized for the successor to import with ImportHandoverState.
+//
+// Peers which dialed us reconnect to the successor on their own, resuming
+// their sessions should SessionResumption be set. The network is left to be
+// closed once exported.
+func (n *Network) ExportHandoverState(retryAfter time.Duration) ([]byte, error) {
+	if n.isClosed() {
+		return nil, ErrNetworkClosed
+	}
+	if !atomic.CompareAndSwapUint32(&n.handedOver, 0, 1) {
+		return nil, ErrHandedOver
+	}
+
+	now := n.now()
+	peers := n.knownPeers(now)
+
+	// Stop accepting peers. A successor may have adopted the listener already,
+	// in which case it keeps accepting them.
+	n.listenerMutex.Lock()
+	if n.listener != nil {
+		n.listener.Close()
+		n.listener = nil
+	}
+	n.listenerMutex.Unlock()
+
+	goodbye := &protobuf.Goodbye{RetryAfter: int64(retryAfter)}
+	n.eachPeer(func(client *PeerClient) bool {
+		if client.HasCapability(GoodbyeCapability) {
+			if err := client.Tell(goodbye); err == nil {
+				n.flushPeer(client.Address)
+			}
+		}
+		client.close(DisconnectHandover)
+		return true
+	})
+
+	// Sessions peers may resume are only retained once their connections drain.
+	n.drainIncoming(handoverDrainTimeout)
+
+	state := &protobuf.HandoverState{
+		PublicKey: n.keys.PublicKey,
+		Address:   n.Address,
+		CreatedAt: now.UnixNano(),
+	}
+
+	for _, record := range peers {
+		state.Peers = append(state.Peers, encodePeerRecord(record))
+	}
+
+	issued, held := n.sessions.handOver()
+	for _, sess := range issued {
+		state.Issued = append(state.Issued, encodeHandoverSession("", sess))
+	}
+	addresses := make([]string, 0, len(held))
+	for address := range held {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		state.Held = append(state.Held, encodeHandoverSession(address, held[address]))
+	}
+
+	n.addresses.Lock()
+	for key, result := range n.addresses.results {
+		if !now.Before(result.expires) {
+			continue
+		}
+		i := strings.LastIndex(key, "|")
+		state.Addresses = append(state.Addresses, &protobuf.HandoverAddress{
+			PublicKey: []byte(key[:i]),
+			Address:   key[i+1:],
+			Status:    uint32(result.status),
+			Expiry:    result.expires.UnixNano(),
+		})
+	}
+	n.addresses.Unlock()
+
+	n.reachability.Lock()
+	state.Reachability = uint32(n.reachability.verdict)
+	state.ReachabilityOutcomes = append([]bool(nil), n.reachability.outcomes...)
+	if !n.reachability.last.IsZero() {
+		state.ReachabilityChecked = n.reachability.last.UnixNano()
+	}
+	n.reachability.Unlock()
+
+	participants := n.handoverParticipants()
+	names := make([]string, 0, len(participants))
+	for name := range participants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		exported, err := participants[name].ExportHandover(n)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to export handover state of %s", name)
+		}
+		state.Plugins = append(state.Plugins, &protobuf.HandoverPlugin{Name: name, State: exported})
+	}
+
+	serialized, err := proto.Marshal(state)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal handover state")
+	}
+
+	return serialized, nil
+}
+
+// drainIncoming waits for the connections peers dialed us over to close, up
+// until a timeout after which they are closed on the peers.
+func (n *Network) drainIncoming(timeout time.Duration) {
+	drained := func() bool {
+		empty := true
+		n.incoming.Range(func(_, _ interface{}) bool {
+			empty = false
+			return false
+		})
+		return empty
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !drained() {
+		if time.Now().After(deadline) {
+			n.incoming.Range(func(key, _ interface{}) bool {
+				key.(net.Conn).Close()
+				return true
+			})
+			deadline = time.Now().Add(timeout)
+		}
+
+		select {
+		case <-time.After(handoverDrainInterval):
+		case <-n.kill:
+			return
+		}
+	}
+}
+
+// ImportHandoverState takes over the state a predecessor of the same keys and
+// address exported with ExportHandoverState. It is to be called before Listen.
+// Sessions are only taken over should SessionResumption be set, and those
+// which expired since are dropped.
+func (n *Network) ImportHandoverState(serialized []byte) error {
+	state := new(protobuf.HandoverState)
+	if err := proto.Unmarshal(serialized, state); err != nil {
+		return errors.Wrap(ErrInvalidHandover, err.Error())
+	}
+
+	if !bytes.Equal(state.PublicKey, n.keys.PublicKey) || state.Address != n.Address {
+		return errors.Wrapf(ErrHandoverMismatch, "exported by %s", state.Address)
+	}
+
+	for _, record := range state.Peers {
+		if len(record.PublicKey) == 0 || bytes.Equal(record.PublicKey, n.keys.PublicKey) {
+			continue
+		}
+		n.candidates.merge(decodePeerRecord(record, record.Addresses))
+	}
+
+	issued := make([]*session, 0, len(state.Issued))
+	for _, sess := range state.Issued {
+		issued = append(issued, decodeHandoverSession(sess))
+	}
+	held := make(map[string]*session, len(state.Held))
+	for _, sess := range state.Held {
+		held[sess.Address] = decodeHandoverSession(sess)
+	}
+	n.sessions.takeOver(issued, held)
+
+	now := n.now()
+
+	n.addresses.Lock()
+	for _, address := range state.Addresses {
+		expires := time.Unix(0, address.Expiry)
+		if now.Before(expires) {
+			n.addresses.results[addressKey(address.PublicKey, address.Address)] = addressResult{
+				status:  AddressStatus(address.Status),
+				expires: expires,
+			}
+		}
+	}
+	n.addresses.Unlock()
+
+	n.reachability.Lock()
+	n.reachability.verdict = Reachability(state.Reachability)
+	n.reachability.outcomes = append([]bool(nil), state.ReachabilityOutcomes...)
+	if state.ReachabilityChecked != 0 {
+		n.reachability.last = time.Unix(0, state.ReachabilityChecked)
+	}
+	n.reachability.Unlock()
+
+	participants := n.handoverParticipants()
+	for _, plugin := range state.Plugins {
+		participant, exists := participants[plugin.Name]
+		if !exists {
+			glog.Warningf("dropping handover state of %s, which this node does not run", plugin.Name)
+			continue
+		}
+		if err := participant.ImportHandover(n, plugin.State); err != nil {
+			return errors.Wrapf(ErrInvalidHandover, "%s: %v", plugin.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func encodeHandoverSession(address string, sess *session) *protobuf.HandoverSession {
+	return &protobuf.HandoverSession{
+		Token:        sess.token,
+		PublicKey:    sess.publicKey,
+		Version:      sess.version,
+		Offer:        sess.offer,
+		MessageNonce: sess.messageNonce,
+		RequestNonce: sess.requestNonce,
+		Expiry:       sess.expiry.UnixNano(),
+		Address:      address,
+		Resumption:   sess.resumption,
+	}
+}
+
+func decodeHandoverSession(sess *protobuf.HandoverSession) *session {
+	return &session{
+		token:        sess.Token,
+		publicKey:    sess.PublicKey,
+		version:      sess.Version,
+		offer:        sess.Offer,
+		messageNonce: sess.MessageNonce,
+		requestNonce: sess.RequestNonce,
+		expiry:       time.Unix(0, sess.Expiry),
+		resumption:   sess.Resumption,
+	}
+}
+
+// handleGoodbye disconnects from a peer going away, and reconnects to its
+// successor once the peer says to should we have dialed it.
+func (n *Network) handleGoodbye(client *PeerClient, name string, payload *types.Any) bool {
+	if name != goodbyeName {
+		return false
+	}
+
+	var msg protobuf.Goodbye
+	if err := types.UnmarshalAny(payload, &msg); err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	retryAfter := time.Duration(msg.RetryAfter)
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	if retryAfter > maxRetryAfter {
+		retryAfter = maxRetryAfter
+	}
+	atomic.StoreInt64(&client.retryAfter, int64(retryAfter))
+
+	glog.Infof("peer %s said goodbye, reconnecting after %s", client.Address, retryAfter)
+
+	client.close(DisconnectGoodbye)
+
+	if client.direction != DirectionOutbound {
+		return true
+	}
+
+	address := client.Address
+	n.spawn(func() {
+		select {
+		case <-n.after(retryAfter):
+		case <-n.kill:
+			return
+		}
+
+		if _, err := n.Client(address); err != nil {
+			glog.Warningf("failed to reconnect to %s after it said goodbye: %v", address, err)
+		}
+	})
+
+	return true
+}
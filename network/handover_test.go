@@ -0,0 +1,98 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandover(t *testing.T) {
+	t.Parallel()
+
+	keys := ed25519.RandomKeyPair()
+	address := FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort()))
+
+	build := func(opts ...BuilderOption) *Network {
+		builder := NewBuilderWithOptions(append([]BuilderOption{SessionResumption(time.Minute)}, opts...)...)
+		builder.SetKeys(keys)
+		builder.SetAddress(address)
+		node, err := builder.Build()
+		assert.Nil(t, err)
+		return node
+	}
+
+	predecessor := build()
+	defer predecessor.Close()
+	go predecessor.Listen()
+	<-predecessor.Ready()
+
+	peer := buildListeningNode(t, SessionResumption(time.Minute))
+	defer peer.Close()
+
+	client, err := peer.Client(address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	waitForPeers(t, peer, 1)
+	waitForPeers(t, predecessor, 1)
+
+	file, err := predecessor.ListenerFile()
+	assert.Nil(t, err)
+
+	successor := build(AdoptListener(file))
+	defer successor.Close()
+
+	state, err := predecessor.ExportHandoverState(100 * time.Millisecond)
+	assert.Nil(t, err)
+
+	_, err = predecessor.ExportHandoverState(0)
+	assert.Equal(t, ErrHandedOver, err)
+
+	assert.Nil(t, successor.ImportHandoverState(state))
+	assert.Nil(t, predecessor.Close())
+
+	go successor.Listen()
+	<-successor.Ready()
+
+	// The peer reconnects to the successor on its own, resuming its sessions.
+	assert.True(t, waitUntil(5*time.Second, func() bool {
+		return peer.ConnectionStateExists(successor.Address)
+	}), "peer never reconnected to the successor")
+
+	reconnected, err := peer.Client(address)
+	assert.Nil(t, err)
+	assert.Nil(t, reconnected.Tell(&testpb.TestMessage{Message: "hello"}))
+	waitForPeers(t, successor, 1)
+
+	assert.Equal(t, predecessor.ID, successor.ID)
+	assert.True(t, successor.HandshakeStats().Resumed > 0)
+	assert.True(t, peer.HandshakeStats().Resumed > 0)
+
+	candidates := successor.Candidates()
+	if assert.Len(t, candidates, 1) {
+		assert.Equal(t, peer.ID.PublicKey, candidates[0].PublicKey)
+		assert.Equal(t, []string{peer.Address}, candidates[0].Addresses)
+	}
+
+	assert.Equal(t, DisconnectGoodbye, client.DisconnectReason())
+	assert.Equal(t, 100*time.Millisecond, client.RetryAfter())
+}
+
+func TestImportHandoverOfAnotherNode(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	state, err := other.ExportHandoverState(0)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrHandoverMismatch, errors.Cause(node.ImportHandoverState(state)))
+	assert.Equal(t, ErrInvalidHandover, errors.Cause(node.ImportHandoverState([]byte{0xff})))
+}
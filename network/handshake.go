@@ -0,0 +1,517 @@
+package network
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/internal/protobuf"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// DefaultProtocolVersion is the wire protocol version offered by default.
+const DefaultProtocolVersion = "noise/1"
+
+// maxHandshakeSize bounds the size of a single handshake frame.
+const maxHandshakeSize = 64 * 1024
+
+var (
+	// ErrHandshakeTimeout is returned should a peer not complete a handshake in time.
+	ErrHandshakeTimeout = errors.New("network: handshake timed out")
+	// ErrNoCommonVersion is returned should two peers not share a protocol version.
+	ErrNoCommonVersion = errors.New("network: peers share no common protocol version")
+)
+
+// HandshakeStats counts handshakes which were aborted or resumed, and the
+// puzzles handed to dialers under load.
+type HandshakeStats struct {
+	// Timeouts is the number of connections closed for not completing a handshake in time.
+	Timeouts uint64
+	// Failures is the number of connections closed for sending an invalid handshake.
+	Failures uint64
+	// Resumed is the number of handshakes which resumed a prior session.
+	Resumed uint64
+	// Sent is the number of handshake messages sent.
+	Sent uint64
+
+	// PuzzlesIssued is the number of puzzles handed to dialers under load.
+	PuzzlesIssued uint64
+	// PuzzlesSolved is the number of those the dialers solved in time.
+	PuzzlesSolved uint64
+	// PuzzlesFailed is the number of those the dialers failed to solve in
+	// time, or answered wrongly.
+	PuzzlesFailed uint64
+	// PuzzlesExempted is the number of pinned peers let through under load
+	// without being handed a puzzle.
+	PuzzlesExempted uint64
+}
+
+// handshakeResult is what was agreed upon with a peer during a handshake.
+type handshakeResult struct {
+	remote  *protobuf.ID
+	version string
+	offer   *protobuf.HandshakeOffer
+
+	// session is the resumable session established or resumed.
+	session *session
+	resumed bool
+
+	// services are the service records the peer offered, which are current
+	// even should the session have been resumed.
+	services *protobuf.ServiceRecords
+
+	// control is set for connections reserved for control messages.
+	control bool
+
+	// keys are the session keys the connection is encrypted with, if any.
+	keys *sessionKeys
+
+	// legacy is set for peers speaking the wire protocol of upstream noise
+	// nodes, with whom no handshake is exchanged.
+	legacy bool
+	// sent is the number of messages already sent to legacy peers over the
+	// connection, to carry the message nonces on from.
+	sent uint64
+}
+
+// HandshakeStats returns the number of handshakes aborted or resumed so far,
+// alongside the number of puzzles handed to dialers.
+func (n *Network) HandshakeStats() HandshakeStats {
+	return HandshakeStats{
+		Timeouts: atomic.LoadUint64(&n.handshakeTimeouts),
+		Failures: atomic.LoadUint64(&n.handshakeFailures),
+		Resumed:  atomic.LoadUint64(&n.handshakesResumed),
+		Sent:     atomic.LoadUint64(&n.handshakesSent),
+
+		PuzzlesIssued:   atomic.LoadUint64(&n.puzzlesIssued),
+		PuzzlesSolved:   atomic.LoadUint64(&n.puzzlesSolved),
+		PuzzlesFailed:   atomic.LoadUint64(&n.puzzlesFailed),
+		PuzzlesExempted: atomic.LoadUint64(&n.puzzlesExempted),
+	}
+}
+
+// localOffer returns the versions and capabilities this node supports,
+// alongside the metadata it presents to peers.
+func (n *Network) localOffer() (*protobuf.HandshakeOffer, error) {
+	offer := &protobuf.HandshakeOffer{
+		Versions:     n.opts.protocolVersions,
+		Capabilities: n.opts.capabilities,
+	}
+
+	if n.opts.peerMetadata != nil {
+		metadata, err := encodePeerMetadata(n.opts.peerMetadata())
+		if err != nil {
+			return nil, err
+		}
+		offer.Metadata = metadata
+	}
+
+	services, err := n.localServices()
+	if err != nil {
+		return nil, err
+	}
+	offer.Services = services
+
+	return offer, nil
+}
+
+// handshake runs either side of a handshake over a new connection under the
+// handshake deadline, counting any failures.
+//
+// The dialer sends its offer, the acceptor replies with its own offer and an
+// echo of the dialer's, and the dialer finishes by echoing the acceptor's
+// offer. Every step is signed, so that an offer stripped or modified in
+// transit fails verification on either side.
+//
+// Should the dialer present a session token the acceptor issued it before,
+// the acceptor may instead reply that the session was resumed, which ends the
+// handshake one step early. Either way, the acceptor may hand the dialer an
+// affinity token and a direct address to reconnect to it at.
+//
+// Under load, the acceptor may hand the dialer a proof-of-work puzzle along
+// with its reply, in which case it neither resumes sessions nor continues the
+// handshake unless the dialer's final step carries a solution to it.
+//
+// Should both sides encrypt connections, the dialer sends an ephemeral key
+// along with its offer and the acceptor replies with one of its own, from
+// which either side derives the keys everything sent past the handshake is
+// encrypted with.
+//
+// Peers are only admitted once the connection gater allows them, the
+// metadata they presented passes validation, every handshake extension run
+// with them admits them, and no hook consulted on EventPeerConnected vetoes
+// them.
+func (n *Network) handshake(conn net.Conn, direction ConnDirection, run func(conn net.Conn) (*handshakeResult, error)) (*handshakeResult, error) {
+	conn.SetDeadline(time.Now().Add(n.opts.handshakeTimeout))
+
+	result, err := run(conn)
+	if err == nil {
+		err = n.checkEncryption(result)
+	}
+	if err == nil {
+		err = n.interceptSecured(result, direction)
+	}
+	if err == nil {
+		err = n.admitPeer(result, direction)
+	}
+	if err == nil {
+		err = n.runExtensions(conn, direction, result)
+	}
+	if err == nil {
+		err = n.hookPeerConnected(result, direction)
+	}
+	if err != nil {
+		n.handshakeFailed(conn, direction, result, err)
+
+		if netErr, ok := errors.Cause(err).(net.Error); ok && netErr.Timeout() {
+			atomic.AddUint64(&n.handshakeTimeouts, 1)
+			return nil, ErrHandshakeTimeout
+		}
+		atomic.AddUint64(&n.handshakeFailures, 1)
+		return nil, errors.Wrap(err, "handshake failed")
+	}
+
+	if result.resumed {
+		atomic.AddUint64(&n.handshakesResumed, 1)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return result, nil
+}
+
+// handshakeDialer handshakes with the peer we dialed at an address. Sessions
+// are neither presented nor held for probes, so that probing an address does
+// not interfere with resuming the connection to it, nor for control
+// connections, which are opened afresh alongside every data connection.
+func (n *Network) handshakeDialer(conn net.Conn, address string, probe bool, control bool) (*handshakeResult, error) {
+	offer, err := n.localOffer()
+	if err != nil {
+		return nil, err
+	}
+	hello := &protobuf.Handshake{Offer: offer, Control: control}
+
+	ephemeral, err := n.ephemeralKey()
+	if err != nil {
+		return nil, err
+	}
+	n.offerEphemeralKey(hello, ephemeral)
+
+	probe = probe || control
+
+	var held *session
+	if !probe {
+		held = n.sessions.takeHeld(address)
+	}
+	if held != nil {
+		hello.SessionToken = held.token
+	}
+	if !probe {
+		if affinity := n.heldAffinity(address); affinity != nil {
+			hello.AffinityToken = affinity.token
+		}
+	}
+
+	if err := n.sendHandshake(conn, hello); err != nil {
+		return nil, err
+	}
+
+	reply, err := n.receiveHandshake(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if !offer.Equal(reply.Echo) {
+		return nil, errors.New("peer received a different offer than the one sent")
+	}
+
+	if len(reply.EphemeralKey) > 0 && ephemeral == nil {
+		return nil, errors.New("peer replied with an ephemeral key though none was sent")
+	}
+
+	var resuming *session
+	if reply.Resumed {
+		resuming = held
+	}
+
+	keys, err := n.agreeSessionKeys(ephemeral, reply, true, resuming)
+	if err != nil {
+		return nil, err
+	}
+
+	if !probe {
+		n.holdAffinity(address, reply.Sender.PublicKey, reply.Affinity)
+	}
+
+	if reply.Resumed {
+		if held == nil || !bytes.Equal(held.publicKey, reply.Sender.PublicKey) {
+			return nil, errors.New("peer resumed a session which was never presented")
+		}
+
+		resumed := held.renew(reply.SessionToken)
+		resumed.resumption = keys.resumptionSecret()
+		n.sessions.hold(address, resumed)
+
+		return &handshakeResult{remote: reply.Sender, version: held.version, offer: held.offer, session: resumed, resumed: true, services: reply.Offer.GetServices(), keys: keys}, nil
+	}
+
+	version, err := negotiateVersion(offer.Versions, reply.Offer.Versions)
+	if err != nil {
+		return nil, err
+	}
+
+	ack := &protobuf.Handshake{Offer: offer, Echo: reply.Offer}
+	if reply.Puzzle != nil {
+		if ack.PuzzleSolution, err = n.solvePuzzle(reply.Puzzle); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := n.sendHandshake(conn, ack); err != nil {
+		return nil, err
+	}
+
+	result := &handshakeResult{remote: reply.Sender, version: version, offer: reply.Offer, services: reply.Offer.GetServices(), control: control, keys: keys}
+
+	if len(reply.SessionToken) > 0 && !probe {
+		result.session = n.sessions.newSession(reply.SessionToken, reply.Sender.PublicKey, version, reply.Offer)
+		result.session.resumption = keys.resumptionSecret()
+		n.sessions.hold(address, result.session)
+	}
+
+	return result, nil
+}
+
+func (n *Network) handshakeAcceptor(conn net.Conn) (*handshakeResult, error) {
+	offer, err := n.localOffer()
+	if err != nil {
+		return nil, err
+	}
+
+	hello, err := n.receiveHandshake(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if hello.Control && !n.opts.splitControlPlane {
+		return nil, errors.New("peer opened a control connection though the control plane is not split")
+	}
+
+	puzzle, err := n.issuePuzzle(conn, hello)
+	if err != nil {
+		return nil, err
+	}
+
+	var affinity *protobuf.Affinity
+	if !hello.Control {
+		affinity = n.issueAffinity(hello)
+	}
+
+	// Dialers which encrypt are replied to with an ephemeral key, should we
+	// encrypt too, and every other dialer hung up on should we require it.
+	if n.opts.encryption == EncryptionRequired && len(hello.EphemeralKey) == 0 {
+		return nil, ErrEncryptionRequired
+	}
+
+	var ephemeral *ecdh.PrivateKey
+	if len(hello.EphemeralKey) > 0 {
+		if ephemeral, err = n.ephemeralKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sessions are not resumed with dialers handed a puzzle, as resuming ends
+	// the handshake before they could answer it.
+	if len(hello.SessionToken) > 0 && !hello.Control && puzzle == nil {
+		if prior := n.sessions.takeIssued(hello.SessionToken, hello.Sender.PublicKey, hello.Offer); prior != nil {
+			keys, err := n.agreeSessionKeys(ephemeral, hello, false, prior)
+			if err != nil {
+				return nil, err
+			}
+
+			resumed := prior.renew(n.sessions.newToken())
+			resumed.resumption = keys.resumptionSecret()
+			n.sessions.issue(resumed)
+
+			reply := &protobuf.Handshake{Offer: offer, Echo: hello.Offer, SessionToken: resumed.token, Resumed: true, Affinity: affinity}
+			n.offerEphemeralKey(reply, ephemeral)
+			if err := n.sendHandshake(conn, reply); err != nil {
+				return nil, err
+			}
+
+			return &handshakeResult{remote: hello.Sender, version: prior.version, offer: prior.offer, session: resumed, resumed: true, keys: keys}, nil
+		}
+	}
+
+	version, err := negotiateVersion(hello.Offer.Versions, offer.Versions)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := n.agreeSessionKeys(ephemeral, hello, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &protobuf.Handshake{Offer: offer, Echo: hello.Offer, Affinity: affinity, Puzzle: puzzle}
+	n.offerEphemeralKey(reply, ephemeral)
+
+	var issued *session
+	if n.sessions.enabled() && !hello.Control {
+		issued = n.sessions.newSession(n.sessions.newToken(), hello.Sender.PublicKey, version, hello.Offer)
+		issued.resumption = keys.resumptionSecret()
+		reply.SessionToken = issued.token
+	}
+
+	if err := n.sendHandshake(conn, reply); err != nil {
+		return nil, err
+	}
+	if puzzle != nil {
+		atomic.AddUint64(&n.puzzlesIssued, 1)
+	}
+
+	ack, err := n.receiveHandshake(conn)
+	if err != nil {
+		if puzzle != nil {
+			atomic.AddUint64(&n.puzzlesFailed, 1)
+		}
+		return nil, err
+	}
+
+	if puzzle != nil {
+		if err := n.verifyPuzzle(puzzle, hello.Sender.PublicKey, ack.PuzzleSolution); err != nil {
+			return nil, err
+		}
+	}
+
+	if !bytes.Equal(ack.Sender.PublicKey, hello.Sender.PublicKey) {
+		return nil, errors.New("peer changed identity mid-handshake")
+	}
+
+	if !hello.Offer.Equal(ack.Offer) {
+		return nil, errors.New("peer changed its offer mid-handshake")
+	}
+
+	if !offer.Equal(ack.Echo) {
+		return nil, errors.New("peer received a different offer than the one sent")
+	}
+
+	if issued != nil {
+		n.sessions.issue(issued)
+	}
+
+	return &handshakeResult{remote: hello.Sender, version: version, offer: hello.Offer, session: issued, control: hello.Control, keys: keys}, nil
+}
+
+// negotiateVersion picks the dialer's most preferred version also supported
+// by the acceptor, so that both sides arrive at the same choice.
+func negotiateVersion(dialer, acceptor []string) (string, error) {
+	for _, version := range dialer {
+		for _, supported := range acceptor {
+			if version == supported {
+				return version, nil
+			}
+		}
+	}
+	return "", ErrNoCommonVersion
+}
+
+// sendHandshake signs and writes a handshake step.
+func (n *Network) sendHandshake(w io.Writer, msg *protobuf.Handshake) error {
+	id := protobuf.ID(n.ID)
+	msg.Sender = &id
+	msg.SignatureScheme = n.signatureScheme()
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal handshake")
+	}
+
+	msg.Signature, err = n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFrame(w, msg); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&n.handshakesSent, 1)
+	return nil
+}
+
+// receiveHandshake reads and verifies a handshake step.
+func (n *Network) receiveHandshake(r io.Reader) (*protobuf.Handshake, error) {
+	msg := new(protobuf.Handshake)
+	if err := readFrame(r, msg, maxHandshakeSize); err != nil {
+		return nil, err
+	}
+
+	if msg.Sender == nil || msg.Sender.PublicKey == nil || msg.Offer == nil || msg.Signature == nil {
+		return nil, errors.New("received an invalid handshake (either no sender, no offer, or no signature)")
+	}
+
+	// Ephemeral peers advertise no address, and every other peer one.
+	if !validSenderAddress(msg.Sender, msg.Offer) {
+		return nil, errors.New("received an invalid handshake (sender address at odds with whether it is ephemeral)")
+	}
+
+	signature := msg.Signature
+	msg.Signature = nil
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal handshake")
+	}
+
+	policy := n.signaturePolicy(msg.SignatureScheme)
+	if policy == nil {
+		return nil, errors.Errorf("received handshake was signed under unknown signature scheme %q", msg.SignatureScheme)
+	}
+
+	if !crypto.Verify(policy, n.opts.hashPolicy, msg.Sender.PublicKey, payload, signature) {
+		return nil, errors.New("received handshake had a malformed signature")
+	}
+
+	msg.Signature = signature
+	return msg, nil
+}
+
+// writeFrame writes a length-prefixed message to a stream.
+func writeFrame(w io.Writer, msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal frame")
+	}
+
+	buffer := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buffer, uint32(len(payload)))
+	copy(buffer[4:], payload)
+
+	if _, err := w.Write(buffer); err != nil {
+		return errors.Wrap(err, "failed to write frame")
+	}
+	return nil
+}
+
+// readFrame reads a length-prefixed message from a stream.
+func readFrame(r io.Reader, msg proto.Message, maxSize uint32) error {
+	_, size, err := frameCodec{maxSize: uint64(maxSize)}.readHeader(r)
+	if err != nil {
+		return err
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return errors.Wrap(err, "failed to read frame")
+	}
+
+	return errors.Wrap(proto.Unmarshal(payload, msg), "failed to unmarshal frame")
+}
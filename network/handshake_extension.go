@@ -0,0 +1,270 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/internal/protobuf"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// ErrExtensionRejected is the cause of ExtensionRejections.
+var ErrExtensionRejected = errors.New("network: peer rejected by handshake extension")
+
+// extensionCapabilityPrefix prefixes the capabilities handshake extensions
+// are advertised under.
+const extensionCapabilityPrefix = "noise/ext/"
+
+// ExtensionCapability returns the capability a handshake extension registered
+// under a name is advertised under.
+func ExtensionCapability(name string) string {
+	return extensionCapabilityPrefix + name
+}
+
+// HandshakeExtension adds an application-defined step to handshakes, such as
+// to exchange and check credentials, which peers must pass before they are
+// admitted.
+//
+// Extensions supported by both sides of a handshake exchange a payload each
+// in a single round once the handshake otherwise succeeded, within the
+// handshake deadline. Each extension first contributes its payload, then
+// processes the payload the peer contributed, and finally renders a verdict
+// on whether to admit the peer. Extensions are run in the order they were
+// registered, and the first to reject the peer fails the handshake.
+//
+// Extensions are shared by all handshakes, and may be called concurrently.
+type HandshakeExtension interface {
+	// Contribute returns the payload to send to the peer.
+	Contribute(peer *ExtensionPeer) ([]byte, error)
+	// Process processes the payload the peer contributed, failing the
+	// handshake should it return an error.
+	Process(peer *ExtensionPeer, payload []byte) error
+	// Verdict returns whether to admit the peer, and if not, why.
+	Verdict(peer *ExtensionPeer) (admit bool, reason string)
+}
+
+// ExtensionPeer describes the peer a handshake extension is run against.
+type ExtensionPeer struct {
+	// ID is the ID the peer handshook as.
+	ID PeerID
+	// Address is the address the peer advertised.
+	Address string
+	// Direction is the direction the connection to the peer was established
+	// in.
+	Direction ConnDirection
+
+	// State is kept for the extension across the calls made for the same
+	// handshake, such as for Process to hand what it made of the payload of
+	// the peer over to Verdict.
+	State interface{}
+}
+
+// ExtensionRejection is returned by handshakes a handshake extension rejected.
+type ExtensionRejection struct {
+	// Extension is the name of the extension which rejected the peer.
+	Extension string
+	// Reason is why the peer was rejected.
+	Reason string
+}
+
+func (r *ExtensionRejection) Error() string {
+	return fmt.Sprintf("network: handshake extension %q rejected peer: %s", r.Extension, r.Reason)
+}
+
+// Cause returns ErrExtensionRejected.
+func (r *ExtensionRejection) Cause() error {
+	return ErrExtensionRejected
+}
+
+// HandshakeFailure describes a failed handshake.
+type HandshakeFailure struct {
+	// Address is the address of the peer, being the remote address of the
+	// connection should the peer not have identified itself.
+	Address string
+	// Direction is the direction the connection was established in.
+	Direction ConnDirection
+	// Extension is the name of the handshake extension which rejected the
+	// peer, if any.
+	Extension string
+	// Reason is why the handshake failed.
+	Reason string
+}
+
+// handshakeExtension is a handshake extension registered under a name.
+type handshakeExtension struct {
+	name      string
+	required  bool
+	extension HandshakeExtension
+}
+
+// runExtensions runs the handshake extensions supported by both us and a
+// peer we handshook with. Peers lacking an extension we require are rejected
+// outright.
+//
+// The dialer sends its payloads first, and the acceptor replies with its own
+// before either processes those of the other, so that both sides learn of
+// each other's payloads however they judge them.
+func (n *Network) runExtensions(conn net.Conn, direction ConnDirection, result *handshakeResult) error {
+	var shared []*handshakeExtension
+
+	for _, ext := range n.handshakeExtensions {
+		if !containsString(result.offer.GetCapabilities(), ExtensionCapability(ext.name)) {
+			if ext.required {
+				return &ExtensionRejection{Extension: ext.name, Reason: "peer lacks the extension"}
+			}
+			continue
+		}
+		shared = append(shared, ext)
+	}
+
+	if len(shared) == 0 {
+		return nil
+	}
+
+	peers := make([]*ExtensionPeer, len(shared))
+	local := new(protobuf.HandshakeExtensions)
+
+	for i, ext := range shared {
+		peers[i] = &ExtensionPeer{ID: peerIDOf(result.remote), Address: result.remote.Address, Direction: direction}
+
+		payload, err := ext.extension.Contribute(peers[i])
+		if err != nil {
+			return errors.Wrapf(err, "handshake extension %q failed to contribute", ext.name)
+		}
+
+		local.Extensions = append(local.Extensions, &protobuf.HandshakeExtension{Name: ext.name, Payload: payload})
+	}
+
+	var remote *protobuf.HandshakeExtensions
+	var err error
+
+	if direction == DirectionOutbound {
+		if err = n.sendExtensions(conn, local); err == nil {
+			remote, err = n.receiveExtensions(conn, result.remote.PublicKey)
+		}
+	} else {
+		if remote, err = n.receiveExtensions(conn, result.remote.PublicKey); err == nil {
+			err = n.sendExtensions(conn, local)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	payloads := make(map[string][]byte, len(remote.Extensions))
+	for _, contributed := range remote.Extensions {
+		payloads[contributed.Name] = contributed.Payload
+	}
+
+	for i, ext := range shared {
+		payload, exists := payloads[ext.name]
+		if !exists {
+			return &ExtensionRejection{Extension: ext.name, Reason: "peer contributed no payload"}
+		}
+
+		if err := ext.extension.Process(peers[i], payload); err != nil {
+			return &ExtensionRejection{Extension: ext.name, Reason: err.Error()}
+		}
+
+		if admit, reason := ext.extension.Verdict(peers[i]); !admit {
+			return &ExtensionRejection{Extension: ext.name, Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+// sendExtensions signs and writes the payloads of our handshake extensions.
+func (n *Network) sendExtensions(w io.Writer, msg *protobuf.HandshakeExtensions) error {
+	id := protobuf.ID(n.ID)
+	msg.Sender = &id
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal handshake extensions")
+	}
+
+	msg.Signature, err = n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFrame(w, msg); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&n.handshakesSent, 1)
+	return nil
+}
+
+// receiveExtensions reads and verifies the payloads of the handshake
+// extensions of the peer holding a public key.
+func (n *Network) receiveExtensions(r io.Reader, publicKey []byte) (*protobuf.HandshakeExtensions, error) {
+	msg := new(protobuf.HandshakeExtensions)
+	if err := readFrame(r, msg, maxHandshakeSize); err != nil {
+		return nil, err
+	}
+
+	if msg.Sender == nil || msg.Signature == nil {
+		return nil, errors.New("received invalid handshake extensions (either no sender or no signature)")
+	}
+
+	if !bytes.Equal(msg.Sender.PublicKey, publicKey) {
+		return nil, errors.New("peer changed identity mid-handshake")
+	}
+
+	signature := msg.Signature
+	msg.Signature = nil
+
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal handshake extensions")
+	}
+
+	if !crypto.Verify(n.opts.signaturePolicy, n.opts.hashPolicy, publicKey, payload, signature) {
+		return nil, errors.New("received handshake extensions had a malformed signature")
+	}
+
+	msg.Signature = signature
+	return msg, nil
+}
+
+// handshakeFailed reports a failed handshake over a connection.
+func (n *Network) handshakeFailed(conn net.Conn, direction ConnDirection, result *handshakeResult, err error) {
+	failure := HandshakeFailure{Address: conn.RemoteAddr().String(), Direction: direction, Reason: err.Error()}
+	if result != nil && result.remote != nil && len(result.remote.Address) > 0 {
+		failure.Address = result.remote.Address
+	}
+
+	// Rejections are wrapped, and themselves have ErrExtensionRejected as
+	// their cause.
+	for cause := err; cause != nil; {
+		if rejection, ok := cause.(*ExtensionRejection); ok {
+			failure.Extension = rejection.Extension
+			failure.Reason = rejection.Reason
+			break
+		}
+
+		causer, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = causer.Cause()
+	}
+
+	if n.opts.onHandshakeFailed != nil {
+		n.opts.onHandshakeFailed(failure)
+	}
+
+	header := EventHeader{Address: failure.Address}
+	if result != nil && result.remote != nil {
+		header.Peer = peerIDOf(result.remote)
+	}
+	n.publishEvent(&HandshakeFailedEvent{EventHeader: header, Failure: failure})
+}
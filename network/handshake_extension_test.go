@@ -0,0 +1,148 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// membership is a handshake extension presenting a certificate of the node's
+// public key signed by a registry, and admitting peers presenting one.
+type membership struct {
+	registry    *crypto.KeyPair
+	certificate []byte
+}
+
+func newMembership(t *testing.T, registry *crypto.KeyPair, member *crypto.KeyPair) *membership {
+	certificate, err := registry.Sign(ed25519.New(), blake2b.New(), member.PublicKey)
+	assert.Nil(t, err)
+	return &membership{registry: registry, certificate: certificate}
+}
+
+func (m *membership) Contribute(peer *ExtensionPeer) ([]byte, error) {
+	return m.certificate, nil
+}
+
+func (m *membership) Process(peer *ExtensionPeer, payload []byte) error {
+	peer.State = crypto.Verify(ed25519.New(), blake2b.New(), m.registry.PublicKey, peer.ID.PublicKey(), payload)
+	return nil
+}
+
+func (m *membership) Verdict(peer *ExtensionPeer) (bool, string) {
+	if !peer.State.(bool) {
+		return false, "not a member"
+	}
+	return true, ""
+}
+
+// buildMemberNode builds a listening node presenting a certificate of its
+// membership to a registry, should it be given one.
+func buildMemberNode(t *testing.T, registry *crypto.KeyPair, required bool, opts ...BuilderOption) *Network {
+	keys := ed25519.RandomKeyPair()
+
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(keys)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	if registry != nil {
+		assert.Nil(t, builder.AddHandshakeExtension("membership", required, newMembership(t, registry, keys)))
+	}
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestHandshakeExtensionAdmitsMembers(t *testing.T) {
+	t.Parallel()
+
+	registry := ed25519.RandomKeyPair()
+
+	server := buildMemberNode(t, registry, true)
+	defer server.Close()
+	member := buildMemberNode(t, registry, true)
+	defer member.Close()
+
+	assert.Contains(t, server.opts.capabilities, ExtensionCapability("membership"))
+
+	_, err := member.Client(server.Address)
+	assert.Nil(t, err)
+
+	waitForPeers(t, member, 1)
+}
+
+func TestHandshakeExtensionRejectsBeforePeersAppear(t *testing.T) {
+	t.Parallel()
+
+	failures := make(chan HandshakeFailure, 4)
+
+	server := buildMemberNode(t, ed25519.RandomKeyPair(), true, OnHandshakeFailed(func(failure HandshakeFailure) {
+		failures <- failure
+	}))
+	defer server.Close()
+
+	// The impostor is certified by another registry.
+	impostor := buildMemberNode(t, ed25519.RandomKeyPair(), true)
+	defer impostor.Close()
+
+	_, err := impostor.Client(server.Address)
+	assert.Equal(t, ErrExtensionRejected, errors.Cause(err))
+
+	select {
+	case failure := <-failures:
+		assert.Equal(t, "membership", failure.Extension)
+		assert.Equal(t, "not a member", failure.Reason)
+		assert.Equal(t, DirectionInbound, failure.Direction)
+		assert.Equal(t, impostor.Address, failure.Address)
+	case <-time.After(3 * time.Second):
+		t.Fatal("handshake never failed")
+	}
+
+	assert.Empty(t, server.Peers())
+	assert.Empty(t, impostor.Peers())
+}
+
+func TestOptionalHandshakeExtensionInterop(t *testing.T) {
+	t.Parallel()
+
+	registry := ed25519.RandomKeyPair()
+
+	failures := make(chan HandshakeFailure, 4)
+
+	optional := buildMemberNode(t, registry, false)
+	defer optional.Close()
+	required := buildMemberNode(t, registry, true, OnHandshakeFailed(func(failure HandshakeFailure) {
+		failures <- failure
+	}))
+	defer required.Close()
+
+	// Peers lacking an optional extension handshake without it.
+	plain := buildMemberNode(t, nil, false)
+	defer plain.Close()
+
+	_, err := plain.Client(optional.Address)
+	assert.Nil(t, err)
+	waitForPeers(t, plain, 1)
+
+	// Though not with peers requiring it, which reject them once their side
+	// of the handshake is done.
+	plain.Client(required.Address)
+
+	select {
+	case failure := <-failures:
+		assert.Equal(t, "membership", failure.Extension)
+		assert.Equal(t, "peer lacks the extension", failure.Reason)
+	case <-time.After(3 * time.Second):
+		t.Fatal("handshake never failed")
+	}
+	assert.Empty(t, required.Peers())
+}
@@ -0,0 +1,138 @@
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshakeStalledIsReaped(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(HandshakeTimeout(100 * time.Millisecond))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	go node.Listen()
+	<-node.Ready()
+
+	addrInfo, err := ParseAddress(node.Address)
+	assert.Nil(t, err)
+
+	// Connect, but never send a handshake.
+	conn, err := net.Dial("tcp", addrInfo.HostPort())
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(3 * time.Second))
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "stalled connection should have been closed")
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.True(t, waitUntil(time.Second, func() bool { return node.HandshakeStats().Timeouts == 1 }))
+}
+
+func TestHandshakeNegotiatesVersion(t *testing.T) {
+	_, err := negotiateVersion([]string{"noise/2"}, []string{"noise/1"})
+	assert.Equal(t, ErrNoCommonVersion, err)
+
+	version, err := negotiateVersion([]string{"noise/2", "noise/1"}, []string{"noise/1", "noise/2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "noise/2", version, "dialer preference should win")
+}
+
+// tamperProxy relays a single connection to a target address, rewriting the
+// offer of the first handshake frame sent in one direction.
+func tamperProxy(t *testing.T, target string, tamperReply bool) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	targetInfo, err := ParseAddress(target)
+	assert.Nil(t, err)
+
+	go func() {
+		defer listener.Close()
+
+		in, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		out, err := net.Dial("tcp", targetInfo.HostPort())
+		if err != nil {
+			in.Close()
+			return
+		}
+
+		// Tear down both sides as soon as either one hangs up.
+		defer in.Close()
+		defer out.Close()
+
+		src, dst := in, out
+		if tamperReply {
+			src, dst = out, in
+		}
+
+		go func() {
+			io.Copy(src, dst)
+			in.Close()
+			out.Close()
+		}()
+
+		// Strip every version but the first from the offer.
+		msg := new(protobuf.Handshake)
+		if err := readFrame(src, msg, maxHandshakeSize); err != nil {
+			return
+		}
+		msg.Offer.Versions = msg.Offer.Versions[:1]
+		if err := writeFrame(dst, msg); err != nil {
+			return
+		}
+
+		io.Copy(dst, src)
+	}()
+
+	return FormatAddress("tcp", "127.0.0.1", uint16(listener.Addr().(*net.TCPAddr).Port))
+}
+
+func TestHandshakeDowngradeDetected(t *testing.T) {
+	t.Parallel()
+
+	for _, tamperReply := range []bool{false, true} {
+		versions := ProtocolVersions("noise/2", DefaultProtocolVersion)
+
+		builder := NewBuilderWithOptions(versions)
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		acceptor, err := builder.Build()
+		assert.Nil(t, err)
+
+		builder = NewBuilderWithOptions(versions)
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		dialer, err := builder.Build()
+		assert.Nil(t, err)
+
+		go acceptor.Listen()
+		go dialer.Listen()
+		<-acceptor.Ready()
+		<-dialer.Ready()
+
+		_, err = dialer.Client(tamperProxy(t, acceptor.Address, tamperReply))
+		assert.NotNil(t, err, "dialer should abort a tampered handshake")
+
+		assert.Equal(t, uint64(1), dialer.HandshakeStats().Failures)
+		assert.True(t, waitUntil(time.Second, func() bool { return acceptor.HandshakeStats().Failures == 1 }),
+			"acceptor should abort a tampered handshake")
+		assert.Equal(t, 0, len(acceptor.Peers()))
+
+		acceptor.Close()
+		dialer.Close()
+	}
+}
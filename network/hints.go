@@ -0,0 +1,108 @@
+package network
+
+import (
+	"encoding/binary"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// MaxHintsSize bounds the total size of the keys and values of the routing
+// hints a message envelope carries.
+const MaxHintsSize = 256
+
+// ErrHintsTooLarge is the error messages carrying routing hints larger than
+// MaxHintsSize are refused with, whether sent or received.
+var ErrHintsTooLarge = errors.New("network: routing hints too large")
+
+// SetHint sets a routing hint on an envelope, replacing any hint under the
+// same key. Outbound hooks may set hints on the messages they see, which are
+// then covered by the sender's signature. Errors with ErrHintsTooLarge should
+// the hints of the envelope grow past MaxHintsSize.
+func SetHint(msg *Envelope, key string, value []byte) error {
+	for _, hint := range msg.Hints {
+		if hint.Key == key {
+			hint.Value = value
+			return checkHints(msg.Hints)
+		}
+	}
+
+	msg.Hints = append(msg.Hints, &protobuf.Hint{Key: key, Value: value})
+	return checkHints(msg.Hints)
+}
+
+// HintsOf returns the routing hints an envelope carries, keyed by name.
+func HintsOf(msg *Envelope) map[string][]byte {
+	return hintsMap(msg.Hints)
+}
+
+func hintsMap(hints []*protobuf.Hint) map[string][]byte {
+	if len(hints) == 0 {
+		return nil
+	}
+
+	m := make(map[string][]byte, len(hints))
+	for _, hint := range hints {
+		m[hint.Key] = hint.Value
+	}
+	return m
+}
+
+// toHints returns routing hints keyed by name as carried by envelopes, in no
+// particular order.
+func toHints(m map[string][]byte) []*protobuf.Hint {
+	if len(m) == 0 {
+		return nil
+	}
+
+	hints := make([]*protobuf.Hint, 0, len(m))
+	for key, value := range m {
+		hints = append(hints, &protobuf.Hint{Key: key, Value: value})
+	}
+	return hints
+}
+
+// checkHints returns ErrHintsTooLarge should routing hints exceed MaxHintsSize.
+func checkHints(hints []*protobuf.Hint) error {
+	size := 0
+	for _, hint := range hints {
+		size += len(hint.Key) + len(hint.Value)
+	}
+
+	if size > MaxHintsSize {
+		return errors.Wrapf(ErrHintsTooLarge, "%d bytes", size)
+	}
+	return nil
+}
+
+// serializeHints appends the routing hints of a message to its serialized
+// envelope in the order they are carried, prefixed with how many there are,
+// so that relays may not alter them in transit.
+func serializeHints(serialized []byte, hints []*protobuf.Hint) []byte {
+	if len(hints) == 0 {
+		return serialized
+	}
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(hints)))
+	serialized = append(serialized, size[:]...)
+
+	for _, hint := range hints {
+		for _, field := range [][]byte{[]byte(hint.Key), hint.Value} {
+			binary.LittleEndian.PutUint32(size[:], uint32(len(field)))
+			serialized = append(serialized, size[:]...)
+			serialized = append(serialized, field...)
+		}
+	}
+	return serialized
+}
+
+// Hints returns the routing hints the message was sent with, keyed by name.
+// They are read off the envelope, without decoding the message.
+func (ctx *PluginContext) Hints() map[string][]byte {
+	ctx.own.check()
+	if ctx.frame == nil {
+		return nil
+	}
+	return HintsOf(ctx.frame.Message)
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type hinted struct {
+	node  *Network
+	hints map[string][]byte
+	hops  int
+}
+
+// buildHandlerNode builds a listening node running fn on every test message.
+func buildHandlerNode(t *testing.T, fn func(ctx *PluginContext)) *Network {
+	builder := NewBuilder()
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: fn})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestHintsSurviveRelay(t *testing.T) {
+	t.Parallel()
+
+	hints := map[string][]byte{"shard": {7}, "region": []byte("eu")}
+
+	arrivals := make(chan hinted, 2)
+	var destination *Network
+
+	// The relay picks the next hop by the region the message is hinted with.
+	relay := buildHandlerNode(t, func(ctx *PluginContext) {
+		arrivals <- hinted{node: ctx.Network(), hints: ctx.Hints(), hops: ctx.Hops()}
+
+		_, err := ctx.Forward(TowardOptions{NextHop: func(hints map[string][]byte) string {
+			if bytes.Equal(hints["region"], []byte("eu")) {
+				return destination.Address
+			}
+			return ""
+		}})
+		assert.Nil(t, err)
+	})
+	defer relay.Close()
+
+	destination = buildHandlerNode(t, func(ctx *PluginContext) {
+		arrivals <- hinted{node: ctx.Network(), hints: ctx.Hints(), hops: ctx.Hops()}
+	})
+	defer destination.Close()
+
+	origin := buildListeningNode(t)
+	defer origin.Close()
+
+	_, err := origin.Client(relay.Address)
+	assert.Nil(t, err)
+	_, err = relay.Client(destination.Address)
+	assert.Nil(t, err)
+
+	// The destination's ID is not the key, so only hints lead the message there.
+	chosen, err := origin.WriteToward(context.Background(), relay.ID.Id, &testpb.TestMessage{Message: "hinted"}, TowardOptions{Hints: hints})
+	assert.Nil(t, err)
+	assert.Equal(t, relay.Address, chosen.Address)
+
+	for i, expected := range []*Network{relay, destination} {
+		select {
+		case arrival := <-arrivals:
+			assert.Equal(t, expected.Address, arrival.node.Address)
+			assert.Equal(t, hints, arrival.hints)
+			assert.Equal(t, i+1, arrival.hops)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("hinted message never reached %s", expected.Address)
+		}
+	}
+
+	_, err = origin.WriteToward(context.Background(), relay.ID.Id, &testpb.TestMessage{}, TowardOptions{
+		Hints: map[string][]byte{"oversized": make([]byte, MaxHintsSize)},
+	})
+	assert.Equal(t, ErrHintsTooLarge, errors.Cause(err))
+}
+
+func TestTamperedHintsFailVerification(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan map[string][]byte, 2)
+	receiver, sender, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- ctx.Hints()
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	send := func(tamper bool) {
+		signed, err := sender.prepareEnvelope("", &testpb.TestMessage{Message: "hinted"}, envelopeFields{})
+		assert.Nil(t, err)
+
+		// Hints are set before the message is signed, as outbound hooks do.
+		assert.Nil(t, SetHint(signed, "shard", []byte{7}))
+		assert.Nil(t, sender.signMessage(signed))
+
+		if tamper {
+			signed.Hints[0].Value = []byte{8}
+		}
+		assert.Equal(t, !tamper, receiver.verifyMessage(signed))
+		assert.Nil(t, sender.Write(receiver.Address, signed))
+	}
+
+	send(false)
+	select {
+	case hints := <-received:
+		assert.Equal(t, map[string][]byte{"shard": {7}}, hints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("untampered message was not delivered")
+	}
+
+	// Messages whose hints were altered in transit fail verification, and
+	// are never handed over to plugins.
+	send(true)
+	select {
+	case hints := <-received:
+		t.Fatalf("tampered message was delivered with hints %v", hints)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
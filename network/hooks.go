@@ -0,0 +1,49 @@
+package network
+
+import (
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+// Envelope is the signed wrapper every message is sent over the wire in.
+type Envelope = protobuf.Message
+
+// OutboundHook is invoked on every outgoing message before it is signed, and
+// may enrich the message's metadata. Returning an error vetoes the send.
+type OutboundHook func(peer PeerInfo, msg *Envelope) error
+
+type outboundHook struct {
+	fn              OutboundHook
+	peerIndependent bool
+}
+
+// runOutboundHooks runs either all peer-independent or all per-peer hooks in
+// the order they were registered, stopping at the first veto.
+func (n *Network) runOutboundHooks(peer PeerInfo, msg *Envelope, peerIndependent bool) error {
+	for _, hook := range n.outboundHooks {
+		if hook.peerIndependent != peerIndependent {
+			continue
+		}
+		if err := hook.fn(peer, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasPeerHooks returns true if any per-peer outbound hooks are registered.
+func (n *Network) hasPeerHooks() bool {
+	for _, hook := range n.outboundHooks {
+		if !hook.peerIndependent {
+			return true
+		}
+	}
+	return false
+}
+
+// peerInfo returns what we know about the peer at a given address.
+func (n *Network) peerInfo(address string) PeerInfo {
+	if c, exists := n.peers.Load(address); exists {
+		return c.(*PeerClient).info()
+	}
+	return PeerInfo{Address: address}
+}
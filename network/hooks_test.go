@@ -0,0 +1,118 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// metadataPlugin stores the metadata of all received test messages.
+type metadataPlugin struct {
+	*Plugin
+	received chan map[string]string
+}
+
+func (p *metadataPlugin) Receive(ctx *PluginContext) error {
+	if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+		p.received <- ctx.Metadata()
+	}
+	return nil
+}
+
+func TestOutboundHookEnrichment(t *testing.T) {
+	t.Parallel()
+
+	plugin := &metadataPlugin{received: make(chan map[string]string, 1)}
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(plugin)
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	var order []string
+
+	builder = NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddOutboundHook(func(peer PeerInfo, msg *Envelope) error {
+		order = append(order, "peer")
+		msg.Metadata["peer"] = peer.Address
+		return nil
+	})
+	builder.AddPeerIndependentOutboundHook(func(peer PeerInfo, msg *Envelope) error {
+		order = append(order, "tenant")
+		msg.Metadata = map[string]string{"tenant": "acme"}
+		return nil
+	})
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "stamp me"}))
+
+	select {
+	case metadata := <-plugin.received:
+		assert.Equal(t, map[string]string{"tenant": "acme", "peer": receiver.Address}, metadata)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	assert.Equal(t, []string{"tenant", "peer"}, order, "peer-independent hooks should run first")
+}
+
+func TestOutboundHookVeto(t *testing.T) {
+	t.Parallel()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	vetoed := errors.New("vetoed")
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddOutboundHook(func(peer PeerInfo, msg *Envelope) error {
+		if _, ok := msg.Metadata["allowed"]; !ok {
+			return vetoed
+		}
+		return nil
+	})
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	err = client.Tell(&testpb.TestMessage{Message: "blocked"})
+	assert.Equal(t, vetoed, errors.Cause(err))
+}
+
+func TestMetadataCoveredBySignature(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	msg, err := node.PrepareMessage(&testpb.TestMessage{Message: "hi"})
+	assert.Nil(t, err)
+	assert.Equal(t, SerializeMessage(msg.Sender, msg.Message.Value), serializeEnvelope(msg))
+
+	msg.Metadata = map[string]string{"tenant": "acme"}
+	assert.NotEqual(t, SerializeMessage(msg.Sender, msg.Message.Value), serializeEnvelope(msg))
+}
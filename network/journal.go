@@ -0,0 +1,211 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// ErrJournalFull is the error a message is rejected with should the inbound
+// journal hold as many bytes of messages yet to be handled as it may.
+var ErrJournalFull = errors.New("network: inbound journal full")
+
+// JournalStore is a write-ahead store messages received are appended to ahead
+// of being handled, as set by InboundJournal. Implementations must be safe for
+// concurrent use.
+type JournalStore interface {
+	// Append appends an entry, returning the sequence number it was appended
+	// under. Sequence numbers increase with every entry appended, and are
+	// never 0. Entries must have been stored durably once Append returns.
+	Append(entry []byte) (uint64, error)
+	// Consume marks the entry appended under a sequence number as consumed,
+	// so that it may be truncated away.
+	Consume(seq uint64) error
+	// Pending returns the entries yet to be consumed, in the order they were
+	// appended.
+	Pending() ([]JournalEntry, error)
+	// Close releases the store. Networks leave the stores they journal to
+	// open, for them to be handed to networks built after them.
+	Close() error
+}
+
+// JournalEntry is an entry of a JournalStore.
+type JournalEntry struct {
+	Seq  uint64
+	Data []byte
+}
+
+// inboundJournal journals messages received into a store, bounding the bytes
+// of entries pending in it.
+type inboundJournal struct {
+	store    JournalStore
+	maxBytes int
+
+	sync.Mutex
+	sizes map[uint64]int
+	bytes int
+
+	// recovered are the entries a previous run left pending, redelivered once
+	// the network starts listening.
+	recovered []JournalEntry
+}
+
+func openInboundJournal(store JournalStore, maxBytes int) (*inboundJournal, error) {
+	pending, err := store.Pending()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read inbound journal")
+	}
+
+	j := &inboundJournal{
+		store:     store,
+		maxBytes:  maxBytes,
+		sizes:     make(map[uint64]int, len(pending)),
+		recovered: pending,
+	}
+	for _, entry := range pending {
+		j.sizes[entry.Seq] = len(entry.Data)
+		j.bytes += len(entry.Data)
+	}
+
+	return j, nil
+}
+
+// append appends an entry, failing with ErrJournalFull should it not fit.
+func (j *inboundJournal) append(entry []byte) (uint64, error) {
+	j.Lock()
+	if j.bytes+len(entry) > j.maxBytes {
+		j.Unlock()
+		return 0, ErrJournalFull
+	}
+	j.bytes += len(entry)
+	j.Unlock()
+
+	seq, err := j.store.Append(entry)
+
+	j.Lock()
+	defer j.Unlock()
+
+	if err != nil {
+		j.bytes -= len(entry)
+		return 0, errors.Wrap(err, "failed to journal message")
+	}
+	j.sizes[seq] = len(entry)
+
+	return seq, nil
+}
+
+// consume marks an entry as consumed. Entries the store fails to mark are
+// redelivered should the network restart.
+func (j *inboundJournal) consume(seq uint64) {
+	if err := j.store.Consume(seq); err != nil {
+		glog.Warningf("network: failed to mark journaled message %d consumed: %v", seq, err)
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if size, exists := j.sizes[seq]; exists {
+		delete(j.sizes, seq)
+		j.bytes -= size
+	}
+}
+
+// consumeJournaled marks a message journaled under seq as consumed, should it
+// have been journaled.
+func (n *Network) consumeJournaled(seq uint64) {
+	if n.journal != nil && seq > 0 {
+		n.journal.consume(seq)
+	}
+}
+
+// journalMessage appends a message received to the inbound journal, with the
+// payload it carries should it have arrived within a batch.
+func (n *Network) journalMessage(frame *receivedMessage, payload *types.Any) (uint64, error) {
+	entry := &protobuf.JournalEntry{
+		Frame:      frame.raw,
+		ReceivedAt: frame.receivedAt.UnixNano(),
+	}
+	if payload != frame.Message.Message {
+		entry.Payload = payload
+	}
+
+	serialized, err := proto.Marshal(entry)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to marshal journal entry")
+	}
+
+	return n.journal.append(serialized)
+}
+
+// redeliverJournal hands the messages a previous run journaled, yet never
+// finished handling, over to plugins one at a time in the order they were
+// received. Messages plugins fail to handle again stay journaled.
+func (n *Network) redeliverJournal() {
+	if n.journal == nil {
+		return
+	}
+
+	recovered := n.journal.recovered
+	n.journal.recovered = nil
+
+	for _, entry := range recovered {
+		result, err := n.redeliver(entry.Data)
+		if err != nil {
+			// Entries which can not be decoded never will be.
+			glog.Warningf("network: dropping journaled message %d: %v", entry.Seq, err)
+			n.journal.consume(entry.Seq)
+			continue
+		}
+
+		if len(result.Errors) > 0 {
+			glog.Warningf("network: failed to handle journaled message %d: %v", entry.Seq, result.Errors)
+			continue
+		}
+
+		n.journal.consume(entry.Seq)
+	}
+}
+
+// redeliver hands a journaled message over to the plugins registered under
+// the protocol tag it was sent under. Replies plugins send fail for there
+// being no connection to the peer.
+func (n *Network) redeliver(serialized []byte) (*ReplayResult, error) {
+	entry := new(protobuf.JournalEntry)
+	if err := proto.Unmarshal(serialized, entry); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal journal entry")
+	}
+
+	msg, err := decodeEnvelope(entry.Frame)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := msg.Message
+	if entry.Payload != nil {
+		payload = entry.Payload
+	}
+
+	name, err := payloadName(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := n.detachedClient(msg.Sender)
+	if err != nil {
+		return nil, err
+	}
+	client.setID((*peer.ID)(msg.Sender))
+
+	received := &receivedMessage{Message: msg, raw: entry.Frame, receivedAt: time.Unix(0, entry.ReceivedAt), refs: 1}
+
+	result := &ReplayResult{Verified: true}
+	n.replayPayload(client, received, name, payload, result)
+
+	return result, nil
+}
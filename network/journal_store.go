@@ -0,0 +1,398 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// memoryJournal is a JournalStore holding entries in memory, surviving the
+// networks using it though not the process.
+type memoryJournal struct {
+	sync.Mutex
+
+	entries map[uint64][]byte
+	nextSeq uint64
+}
+
+// NewMemoryJournal returns a JournalStore holding entries in memory. Entries
+// survive networks being rebuilt over the store, though not the process.
+func NewMemoryJournal() JournalStore {
+	return &memoryJournal{entries: make(map[uint64][]byte), nextSeq: 1}
+}
+
+func (j *memoryJournal) Append(entry []byte) (uint64, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	seq := j.nextSeq
+	j.nextSeq++
+	j.entries[seq] = append([]byte(nil), entry...)
+
+	return seq, nil
+}
+
+func (j *memoryJournal) Consume(seq uint64) error {
+	j.Lock()
+	defer j.Unlock()
+
+	delete(j.entries, seq)
+	return nil
+}
+
+func (j *memoryJournal) Close() error {
+	return nil
+}
+
+func (j *memoryJournal) Pending() ([]JournalEntry, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	pending := make([]JournalEntry, 0, len(j.entries))
+	for seq, data := range j.entries {
+		pending = append(pending, JournalEntry{Seq: seq, Data: data})
+	}
+	sort.Slice(pending, func(i, k int) bool { return pending[i].Seq < pending[k].Seq })
+
+	return pending, nil
+}
+
+const (
+	// journalSegmentBytes is the size past which a file journal starts
+	// appending to a new segment. Segments are deleted once all entries
+	// appended to them, and to the segments before them, were consumed.
+	journalSegmentBytes = 4 * 1024 * 1024
+
+	// journalMaxSegments is how many segments may pile up before the entries
+	// pending in the oldest are carried over to the newest.
+	journalMaxSegments = 4
+
+	// Kinds of records appended to the segments of a file journal.
+	journalAppend  = 1
+	journalConsume = 2
+)
+
+// journalSegment is a file holding a run of records of a file journal.
+type journalSegment struct {
+	id   uint64
+	size int64
+	// live is the number of entries appended to the segment yet to be
+	// consumed.
+	live int
+}
+
+func (s *journalSegment) path(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d.journal", s.id))
+}
+
+// fileJournal is a JournalStore appending records to segment files, being
+// synced to disk on every append. Records are framed as those of spill logs,
+// and consist of their kind, the sequence number of their entry, and the
+// entry's data should they append it.
+type fileJournal struct {
+	sync.Mutex
+
+	dir string
+
+	segments []*journalSegment
+	writer   *os.File
+
+	pending map[uint64]pendingJournalEntry
+	nextSeq uint64
+}
+
+type pendingJournalEntry struct {
+	data    []byte
+	segment *journalSegment
+}
+
+// OpenFileJournal opens a JournalStore keeping entries in segment files under
+// a directory, picking up the entries left pending in it. Appends are synced
+// to disk before they return. A record torn by a crash ends the segment it was
+// being appended to.
+func OpenFileJournal(dir string) (JournalStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create journal directory")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read journal directory")
+	}
+
+	j := &fileJournal{dir: dir, pending: make(map[uint64]pendingJournalEntry), nextSeq: 1}
+
+	for _, file := range files {
+		var id uint64
+		if !strings.HasSuffix(file.Name(), ".journal") {
+			continue
+		}
+		if _, err := fmt.Sscanf(file.Name(), "%08d.journal", &id); err != nil {
+			continue
+		}
+		j.segments = append(j.segments, &journalSegment{id: id, size: file.Size()})
+	}
+	sort.Slice(j.segments, func(i, k int) bool { return j.segments[i].id < j.segments[k].id })
+
+	for _, segment := range j.segments {
+		if err := j.load(segment); err != nil {
+			return nil, err
+		}
+	}
+
+	j.truncate()
+
+	return j, nil
+}
+
+// load replays the records of a segment.
+func (j *fileJournal) load(segment *journalSegment) error {
+	data, err := ioutil.ReadFile(segment.path(j.dir))
+	if err != nil {
+		return errors.Wrap(err, "failed to read journal segment")
+	}
+
+	for len(data) >= spillHeaderSize {
+		length := int(binary.LittleEndian.Uint32(data[0:4]))
+		if length > len(data)-spillHeaderSize {
+			break
+		}
+
+		record := data[spillHeaderSize : spillHeaderSize+length]
+		if crc32.ChecksumIEEE(record) != binary.LittleEndian.Uint32(data[4:8]) {
+			break
+		}
+		data = data[spillHeaderSize+length:]
+
+		kind, seq, entry, ok := decodeJournalRecord(record)
+		if !ok {
+			break
+		}
+
+		if seq >= j.nextSeq {
+			j.nextSeq = seq + 1
+		}
+
+		switch kind {
+		case journalAppend:
+			// Entries carried over to a later segment are appended anew.
+			if carried, exists := j.pending[seq]; exists {
+				carried.segment.live--
+			}
+			j.pending[seq] = pendingJournalEntry{data: entry, segment: segment}
+			segment.live++
+		case journalConsume:
+			if pending, exists := j.pending[seq]; exists {
+				delete(j.pending, seq)
+				pending.segment.live--
+			}
+		}
+	}
+
+	return nil
+}
+
+func encodeJournalRecord(kind byte, seq uint64, entry []byte) []byte {
+	record := make([]byte, 1+binary.MaxVarintLen64+len(entry))
+	record[0] = kind
+	n := binary.PutUvarint(record[1:], seq)
+	copy(record[1+n:], entry)
+	record = record[:1+n+len(entry)]
+
+	buf := make([]byte, spillHeaderSize+len(record))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(record))
+	copy(buf[spillHeaderSize:], record)
+
+	return buf
+}
+
+func decodeJournalRecord(record []byte) (kind byte, seq uint64, entry []byte, ok bool) {
+	if len(record) < 2 {
+		return 0, 0, nil, false
+	}
+
+	seq, n := binary.Uvarint(record[1:])
+	if n <= 0 || seq == 0 {
+		return 0, 0, nil, false
+	}
+
+	return record[0], seq, record[1+n:], true
+}
+
+// write appends a record to the last segment, starting a new one should the
+// last segment be full.
+func (j *fileJournal) write(record []byte, sync bool) (*journalSegment, error) {
+	if j.writer == nil || j.segments[len(j.segments)-1].size >= journalSegmentBytes {
+		if err := j.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := j.writer.Write(record); err != nil {
+		return nil, errors.Wrap(err, "failed to append to journal")
+	}
+	if sync {
+		if err := j.writer.Sync(); err != nil {
+			return nil, errors.Wrap(err, "failed to sync journal")
+		}
+	}
+
+	last := j.segments[len(j.segments)-1]
+	last.size += int64(len(record))
+
+	return last, nil
+}
+
+// rotate starts a new segment to append records to.
+func (j *fileJournal) rotate() error {
+	var id uint64
+	if len(j.segments) > 0 {
+		id = j.segments[len(j.segments)-1].id + 1
+	}
+	segment := &journalSegment{id: id}
+
+	file, err := os.OpenFile(segment.path(j.dir), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create journal segment")
+	}
+
+	if j.writer != nil {
+		j.writer.Close()
+	}
+
+	j.writer = file
+	j.segments = append(j.segments, segment)
+
+	return nil
+}
+
+// truncate deletes the leading segments whose entries were all consumed,
+// leaving the segment being appended to. Entries left pending in the oldest
+// segment are carried over to the segment being appended to once more than
+// journalMaxSegments segments pile up, so that a few entries never consumed
+// do not keep every segment after them around.
+func (j *fileJournal) truncate() {
+	for len(j.segments) > 0 {
+		head := j.segments[0]
+		if j.writer != nil && len(j.segments) == 1 {
+			return
+		}
+		if head.live > 0 && (len(j.segments) <= journalMaxSegments || !j.carryOver(head)) {
+			return
+		}
+
+		os.Remove(head.path(j.dir))
+		j.segments = j.segments[1:]
+	}
+}
+
+// carryOver appends the entries pending in a segment anew under the same
+// sequence numbers, returning true once the segment holds none.
+func (j *fileJournal) carryOver(segment *journalSegment) bool {
+	var seqs []uint64
+	for seq, pending := range j.pending {
+		if pending.segment == segment {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, k int) bool { return seqs[i] < seqs[k] })
+
+	moved := make(map[uint64]*journalSegment, len(seqs))
+	for _, seq := range seqs {
+		to, err := j.write(encodeJournalRecord(journalAppend, seq, j.pending[seq].data), false)
+		if err != nil {
+			return false
+		}
+		moved[seq] = to
+	}
+	if err := j.writer.Sync(); err != nil {
+		return false
+	}
+
+	for seq, to := range moved {
+		pending := j.pending[seq]
+		pending.segment.live--
+		pending.segment = to
+		to.live++
+		j.pending[seq] = pending
+	}
+
+	return segment.live == 0
+}
+
+func (j *fileJournal) Append(entry []byte) (uint64, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	seq := j.nextSeq
+
+	segment, err := j.write(encodeJournalRecord(journalAppend, seq, entry), true)
+	if err != nil {
+		return 0, err
+	}
+
+	j.nextSeq++
+	j.pending[seq] = pendingJournalEntry{data: append([]byte(nil), entry...), segment: segment}
+	segment.live++
+
+	return seq, nil
+}
+
+// Consume marks an entry consumed without syncing to disk, entries whose mark
+// is lost to a crash being handled again.
+func (j *fileJournal) Consume(seq uint64) error {
+	j.Lock()
+	defer j.Unlock()
+
+	pending, exists := j.pending[seq]
+	if !exists {
+		return nil
+	}
+
+	if _, err := j.write(encodeJournalRecord(journalConsume, seq, nil), false); err != nil {
+		return err
+	}
+
+	delete(j.pending, seq)
+	pending.segment.live--
+
+	j.truncate()
+
+	return nil
+}
+
+func (j *fileJournal) Pending() ([]JournalEntry, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	pending := make([]JournalEntry, 0, len(j.pending))
+	for seq, entry := range j.pending {
+		pending = append(pending, JournalEntry{Seq: seq, Data: entry.data})
+	}
+	sort.Slice(pending, func(i, k int) bool { return pending[i].Seq < pending[k].Seq })
+
+	return pending, nil
+}
+
+func (j *fileJournal) Close() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.writer == nil {
+		return nil
+	}
+
+	err := j.writer.Close()
+	j.writer = nil
+
+	return err
+}
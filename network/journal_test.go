@@ -0,0 +1,220 @@
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// ledger applies entries idempotently, outliving the networks handling them.
+type ledger struct {
+	sync.Mutex
+	applied map[string]int
+	handled int
+}
+
+// ledgerPlugin applies test messages to a ledger, crashing before applying
+// one of them.
+type ledgerPlugin struct {
+	*Plugin
+	ledger  *ledger
+	crashOn string
+}
+
+func (p *ledgerPlugin) Receive(ctx *PluginContext) error {
+	msg, ok := ctx.Message().(*testpb.TestMessage)
+	if !ok {
+		return nil
+	}
+
+	if msg.Message == p.crashOn {
+		panic("crashed between journaling and handling")
+	}
+
+	p.ledger.Lock()
+	defer p.ledger.Unlock()
+
+	p.ledger.handled++
+	if p.ledger.applied[msg.Message] == 0 {
+		p.ledger.applied[msg.Message]++
+	}
+
+	return nil
+}
+
+func TestJournalRedeliversAfterCrash(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryJournal()
+	applied := &ledger{applied: make(map[string]int)}
+
+	build := func(crashOn string) *Network {
+		builder := NewBuilderWithOptions(InboundJournal(store, 1024*1024))
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(&ledgerPlugin{ledger: applied, crashOn: crashOn})
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		go node.Listen()
+		<-node.Ready()
+
+		return node
+	}
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	receiver := build("c")
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	for _, message := range []string{"a", "b", "c"} {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: message}))
+	}
+
+	// Only the message handling crashed on is left journaled.
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		applied.Lock()
+		defer applied.Unlock()
+
+		pending, err := store.Pending()
+		return err == nil && len(pending) == 1 && applied.handled == 2
+	}), "messages were never handled")
+	assert.Nil(t, receiver.Close())
+
+	// A restarted network handles it before anything else.
+	receiver = build("")
+	defer receiver.Close()
+
+	pending, err := store.Pending()
+	assert.Nil(t, err)
+	assert.Len(t, pending, 0)
+
+	// The sender retrying the message it never heard back of has no effect.
+	client, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "c"}))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		applied.Lock()
+		defer applied.Unlock()
+		return applied.handled == 4
+	}), "retried message was never handled")
+
+	applied.Lock()
+	defer applied.Unlock()
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1}, applied.applied)
+}
+
+func TestJournalFullRejectsMessages(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		ctx.Reply(&testpb.TestMessage{Message: "ok"})
+	}, InboundJournal(NewMemoryJournal(), 64), SendRejections(10, 10))
+	defer receiver.Close()
+	defer sender.Close()
+
+	_, err := client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: strings.Repeat("x", 128)}, Timeout: 3 * time.Second})
+	assert.Equal(t, ErrJournalFull, errors.Cause(err))
+
+	_, err = NewBuilderWithOptions(InboundJournal(NewMemoryJournal(), 0)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestFileJournal(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenFileJournal(dir)
+	assert.Nil(t, err)
+
+	a, err := store.Append([]byte("a"))
+	assert.Nil(t, err)
+	b, err := store.Append([]byte("b"))
+	assert.Nil(t, err)
+	assert.Nil(t, store.Consume(a))
+	assert.Nil(t, store.Close())
+
+	// Entries left pending survive reopening, and sequence numbers carry on.
+	store, err = OpenFileJournal(dir)
+	assert.Nil(t, err)
+
+	pending, err := store.Pending()
+	assert.Nil(t, err)
+	assert.Equal(t, []JournalEntry{{Seq: b, Data: []byte("b")}}, pending)
+
+	c, err := store.Append([]byte("c"))
+	assert.Nil(t, err)
+	assert.True(t, c > b)
+	assert.Nil(t, store.Close())
+
+	// A record torn by a crash is dropped, along with whatever follows it.
+	segments, err := filepath.Glob(filepath.Join(dir, "*.journal"))
+	assert.Nil(t, err)
+	last := segments[len(segments)-1]
+	data, err := ioutil.ReadFile(last)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(last, data[:len(data)-1], 0600))
+
+	store, err = OpenFileJournal(dir)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	pending, err = store.Pending()
+	assert.Nil(t, err)
+	assert.Equal(t, []JournalEntry{{Seq: b, Data: []byte("b")}}, pending)
+}
+
+func TestFileJournalIsTruncated(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenFileJournal(dir)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	segments := func() int {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.journal"))
+		assert.Nil(t, err)
+		return len(matches)
+	}
+
+	// An entry never consumed is carried over, rather than keeping every
+	// segment after it around.
+	stuck, err := store.Append([]byte("stuck"))
+	assert.Nil(t, err)
+
+	entry := make([]byte, journalSegmentBytes/2)
+	for i := 0; i < 4*journalMaxSegments; i++ {
+		seq, err := store.Append(entry)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Consume(seq))
+	}
+	assert.True(t, segments() <= journalMaxSegments+1, "%d segments left", segments())
+
+	pending, err := store.Pending()
+	assert.Nil(t, err)
+	assert.Equal(t, []JournalEntry{{Seq: stuck, Data: []byte("stuck")}}, pending)
+
+	assert.Nil(t, store.Consume(stuck))
+	seq, err := store.Append(entry)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Consume(seq))
+	assert.Equal(t, 1, segments())
+}
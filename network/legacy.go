@@ -0,0 +1,404 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// LegacyProtocolVersion is the version legacy peers are recorded as speaking:
+// the wire protocol of upstream noise nodes, which this network talks to
+// peers in only with AllowLegacyPeers. Legacy peers exchange no handshake,
+// the first message they send identifying them instead, and prefix frames
+// with their size as a uvarint zero-padded to 10 bytes. Messages are signed
+// over their sender's address and ID followed by their payload, as
+// SerializeMessage lays out, and carry no fields beyond the payload, sender,
+// signature, nonces and reply flag. Of the messages the network handles
+// itself, legacy peers only understand pings, pongs, node lookups and bytes.
+//
+// Sessions with legacy peers therefore carry no batches, padding, keepalives,
+// control connections, session resumption, protocol tags, metadata, hints or
+// additional signatures, and neither do legacy peers advertise capabilities.
+const LegacyProtocolVersion = "noise/0"
+
+// legacyHeaderSize is the size of the header prefixing legacy frames.
+const legacyHeaderSize = binary.MaxVarintLen64
+
+// ErrLegacyUnsupported is returned when sending legacy peers a message they
+// would not understand.
+var ErrLegacyUnsupported = errors.New("network: not supported by legacy peers")
+
+// legacyMessages are the messages the network handles itself which legacy
+// peers understand.
+var legacyMessages = map[string]struct{}{
+	pingName:                                 {},
+	bytesName:                                {},
+	proto.MessageName((*protobuf.Pong)(nil)): {},
+	proto.MessageName((*protobuf.LookupNodeRequest)(nil)):  {},
+	proto.MessageName((*protobuf.LookupNodeResponse)(nil)): {},
+}
+
+// internalPackage is the import path of the messages the network handles
+// itself.
+var internalPackage = reflect.TypeOf(protobuf.Ping{}).PkgPath()
+
+// legacyMessage returns true if legacy peers understand messages of a type,
+// being messages of the application's and those of legacyMessages.
+func legacyMessage(name string) bool {
+	if _, exists := legacyMessages[name]; exists {
+		return true
+	}
+	t := proto.MessageType(name)
+	return t == nil || t.Elem().PkgPath() != internalPackage
+}
+
+// hasForkFields returns true if a message carries any field of an envelope
+// legacy peers know nothing of.
+func hasForkFields(msg *protobuf.Message) bool {
+	return len(msg.Metadata) > 0 || len(msg.Signatures) > 0 || len(msg.CriticalExtensions) > 0 ||
+		len(msg.Protocol) > 0 || msg.BudgetMs > 0 || len(msg.Hints) > 0 || len(msg.Padding) > 0
+}
+
+// legacyEnvelope returns a copy of a message carrying only the fields legacy
+// peers know of. Our own messages are signed anew over their legacy preimage,
+// while messages signed by other nodes are only relayed to legacy peers
+// should their signatures already cover the legacy preimage alone.
+func (n *Network) legacyEnvelope(message *protobuf.Message) (*protobuf.Message, error) {
+	name, err := types.AnyMessageName(message.Message)
+	if err != nil {
+		return nil, err
+	}
+	if !legacyMessage(name) {
+		return nil, errors.Wrapf(ErrLegacyUnsupported, "failed to send %s", name)
+	}
+	if len(message.Protocol) > 0 {
+		return nil, errors.Wrapf(ErrLegacyUnsupported, "failed to send under %q", message.Protocol)
+	}
+
+	legacy := &protobuf.Message{
+		Message:      message.Message,
+		Sender:       message.Sender,
+		Signature:    message.Signature,
+		RequestNonce: message.RequestNonce,
+		ReplyFlag:    message.ReplyFlag,
+	}
+
+	if !bytes.Equal(message.Sender.PublicKey, n.keys.PublicKey) {
+		if hasForkFields(message) || signedCanonically(message) {
+			return nil, errors.Wrapf(ErrLegacyUnsupported, "failed to relay %s signed over fields of its envelope", name)
+		}
+		return legacy, nil
+	}
+
+	legacy.Signature, err = n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, SerializeMessage(legacy.Sender, legacy.Message.Value))
+	if err != nil {
+		return nil, err
+	}
+
+	return legacy, nil
+}
+
+// verifyLegacy returns true if a message received from a legacy peer carries
+// no fields legacy peers know nothing of, and is signed over its legacy
+// preimage.
+func (n *Network) verifyLegacy(msg *protobuf.Message) bool {
+	if hasForkFields(msg) {
+		return false
+	}
+	return crypto.Verify(n.opts.signaturePolicy, n.opts.hashPolicy, msg.Sender.PublicKey, SerializeMessage(msg.Sender, msg.Message.Value), msg.Signature)
+}
+
+// validateLegacyFrame decodes and verifies a frame received from a legacy
+// peer, as validateFrame does frames received from every other peer.
+func (n *Network) validateLegacyFrame(source net.Addr, remote []byte, buffer []byte) (*protobuf.Message, error) {
+	msg, err := decodeEnvelope(buffer)
+	if err != nil {
+		atomic.AddUint64(&n.screenedFrames, 1)
+		return nil, &malformedFrameError{err: err}
+	}
+
+	if err := n.screenFrame(source, remote, msg, len(buffer)); err != nil {
+		atomic.AddUint64(&n.screenedFrames, 1)
+		return msg, err
+	}
+
+	if name, _ := types.AnyMessageName(msg.Message); !legacyMessage(name) {
+		atomic.AddUint64(&n.screenedFrames, 1)
+		return msg, &screenedFrameError{err: errors.Errorf("network: legacy peer sent a %s", name)}
+	}
+
+	if !n.verifyLegacy(msg) {
+		atomic.AddUint64(&n.rejectedFrames, 1)
+		return msg, &malformedFrameError{err: errors.New("received legacy message had a malformed signature")}
+	}
+
+	return msg, nil
+}
+
+// writeLegacy writes a message out to a legacy peer in a legacy frame,
+// taking over counting the bytes the futures it carries were counted as
+// queued for. Legacy frames are neither padded, captured nor mirrored.
+func (n *Network) writeLegacy(address string, state *ConnState, message *protobuf.Message, futures ...*SendFuture) error {
+	if err := n.allowWrite(address); err != nil {
+		return err
+	}
+
+	legacy, err := n.legacyEnvelope(message)
+	if err != nil {
+		return err
+	}
+	legacy.MessageNonce = atomic.AddUint64(&state.messageNonce, 1)
+
+	body, err := proto.Marshal(legacy)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal message")
+	}
+
+	size := legacyHeaderSize + len(body)
+
+	// The message counts as queued until it makes it into the buffer.
+	state.flow.add(int64(size) - state.flow.release(futures...))
+
+	n.shape(state, message, size)
+
+	state.conn.SetWriteDeadline(time.Now().Add(n.writeTimeout(address, size)))
+
+	err = n.writeParts(state.writer, state.writerMutex, state.flow, n.frameCodec(true).header(len(body)), body, nil)
+	state.flow.add(-int64(size))
+	n.markWritten(address, err)
+	if err != nil {
+		return err
+	}
+
+	n.tailMessage(DirectionOutbound, address, legacy, size, true)
+
+	return nil
+}
+
+// replayConn is a connection whose reads return bytes already read off it
+// before any more are read.
+type replayConn struct {
+	net.Conn
+	replay []byte
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if len(c.replay) > 0 {
+		read := copy(b, c.replay)
+		c.replay = c.replay[read:]
+		return read, nil
+	}
+	return c.Conn.Read(b)
+}
+
+// acceptorFor returns the side of a handshake to run with a peer which dialed
+// us, alongside the connection to read from it over thereafter. With
+// AllowLegacyPeers, the first byte the peer sent tells legacy peers apart:
+// handshakes are prefixed with their size as a big-endian uint32 which never
+// reaches maxHandshakeSize, and so start with a zero byte, while legacy
+// frames start with a uvarint of their size, which is never zero.
+func (n *Network) acceptorFor(conn net.Conn) (net.Conn, func(conn net.Conn) (*handshakeResult, error)) {
+	if !n.opts.allowLegacyPeers {
+		return conn, n.handshakeAcceptor
+	}
+
+	peeked := &replayConn{Conn: conn, replay: make([]byte, 1)}
+
+	conn.SetReadDeadline(time.Now().Add(n.opts.handshakeTimeout))
+	if _, err := io.ReadFull(conn, peeked.replay); err != nil {
+		peeked.replay = nil
+	}
+
+	if len(peeked.replay) == 0 || peeked.replay[0] == 0 {
+		return peeked, n.handshakeAcceptor
+	}
+
+	return peeked, func(net.Conn) (*handshakeResult, error) {
+		return n.handshakeLegacyAcceptor(peeked)
+	}
+}
+
+// handshakeLegacyAcceptor identifies a legacy peer which dialed us by the
+// first message it sent, which is read off the connection again once the
+// peer is admitted, and dispatched as any other.
+func (n *Network) handshakeLegacyAcceptor(conn *replayConn) (*handshakeResult, error) {
+	header, size, err := frameCodec{legacy: true, maxSize: maxHandshakeSize}.readHeader(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, errors.Wrap(err, "failed to read frame")
+	}
+
+	msg, err := decodeEnvelope(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msg.Sender.Address) == 0 {
+		return nil, errors.New("received an invalid legacy message (no sender address)")
+	}
+
+	if !n.verifyLegacy(msg) {
+		return nil, errors.New("received legacy message had a malformed signature")
+	}
+
+	conn.replay = append(header, body...)
+
+	return legacyResult(msg.Sender, 0), nil
+}
+
+// probeLegacy probes a peer which hung up on our handshake with a ping in a
+// legacy frame, and waits for as long as a handshake may take for the peer
+// to connect back to us as legacy peers do.
+func (n *Network) probeLegacy(conn net.Conn, address string) (*handshakeResult, error) {
+	connected := n.legacy.await(address)
+	defer n.legacy.stopAwaiting(address, connected)
+
+	ping, err := n.PrepareMessage(&protobuf.Ping{})
+	if err != nil {
+		return nil, err
+	}
+
+	legacy, err := n.legacyEnvelope(ping)
+	if err != nil {
+		return nil, err
+	}
+	legacy.MessageNonce = 1
+
+	body, err := proto.Marshal(legacy)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal message")
+	}
+
+	if _, err := conn.Write(append(n.frameCodec(true).header(len(body)), body...)); err != nil {
+		return nil, errors.Wrap(err, "failed to write frame")
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(n.opts.handshakeTimeout):
+		return nil, errors.New("peer neither handshook nor connected back as a legacy peer")
+	case <-n.kill:
+		return nil, ErrNetworkClosed
+	}
+
+	remote := n.legacy.lookup(address)
+	if remote == nil {
+		return nil, errors.New("legacy peer disconnected before being dialed")
+	}
+
+	return legacyResult(remote, legacy.MessageNonce), nil
+}
+
+// legacyResult returns the result of a handshake with a legacy peer.
+func legacyResult(remote *protobuf.ID, sent uint64) *handshakeResult {
+	offer := &protobuf.HandshakeOffer{Versions: []string{LegacyProtocolVersion}}
+	return &handshakeResult{remote: remote, version: LegacyProtocolVersion, offer: offer, legacy: true, sent: sent}
+}
+
+// hungUp returns true if a connection failed for the peer hanging up.
+func hungUp(err error) bool {
+	cause := errors.Cause(err)
+	if cause == io.EOF || cause == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	if opErr, ok := cause.(*net.OpError); ok {
+		if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+			return sysErr.Err == syscall.ECONNRESET
+		}
+	}
+	return false
+}
+
+// legacyPeers remembers the legacy peers which connected to us by the address
+// they listen at, so that they are dialed back without a handshake, alongside
+// the dialers waiting on legacy peers to connect back to them.
+type legacyPeers struct {
+	sync.Mutex
+	remotes map[string]*protobuf.ID
+	waiters map[string][]chan struct{}
+}
+
+func (l *legacyPeers) learn(remote *protobuf.ID) {
+	address, err := ToUnifiedAddress(remote.Address)
+	if err != nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.remotes == nil {
+		l.remotes = make(map[string]*protobuf.ID)
+	}
+	l.remotes[address] = remote
+
+	for _, waiter := range l.waiters[address] {
+		close(waiter)
+	}
+	delete(l.waiters, address)
+}
+
+func (l *legacyPeers) lookup(address string) *protobuf.ID {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.remotes[address]
+}
+
+func (l *legacyPeers) forget(address string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.remotes, address)
+}
+
+// await returns a channel closed once the legacy peer at an address connects
+// to us.
+func (l *legacyPeers) await(address string) chan struct{} {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.waiters == nil {
+		l.waiters = make(map[string][]chan struct{})
+	}
+
+	waiter := make(chan struct{})
+	l.waiters[address] = append(l.waiters[address], waiter)
+	return waiter
+}
+
+func (l *legacyPeers) stopAwaiting(address string, waiter chan struct{}) {
+	l.Lock()
+	defer l.Unlock()
+
+	waiters := l.waiters[address]
+	for i, w := range waiters {
+		if w == waiter {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(l.waiters, address)
+	} else {
+		l.waiters[address] = waiters
+	}
+}
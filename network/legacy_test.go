@@ -0,0 +1,408 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// legacyPeer emulates an upstream noise node: it exchanges no handshake,
+// prefixes frames with their size as a uvarint zero-padded to 10 bytes, signs
+// messages over SerializeMessage, and answers pings with pongs over a
+// connection of its own to their sender.
+type legacyPeer struct {
+	keys     *crypto.KeyPair
+	id       protobuf.ID
+	listener net.Listener
+
+	sync.Mutex
+	outgoing map[string]net.Conn
+	nonces   map[string]uint64
+
+	received chan *protobuf.Message
+}
+
+func newLegacyPeer(t *testing.T, keys *crypto.KeyPair, address string) *legacyPeer {
+	return &legacyPeer{
+		keys:     keys,
+		id:       protobuf.ID(peer.CreateID(address, keys.PublicKey)),
+		outgoing: make(map[string]net.Conn),
+		nonces:   make(map[string]uint64),
+		received: make(chan *protobuf.Message, 64),
+	}
+}
+
+func listenLegacyPeer(t *testing.T) *legacyPeer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	p := newLegacyPeer(t, ed25519.RandomKeyPair(), FormatAddress("tcp", "127.0.0.1", uint16(listener.Addr().(*net.TCPAddr).Port)))
+	p.listener = listener
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+
+	return p
+}
+
+func (p *legacyPeer) Close() {
+	p.listener.Close()
+
+	p.Lock()
+	defer p.Unlock()
+	for _, conn := range p.outgoing {
+		conn.Close()
+	}
+}
+
+// frame returns a legacy frame holding a message signed by the peer.
+func (p *legacyPeer) frame(message proto.Message, nonce uint64) []byte {
+	payload, err := types.MarshalAny(message)
+	if err != nil {
+		panic(err)
+	}
+
+	msg := &protobuf.Message{Message: payload, Sender: &p.id, MessageNonce: nonce}
+	if msg.Signature, err = p.keys.Sign(ed25519.New(), blake2b.New(), SerializeMessage(msg.Sender, payload.Value)); err != nil {
+		panic(err)
+	}
+
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+
+	header := make([]byte, binary.MaxVarintLen64)
+	binary.PutUvarint(header, uint64(len(body)))
+	return append(header, body...)
+}
+
+func (p *legacyPeer) send(address string, message proto.Message) error {
+	p.Lock()
+	defer p.Unlock()
+
+	conn, exists := p.outgoing[address]
+	if !exists {
+		info, err := ParseAddress(address)
+		if err != nil {
+			return err
+		}
+		if conn, err = net.Dial("tcp", info.HostPort()); err != nil {
+			return err
+		}
+		p.outgoing[address] = conn
+	}
+
+	p.nonces[address]++
+	_, err := conn.Write(p.frame(message, p.nonces[address]))
+	return err
+}
+
+func (p *legacyPeer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readLegacyFrame(r)
+		if err != nil {
+			return
+		}
+		p.received <- msg
+
+		if name, _ := types.AnyMessageName(msg.Message); name == pingName {
+			p.send(msg.Sender.Address, &protobuf.Pong{})
+		}
+	}
+}
+
+// next returns the next message of a type the peer received.
+func (p *legacyPeer) next(t *testing.T, name string) *protobuf.Message {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case msg := <-p.received:
+			if received, _ := types.AnyMessageName(msg.Message); received == name {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("legacy peer never received a %s", name)
+			return nil
+		}
+	}
+}
+
+// readLegacyFrame reads a legacy frame, checking that its message is signed
+// over its legacy preimage and carries no fields legacy peers know nothing of.
+func readLegacyFrame(r io.Reader) (*protobuf.Message, error) {
+	header := make([]byte, binary.MaxVarintLen64)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size, read := binary.Uvarint(header)
+	if read <= 0 || size == 0 {
+		return nil, errors.New("frame has a broken header")
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	msg := new(protobuf.Message)
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	if hasForkFields(msg) {
+		return nil, errors.New("message carries fields unknown to legacy peers")
+	}
+	if !crypto.Verify(ed25519.New(), blake2b.New(), msg.Sender.PublicKey, SerializeMessage(msg.Sender, msg.Message.Value), msg.Signature) {
+		return nil, errors.New("message is not signed over its legacy preimage")
+	}
+
+	return msg, nil
+}
+
+type legacyFrame struct {
+	Name  string `json:"name"`
+	Frame string `json:"frame"`
+}
+
+func TestLegacyFramesGolden(t *testing.T) {
+	t.Parallel()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(bytes.NewReader(bytes.Repeat([]byte{1}, 32)))
+	assert.Nil(t, err)
+	keys := &crypto.KeyPair{PublicKey: publicKey, PrivateKey: privateKey}
+
+	const address = "tcp://127.0.0.1:3000"
+
+	messages := []struct {
+		name    string
+		message proto.Message
+	}{
+		{"ping", &protobuf.Ping{}},
+		{"application message", &testpb.TestMessage{Message: "hello"}},
+		{"bytes", &protobuf.Bytes{Data: []byte{0xde, 0xad, 0xbe, 0xef}}},
+	}
+
+	legacy := newLegacyPeer(t, keys, address)
+
+	var frames []legacyFrame
+	for i, m := range messages {
+		frames = append(frames, legacyFrame{Name: m.name, Frame: hex.EncodeToString(legacy.frame(m.message, uint64(i+1)))})
+	}
+
+	golden := filepath.Join("testdata", "legacy_frames.golden.json")
+	if *updateGolden {
+		encoded, err := json.MarshalIndent(frames, "", "  ")
+		assert.Nil(t, err)
+		assert.Nil(t, ioutil.WriteFile(golden, append(encoded, '\n'), 0644))
+	}
+
+	encoded, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+
+	var expected []legacyFrame
+	assert.Nil(t, json.Unmarshal(encoded, &expected))
+	assert.Equal(t, expected, frames)
+
+	var captured []byte
+	for _, frame := range expected {
+		raw, err := hex.DecodeString(frame.Frame)
+		assert.Nil(t, err)
+		captured = append(captured, raw...)
+	}
+
+	builder := NewBuilderWithOptions(AllowLegacyPeers(true))
+	builder.SetKeys(keys)
+	builder.SetAddress(address)
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	// Frames are written to legacy peers exactly as captured.
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	var written bytes.Buffer
+	state := &ConnState{conn: local, writer: bufio.NewWriter(&written), writerMutex: new(sync.Mutex), flow: new(sendFlow), bandwidth: node.newPeerBandwidth(publicKey), legacy: true}
+	for _, m := range messages {
+		signed, err := node.PrepareMessage(m.message)
+		assert.Nil(t, err)
+		assert.Nil(t, node.writeLegacy(address, state, signed))
+	}
+	assert.Nil(t, state.writer.Flush())
+	assert.Equal(t, hex.EncodeToString(captured), hex.EncodeToString(written.Bytes()))
+
+	// Frames as captured are read off legacy peers.
+	go remote.Write(captured)
+
+	for i, m := range messages {
+		msg, err := node.receiveMessage(local, publicKey, true)
+		if !assert.Nil(t, err, m.name) {
+			continue
+		}
+
+		var decoded types.DynamicAny
+		assert.Nil(t, types.UnmarshalAny(msg.Message.Message, &decoded))
+		assert.Equal(t, m.message, decoded.Message, m.name)
+		assert.Equal(t, uint64(i+1), msg.MessageNonce)
+		assert.Equal(t, len(expected[i].Frame)/2, len(msg.raw)+frameHeaderSize(msg.legacy))
+		msg.done()
+	}
+
+	// Legacy frames are no handshake.
+	_, err = readHandshakeFrame(captured)
+	assert.NotNil(t, err)
+}
+
+// readHandshakeFrame reads a handshake off the start of a stream.
+func readHandshakeFrame(stream []byte) (*protobuf.Handshake, error) {
+	msg := new(protobuf.Handshake)
+	return msg, readFrame(bytes.NewReader(stream), msg, maxHandshakeSize)
+}
+
+func TestLegacyMixedSessions(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(AllowLegacyPeers(true))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok && msg.Message != "reply" {
+			ctx.Reply(&testpb.TestMessage{Message: "reply"})
+		}
+	}})
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	go node.Listen()
+	<-node.Ready()
+
+	legacy := listenLegacyPeer(t)
+	defer legacy.Close()
+
+	replies := make(chan string, 4)
+	modern := buildClockedNode(t, &fakeClock{now: time.Now()}, func(ctx *PluginContext) {
+		replies <- ctx.Message().(*testpb.TestMessage).Message
+	})
+	defer modern.Close()
+
+	// Both peers connect at once, and are served side by side.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.Nil(t, legacy.send(node.Address, &testpb.TestMessage{Message: "legacy"}))
+	}()
+	go func() {
+		defer wg.Done()
+		client, err := modern.Client(node.Address)
+		if assert.Nil(t, err) {
+			assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "modern"}))
+		}
+	}()
+	wg.Wait()
+
+	waitForPeers(t, node, 2)
+
+	for _, info := range node.Peers() {
+		assert.Equal(t, info.Address == legacy.id.Address, info.LegacyPeer, info.Address)
+	}
+	for _, info := range modern.Peers() {
+		assert.False(t, info.LegacyPeer)
+	}
+
+	// Replies reach either peer in its own framing.
+	reply := legacy.next(t, proto.MessageName(&testpb.TestMessage{}))
+	assert.Equal(t, node.keys.PublicKey, reply.Sender.PublicKey)
+	assert.Equal(t, "reply", <-replies)
+
+	node.Broadcast(&testpb.TestMessage{Message: "broadcast"})
+	legacy.next(t, proto.MessageName(&testpb.TestMessage{}))
+	assert.Equal(t, "broadcast", <-replies)
+
+	// Legacy peers are sent none of the messages only this network knows of.
+	keepalive, err := node.PrepareMessage(&protobuf.Keepalive{})
+	assert.Nil(t, err)
+	assert.Equal(t, ErrLegacyUnsupported, errors.Cause(node.write(legacy.id.Address, keepalive)))
+
+	tagged, err := node.prepareMessage("chat", &testpb.TestMessage{})
+	assert.Nil(t, err)
+	assert.Equal(t, ErrLegacyUnsupported, errors.Cause(node.write(legacy.id.Address, tagged)))
+
+	signed, err := node.PrepareMessage(&protobuf.Ping{})
+	assert.Nil(t, err)
+	assert.Nil(t, node.write(legacy.id.Address, signed))
+	legacy.next(t, pingName)
+}
+
+func TestLegacyPeerProbed(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, AllowLegacyPeers(true))
+	defer node.Close()
+
+	legacy := listenLegacyPeer(t)
+	defer legacy.Close()
+
+	// The legacy peer hangs up on our handshake, and connects back once
+	// probed with a ping.
+	client, err := node.Client(legacy.id.Address)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, client.Info().LegacyPeer)
+	legacy.next(t, pingName)
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	msg := legacy.next(t, proto.MessageName(&testpb.TestMessage{}))
+	assert.Equal(t, uint64(2), msg.MessageNonce)
+}
+
+func TestLegacyPeersDisallowed(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	legacy := listenLegacyPeer(t)
+	defer legacy.Close()
+
+	assert.Nil(t, legacy.send(node.Address, &testpb.TestMessage{Message: "legacy"}))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return node.HandshakeStats().Failures == 1 }))
+	assert.Empty(t, node.Peers())
+
+	_, err := node.Client(legacy.id.Address)
+	assert.NotNil(t, err)
+}
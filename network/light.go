@@ -0,0 +1,11 @@
+package network
+
+// ClientCapability is advertised by light nodes, which take no part in
+// routing: full nodes neither add them to their routing tables nor route
+// messages through them.
+const ClientCapability = "noise/client"
+
+// IsLight returns true should the node run in light mode.
+func (n *Network) IsLight() bool {
+	return n.opts.light
+}
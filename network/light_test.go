@@ -0,0 +1,133 @@
+package network
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// trackingExecutor runs every task on a goroutine of its own, remembering
+// which goroutines run tasks yet to finish.
+type trackingExecutor struct {
+	sync.Mutex
+	running map[int64]struct{}
+}
+
+func (e *trackingExecutor) Go(task func()) {
+	go func() {
+		id := goroutineID()
+
+		e.Lock()
+		e.running[id] = struct{}{}
+		e.Unlock()
+
+		defer func() {
+			e.Lock()
+			delete(e.running, id)
+			e.Unlock()
+		}()
+
+		task()
+	}()
+}
+
+func (e *trackingExecutor) Yield() {}
+
+// stacks returns the stack traces of all goroutines running tasks.
+func (e *trackingExecutor) stacks() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	e.Lock()
+	defer e.Unlock()
+
+	var stacks []string
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		var id int64
+		fmt.Sscanf(stack, "goroutine %d", &id)
+		if _, running := e.running[id]; running {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks
+}
+
+// isPeerLoop returns true should a stack trace be that of a per-peer loop.
+func isPeerLoop(stack string) bool {
+	for _, loop := range []string{".(*Network).Accept(", ".(*Network).sendLoop(", ".(*PeerClient).executeJobs("} {
+		if strings.Contains(stack, loop) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLightMode(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+	full, sender, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		msg, ok := ctx.Message().(*testpb.TestMessage)
+		if !ok {
+			return
+		}
+		if msg.Message == "request" {
+			ctx.Reply(&testpb.TestMessage{Message: "reply to " + msg.Message})
+			return
+		}
+		received <- msg.Message
+	})
+	defer full.Close()
+	defer sender.Close()
+
+	// Frames are flushed out as they are written, rather than by a flusher.
+	executor := &trackingExecutor{running: make(map[int64]struct{})}
+	light := buildListeningNode(t, LightMode(), TaskExecutor(executor), WriteFlushLatency(time.Hour))
+	defer light.Close()
+
+	client, err := light.Client(full.Address)
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "first"}))
+	select {
+	case message := <-received:
+		assert.Equal(t, "first", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was not flushed out")
+	}
+
+	reply, err := client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: "request"}, Timeout: 3 * time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, "reply to request", reply.(*testpb.TestMessage).Message)
+
+	// Full nodes see light nodes as clients taking no part in routing.
+	peer, err := full.Client(light.Address)
+	assert.Nil(t, err)
+	assert.True(t, peer.HasCapability(ClientCapability))
+	assert.False(t, peer.HasCapability(RoutingCapability))
+	assert.True(t, light.IsLight())
+	assert.False(t, full.IsLight())
+
+	// Nothing runs in the background other than the loops of the peer.
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		for _, stack := range executor.stacks() {
+			if !isPeerLoop(stack) {
+				return false
+			}
+		}
+		return true
+	}))
+	for _, stack := range executor.stacks() {
+		assert.True(t, isPeerLoop(stack), "unexpected background task:\n%s", stack)
+	}
+
+	_, err = NewBuilderWithOptions(LightMode(), ReapUnresponsive(time.Second, 3, 0)).Build()
+	assert.NotNil(t, err)
+}
@@ -1,44 +1,254 @@
 package network
 
 import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
 	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// Names of the messages the network handles itself.
+var (
+	batchName        = proto.MessageName((*protobuf.Batch)(nil))
+	bytesName        = proto.MessageName((*protobuf.Bytes)(nil))
+	keepaliveName    = proto.MessageName((*protobuf.Keepalive)(nil))
+	keepaliveAckName = proto.MessageName((*protobuf.KeepaliveAck)(nil))
+	pingName         = proto.MessageName((*protobuf.Ping)(nil))
 )
 
+// payloadName returns the name of the message type a payload holds, erroring
+// should the type not be registered.
+func payloadName(payload *types.Any) (string, error) {
+	name, err := types.AnyMessageName(payload)
+	if err != nil {
+		return "", err
+	}
+	if proto.MessageType(name) == nil {
+		return "", errors.Errorf("network: received a message of unknown type %s", name)
+	}
+	return name, nil
+}
+
 // PluginContext provides parameters and helper functions to a Plugin
 // for interacting with/analyzing incoming messages from a select peer.
 type PluginContext struct {
-	client  *PeerClient
-	message proto.Message
-	nonce   uint64
+	client *PeerClient
+	nonce  uint64
+	origin peer.ID
+	frame  *receivedMessage
+
+	// The protocol tag the message was sent under.
+	protocol string
+
+	// The key the message was routed toward and how far it went, if routed.
+	routed *protobuf.Routed
+
+	// When the requester stops waiting for a reply, if ever, alongside the
+	// context handlers run under, created on demand.
+	deadline   time.Time
+	handlerCtx context.Context
+	cancel     context.CancelFunc
+
+	// The payload is only decoded once a plugin asks for it.
+	name      string
+	payload   *types.Any
+	decode    sync.Once
+	message   proto.Message
+	decodeErr error
+
+	own ownership
+}
+
+// reset readies a pooled context for a new payload.
+func (ctx *PluginContext) reset(name string, payload *types.Any) {
+	ctx.deadline = time.Time{}
+	ctx.routed = nil
+	ctx.handlerCtx, ctx.cancel = nil, nil
+	ctx.name = name
+	ctx.payload = payload
+	ctx.decode = sync.Once{}
+	ctx.message = nil
+	ctx.decodeErr = nil
+}
+
+// decoded decodes the payload on first call, caching the message for all
+// plugins handling it.
+func (ctx *PluginContext) decoded() (proto.Message, error) {
+	ctx.decode.Do(func() {
+		var ptr types.DynamicAny
+		if ctx.decodeErr = types.UnmarshalAny(ctx.payload, &ptr); ctx.decodeErr == nil {
+			ctx.message = ptr.Message
+		}
+	})
+	return ctx.message, ctx.decodeErr
 }
 
-// Reply sends back a message to an incoming message's incoming stream.
+// Reply sends back a message to an incoming message's incoming stream. Replies
+// to requests whose deadline passed are skipped, and counted in DeadlineStats.
 func (ctx *PluginContext) Reply(message proto.Message) error {
-	return ctx.client.Reply(ctx.nonce, message)
+	ctx.own.check()
+	if ctx.Network().expired(ctx.deadline) {
+		return nil
+	}
+	return ctx.client.reply(ctx.protocol, ctx.nonce, message)
 }
 
-// Message returns the decoded protobuf message.
+// RequestID returns the ID of the request the message is, through which it may
+// be answered with Network.Reply once the handler returned. The nonce of the
+// ID is zero should the message not be a request.
+func (ctx *PluginContext) RequestID() RequestID {
+	ctx.own.check()
+	return RequestID{Address: ctx.client.Address, Nonce: ctx.nonce, Protocol: ctx.protocol, deadline: ctx.deadline}
+}
+
+// Protocol returns the protocol tag the message was sent under, replies being
+// sent under the same tag. The default protocol's tag is empty.
+func (ctx *PluginContext) Protocol() string {
+	ctx.own.check()
+	return ctx.protocol
+}
+
+// Message returns the decoded protobuf message, decoding it should no plugin
+// have done so yet. The message is shared by all plugins and must not be
+// modified. Returns nil should the payload fail to decode.
 func (ctx *PluginContext) Message() proto.Message {
-	return ctx.message
+	ctx.own.check()
+	message, err := ctx.decoded()
+	if err != nil {
+		glog.Errorf("network: failed to decode %s from %s: %v", ctx.name, ctx.client.Address, err)
+	}
+	return message
+}
+
+// Decode sets into to the decoded protobuf message, decoding it should no
+// plugin have done so yet. into must be of the message's type, and shares its
+// fields with the message handed to all other plugins, so must not be
+// modified.
+func (ctx *PluginContext) Decode(into proto.Message) error {
+	ctx.own.check()
+	message, err := ctx.decoded()
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode %s", ctx.name)
+	}
+
+	if reflect.TypeOf(into) != reflect.TypeOf(message) {
+		return errors.Errorf("network: cannot decode %s into %T", ctx.name, into)
+	}
+
+	if into != message {
+		reflect.ValueOf(into).Elem().Set(reflect.ValueOf(message).Elem())
+	}
+	return nil
+}
+
+// MessageName returns the fully-qualified protobuf name of the message's type,
+// without decoding it.
+func (ctx *PluginContext) MessageName() string {
+	ctx.own.check()
+	return ctx.name
+}
+
+// Payload returns the message's serialized bytes, as covered by the sender's
+// signature. The returned slice must not be modified.
+func (ctx *PluginContext) Payload() []byte {
+	ctx.own.check()
+	return ctx.payload.Value
+}
+
+// Envelope returns the signed envelope the message arrived in, which for
+// messages received within a batch is the envelope of the whole batch. It must
+// not be modified.
+func (ctx *PluginContext) Envelope() *Envelope {
+	ctx.own.check()
+	return ctx.frame.Message
 }
 
 // Client returns the peer client.
 func (ctx *PluginContext) Client() *PeerClient {
+	ctx.own.check()
 	return ctx.client
 }
 
 // Network returns the entire node's network.
 func (ctx *PluginContext) Network() *Network {
+	ctx.own.check()
 	return ctx.client.Network
 }
 
 // Self returns the node's ID.
 func (ctx *PluginContext) Self() peer.ID {
+	ctx.own.check()
 	return ctx.Network().ID
 }
 
+// Origin returns the ID of the peer the message arrived from. Rebroadcasts
+// of the message should skip it.
+func (ctx *PluginContext) Origin() peer.ID {
+	ctx.own.check()
+	return ctx.origin
+}
+
 // Sender returns the peer's ID.
 func (ctx *PluginContext) Sender() peer.ID {
-	return *ctx.client.ID
+	ctx.own.check()
+	return *ctx.client.id()
+}
+
+// RawFrame returns the signed message exactly as it was received off the
+// wire, excluding its length prefix. The returned slice is shared by all
+// plugins and must not be modified, though it stays valid after the callback
+// returns as the network never reuses it. Use CopyRawFrame to get a private
+// copy. Messages received within a batch share the frame and signature of
+// the whole batch.
+func (ctx *PluginContext) RawFrame() []byte {
+	ctx.own.check()
+	return ctx.frame.raw
+}
+
+// CopyRawFrame returns a private copy of the signed message exactly as it was
+// received off the wire.
+func (ctx *PluginContext) CopyRawFrame() []byte {
+	ctx.own.check()
+	raw := make([]byte, len(ctx.frame.raw))
+	copy(raw, ctx.frame.raw)
+	return raw
+}
+
+// Signature returns the senders signature over the message.
+func (ctx *PluginContext) Signature() []byte {
+	ctx.own.check()
+	return ctx.frame.Signature
+}
+
+// SignerPublicKey returns the public key the message's signature was verified against.
+func (ctx *PluginContext) SignerPublicKey() []byte {
+	ctx.own.check()
+	return ctx.frame.Sender.PublicKey
+}
+
+// ReceivedAt returns the time the message was fully read off the wire.
+func (ctx *PluginContext) ReceivedAt() time.Time {
+	ctx.own.check()
+	return ctx.frame.receivedAt
+}
+
+// WireSize returns the number of bytes the message occupied on the wire,
+// including its length prefix.
+func (ctx *PluginContext) WireSize() int {
+	ctx.own.check()
+	return len(ctx.frame.raw) + frameHeaderSize(ctx.frame.legacy)
+}
+
+// Metadata returns the signed key/value pairs the sender attached to the
+// message through its outbound hooks.
+func (ctx *PluginContext) Metadata() map[string]string {
+	ctx.own.check()
+	return ctx.frame.Metadata
 }
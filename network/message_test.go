@@ -0,0 +1,232 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedFrame struct {
+	raw        []byte
+	signature  []byte
+	publicKey  []byte
+	receivedAt time.Time
+	wireSize   int
+}
+
+// framePlugin stores the raw frames of all received test messages.
+type framePlugin struct {
+	*Plugin
+	frames chan capturedFrame
+}
+
+func (p *framePlugin) Receive(ctx *PluginContext) error {
+	if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+		p.frames <- capturedFrame{
+			raw:        ctx.RawFrame(),
+			signature:  ctx.Signature(),
+			publicKey:  ctx.SignerPublicKey(),
+			receivedAt: ctx.ReceivedAt(),
+			wireSize:   ctx.WireSize(),
+		}
+	}
+	return nil
+}
+
+func TestRawFrameReverifies(t *testing.T) {
+	t.Parallel()
+
+	plugin := &framePlugin{frames: make(chan capturedFrame, 1)}
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(plugin)
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	before := time.Now()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "audit me"}))
+
+	var frame capturedFrame
+	select {
+	case frame = <-plugin.frames:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	assert.Equal(t, len(frame.raw)+4, frame.wireSize)
+	assert.True(t, !frame.receivedAt.Before(before))
+	assert.Equal(t, sender.GetKeys().PublicKey, frame.publicKey)
+
+	// Decode the stored bytes from scratch and verify the signature independently.
+	msg := new(protobuf.Message)
+	assert.Nil(t, proto.Unmarshal(frame.raw, msg))
+	assert.Equal(t, frame.signature, msg.Signature)
+
+	assert.True(t, crypto.Verify(
+		ed25519.New(),
+		blake2b.New(),
+		msg.Sender.PublicKey,
+		SerializeMessage(msg.Sender, msg.Message.Value),
+		msg.Signature,
+	), "stored raw frame should carry a valid signature")
+
+	// Re-encoding the decoded message should reproduce the frame byte for byte.
+	reencoded, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, frame.raw, reencoded)
+}
+
+// decodePlugin hands over the messages it decodes.
+type decodePlugin struct {
+	*Plugin
+	decoded chan decodedMessage
+}
+
+type decodedMessage struct {
+	message proto.Message
+	into    *testpb.TestMessage
+	err     error
+}
+
+func (p *decodePlugin) Receive(ctx *PluginContext) error {
+	into := new(testpb.TestMessage)
+	err := ctx.Decode(into)
+	p.decoded <- decodedMessage{message: ctx.Message(), into: into, err: err}
+	return nil
+}
+
+// secondDecodePlugin is a decodePlugin registered as a plugin of its own.
+type secondDecodePlugin struct {
+	decodePlugin
+}
+
+func TestDecodeCachesMessage(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan decodedMessage, 2)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		assert.Equal(t, proto.MessageName(&testpb.TestMessage{}), ctx.MessageName())
+		assert.NotEmpty(t, ctx.Payload())
+		assert.Equal(t, ctx.Payload(), ctx.Envelope().Message.Value)
+		assert.NotNil(t, ctx.Decode(new(protobuf.Ping)), "messages should not decode into another type")
+	}})
+	builder.AddPlugin(&decodePlugin{decoded: results})
+	builder.AddPlugin(&secondDecodePlugin{decodePlugin{decoded: results}})
+
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "cached"}))
+
+	var first, second decodedMessage
+	for _, result := range []*decodedMessage{&first, &second} {
+		select {
+		case *result = <-results:
+		case <-time.After(3 * time.Second):
+			t.Fatal("message was not handled")
+		}
+	}
+
+	assert.Nil(t, first.err)
+	assert.Nil(t, second.err)
+	assert.True(t, first.message == second.message, "handlers should share the cached message")
+	assert.Equal(t, "cached", first.into.Message)
+	assert.Equal(t, first.into, second.into)
+}
+
+// accountingPlugin tallies messages by type without decoding them.
+type accountingPlugin struct {
+	*Plugin
+	decode bool
+	bytes  map[string]int
+}
+
+func (p *accountingPlugin) Receive(ctx *PluginContext) error {
+	if p.decode {
+		ctx.Message()
+	}
+	p.bytes[ctx.MessageName()] += ctx.WireSize()
+	return nil
+}
+
+func benchmarkAccounting(b *testing.B, decode bool) {
+	plugin := &accountingPlugin{decode: decode, bytes: make(map[string]int)}
+
+	builder := NewBuilder()
+	builder.AddPlugin(plugin)
+
+	node, err := builder.Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	client, err := createPeerClient(node, "tcp://localhost:3000")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	response := &protobuf.LookupNodeResponse{}
+	for i := 0; i < 64; i++ {
+		response.Peers = append(response.Peers, &protobuf.ID{
+			PublicKey: make([]byte, 32),
+			Address:   fmt.Sprintf("tcp://10.0.0.%d:3000", i),
+			Id:        make([]byte, 32),
+		})
+	}
+
+	payload, err := types.MarshalAny(response)
+	if err != nil {
+		b.Fatal(err)
+	}
+	name := proto.MessageName(response)
+
+	ctx := &PluginContext{client: client, frame: &receivedMessage{raw: make([]byte, payload.Size())}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx.reset(name, payload)
+		node.handleMessage(ctx, name)
+	}
+}
+
+func BenchmarkAccountingLazy(b *testing.B) {
+	benchmarkAccounting(b, false)
+}
+
+func BenchmarkAccountingDecoded(b *testing.B) {
+	benchmarkAccounting(b, true)
+}
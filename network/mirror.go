@@ -0,0 +1,327 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// defaultMirrorQueueSize is the number of frames which may be queued up to be
+// mirrored before further ones are dropped.
+const defaultMirrorQueueSize = 1024
+
+// ErrMirrorNoSink is returned should a mirror rule be added without a sink.
+var ErrMirrorNoSink = errors.New("network: mirror rule has no sink")
+
+// MirroredFrame is a single frame sent or received by a node, mirrored to a
+// sink. Frame holds a copy of the signed message exactly as written to the
+// wire, so that its signature may be verified anew.
+type MirroredFrame struct {
+	CapturedFrame
+
+	// Type is the fully-qualified protobuf name of the message.
+	Type string
+}
+
+// MirrorSink receives the frames matching a mirror rule. Frames are handed to
+// sinks one at a time, off the data path.
+type MirrorSink interface {
+	Mirror(frame *MirroredFrame) error
+}
+
+// MirrorRule selects which frames are mirrored to a sink. Empty fields match
+// everything.
+type MirrorRule struct {
+	// Types limits the rule to messages of the given fully-qualified protobuf names.
+	Types []string
+	// Peer limits the rule to frames exchanged with peers it returns true for.
+	Peer func(address string, publicKey []byte) bool
+	// Directions limits the rule to either sent or received frames.
+	Directions []ConnDirection
+
+	// Sink is handed every frame matching the rule.
+	Sink MirrorSink
+}
+
+func (r *MirrorRule) matches(direction ConnDirection, typ string, address string, key []byte) bool {
+	if len(r.Directions) > 0 {
+		found := false
+		for _, d := range r.Directions {
+			if d == direction {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.Types) > 0 && !containsString(r.Types, typ) {
+		return false
+	}
+
+	return r.Peer == nil || r.Peer(address, key)
+}
+
+// MirrorStats counts frames mirrored to sinks. A frame matching the rules of
+// several sinks counts once per sink.
+type MirrorStats struct {
+	// Rules is the number of mirror rules registered.
+	Rules int
+	// Queued is the number of frames waiting to be mirrored.
+	Queued int
+	// Mirrored is the number of frames handed to sinks.
+	Mirrored uint64
+	// Dropped is the number of frames not mirrored for the queue being full.
+	Dropped uint64
+	// Failed is the number of frames sinks failed to mirror.
+	Failed uint64
+}
+
+// mirroredFrame is a frame queued up to be mirrored to sinks.
+type mirroredFrame struct {
+	frame *MirroredFrame
+	sinks []MirrorSink
+}
+
+// mirrors holds the mirror rules registered, and the queue of frames waiting
+// to be mirrored, drained by a single goroutine spawned once the first rule is
+// added.
+type mirrors struct {
+	sync.RWMutex
+	rules  map[uint64]*MirrorRule
+	nextID uint64
+
+	count int32 // for atomic ops
+
+	queue chan mirroredFrame
+	start sync.Once
+
+	mirrored uint64 // for atomic ops
+	dropped  uint64 // for atomic ops
+	failed   uint64 // for atomic ops
+}
+
+// AddMirrorRule registers a rule mirroring matching frames sent and received
+// to the rule's sink, returning an ID to remove the rule by. Frames are
+// mirrored asynchronously, being dropped should the sink fall behind.
+func (n *Network) AddMirrorRule(rule MirrorRule) (uint64, error) {
+	if rule.Sink == nil {
+		return 0, ErrMirrorNoSink
+	}
+
+	n.mirrors.start.Do(func() {
+		n.spawnIn(SubsystemNetwork, n.mirrorLoop)
+	})
+
+	n.mirrors.Lock()
+	defer n.mirrors.Unlock()
+
+	if n.mirrors.rules == nil {
+		n.mirrors.rules = make(map[uint64]*MirrorRule)
+	}
+
+	n.mirrors.nextID++
+	n.mirrors.rules[n.mirrors.nextID] = &rule
+	atomic.AddInt32(&n.mirrors.count, 1)
+
+	return n.mirrors.nextID, nil
+}
+
+// RemoveMirrorRule removes a mirror rule by its ID, returning false should no
+// such rule exist. Frames already queued up are still mirrored.
+func (n *Network) RemoveMirrorRule(id uint64) bool {
+	n.mirrors.Lock()
+	defer n.mirrors.Unlock()
+
+	if _, exists := n.mirrors.rules[id]; !exists {
+		return false
+	}
+
+	delete(n.mirrors.rules, id)
+	atomic.AddInt32(&n.mirrors.count, -1)
+
+	return true
+}
+
+// MirrorStats returns the number of frames mirrored so far.
+func (n *Network) MirrorStats() MirrorStats {
+	n.mirrors.RLock()
+	defer n.mirrors.RUnlock()
+
+	return MirrorStats{
+		Rules:    len(n.mirrors.rules),
+		Queued:   len(n.mirrors.queue),
+		Mirrored: atomic.LoadUint64(&n.mirrors.mirrored),
+		Dropped:  atomic.LoadUint64(&n.mirrors.dropped),
+		Failed:   atomic.LoadUint64(&n.mirrors.failed),
+	}
+}
+
+// mirrorFrame queues up a frame sent or received, made up of parts, to be
+// mirrored to the sinks of all rules it matches. The frame is only copied
+// should it match any.
+func (n *Network) mirrorFrame(direction ConnDirection, peer string, key []byte, msg *protobuf.Message, verified bool, at time.Time, parts ...[]byte) {
+	if atomic.LoadInt32(&n.mirrors.count) == 0 {
+		return
+	}
+
+	var typ string
+	if msg.Message != nil {
+		typ, _ = types.AnyMessageName(msg.Message)
+	}
+
+	var sinks []MirrorSink
+
+	n.mirrors.RLock()
+	for _, rule := range n.mirrors.rules {
+		if rule.matches(direction, typ, peer, key) {
+			sinks = append(sinks, rule.Sink)
+		}
+	}
+	n.mirrors.RUnlock()
+
+	if len(sinks) == 0 {
+		return
+	}
+
+	frame := &MirroredFrame{
+		CapturedFrame: CapturedFrame{
+			Direction: direction,
+			Peer:      peer,
+			PeerKey:   append([]byte(nil), key...),
+			Time:      at,
+			Verified:  verified,
+			Frame:     bytes.Join(parts, nil),
+		},
+		Type: typ,
+	}
+
+	select {
+	case n.mirrors.queue <- mirroredFrame{frame: frame, sinks: sinks}:
+	default:
+		atomic.AddUint64(&n.mirrors.dropped, uint64(len(sinks)))
+	}
+}
+
+// mirrorOutbound queues up a frame sent to a peer to be mirrored, looking up
+// the peer's public key only should any rules be registered.
+func (n *Network) mirrorOutbound(address string, msg *protobuf.Message, parts ...[]byte) {
+	if atomic.LoadInt32(&n.mirrors.count) == 0 {
+		return
+	}
+
+	var key []byte
+	if client, ok := n.peers.Load(address); ok {
+		if id := client.(*PeerClient).id(); id != nil {
+			key = id.PublicKey
+		}
+	}
+
+	n.mirrorFrame(DirectionOutbound, address, key, msg, true, time.Now(), parts...)
+}
+
+// mirrorLoop hands queued up frames to their sinks until the network is
+// closed.
+func (n *Network) mirrorLoop() {
+	for {
+		select {
+		case <-n.kill:
+			return
+		case queued := <-n.mirrors.queue:
+			for _, sink := range queued.sinks {
+				if err := sink.Mirror(queued.frame); err != nil {
+					atomic.AddUint64(&n.mirrors.failed, 1)
+					continue
+				}
+				atomic.AddUint64(&n.mirrors.mirrored, 1)
+			}
+		}
+	}
+}
+
+// MirrorRing is a MirrorSink keeping the latest frames mirrored in memory.
+type MirrorRing struct {
+	mu     sync.Mutex
+	frames []*MirroredFrame
+	next   int
+	full   bool
+}
+
+// NewMirrorRing returns a MirrorSink keeping the latest size frames mirrored.
+func NewMirrorRing(size int) *MirrorRing {
+	if size <= 0 {
+		size = 1
+	}
+	return &MirrorRing{frames: make([]*MirroredFrame, size)}
+}
+
+// Mirror keeps a frame, evicting the oldest one kept should the ring be full.
+func (r *MirrorRing) Mirror(frame *MirroredFrame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.frames[r.next] = frame
+	r.next = (r.next + 1) % len(r.frames)
+	if r.next == 0 {
+		r.full = true
+	}
+
+	return nil
+}
+
+// Frames returns the frames kept, oldest first.
+func (r *MirrorRing) Frames() []*MirroredFrame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]*MirroredFrame(nil), r.frames[:r.next]...)
+	}
+	return append(append([]*MirroredFrame(nil), r.frames[r.next:]...), r.frames[:r.next]...)
+}
+
+// MirrorLog is a MirrorSink appending frames mirrored to a writer, in the same
+// format as captures, such that the log may be read back with
+// NewCaptureReader. The type of messages is not recorded, as it may be
+// decoded from their frames.
+type MirrorLog struct {
+	mu      sync.Mutex
+	capture *capture
+	begun   bool
+}
+
+// NewMirrorLog returns a MirrorSink appending frames mirrored to w.
+func NewMirrorLog(w io.Writer) *MirrorLog {
+	return &MirrorLog{capture: &capture{w: bufio.NewWriter(w)}}
+}
+
+// Mirror appends a frame to the log, flushing it out to the writer. Once the
+// writer fails, all further frames fail to be mirrored.
+func (l *MirrorLog) Mirror(frame *MirroredFrame) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.begun {
+		l.capture.writeMagic()
+		l.begun = true
+	}
+
+	if _, err := l.capture.record(frame.Direction, frame.Peer, frame.PeerKey, frame.Frame, frame.Verified, frame.Time); err != nil {
+		return err
+	}
+
+	if l.capture.err = l.capture.w.Flush(); l.capture.err != nil {
+		return errors.Wrap(l.capture.err, "failed to flush mirror log")
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSink is a MirrorSink taking its time to mirror every frame, until
+// released.
+type blockingSink struct {
+	mirroring chan struct{}
+	release   chan struct{}
+}
+
+func (s *blockingSink) Mirror(frame *MirroredFrame) error {
+	s.mirroring <- struct{}{}
+	<-s.release
+	return nil
+}
+
+// mirroredTypes returns the type and direction of every frame a ring kept.
+func mirroredTypes(ring *MirrorRing) []string {
+	var kept []string
+	for _, frame := range ring.Frames() {
+		kept = append(kept, frame.Direction.String()+" "+frame.Type)
+	}
+	return kept
+}
+
+func TestMirrorRulesMatchDirectionAndType(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+	peer := buildListeningNode(t)
+	defer peer.Close()
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	testType := proto.MessageName(&testpb.TestMessage{})
+	bytesType := proto.MessageName(&protobuf.Bytes{})
+
+	sent, received := NewMirrorRing(16), NewMirrorRing(16)
+
+	_, err := node.AddMirrorRule(MirrorRule{Types: []string{testType}, Directions: []ConnDirection{DirectionOutbound}, Sink: sent})
+	assert.Nil(t, err)
+	receivedID, err := node.AddMirrorRule(MirrorRule{
+		Types:      []string{testType, bytesType},
+		Directions: []ConnDirection{DirectionInbound},
+		Peer:       func(address string, publicKey []byte) bool { return address == peer.Address },
+		Sink:       received,
+	})
+	assert.Nil(t, err)
+
+	_, err = node.AddMirrorRule(MirrorRule{})
+	assert.Equal(t, ErrMirrorNoSink, err)
+
+	connectPeers(t, node, peer, other)
+
+	// The hellos sent to either peer match the rule for sent frames.
+	assert.True(t, waitUntil(3*time.Second, func() bool { return len(sent.Frames()) == 2 }), "kept %v", mirroredTypes(sent))
+
+	assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "sent")))
+
+	bytesMessage, err := node.prepareMessage("", &protobuf.Bytes{Data: []byte("sent")})
+	assert.Nil(t, err)
+	assert.Nil(t, node.Write(peer.Address, bytesMessage))
+
+	assert.Nil(t, peer.Write(node.Address, signedTestMessage(t, peer, "received")))
+	bytesMessage, err = peer.prepareMessage("", &protobuf.Bytes{Data: []byte("received")})
+	assert.Nil(t, err)
+	assert.Nil(t, peer.Write(node.Address, bytesMessage))
+
+	// Only frames received from the peer match the rule for received frames.
+	assert.Nil(t, other.Write(node.Address, signedTestMessage(t, other, "ignored")))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return len(sent.Frames()) == 3 && len(received.Frames()) == 2
+	}), "sent %v, received %v", mirroredTypes(sent), mirroredTypes(received))
+
+	assert.Equal(t, []string{"outbound " + testType, "outbound " + testType, "outbound " + testType}, mirroredTypes(sent))
+	assert.Equal(t, []string{"inbound " + testType, "inbound " + bytesType}, mirroredTypes(received))
+
+	for _, frame := range received.Frames() {
+		assert.Equal(t, peer.Address, frame.Peer)
+		assert.Equal(t, peer.keys.PublicKey, frame.PeerKey)
+		assert.True(t, frame.Verified)
+	}
+
+	// Rules may be removed at runtime.
+	assert.True(t, node.RemoveMirrorRule(receivedID))
+	assert.False(t, node.RemoveMirrorRule(receivedID))
+
+	assert.Nil(t, peer.Write(node.Address, signedTestMessage(t, peer, "unmirrored")))
+	assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "mirrored")))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return len(sent.Frames()) == 4 }))
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, received.Frames(), 2)
+
+	stats := node.MirrorStats()
+	assert.Equal(t, 1, stats.Rules)
+	assert.Equal(t, uint64(6), stats.Mirrored)
+	assert.Zero(t, stats.Dropped)
+}
+
+func TestMirrorDropsFramesUnderSlowSink(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, MirrorQueueSize(1))
+	defer node.Close()
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	connectPeers(t, node, peer)
+
+	sink := &blockingSink{mirroring: make(chan struct{}, 8), release: make(chan struct{})}
+	_, err := node.AddMirrorRule(MirrorRule{Directions: []ConnDirection{DirectionOutbound}, Sink: sink})
+	assert.Nil(t, err)
+
+	// The first frame holds up the sink, the second one fills up the queue,
+	// and the rest are dropped without holding up writes.
+	assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "first")))
+
+	select {
+	case <-sink.mirroring:
+	case <-time.After(3 * time.Second):
+		t.Fatal("first frame was never mirrored")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 4; i++ {
+			assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "slow")))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("writes were held up by the slow sink")
+	}
+
+	assert.Equal(t, MirrorStats{Rules: 1, Queued: 1, Dropped: 3}, node.MirrorStats())
+
+	close(sink.release)
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return node.MirrorStats() == MirrorStats{Rules: 1, Mirrored: 2, Dropped: 3}
+	}), "stats %+v", node.MirrorStats())
+}
+
+func TestMirroredFramesVerify(t *testing.T) {
+	t.Parallel()
+
+	// Frames sent are padded, which mirrored frames keep byte for byte.
+	node := buildListeningNode(t, PaddingBuckets(256))
+	defer node.Close()
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	var log bytes.Buffer
+	_, err := node.AddMirrorRule(MirrorRule{Types: []string{proto.MessageName(&testpb.TestMessage{})}, Sink: NewMirrorLog(&log)})
+	assert.Nil(t, err)
+
+	connectPeers(t, node, peer)
+	assert.Nil(t, peer.Write(node.Address, signedTestMessage(t, peer, "reply")))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return node.MirrorStats().Mirrored == 2 }), "stats %+v", node.MirrorStats())
+
+	reader, err := NewCaptureReader(bytes.NewReader(log.Bytes()))
+	assert.Nil(t, err)
+
+	// An auditor holding nothing but the log verifies every frame mirrored.
+	auditor := NewBuilder()
+	auditor.SetKeys(ed25519.RandomKeyPair())
+	verifier, err := auditor.Build()
+	assert.Nil(t, err)
+	defer verifier.Close()
+
+	senders := map[ConnDirection]*Network{DirectionOutbound: node, DirectionInbound: peer}
+
+	for _, direction := range []ConnDirection{DirectionOutbound, DirectionInbound} {
+		frame, err := reader.Next()
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, direction, frame.Direction)
+		assert.Equal(t, peer.Address, frame.Peer)
+
+		if direction == DirectionOutbound {
+			assert.Equal(t, 252, len(frame.Frame), "padded frame was not mirrored as written")
+		}
+
+		msg, verified, err := verifier.decodeMessage(frame.Frame)
+		assert.Nil(t, err)
+		assert.True(t, verified)
+		assert.Equal(t, senders[direction].keys.PublicKey, msg.Sender.PublicKey)
+
+		// Tampering with the payload fails verification.
+		tampered := append([]byte(nil), frame.Frame...)
+		tampered[bytes.Index(tampered, msg.Message.Value)+len(msg.Message.Value)-1] ^= 0xff
+		if _, verified, err := verifier.decodeMessage(tampered); err == nil {
+			assert.False(t, verified, "tampered frame verified")
+		}
+	}
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
@@ -2,8 +2,12 @@ package network
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"io"
 	"math/rand"
 	"net"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,6 +30,9 @@ const (
 	defaultWriteBufferSize   = 4096
 	defaultWriteFlushLatency = 50 * time.Millisecond
 	defaultWriteTimeout      = 3 * time.Second
+	defaultHandshakeTimeout  = 5 * time.Second
+
+	defaultReceiveMemoryBudget = 32 * defaultMaxFrameSize
 )
 
 var contextPool = sync.Pool{
@@ -36,12 +43,52 @@ var contextPool = sync.Pool{
 
 var (
 	_ NetworkInterface = (*Network)(nil)
+
+	// ErrNotReady is returned by writes issued before the network is ready.
+	ErrNotReady = errors.New("network: not yet listening for peers")
+
+	// ErrNetworkClosed is returned by operations aborted by the network shutting down.
+	ErrNetworkClosed = errors.New("network: closed")
+)
+
+// ReadinessPolicy decides what happens to writes issued before the network
+// is ready.
+type ReadinessPolicy int
+
+const (
+	// QueueUntilReady holds writes until the network is ready, failing them
+	// with ErrNotReady should the write timeout elapse first.
+	QueueUntilReady ReadinessPolicy = iota
+	// FailUntilReady immediately fails writes with ErrNotReady until the
+	// network is ready.
+	FailUntilReady
 )
 
 // Network represents the current networking state for this node.
 type Network struct {
+	// Counters of aborted handshakes. Kept first for 64-bit alignment.
+	handshakeTimeouts uint64 // for atomic ops
+	handshakeFailures uint64 // for atomic ops
+	handshakesResumed uint64 // for atomic ops
+	handshakesSent    uint64 // for atomic ops
+
+	// Counters of puzzles handed to dialers under load.
+	puzzlesIssued   uint64 // for atomic ops
+	puzzlesSolved   uint64 // for atomic ops
+	puzzlesFailed   uint64 // for atomic ops
+	puzzlesExempted uint64 // for atomic ops
+
+	// Number of inbound handshakes underway.
+	pendingHandshakes int64 // for atomic ops
+
+	// Number of messages queued across the send queues of all peers.
+	sendsQueued int64 // for atomic ops
+
 	opts options
 
+	// Preset the network was built with, and overrides of it.
+	profile Profile
+
 	// Node's keypair.
 	keys *crypto.KeyPair
 
@@ -52,6 +99,205 @@ type Network struct {
 	// map[string]Plugin
 	plugins *PluginList
 
+	// Plugins handling messages sent under a protocol tag, by tag.
+	protocols map[string]*PluginList
+
+	// Hooks invoked on outgoing messages before they are signed.
+	outboundHooks []outboundHook
+
+	// Filters screening received frames before their signature is verified,
+	// and how many frames each tier of validation dropped.
+	frameFilters   []FrameFilter
+	screenedFrames uint64
+	rejectedFrames uint64
+	replayedFrames uint64
+
+	// Handshake extensions run with peers, in the order they were registered.
+	handshakeExtensions []*handshakeExtension
+
+	// Heartbeats of long-lived loops, watched for loops which got stuck.
+	watchdog *watchdog
+
+	// Goroutines and file descriptors held, by subsystem.
+	resources *resourceRegistry
+
+	// Sessions which peers may resume without a full handshake.
+	sessions *sessionStore
+
+	// Affinity tokens issued to peers which dialed us, and held for peers we
+	// dialed.
+	affinities *affinityStore
+
+	// Addresses the public keys of peers were last seen at.
+	identities identities
+
+	// Public keys peers demonstrated under each signature scheme.
+	signatureBindings signatureBindings
+
+	// Results of dialing peers back at the addresses they advertise.
+	addresses addressVerifier
+
+	// Outcomes of peers dialing us back at the address we advertise, and
+	// the rate at which we dial peers back on their behalf.
+	reachability reachabilityCheck
+	probeAnswers *tokenBucket
+
+	// Latest protocol upgrade announced by every node, ourselves included.
+	upgrades upgradeBook
+
+	// Partial view of the network maintained by peer sampling.
+	sampler *peerSampler
+
+	// Peers imported from bundles, yet to be dialed.
+	candidates addressBook
+
+	// Recent disconnects, held back from plugins during storms.
+	storm stormDetector
+
+	// Memory budget shared by messages received from all peers.
+	budget *receiveBudget
+
+	// Subscribers to summaries of all messages sent and received.
+	tails tails
+
+	// Recent events published, and subscribers to them.
+	events eventBus
+
+	// Components swept of expired entries, alongside what was reclaimed.
+	sweeper sweeper
+
+	// Legacy peers which connected to us, by address.
+	legacy legacyPeers
+
+	// Capture all frames sent and received are written to while capturing,
+	// alongside counts of frames captured.
+	captureMutex   sync.Mutex
+	capture        *capture
+	capturing      uint32 // for atomic ops
+	capturedFrames uint64
+	capturedBytes  uint64
+	captureDropped uint64
+
+	// Rules frames sent and received are mirrored by, and the queue of frames
+	// waiting to be mirrored.
+	mirrors mirrors
+
+	// now returns the current time, and after a channel the current time is
+	// sent on once a duration elapsed. Both may be swapped out in tests.
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+
+	// Semaphores bounding how many messages of a given type are handled at once.
+	handlerSlots map[string]chan struct{}
+
+	// Semaphores bounding how many messages under a given protocol tag are
+	// handled at once.
+	protocolSlots map[string]chan struct{}
+
+	// Payload hashes of recently delivered messages of a given type, should
+	// they be deduplicated.
+	dedupes    map[string]*dedupeSet
+	duplicates uint64
+
+	// Idempotency keys of recently handled requests, keyed by sender, so that
+	// retries of requests handled already are dropped.
+	idempotency *dedupeSet
+
+	// Semaphore bounding how many peers are dialed at once, if bounded.
+	dialSlots chan struct{}
+
+	// Workers handling received messages, if pooled.
+	dispatch *dispatchPool
+
+	// Outbound bandwidth limits across all peers, and of every peer.
+	bandwidth          *tokenBucket
+	peerBandwidthMutex sync.Mutex
+	peerBandwidth      int
+	peerBandwidthBurst int
+	shapingExempt      map[string]struct{}
+	shapingDelayed     uint64
+	shapingDelay       int64
+
+	// Dials in progress on behalf of writes.
+	writeDials writeDials
+
+	// Requests awaiting replies across all peers.
+	requests requestTable
+
+	// Circuits of peers which failed to be sent to.
+	circuits circuitBreakers
+
+	// Healthy peers of every group, as last noticed.
+	groupHealth groupHealth
+
+	// Connections accepted over the last second, for puzzles to be handed to
+	// dialers once past the threshold.
+	accepts acceptRate
+
+	// Admission decisions taken on writes, by priority.
+	admissions admissionCounters
+
+	// Tags the application gave peers, and those protecting peers from
+	// eviction.
+	tags          peerTags
+	protectedTags map[string]struct{}
+
+	// Frames padded, and bytes of padding sent and received.
+	paddedFrames    uint64
+	paddingSent     uint64
+	paddingReceived uint64
+
+	// Messages sent over control connections, alongside counts of messages
+	// sent and received over them.
+	controlMessages map[string]struct{}
+	controlSent     uint64
+	controlReceived uint64
+
+	// Counts of rejections sent to, suppressed for and received from peers.
+	rejectionsSent       uint64
+	rejectionsSuppressed uint64
+	rejectionsReceived   uint64
+
+	// Messages being reassembled from their chunks, and the IDs and count of
+	// those sent in chunks.
+	transfers     transferTable
+	transferSeq   uint64
+	transfersSent uint64
+
+	// Replay nonces stamped on the envelopes we sign, carrying on from the
+	// time the network was built so that they keep increasing across
+	// restarts, and the windows of nonces received from peers.
+	replayNonce uint64
+	replays     *replayFilter
+
+	// Limits on the rate of messages read from peers.
+	limiter *rateLimiter
+
+	// Results of checking the signatures of recently received messages, if cached.
+	verifications *verificationCache
+
+	// Policy vetoing connections, if any.
+	gater ConnectionGater
+
+	// Counts of messages sent and received by type.
+	stats *Stats
+
+	// Workers signing and serializing messages sent through SendAsync, if pooled.
+	pipeline *sendPipeline
+
+	// Map of peer addresses (string) <-> *outbox of messages sent through
+	// QueueMessage, alongside counts of those which spilled over to disk.
+	outboxes       sync.Map
+	spilled        uint64
+	spillCorrupted uint64
+
+	// Replies skipped as their request's deadline had passed.
+	skippedReplies uint64
+
+	// Services the node advertises to its peers.
+	servicesMutex sync.Mutex
+	services      serviceRecords
+
 	// Node's cryptographic ID.
 	ID peer.ID
 
@@ -67,11 +313,49 @@ type Network struct {
 	// Map of protocol addresses (string) <-> *transport.Layer
 	transports *sync.Map
 
+	// Inbound/outbound peer quotas.
+	slots *peerSlots
+
+	// Set of unified addresses of peers allowed to use reserved slots.
+	pinned map[string]struct{}
+
+	// Set of IDs of peers allowed to use reserved slots.
+	pinnedIDs map[PeerID]struct{}
+
 	// listeningCh will block a goroutine until this node is listening for peers.
 	listeningCh chan struct{}
 
+	// peersChanged is closed and replaced every time a peer connects or disconnects.
+	peersChanged      chan struct{}
+	peersChangedMutex sync.Mutex
+
 	// <-kill will begin the server shutdown process
 	kill chan struct{}
+
+	// Pooled contexts and received frames held, tracked only while auditing
+	// ownership.
+	owned ownershipRegistry
+
+	// Listener accepting new peers, set once Listen has bound it.
+	listener      net.Listener
+	listenerMutex sync.Mutex
+
+	// Set of incoming connections (net.Conn) being served by Accept.
+	incoming *sync.Map
+
+	// Journal messages bound for plugins are appended to before being
+	// handled, if any.
+	journal *inboundJournal
+
+	// started is set once plugin Startup hooks have been invoked.
+	started uint32 // for atomic ops
+
+	// handedOver is set once the network handed its state and listener over
+	// to a successor.
+	handedOver uint32 // for atomic ops
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // options for network struct
@@ -84,6 +368,227 @@ type options struct {
 	writeBufferSize   int
 	writeFlushLatency time.Duration
 	writeTimeout      time.Duration
+	maxPeers          int
+	maxInboundPeers   int
+	maxOutboundPeers  int
+	reservedPeers     int
+	pinnedPeers       []string
+	pinnedPeerIDs     []PeerID
+	readinessPolicy   ReadinessPolicy
+	dialOnWrite       DialPolicy
+	handshakeTimeout  time.Duration
+	protocolVersions  []string
+	capabilities      []string
+	sessionLifetime   time.Duration
+	peerSessionLimit  int
+	sessionCarryOver  func(client *PeerClient, name string, value interface{}) bool
+	roamingPolicy     RoamingPolicy
+	maxDials          int
+	warmUpPeers       []string
+	onDialFailed      func(address string, err error)
+	candidateOrder    CandidateOrder
+
+	adaptiveWrites       bool
+	adaptiveWriteBase    time.Duration
+	adaptiveWriteFloor   time.Duration
+	adaptiveWriteCeiling time.Duration
+
+	readIdleTimeout time.Duration
+	readBodyTimeout time.Duration
+	readBodyMinRate int
+
+	batchMessages int
+	batchBytes    int
+	batchDelay    time.Duration
+
+	receiveMemoryBudget int
+	receiveWatermark    float64
+	onReceiveWatermark  func(stats ReceiveBudgetStats, above bool)
+
+	sendHighWatermark int
+	sendLowWatermark  int
+	onSendWatermark   func(address string, congested bool)
+
+	quarantinePeriod   time.Duration
+	quarantineMessages int
+	onPeerGraduated    func(client *PeerClient)
+
+	handlerConcurrency   map[string]int
+	protocolConcurrency  map[string]int
+	orderedHandlers      map[string]struct{}
+	dedupeWindows        map[string]dedupeWindow
+	onHandlerPanic       func(client *PeerClient, p *HandlerPanic)
+	handlerErrorResults  map[string]HandlerResult
+	defaultHandlerResult HandlerResult
+	handlerPanicResult   HandlerResult
+	onViolation          func(client *PeerClient, err error)
+
+	maxPendingRequests int
+	requestLeakFactor  int
+
+	circuitFailures    int
+	circuitCooldown    time.Duration
+	circuitMaxCooldown time.Duration
+	onCircuitChanged   func(address string, state CircuitState)
+
+	admissionCapacity int
+	admissionPolicy   AdmissionPolicy
+
+	protectedTags []string
+	tagRateLimits map[string]RateLimit
+	tagBandwidth  map[string]bandwidthLimit
+	onPeerTagged  func(id PeerID, tag string, tagged bool)
+
+	groupMetadataKey string
+	onGroupEmptied   func(group string)
+	onGroupRecovered func(group string, health GroupHealth)
+
+	puzzlePending       int
+	puzzleRate          int
+	puzzleDifficulty    int
+	puzzleMaxDifficulty int
+
+	eventReplaySize int
+	eventHooks      []eventHook
+
+	compactionInterval time.Duration
+	compactionBudget   int
+
+	maxFrameSize   int
+	maxMessageSize int
+
+	chunkSize       int
+	maxTransferSize int
+	transferTimeout time.Duration
+
+	rejectRate  int
+	rejectBurst int
+	onRejection func(client *PeerClient, r *Rejection)
+
+	pingRate         int
+	pingBurst        int
+	pingDialOnDemand bool
+
+	reachabilityProbes    int
+	reachabilityTimeout   time.Duration
+	onReachabilityChanged func(previous, current Reachability)
+
+	enforceUpgradeVersion    uint32
+	enforceUpgradeActivation time.Time
+
+	peerSamplingView     int
+	peerSamplingInterval time.Duration
+	peerSamplingHealing  int
+	peerSamplingSwap     int
+
+	shareDiagnostics []PeerID
+
+	signingForm SigningForm
+
+	// profile names the preset last applied, if any.
+	profile string
+
+	// listenerFile is the listener to adopt in place of binding one, if any.
+	listenerFile *os.File
+
+	journalStore JournalStore
+	journalBytes int
+
+	rateLimits    RateLimits
+	onRateLimited func(client *PeerClient, level RateLimitLevel, delay time.Duration)
+
+	light     bool
+	ephemeral bool
+
+	dispatchWorkers   int
+	dispatchQueueSize int
+	dispatchKeys      map[string]func(ctx *PluginContext) []byte
+
+	sendWorkers int
+
+	statsInterval  time.Duration
+	statsRetention time.Duration
+
+	bandwidth          int
+	bandwidthBurst     int
+	peerBandwidth      int
+	peerBandwidthBurst int
+	shapingExempt      []string
+
+	paddingBuckets []int
+	paddingFixed   map[string]int
+
+	splitControlPlane bool
+	controlMessages   []string
+
+	allowLegacyPeers bool
+
+	encryption      EncryptionPolicy
+	ratchetRecords  int
+	ratchetInterval time.Duration
+
+	replayWindow        int
+	requireReplayNonces bool
+	replayHorizon       time.Duration
+
+	verificationCacheSize int
+	verificationCacheTTL  time.Duration
+	verifyAlways          map[string]struct{}
+
+	idempotencyWindow time.Duration
+	idempotencySize   int
+
+	gaters            []ConnectionGater
+	allowNetworks     []string
+	denyNetworks      []string
+	onConnectionGated func(stage GateStage, address string, reason string)
+
+	onHandshakeFailed func(failure HandshakeFailure)
+
+	watchdogThreshold time.Duration
+	onNetworkStalled  func(stall NetworkStall)
+
+	resourceBudgets  map[string]Budget
+	onBudgetExceeded func(err *BudgetError)
+
+	affinityAddress  string
+	affinityLifetime time.Duration
+	onAffinityEchoed func(address string, token []byte, ours bool)
+
+	mirrorQueueSize int
+
+	signatureSchemes       []SignatureScheme
+	signatureTransitionEnd time.Time
+	strictSignatures       bool
+
+	verifyAddresses       bool
+	verifyAddressInterval time.Duration
+
+	reapInterval      time.Duration
+	reapProbes        int
+	reapWriteFailures int
+
+	keepalivePayload   func(peer PeerInfo) []byte
+	onKeepalivePayload func(peer PeerInfo, payload []byte, rtt time.Duration)
+	onPeerServices     func(peer PeerInfo, services map[string]ServiceRecord)
+
+	spillDir         string
+	spillMemoryBytes int
+
+	deadlineCeiling time.Duration
+	deadlineFloor   time.Duration
+
+	executor Executor
+
+	peerBundleMaxAge time.Duration
+
+	stormThreshold    int
+	stormWindow       time.Duration
+	stormSmear        time.Duration
+	onNetworkDegraded func(event NetworkDegraded)
+
+	peerMetadata         func() map[string][]byte
+	validatePeerMetadata func(info PeerInfo, metadata map[string][]byte) error
 }
 
 // ConnState represents a connection.
@@ -92,32 +597,103 @@ type ConnState struct {
 	writer       *bufio.Writer
 	messageNonce uint64
 	writerMutex  *sync.Mutex
+
+	// sends holds messages written asynchronously through WriteAsync.
+	sends *sendQueue
+
+	// bandwidth limits the rate of writes to the peer.
+	bandwidth *tokenBucket
+
+	// flow counts the bytes bound for the peer at every stage of being
+	// written out. It is nil for control connections.
+	flow *sendFlow
+
+	// control is the connection control messages are written over, should
+	// the peer accept one.
+	control *ConnState
+
+	// legacy is set for connections to peers speaking the wire protocol of
+	// upstream noise nodes.
+	legacy bool
+}
+
+// closeControl closes the control connection to the peer, if any.
+func (s *ConnState) closeControl() {
+	if s.control != nil {
+		s.control.conn.Close()
+	}
 }
 
 // Init starts all network I/O workers.
 func (n *Network) Init() {
-	// Spawn write flusher.
-	go n.flushLoop()
+	// Spawn write flusher, unless frames are flushed out as they are written.
+	if !n.opts.light {
+		n.spawnIn(SubsystemNetwork, n.flushLoop)
+	}
+
+	if n.dispatch != nil {
+		n.dispatch.start(accountedExecutor{n: n, subsystem: SubsystemDispatch}, n.watchdog)
+	}
+
+	if n.pipeline != nil {
+		n.pipeline.start(accountedExecutor{n: n, subsystem: SubsystemNetwork}, n.opts.sendWorkers, n.watchdog)
+	}
+
+	if n.watchdog != nil {
+		n.spawnIn(SubsystemNetwork, n.watchdogLoop)
+	}
+
+	if n.opts.reapInterval > 0 {
+		n.spawnIn(SubsystemNetwork, n.reapLoop)
+	}
+
+	if n.opts.enforceUpgradeVersion > 0 {
+		n.spawnIn(SubsystemNetwork, n.upgradeLoop)
+	}
+
+	if n.opts.peerSamplingView > 0 {
+		n.spawnIn(SubsystemNetwork, n.samplingLoop)
+	}
+
+	if n.opts.compactionInterval > 0 {
+		n.spawnIn(SubsystemNetwork, n.compactionLoop)
+	}
 }
 
 func (n *Network) flushLoop() {
 	t := time.NewTicker(n.opts.writeFlushLatency)
 	defer t.Stop()
+
+	h := n.watchdog.watch(ComponentFlush, "", nil)
+	defer n.watchdog.unwatch(h)
+
 	for {
 		select {
 		case <-n.kill:
 			return
 		case <-t.C:
+			h.beat()
 			n.connections.Range(func(key, value interface{}) bool {
 				if state, ok := value.(*ConnState); ok {
 					state.writerMutex.Lock()
-					if err := state.writer.Flush(); err != nil {
+					err := state.writer.Flush()
+					if err != nil {
 						glog.Warning(err)
 					}
+					state.flow.setBuffered(state.writer.Buffered())
 					state.writerMutex.Unlock()
+
+					if err != nil {
+						n.markWritten(key.(string), err)
+					}
+
+					state.sends.flush(err)
 				}
 				return true
 			})
+
+			n.flushCapture()
+			h.idle()
 		}
 	}
 }
@@ -127,19 +703,122 @@ func (n *Network) GetKeys() *crypto.KeyPair {
 	return n.keys
 }
 
-func (n *Network) dispatchMessage(client *PeerClient, msg *protobuf.Message) {
-	if !client.IsIncomingReady() {
+func (n *Network) dispatchMessage(client *PeerClient, frame *receivedMessage) {
+	defer frame.done()
+
+	// Messages received over a session merged into another one are dispatched
+	// as though they were received over the latter.
+	client = client.merged()
+
+	if !client.IsIncomingReady() || client.isClosed() {
+		return
+	}
+	msg := frame.Message
+
+	if err := checkExtensions(msg); err != nil {
+		atomic.AddUint64(&n.rejectedFrames, 1)
+		n.reportViolation(client, err)
+		n.reject(client, frame, err)
 		return
 	}
-	var ptr types.DynamicAny
-	if err := types.UnmarshalAny(msg.Message, &ptr); err != nil {
+
+	if err := checkHints(msg.Hints); err != nil {
+		atomic.AddUint64(&n.rejectedFrames, 1)
+		n.reportViolation(client, err)
+		n.reject(client, frame, err)
+		return
+	}
+
+	// Replays are dropped past the receive window, so that the connection
+	// sequence carries on past them. They are not held against the peer, as
+	// it need not be the one replaying them.
+	if err := n.checkReplay(msg); err != nil {
+		atomic.AddUint64(&n.replayedFrames, 1)
+		glog.Warningf("network: dropped message from %s: %v", client.Address, err)
+		return
+	}
+
+	if n.opts.maxMessageSize > 0 && len(frame.raw)-frame.padding > n.opts.maxMessageSize {
+		glog.Warningf("network: dropped message of %d bytes from %s", len(frame.raw)-frame.padding, client.Address)
+		n.reject(client, frame, ErrMessageTooLarge)
+		return
+	}
+
+	// Payloads are only decoded up front should the network handle them
+	// itself; plugins decode them on demand.
+	name, err := payloadName(msg.Message)
+	if err != nil {
 		glog.Error(err)
 		return
 	}
 
+	// Unpack batches, having verified their signature once for all payloads.
+	if name == batchName {
+		var batch protobuf.Batch
+		if err := types.UnmarshalAny(msg.Message, &batch); err != nil {
+			glog.Error(err)
+			return
+		}
+
+		n.checkQuarantine(client, len(batch.Payloads))
+
+		for _, payload := range batch.Payloads {
+			name, err := payloadName(payload)
+			if err != nil {
+				glog.Error(err)
+				continue
+			}
+
+			if name == batchName {
+				glog.Error("network: received a batch nested within a batch")
+				continue
+			}
+
+			n.deliverMessage(client, frame, 0, msg.Protocol, name, payload)
+		}
+		return
+	}
+
+	// Messages sent in chunks are handed over once reassembled.
+	if name == chunkName {
+		payload, err := n.reassemble(client, frame, msg.Message)
+		if err != nil {
+			n.reportViolation(client, err)
+			return
+		}
+		if payload == nil {
+			return
+		}
+
+		if name, err = payloadName(payload); err != nil {
+			glog.Error(err)
+			return
+		}
+
+		if name == chunkName || name == batchName {
+			glog.Errorf("network: received a %s reassembled from chunks", name)
+			return
+		}
+
+		n.checkQuarantine(client, 1)
+		n.deliverMessage(client, frame, 0, msg.Protocol, name, payload)
+		return
+	}
+
+	if n.handleKeepalive(client, name, msg.Message) || n.handlePing(client, name, msg) || n.handleReachabilityProbe(client, name, msg) || n.handleShuffle(client, name, msg) || n.handleDiagnosticsRequest(client, name, msg) || n.handleServiceRecords(client, name, msg.Message) || n.handleRejection(client, name, msg.Message) || n.handleUpgrade(client, name, msg.Message) || n.handleGoodbye(client, name, msg.Message) || n.handleAffinityRevoked(client, name, msg.Message) {
+		return
+	}
+
+	n.checkQuarantine(client, 1)
+
 	if msg.RequestNonce > 0 && msg.ReplyFlag {
-		if _state, exists := client.Requests.Load(msg.RequestNonce); exists {
-			state := _state.(*RequestState)
+		if state := n.requests.lookup(client, msg.RequestNonce); state != nil {
+			var ptr types.DynamicAny
+			if err := types.UnmarshalAny(msg.Message, &ptr); err != nil {
+				glog.Error(err)
+				return
+			}
+
 			select {
 			case state.data <- ptr.Message:
 			case <-state.closeSignal:
@@ -148,42 +827,127 @@ func (n *Network) dispatchMessage(client *PeerClient, msg *protobuf.Message) {
 		}
 	}
 
-	switch msgRaw := ptr.Message.(type) {
-	case *protobuf.Bytes:
-		client.handleBytes(msgRaw.Data)
-	default:
-		ctx := contextPool.Get().(*PluginContext)
-		ctx.client = client
-		ctx.message = msgRaw
-		ctx.nonce = msg.RequestNonce
-
-		go func() {
-			// Execute 'on receive message' callback for all plugins.
-			n.plugins.Each(func(plugin PluginInterface) {
-				if err := plugin.Receive(ctx); err != nil {
-					glog.Errorf("%+v", err)
-				}
-			})
+	n.deliverMessage(client, frame, msg.RequestNonce, msg.Protocol, name, msg.Message)
+}
 
-			contextPool.Put(ctx)
-		}()
+// deliverMessage hands a single received message over to all plugins
+// registered under the protocol tag it was sent under.
+func (n *Network) deliverMessage(client *PeerClient, frame *receivedMessage, nonce uint64, protocol string, name string, payload *types.Any) {
+	if _, supported := n.protocolPlugins(protocol); !supported {
+		err := errors.Wrapf(ErrProtocolUnsupported, "protocol %q", protocol)
+		n.reportViolation(client, err)
+		n.reject(client, frame, err)
+		return
+	}
+
+	if name == bytesName && protocol == "" {
+		var bytes protobuf.Bytes
+		if err := types.UnmarshalAny(payload, &bytes); err != nil {
+			glog.Error(err)
+			return
+		}
+		client.handleBytes(bytes.Data)
+		return
+	}
+
+	// Messages routed toward a key are handed over to plugins unwrapped.
+	var routed *protobuf.Routed
+	journaled := payload
+	if name == routedName {
+		var err error
+		if routed, name, err = unwrapRouted(payload); err != nil {
+			n.reportViolation(client, err)
+			return
+		}
+		payload = routed.Message
+	}
+
+	// Messages are journaled before anything is made of them, so that a peer
+	// is told of those which never will be.
+	var seq uint64
+	if n.journal != nil {
+		var err error
+		if seq, err = n.journalMessage(frame, journaled); err != nil {
+			glog.Warningf("network: dropping message from %s: %v", client.Address, err)
+			n.reject(client, frame, err)
+			return
+		}
+	}
+
+	// Copies of a message delivered recently are dropped silently.
+	if n.isDuplicate(ProtocolMessageName(protocol, name), payload.Value) {
+		n.consumeJournaled(seq)
+		return
+	}
+
+	// Retries of requests handled already are dropped too, leaving the reply
+	// to the attempt handled to answer them.
+	if n.isRetriedRequest(frame.Message) {
+		n.consumeJournaled(seq)
+		return
+	}
+
+	ctx := contextPool.Get().(*PluginContext)
+	ctx.own.acquire(&n.owned, "plugin context")
+	ctx.client = client
+	ctx.nonce = nonce
+	ctx.origin = *client.id()
+	ctx.frame = frame
+	ctx.protocol = protocol
+	ctx.reset(name, payload)
+	ctx.routed = routed
+	if nonce > 0 {
+		ctx.deadline = n.handlerDeadline(client, frame.Message.BudgetMs)
+	}
+
+	frame.hold()
+	job := func() {
+		if n.handleMessage(ctx, ProtocolMessageName(protocol, name)) {
+			n.consumeJournaled(seq)
+		}
+		releaseContext(ctx)
+		frame.done()
+	}
+
+	if n.dispatch != nil {
+		n.dispatch.submit(n.dispatchKey(ctx, name), job)
+	} else if n.isOrdered(name) {
+		client.submitOrdered(name, job)
+	} else if err := n.trySpawnIn(SubsystemDispatch, job); err != nil {
+		// Messages are dropped rather than handled past the budget.
+		glog.Warningf("dropped message from %s: %v", client.Address, err)
+
+		releaseContext(ctx)
+		frame.done()
 	}
 }
 
-// Listen starts listening for peers on a port.
+// Listen starts listening for peers on a port. Ephemeral nodes take no
+// connections, and only start their plugins.
 func (n *Network) Listen() {
+	if n.isClosed() {
+		return
+	}
 
 	// Handle 'network starts listening' callback for plugins.
-	n.plugins.Each(func(plugin PluginInterface) {
+	n.eachPlugin(func(plugin PluginInterface) {
 		plugin.Startup(n)
 	})
+	atomic.StoreUint32(&n.started, 1)
 
-	// Handle 'network stops listening' callback for plugins.
-	defer func() {
-		n.plugins.Each(func(plugin PluginInterface) {
-			plugin.Cleanup(n)
-		})
-	}()
+	// Messages left unhandled by a previous run go before any received anew.
+	n.redeliverJournal()
+
+	// Ephemeral nodes take no connections.
+	if n.opts.ephemeral {
+		n.startListening()
+		glog.Infof("Running ephemerally as %x.\n", n.keys.PublicKey)
+
+		if len(n.opts.warmUpPeers) > 0 {
+			n.Warm(context.Background(), n.opts.warmUpPeers...)
+		}
+		return
+	}
 
 	addrInfo, err := ParseAddress(n.Address)
 	if err != nil {
@@ -192,8 +956,17 @@ func (n *Network) Listen() {
 
 	var listener net.Listener
 
-	if t, exists := n.transports.Load(addrInfo.Protocol); exists {
-		listener, err = t.(transport.Layer).Listen(int(addrInfo.Port))
+	if n.opts.listenerFile != nil {
+		listener, err = n.adoptListener(addrInfo)
+		if err != nil {
+			glog.Fatal(err)
+		}
+	} else if t, exists := n.transports.Load(addrInfo.Protocol); exists {
+		if l, ok := t.(transport.AddressListener); ok {
+			listener, err = l.ListenAddress(addrInfo.HostPort())
+		} else {
+			listener, err = t.(transport.Layer).Listen(int(addrInfo.Port))
+		}
 		if err != nil {
 			glog.Fatal(err)
 		}
@@ -201,40 +974,81 @@ func (n *Network) Listen() {
 		glog.Fatal("invalid protocol: " + addrInfo.Protocol)
 	}
 
+	// Close() may have been called while we were binding the listener.
+	n.listenerMutex.Lock()
+	if n.isClosed() {
+		n.listenerMutex.Unlock()
+		listener.Close()
+		return
+	}
+	n.listener = listener
+	n.listenerMutex.Unlock()
+
+	// The listener is closed as the loop accepting connections off it ends.
+	n.resources.track(SubsystemNetwork, 1, 1)
+	defer n.resources.track(SubsystemNetwork, -1, -1)
+
 	n.startListening()
 
 	glog.Infof("Listening for peers on %s.\n", n.Address)
 
-	// handle server shutdowns
-	go func() {
-		select {
-		case <-n.kill:
-			// cause listener.Accept() to stop blocking so it can continue the loop
-			listener.Close()
-		}
-	}()
+	if len(n.opts.warmUpPeers) > 0 {
+		n.Warm(context.Background(), n.opts.warmUpPeers...)
+	}
 
 	// Handle new clients.
 	for {
 		if conn, err := listener.Accept(); err == nil {
-			go n.Accept(conn)
+			accounted, err := n.accountConn(SubsystemPeers, conn)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			n.spawnIn(SubsystemPeers, func() { n.Accept(accounted) })
 
 		} else {
 			// if the Shutdown flag is set, no need to continue with the for loop
-			select {
-			case <-n.kill:
+			if n.isClosed() {
 				glog.Infof("Shutting down server on %s.\n", n.Address)
 				return
-			default:
-				glog.Error(err)
 			}
+			if atomic.LoadUint32(&n.handedOver) == 1 {
+				glog.Infof("Handed listening on %s over to a successor.\n", n.Address)
+				return
+			}
+			glog.Error(err)
 		}
 	}
 }
 
+// isClosed returns true once the network has begun shutting down.
+func (n *Network) isClosed() bool {
+	select {
+	case <-n.kill:
+		return true
+	default:
+		return false
+	}
+}
+
 // Client either creates or returns a cached peer client given its host address.
 func (n *Network) Client(address string) (*PeerClient, error) {
-	address, err := ToUnifiedAddress(address)
+	return n.client(address, DirectionOutbound, false)
+}
+
+// ReservedClient is equivalent to Client, though it may additionally draw
+// from the reserved peer slots should the outbound quota be exhausted. It is
+// meant for maintenance traffic such as DHT lookups.
+func (n *Network) ReservedClient(address string) (*PeerClient, error) {
+	return n.client(address, DirectionOutbound, true)
+}
+
+func (n *Network) client(address string, direction ConnDirection, canReserve bool) (_ *PeerClient, err error) {
+	if n.isClosed() {
+		return nil, ErrNetworkClosed
+	}
+
+	address, err = ToUnifiedAddress(address)
 	if err != nil {
 		return nil, err
 	}
@@ -260,25 +1074,193 @@ func (n *Network) Client(address string) (*PeerClient, error) {
 	}
 
 	client := c.(*PeerClient)
+	connected := false
 	defer func() {
 		client.setOutgoingReady()
+		if client.isClosed() {
+			client.releaseSlot()
+		}
+		if err != nil {
+			n.dialFailed(address, err)
+		}
+		if connected {
+			client.publishConnected()
+		}
 	}()
 
-	conn, err := n.Dial(address)
-	if err != nil {
-		n.peers.Delete(address)
-		return nil, err
+	client.direction = direction
+	client.reserved, err = n.slots.acquire(direction, canReserve || n.isPinned(address))
+	if err == nil {
+		client.holdSlot()
+	}
+
+	// Peers pinned by ID are only recognized once they prove their identity,
+	// so should slots run out, dial anyway and take a reserved slot if pinned.
+	// Peers holding a protected tag may evict others for a slot, so likewise
+	// dial anyway.
+	slotErr := err
+	if slotErr != nil && len(n.pinnedIDs) == 0 && len(n.protectedTags) == 0 {
+		n.peers.Delete(address)
+		return nil, slotErr
+	}
+
+	dialStart := n.now()
+	conn, handshake, err := n.dial(address, false)
+	n.candidates.recordDial(address, n.now(), n.now().Sub(dialStart), err)
+	if err != nil {
+		n.recordSend(address, err)
+	}
+	if err == nil && n.isClosed() {
+		// Shut down while dialing; don't leak the connection.
+		conn.Close()
+		err = ErrNetworkClosed
+	}
+	if err == nil && slotErr != nil {
+		client.reserved, err = n.slots.acquire(direction, n.isPinnedID(handshake.remote.PublicKey))
+		if err != nil && n.isProtected(handshake.remote.PublicKey) && n.evictFor(direction) {
+			client.reserved, err = n.slots.acquire(direction, false)
+		}
+		if err != nil {
+			conn.Close()
+		} else {
+			client.holdSlot()
+		}
+	}
+	if err != nil {
+		client.releaseSlot()
+		n.peers.Delete(address)
+		return nil, err
+	}
+
+	client.publicKey = handshake.remote.PublicKey
+	client.legacy = handshake.legacy
+	client.encrypted = handshake.keys != nil
+
+	state := n.newConnState(client, address, conn, handshake.remote.PublicKey)
+	state.legacy = handshake.legacy
+	state.messageNonce = handshake.sent
+
+	// Control messages fall back to the data connection should the peer not
+	// accept a control connection.
+	if n.splitsControlPlane(handshake.offer) {
+		control, controlErr := n.dialControl(address)
+		if controlErr != nil {
+			glog.Warningf("sending control messages to %s over the data connection: %v", address, controlErr)
+		}
+		state.control = control
+	}
+
+	// Carry on from where the sequence numbers of a resumed session left off.
+	client.session = handshake.session
+	client.offer = handshake.offer
+	n.adoptOfferedServices(client, handshake.services)
+	if handshake.resumed {
+		state.messageNonce = handshake.session.messageNonce
+		client.RequestNonce = handshake.session.requestNonce
+		client.state.restore(handshake.session.carried)
+		handshake.session.carried = nil
+	}
+
+	n.yield()
+
+	n.connections.Store(address, state)
+
+	// Close() may have gone through peers before the connection was stored.
+	if n.isClosed() || client.isClosed() {
+		client.Close()
+		n.connections.Delete(address)
+		conn.Close()
+		state.closeControl()
+		state.sends.close(ErrNetworkClosed)
+
+		// Messages of a peer whose session was merged into another one while
+		// we dialed it back are still dispatched, through the latter.
+		if direction == DirectionInbound && !n.isClosed() && client.successorOf() != nil {
+			return client, nil
+		}
+		return nil, ErrNetworkClosed
+	}
+	n.spawnIn(SubsystemPeers, func() { n.sendLoop(address, state.sends) })
+
+	// Ephemeral nodes are never dialed back, so peers write to them over the
+	// connections they dialed.
+	if n.opts.ephemeral {
+		n.spawnIn(SubsystemPeers, func() {
+			n.serve(conn, DirectionOutbound, handshake, func(*protobuf.ID) (*PeerClient, error) {
+				return client, nil
+			})
+		})
+	}
+
+	n.startQuarantine(client)
+	n.roam(client, state, handshake.remote.PublicKey)
+
+	client.Init()
+	connected = true
+	n.probeReachability(client)
+	n.announceUpgradeTo(client)
+	n.seedSampler(client)
+
+	n.closeCircuit(address)
+
+	n.notifyPeersChanged()
+	n.resumeOutbox(address)
+
+	return client, nil
+}
+
+// newConnState returns the state of a connection to a peer over which
+// messages are written to it at an address.
+func (n *Network) newConnState(client *PeerClient, address string, conn net.Conn, publicKey []byte) *ConnState {
+	flow := n.newSendFlow(address)
+
+	var w io.Writer = &flowWriter{w: conn, flow: flow}
+	if n.opts.adaptiveWrites {
+		w = &meteredWriter{w: w, estimator: &client.throughput}
 	}
 
-	n.connections.Store(address, &ConnState{
+	state := &ConnState{
 		conn:        conn,
-		writer:      bufio.NewWriterSize(conn, n.opts.writeBufferSize),
+		writer:      bufio.NewWriterSize(w, n.opts.writeBufferSize),
 		writerMutex: new(sync.Mutex),
-	})
+		sends:       newSendQueue(),
+		bandwidth:   n.newPeerBandwidth(publicKey),
+		flow:        flow,
+	}
+	state.sends.flow = flow
+	state.sends.total = &n.sendsQueued
 
-	client.Init()
+	return state
+}
 
-	return client, nil
+// isPinned returns true if an address belongs to a pinned peer.
+func (n *Network) isPinned(address string) bool {
+	_, pinned := n.pinned[address]
+	return pinned
+}
+
+// isPinnedID returns true if a public key belongs to a peer pinned by ID.
+func (n *Network) isPinnedID(publicKey []byte) bool {
+	_, pinned := n.pinnedIDs[PeerID{publicKey: string(publicKey)}]
+	return pinned
+}
+
+// isPinnedPeer returns true if a peer is pinned by either its address or ID.
+func (n *Network) isPinnedPeer(c *PeerClient) bool {
+	return n.isPinned(c.Address) || n.isPinnedID(c.publicKey)
+}
+
+// Peers returns a snapshot of all peers this node is connected to, alongside
+// the direction each connection was established in.
+func (n *Network) Peers() []PeerInfo {
+	var peers []PeerInfo
+
+	n.eachPeer(func(client *PeerClient) bool {
+		peers = append(peers, client.info())
+		return true
+	})
+
+	return peers
 }
 
 // ConnectionStateExists returns true if network has a connection on a given address.
@@ -306,6 +1288,80 @@ func (n *Network) BlockUntilListening() {
 	<-n.listeningCh
 }
 
+// Ready returns a channel that is closed once this node is listening for new
+// peers, and all plugins have completed their Startup hooks.
+func (n *Network) Ready() <-chan struct{} {
+	return n.listeningCh
+}
+
+// WaitForPeers blocks until this node is connected to at least count peers,
+// or until the context is done.
+func (n *Network) WaitForPeers(ctx context.Context, count int) error {
+	for {
+		changed := n.peersChangedSignal()
+
+		connected := 0
+		n.eachPeer(func(client *PeerClient) bool {
+			if n.ConnectionStateExists(client.Address) {
+				connected++
+			}
+			return connected < count
+		})
+
+		if connected >= count {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-n.kill:
+			return ErrNetworkClosed
+		}
+	}
+}
+
+// peersChangedSignal returns a channel that is closed upon the next change to
+// the set of connected peers.
+func (n *Network) peersChangedSignal() <-chan struct{} {
+	n.peersChangedMutex.Lock()
+	defer n.peersChangedMutex.Unlock()
+	return n.peersChanged
+}
+
+// notifyPeersChanged wakes up everyone waiting on the set of connected peers.
+func (n *Network) notifyPeersChanged() {
+	n.peersChangedMutex.Lock()
+	close(n.peersChanged)
+	n.peersChanged = make(chan struct{})
+	n.peersChangedMutex.Unlock()
+
+	n.refreshGroupHealth()
+}
+
+// waitUntilReady applies the networks readiness policy to a pending write.
+func (n *Network) waitUntilReady() error {
+	select {
+	case <-n.listeningCh:
+		return nil
+	default:
+	}
+
+	if n.opts.readinessPolicy == FailUntilReady {
+		return ErrNotReady
+	}
+
+	select {
+	case <-n.listeningCh:
+		return nil
+	case <-n.kill:
+		return ErrNetworkClosed
+	case <-time.After(n.opts.writeTimeout):
+		return ErrNotReady
+	}
+}
+
 // Bootstrap with a number of peers and commence a handshake.
 func (n *Network) Bootstrap(addresses ...string) {
 	n.BlockUntilListening()
@@ -329,15 +1385,61 @@ func (n *Network) Bootstrap(addresses ...string) {
 
 // Dial establishes a bidirectional connection to an address, and additionally handshakes with said address.
 func (n *Network) Dial(address string) (net.Conn, error) {
+	conn, _, err := n.dial(address, false)
+	return conn, err
+}
+
+func (n *Network) dial(address string, probe bool) (net.Conn, *handshakeResult, error) {
+	// Legacy peers which connected to us are dialed back without a handshake.
+	if remote := n.legacy.lookup(address); remote != nil && !probe {
+		conn, result, err := n.dialWith(address, func(net.Conn) (*handshakeResult, error) {
+			return legacyResult(remote, 0), nil
+		})
+		if err != nil {
+			n.legacy.forget(address)
+		}
+		return conn, result, err
+	}
+
+	run := func(conn net.Conn) (*handshakeResult, error) {
+		return n.handshakeDialer(conn, address, probe, false)
+	}
+
+	// Peers which handed us an affinity are reconnected to directly first.
+	if !probe {
+		if conn, result, ok := n.dialAffinity(address, run); ok {
+			return conn, result, nil
+		}
+	}
+
+	conn, result, err := n.dialWith(address, run)
+
+	// Peers hanging up on our handshake may be legacy peers.
+	if err != nil && !probe && n.opts.allowLegacyPeers && hungUp(err) {
+		return n.dialWith(address, func(conn net.Conn) (*handshakeResult, error) {
+			return n.probeLegacy(conn, address)
+		})
+	}
+
+	return conn, result, err
+}
+
+// dialWith establishes a connection to an address, and runs a dialer's side
+// of a handshake over it.
+func (n *Network) dialWith(address string, run func(conn net.Conn) (*handshakeResult, error)) (net.Conn, *handshakeResult, error) {
 	addrInfo, err := ParseAddress(address)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if addrInfo.Host != "127.0.0.1" {
+	if err := n.interceptDial(address); err != nil {
+		return nil, nil, err
+	}
+
+	if addrInfo.Path == "" && addrInfo.Host != "127.0.0.1" {
 		host, err := ParseAddress(n.Address)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// check if dialing address is same as its own IP
 		if addrInfo.Host == host.Host {
@@ -351,36 +1453,171 @@ func (n *Network) Dial(address string) (net.Conn, error) {
 		glog.Fatal("invalid protocol: " + addrInfo.Protocol)
 	}
 
-	var conn net.Conn
-	conn, err = t.(transport.Layer).Dial(addrInfo.HostPort())
+	if !n.acquireDial() {
+		return nil, nil, ErrNetworkClosed
+	}
+	defer n.releaseDial()
+
+	if err := n.resources.acquire(SubsystemDials, 0, 1); err != nil {
+		return nil, nil, err
+	}
+
+	raw, err := t.(transport.Layer).Dial(addrInfo.HostPort())
 	if err != nil {
-		return nil, err
+		n.resources.track(SubsystemDials, 0, -1)
+		return nil, nil, err
+	}
+	conn := &accountedConn{Conn: raw, resources: n.resources, subsystem: SubsystemDials}
+
+	handshake, err := n.handshake(conn, DirectionOutbound, run)
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrapf(err, "failed to handshake with %s", address)
+	}
+
+	// Connections which handshook are accounted to the peer they reach.
+	if err := conn.transfer(SubsystemPeers); err != nil {
+		conn.Close()
+		return nil, nil, err
 	}
-	return conn, nil
+
+	secured, err := secure(conn, handshake, n.now)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return secured, handshake, nil
 }
 
 // Accept handles peer registration and processes incoming message streams.
 func (n *Network) Accept(incoming net.Conn) {
+	if err := n.interceptAccept(incoming); err != nil {
+		incoming.Close()
+		return
+	}
+
+	// Track the connection so that Close() may interrupt reading from it.
+	n.incoming.Store(incoming, struct{}{})
+	if n.isClosed() {
+		n.incoming.Delete(incoming)
+		incoming.Close()
+		return
+	}
+
+	defer func() {
+		incoming.Close()
+		n.incoming.Delete(incoming)
+	}()
+
+	// Load is tracked for puzzles to be handed to dialers once past the
+	// thresholds.
+	n.accepts.record(n.now())
+	atomic.AddInt64(&n.pendingHandshakes, 1)
+	conn, run := n.acceptorFor(incoming)
+	handshake, err := n.handshake(conn, DirectionInbound, run)
+	atomic.AddInt64(&n.pendingHandshakes, -1)
+	if err != nil {
+		glog.Errorf("failed to handshake with %s: %v", incoming.RemoteAddr(), err)
+		return
+	}
+
+	if handshake.legacy {
+		n.legacy.learn(handshake.remote)
+	}
+
+	conn, err = secure(conn, handshake, n.now)
+	if err != nil {
+		glog.Errorf("failed to secure connection with %s: %v", incoming.RemoteAddr(), err)
+		return
+	}
+
+	// Ephemeral peers are written to over the connection they dialed, and
+	// every other peer over a connection we dial back.
+	n.serve(conn, DirectionInbound, handshake, func(sender *protobuf.ID) (*PeerClient, error) {
+		if isEphemeralOffer(handshake.offer) {
+			return n.ephemeralClient(conn, handshake)
+		}
+		return n.client(sender.Address, DirectionInbound, false)
+	})
+}
+
+// serve reads messages off a connection established in a direction until it
+// closes, and dispatches them. The client messages are dispatched through is
+// attached on the first message read, given its sender.
+func (n *Network) serve(conn net.Conn, direction ConnDirection, handshake *handshakeResult, attach func(sender *protobuf.ID) (*PeerClient, error)) {
 	var client *PeerClient
 	var clientInit sync.Once
 
 	recvWindow := NewRecvWindow(n.opts.recvWindowSize)
 
+	// The loop is watched once it is known which peer it serves.
+	var h *heartbeat
+
 	// Cleanup connections when we are done with them.
 	defer func() {
-		time.Sleep(1 * time.Second)
+		n.watchdog.unwatch(h)
 
 		if client != nil {
+			// Let in-flight messages get dispatched, unless shutting down.
+			select {
+			case <-time.After(1 * time.Second):
+			case <-n.kill:
+			}
+
 			client.Close()
+			n.limiter.forget(client)
+
+			if direction == DirectionInbound && handshake.session != nil {
+				n.sessions.retainIssued(handshake.session, recvWindow.LocalNonce())
+			}
 		}
 
-		if incoming != nil {
-			incoming.Close()
+		// Hand back the memory held by messages which never made it to dispatch.
+		for _, msg := range recvWindow.Range(func(uint64, interface{}) bool { return true }) {
+			if msg != nil {
+				msg.(*receivedMessage).done()
+			}
 		}
 	}()
 
+	// Carry on from where the sequence numbers of a resumed session left off.
+	if direction == DirectionInbound && handshake.resumed && handshake.session.messageNonce > 0 {
+		recvWindow.SetLocalNonce(handshake.session.messageNonce)
+	}
+
+	// Reads from a dead peer are interrupted by closing its data connection,
+	// which its control connection only ever accompanies.
+	live := conn
+	if handshake.control {
+		live = nil
+	}
+
+	// Control connections are exempt from rate limits, so that pings and
+	// keepalives are answered however much data a peer sends.
+	ip := remoteIP(conn.RemoteAddr())
+
+	// Frames which fail to decode are skipped, unless a peer keeps sending
+	// them; errors reading frames off the connection close it. Frames dropped
+	// by screening are skipped silently.
+	malformed := 0
+
 	for {
-		msg, err := n.receiveMessage(incoming)
+		h.idle()
+
+		msg, err := n.receiveMessage(conn, handshake.remote.PublicKey, handshake.legacy)
+		if err != nil && isScreenedFrame(err) {
+			continue
+		}
+		if err != nil && isMalformedFrame(err) && malformed < maxMalformedFrames {
+			malformed++
+			if client != nil {
+				n.reportViolation(client, err)
+			} else {
+				glog.Errorf("network: skipped malformed frame from %s: %v", conn.RemoteAddr(), err)
+			}
+			continue
+		}
 		if err != nil {
 			if err != errEmptyMsg {
 				glog.Error(err)
@@ -388,44 +1625,83 @@ func (n *Network) Accept(incoming net.Conn) {
 			break
 		}
 
+		n.yield()
+
+		// Only ephemeral peers send messages without an address.
+		if !validSenderAddress(msg.Sender, handshake.offer) {
+			glog.Errorf("message from %s advertises an address %q at odds with its handshake", conn.RemoteAddr(), msg.Sender.Address)
+			msg.done()
+			return
+		}
+
 		// Initialize client if not exists.
 		clientInit.Do(func() {
-			client, err = n.Client(msg.Sender.Address)
+			client, err = attach(msg.Sender)
 			if err != nil {
 				return
 			}
 
-			client.ID = (*peer.ID)(msg.Sender)
+			if !bytes.Equal(msg.Sender.PublicKey, handshake.remote.PublicKey) {
+				err = errors.New("network: message sender does not match handshake")
+				return
+			}
+
+			client.setID((*peer.ID)(msg.Sender))
 
-			if !n.ConnectionStateExists(client.ID.Address) {
+			if !n.ConnectionStateExists(client.Address) && client.successorOf() == nil {
 				err = errors.New("network: failed to load session")
 			}
 
 			client.setIncomingReady()
+
+			client.source.Store(conn.RemoteAddr().String())
+			n.verifyInbound(client, handshake.remote.PublicKey, conn.RemoteAddr().String())
+
+			h = n.watchdog.watch(ComponentReceive, client.Address, conn)
 		})
 
 		if err != nil {
 			glog.Error(err)
+			msg.done()
 			return
 		}
 
-		go func() {
-			// Peer sent message with a completely different ID. Disconnect.
-			if !client.ID.Equals(peer.ID(*msg.Sender)) {
-				glog.Errorf("message signed by peer %s but client is %s", peer.ID(*msg.Sender), client.ID.Address)
-				return
-			}
+		h.beat()
+
+		// Peer sent message with a completely different ID. Disconnect.
+		if id := client.id(); !id.Equals(peer.ID(*msg.Sender)) {
+			glog.Errorf("message signed by peer %s but client is %s", peer.ID(*msg.Sender), id.Address)
+			msg.done()
+			return
+		}
+
+		n.markReceived(client, live)
+		n.countTraffic(client, DirectionInbound, len(msg.raw)+frameHeaderSize(msg.legacy))
+		if handshake.control {
+			atomic.AddUint64(&n.controlReceived, 1)
+		} else {
+			n.limitInbound(client, ip)
+		}
 
-			recvWindow.Push(msg.MessageNonce, msg)
+		// Messages are pushed in the order they are read so that the window
+		// starts off at the first nonce received.
+		if displaced := recvWindow.Push(msg.MessageNonce, msg); displaced != nil {
+			displaced.(*receivedMessage).done()
+		}
+
+		ready := recvWindow.Pop()
+		for _, msg := range ready {
+			frame := msg.(*receivedMessage)
+			job := func() {
+				n.dispatchMessage(client, frame)
+			}
 
-			ready := recvWindow.Pop()
-			for _, msg := range ready {
-				msg := msg
-				client.Submit(func() {
-					n.dispatchMessage(client, msg.(*protobuf.Message))
-				})
+			// Dispatch is skipped once the client closes, though the job still
+			// has to run to hand back the message's memory.
+			if !client.submit(job) {
+				job()
 			}
-		}()
+		}
 	}
 }
 
@@ -440,6 +1716,23 @@ func (n *Network) Plugin(key interface{}) (PluginInterface, bool) {
 // PrepareMessage marshals a message into a *protobuf.Message and signs it with this
 // nodes private key. Errors if the message is null.
 func (n *Network) PrepareMessage(message proto.Message) (*protobuf.Message, error) {
+	return n.prepareMessage("", message)
+}
+
+// prepareMessage marshals and signs a message sent under a protocol tag.
+func (n *Network) prepareMessage(protocol string, message proto.Message) (*protobuf.Message, error) {
+	return n.prepareEnvelope(protocol, message, envelopeFields{})
+}
+
+// envelopeFields are optional fields of an envelope covered by its signature.
+type envelopeFields struct {
+	metadata map[string]string
+	hints    []*protobuf.Hint
+}
+
+// prepareEnvelope marshals and signs a message sent under a protocol tag,
+// carrying fields covered by its signature.
+func (n *Network) prepareEnvelope(protocol string, message proto.Message, fields envelopeFields) (*protobuf.Message, error) {
 	if message == nil {
 		return nil, errors.New("network: message is null")
 	}
@@ -451,47 +1744,147 @@ func (n *Network) PrepareMessage(message proto.Message) (*protobuf.Message, erro
 
 	id := protobuf.ID(n.ID)
 
-	signature, err := n.keys.Sign(
-		n.opts.signaturePolicy,
-		n.opts.hashPolicy,
-		SerializeMessage(&id, raw.Value),
-	)
-	if err != nil {
+	msg := &protobuf.Message{
+		Message:  raw,
+		Sender:   &id,
+		Protocol: protocol,
+	}
+
+	if len(fields.metadata) > 0 {
+		msg.Metadata = make(map[string]string, len(fields.metadata))
+		for key, value := range fields.metadata {
+			msg.Metadata[key] = value
+		}
+	}
+	msg.Hints = fields.hints
+
+	if err := n.runOutboundHooks(PeerInfo{}, msg, true); err != nil {
 		return nil, err
 	}
 
-	msg := &protobuf.Message{
-		Message:   raw,
-		Sender:    &id,
-		Signature: signature,
+	n.stampReplayNonce(msg)
+
+	if err := n.signMessage(msg); err != nil {
+		return nil, err
 	}
 	return msg, nil
 }
 
-// Write asynchronously sends a message to a denoted target address.
+// Write asynchronously sends a message to a denoted target address, dialing
+// it should we not be connected to it as decided by the policy set through
+// DialOnWrite.
 func (n *Network) Write(address string, message *protobuf.Message) error {
+	return n.WriteContext(context.Background(), address, message)
+}
+
+// write sends a message to a denoted target address, taking over counting
+// the bytes the futures it carries were counted as queued for.
+func (n *Network) write(address string, message *protobuf.Message, futures ...*SendFuture) error {
+	if n.isClosed() {
+		return ErrNetworkClosed
+	}
+
+	if err := n.waitUntilReady(); err != nil {
+		return err
+	}
+
 	state, ok := n.ConnectionState(address)
 	if !ok {
-		return errors.New("network: connection does not exist")
+		return errors.Wrapf(ErrNotConnected, "failed to write to %s", address)
+	}
+
+	message, err := n.enrichMessage(address, message)
+	if err != nil {
+		return err
+	}
+
+	if state.control != nil && n.isControlMessage(message) {
+		return n.writeControl(address, state.control, message)
+	}
+
+	if state.legacy {
+		return n.writeLegacy(address, state, message, futures...)
+	}
+
+	if err := n.allowWrite(address); err != nil {
+		return err
 	}
 
 	message.MessageNonce = atomic.AddUint64(&state.messageNonce, 1)
 
-	state.conn.SetWriteDeadline(time.Now().Add(n.opts.writeTimeout))
+	padding := n.padding(address, message, message.Size())
+	size := message.Size() + len(padding) + 4
+
+	// The message counts as queued until it makes it into the buffer.
+	queued := int64(size)
+	state.flow.add(queued - state.flow.release(futures...))
+
+	n.shape(state, message, size)
 
-	err := n.sendMessage(state.writer, message, state.writerMutex)
+	state.conn.SetWriteDeadline(time.Now().Add(n.writeTimeout(address, size)))
+
+	frame, err := n.sendMessage(state.writer, message, state.writerMutex, state.flow, padding)
+	state.flow.add(-queued)
+	n.markWritten(address, err)
 	if err != nil {
 		return err
 	}
+
+	n.tailMessage(DirectionOutbound, address, message, size, true)
+	n.captureOutbound(address, frame, padding)
+	n.mirrorOutbound(address, message, frame, padding)
+
 	return nil
 }
 
-// Broadcast asynchronously broadcasts a message to all peer clients.
+// enrichMessage runs per-peer hooks over a copy of a message and re-signs it,
+// as the same message may be written to many peers.
+func (n *Network) enrichMessage(address string, message *protobuf.Message) (*protobuf.Message, error) {
+	if !n.hasPeerHooks() {
+		return message, nil
+	}
+
+	enriched := *message
+	if message.Metadata != nil {
+		enriched.Metadata = make(map[string]string, len(message.Metadata))
+		for key, value := range message.Metadata {
+			enriched.Metadata[key] = value
+		}
+	}
+	enriched.CriticalExtensions = append([]uint32(nil), message.CriticalExtensions...)
+
+	if err := n.runOutboundHooks(n.peerInfo(address), &enriched, false); err != nil {
+		return nil, err
+	}
+
+	if err := n.signMessage(&enriched); err != nil {
+		return nil, err
+	}
+
+	return &enriched, nil
+}
+
+// Broadcast asynchronously broadcasts a message to all peer clients. The
+// message is signed once and, without per-peer hooks, serialized once into a
+// frame written out to every peer as is; with SendWorkers set, the frame is
+// queued up on every peer's send loop.
 func (n *Network) Broadcast(message proto.Message) {
+	if n.pipeline != nil && !n.hasPeerHooks() {
+		n.broadcastPrepared(message)
+		return
+	}
+
+	signed, err := n.PrepareMessage(message)
+	if err != nil {
+		glog.Warningf("failed to prepare broadcast [err=%s]", err)
+		return
+	}
+	frame := n.shareFrame(signed)
+
 	n.eachPeer(func(client *PeerClient) bool {
-		err := client.Tell(message)
+		err := n.writeBroadcast(client.Address, signed, frame)
 		if err != nil {
-			glog.Warningf("failed to send message to peer %v [err=%s]", client.ID, err)
+			glog.Warningf("failed to send message to peer %v [err=%s]", client.id(), err)
 		}
 		return true
 	})
@@ -503,9 +1896,10 @@ func (n *Network) BroadcastByAddresses(message proto.Message, addresses ...strin
 	if err != nil {
 		return
 	}
+	frame := n.shareFrame(signed)
 
 	for _, address := range addresses {
-		n.Write(address, signed)
+		n.writeBroadcast(address, signed, frame)
 	}
 }
 
@@ -515,10 +1909,85 @@ func (n *Network) BroadcastByIDs(message proto.Message, ids ...peer.ID) {
 	if err != nil {
 		return
 	}
+	frame := n.shareFrame(signed)
 
 	for _, id := range ids {
-		n.Write(id.Address, signed)
+		n.writeBroadcast(id.Address, signed, frame)
+	}
+}
+
+// BroadcastExcept asynchronously broadcasts a message to all peer clients,
+// skipping peers whose public keys match any of the excluded peer IDs. The
+// message is signed only once, and is sent at most once per public key.
+func (n *Network) BroadcastExcept(message proto.Message, excluded ...peer.ID) {
+	n.broadcastExcept("", message, excluded...)
+}
+
+// broadcastExcept broadcasts a message under a protocol tag to all peers which
+// support it, save for excluded peers.
+func (n *Network) broadcastExcept(protocol string, message proto.Message, excluded ...peer.ID) {
+	n.broadcastFanout(protocol, message, 0, nil, excluded...)
+}
+
+// broadcastFanout broadcasts a message under a protocol tag to at most fanout
+// peers which support it picked at random, save for excluded peers, returning
+// how many peers it was sent to. A fanout of zero sends it to all of them.
+// Given a group preference, peers are only picked among healthy ones, from
+// its groups in order.
+func (n *Network) broadcastFanout(protocol string, message proto.Message, fanout int, preference GroupPreference, excluded ...peer.ID) int {
+	signed, err := n.prepareMessage(protocol, message)
+	if err != nil {
+		return 0
+	}
+	frame := n.shareFrame(signed)
+
+	skip := make(map[string]struct{})
+	skipAddresses := make(map[string]struct{})
+	for _, id := range excluded {
+		skip[id.PublicKeyHex()] = struct{}{}
+		skipAddresses[id.Address] = struct{}{}
+	}
+
+	var targets []*PeerClient
+
+	n.eachFanoutPeer(func(client *PeerClient) bool {
+		if !client.SupportsProtocol(protocol) {
+			return true
+		}
+
+		// Peers we have not heard from yet are only known by their address.
+		if id := client.id(); id == nil {
+			if _, excluded := skipAddresses[client.Address]; excluded {
+				return true
+			}
+		} else {
+			key := id.PublicKeyHex()
+			if _, excluded := skip[key]; excluded {
+				return true
+			}
+			skip[key] = struct{}{}
+		}
+
+		targets = append(targets, client)
+		return true
+	})
+
+	if len(preference) > 0 {
+		targets = n.pickPreferred(preference, targets, fanout)
+	} else if fanout > 0 && len(targets) > fanout {
+		rand.Shuffle(len(targets), func(i, j int) {
+			targets[i], targets[j] = targets[j], targets[i]
+		})
+		targets = targets[:fanout]
+	}
+
+	for _, client := range targets {
+		if err := n.writeBroadcast(client.Address, signed, frame); err != nil {
+			glog.Warningf("failed to send message to peer %v [err=%s]", client.id(), err)
+		}
 	}
+
+	return len(targets)
 }
 
 // BroadcastRandomly asynchronously broadcasts a message to random selected K peers.
@@ -526,7 +1995,7 @@ func (n *Network) BroadcastByIDs(message proto.Message, ids ...peer.ID) {
 func (n *Network) BroadcastRandomly(message proto.Message, K int) {
 	var addresses []string
 
-	n.eachPeer(func(client *PeerClient) bool {
+	n.eachFanoutPeer(func(client *PeerClient) bool {
 		addresses = append(addresses, client.Address)
 
 		// Limit total amount of addresses in case we have a lot of peers.
@@ -545,14 +2014,50 @@ func (n *Network) BroadcastRandomly(message proto.Message, K int) {
 	n.BroadcastByAddresses(message, addresses[:K]...)
 }
 
-// Close shuts down the entire network.
-func (n *Network) Close() {
-	close(n.kill)
+// Close shuts down the entire network, releasing its listener, all peer
+// connections and all I/O workers. Plugin Cleanup hooks are invoked once.
+// Calling Close more than once is safe, and returns the first call's result.
+func (n *Network) Close() error {
+	n.closeOnce.Do(func() {
+		n.listenerMutex.Lock()
+		close(n.kill)
+		n.budget.close()
+		if n.listener != nil {
+			n.closeErr = n.listener.Close()
+		}
+		n.listenerMutex.Unlock()
+
+		n.yield()
 
-	n.eachPeer(func(client *PeerClient) bool {
-		client.Close()
-		return true
+		n.eachPeer(func(client *PeerClient) bool {
+			client.Close()
+			return true
+		})
+
+		n.removeOutboxes()
+
+		// Flush out frames captured up until the network closed.
+		if err := n.StopCapture(); err != nil {
+			glog.Warning(err)
+		}
+
+		// Unblock Accept() loops still reading from their connections.
+		n.incoming.Range(func(key, _ interface{}) bool {
+			key.(net.Conn).Close()
+			return true
+		})
+
+		// Handle 'network stops listening' callback for plugins.
+		if atomic.LoadUint32(&n.started) == 1 {
+			n.eachPlugin(func(plugin PluginInterface) {
+				plugin.Cleanup(n)
+			})
+		}
+
+		n.reportOwnershipLeaks()
 	})
+
+	return n.closeErr
 }
 
 func (n *Network) eachPeer(fn func(client *PeerClient) bool) {
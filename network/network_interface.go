@@ -1,7 +1,11 @@
 package network
 
 import (
+	"context"
+	"io"
 	"net"
+	"os"
+	"time"
 
 	"github.com/perlin-network/noise/crypto"
 	"github.com/perlin-network/noise/internal/protobuf"
@@ -25,9 +29,24 @@ type NetworkInterface interface {
 	// Client either creates or returns a cached peer client given its host address.
 	Client(address string) (*PeerClient, error)
 
+	// ReservedClient is equivalent to Client, though it may additionally draw
+	// from the reserved peer slots should the outbound quota be exhausted.
+	ReservedClient(address string) (*PeerClient, error)
+
+	// Peers returns a snapshot of all peers this node is connected to.
+	Peers() []PeerInfo
+
 	// BlockUntilListening blocks until this node is listening for new peers.
 	BlockUntilListening()
 
+	// Ready returns a channel that is closed once this node is listening for new
+	// peers, and all plugins have completed their Startup hooks.
+	Ready() <-chan struct{}
+
+	// WaitForPeers blocks until this node is connected to at least count peers,
+	// or until the context is done.
+	WaitForPeers(ctx context.Context, count int) error
+
 	// Bootstrap with a number of peers and commence a handshake.
 	Bootstrap(addresses ...string)
 
@@ -50,6 +69,68 @@ type NetworkInterface interface {
 	// Write asynchronously sends a message to a denoted target address.
 	Write(address string, message *protobuf.Message) error
 
+	// WriteTo sends a message to a connected peer denoted by its ID.
+	WriteTo(id PeerID, message *protobuf.Message) error
+
+	// FindClosestPeers looks up the k peers closest to an arbitrary key across the network.
+	FindClosestPeers(ctx context.Context, key []byte, k int) ([]PeerInfo, error)
+
+	// WriteToward sends a message to the connected peer closest to a key.
+	WriteToward(ctx context.Context, key []byte, message proto.Message, opts TowardOptions) (PeerInfo, error)
+
+	// Ping sends a peer a ping right away, returning the round trip measured.
+	Ping(ctx context.Context, address string) (time.Duration, error)
+
+	// PingAll pings every connected peer, returning the outcome of every ping.
+	PingAll(ctx context.Context) map[string]PingResult
+
+	// Reachability returns whether peers are able to dial us at the address
+	// we advertise, as judged by reachability probes.
+	Reachability() Reachability
+
+	// AnnounceUpgrade announces to all peers that we will require a protocol
+	// version from activation onward.
+	AnnounceUpgrade(version uint32, activation time.Time, note string) error
+
+	// UpgradeStatus returns which upgrades connected peers announced.
+	UpgradeStatus() UpgradeStatus
+
+	// SamplePeers returns up to count nodes sampled at random from across
+	// the whole network by peer sampling.
+	SamplePeers(count int) []peer.ID
+
+	// RequestPeerDiagnostics asks a peer for what it measured of its session
+	// with us, should it share diagnostics with us.
+	RequestPeerDiagnostics(ctx context.Context, address string) (PeerDiagnostics, error)
+
+	// BootstrapCandidates dials candidates from the address book, best first,
+	// until the node is connected to at least target peers.
+	BootstrapCandidates(ctx context.Context, target int) (BootstrapResult, error)
+
+	// PeerByID returns the client of a connected peer by its ID.
+	PeerByID(id PeerID) (*PeerClient, bool)
+
+	// Warm dials and handshakes with peers in the background ahead of the first write to them.
+	Warm(ctx context.Context, addresses ...string)
+
+	// WarmAll dials and handshakes with peers, blocking until all of them were dialed.
+	WarmAll(ctx context.Context, addresses ...string) error
+
+	// WriteAsync queues up a message to be sent to a denoted target address without
+	// blocking, returning a future resolved once the message was written out.
+	WriteAsync(address string, message *protobuf.Message, opts ...WriteOption) (*SendFuture, error)
+
+	// SendAsync queues up a message to be signed and sent to a denoted target address
+	// without blocking, returning a future resolved once the message was written out.
+	SendAsync(address string, message proto.Message, opts ...WriteOption) (*SendFuture, error)
+
+	// PeerSendBudget returns how much data bound for the peer at an address is
+	// backed up on our side, and how much more may be sent before it is held back.
+	PeerSendBudget(address string) (SendBudget, bool)
+
+	// WriteBatch sends a list of messages to a denoted target address under a single signed envelope.
+	WriteBatch(address string, messages []proto.Message) error
+
 	// Broadcast asynchronously broadcasts a message to all peer clients.
 	Broadcast(message proto.Message)
 
@@ -59,10 +140,182 @@ type NetworkInterface interface {
 	// BroadcastByIDs broadcasts a message to a set of peer clients denoted by their peer IDs.
 	BroadcastByIDs(message proto.Message, ids ...peer.ID)
 
+	// BroadcastExcept asynchronously broadcasts a message to all peer clients,
+	// skipping peers whose public keys match any of the excluded peer IDs.
+	BroadcastExcept(message proto.Message, excluded ...peer.ID)
+
 	// BroadcastRandomly asynchronously broadcasts a message to random selected K peers.
 	// Does not guarantee broadcasting to exactly K peers.
 	BroadcastRandomly(message proto.Message, K int)
 
-	// Close shuts down the entire network.
-	Close()
+	// TailMessages subscribes to summaries of all messages sent or received matching a filter.
+	TailMessages(ctx context.Context, filter TailFilter) *MessageTail
+
+	// SubscribeEvents subscribes to events published on the network's event bus matching a filter.
+	SubscribeEvents(filter EventFilter, opts ...SubscribeOption) (*EventSubscription, func())
+
+	// EventStats returns how many events were published, dropped and vetoed.
+	EventStats() EventStats
+
+	// CompactionStats returns how many expired entries were swept from the internal state of every component.
+	CompactionStats() CompactionStats
+
+	// HandshakeStats returns the number of handshakes aborted so far.
+	HandshakeStats() HandshakeStats
+
+	// Profile returns the preset the network was built with, and which of its settings were overridden.
+	Profile() Profile
+
+	// ListenerFile returns a duplicate of the listener's file descriptor for a successor process to adopt.
+	ListenerFile() (*os.File, error)
+
+	// ExportHandoverState hands the node over to a successor, serializing its state for it to import.
+	ExportHandoverState(retryAfter time.Duration) ([]byte, error)
+
+	// ImportHandoverState takes over the state exported by a predecessor of the same keys and address.
+	ImportHandoverState(serialized []byte) error
+
+	// ReceiveBudgetStats returns how much of the receive memory budget is in use.
+	ReceiveBudgetStats() ReceiveBudgetStats
+
+	// SetBandwidthLimit changes the outbound bandwidth limit across all peers.
+	SetBandwidthLimit(bytesPerSecond, burst int)
+
+	// SetPeerBandwidthLimit changes the outbound bandwidth limit of every peer.
+	SetPeerBandwidthLimit(bytesPerSecond, burst int)
+
+	// ShapingStats returns how many writes were delayed to keep within bandwidth limits.
+	ShapingStats() ShapingStats
+
+	// PaddingStats returns how many frames were padded, and how many bytes of padding were sent and received.
+	PaddingStats() PaddingStats
+
+	// TagPeer tags a peer by its ID, whether or not it is connected.
+	TagPeer(id PeerID, tag string) error
+
+	// UntagPeer removes a tag from a peer, if it holds it.
+	UntagPeer(id PeerID, tag string)
+
+	// PeerTags returns the tags of a peer in sorted order.
+	PeerTags(id PeerID) []string
+
+	// PeersWithTag returns the IDs of all peers tagged with tag, connected or not.
+	PeersWithTag(tag string) []PeerID
+
+	// TagStats returns the messages sent to and received from peers while they were tagged with tag.
+	TagStats(tag string) MessageCounts
+
+	// BroadcastFilter asynchronously broadcasts a message to all peers selected by filter.
+	BroadcastFilter(message proto.Message, filter PeerFilter)
+
+	// RequestTableStats returns how many requests await replies, and how many were failed by the pending request table.
+	RequestTableStats() RequestTableStats
+
+	// AdmissionStats returns how many messages are queued to be sent, and how many writes were admitted or shed by priority.
+	AdmissionStats() AdmissionStats
+
+	// WriteContext sends a message to a denoted target address, dialing it as decided by the dial policy.
+	WriteContext(ctx context.Context, address string, message *protobuf.Message, opts ...WriteOption) error
+
+	// VerificationStats returns how often signature checks were skipped for messages received before.
+	VerificationStats() VerificationStats
+
+	// Stats returns the counts and rates of messages sent and received by type.
+	Stats() *Stats
+
+	// DispatchStats returns the load on the dispatch worker pool.
+	DispatchStats() DispatchStats
+
+	// AddressStatus returns whether a peer was proven to be reachable at an address.
+	AddressStatus(publicKey []byte, address string) AddressStatus
+
+	// VerifyAddress asynchronously dials a peer back at an address it advertises.
+	VerifyAddress(publicKey []byte, address string)
+
+	// QueueMessage queues up a message to be sent to a peer whenever it is connected.
+	QueueMessage(address string, message proto.Message) error
+
+	// DropQueue drops all messages queued up for a peer.
+	DropQueue(address string)
+
+	// SpillStats returns how many messages queued up for peers spilled over to disk.
+	SpillStats() SpillStats
+
+	// DeadlineStats returns how many replies were skipped as their request's deadline had passed.
+	DeadlineStats() DeadlineStats
+
+	// FlushDedupe forgets every message of a type delivered so far, so that copies of them are delivered again.
+	FlushDedupe(message proto.Message)
+
+	// DedupeStats returns how many messages were dropped as copies of messages delivered recently.
+	DedupeStats() DedupeStats
+
+	// ValidationStats returns how many frames received were dropped by each tier of validation.
+	ValidationStats() ValidationStats
+
+	// RegisterService advertises a named service to all peers.
+	RegisterService(name string, address string, metadata map[string][]byte) error
+
+	// UnregisterService stops advertising a named service to all peers.
+	UnregisterService(name string) error
+
+	// PeerServices returns the services a connected peer advertises, by name.
+	PeerServices(id PeerID) map[string]ServiceRecord
+
+	// StartCapture starts writing every frame sent and received to a writer.
+	StartCapture(w io.Writer, maxBytes int64) error
+
+	// StartCaptureFiles starts writing every frame sent and received to rotated files within a directory.
+	StartCaptureFiles(dir string, limits CaptureLimits) error
+
+	// StopCapture stops the capture running, if any, flushing out all frames captured so far.
+	StopCapture() error
+
+	// CaptureStats returns the number of frames captured so far.
+	CaptureStats() CaptureStats
+
+	// AddMirrorRule registers a rule mirroring matching frames sent and received to a sink.
+	AddMirrorRule(rule MirrorRule) (uint64, error)
+
+	// RemoveMirrorRule removes a mirror rule by its ID.
+	RemoveMirrorRule(id uint64) bool
+
+	// MirrorStats returns the number of frames mirrored and dropped so far.
+	MirrorStats() MirrorStats
+
+	// RevokeAffinity stops handing out affinity tokens, and tells peers holding one to stop using it.
+	RevokeAffinity() int
+
+	// AffinityStats returns the number of affinity tokens issued and revoked, and of reconnects made using them.
+	AffinityStats() AffinityStats
+
+	// ReplayFrame feeds a frame as received from a peer through the network's plugins without any sockets.
+	ReplayFrame(frame []byte) (*ReplayResult, error)
+
+	// ControlPlaneStats returns how many peers are sent control messages over a connection of their own.
+	ControlPlaneStats() ControlPlaneStats
+
+	// RejectionStats returns how many rejections were sent, suppressed and received.
+	RejectionStats() RejectionStats
+
+	// SetRateLimits changes the limits on the rate of messages read from peers at runtime.
+	SetRateLimits(limits RateLimits) error
+
+	// RateLimits returns the limits on the rate of messages read from peers.
+	RateLimits() RateLimits
+
+	// RateLimitStats returns how many messages read from peers were delayed by every level of the rate limits.
+	RateLimitStats() RateLimitStats
+
+	// SignatureStats returns how many connected peers demonstrated each signature scheme.
+	SignatureStats() SignatureStats
+
+	// IsLight returns true should the node run in light mode.
+	IsLight() bool
+
+	// IsEphemeral returns true should the node run without a listenable address.
+	IsEphemeral() bool
+
+	// Close shuts down the entire network. Calling Close more than once is safe.
+	Close() error
 }
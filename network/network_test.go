@@ -4,8 +4,11 @@ import (
 	"testing"
 	"time"
 
+	noiseprotobuf "github.com/perlin-network/noise/internal/protobuf"
 	"github.com/perlin-network/noise/internal/test/protobuf"
 	"github.com/perlin-network/noise/network"
+	"github.com/perlin-network/noise/network/discovery"
+	"github.com/perlin-network/noise/network/rpc"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -134,3 +137,52 @@ func testNodeBroadcastByAddresses(t *testing.T, e env, numNodes, numPeers int) {
 		}
 	}
 }
+
+func TestLightNodeStaysOutOfRoutes(t *testing.T) {
+	t.Parallel()
+
+	te := newTest(t, tcpEnv)
+	te.startBoostrap(3)
+	defer te.tearDown()
+
+	builder := network.NewBuilderWithOptions(network.LightMode())
+	builder.SetAddress(network.FormatAddress("tcp", "localhost", uint16(network.GetRandomUnusedPort())))
+	builder.AddPlugin(new(discovery.Plugin))
+	builder.AddPlugin(new(MailBoxPlugin))
+
+	light, err := builder.Build()
+	assert.Nil(t, err)
+	defer light.Close()
+
+	go light.Listen()
+	<-light.Ready()
+
+	light.Bootstrap(te.bootstrapNode.Address)
+	for !te.bootstrapNode.ConnectionStateExists(light.Address) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Light nodes still receive messages, though they neither learn of other
+	// peers nor are learnt of.
+	te.bootstrapNode.Broadcast(&protobuf.TestMessage{Message: "hello"})
+	select {
+	case received := <-te.getMailbox(light).RecvMailbox:
+		assert.Equal(t, "hello", received.Message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("light node did not receive broadcast")
+	}
+
+	assert.Empty(t, te.getPeers(light))
+	for _, node := range append(te.nodes, te.bootstrapNode) {
+		assert.False(t, isIn(light.Address, te.getPeers(node)...))
+	}
+
+	// Lookups are answered, though with no peers.
+	client, err := te.bootstrapNode.Client(light.Address)
+	assert.Nil(t, err)
+
+	response, err := client.Request(&rpc.Request{Message: &noiseprotobuf.LookupNodeRequest{Target: &noiseprotobuf.ID{Id: light.ID.Id}}, Timeout: 3 * time.Second})
+	assert.Nil(t, err)
+	assert.Empty(t, response.(*noiseprotobuf.LookupNodeResponse).Peers)
+	assert.Empty(t, response.(*noiseprotobuf.LookupNodeResponse).Compact)
+}
@@ -23,7 +23,8 @@ type env struct {
 var (
 	kcpEnv          = env{name: "kcp-blake2b-ed25519", networkType: "kcp", hash: blake2b.New(), signature: ed25519.New()}
 	tcpEnv          = env{name: "tcp-blake2b-ed25519", networkType: "tcp", hash: blake2b.New(), signature: ed25519.New()}
-	allEnvs         = []env{kcpEnv, tcpEnv}
+	wsEnv           = env{name: "ws-blake2b-ed25519", networkType: "ws", hash: blake2b.New(), signature: ed25519.New()}
+	allEnvs         = []env{kcpEnv, tcpEnv, wsEnv}
 	mailboxPluginID = (*MailBoxPlugin)(nil)
 )
 
@@ -68,7 +69,7 @@ func (te *testSuite) startBoostrap(numNodes int, plugins ...network.PluginInterf
 
 		if i == 0 {
 			te.bootstrapNode = node
-			node.BlockUntilListening()
+			<-node.Ready()
 		} else {
 			te.nodes = append(te.nodes, node)
 		}
@@ -0,0 +1,288 @@
+package network
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// SpillStats counts messages queued up for peers which spilled over to disk.
+type SpillStats struct {
+	// Spilled is the number of messages written to spill logs.
+	Spilled uint64
+	// Corrupted is the number of spilled messages skipped as they failed
+	// their check when read back.
+	Corrupted uint64
+	// Bytes is the size of spill logs left on disk across all peers.
+	Bytes int64
+}
+
+// outbox holds messages queued up for a peer through QueueMessage, whether or
+// not the peer is connected, until they are written out. Past a threshold of
+// bytes held in memory, messages spill over to a log on disk, being read back
+// once all messages held in memory were written out.
+type outbox struct {
+	sync.Mutex
+
+	address string
+
+	memory      [][]byte
+	memoryBytes int
+
+	// spill is nil unless messages spill over to disk.
+	spill *spillLog
+
+	kick    chan struct{}
+	removed bool
+}
+
+// push queues up a serialized message, spilling it over to disk should
+// more than limit bytes be held in memory. Without a spill log, at most
+// window messages may be queued up. It returns whether the message spilled.
+func (o *outbox) push(record []byte, limit int, window int) (bool, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.removed {
+		return false, errors.New("network: queue was dropped")
+	}
+
+	// Messages go to disk for as long as earlier ones are there, so that they
+	// are read back in order.
+	if o.spill != nil && (o.spill.len() > 0 || o.memoryBytes+len(record) > limit) {
+		return true, o.spill.append(record)
+	}
+
+	if o.spill == nil && window > 0 && len(o.memory) >= window {
+		return false, ErrSendWindowFull
+	}
+
+	o.memory = append(o.memory, record)
+	o.memoryBytes += len(record)
+
+	return false, nil
+}
+
+// peek returns the next serialized message to write out, alongside how many
+// corrupted messages were skipped reading it back from disk.
+func (o *outbox) peek() (record []byte, skipped int, err error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.removed {
+		return nil, 0, nil
+	}
+
+	if len(o.memory) > 0 {
+		return o.memory[0], 0, nil
+	}
+
+	if o.spill == nil {
+		return nil, 0, nil
+	}
+
+	return o.spill.peek()
+}
+
+// pop consumes the serialized message returned by peek once it was written
+// out.
+func (o *outbox) pop() {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.removed {
+		return
+	}
+
+	if len(o.memory) > 0 {
+		o.memoryBytes -= len(o.memory[0])
+		o.memory[0] = nil
+		o.memory = o.memory[1:]
+		return
+	}
+
+	if o.spill != nil {
+		o.spill.pop()
+	}
+}
+
+// signal wakes up the outbox's drain loop.
+func (o *outbox) signal() {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+// remove drops all queued messages, and deletes the outbox's spill log.
+func (o *outbox) remove() {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.removed {
+		return
+	}
+	o.removed = true
+	o.memory, o.memoryBytes = nil, 0
+
+	if o.spill != nil {
+		if err := o.spill.remove(); err != nil {
+			glog.Warningf("failed to delete messages spilled for %s: %v", o.address, err)
+		}
+	}
+}
+
+// outbox returns the outbox of a peer, creating it should it not exist.
+func (n *Network) outbox(address string) *outbox {
+	if o, exists := n.outboxes.Load(address); exists {
+		return o.(*outbox)
+	}
+
+	o := &outbox{address: address, kick: make(chan struct{}, 1)}
+	if n.opts.spillDir != "" {
+		o.spill = newSpillLog(filepath.Join(n.opts.spillDir, hex.EncodeToString([]byte(address))))
+	}
+
+	existing, loaded := n.outboxes.LoadOrStore(address, o)
+	if !loaded {
+		n.spawn(func() { n.drainOutbox(o) })
+	}
+	return existing.(*outbox)
+}
+
+// drainOutbox writes out the messages queued up in an outbox whenever its
+// peer is connected, until the outbox is dropped or the network is closed.
+func (n *Network) drainOutbox(o *outbox) {
+	for {
+		select {
+		case <-o.kick:
+		case <-n.kill:
+			return
+		}
+
+		for n.ConnectionStateExists(o.address) {
+			record, skipped, err := o.peek()
+			if skipped > 0 {
+				glog.Warningf("skipped %d corrupted messages spilled for %s", skipped, o.address)
+				atomic.AddUint64(&n.spillCorrupted, uint64(skipped))
+			}
+			if err != nil {
+				glog.Warningf("failed to read messages spilled for %s: %v", o.address, err)
+				break
+			}
+			if record == nil {
+				break
+			}
+
+			msg := new(protobuf.Message)
+			if err := msg.Unmarshal(record); err != nil {
+				glog.Warningf("dropping malformed message queued for %s: %v", o.address, err)
+				o.pop()
+				continue
+			}
+
+			// Messages which failed to be written out are retried once the
+			// peer reconnects.
+			if err := n.Write(o.address, msg); err != nil {
+				glog.Warningf("failed to write message queued for %s: %v", o.address, err)
+				break
+			}
+			o.pop()
+		}
+
+		o.Lock()
+		removed := o.removed
+		o.Unlock()
+		if removed {
+			return
+		}
+	}
+}
+
+// QueueMessage signs a message and queues it up to be sent to a peer,
+// whether or not it is connected. Queued messages are written out in order
+// whenever the peer is connected, and are retried once it reconnects should
+// they fail to be. Past SpillOver's memory threshold, queued messages spill
+// over to disk; without SpillOver, at most SendWindowSize messages may be
+// queued up for a peer.
+func (n *Network) QueueMessage(address string, message proto.Message) error {
+	if n.isClosed() {
+		return ErrNetworkClosed
+	}
+
+	signed, err := n.PrepareMessage(message)
+	if err != nil {
+		return err
+	}
+
+	record, err := encodeBody(signed)
+	if err != nil {
+		return err
+	}
+
+	o := n.outbox(address)
+
+	spilled, err := o.push(record, n.opts.spillMemoryBytes, n.opts.sendWindowSize)
+	if err != nil {
+		return err
+	}
+	if spilled {
+		atomic.AddUint64(&n.spilled, 1)
+	}
+	o.signal()
+
+	return nil
+}
+
+// DropQueue drops all messages queued up for a peer through QueueMessage,
+// deleting those spilled over to disk.
+func (n *Network) DropQueue(address string) {
+	if o, exists := n.outboxes.Load(address); exists {
+		n.outboxes.Delete(address)
+		o.(*outbox).remove()
+		o.(*outbox).signal()
+	}
+}
+
+// SpillStats returns how many messages queued up for peers spilled over to
+// disk.
+func (n *Network) SpillStats() SpillStats {
+	stats := SpillStats{
+		Spilled:   atomic.LoadUint64(&n.spilled),
+		Corrupted: atomic.LoadUint64(&n.spillCorrupted),
+	}
+
+	n.outboxes.Range(func(_, value interface{}) bool {
+		o := value.(*outbox)
+		o.Lock()
+		if o.spill != nil {
+			stats.Bytes += o.spill.bytes()
+		}
+		o.Unlock()
+		return true
+	})
+
+	return stats
+}
+
+// resumeOutbox wakes up the outbox of a peer which just connected.
+func (n *Network) resumeOutbox(address string) {
+	if o, exists := n.outboxes.Load(address); exists {
+		o.(*outbox).signal()
+	}
+}
+
+// removeOutboxes drops all queued messages once the network is closed.
+func (n *Network) removeOutboxes() {
+	n.outboxes.Range(func(key, value interface{}) bool {
+		n.outboxes.Delete(key)
+		value.(*outbox).remove()
+		return true
+	})
+}
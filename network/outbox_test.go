@@ -0,0 +1,178 @@
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// heapInUse returns the bytes of heap in use once garbage was collected.
+func heapInUse() uint64 {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// buildDownNode builds a node which is not listening yet, running fn on every
+// test message received once it does, in the order they were received.
+func buildDownNode(t *testing.T, fn func(msg *testpb.TestMessage)) *Network {
+	builder := NewBuilderWithOptions(OrderedHandlers(&testpb.TestMessage{}))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			fn(msg)
+		}
+	}})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	return node
+}
+
+// Not parallel, as heap usage is measured.
+func TestQueueSpillsOverToDisk(t *testing.T) {
+	const (
+		total     = 50 * 1024 * 1024
+		size      = 32 * 1024
+		threshold = 1024 * 1024
+	)
+	count := total / size
+
+	dir, err := ioutil.TempDir("", "spill")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	received := make(chan string, count)
+	receiver := buildDownNode(t, func(msg *testpb.TestMessage) {
+		received <- msg.Message[:strings.IndexByte(msg.Message, ' ')]
+	})
+	defer receiver.Close()
+
+	sender := buildListeningNode(t, SpillOver(dir, threshold))
+	defer sender.Close()
+
+	before := heapInUse()
+
+	padding := strings.Repeat("x", size)
+	for i := 0; i < count; i++ {
+		assert.Nil(t, sender.QueueMessage(receiver.Address, &testpb.TestMessage{Message: fmt.Sprintf("%d %s", i, padding)}))
+	}
+
+	// Only up to the threshold is held in memory while the peer is down.
+	assert.True(t, heapInUse() < before+8*threshold, "queued messages should spill over to disk")
+	stats := sender.SpillStats()
+	assert.True(t, stats.Spilled >= uint64(count)-threshold/size)
+	assert.True(t, stats.Bytes >= int64(total-threshold))
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	for i := 0; i < count; i++ {
+		select {
+		case index := <-received:
+			if !assert.Equal(t, fmt.Sprint(i), index, "messages should be delivered in order") {
+				return
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("only %d of %d queued messages were delivered", i, count)
+		}
+	}
+
+	// Segments are deleted once written out.
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return sender.SpillStats().Bytes == 0
+	}))
+	assert.Equal(t, uint64(0), sender.SpillStats().Corrupted)
+}
+
+func TestQueueSkipsCorruptedSpills(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "spill")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	received := make(chan string, 8)
+	receiver := buildDownNode(t, func(msg *testpb.TestMessage) {
+		received <- msg.Message
+	})
+	defer receiver.Close()
+
+	sender := buildListeningNode(t, SpillOver(dir, 0))
+	defer sender.Close()
+
+	for _, message := range []string{"first", "second", "third"} {
+		assert.Nil(t, sender.QueueMessage(receiver.Address, &testpb.TestMessage{Message: message}))
+	}
+
+	// Flip a byte within the second spilled record.
+	segments, err := filepath.Glob(filepath.Join(dir, "*", "*.log"))
+	assert.Nil(t, err)
+	assert.Len(t, segments, 1)
+
+	data, err := ioutil.ReadFile(segments[0])
+	assert.Nil(t, err)
+	data[len(data)/2] ^= 0xff
+	assert.Nil(t, ioutil.WriteFile(segments[0], data, 0600))
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	for _, expected := range []string{"first", "third"} {
+		select {
+		case message := <-received:
+			assert.Equal(t, expected, message)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("queued message %q was not delivered", expected)
+		}
+	}
+	assert.Equal(t, uint64(1), sender.SpillStats().Corrupted)
+}
+
+func TestQueueSpillsAreCleanedUp(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "spill")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	node := buildListeningNode(t, SpillOver(dir, 0))
+
+	for _, address := range []string{"tcp://127.0.0.1:1", "tcp://127.0.0.1:2"} {
+		assert.Nil(t, node.QueueMessage(address, &testpb.TestMessage{Message: "hello"}))
+	}
+
+	spills, _ := ioutil.ReadDir(dir)
+	assert.Len(t, spills, 2)
+
+	// Dropping a peer's queue deletes its spills.
+	node.DropQueue("tcp://127.0.0.1:1")
+	spills, _ = ioutil.ReadDir(dir)
+	assert.Len(t, spills, 1)
+
+	// Closing the network deletes all spills.
+	node.Close()
+	spills, _ = ioutil.ReadDir(dir)
+	assert.Len(t, spills, 0)
+
+	assert.Equal(t, ErrNetworkClosed, node.QueueMessage("tcp://127.0.0.1:2", &testpb.TestMessage{Message: "hello"}))
+}
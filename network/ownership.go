@@ -0,0 +1,100 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+)
+
+// Networks built with the noiseaudit build tag track the ownership of the
+// plugin contexts they pool and of the frames they hold against the receive
+// budget, panicking with an OwnershipError should either be used or released
+// after it was released, and reporting those still held on Close. Built
+// without it, the tracking compiles away.
+
+// OwnershipError is what networks built with the noiseaudit build tag panic
+// with should an object be used or released after it was released.
+type OwnershipError struct {
+	// Kind is the kind of object misused, such as "plugin context".
+	Kind string
+	// Op is the misuse, either "use" or "release".
+	Op string
+	// Generation tells apart objects acquired by the same network, counting
+	// up from one.
+	Generation uint64
+	// AcquiredAt and ReleasedAt are the stacks the object was last acquired
+	// and released at.
+	AcquiredAt string
+	ReleasedAt string
+}
+
+func (e *OwnershipError) Error() string {
+	return fmt.Sprintf("network: %s of %s (generation %d) after release\nacquired at:\n%s\nreleased at:\n%s", e.Op, e.Kind, e.Generation, e.AcquiredAt, e.ReleasedAt)
+}
+
+// OwnershipLeak is an object still held as a network built with the
+// noiseaudit build tag closed.
+type OwnershipLeak struct {
+	Kind       string
+	Generation uint64
+	// AcquiredAt is the stack the object was acquired at.
+	AcquiredAt string
+}
+
+// OwnershipAudited returns true should the network be built with the
+// noiseaudit build tag.
+func OwnershipAudited() bool {
+	return auditOwnership
+}
+
+// OwnershipLeaks returns the objects acquired by the network which were not
+// released yet, oldest first. It is always empty unless the network is built
+// with the noiseaudit build tag.
+func (n *Network) OwnershipLeaks() []OwnershipLeak {
+	return n.owned.leaks()
+}
+
+// ownershipLeakGrace is how long a closing network waits for the handlers
+// still running, or left queued up on its peers, to release what they hold
+// before reporting it as leaked.
+const ownershipLeakGrace = time.Second
+
+// reportOwnershipLeaks logs every object still held as the network closes.
+func (n *Network) reportOwnershipLeaks() {
+	if !auditOwnership {
+		return
+	}
+
+	deadline := time.Now().Add(ownershipLeakGrace)
+	for len(n.OwnershipLeaks()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, leak := range n.OwnershipLeaks() {
+		glog.Errorf("network: %s (generation %d) still held on close, acquired at:\n%s", leak.Kind, leak.Generation, leak.AcquiredAt)
+	}
+}
+
+// poisonedPayload is what released contexts hold in place of their payload
+// while auditing ownership.
+var poisonedPayload = &types.Any{TypeUrl: "noise/poisoned", Value: []byte{0xde, 0xad, 0xbe, 0xef}}
+
+// releaseContext hands a context back to the pool once its handlers returned.
+func releaseContext(ctx *PluginContext) {
+	if ctx.cancel != nil {
+		ctx.cancel()
+	}
+
+	if auditOwnership {
+		// Released contexts are never reused while auditing, so that every
+		// later use of them is caught rather than seeing another message.
+		ctx.reset("", poisonedPayload)
+		ctx.client, ctx.frame = nil, nil
+		ctx.own.release()
+		return
+	}
+
+	contextPool.Put(ctx)
+}
@@ -0,0 +1,110 @@
+//go:build noiseaudit
+
+package network
+
+import (
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+const auditOwnership = true
+
+// ownershipRegistry keeps track of the objects a network acquired and did not
+// release yet.
+type ownershipRegistry struct {
+	sync.Mutex
+	generation uint64
+	held       map[*ownership]struct{}
+}
+
+func (r *ownershipRegistry) leaks() []OwnershipLeak {
+	r.Lock()
+	defer r.Unlock()
+
+	leaks := make([]OwnershipLeak, 0, len(r.held))
+	for o := range r.held {
+		o.Lock()
+		leaks = append(leaks, OwnershipLeak{Kind: o.kind, Generation: o.generation, AcquiredAt: o.acquiredAt})
+		o.Unlock()
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].Generation < leaks[j].Generation })
+
+	return leaks
+}
+
+// ownership tracks who acquired and released an object. Objects never
+// acquired are never checked.
+type ownership struct {
+	sync.Mutex
+	registry   *ownershipRegistry
+	kind       string
+	generation uint64
+	released   bool
+	acquiredAt string
+	releasedAt string
+}
+
+// acquire marks an object as held, under a new generation.
+func (o *ownership) acquire(registry *ownershipRegistry, kind string) {
+	registry.Lock()
+	registry.generation++
+	generation := registry.generation
+	if registry.held == nil {
+		registry.held = make(map[*ownership]struct{})
+	}
+	registry.held[o] = struct{}{}
+	registry.Unlock()
+
+	o.Lock()
+	o.registry = registry
+	o.kind = kind
+	o.generation = generation
+	o.released = false
+	o.acquiredAt = string(debug.Stack())
+	o.releasedAt = ""
+	o.Unlock()
+}
+
+// release marks an object as released, panicking should it be already.
+func (o *ownership) release() {
+	o.Lock()
+	if o.registry == nil {
+		o.Unlock()
+		return
+	}
+	if o.released {
+		err := o.misuse("release")
+		o.Unlock()
+		panic(err)
+	}
+	o.released = true
+	o.releasedAt = string(debug.Stack())
+	registry := o.registry
+	o.Unlock()
+
+	registry.Lock()
+	delete(registry.held, o)
+	registry.Unlock()
+}
+
+// check panics should an object be used after it was released.
+func (o *ownership) check() {
+	o.Lock()
+	if o.released {
+		err := o.misuse("use")
+		o.Unlock()
+		panic(err)
+	}
+	o.Unlock()
+}
+
+func (o *ownership) misuse(op string) *OwnershipError {
+	return &OwnershipError{
+		Kind:       o.kind,
+		Op:         op,
+		Generation: o.generation,
+		AcquiredAt: o.acquiredAt,
+		ReleasedAt: o.releasedAt,
+	}
+}
@@ -0,0 +1,124 @@
+//go:build noiseaudit
+
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// misuse runs fn, returning what it panicked with should it be an
+// OwnershipError.
+func misuse(fn func()) (err *OwnershipError) {
+	defer func() {
+		err, _ = recover().(*OwnershipError)
+	}()
+	fn()
+	return nil
+}
+
+// assertStacks asserts that an ownership error carries the stacks the object
+// was acquired and released at.
+func assertStacks(t *testing.T, err *OwnershipError, acquiredIn, releasedIn string) {
+	assert.True(t, strings.Contains(err.AcquiredAt, acquiredIn), "acquired at:\n%s", err.AcquiredAt)
+	assert.True(t, strings.Contains(err.ReleasedAt, releasedIn), "released at:\n%s", err.ReleasedAt)
+	assert.True(t, strings.Contains(err.Error(), err.AcquiredAt))
+	assert.True(t, strings.Contains(err.Error(), err.ReleasedAt))
+}
+
+func TestContextUsedAfterRelease(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, OwnershipAudited())
+
+	retained := make(chan *PluginContext, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		retained <- ctx
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "retained"}))
+
+	var ctx *PluginContext
+	select {
+	case ctx = <-retained:
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never handled")
+	}
+
+	// The context is released once the handler returns, and poisoned rather
+	// than reused, so that retaining it past then is caught.
+	var err *OwnershipError
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		err = misuse(func() { ctx.MessageName() })
+		return err != nil
+	}), "context was never released")
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "plugin context", err.Kind)
+		assert.Equal(t, "use", err.Op)
+		assertStacks(t, err, "deliverMessage", "releaseContext")
+	}
+
+	assert.NotNil(t, misuse(func() { ctx.Reply(&testpb.TestMessage{}) }))
+	assert.NotNil(t, misuse(func() { ctx.RawFrame() }))
+	assert.Equal(t, poisonedPayload, ctx.payload)
+}
+
+func TestFrameReleasedTwice(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	frame := &receivedMessage{refs: 1}
+	frame.own.acquire(&node.owned, "received frame")
+
+	leaks := node.OwnershipLeaks()
+	if assert.Len(t, leaks, 1) {
+		assert.Equal(t, "received frame", leaks[0].Kind)
+		assert.True(t, strings.Contains(leaks[0].AcquiredAt, "TestFrameReleasedTwice"))
+	}
+
+	frame.hold()
+	frame.done()
+	assert.Len(t, node.OwnershipLeaks(), 1)
+	frame.done()
+	assert.Empty(t, node.OwnershipLeaks())
+
+	err := misuse(frame.done)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "release", err.Op)
+		assert.Equal(t, leaks[0].Generation, err.Generation)
+		assertStacks(t, err, "TestFrameReleasedTwice", "(*receivedMessage).done")
+	}
+
+	err = misuse(frame.hold)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "use", err.Op)
+	}
+}
+
+func TestContextReleasedTwice(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	ctx := new(PluginContext)
+	ctx.own.acquire(&node.owned, "plugin context")
+	releaseContext(ctx)
+
+	err := misuse(func() { releaseContext(ctx) })
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "release", err.Op)
+		assertStacks(t, err, "TestContextReleasedTwice", "releaseContext")
+	}
+	assert.Empty(t, node.OwnershipLeaks())
+}
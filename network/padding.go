@@ -0,0 +1,164 @@
+package network
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// PaddingCapability is advertised by nodes which strip padding off the frames
+// they receive. Frames are only padded when written to peers advertising it.
+const PaddingCapability = "noise/padding"
+
+// paddingTag is the key of the padding field of a serialized message, being
+// field 13 of wire type bytes.
+const paddingTag = 13<<3 | 2
+
+// PaddingStats counts the bytes frames were padded with, which are the
+// bandwidth spent on hiding the size of messages.
+type PaddingStats struct {
+	// Padded is the number of frames written out padded.
+	Padded uint64
+	// Sent is the number of bytes frames written out were padded with.
+	Sent uint64
+	// Received is the number of bytes of padding stripped off frames received.
+	Received uint64
+}
+
+// checkPadding checks that padding buckets are in ascending order, and that
+// neither buckets nor fixed sizes exceed the largest frame carried.
+func checkPadding(buckets []int, fixed map[string]int, maxFrameSize int) error {
+	for i, bucket := range buckets {
+		if bucket <= 4 || bucket > maxFrameSize+4 || (i > 0 && bucket <= buckets[i-1]) {
+			return errors.Errorf("invalid padding bucket of %d bytes", bucket)
+		}
+	}
+
+	names := make([]string, 0, len(fixed))
+	for name := range fixed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if size := fixed[name]; size <= 4 || size > maxFrameSize+4 {
+			return errors.Errorf("invalid fixed padding of %d bytes for %s", size, name)
+		}
+	}
+
+	return nil
+}
+
+// supportsPadding returns true if the peer at an address advertised that it
+// strips padding.
+func (n *Network) supportsPadding(address string) bool {
+	if c, exists := n.peers.Load(address); exists {
+		return c.(*PeerClient).HasCapability(PaddingCapability)
+	}
+	return false
+}
+
+// padding returns the padding field to append to a serialized message of size
+// bytes written out to the peer at an address, or nil should it be written
+// out as is. Frames are padded to the fixed size set for the message they
+// carry, or otherwise to the smallest bucket they fit in.
+func (n *Network) padding(address string, message *protobuf.Message, size int) []byte {
+	if len(n.opts.paddingBuckets) == 0 && len(n.opts.paddingFixed) == 0 {
+		return nil
+	}
+
+	if !n.supportsPadding(address) {
+		return nil
+	}
+
+	// Sizes padded to count the length prefix of frames.
+	size += 4
+
+	if len(n.opts.paddingFixed) > 0 && message.Message != nil {
+		if name, err := types.AnyMessageName(message.Message); err == nil {
+			if target, exists := n.opts.paddingFixed[name]; exists && target >= size {
+				if target == size {
+					return nil
+				}
+				if field := encodePadding(target - size); field != nil {
+					return n.countPadding(field)
+				}
+			}
+		}
+	}
+
+	for _, bucket := range n.opts.paddingBuckets {
+		if bucket == size {
+			return nil
+		}
+
+		// A frame falling a byte or so short of a bucket, too little to fit
+		// the padding field's header, is padded to the next bucket instead.
+		if bucket > size {
+			if field := encodePadding(bucket - size); field != nil {
+				return n.countPadding(field)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (n *Network) countPadding(field []byte) []byte {
+	atomic.AddUint64(&n.paddedFrames, 1)
+	atomic.AddUint64(&n.paddingSent, uint64(len(field)))
+	return field
+}
+
+// encodePadding encodes a padding field of exactly gap bytes filled with
+// pseudo-random bytes, or returns nil should no field be that long.
+func encodePadding(gap int) []byte {
+	var varint [binary.MaxVarintLen64]byte
+
+	for header := 2; header <= 1+binary.MaxVarintLen32; header++ {
+		length := gap - header
+		if length < 0 {
+			return nil
+		}
+
+		if 1+binary.PutUvarint(varint[:], uint64(length)) == header {
+			field := make([]byte, gap)
+			field[0] = paddingTag
+			copy(field[1:], varint[:header-1])
+			rand.Read(field[header:])
+			return field
+		}
+	}
+
+	return nil
+}
+
+// stripPadding strips the padding off a message received, returning how many
+// bytes of the frame it took up.
+func (n *Network) stripPadding(msg *protobuf.Message) int {
+	if msg.Padding == nil {
+		return 0
+	}
+
+	size := 1 + len(appendUvarint(nil, uint64(len(msg.Padding)))) + len(msg.Padding)
+	msg.Padding = nil
+
+	atomic.AddUint64(&n.paddingReceived, uint64(size))
+
+	return size
+}
+
+// PaddingStats returns how many frames were padded, and how many bytes of
+// padding were sent and received.
+func (n *Network) PaddingStats() PaddingStats {
+	return PaddingStats{
+		Padded:   atomic.LoadUint64(&n.paddedFrames),
+		Sent:     atomic.LoadUint64(&n.paddingSent),
+		Received: atomic.LoadUint64(&n.paddingReceived),
+	}
+}
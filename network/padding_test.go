@@ -0,0 +1,174 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+type paddedArrival struct {
+	message  string
+	wireSize int
+	padding  []byte
+}
+
+// connectPadded connects a sender built with opts to a receiver reporting
+// every test message it handles.
+func connectPadded(t *testing.T, receiverOpts []BuilderOption, opts ...BuilderOption) (*Network, *Network, *PeerClient, chan paddedArrival) {
+	arrivals := make(chan paddedArrival, 16)
+
+	builder := NewBuilderWithOptions(receiverOpts...)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			arrivals <- paddedArrival{message: msg.Message, wireSize: ctx.WireSize(), padding: ctx.Envelope().Padding}
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t, opts...)
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	return receiver, sender, client, arrivals
+}
+
+func receivePadded(t *testing.T, arrivals chan paddedArrival) paddedArrival {
+	select {
+	case arrival := <-arrivals:
+		return arrival
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never received")
+		return paddedArrival{}
+	}
+}
+
+func TestPaddingBuckets(t *testing.T) {
+	t.Parallel()
+
+	buckets := []int{512, 1024, 4096}
+
+	receiver, sender, client, arrivals := connectPadded(t, nil, PaddingBuckets(buckets...))
+	defer receiver.Close()
+	defer sender.Close()
+
+	for _, message := range []string{"", "a", strings.Repeat("b", 300), strings.Repeat("c", 700), strings.Repeat("d", 2000)} {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: message}))
+
+		arrival := receivePadded(t, arrivals)
+		assert.Equal(t, message, arrival.message)
+		assert.Nil(t, arrival.padding)
+		assert.Contains(t, buckets, arrival.wireSize)
+	}
+
+	// Frames shared by every peer broadcast to are padded for each of them.
+	sender.Broadcast(&testpb.TestMessage{Message: "broadcast"})
+
+	arrival := receivePadded(t, arrivals)
+	assert.Equal(t, "broadcast", arrival.message)
+	assert.Equal(t, buckets[0], arrival.wireSize)
+
+	// Frames too large for any bucket are written out as is.
+	large := strings.Repeat("e", 5000)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: large}))
+	assert.Equal(t, large, receivePadded(t, arrivals).message)
+
+	sent := sender.PaddingStats()
+	assert.Equal(t, uint64(6), sent.Padded)
+	assert.True(t, sent.Sent > 0)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return receiver.PaddingStats().Received == sent.Sent
+	}), "padding received was never counted")
+}
+
+func TestFixedPadding(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client, arrivals := connectPadded(t, nil, PaddingBuckets(4096), FixedPadding(&testpb.TestMessage{}, 600))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "vote"}))
+	assert.Equal(t, 600, receivePadded(t, arrivals).wireSize)
+
+	// Messages outgrowing their fixed size fall back to the buckets.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: strings.Repeat("v", 1000)}))
+	assert.Equal(t, 4096, receivePadded(t, arrivals).wireSize)
+}
+
+func TestPaddingIsNotCountedAgainstMaxMessageSize(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client, arrivals := connectPadded(t, []BuilderOption{MaxMessageSize(1024)}, PaddingBuckets(4096))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "small"}))
+
+	arrival := receivePadded(t, arrivals)
+	assert.Equal(t, "small", arrival.message)
+	assert.Equal(t, 4096, arrival.wireSize)
+
+	// Padding may never grow frames past what the wire format carries.
+	_, err := NewBuilderWithOptions(PaddingBuckets(defaultMaxFrameSize + 5)).Build()
+	assert.NotNil(t, err)
+	_, err = NewBuilderWithOptions(PaddingBuckets(1024, 512)).Build()
+	assert.NotNil(t, err)
+	_, err = NewBuilderWithOptions(FixedPadding(&testpb.TestMessage{}, defaultMaxFrameSize+5)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestNoPaddingForPeersNotStrippingIt(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client, arrivals := connectPadded(t, nil, PaddingBuckets(4096))
+	defer receiver.Close()
+	defer sender.Close()
+
+	var capabilities []string
+	for _, capability := range client.Capabilities() {
+		if capability != PaddingCapability {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	client.offer.Capabilities = capabilities
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "plain"}))
+	assert.True(t, receivePadded(t, arrivals).wireSize < 4096)
+	assert.Equal(t, uint64(0), sender.PaddingStats().Padded)
+}
+
+func TestEncodePadding(t *testing.T) {
+	t.Parallel()
+
+	body, err := proto.Marshal(&protobuf.Message{Protocol: "test"})
+	assert.Nil(t, err)
+
+	// Padding fields are exactly as long as asked, save for the few lengths
+	// no field header fits.
+	var unfit []int
+	for gap := 0; gap < 20000; gap++ {
+		field := encodePadding(gap)
+		if field == nil {
+			unfit = append(unfit, gap)
+			continue
+		}
+		assert.Len(t, field, gap)
+
+		var msg protobuf.Message
+		assert.Nil(t, proto.Unmarshal(append(append([]byte(nil), body...), field...), &msg))
+		assert.Equal(t, "test", msg.Protocol)
+		assert.Equal(t, gap, 1+len(appendUvarint(nil, uint64(len(msg.Padding))))+len(msg.Padding))
+	}
+	assert.Equal(t, []int{0, 1, 130, 16387}, unfit)
+}
@@ -0,0 +1,307 @@
+package network
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPeerBundleMaxAge = 24 * time.Hour
+
+	// peerBundleClockSkew is how far into the future a bundle may be dated.
+	peerBundleClockSkew = 1 * time.Minute
+)
+
+var (
+	// ErrUntrustedPeerBundle is returned when importing a peer bundle signed by
+	// a key outside the trusted signers.
+	ErrUntrustedPeerBundle = errors.New("network: peer bundle is not signed by a trusted signer")
+	// ErrInvalidPeerBundle is returned when importing a peer bundle whose
+	// signature does not verify, or which is malformed.
+	ErrInvalidPeerBundle = errors.New("network: peer bundle is invalid")
+	// ErrExpiredPeerBundle is returned when importing a peer bundle past its
+	// max age.
+	ErrExpiredPeerBundle = errors.New("network: peer bundle has expired")
+)
+
+// PeerRecord describes a peer known to the network.
+type PeerRecord struct {
+	PublicKey []byte
+	Addresses []string
+	// LastSeen is when the peer was last connected.
+	LastSeen time.Time
+	// Tags are free-form labels attached by whoever curated the record.
+	Tags []string
+
+	// LastConnected is when the peer was last dialed successfully.
+	LastConnected time.Time
+	// Connects and Failures count the dials to the peer which succeeded and
+	// failed.
+	Connects uint64
+	Failures uint64
+	// FailureStreak is the number of dials to the peer which failed since it
+	// was last connected.
+	FailureStreak uint64
+	// RTT is how long the latest successful dial to the peer took, handshake
+	// included.
+	RTT time.Duration
+}
+
+// hasHistory returns true if the peer was ever dialed.
+func (r *PeerRecord) hasHistory() bool {
+	return r.Connects+r.Failures > 0
+}
+
+// encodePeerRecord returns the wire form of a record.
+func encodePeerRecord(record PeerRecord) *protobuf.PeerRecord {
+	encoded := &protobuf.PeerRecord{
+		PublicKey:     record.PublicKey,
+		Addresses:     record.Addresses,
+		LastSeen:      record.LastSeen.UnixNano(),
+		Tags:          record.Tags,
+		Connects:      record.Connects,
+		Failures:      record.Failures,
+		FailureStreak: record.FailureStreak,
+		Rtt:           int64(record.RTT),
+	}
+	if !record.LastConnected.IsZero() {
+		encoded.LastConnected = record.LastConnected.UnixNano()
+	}
+	return encoded
+}
+
+// decodePeerRecord returns a record from its wire form, with its addresses
+// replaced.
+func decodePeerRecord(record *protobuf.PeerRecord, addresses []string) PeerRecord {
+	decoded := PeerRecord{
+		PublicKey:     record.PublicKey,
+		Addresses:     addresses,
+		LastSeen:      time.Unix(0, record.LastSeen),
+		Tags:          record.Tags,
+		Connects:      record.Connects,
+		Failures:      record.Failures,
+		FailureStreak: record.FailureStreak,
+		RTT:           time.Duration(record.Rtt),
+	}
+	if record.LastConnected != 0 {
+		decoded.LastConnected = time.Unix(0, record.LastConnected)
+	}
+	return decoded
+}
+
+// addressBook holds the peers imported from bundles by public key, as
+// candidates to dial. Their addresses are unverified until dialed.
+type addressBook struct {
+	sync.Mutex
+	records map[string]PeerRecord
+	peak    peakSize
+}
+
+// merge adds a record, taking the union of addresses and tags with any record
+// of the same peer and keeping the latest time it was seen. Of the two dial
+// histories, the one spanning the most dials is kept.
+func (b *addressBook) merge(record PeerRecord) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.records == nil {
+		b.records = make(map[string]PeerRecord)
+	}
+
+	if existing, exists := b.records[string(record.PublicKey)]; exists {
+		record.Addresses = unionStrings(existing.Addresses, record.Addresses)
+		record.Tags = unionStrings(existing.Tags, record.Tags)
+		if existing.LastSeen.After(record.LastSeen) {
+			record.LastSeen = existing.LastSeen
+		}
+		if existing.Connects+existing.Failures > record.Connects+record.Failures {
+			record.LastConnected = existing.LastConnected
+			record.Connects = existing.Connects
+			record.Failures = existing.Failures
+			record.FailureStreak = existing.FailureStreak
+			record.RTT = existing.RTT
+		}
+	}
+
+	b.records[string(record.PublicKey)] = record
+}
+
+func (b *addressBook) list() []PeerRecord {
+	b.Lock()
+	defer b.Unlock()
+
+	records := make([]PeerRecord, 0, len(b.records))
+	for _, record := range b.records {
+		records = append(records, record)
+	}
+	return records
+}
+
+// compact drops candidates which were never connected to, once they were
+// last seen longer than maxAge ago, examining at most budget of them.
+func (b *addressBook) compact(now time.Time, maxAge time.Duration, budget int) compaction {
+	b.Lock()
+	defer b.Unlock()
+
+	return compactMap(&b.records, &b.peak, budget, func(_ string, record PeerRecord) bool {
+		return record.Connects == 0 && now.Sub(record.LastSeen) > maxAge
+	})
+}
+
+func unionStrings(a, b []string) []string {
+	union := append([]string(nil), a...)
+	for _, s := range b {
+		if !containsString(union, s) {
+			union = append(union, s)
+		}
+	}
+	return union
+}
+
+// sortPeerRecords sorts records by when they were last seen, latest first.
+func sortPeerRecords(records []PeerRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].LastSeen.Equal(records[j].LastSeen) {
+			return records[i].LastSeen.After(records[j].LastSeen)
+		}
+		return bytes.Compare(records[i].PublicKey, records[j].PublicKey) < 0
+	})
+}
+
+// Candidates returns the peers imported from bundles, latest seen first, as
+// unverified candidates to dial.
+func (n *Network) Candidates() []PeerRecord {
+	records := n.candidates.list()
+	sortPeerRecords(records)
+	return records
+}
+
+// knownPeers returns the candidates merged with the connected peers, seen as
+// of now, latest seen first.
+func (n *Network) knownPeers(now time.Time) []PeerRecord {
+	book := addressBook{}
+	for _, record := range n.candidates.list() {
+		book.merge(record)
+	}
+	n.eachPeer(func(client *PeerClient) bool {
+		// Ephemeral peers have no address to share.
+		if id := client.id(); id != nil && len(id.Address) > 0 {
+			book.merge(PeerRecord{
+				PublicKey: id.PublicKey,
+				Addresses: []string{id.Address},
+				LastSeen:  now,
+			})
+		}
+		return true
+	})
+
+	records := book.list()
+	sortPeerRecords(records)
+	return records
+}
+
+// ExportPeerBundle serializes the connected peers and candidates this node
+// knows of into a bundle signed with its identity key, importable until the
+// age set by PeerBundleMaxAge. Records are only included should filter, if
+// set, return true, and filter may attach tags to them.
+func (n *Network) ExportPeerBundle(filter func(record *PeerRecord) bool) ([]byte, error) {
+	now := n.now()
+	records := n.knownPeers(now)
+
+	bundle := &protobuf.PeerBundle{
+		CreatedAt: now.UnixNano(),
+		MaxAge:    int64(n.opts.peerBundleMaxAge),
+	}
+	for _, record := range records {
+		if filter != nil && !filter(&record) {
+			continue
+		}
+		bundle.Records = append(bundle.Records, encodePeerRecord(record))
+	}
+
+	serialized, err := proto.Marshal(bundle)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal peer bundle")
+	}
+
+	signature, err := n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, serialized)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign peer bundle")
+	}
+
+	return proto.Marshal(&protobuf.SignedPeerBundle{
+		Bundle:    serialized,
+		Signer:    n.keys.PublicKey,
+		Signature: signature,
+	})
+}
+
+// ImportPeerBundle verifies a bundle was signed by one of the trusted signers'
+// public keys and has yet to expire, and merges its records into the network's
+// candidates, returning the number of records merged. Records of this node are
+// skipped. Imported addresses stay unverified until dialed.
+func (n *Network) ImportPeerBundle(serialized []byte, trustedSigners [][]byte) (int, error) {
+	signed := new(protobuf.SignedPeerBundle)
+	if err := proto.Unmarshal(serialized, signed); err != nil {
+		return 0, errors.Wrap(ErrInvalidPeerBundle, err.Error())
+	}
+
+	trusted := false
+	for _, signer := range trustedSigners {
+		if bytes.Equal(signer, signed.Signer) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return 0, ErrUntrustedPeerBundle
+	}
+
+	if !crypto.Verify(n.opts.signaturePolicy, n.opts.hashPolicy, signed.Signer, signed.Bundle, signed.Signature) {
+		return 0, errors.Wrap(ErrInvalidPeerBundle, "signature does not verify")
+	}
+
+	bundle := new(protobuf.PeerBundle)
+	if err := proto.Unmarshal(signed.Bundle, bundle); err != nil {
+		return 0, errors.Wrap(ErrInvalidPeerBundle, err.Error())
+	}
+
+	now := n.now()
+	created := time.Unix(0, bundle.CreatedAt)
+
+	if created.After(now.Add(peerBundleClockSkew)) {
+		return 0, errors.Wrapf(ErrInvalidPeerBundle, "created in the future at %s", created)
+	}
+	if bundle.MaxAge <= 0 || now.After(created.Add(time.Duration(bundle.MaxAge))) {
+		return 0, errors.Wrapf(ErrExpiredPeerBundle, "created at %s with max age %s", created, time.Duration(bundle.MaxAge))
+	}
+
+	merged := 0
+	for _, record := range bundle.Records {
+		if len(record.PublicKey) == 0 || bytes.Equal(record.PublicKey, n.keys.PublicKey) {
+			continue
+		}
+
+		var addresses []string
+		for _, address := range record.Addresses {
+			if unified, err := ToUnifiedAddress(address); err == nil {
+				addresses = append(addresses, unified)
+			}
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+
+		n.candidates.merge(decodePeerRecord(record, addresses))
+		merged++
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,117 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerBundleRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	exporter := buildListeningNode(t)
+	defer exporter.Close()
+
+	var peers []*Network
+	for i := 0; i < 2; i++ {
+		peer := buildListeningNode(t)
+		defer peer.Close()
+
+		client, err := peer.Client(exporter.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+		peers = append(peers, peer)
+	}
+	waitForPeers(t, exporter, 2)
+
+	importer := buildListeningNode(t)
+	defer importer.Close()
+
+	// Only the first peer is curated into the bundle, tagged by region.
+	bundle, err := exporter.ExportPeerBundle(func(record *PeerRecord) bool {
+		if string(record.PublicKey) != string(peers[0].keys.PublicKey) {
+			return false
+		}
+		record.Tags = []string{"region:eu"}
+		return true
+	})
+	assert.Nil(t, err)
+
+	merged, err := importer.ImportPeerBundle(bundle, [][]byte{exporter.keys.PublicKey})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, merged)
+
+	candidates := importer.Candidates()
+	if assert.Equal(t, 1, len(candidates)) {
+		assert.Equal(t, peers[0].keys.PublicKey, candidates[0].PublicKey)
+		assert.Equal(t, []string{peers[0].Address}, candidates[0].Addresses)
+		assert.Equal(t, []string{"region:eu"}, candidates[0].Tags)
+		assert.False(t, candidates[0].LastSeen.IsZero())
+	}
+
+	// Candidates are re-exported alongside connected peers.
+	bundle, err = importer.ExportPeerBundle(nil)
+	assert.Nil(t, err)
+
+	merged, err = peers[1].ImportPeerBundle(bundle, [][]byte{importer.keys.PublicKey})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, merged)
+}
+
+func TestPeerBundleRejected(t *testing.T) {
+	t.Parallel()
+
+	exporter := buildListeningNode(t, PeerBundleMaxAge(time.Hour))
+	defer exporter.Close()
+
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	client, err := peer.Client(exporter.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	waitForPeers(t, exporter, 1)
+
+	importer := buildListeningNode(t)
+	defer importer.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	importer.now = clock.Now
+
+	bundle, err := exporter.ExportPeerBundle(nil)
+	assert.Nil(t, err)
+
+	untrusted := buildListeningNode(t)
+	defer untrusted.Close()
+
+	_, err = importer.ImportPeerBundle(bundle, [][]byte{untrusted.keys.PublicKey})
+	assert.Equal(t, ErrUntrustedPeerBundle, errors.Cause(err))
+
+	// Swap the peer's address for another in transit.
+	signed := new(protobuf.SignedPeerBundle)
+	assert.Nil(t, proto.Unmarshal(bundle, signed))
+
+	contents := new(protobuf.PeerBundle)
+	assert.Nil(t, proto.Unmarshal(signed.Bundle, contents))
+	contents.Records[0].Addresses = []string{untrusted.Address}
+
+	signed.Bundle, err = proto.Marshal(contents)
+	assert.Nil(t, err)
+	tampered, err := proto.Marshal(signed)
+	assert.Nil(t, err)
+
+	_, err = importer.ImportPeerBundle(tampered, [][]byte{exporter.keys.PublicKey})
+	assert.Equal(t, ErrInvalidPeerBundle, errors.Cause(err))
+
+	clock.Advance(time.Hour + time.Second)
+
+	_, err = importer.ImportPeerBundle(bundle, [][]byte{exporter.keys.PublicKey})
+	assert.Equal(t, ErrExpiredPeerBundle, errors.Cause(err))
+
+	assert.Equal(t, 0, len(importer.Candidates()), "rejected bundles should not be merged")
+}
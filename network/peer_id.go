@@ -0,0 +1,206 @@
+package network
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// peerIDPrefix prefixes the string form of all peer IDs.
+	peerIDPrefix = "noise1"
+	// peerIDChecksumSize is the number of bytes of the public key's hash
+	// appended to the string form of a peer ID to catch typos.
+	peerIDChecksumSize = 4
+	// maxPublicKeySize is the size of the largest public key a peer ID may
+	// hold, fitting those of post-quantum schemes such as ML-DSA.
+	maxPublicKeySize = 4096
+)
+
+// ErrInvalidPeerID is returned when parsing or validating a malformed peer ID.
+var ErrInvalidPeerID = errors.New("network: invalid peer ID")
+
+var peerIDEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// PeerID identifies a peer by its public key alone, regardless of the address
+// it is reachable at. Peer IDs are comparable, and may be used as map keys.
+// The zero value identifies no peer.
+type PeerID struct {
+	publicKey string
+}
+
+// PeerIDFromPublicKey creates a peer ID from a peer's public key.
+func PeerIDFromPublicKey(publicKey []byte) (PeerID, error) {
+	id := PeerID{publicKey: string(publicKey)}
+	if err := id.Validate(); err != nil {
+		return PeerID{}, err
+	}
+	return id, nil
+}
+
+// ParsePeerID parses a peer ID from the string form returned by String.
+func ParsePeerID(s string) (PeerID, error) {
+	if !strings.HasPrefix(s, peerIDPrefix) {
+		return PeerID{}, errors.Wrap(ErrInvalidPeerID, "missing prefix")
+	}
+
+	raw, err := peerIDEncoding.DecodeString(s[len(peerIDPrefix):])
+	if err != nil {
+		return PeerID{}, errors.Wrap(ErrInvalidPeerID, err.Error())
+	}
+
+	if len(raw) <= peerIDChecksumSize {
+		return PeerID{}, errors.Wrap(ErrInvalidPeerID, "too short")
+	}
+
+	publicKey, checksum := raw[:len(raw)-peerIDChecksumSize], raw[len(raw)-peerIDChecksumSize:]
+
+	id, err := PeerIDFromPublicKey(publicKey)
+	if err != nil {
+		return PeerID{}, err
+	}
+
+	if !bytes.Equal(id.Hash()[:peerIDChecksumSize], checksum) {
+		return PeerID{}, errors.Wrap(ErrInvalidPeerID, "checksum mismatch")
+	}
+
+	return id, nil
+}
+
+// Validate returns an error should the peer ID not hold a plausible public key.
+func (id PeerID) Validate() error {
+	if len(id.publicKey) == 0 {
+		return errors.Wrap(ErrInvalidPeerID, "empty public key")
+	}
+	if len(id.publicKey) > maxPublicKeySize {
+		return errors.Wrap(ErrInvalidPeerID, "public key too large")
+	}
+	return nil
+}
+
+// IsZero returns true if the peer ID identifies no peer.
+func (id PeerID) IsZero() bool {
+	return len(id.publicKey) == 0
+}
+
+// PublicKey returns the peer's public key.
+func (id PeerID) PublicKey() []byte {
+	return []byte(id.publicKey)
+}
+
+// Hash returns the hash of the peer's public key, which is what distances
+// between peers are measured over.
+func (id PeerID) Hash() []byte {
+	return blake2b.New().HashBytes([]byte(id.publicKey))
+}
+
+// Short returns an abbreviated hex form of the peer ID's hash for logs.
+func (id PeerID) Short() string {
+	return hex.EncodeToString(id.Hash()[:4])
+}
+
+// String returns the peer ID's stable string form, suitable for config files
+// and logs, e.g. "noise1...".
+func (id PeerID) String() string {
+	if id.IsZero() {
+		return ""
+	}
+
+	raw := append([]byte(id.publicKey), id.Hash()[:peerIDChecksumSize]...)
+	return peerIDPrefix + peerIDEncoding.EncodeToString(raw)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id PeerID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *PeerID) UnmarshalText(text []byte) error {
+	parsed, err := ParsePeerID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Equal returns true if both peer IDs hold the same public key.
+func (id PeerID) Equal(other PeerID) bool {
+	return id.publicKey == other.publicKey
+}
+
+// Less returns true if the peer ID's hash sorts before the other's.
+func (id PeerID) Less(other PeerID) bool {
+	return bytes.Compare(id.Hash(), other.Hash()) < 0
+}
+
+// Distance returns the XOR distance between the hashes of two peer IDs.
+func (id PeerID) Distance(other PeerID) []byte {
+	a, b := id.Hash(), other.Hash()
+
+	distance := make([]byte, len(a))
+	for i := 0; i < len(a) && i < len(b); i++ {
+		distance[i] = a[i] ^ b[i]
+	}
+	return distance
+}
+
+// SortByDistance sorts peer IDs by their XOR distance to a target, closest first.
+func SortByDistance(target PeerID, ids []PeerID) {
+	distances := make(map[PeerID][]byte, len(ids))
+	for _, id := range ids {
+		distances[id] = target.Distance(id)
+	}
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		return bytes.Compare(distances[ids[i]], distances[ids[j]]) < 0
+	})
+}
+
+// peerIDOf returns the peer ID of a protobuf ID.
+func peerIDOf(id *protobuf.ID) PeerID {
+	if id == nil {
+		return PeerID{}
+	}
+	return PeerID{publicKey: string(id.PublicKey)}
+}
+
+// PeerID returns the ID of the peer, being the zero value until the peer has
+// proven its identity.
+func (c *PeerClient) PeerID() PeerID {
+	if c.publicKey != nil {
+		return PeerID{publicKey: string(c.publicKey)}
+	}
+	return peerIDOf((*protobuf.ID)(c.id()))
+}
+
+// PeerByID returns the client of a connected peer by its ID, regardless of the
+// address it is currently reachable at.
+func (n *Network) PeerByID(id PeerID) (*PeerClient, bool) {
+	address, known := n.identities.lookup(id.PublicKey())
+	if !known {
+		return nil, false
+	}
+
+	c, exists := n.peers.Load(address)
+	if !exists {
+		return nil, false
+	}
+	return c.(*PeerClient), true
+}
+
+// WriteTo sends a message to a connected peer denoted by its ID.
+func (n *Network) WriteTo(id PeerID, message *protobuf.Message) error {
+	client, ok := n.PeerByID(id)
+	if !ok {
+		return errors.Errorf("network: peer %s is not connected", id.Short())
+	}
+	return n.Write(client.Address, message)
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func randomPeerID(t *testing.T) PeerID {
+	id, err := PeerIDFromPublicKey(ed25519.RandomKeyPair().PublicKey)
+	assert.Nil(t, err)
+	return id
+}
+
+func TestPeerIDRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for i := 0; i < 32; i++ {
+		id := randomPeerID(t)
+
+		s := id.String()
+		assert.True(t, strings.HasPrefix(s, "noise1"))
+		assert.Equal(t, strings.ToLower(s), s)
+
+		parsed, err := ParsePeerID(s)
+		assert.Nil(t, err)
+		assert.Equal(t, id, parsed)
+		assert.True(t, id.Equal(parsed))
+		assert.Equal(t, s, parsed.String())
+	}
+
+	id := randomPeerID(t)
+
+	raw, err := json.Marshal(map[string]PeerID{"peer": id})
+	assert.Nil(t, err)
+
+	var decoded map[string]PeerID
+	assert.Nil(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, id, decoded["peer"])
+
+	// Peer IDs agree with the hashes peer.ID is keyed by.
+	assert.Equal(t, peer.CreateID("tcp://localhost:3000", id.PublicKey()).Id, id.Hash())
+}
+
+func TestPeerIDValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := PeerIDFromPublicKey(nil)
+	assert.Equal(t, ErrInvalidPeerID, errors.Cause(err))
+
+	_, err = PeerIDFromPublicKey(make([]byte, maxPublicKeySize+1))
+	assert.Equal(t, ErrInvalidPeerID, errors.Cause(err))
+
+	assert.True(t, PeerID{}.IsZero())
+	assert.NotNil(t, PeerID{}.Validate())
+
+	s := randomPeerID(t).String()
+
+	// Flip a character in the middle of the encoded public key.
+	flipped := []byte(s)
+	if flipped[10] == 'a' {
+		flipped[10] = 'b'
+	} else {
+		flipped[10] = 'a'
+	}
+
+	for _, invalid := range []string{"", "noise1", "noise2" + s[6:], s[6:], string(flipped), s + "!", "noise1aaaa"} {
+		_, err := ParsePeerID(invalid)
+		assert.Equal(t, ErrInvalidPeerID, errors.Cause(err), "%q should not parse", invalid)
+	}
+}
+
+func TestPeerIDDistanceOrdering(t *testing.T) {
+	t.Parallel()
+
+	target := randomPeerID(t)
+
+	var ids []PeerID
+	for i := 0; i < 64; i++ {
+		ids = append(ids, randomPeerID(t))
+	}
+
+	SortByDistance(target, ids)
+
+	for i := 1; i < len(ids); i++ {
+		assert.True(t, bytes.Compare(target.Distance(ids[i-1]), target.Distance(ids[i])) <= 0)
+	}
+	assert.Equal(t, make([]byte, len(target.Hash())), target.Distance(target))
+
+	// Distances agree with those between peer.IDs.
+	a, b := peer.CreateID("a", target.PublicKey()), peer.CreateID("b", ids[0].PublicKey())
+	assert.Equal(t, a.XorID(b).Id, target.Distance(ids[0]))
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+	for i := 1; i < len(ids); i++ {
+		assert.True(t, bytes.Compare(ids[i-1].Hash(), ids[i].Hash()) < 0)
+	}
+}
+
+func TestWriteToPeerID(t *testing.T) {
+	t.Parallel()
+
+	keys := ed25519.RandomKeyPair()
+	id, err := PeerIDFromPublicKey(keys.PublicKey)
+	assert.Nil(t, err)
+
+	received := make(chan string, 1)
+
+	builder := NewBuilder()
+	builder.SetKeys(keys)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t, QuarantinePeriod(time.Hour), PinnedPeerIDs(id))
+	defer sender.Close()
+
+	_, ok := sender.PeerByID(id)
+	assert.False(t, ok)
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+	assert.NotNil(t, sender.WriteTo(id, message))
+
+	_, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	client, ok := sender.PeerByID(id)
+	assert.True(t, ok)
+	assert.Equal(t, receiver.Address, client.Address)
+	assert.Equal(t, id, client.PeerID())
+	assert.False(t, client.Quarantined(), "peers pinned by ID should skip quarantine")
+
+	assert.Nil(t, sender.WriteTo(id, message))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "hello", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never received")
+	}
+}
@@ -0,0 +1,109 @@
+package network
+
+import (
+	"sort"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// maxPeerMetadataSize bounds the total size of the keys and values of the
+// metadata a peer presents during its handshake.
+const maxPeerMetadataSize = 4 * 1024
+
+// ErrPeerMetadataTooLarge is returned should a peer's handshake metadata
+// exceed maxPeerMetadataSize.
+var ErrPeerMetadataTooLarge = errors.New("network: peer metadata too large")
+
+// encodePeerMetadata encodes metadata sorted by key, so that its signature
+// covers the same bytes on either side.
+func encodePeerMetadata(metadata map[string][]byte) ([]*protobuf.HandshakeMetadata, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(metadata))
+	size := 0
+	for key, value := range metadata {
+		keys = append(keys, key)
+		size += len(key) + len(value)
+	}
+
+	if size > maxPeerMetadataSize {
+		return nil, ErrPeerMetadataTooLarge
+	}
+
+	sort.Strings(keys)
+
+	entries := make([]*protobuf.HandshakeMetadata, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, &protobuf.HandshakeMetadata{Key: key, Value: metadata[key]})
+	}
+	return entries, nil
+}
+
+// decodePeerMetadata decodes the metadata a peer presented, being nil should
+// the peer have presented none.
+func decodePeerMetadata(entries []*protobuf.HandshakeMetadata) (map[string][]byte, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	metadata := make(map[string][]byte, len(entries))
+	size := 0
+	for _, entry := range entries {
+		size += len(entry.Key) + len(entry.Value)
+		metadata[entry.Key] = entry.Value
+	}
+
+	if size > maxPeerMetadataSize {
+		return nil, ErrPeerMetadataTooLarge
+	}
+	return metadata, nil
+}
+
+// handshakeInfo describes a peer by what its handshake established of it.
+func handshakeInfo(result *handshakeResult, direction ConnDirection) (PeerInfo, error) {
+	var entries []*protobuf.HandshakeMetadata
+	if result.offer != nil {
+		entries = result.offer.Metadata
+	}
+
+	metadata, err := decodePeerMetadata(entries)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+
+	return PeerInfo{
+		PeerID:    peerIDOf(result.remote),
+		Address:   result.remote.Address,
+		Direction: direction,
+		Metadata:  metadata,
+	}, nil
+}
+
+// admitPeer runs the peer metadata validation hook over a peer which just
+// completed its handshake.
+func (n *Network) admitPeer(result *handshakeResult, direction ConnDirection) error {
+	info, err := handshakeInfo(result, direction)
+	if err != nil {
+		return err
+	}
+
+	if n.opts.validatePeerMetadata == nil {
+		return nil
+	}
+
+	return errors.Wrap(n.opts.validatePeerMetadata(info, info.Metadata), "peer rejected")
+}
+
+// Metadata returns the metadata the peer presented during its handshake,
+// being nil should the peer have presented none.
+func (c *PeerClient) Metadata() map[string][]byte {
+	if c.offer == nil {
+		return nil
+	}
+
+	metadata, _ := decodePeerMetadata(c.offer.Metadata)
+	return metadata
+}
@@ -0,0 +1,127 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// connectPlugin reports the info of every peer as it connects.
+type connectPlugin struct {
+	*Plugin
+	connected chan PeerInfo
+}
+
+func (p *connectPlugin) PeerConnect(client *PeerClient) {
+	p.connected <- client.info()
+}
+
+func withRole(role string) BuilderOption {
+	return PeerMetadata(func() map[string][]byte {
+		return map[string][]byte{"role": []byte(role), "shard": []byte("7")}
+	})
+}
+
+// rejectRole rejects peers presenting a given role.
+func rejectRole(role string) BuilderOption {
+	return ValidatePeerMetadata(func(info PeerInfo, metadata map[string][]byte) error {
+		if string(metadata["role"]) == role {
+			return errors.Errorf("peer %s is a %s node", info.Address, role)
+		}
+		return nil
+	})
+}
+
+func TestPeerMetadataVisibleOnConnect(t *testing.T) {
+	t.Parallel()
+
+	plugin := &connectPlugin{connected: make(chan PeerInfo, 4)}
+
+	builder := NewBuilderWithOptions(withRole("archive"))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(plugin)
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	go node.Listen()
+	<-node.Ready()
+
+	validator := buildListeningNode(t, withRole("validator"))
+	defer validator.Close()
+
+	_, err = node.Client(validator.Address)
+	assert.Nil(t, err)
+
+	select {
+	case info := <-plugin.connected:
+		assert.Equal(t, validator.Address, info.Address)
+		assert.Equal(t, "validator", string(info.Metadata["role"]))
+		assert.Equal(t, "7", string(info.Metadata["shard"]))
+	case <-time.After(3 * time.Second):
+		t.Fatal("peer never connected")
+	}
+
+	// Peers which present no metadata are still connected to.
+	old := buildListeningNode(t)
+	defer old.Close()
+
+	client, err := node.Client(old.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Metadata())
+	assert.Nil(t, (<-plugin.connected).Metadata)
+}
+
+func TestValidatePeerMetadata(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, withRole("validator"), rejectRole("light"))
+	defer node.Close()
+
+	light := buildListeningNode(t, withRole("light"))
+	defer light.Close()
+
+	_, err := node.Client(light.Address)
+	assert.NotNil(t, err, "dialing a rejected role should fail")
+
+	// Peers which dial us are rejected before being admitted.
+	failures := node.HandshakeStats().Failures
+
+	light.Client(node.Address)
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return node.HandshakeStats().Failures > failures }))
+	assert.Equal(t, 0, len(node.Peers()))
+
+	archive := buildListeningNode(t, withRole("archive"))
+	defer archive.Close()
+
+	_, err = node.Client(archive.Address)
+	assert.Nil(t, err)
+
+	old := buildListeningNode(t)
+	defer old.Close()
+
+	_, err = node.Client(old.Address)
+	assert.Nil(t, err, "peers presenting no metadata should be admitted")
+}
+
+func TestPeerMetadataSizeCap(t *testing.T) {
+	t.Parallel()
+
+	large := buildListeningNode(t, PeerMetadata(func() map[string][]byte {
+		return map[string][]byte{"blob": []byte(strings.Repeat("x", maxPeerMetadataSize))}
+	}))
+	defer large.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	_, err := large.Client(node.Address)
+	assert.Equal(t, ErrPeerMetadataTooLarge, errors.Cause(err))
+
+	_, err = encodePeerMetadata(map[string][]byte{"role": []byte("validator")})
+	assert.Nil(t, err)
+}
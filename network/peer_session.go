@@ -0,0 +1,243 @@
+package network
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultPeerSessionLimit bounds the size of the values a peer's session
+	// holds by default.
+	defaultPeerSessionLimit = 64 * 1024
+
+	// sessionEntryOverhead is what every value a session holds is accounted
+	// for on top of its own size and the name of its key.
+	sessionEntryOverhead = 16
+)
+
+// ErrPeerSessionFull is returned when setting a value would have a peer's
+// session grow past PeerSessionLimit.
+var ErrPeerSessionFull = errors.New("network: peer session full")
+
+// SessionKey names a value of type T held in peers' sessions. Keys are told
+// apart by identity rather than by name, so that plugins declaring their own
+// keys never clash.
+type SessionKey[T any] struct {
+	name string
+}
+
+// NewSessionKey declares a key for values of type T, named for debugging
+// and for SessionCarryOver.
+func NewSessionKey[T any](name string) *SessionKey[T] {
+	return &SessionKey[T]{name: name}
+}
+
+// Name returns the name the key was declared under.
+func (k *SessionKey[T]) Name() string {
+	return k.name
+}
+
+// Get returns the value a session holds under the key, if any.
+func (k *SessionKey[T]) Get(s *PeerSession) (T, bool) {
+	value, exists := s.get(k)
+	if !exists {
+		var zero T
+		return zero, false
+	}
+	return value.(T), true
+}
+
+// Set has a session hold a value under the key, failing with
+// ErrPeerSessionFull should the session grow past its limit.
+func (k *SessionKey[T]) Set(s *PeerSession, value T) error {
+	return s.set(k, k.name, value)
+}
+
+// Update atomically replaces the value a session holds under the key by what
+// fn returns given the value held, if any, so that handlers running at once
+// may keep tallies.
+func (k *SessionKey[T]) Update(s *PeerSession, fn func(value T, exists bool) T) error {
+	return s.update(k, k.name, func(value interface{}, exists bool) interface{} {
+		if !exists {
+			var zero T
+			return fn(zero, false)
+		}
+		return fn(value.(T), true)
+	})
+}
+
+// Delete removes the value a session holds under the key, if any.
+func (k *SessionKey[T]) Delete(s *PeerSession) {
+	s.delete(k)
+}
+
+// PeerSession holds the state the plugins handling a peer's messages share,
+// such as parameters negotiated with the peer or claims established by an
+// earlier plugin, under keys declared with NewSessionKey. It lives for as long
+// as the peer stays connected, and is cleared as it disconnects, save for the
+// values SessionCarryOver retains for the session to be resumed with.
+type PeerSession struct {
+	sync.Mutex
+
+	limit   int
+	size    int
+	entries map[interface{}]sessionEntry
+}
+
+// sessionEntry is a value a session holds, alongside the name of its key and
+// how much of the session's limit it takes up.
+type sessionEntry struct {
+	name  string
+	value interface{}
+	size  int
+}
+
+// newPeerSession creates a session holding at most limit bytes, being
+// unlimited should limit be zero.
+func newPeerSession(limit int) *PeerSession {
+	return &PeerSession{limit: limit, entries: make(map[interface{}]sessionEntry)}
+}
+
+func (s *PeerSession) get(key interface{}) (interface{}, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, exists := s.entries[key]
+	return entry.value, exists
+}
+
+func (s *PeerSession) set(key interface{}, name string, value interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.setLocked(key, name, value)
+}
+
+func (s *PeerSession) update(key interface{}, name string, fn func(value interface{}, exists bool) interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, exists := s.entries[key]
+	return s.setLocked(key, name, fn(entry.value, exists))
+}
+
+func (s *PeerSession) setLocked(key interface{}, name string, value interface{}) error {
+	entry := sessionEntry{name: name, value: value, size: sessionEntryOverhead + len(name) + sessionValueSize(value)}
+
+	size := s.size + entry.size - s.entries[key].size
+	if s.limit > 0 && size > s.limit {
+		return errors.Wrapf(ErrPeerSessionFull, "failed to hold %s taking up %d bytes", name, entry.size)
+	}
+
+	s.entries[key] = entry
+	s.size = size
+	return nil
+}
+
+func (s *PeerSession) delete(key interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.size -= s.entries[key].size
+	delete(s.entries, key)
+}
+
+// Len returns the number of values the session holds.
+func (s *PeerSession) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.entries)
+}
+
+// Size returns how many bytes of its limit the values the session holds take
+// up, as estimated.
+func (s *PeerSession) Size() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.size
+}
+
+// clear drops every value the session holds, returning those keep retains.
+func (s *PeerSession) clear(keep func(name string, value interface{}) bool) map[interface{}]sessionEntry {
+	s.Lock()
+	defer s.Unlock()
+
+	var kept map[interface{}]sessionEntry
+	if keep != nil {
+		for key, entry := range s.entries {
+			if keep(entry.name, entry.value) {
+				if kept == nil {
+					kept = make(map[interface{}]sessionEntry)
+				}
+				kept[key] = entry
+			}
+		}
+	}
+
+	s.entries = make(map[interface{}]sessionEntry)
+	s.size = 0
+
+	return kept
+}
+
+// restore has the session hold the values retained by a session it resumes,
+// within its limit.
+func (s *PeerSession) restore(entries map[interface{}]sessionEntry) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, entry := range entries {
+		if s.limit > 0 && s.size+entry.size > s.limit {
+			continue
+		}
+		s.entries[key] = entry
+		s.size += entry.size
+	}
+}
+
+// sessionValueSize estimates how many bytes a value takes up. Strings, byte
+// slices and values with a Size method, such as protobuf messages, are sized
+// by their contents, and other values by their type alone.
+func sessionValueSize(value interface{}) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case interface{ Size() int }:
+		return v.Size()
+	default:
+		return int(reflect.TypeOf(value).Size())
+	}
+}
+
+// Session returns the session holding the state shared by the plugins
+// handling the peer's messages.
+func (c *PeerClient) Session() *PeerSession {
+	return c.state
+}
+
+// Session returns the session of the peer the message arrived from.
+func (ctx *PluginContext) Session() *PeerSession {
+	ctx.own.check()
+	return ctx.client.state
+}
+
+// endSession clears the peer's session as it disconnects, retaining the values
+// SessionCarryOver picks alongside the session held to resume the connection
+// we dialed, if any.
+func (c *PeerClient) endSession() map[interface{}]sessionEntry {
+	var keep func(name string, value interface{}) bool
+	if carry := c.Network.opts.sessionCarryOver; carry != nil && c.session != nil {
+		keep = func(name string, value interface{}) bool {
+			return carry(c, name, value)
+		}
+	}
+	return c.state.clear(keep)
+}
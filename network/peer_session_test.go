@@ -0,0 +1,188 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	claimKey = NewSessionKey[string]("claim")
+	tallyKey = NewSessionKey[int]("tally")
+)
+
+// authPlugin establishes the claim of a peer as it sends "auth:<claim>", and
+// counts every other message the peer sends, as a middleware would.
+type authPlugin struct {
+	*Plugin
+}
+
+func (p *authPlugin) Receive(ctx *PluginContext) error {
+	msg, ok := ctx.Message().(*testpb.TestMessage)
+	if !ok {
+		return nil
+	}
+	if strings.HasPrefix(msg.Message, "auth:") {
+		return claimKey.Set(ctx.Session(), strings.TrimPrefix(msg.Message, "auth:"))
+	}
+	return tallyKey.Update(ctx.Session(), func(tally int, _ bool) int { return tally + 1 })
+}
+
+// buildSessionNode builds a node reporting the claim and count of the peer
+// every message other than claims arrived from, as established by an
+// authPlugin ahead of it.
+func buildSessionNode(t *testing.T, seen chan string, opts ...BuilderOption) *Network {
+	opts = append(opts, OrderedHandlers(&testpb.TestMessage{}))
+
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	builder.AddPlugin(new(authPlugin))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		msg, ok := ctx.Message().(*testpb.TestMessage)
+		if !ok || strings.HasPrefix(msg.Message, "auth:") {
+			return
+		}
+		claim, _ := claimKey.Get(ctx.Session())
+		tally, _ := tallyKey.Get(ctx.Session())
+		seen <- claim + strings.Repeat("+", tally)
+	}})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+// nextSeen returns what the second plugin of a session node reported next.
+func nextSeen(t *testing.T, seen chan string) string {
+	select {
+	case s := <-seen:
+		return s
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never handled")
+		return ""
+	}
+}
+
+func TestPeerSessionSharedAcrossHandlers(t *testing.T) {
+	t.Parallel()
+
+	seen := make(chan string, 8)
+
+	receiver := buildSessionNode(t, seen)
+	defer receiver.Close()
+
+	alice := buildListeningNode(t)
+	defer alice.Close()
+	bob := buildListeningNode(t)
+	defer bob.Close()
+
+	toReceiver := func(node *Network) *PeerClient {
+		client, err := node.Client(receiver.Address)
+		assert.Nil(t, err)
+		return client
+	}
+
+	// Claims established by the first plugin are seen by the second, for
+	// the peer which sent them alone.
+	assert.Nil(t, toReceiver(alice).Tell(&testpb.TestMessage{Message: "auth:alice"}))
+	assert.Nil(t, toReceiver(alice).Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "alice+", nextSeen(t, seen))
+
+	assert.Nil(t, toReceiver(bob).Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "+", nextSeen(t, seen))
+
+	assert.Nil(t, toReceiver(bob).Tell(&testpb.TestMessage{Message: "auth:bob"}))
+	assert.Nil(t, toReceiver(bob).Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "bob++", nextSeen(t, seen))
+
+	assert.Nil(t, toReceiver(alice).Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "alice++", nextSeen(t, seen))
+
+	// Sessions are cleared as peers disconnect.
+	peer, exists := receiver.peers.Load(alice.Address)
+	if !assert.True(t, exists) {
+		return
+	}
+	info := peer.(*PeerClient).Info()
+	assert.Equal(t, 2, info.Session.Len())
+
+	peer.(*PeerClient).Close()
+	assert.Zero(t, info.Session.Len())
+	assert.Zero(t, info.Session.Size())
+	_, exists = claimKey.Get(info.Session)
+	assert.False(t, exists)
+}
+
+func TestPeerSessionCarriedOverOnResumption(t *testing.T) {
+	t.Parallel()
+
+	seen := make(chan string, 8)
+
+	receiver := buildSessionNode(t, seen, SessionResumption(time.Minute), SessionCarryOver(func(client *PeerClient, name string, value interface{}) bool {
+		return name == claimKey.Name()
+	}))
+	defer receiver.Close()
+
+	builder := NewBuilderWithOptions(SessionResumption(time.Minute))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "auth:alice"}))
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "alice+", nextSeen(t, seen))
+
+	client.Close()
+	assert.True(t, waitUntil(5*time.Second, func() bool {
+		return len(sender.Peers()) == 0 && len(receiver.Peers()) == 0
+	}), "peers never disconnected")
+
+	// The claim is carried over into the resumed session, while the tally
+	// starts over.
+	client, err = sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	assert.Equal(t, "alice+", nextSeen(t, seen))
+	assert.Equal(t, uint64(2), receiver.HandshakeStats().Resumed)
+}
+
+func TestPeerSessionLimit(t *testing.T) {
+	t.Parallel()
+
+	session := newPeerSession(64)
+
+	assert.Nil(t, claimKey.Set(session, "alice"))
+	assert.Equal(t, sessionEntryOverhead+len("claim")+len("alice"), session.Size())
+
+	err := claimKey.Set(session, strings.Repeat("a", 64))
+	assert.Equal(t, ErrPeerSessionFull, errors.Cause(err))
+
+	// Values failing to be set leave those held be, and replacing a value
+	// only accounts for the difference.
+	claim, _ := claimKey.Get(session)
+	assert.Equal(t, "alice", claim)
+	assert.Nil(t, claimKey.Set(session, strings.Repeat("a", 40)))
+	assert.Equal(t, ErrPeerSessionFull, errors.Cause(tallyKey.Set(session, 1)))
+
+	claimKey.Delete(session)
+	assert.Nil(t, tallyKey.Set(session, 1))
+	assert.Equal(t, sessionEntryOverhead+len("tally")+8, session.Size())
+	assert.Equal(t, 1, session.Len())
+}
@@ -0,0 +1,194 @@
+package network
+
+import (
+	"sync"
+	"time"
+
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// ConnDirection denotes which side initiated a connection with a peer.
+type ConnDirection uint8
+
+const (
+	// DirectionOutbound marks a peer that this node dialed first.
+	DirectionOutbound ConnDirection = iota
+	// DirectionInbound marks a peer that dialed this node first.
+	DirectionInbound
+)
+
+// String returns a human-readable name of the connection direction.
+func (d ConnDirection) String() string {
+	switch d {
+	case DirectionOutbound:
+		return "outbound"
+	case DirectionInbound:
+		return "inbound"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// ErrInboundQuotaExceeded is returned when no inbound peer slots are left.
+	ErrInboundQuotaExceeded = errors.New("network: inbound peer quota exceeded")
+	// ErrOutboundQuotaExceeded is returned when no outbound peer slots are left.
+	ErrOutboundQuotaExceeded = errors.New("network: outbound peer quota exceeded")
+)
+
+// PeerInfo describes a single peer connection of this node.
+type PeerInfo struct {
+	// ID is nil until the peer has sent us its first message.
+	ID *peer.ID
+	// PeerID identifies the peer by its public key alone.
+	PeerID    PeerID
+	Address   string
+	Direction ConnDirection
+	// Reserved is true if the peer occupies one of the reserved slots.
+	Reserved bool
+	// Quarantined is true if the peer has yet to graduate from quarantine.
+	Quarantined bool
+	// Connected is true if the peer is currently connected. It is only ever
+	// false for peers found through lookups.
+	Connected bool
+	// WriteThroughput is the estimated rate in bytes per second at which
+	// writes to the peer make it onto the wire, being zero until enough
+	// writes were observed. It is only tracked with AdaptiveWriteTimeout.
+	WriteThroughput float64
+	// SignatureSchemes are the signature schemes the peer demonstrated.
+	SignatureSchemes []string
+	// Metadata is what the peer presented about itself during its handshake.
+	Metadata map[string][]byte
+	// Services are the services the peer advertises, by name.
+	Services map[string]ServiceRecord
+	// RTT is the smoothed round trip to the peer, being zero until a
+	// keepalive or ping sent to it was answered.
+	RTT time.Duration
+	// Source is the address the peer last connected to us from, if it
+	// dialed us.
+	Source string
+	// Traffic counts the messages sent to and received from the peer.
+	Traffic MessageCounts
+	// Violations is how many times the peer was reported for misbehaving.
+	Violations uint64
+	// Penalty is the sum of the weights of the penalties plugins handling
+	// the peer's messages dealt it.
+	Penalty uint64
+	// Circuit is the state of the circuit breaker guarding writes to the
+	// peer. It is only tracked with CircuitBreaker.
+	Circuit CircuitState
+	// Tags are the tags the application gave the peer, in sorted order.
+	Tags []string
+	// Group is the group the peer is in, if any.
+	Group string
+	// Session holds the state shared by the plugins handling the peer's
+	// messages. It is nil for peers found through lookups.
+	Session *PeerSession
+	// LegacyPeer is true if the peer speaks the wire protocol of upstream
+	// noise nodes, which it is talked to in.
+	LegacyPeer bool
+}
+
+// peerSlots keeps count of peer connections per direction. A quota of zero
+// leaves a direction unlimited.
+type peerSlots struct {
+	sync.Mutex
+
+	maxInbound  int
+	maxOutbound int
+	maxReserved int
+
+	inbound  int
+	outbound int
+	reserved int
+}
+
+// newPeerSlots derives per-direction quotas from the networks options. Unset
+// inbound/outbound quotas split whatever is left of maxPeers after reserving
+// slots for pinned peers and DHT lookups.
+func newPeerSlots(opts options) *peerSlots {
+	s := &peerSlots{
+		maxInbound:  opts.maxInboundPeers,
+		maxOutbound: opts.maxOutboundPeers,
+		maxReserved: opts.reservedPeers,
+	}
+
+	if opts.maxPeers <= 0 {
+		return s
+	}
+
+	if s.maxReserved <= 0 {
+		s.maxReserved = opts.maxPeers / 10
+		if s.maxReserved == 0 {
+			s.maxReserved = 1
+		}
+	}
+
+	available := opts.maxPeers - s.maxReserved
+	if available < 2 {
+		available = 2
+	}
+
+	switch {
+	case s.maxInbound <= 0 && s.maxOutbound <= 0:
+		s.maxInbound = available / 2
+		s.maxOutbound = available - s.maxInbound
+	case s.maxInbound <= 0:
+		s.maxInbound = available - s.maxOutbound
+	case s.maxOutbound <= 0:
+		s.maxOutbound = available - s.maxInbound
+	}
+
+	// Never let a derived quota silently make a direction unlimited.
+	if s.maxInbound <= 0 {
+		s.maxInbound = 1
+	}
+	if s.maxOutbound <= 0 {
+		s.maxOutbound = 1
+	}
+
+	return s
+}
+
+// acquire takes a slot for a peer connecting in a given direction. Should the
+// direction's quota be exhausted and canReserve be set, a reserved slot is
+// taken instead, which is signalled by the first return value.
+func (s *peerSlots) acquire(direction ConnDirection, canReserve bool) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	count, max := &s.outbound, s.maxOutbound
+	err := ErrOutboundQuotaExceeded
+	if direction == DirectionInbound {
+		count, max = &s.inbound, s.maxInbound
+		err = ErrInboundQuotaExceeded
+	}
+
+	if max <= 0 || *count < max {
+		*count++
+		return false, nil
+	}
+
+	if canReserve && s.reserved < s.maxReserved {
+		s.reserved++
+		return true, nil
+	}
+
+	return false, err
+}
+
+// release returns a slot previously taken by acquire.
+func (s *peerSlots) release(direction ConnDirection, reserved bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case reserved:
+		s.reserved--
+	case direction == DirectionInbound:
+		s.inbound--
+	default:
+		s.outbound--
+	}
+}
@@ -0,0 +1,194 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/stretchr/testify/assert"
+)
+
+func buildListeningNode(t testing.TB, opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	node, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func countPeers(n *Network, direction ConnDirection) int {
+	count := 0
+	for _, info := range n.Peers() {
+		if info.Direction == direction {
+			count++
+		}
+	}
+	return count
+}
+
+func waitForPeers(t *testing.T, n *Network, expected int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := n.WaitForPeers(ctx, expected); err != nil {
+		t.Fatalf("expected %d peers, got %d: %v", expected, len(n.Peers()), err)
+	}
+}
+
+func TestPeerSlotsDerivedFromMaxPeers(t *testing.T) {
+	t.Parallel()
+
+	slots := newPeerSlots(options{maxPeers: 20})
+	assert.Equal(t, 2, slots.maxReserved)
+	assert.Equal(t, 9, slots.maxInbound)
+	assert.Equal(t, 9, slots.maxOutbound)
+
+	slots = newPeerSlots(options{maxPeers: 20, maxInboundPeers: 4})
+	assert.Equal(t, 4, slots.maxInbound)
+	assert.Equal(t, 14, slots.maxOutbound)
+
+	slots = newPeerSlots(options{})
+	reserved, err := slots.acquire(DirectionInbound, false)
+	assert.Nil(t, err)
+	assert.False(t, reserved, "unlimited quotas should never draw reserved slots")
+}
+
+func TestPeerSlotsReserved(t *testing.T) {
+	t.Parallel()
+
+	slots := newPeerSlots(options{maxOutboundPeers: 1, reservedPeers: 1})
+
+	_, err := slots.acquire(DirectionOutbound, false)
+	assert.Nil(t, err)
+
+	_, err = slots.acquire(DirectionOutbound, false)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	reserved, err := slots.acquire(DirectionOutbound, true)
+	assert.Nil(t, err)
+	assert.True(t, reserved)
+
+	_, err = slots.acquire(DirectionOutbound, true)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	slots.release(DirectionOutbound, true)
+	_, err = slots.acquire(DirectionOutbound, true)
+	assert.Nil(t, err)
+}
+
+func TestInboundQuotaLeavesOutboundSlots(t *testing.T) {
+	node := buildListeningNode(t, MaxInboundPeers(2), MaxOutboundPeers(2))
+	defer node.Close()
+
+	var others []*Network
+	for i := 0; i < 5; i++ {
+		other := buildListeningNode(t)
+		defer other.Close()
+		others = append(others, other)
+	}
+
+	// Fill up the inbound quota.
+	others[0].Bootstrap(node.Address)
+	others[1].Bootstrap(node.Address)
+	waitForPeers(t, node, 2)
+	assert.Equal(t, 2, countPeers(node, DirectionInbound))
+
+	// Inbound quota is full; this peer should get turned away.
+	rejected, unsubscribe := node.SubscribeEvents(EventFilter{Kinds: []EventKind{EventDialFailed}})
+	defer unsubscribe()
+
+	others[2].Bootstrap(node.Address)
+	event, ok := nextEvent(t, rejected).(*DialFailedEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, ErrInboundQuotaExceeded, event.Err)
+	}
+	assert.Equal(t, 2, countPeers(node, DirectionInbound))
+
+	// Dialing out must still succeed.
+	_, err := node.Client(others[3].Address)
+	assert.Nil(t, err)
+	_, err = node.Client(others[4].Address)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countPeers(node, DirectionOutbound))
+
+	_, err = node.Client(others[2].Address)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+}
+
+func TestOutboundQuotaLeavesInboundSlots(t *testing.T) {
+	node := buildListeningNode(t, MaxInboundPeers(2), MaxOutboundPeers(1))
+	defer node.Close()
+
+	var others []*Network
+	for i := 0; i < 3; i++ {
+		other := buildListeningNode(t)
+		defer other.Close()
+		others = append(others, other)
+	}
+
+	_, err := node.Client(others[0].Address)
+	assert.Nil(t, err)
+
+	_, err = node.Client(others[1].Address)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	// Peers dialing us must still be accepted.
+	others[1].Bootstrap(node.Address)
+	others[2].Bootstrap(node.Address)
+	waitForPeers(t, node, 3)
+	assert.Equal(t, 2, countPeers(node, DirectionInbound))
+}
+
+func TestReservedSlotsForPinnedPeers(t *testing.T) {
+	pinned := buildListeningNode(t)
+	defer pinned.Close()
+
+	node := buildListeningNode(t, MaxOutboundPeers(1), ReservedPeers(1), PinnedPeers(pinned.Address))
+	defer node.Close()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	_, err := node.Client(other.Address)
+	assert.Nil(t, err)
+
+	client, err := node.Client(pinned.Address)
+	assert.Nil(t, err)
+	assert.True(t, client.reserved, "pinned peer should occupy a reserved slot")
+}
+
+func TestReservedSlotsForPeersPinnedByID(t *testing.T) {
+	pinned := buildListeningNode(t)
+	defer pinned.Close()
+
+	id, err := PeerIDFromPublicKey(pinned.GetKeys().PublicKey)
+	assert.Nil(t, err)
+
+	node := buildListeningNode(t, MaxOutboundPeers(1), ReservedPeers(1), PinnedPeerIDs(id))
+	defer node.Close()
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	stranger := buildListeningNode(t)
+	defer stranger.Close()
+
+	_, err = node.Client(other.Address)
+	assert.Nil(t, err)
+
+	_, err = node.Client(stranger.Address)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	client, err := node.Client(pinned.Address)
+	assert.Nil(t, err)
+	assert.True(t, client.reserved, "peer pinned by ID should occupy a reserved slot")
+}
@@ -0,0 +1,48 @@
+package network
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// KeepalivePayloadCapability is advertised by nodes which consume application
+// payloads piggybacking on keepalives.
+const KeepalivePayloadCapability = "noise/keepalive-payload"
+
+// MaxKeepalivePayload is the largest application payload, in bytes, which may
+// piggyback on a keepalive or its acknowledgement. Larger payloads are dropped
+// by both the sender and the receiver rather than truncated, as a truncated
+// payload would rarely make sense to the application.
+const MaxKeepalivePayload = 256
+
+// piggyback returns the application payload to piggyback on a keepalive or an
+// acknowledgement sent to a peer, if any.
+func (n *Network) piggyback(client *PeerClient) []byte {
+	if n.opts.keepalivePayload == nil || !client.HasCapability(KeepalivePayloadCapability) {
+		return nil
+	}
+
+	payload := n.opts.keepalivePayload(client.Info())
+	if len(payload) > MaxKeepalivePayload {
+		glog.Warningf("dropping keepalive payload of %d bytes for %s exceeding %d bytes", len(payload), client.Address, MaxKeepalivePayload)
+		return nil
+	}
+
+	return payload
+}
+
+// consumePiggyback hands a payload a peer piggybacked on a keepalive or an
+// acknowledgement over to the registered consumer.
+func (n *Network) consumePiggyback(client *PeerClient, payload []byte, rtt time.Duration) {
+	if n.opts.onKeepalivePayload == nil || len(payload) == 0 {
+		return
+	}
+
+	if len(payload) > MaxKeepalivePayload {
+		glog.Warningf("dropping keepalive payload of %d bytes from %s exceeding %d bytes", len(payload), client.Address, MaxKeepalivePayload)
+		return
+	}
+
+	n.opts.onKeepalivePayload(client.Info(), payload, rtt)
+}
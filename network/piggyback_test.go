@@ -0,0 +1,144 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// piggybacked is a payload handed over to a keepalive payload consumer.
+type piggybacked struct {
+	payload string
+	rtt     time.Duration
+}
+
+// buildProber builds a listening node reading the time off a fake clock.
+func buildProber(t *testing.T, clock *fakeClock, opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	node.now = clock.Now
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestKeepalivePayloadRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	const interval = time.Minute
+
+	clock := &fakeClock{now: time.Now()}
+
+	proberPayloads := make(chan piggybacked, 4)
+	prober := buildProber(t, clock,
+		ReapUnresponsive(interval, 3, 0),
+		KeepalivePayload(func(peer PeerInfo) []byte {
+			return []byte("status from prober")
+		}),
+		OnKeepalivePayload(func(peer PeerInfo, payload []byte, rtt time.Duration) {
+			proberPayloads <- piggybacked{payload: string(payload), rtt: rtt}
+		}),
+	)
+	defer prober.Close()
+
+	peerPayloads := make(chan piggybacked, 4)
+	peer := buildListeningNode(t,
+		KeepalivePayload(func(peer PeerInfo) []byte {
+			// The acknowledgement is only sent back once the prober's clock
+			// moved forward, for the round trip to be measured deterministically.
+			clock.Advance(40 * time.Millisecond)
+			return []byte("status from peer")
+		}),
+		OnKeepalivePayload(func(peer PeerInfo, payload []byte, rtt time.Duration) {
+			peerPayloads <- piggybacked{payload: string(payload), rtt: rtt}
+		}),
+	)
+	defer peer.Close()
+
+	client, err := peer.Client(prober.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	waitForPeers(t, prober, 1)
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(interval)
+		prober.reap(clock.Now())
+
+		select {
+		case p := <-peerPayloads:
+			assert.Equal(t, "status from prober", p.payload)
+		case <-time.After(3 * time.Second):
+			t.Fatal("payload piggybacking on keepalive was not received")
+		}
+
+		select {
+		case p := <-proberPayloads:
+			assert.Equal(t, piggybacked{payload: "status from peer", rtt: 40 * time.Millisecond}, p)
+		case <-time.After(3 * time.Second):
+			t.Fatal("payload piggybacking on acknowledgement was not received")
+		}
+	}
+}
+
+func TestKeepalivePayloadPolicy(t *testing.T) {
+	t.Parallel()
+
+	const interval = time.Minute
+
+	clock := &fakeClock{now: time.Now()}
+
+	provided := bytes.Repeat([]byte{'x'}, MaxKeepalivePayload+1)
+	prober := buildProber(t, clock,
+		ReapUnresponsive(interval, 3, 0),
+		KeepalivePayload(func(peer PeerInfo) []byte {
+			return provided
+		}),
+	)
+	defer prober.Close()
+
+	payloads := make(chan []byte, 4)
+	consumer := buildListeningNode(t, OnKeepalivePayload(func(peer PeerInfo, payload []byte, rtt time.Duration) {
+		payloads <- payload
+	}))
+	defer consumer.Close()
+
+	// Peers not advertising they consume payloads are sent plain keepalives.
+	plain := buildListeningNode(t)
+	defer plain.Close()
+
+	for _, peer := range []*Network{consumer, plain} {
+		client, err := peer.Client(prober.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	}
+	waitForPeers(t, prober, 2)
+
+	consumerClient, _ := prober.peers.Load(consumer.Address)
+	plainClient, _ := prober.peers.Load(plain.Address)
+	assert.Nil(t, prober.piggyback(consumerClient.(*PeerClient)), "oversized payloads should be dropped")
+	assert.Nil(t, prober.piggyback(plainClient.(*PeerClient)))
+
+	provided = []byte("fits")
+	assert.Equal(t, []byte("fits"), prober.piggyback(consumerClient.(*PeerClient)))
+	assert.Nil(t, prober.piggyback(plainClient.(*PeerClient)), "peers without the capability should get plain keepalives")
+
+	clock.Advance(interval)
+	prober.reap(clock.Now())
+
+	select {
+	case payload := <-payloads:
+		assert.Equal(t, []byte("fits"), payload)
+	case <-time.After(3 * time.Second):
+		t.Fatal("payload piggybacking on keepalive was not received")
+	}
+}
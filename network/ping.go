@@ -0,0 +1,170 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultPingTimeout is how long pings sent under a context without a
+	// deadline wait for their pong.
+	defaultPingTimeout = 5 * time.Second
+
+	// defaultPingRate and defaultPingBurst bound how many on-demand pings are
+	// sent to a peer every second.
+	defaultPingRate  = 10
+	defaultPingBurst = 5
+
+	// pingFanOut is how many peers PingAll pings at once.
+	pingFanOut = 16
+)
+
+var (
+	// ErrNotConnected is returned when pinging a peer we are not connected
+	// to, unless peers are dialed on demand through PingDialOnDemand.
+	ErrNotConnected = errors.New("network: not connected to peer")
+	// ErrPingRateLimited is returned when pinging a peer more often than
+	// PingRate allows.
+	ErrPingRateLimited = errors.New("network: peer pinged too often")
+)
+
+// PingResult is the outcome of pinging a peer.
+type PingResult struct {
+	// RTT is the round trip measured to the peer, if it answered.
+	RTT time.Duration
+	// Err is why the peer could not be pinged, if it could not.
+	Err error
+}
+
+// Ping sends a peer a ping right away, over its control connection should it
+// have one, and returns the round trip measured once it answers. The round
+// trip is folded into the one tracked for the peer alongside keepalives.
+// Peers we are not connected to are dialed should PingDialOnDemand be set,
+// and otherwise fail with ErrNotConnected. Pings wait for up to 5 seconds
+// under a context without a deadline.
+func (n *Network) Ping(ctx context.Context, address string) (time.Duration, error) {
+	client, err := n.pingClient(address)
+	if err != nil {
+		return 0, err
+	}
+
+	if client.pings != nil && !client.pings.take(time.Now()) {
+		return 0, ErrPingRateLimited
+	}
+
+	signed, err := n.prepareMessage("", &protobuf.Ping{})
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := defaultPingTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(n.now())
+	}
+
+	r := client.trackRequest()
+	r.urgent = true
+	defer r.close()
+
+	start := n.now()
+	if _, err := r.attempt(ctx, signed, timeout); err != nil {
+		return 0, errors.Wrapf(err, "failed to ping %s", address)
+	}
+	rtt := n.now().Sub(start)
+
+	client.liveness.measured(rtt)
+	return rtt, nil
+}
+
+// PingAll pings every peer we are connected to, up to 16 at once, returning
+// the outcome of every ping keyed by the address of the peer.
+func (n *Network) PingAll(ctx context.Context) map[string]PingResult {
+	var addresses []string
+	n.eachPeer(func(client *PeerClient) bool {
+		addresses = append(addresses, client.Address)
+		return true
+	})
+
+	var (
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]PingResult, len(addresses))
+		slots   = make(chan struct{}, pingFanOut)
+	)
+
+	for _, address := range addresses {
+		address := address
+
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			rtt, err := n.Ping(ctx, address)
+
+			mutex.Lock()
+			results[address] = PingResult{RTT: rtt, Err: err}
+			mutex.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// pingClient returns the client of a peer to ping, dialing it should peers be
+// dialed on demand.
+func (n *Network) pingClient(address string) (*PeerClient, error) {
+	if n.opts.pingDialOnDemand {
+		return n.Client(address)
+	}
+
+	client, exists := n.peers.Load(address)
+	if !exists || !n.ConnectionStateExists(address) {
+		return nil, errors.Wrapf(ErrNotConnected, "failed to ping %s", address)
+	}
+	return client.(*PeerClient), nil
+}
+
+// handlePing answers pings sent as requests with a pong flushed out right
+// away, returning true if a message was one. Pings told to us are handed over
+// to plugins.
+func (n *Network) handlePing(client *PeerClient, name string, message *protobuf.Message) bool {
+	if name != pingName || message.RequestNonce == 0 || message.ReplyFlag {
+		return false
+	}
+
+	if err := client.Reply(message.RequestNonce, &protobuf.Pong{}); err != nil {
+		glog.Warningf("failed to answer ping from %s: %v", client.Address, err)
+		return true
+	}
+
+	n.flushPeer(client.Address)
+	return true
+}
+
+// flushPeer flushes out the messages buffered up for a peer, rather than
+// leaving them to the flusher. Control connections are never buffered up.
+func (n *Network) flushPeer(address string) {
+	state, ok := n.ConnectionState(address)
+	if !ok || state.control != nil {
+		return
+	}
+
+	state.writerMutex.Lock()
+	defer state.writerMutex.Unlock()
+
+	if err := state.writer.Flush(); err != nil {
+		glog.Warningf("failed to flush messages to %s: %v", address, err)
+	}
+	state.flow.setBuffered(state.writer.Buffered())
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// laggyConn delays every write by the latency of its link.
+type laggyConn struct {
+	net.Conn
+	latency time.Duration
+}
+
+func (c *laggyConn) Write(p []byte) (int, error) {
+	time.Sleep(c.latency)
+	return c.Conn.Write(p)
+}
+
+// laggyListener hands out accepted connections delaying every write.
+type laggyListener struct {
+	net.Listener
+	latency time.Duration
+}
+
+func (l *laggyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &laggyConn{Conn: conn, latency: l.latency}, nil
+}
+
+// laggyLink is a TCP transport whose connections, both dialed and accepted,
+// take latency to get every write across.
+type laggyLink struct {
+	*transport.TCP
+	latency time.Duration
+}
+
+func (l *laggyLink) Listen(port int) (net.Listener, error) {
+	listener, err := l.TCP.Listen(port)
+	if err != nil {
+		return nil, err
+	}
+	return &laggyListener{Listener: listener, latency: l.latency}, nil
+}
+
+func (l *laggyLink) Dial(address string) (net.Conn, error) {
+	conn, err := l.TCP.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return &laggyConn{Conn: conn, latency: l.latency}, nil
+}
+
+// buildLaggyNode builds a listening node whose links take latency each way.
+func buildLaggyNode(t *testing.T, latency time.Duration, opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("tcp", &laggyLink{TCP: transport.NewTCP(), latency: latency})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+// approximatesRTT returns true should a round trip measured over links taking
+// latency each way be close to twice the latency.
+func approximatesRTT(rtt, latency time.Duration) bool {
+	return rtt >= 2*latency && rtt < 2*latency+50*time.Millisecond
+}
+
+func TestPingMeasuresLinkLatency(t *testing.T) {
+	t.Parallel()
+
+	const latency = 25 * time.Millisecond
+
+	pinger := buildLaggyNode(t, latency)
+	defer pinger.Close()
+
+	peers := []*Network{buildLaggyNode(t, latency), buildLaggyNode(t, latency)}
+	warmUps := make([]time.Duration, len(peers))
+	for i, peer := range peers {
+		defer peer.Close()
+
+		_, err := pinger.Client(peer.Address)
+		assert.Nil(t, err)
+
+		// The first ping waits on the peer connecting back to us.
+		warmUps[i], err = pinger.Ping(context.Background(), peer.Address)
+		assert.Nil(t, err)
+	}
+
+	// Pings are flushed out right away, rather than waiting on the flusher.
+	for i := 0; i < 3; i++ {
+		rtt, err := pinger.Ping(context.Background(), peers[0].Address)
+		assert.Nil(t, err)
+		assert.True(t, approximatesRTT(rtt, latency), "ping took %s", rtt)
+	}
+
+	client, err := pinger.Client(peers[0].Address)
+	assert.Nil(t, err)
+
+	// Measurements are folded into the round trip the peer is tracked with.
+	smoothed := client.liveness.lastRTT()
+	assert.True(t, smoothed >= 2*latency && smoothed < warmUps[0], "smoothed round trip is %s", smoothed)
+
+	results := pinger.PingAll(context.Background())
+	assert.Len(t, results, len(peers))
+	for _, peer := range peers {
+		result := results[peer.Address]
+		assert.Nil(t, result.Err)
+		assert.True(t, approximatesRTT(result.RTT, latency), "ping to %s took %s", peer.Address, result.RTT)
+	}
+}
+
+func TestPingDisconnectedAndRateLimited(t *testing.T) {
+	t.Parallel()
+
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	// Peers are not dialed unless asked to.
+	strict := buildListeningNode(t)
+	defer strict.Close()
+
+	_, err := strict.Ping(context.Background(), peer.Address)
+	assert.Equal(t, ErrNotConnected, errors.Cause(err))
+
+	dialing := buildListeningNode(t, PingDialOnDemand(), PingRate(1, 1))
+	defer dialing.Close()
+
+	rtt, err := dialing.Ping(context.Background(), peer.Address)
+	assert.Nil(t, err)
+	assert.True(t, rtt > 0)
+	assert.True(t, dialing.ConnectionStateExists(peer.Address))
+
+	_, err = dialing.Ping(context.Background(), peer.Address)
+	assert.Equal(t, ErrPingRateLimited, err)
+}
@@ -0,0 +1,205 @@
+package network
+
+import (
+	"encoding/binary"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// messageNonceTag is the key of the message_nonce field of a serialized
+// message, being field 5 of wire type varint.
+const messageNonceTag = 5<<3 | 0
+
+// sendPipeline prepares messages written asynchronously on a pool of workers,
+// leaving peers' send loops to only write out the prepared bytes.
+type sendPipeline struct {
+	jobs chan func()
+	kill chan struct{}
+}
+
+func newSendPipeline(workers int, kill chan struct{}) *sendPipeline {
+	return &sendPipeline{
+		jobs: make(chan func(), workers*64),
+		kill: kill,
+	}
+}
+
+func (p *sendPipeline) start(exec Executor, workers int, w *watchdog) {
+	for i := 0; i < workers; i++ {
+		exec.Go(func() { p.work(w) })
+	}
+}
+
+func (p *sendPipeline) work(w *watchdog) {
+	h := w.watch(ComponentPipeline, "", nil)
+	defer w.unwatch(h)
+
+	for {
+		select {
+		case job := <-p.jobs:
+			h.beat()
+			job()
+			h.idle()
+		case <-p.kill:
+			drainOrdered(p.jobs)
+			return
+		}
+	}
+}
+
+// submit queues up a job, running it inline should the network shut down.
+func (p *sendPipeline) submit(job func()) {
+	select {
+	case p.jobs <- job:
+	case <-p.kill:
+		job()
+	}
+}
+
+// encodeBody serializes a message without its message nonce, which is only
+// appended once the message is written out so that the same bytes may be
+// written to many peers.
+func encodeBody(message *protobuf.Message) ([]byte, error) {
+	body := *message
+	body.MessageNonce = 0
+
+	bytes, err := proto.Marshal(&body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal message")
+	}
+	return bytes, nil
+}
+
+// prepare sets up a future to be prepared by build, on the send pipeline
+// should there be one. build returns the signed message to write.
+func (n *Network) prepare(address string, f *SendFuture, build func() (*protobuf.Message, error)) {
+	job := func() {
+		defer close(f.prepared)
+
+		message, err := build()
+		if err == nil {
+			message, err = n.enrichMessage(address, message)
+		}
+		if err == nil {
+			f.message = message
+			f.body, err = encodeBody(message)
+		}
+		f.prepareErr = err
+
+		if state, ok := n.ConnectionState(address); ok && err == nil {
+			state.flow.track(f, len(f.body)+4)
+		}
+	}
+
+	if n.pipeline == nil {
+		job()
+		return
+	}
+	n.pipeline.submit(job)
+}
+
+// SendAsync queues up a message to be signed and sent to a denoted target
+// address without blocking, returning a future resolved once the message was
+// written out to the peer's connection. Messages are signed on the send
+// pipeline should SendWorkers be set, and are written out in the order they
+// were queued up either way. Writes may be shed by admission control.
+func (n *Network) SendAsync(address string, message proto.Message, opts ...WriteOption) (*SendFuture, error) {
+	return n.queuePrepared(address, newWriteOptions(n, opts).priority, func() (*protobuf.Message, error) {
+		return n.PrepareMessage(message)
+	})
+}
+
+// queuePrepared queues up a future for a message built by build.
+func (n *Network) queuePrepared(address string, priority Priority, build func() (*protobuf.Message, error)) (*SendFuture, error) {
+	if n.isClosed() {
+		return nil, ErrNetworkClosed
+	}
+
+	state, ok := n.ConnectionState(address)
+	if !ok {
+		return nil, errors.New("network: connection does not exist")
+	}
+
+	if err := n.admit(address, priority); err != nil {
+		return nil, err
+	}
+
+	f := newSendFuture(nil)
+	f.prepared = make(chan struct{})
+
+	// Futures are queued up before being prepared, so that messages are
+	// written out in order regardless of which worker prepares them.
+	if err := state.sends.push(f, n.opts.sendWindowSize); err != nil {
+		return nil, err
+	}
+
+	n.prepare(address, f, build)
+
+	return f, nil
+}
+
+// broadcastPrepared signs and serializes a message once, and queues the same
+// frame up to be written to every peer.
+func (n *Network) broadcastPrepared(message proto.Message) {
+	signed, err := n.PrepareMessage(message)
+	if err != nil {
+		glog.Warningf("failed to prepare broadcast [err=%s]", err)
+		return
+	}
+
+	frame := n.shareFrame(signed)
+	if frame == nil {
+		return
+	}
+
+	n.eachPeer(func(client *PeerClient) bool {
+		state, ok := n.ConnectionState(client.Address)
+		if !ok {
+			return true
+		}
+
+		// Every peer's queue references the same frame.
+		f := newSendFuture(signed)
+		f.body = frame.body
+		f.prepared = make(chan struct{})
+		close(f.prepared)
+
+		if err := state.sends.push(f, n.opts.sendWindowSize); err != nil {
+			glog.Warningf("failed to send message to peer %v [err=%s]", client.id(), err)
+		}
+		return true
+	})
+}
+
+// writePrepared writes out a prepared message, tagged with the next message
+// nonce of the peer's connection.
+func (n *Network) writePrepared(address string, q *sendQueue, f *SendFuture) {
+	<-f.prepared
+	defer q.flow.untrack(f)
+
+	if f.prepareErr != nil {
+		f.resolve(f.prepareErr)
+		return
+	}
+
+	state, ok := n.ConnectionState(address)
+	if !ok {
+		f.resolve(errors.New("network: connection does not exist"))
+		return
+	}
+
+	if err := n.writeBody(address, state, f.message, f.body, f); err != nil {
+		f.resolve(err)
+		return
+	}
+
+	q.written(f)
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var varint [binary.MaxVarintLen64]byte
+	return append(buf, varint[:binary.PutUvarint(varint[:], x)]...)
+}
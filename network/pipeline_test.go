@@ -0,0 +1,190 @@
+package network
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestSendPipelinePreservesOrder(t *testing.T) {
+	t.Parallel()
+
+	const numMessages = 500
+
+	var mutex sync.Mutex
+	var sequence []int
+
+	builder := NewBuilderWithOptions(DispatchWorkers(1, 0))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		i, err := strconv.Atoi(ctx.Message().(*testpb.TestMessage).Message)
+		assert.Nil(t, err)
+
+		mutex.Lock()
+		sequence = append(sequence, i)
+		mutex.Unlock()
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	node := buildListeningNode(t, SendWorkers(8))
+	defer node.Close()
+
+	_, err = node.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	futures := make([]*SendFuture, 0, numMessages)
+	for i := 0; i < numMessages; i++ {
+		f, err := node.SendAsync(receiver.Address, &testpb.TestMessage{Message: fmt.Sprint(i)})
+		assert.Nil(t, err)
+		futures = append(futures, f)
+	}
+
+	for _, f := range futures {
+		<-f.Done()
+		assert.Nil(t, f.Err())
+	}
+
+	assert.True(t, waitUntil(10*time.Second, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(sequence) == numMessages
+	}))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for i, j := range sequence {
+		if !assert.Equal(t, i, j, "messages were written out of order") {
+			break
+		}
+	}
+}
+
+func TestSendAsyncWithoutPipeline(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	f, err := sender.SendAsync(client.Address, &testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+	<-f.Done()
+	assert.Nil(t, f.Err())
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "hello", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message never received")
+	}
+
+	_, err = sender.SendAsync("tcp://localhost:1", &testpb.TestMessage{Message: "hello"})
+	assert.NotNil(t, err)
+}
+
+func TestBroadcastSignsOnce(t *testing.T) {
+	t.Parallel()
+
+	const numPeers = 3
+
+	signatures := make(chan []byte, numPeers)
+
+	node := buildListeningNode(t, SendWorkers(2))
+	defer node.Close()
+
+	for i := 0; i < numPeers; i++ {
+		builder := NewBuilder()
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+			signatures <- append([]byte(nil), ctx.Signature()...)
+		}})
+		peer, err := builder.Build()
+		assert.Nil(t, err)
+		defer peer.Close()
+
+		go peer.Listen()
+		<-peer.Ready()
+
+		_, err = node.Client(peer.Address)
+		assert.Nil(t, err)
+	}
+
+	node.Broadcast(&testpb.TestMessage{Message: "hello"})
+
+	var first []byte
+	for i := 0; i < numPeers; i++ {
+		select {
+		case signature := <-signatures:
+			if first == nil {
+				first = signature
+			}
+			assert.Equal(t, first, signature, "every peer should receive the same signed frame")
+		case <-time.After(3 * time.Second):
+			t.Fatalf("only %d of %d peers received the broadcast", i, numPeers)
+		}
+	}
+}
+
+func benchmarkSendPipeline(b *testing.B, workers int) {
+	const numMessages = 256
+
+	var received atomic.Int32
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		received.Inc()
+	}})
+
+	receiver, err := builder.Build()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(b, SendWorkers(workers))
+	defer sender.Close()
+
+	if _, err := sender.Client(receiver.Address); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		for j := 0; j < numMessages; j++ {
+			if _, err := sender.SendAsync(receiver.Address, &testpb.TestMessage{Message: fmt.Sprint(j)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if !waitUntil(time.Minute, func() bool { return received.Load() == int32(i*numMessages) }) {
+			b.Fatalf("received %d messages", received.Load())
+		}
+	}
+}
+
+func BenchmarkSendPipeline(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkSendPipeline(b, workers)
+		})
+	}
+}
@@ -19,6 +19,12 @@ type PluginInterface interface {
 	PeerDisconnect(client *PeerClient)
 }
 
+// CapabilityAdvertiser may be implemented by plugins which want capabilities
+// advertised to peers during handshakes on their behalf.
+type CapabilityAdvertiser interface {
+	Capabilities() []string
+}
+
 // Plugin is an abstract class which all plugins extend.
 type Plugin struct{}
 
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	noop "github.com/perlin-network/noise/crypto/noop"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPreset names the preset applied by DefaultProfile.
+	DefaultPreset = "default"
+	// StrictPreset names the preset applied by StrictProfile.
+	StrictPreset = "strict"
+)
+
+// presets are the option bundles applied by each preset, on top of the
+// defaults of a builder. Changes to them change the behavior of every node
+// built under them, and are to be made deliberately.
+var presets = map[string][]BuilderOption{
+	DefaultPreset: {
+		ReadBodyTimeout(time.Minute, 1024),
+		MaxConcurrentDials(64),
+	},
+	StrictPreset: {
+		HandshakeTimeout(3 * time.Second),
+		ReadBodyTimeout(10*time.Second, 16*1024),
+		MaxMessageSize(1024 * 1024),
+		MaxPeers(128),
+		MaxConcurrentDials(16),
+		InboundRateLimits(RateLimits{
+			Global: RateLimit{Rate: 5000, Burst: 10000},
+			Prefix: RateLimit{Rate: 500, Burst: 1000},
+			Peer:   RateLimit{Rate: 100, Burst: 200},
+		}),
+		IdempotencyWindow(5*time.Minute, 16384),
+		EnvelopeSigning(SigningCanonical),
+		VerifyAddresses(time.Minute),
+	},
+}
+
+// Profile reports the preset a node was configured with, and which of the
+// settings it applies were overridden since.
+type Profile struct {
+	// Preset is the name of the preset applied last, or empty should no
+	// preset have been applied.
+	Preset string
+	// Overrides are the keys of the config fields, as in Config, whose values
+	// differ from those the preset produces, sorted.
+	Overrides []string
+}
+
+// DefaultProfile returns a BuilderOption applying the default preset: the
+// defaults of a builder, plus safety limits no well-behaved peer ever runs
+// into. Peers trickling message bodies in at under 1KB a second are cut off
+// after a minute, and at most 64 peers are dialed at once.
+func DefaultProfile() BuilderOption {
+	return applyPreset(DefaultPreset)
+}
+
+// StrictProfile returns a BuilderOption applying the strict preset, which
+// enables every security-oriented option with vetted values: handshakes time
+// out after 3 seconds, peers are capped at 128 with 16 dials at once, inbound
+// messages are rate limited and bounded to 1MB, retried requests are
+// remembered for 5 minutes, envelopes are signed and verified over their
+// canonical preimage only, and peers must prove possession of their keys at
+// the addresses they advertise. Strictly profiled nodes do not interoperate
+// with nodes signing over the legacy preimage.
+//
+// Options applied after a profile override it, and are reported by Profile.
+// Overrides disabling what the strict preset demands fail the build.
+func StrictProfile() BuilderOption {
+	return applyPreset(StrictPreset)
+}
+
+func applyPreset(name string) BuilderOption {
+	return func(o *options) {
+		o.profile = name
+		for _, opt := range presets[name] {
+			opt(o)
+		}
+	}
+}
+
+// Profile returns the preset the builder was configured with, and which of
+// its settings were overridden since.
+func (builder *Builder) Profile() Profile {
+	profile := Profile{Preset: builder.opts.profile, Overrides: []string{}}
+	if len(profile.Preset) == 0 {
+		return profile
+	}
+
+	preset := &Builder{opts: defaultBuilderOptions, address: builder.address, transports: builder.transports}
+	applyPreset(profile.Preset)(&preset.opts)
+
+	expected, actual := configFields(preset.Config()), configFields(builder.Config())
+	for key, value := range actual {
+		if string(expected[key]) != string(value) {
+			profile.Overrides = append(profile.Overrides, key)
+		}
+	}
+	sort.Strings(profile.Overrides)
+
+	return profile
+}
+
+// Profile returns the preset the network was built with, and which of its
+// settings were overridden.
+func (n *Network) Profile() Profile {
+	return Profile{Preset: n.profile.Preset, Overrides: append([]string{}, n.profile.Overrides...)}
+}
+
+// configFields returns the encoded value of every field of a config, by key.
+func configFields(cfg Config) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+
+	encoded, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(encoded, &fields)
+	}
+	if err != nil {
+		panic(errors.Wrap(err, "failed to encode config"))
+	}
+
+	return fields
+}
+
+// validateProfile checks options for contradicting the preset they were
+// configured with.
+func (o *options) validateProfile() error {
+	if o.profile != StrictPreset {
+		return nil
+	}
+
+	var contradictions []string
+	if _, ok := o.hashPolicy.(*noop.Noop); ok {
+		contradictions = append(contradictions, "hash_policy")
+	}
+	if o.signingForm != SigningCanonical {
+		contradictions = append(contradictions, "signing_form")
+	}
+	if !o.verifyAddresses {
+		contradictions = append(contradictions, "verify_addresses")
+	}
+	if o.handshakeTimeout <= 0 {
+		contradictions = append(contradictions, "handshake_timeout")
+	}
+	if o.readBodyTimeout <= 0 {
+		contradictions = append(contradictions, "read_body_timeout")
+	}
+	if o.maxMessageSize <= 0 {
+		contradictions = append(contradictions, "max_message_size")
+	}
+	if o.maxPeers <= 0 {
+		contradictions = append(contradictions, "max_peers")
+	}
+	if o.rateLimits.Peer.Rate <= 0 {
+		contradictions = append(contradictions, "peer_rate_limit")
+	}
+
+	if len(contradictions) > 0 {
+		return errors.Errorf("network: overrides of %s contradict the strict profile", strings.Join(contradictions, ", "))
+	}
+
+	return nil
+}
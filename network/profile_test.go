@@ -0,0 +1,133 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	noop "github.com/perlin-network/noise/crypto/noop"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresetValues(t *testing.T) {
+	t.Parallel()
+
+	defaults := NewBuilder().Config()
+
+	tests := []struct {
+		name     string
+		opts     []BuilderOption
+		expected func(cfg *Config)
+		profile  Profile
+	}{
+		{
+			name:     "none",
+			expected: func(cfg *Config) {},
+			profile:  Profile{Overrides: []string{}},
+		},
+		{
+			name: "default",
+			opts: []BuilderOption{DefaultProfile()},
+			expected: func(cfg *Config) {
+				cfg.ReadBodyTimeout = Duration(time.Minute)
+				cfg.ReadBodyMinRate = 1024
+				cfg.MaxConcurrentDials = 64
+			},
+			profile: Profile{Preset: DefaultPreset, Overrides: []string{}},
+		},
+		{
+			name: "strict",
+			opts: []BuilderOption{StrictProfile()},
+			expected: func(cfg *Config) {
+				cfg.HandshakeTimeout = Duration(3 * time.Second)
+				cfg.ReadBodyTimeout = Duration(10 * time.Second)
+				cfg.ReadBodyMinRate = 16 * 1024
+				cfg.MaxMessageSize = 1024 * 1024
+				cfg.MaxPeers = 128
+				cfg.MaxConcurrentDials = 16
+				cfg.GlobalRateLimit, cfg.GlobalRateBurst = 5000, 10000
+				cfg.PrefixRateLimit, cfg.PrefixRateBurst = 500, 1000
+				cfg.PeerRateLimit, cfg.PeerRateBurst = 100, 200
+				cfg.IdempotencyWindow = Duration(5 * time.Minute)
+				cfg.IdempotencySize = 16384
+				cfg.SigningForm = "canonical"
+				cfg.VerifyAddresses = true
+				cfg.VerifyAddressInterval = Duration(time.Minute)
+			},
+			profile: Profile{Preset: StrictPreset, Overrides: []string{}},
+		},
+		{
+			name: "strict with overrides",
+			opts: []BuilderOption{StrictProfile(), MaxPeers(256), HandshakeTimeout(5 * time.Second)},
+			expected: func(cfg *Config) {
+				cfg.HandshakeTimeout = Duration(5 * time.Second)
+				cfg.ReadBodyTimeout = Duration(10 * time.Second)
+				cfg.ReadBodyMinRate = 16 * 1024
+				cfg.MaxMessageSize = 1024 * 1024
+				cfg.MaxPeers = 256
+				cfg.MaxConcurrentDials = 16
+				cfg.GlobalRateLimit, cfg.GlobalRateBurst = 5000, 10000
+				cfg.PrefixRateLimit, cfg.PrefixRateBurst = 500, 1000
+				cfg.PeerRateLimit, cfg.PeerRateBurst = 100, 200
+				cfg.IdempotencyWindow = Duration(5 * time.Minute)
+				cfg.IdempotencySize = 16384
+				cfg.SigningForm = "canonical"
+				cfg.VerifyAddresses = true
+				cfg.VerifyAddressInterval = Duration(time.Minute)
+			},
+			profile: Profile{Preset: StrictPreset, Overrides: []string{"handshake_timeout", "max_peers"}},
+		},
+		{
+			name: "default applied over strict",
+			opts: []BuilderOption{StrictProfile(), DefaultProfile()},
+			expected: func(cfg *Config) {
+				cfg.HandshakeTimeout = Duration(3 * time.Second)
+				cfg.ReadBodyTimeout = Duration(time.Minute)
+				cfg.ReadBodyMinRate = 1024
+				cfg.MaxMessageSize = 1024 * 1024
+				cfg.MaxPeers = 128
+				cfg.MaxConcurrentDials = 64
+				cfg.GlobalRateLimit, cfg.GlobalRateBurst = 5000, 10000
+				cfg.PrefixRateLimit, cfg.PrefixRateBurst = 500, 1000
+				cfg.PeerRateLimit, cfg.PeerRateBurst = 100, 200
+				cfg.IdempotencyWindow = Duration(5 * time.Minute)
+				cfg.IdempotencySize = 16384
+				cfg.SigningForm = "canonical"
+				cfg.VerifyAddresses = true
+				cfg.VerifyAddressInterval = Duration(time.Minute)
+			},
+			profile: Profile{Preset: DefaultPreset, Overrides: []string{
+				"global_rate_burst", "global_rate_limit", "handshake_timeout", "idempotency_size", "idempotency_window",
+				"max_message_size", "max_peers", "peer_rate_burst", "peer_rate_limit", "prefix_rate_burst",
+				"prefix_rate_limit", "signing_form", "verify_address_interval", "verify_addresses",
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewBuilderWithOptions(test.opts...)
+
+			expected := defaults
+			test.expected(&expected)
+
+			assert.Equal(t, expected, builder.Config())
+			assert.Equal(t, test.profile, builder.Profile())
+		})
+	}
+}
+
+func TestStrictProfileContradictions(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBuilderWithOptions(StrictProfile(), HashPolicy(noop.New()), EnvelopeSigning(SigningLegacy), MaxPeers(0)).Build()
+	assert.EqualError(t, err, "network: overrides of hash_policy, signing_form, max_peers contradict the strict profile")
+
+	// Contradictions are only flagged under the strict profile.
+	_, err = NewBuilderWithOptions(DefaultProfile(), HashPolicy(noop.New())).Build()
+	assert.Nil(t, err)
+
+	net, err := NewBuilderWithOptions(StrictProfile(), MaxPeers(64)).Build()
+	assert.Nil(t, err)
+	assert.Equal(t, Profile{Preset: StrictPreset, Overrides: []string{"max_peers"}}, net.Profile())
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// ErrProtocolUnsupported is returned when sending a message under a protocol
+// tag the peer did not advertise.
+var ErrProtocolUnsupported = errors.New("network: peer does not support protocol")
+
+// ProtocolCapability returns the capability peers advertise to receive
+// messages under a protocol tag.
+func ProtocolCapability(tag string) string {
+	return "protocol/" + tag
+}
+
+// ProtocolMessageName returns the name messages of a type sent under a
+// protocol tag are counted under in stats, being just the name of the type
+// under the default protocol.
+func ProtocolMessageName(tag string, name string) string {
+	if tag == "" {
+		return name
+	}
+	return tag + "/" + name
+}
+
+// Protocol sends messages under a protocol tag, so that they are only handed
+// to the plugins the receiving peer registered under the same tag. Several
+// applications may thus share one network without their messages and
+// plugins colliding.
+type Protocol struct {
+	net *Network
+	tag string
+}
+
+// Protocol returns a handle for sending messages under a protocol tag, the
+// empty tag being the default protocol plugins registered through AddPlugin
+// handle.
+func (n *Network) Protocol(tag string) *Protocol {
+	return &Protocol{net: n, tag: tag}
+}
+
+// Tag returns the protocol's tag.
+func (p *Protocol) Tag() string {
+	return p.tag
+}
+
+// Tell asynchronously emits a message to a peer under the protocol. Errors
+// with ErrProtocolUnsupported if the peer did not advertise the protocol.
+func (p *Protocol) Tell(client *PeerClient, message proto.Message) error {
+	return client.tell(p.tag, message)
+}
+
+// Request sends a request to a peer under the protocol, and waits for its
+// response.
+func (p *Protocol) Request(client *PeerClient, req *rpc.Request) (proto.Message, error) {
+	return client.request(p.tag, req)
+}
+
+// RequestContext sends a request to a peer under the protocol, retrying it
+// within the deadline of ctx as PeerClient.RequestContext does.
+func (p *Protocol) RequestContext(ctx context.Context, client *PeerClient, message proto.Message, opts ...RequestOption) (proto.Message, error) {
+	return client.requestContext(ctx, p.tag, message, opts...)
+}
+
+// Broadcast asynchronously broadcasts a message under the protocol to all
+// peers which support it.
+func (p *Protocol) Broadcast(message proto.Message) {
+	signed, err := p.net.prepareMessage(p.tag, message)
+	if err != nil {
+		glog.Warningf("failed to prepare broadcast [err=%s]", err)
+		return
+	}
+
+	p.net.eachPeer(func(client *PeerClient) bool {
+		if !client.SupportsProtocol(p.tag) {
+			return true
+		}
+		if err := p.net.Write(client.Address, signed); err != nil {
+			glog.Warningf("failed to send message to peer %v [err=%s]", client.id(), err)
+		}
+		return true
+	})
+}
+
+// BroadcastExcept is equivalent to Network.BroadcastExcept, sending the
+// message under the protocol to peers which support it.
+func (p *Protocol) BroadcastExcept(message proto.Message, excluded ...peer.ID) {
+	p.net.broadcastExcept(p.tag, message, excluded...)
+}
+
+// BroadcastFanout is equivalent to BroadcastExcept, sending the message to at
+// most fanout peers picked at random, and returns how many peers it was sent
+// to. A fanout of zero sends the message to all peers, as BroadcastExcept does.
+func (p *Protocol) BroadcastFanout(message proto.Message, fanout int, excluded ...peer.ID) int {
+	return p.net.broadcastFanout(p.tag, message, fanout, nil, excluded...)
+}
+
+// BroadcastFanoutToGroups is equivalent to BroadcastFanout, picking healthy
+// peers from the groups of a preference in order. A fanout of zero sends the
+// message to every healthy peer of the first group holding any.
+func (p *Protocol) BroadcastFanoutToGroups(preference GroupPreference, message proto.Message, fanout int, excluded ...peer.ID) int {
+	return p.net.broadcastFanout(p.tag, message, fanout, preference, excluded...)
+}
+
+// SupportsProtocol returns true if the peer advertised it handles messages
+// under a protocol tag. Every peer supports the default protocol.
+func (c *PeerClient) SupportsProtocol(tag string) bool {
+	return tag == "" || c.HasCapability(ProtocolCapability(tag))
+}
+
+// AddProtocolPlugin registers a plugin under a protocol tag, handed only the
+// messages peers sent under the tag. Plugins of the same type may be
+// registered under different tags. The network advertises the tag to peers.
+func (builder *Builder) AddProtocolPlugin(tag string, plugin PluginInterface) error {
+	if tag == "" {
+		return errors.New("network: protocol tag must not be empty")
+	}
+
+	if builder.protocols == nil {
+		builder.protocols = make(map[string]*PluginList)
+	}
+
+	plugins, exists := builder.protocols[tag]
+	if !exists {
+		plugins = NewPluginList()
+		builder.protocols[tag] = plugins
+	}
+
+	if !plugins.Put(builder.pluginCount, plugin) {
+		return errors.Errorf(ErrStrDuplicatePlugin, reflect.TypeOf(plugin).String())
+	}
+	builder.pluginCount++
+
+	return nil
+}
+
+// ProtocolPlugin returns a plugin registered under a protocol tag. The
+// second returning parameter is false should it not be registered.
+func (n *Network) ProtocolPlugin(tag string, key interface{}) (PluginInterface, bool) {
+	if tag == "" {
+		return n.plugins.Get(key)
+	}
+
+	plugins, exists := n.protocols[tag]
+	if !exists {
+		return nil, false
+	}
+	return plugins.Get(key)
+}
+
+// protocolPlugins returns the plugins handling messages under a protocol tag.
+func (n *Network) protocolPlugins(tag string) (*PluginList, bool) {
+	if tag == "" {
+		return n.plugins, true
+	}
+
+	plugins, exists := n.protocols[tag]
+	return plugins, exists
+}
+
+// eachPlugin goes through the plugins of the default protocol, followed by
+// those of every other protocol sorted by tag.
+func (n *Network) eachPlugin(fn func(plugin PluginInterface)) {
+	n.plugins.Each(fn)
+
+	tags := make([]string, 0, len(n.protocols))
+	for tag := range n.protocols {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		n.protocols[tag].Each(fn)
+	}
+}
@@ -0,0 +1,145 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildProtocolNode builds a listening node running fn on every message
+// received under each of a set of protocol tags, alongside the tag.
+func buildProtocolNode(t *testing.T, fn func(tag string, ctx *PluginContext), tags ...string) *Network {
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		fn("", ctx)
+	}})
+
+	for _, tag := range tags {
+		tag := tag
+		assert.Nil(t, builder.AddProtocolPlugin(tag, &handlerPlugin{fn: func(ctx *PluginContext) {
+			fn(tag, ctx)
+		}}))
+	}
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestProtocolsDoNotCrossDeliver(t *testing.T) {
+	t.Parallel()
+
+	type delivery struct {
+		tag      string
+		protocol string
+		message  string
+	}
+	received := make(chan delivery, 16)
+
+	receiver := buildProtocolNode(t, func(tag string, ctx *PluginContext) {
+		msg := ctx.Message().(*testpb.TestMessage)
+		if msg.Message == "request" {
+			ctx.Reply(&testpb.TestMessage{Message: "reply from " + tag})
+			return
+		}
+		received <- delivery{tag: tag, protocol: ctx.Protocol(), message: msg.Message}
+	}, "chain", "telemetry")
+	defer receiver.Close()
+
+	sender := buildProtocolNode(t, func(tag string, ctx *PluginContext) {}, "chain")
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.True(t, client.SupportsProtocol("telemetry"))
+
+	// Both protocols handle the same message type, each under its own tag.
+	assert.Nil(t, sender.Protocol("chain").Tell(client, &testpb.TestMessage{Message: "block"}))
+	assert.Nil(t, sender.Protocol("telemetry").Tell(client, &testpb.TestMessage{Message: "metrics"}))
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "untagged"}))
+
+	deliveries := make(map[string]delivery)
+	for i := 0; i < 3; i++ {
+		select {
+		case d := <-received:
+			deliveries[d.message] = d
+		case <-time.After(3 * time.Second):
+			t.Fatal("message was not delivered")
+		}
+	}
+
+	assert.Equal(t, delivery{tag: "chain", protocol: "chain", message: "block"}, deliveries["block"])
+	assert.Equal(t, delivery{tag: "telemetry", protocol: "telemetry", message: "metrics"}, deliveries["metrics"])
+	assert.Equal(t, delivery{tag: "", protocol: "", message: "untagged"}, deliveries["untagged"])
+
+	select {
+	case d := <-received:
+		t.Fatalf("message %q was delivered twice", d.message)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Replies are sent back under the tag of the request.
+	res, err := sender.Protocol("telemetry").Request(client, &rpc.Request{
+		Message: &testpb.TestMessage{Message: "request"},
+		Timeout: 3 * time.Second,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "reply from telemetry", res.(*testpb.TestMessage).Message)
+
+	// Messages are counted per tag.
+	name := proto.MessageName((*testpb.TestMessage)(nil))
+	assert.True(t, waitUntil(time.Second, func() bool {
+		return receiver.Stats().Snapshot().Messages[ProtocolMessageName("chain", name)].Received == 1
+	}))
+	messages := receiver.Stats().Snapshot().Messages
+	assert.Equal(t, uint64(2), messages[ProtocolMessageName("telemetry", name)].Received)
+	assert.Equal(t, uint64(1), messages[name].Received)
+}
+
+func TestProtocolUnsupported(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 1)
+	receiver := buildListeningNode(t, OnViolation(func(client *PeerClient, err error) {
+		violations <- err
+	}))
+	defer receiver.Close()
+
+	sender := buildProtocolNode(t, func(tag string, ctx *PluginContext) {}, "chain")
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	// Sends to peers which did not advertise a tag fail fast.
+	err = sender.Protocol("chain").Tell(client, &testpb.TestMessage{Message: "block"})
+	assert.Equal(t, ErrProtocolUnsupported, errors.Cause(err))
+
+	_, err = sender.Protocol("chain").Request(client, &rpc.Request{
+		Message: &testpb.TestMessage{Message: "request"},
+		Timeout: time.Second,
+	})
+	assert.Equal(t, ErrProtocolUnsupported, errors.Cause(err))
+
+	// Messages sent under a tag anyway are dropped as a violation.
+	signed, err := sender.prepareMessage("chain", &testpb.TestMessage{Message: "block"})
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Write(client.Address, signed))
+
+	select {
+	case err := <-violations:
+		assert.Equal(t, ErrProtocolUnsupported, errors.Cause(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("message under an unsupported tag was not rejected")
+	}
+}
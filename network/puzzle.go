@@ -0,0 +1,208 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultPuzzleDifficulty is the difficulty of puzzles handed at the
+	// thresholds set through HandshakePuzzles by default, in bits.
+	defaultPuzzleDifficulty = 12
+	// defaultMaxPuzzleDifficulty is the difficulty puzzles grow to under load
+	// by default, in bits.
+	defaultMaxPuzzleDifficulty = 20
+
+	// maxPuzzleDifficulty bounds the difficulty of puzzles, so that dialers
+	// refuse to spin on puzzles no honest acceptor hands out.
+	maxPuzzleDifficulty = 28
+
+	// puzzleSeedSize is the size of the random seed of every puzzle.
+	puzzleSeedSize = 16
+)
+
+// ErrPuzzleFailed is returned when a dialer answers the puzzle it was handed
+// during a handshake with a wrong solution, or with none.
+var ErrPuzzleFailed = errors.New("network: handshake puzzle left unsolved")
+
+// acceptRate estimates how many connections were accepted over the last
+// second, weighing those accepted over the second before by how much of it
+// overlaps with the last second.
+type acceptRate struct {
+	sync.Mutex
+
+	window   time.Time
+	current  int
+	previous int
+}
+
+// rotate moves the window the rate is counted over up to now.
+func (r *acceptRate) rotate(now time.Time) {
+	switch elapsed := now.Sub(r.window); {
+	case elapsed >= 2*time.Second:
+		r.window, r.current, r.previous = now, 0, 0
+	case elapsed >= time.Second:
+		r.window, r.current, r.previous = r.window.Add(time.Second), 0, r.current
+	}
+}
+
+// record counts a connection accepted at now.
+func (r *acceptRate) record(now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate(now)
+	r.current++
+}
+
+// rate returns the number of connections accepted over the second up to now.
+func (r *acceptRate) rate(now time.Time) float64 {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rotate(now)
+	overlap := 1 - float64(now.Sub(r.window))/float64(time.Second)
+	return float64(r.previous)*overlap + float64(r.current)
+}
+
+// puzzlesEnabled returns true if puzzles are handed out under load.
+func (n *Network) puzzlesEnabled() bool {
+	return n.opts.puzzlePending > 0 || n.opts.puzzleRate > 0
+}
+
+// puzzleDifficulty returns the difficulty of the puzzle to hand a dialer
+// given the load inbound handshakes are under, being zero should it be within
+// the thresholds. Difficulty grows by a bit, doubling the work of solving
+// puzzles, with every doubling of the load past the thresholds.
+func (n *Network) puzzleDifficulty() int {
+	var load float64
+	if n.opts.puzzlePending > 0 {
+		load = float64(atomic.LoadInt64(&n.pendingHandshakes)) / float64(n.opts.puzzlePending)
+	}
+	if n.opts.puzzleRate > 0 {
+		if rate := n.accepts.rate(n.now()) / float64(n.opts.puzzleRate); rate > load {
+			load = rate
+		}
+	}
+
+	if load <= 1 {
+		return 0
+	}
+
+	difficulty := n.opts.puzzleDifficulty + int(math.Log2(load))
+	if difficulty > n.opts.puzzleMaxDifficulty {
+		difficulty = n.opts.puzzleMaxDifficulty
+	}
+	return difficulty
+}
+
+// exemptFromPuzzles returns true if a dialer is a pinned peer, either by the
+// public key its handshake was signed with, or by the address it advertises
+// should it connect from the host of that address.
+func (n *Network) exemptFromPuzzles(conn net.Conn, sender *protobuf.ID) bool {
+	if n.isPinnedID(sender.PublicKey) {
+		return true
+	}
+	if !n.isPinned(sender.Address) {
+		return false
+	}
+
+	info, err := ParseAddress(sender.Address)
+	if err != nil {
+		return false
+	}
+	ip := remoteIP(conn.RemoteAddr())
+	return ip != nil && ip.Equal(net.ParseIP(info.Host))
+}
+
+// issuePuzzle returns the puzzle to hand a dialer, being nil should inbound
+// handshakes be within the thresholds, or should the dialer be exempt.
+func (n *Network) issuePuzzle(conn net.Conn, hello *protobuf.Handshake) (*protobuf.HandshakePuzzle, error) {
+	if !n.puzzlesEnabled() {
+		return nil, nil
+	}
+
+	difficulty := n.puzzleDifficulty()
+	if difficulty == 0 {
+		return nil, nil
+	}
+
+	if n.exemptFromPuzzles(conn, hello.Sender) {
+		atomic.AddUint64(&n.puzzlesExempted, 1)
+		return nil, nil
+	}
+
+	seed := make([]byte, puzzleSeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, errors.Wrap(err, "failed to generate puzzle")
+	}
+
+	return &protobuf.HandshakePuzzle{Seed: seed, Difficulty: uint32(difficulty)}, nil
+}
+
+// puzzleHash hashes the seed of a puzzle, the public key of the dialer it was
+// handed to and a candidate solution.
+func (n *Network) puzzleHash(puzzle *protobuf.HandshakePuzzle, publicKey []byte, solution []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(puzzle.Seed)
+	buf.Write(publicKey)
+	buf.Write(solution)
+	return n.opts.hashPolicy.HashBytes(buf.Bytes())
+}
+
+// leadingZeroBits returns the number of zero bits a hash starts with.
+func leadingZeroBits(hash []byte) int {
+	zeros := 0
+	for _, b := range hash {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
+
+// solvePuzzle searches for a solution to a puzzle handed to us, giving up
+// once the handshake would have timed out.
+func (n *Network) solvePuzzle(puzzle *protobuf.HandshakePuzzle) ([]byte, error) {
+	if puzzle.Difficulty > maxPuzzleDifficulty {
+		return nil, errors.Errorf("peer handed a puzzle of difficulty %d, past the most of %d", puzzle.Difficulty, maxPuzzleDifficulty)
+	}
+
+	deadline := time.Now().Add(n.opts.handshakeTimeout)
+	publicKey := n.ID.PublicKey
+	solution := make([]byte, 8)
+
+	for counter := uint64(0); ; counter++ {
+		binary.BigEndian.PutUint64(solution, counter)
+		if leadingZeroBits(n.puzzleHash(puzzle, publicKey, solution)) >= int(puzzle.Difficulty) {
+			return solution, nil
+		}
+
+		if counter%1024 == 1023 && time.Now().After(deadline) {
+			return nil, errors.Errorf("failed to solve puzzle of difficulty %d in time", puzzle.Difficulty)
+		}
+	}
+}
+
+// verifyPuzzle checks the solution a dialer answered the puzzle handed to it
+// with, counting whether it solved it.
+func (n *Network) verifyPuzzle(puzzle *protobuf.HandshakePuzzle, publicKey []byte, solution []byte) error {
+	if len(solution) == 0 || leadingZeroBits(n.puzzleHash(puzzle, publicKey, solution)) < int(puzzle.Difficulty) {
+		atomic.AddUint64(&n.puzzlesFailed, 1)
+		return errors.Wrapf(ErrPuzzleFailed, "puzzle of difficulty %d", puzzle.Difficulty)
+	}
+
+	atomic.AddUint64(&n.puzzlesSolved, 1)
+	return nil
+}
@@ -0,0 +1,150 @@
+package network
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// flood opens connections to a node which never handshake, until as many
+// inbound handshakes are underway.
+func flood(t *testing.T, node *Network, count int) []net.Conn {
+	info, err := ParseAddress(node.Address)
+	assert.Nil(t, err)
+
+	var conns []net.Conn
+	for i := 0; i < count; i++ {
+		conn, err := net.Dial("tcp", info.HostPort())
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return atomic.LoadInt64(&node.pendingHandshakes) >= int64(count)
+	}), "flood never got underway")
+
+	return conns
+}
+
+func TestPuzzleDifficultyGrowsWithLoad(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, HandshakePuzzles(4, 10), PuzzleDifficulty(8, 11))
+	defer node.Close()
+
+	for pending, difficulty := range map[int64]int{0: 0, 4: 0, 5: 8, 8: 9, 16: 10, 64: 11} {
+		atomic.StoreInt64(&node.pendingHandshakes, pending)
+		assert.Equal(t, difficulty, node.puzzleDifficulty(), "%d handshakes pending", pending)
+	}
+	atomic.StoreInt64(&node.pendingHandshakes, 0)
+
+	for i := 0; i < 20; i++ {
+		node.accepts.record(clock.Now())
+	}
+	assert.Equal(t, 9, node.puzzleDifficulty())
+
+	// Accepts age out of the rate over the following second.
+	clock.Advance(1500 * time.Millisecond)
+	assert.Zero(t, node.puzzleDifficulty())
+	clock.Advance(time.Second)
+	assert.Zero(t, node.accepts.rate(clock.Now()))
+}
+
+func TestNoPuzzleUnderLightLoad(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, HandshakePuzzles(2, 50), PuzzleDifficulty(8, 10))
+	defer node.Close()
+
+	dialer := buildListeningNode(t)
+	defer dialer.Close()
+
+	_, err := dialer.Client(node.Address)
+	assert.Nil(t, err)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return atomic.LoadInt64(&node.pendingHandshakes) == 0
+	}), "handshake never completed")
+
+	stats := node.HandshakeStats()
+	assert.Zero(t, stats.PuzzlesIssued)
+	assert.Zero(t, stats.PuzzlesExempted)
+}
+
+func TestPuzzleEnforcedUnderFlood(t *testing.T) {
+	t.Parallel()
+
+	static := buildListeningNode(t)
+	defer static.Close()
+
+	node := buildListeningNode(t, HandshakePuzzles(2, 0), PuzzleDifficulty(8, 10), PinnedPeerIDs(peerIDOfNode(t, static)))
+	defer node.Close()
+
+	for _, conn := range flood(t, node, 4) {
+		defer conn.Close()
+	}
+
+	// Dialers solving the puzzle they are handed connect.
+	dialer := buildListeningNode(t)
+	defer dialer.Close()
+
+	_, err := dialer.Client(node.Address)
+	assert.Nil(t, err)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return node.HandshakeStats().PuzzlesSolved == 1
+	}), "puzzle was never solved")
+	assert.Equal(t, uint64(1), node.HandshakeStats().PuzzlesIssued)
+
+	// Static peers connect without being handed one.
+	_, err = static.Client(node.Address)
+	assert.Nil(t, err)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return node.HandshakeStats().PuzzlesExempted == 1
+	}), "static peer was never exempted")
+	assert.Equal(t, uint64(1), node.HandshakeStats().PuzzlesIssued)
+
+	// Dialers answering wrongly are disconnected.
+	cheater := buildListeningNode(t)
+	defer cheater.Close()
+
+	info, err := ParseAddress(node.Address)
+	assert.Nil(t, err)
+	conn, err := net.Dial("tcp", info.HostPort())
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	offer, err := cheater.localOffer()
+	assert.Nil(t, err)
+	assert.Nil(t, cheater.sendHandshake(conn, &protobuf.Handshake{Offer: offer}))
+
+	reply, err := cheater.receiveHandshake(conn)
+	if !assert.Nil(t, err) || !assert.NotNil(t, reply.Puzzle) {
+		return
+	}
+	assert.True(t, reply.Puzzle.Difficulty >= 9, "difficulty %d", reply.Puzzle.Difficulty)
+
+	wrong := make([]byte, 8)
+	for counter := uint64(0); ; counter++ {
+		binary.BigEndian.PutUint64(wrong, counter)
+		if leadingZeroBits(node.puzzleHash(reply.Puzzle, cheater.ID.PublicKey, wrong)) < int(reply.Puzzle.Difficulty) {
+			break
+		}
+	}
+	assert.Nil(t, cheater.sendHandshake(conn, &protobuf.Handshake{Offer: offer, Echo: reply.Offer, PuzzleSolution: wrong}))
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "cheater should have been disconnected")
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return node.HandshakeStats().PuzzlesFailed == 1
+	}), "puzzle never failed")
+	assert.Equal(t, uint64(2), node.HandshakeStats().PuzzlesIssued)
+	assert.Equal(t, uint64(1), node.HandshakeStats().PuzzlesSolved)
+}
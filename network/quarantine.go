@@ -0,0 +1,136 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// quarantine tracks a newly connected peer while it is on probation. A
+// quarantined peer may send and receive messages as usual, though it is left
+// out of broadcast fanout and routing tables until it graduates.
+type quarantine struct {
+	sync.Mutex
+
+	active   bool
+	since    time.Time
+	received int
+}
+
+// quarantineEnabled returns true if new peers are put under quarantine.
+func (n *Network) quarantineEnabled() bool {
+	return n.opts.quarantinePeriod > 0 || n.opts.quarantineMessages > 0
+}
+
+// startQuarantine puts a newly connected peer under quarantine, unless it is pinned.
+func (n *Network) startQuarantine(c *PeerClient) {
+	if !n.quarantineEnabled() || n.isPinnedPeer(c) {
+		return
+	}
+
+	c.quarantine.Lock()
+	c.quarantine.active = true
+	c.quarantine.since = n.now()
+	c.quarantine.Unlock()
+
+	if n.opts.quarantinePeriod > 0 {
+		n.spawnIn(SubsystemPeers, func() { n.watchQuarantine(c) })
+	}
+}
+
+// watchQuarantine graduates a quarantined peer once its quarantine period
+// elapses, as told by the network's clock, rather than only once it next
+// sends us a message. Quarantines restarted in the meantime are waited out.
+func (n *Network) watchQuarantine(c *PeerClient) {
+	for {
+		c.quarantine.Lock()
+		active := c.quarantine.active
+		remaining := n.opts.quarantinePeriod - n.now().Sub(c.quarantine.since)
+		c.quarantine.Unlock()
+
+		if !active {
+			return
+		}
+
+		if remaining <= 0 {
+			n.checkQuarantine(c, 0)
+			return
+		}
+
+		select {
+		case <-n.after(remaining):
+		case <-c.closeSignal:
+			return
+		case <-n.kill:
+			return
+		}
+	}
+}
+
+// Quarantined returns true if the peer has yet to graduate from the
+// quarantine newly connected peers are put under.
+func (c *PeerClient) Quarantined() bool {
+	c.quarantine.Lock()
+	defer c.quarantine.Unlock()
+
+	return c.quarantine.active
+}
+
+// eachFanoutPeer calls fn on every peer messages may be fanned out to, until
+// fn returns false. Peers under quarantine are left out of fanout, be it to
+// all peers, to random ones or to those picked by group or tag, until they
+// graduate from it.
+func (n *Network) eachFanoutPeer(fn func(client *PeerClient) bool) {
+	n.eachPeer(func(client *PeerClient) bool {
+		if client.Quarantined() {
+			return true
+		}
+		return fn(client)
+	})
+}
+
+// checkQuarantine counts received messages towards a peer's quarantine, and
+// graduates the peer once it has behaved for long enough.
+func (n *Network) checkQuarantine(c *PeerClient, received int) bool {
+	c.quarantine.Lock()
+
+	if !c.quarantine.active {
+		c.quarantine.Unlock()
+		return false
+	}
+
+	c.quarantine.received += received
+
+	// A peer which never got back to us has not proven itself responsive.
+	responsive := false
+	select {
+	case <-c.incomingReady:
+		responsive = true
+	default:
+	}
+
+	graduated := responsive &&
+		n.now().Sub(c.quarantine.since) >= n.opts.quarantinePeriod &&
+		c.quarantine.received >= n.opts.quarantineMessages
+
+	if graduated {
+		c.quarantine.active = false
+	}
+
+	c.quarantine.Unlock()
+
+	if graduated && n.opts.onPeerGraduated != nil {
+		n.opts.onPeerGraduated(c)
+	}
+
+	return !graduated
+}
+
+// restartQuarantine restarts the quarantine of a peer which misbehaved.
+func (n *Network) restartQuarantine(c *PeerClient) {
+	c.quarantine.Lock()
+	if c.quarantine.active {
+		c.quarantine.since = n.now()
+		c.quarantine.received = 0
+	}
+	c.quarantine.Unlock()
+}
@@ -0,0 +1,184 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// fakeClock is a clock which only moves forward when told to.
+type fakeClock struct {
+	sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+// clockWaiter is a channel the time is sent on once the clock reaches at.
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// After returns a channel the time is sent on once the clock is advanced by d.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, clockWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Waiters returns how many channels returned by After are yet to fire.
+func (c *fakeClock) Waiters() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.waiters)
+}
+
+// countingPlugin counts all received test messages.
+type countingPlugin struct {
+	*Plugin
+	received atomic.Int32
+}
+
+func (p *countingPlugin) Receive(ctx *PluginContext) error {
+	if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+		p.received.Inc()
+	}
+	return nil
+}
+
+func TestQuarantine(t *testing.T) {
+	t.Parallel()
+
+	build := func(plugin PluginInterface, address string, opts ...BuilderOption) *Network {
+		builder := NewBuilderWithOptions(opts...)
+		builder.SetAddress(address)
+		builder.AddPlugin(plugin)
+		node, err := builder.Build()
+		assert.Nil(t, err)
+		return node
+	}
+
+	strangerPlugin, pinnedPlugin := new(countingPlugin), new(countingPlugin)
+
+	stranger := build(strangerPlugin, FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	defer stranger.Close()
+
+	pinned := build(pinnedPlugin, FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	defer pinned.Close()
+
+	graduated := make(chan string, 2)
+	clock := &fakeClock{now: time.Now()}
+
+	node := build(new(Plugin), FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())),
+		QuarantinePeriod(time.Minute),
+		PinnedPeers(pinned.Address),
+		OnPeerGraduated(func(client *PeerClient) { graduated <- client.Address }),
+	)
+	node.now = clock.Now
+	node.after = clock.After
+	defer node.Close()
+
+	for _, n := range []*Network{stranger, pinned, node} {
+		go n.Listen()
+		<-n.Ready()
+	}
+
+	for _, n := range []*Network{stranger, pinned} {
+		client, err := n.Client(node.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	}
+	waitForPeers(t, node, 2)
+
+	quarantined := func() map[string]bool {
+		status := make(map[string]bool)
+		for _, info := range node.Peers() {
+			status[info.Address] = info.Quarantined
+		}
+		return status
+	}
+
+	assert.Equal(t, map[string]bool{stranger.Address: true, pinned.Address: false}, quarantined())
+
+	for i := 1; i <= 5; i++ {
+		node.BroadcastRandomly(&testpb.TestMessage{Message: "fanout"}, 2)
+		assert.True(t, waitUntil(3*time.Second, func() bool { return pinnedPlugin.received.Load() == int32(i) }))
+	}
+	assert.Equal(t, int32(0), strangerPlugin.received.Load(), "quarantined peer should be left out of fanout")
+
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, map[string]bool{stranger.Address: true, pinned.Address: false}, quarantined())
+
+	clock.Advance(time.Second)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return !quarantined()[stranger.Address]
+	}), "quarantine period elapsed without the peer graduating")
+	assert.Equal(t, map[string]bool{stranger.Address: false, pinned.Address: false}, quarantined())
+
+	select {
+	case address := <-graduated:
+		assert.Equal(t, stranger.Address, address)
+	case <-time.After(time.Second):
+		t.Fatal("graduation was never reported")
+	}
+	assert.Equal(t, 0, len(graduated), "pinned peers never serve quarantine")
+
+	node.BroadcastRandomly(&testpb.TestMessage{Message: "fanout"}, 2)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return strangerPlugin.received.Load() == 1 }))
+}
+
+func TestQuarantinedDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	graduated := make(chan struct{}, 1)
+
+	builder := NewBuilderWithOptions(QuarantinePeriod(time.Nanosecond), OnPeerGraduated(func(*PeerClient) { graduated <- struct{}{} }))
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	// The peer never got back to us, so it may not graduate.
+	client := newPeerClient(node, "tcp://127.0.0.1:1")
+	node.startQuarantine(client)
+
+	start := time.Now()
+	assert.True(t, client.Quarantined())
+	assert.True(t, client.Info().Quarantined)
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "reading whether a peer is quarantined should not wait on it")
+
+	select {
+	case <-graduated:
+		t.Fatal("unresponsive peer graduated")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,383 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultRateLimitPrefixV4 is the length of the prefix IPv4 peers are
+	// grouped under for per-prefix rate limits.
+	defaultRateLimitPrefixV4 = 24
+	// defaultRateLimitPrefixV6 is the length of the prefix IPv6 peers are
+	// grouped under for per-prefix rate limits.
+	defaultRateLimitPrefixV6 = 48
+
+	// minPrefixBuckets is how many per-prefix buckets are held before idle
+	// ones are first pruned.
+	minPrefixBuckets = 64
+)
+
+// RateLimitLevel is a level of the hierarchy of inbound rate limits.
+type RateLimitLevel int
+
+const (
+	// RateLimitGlobal limits messages received across all peers.
+	RateLimitGlobal RateLimitLevel = iota
+	// RateLimitPrefix limits messages received across all peers connecting
+	// from within the same IP prefix.
+	RateLimitPrefix
+	// RateLimitPeer limits messages received from a single peer.
+	RateLimitPeer
+)
+
+func (l RateLimitLevel) String() string {
+	switch l {
+	case RateLimitGlobal:
+		return "global"
+	case RateLimitPrefix:
+		return "prefix"
+	case RateLimitPeer:
+		return "peer"
+	default:
+		return "RateLimitLevel(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// RateLimit is a rate of messages per second, allowing bursts of up to Burst
+// messages. A rate of 0 leaves messages unlimited, and a burst of 0 defaults
+// to the rate.
+type RateLimit struct {
+	Rate  int
+	Burst int
+}
+
+// RateLimits configures the hierarchy of limits on the rate of messages read
+// from peers.
+type RateLimits struct {
+	// Global limits messages received across all peers.
+	Global RateLimit
+	// Prefix limits messages received across all peers connecting from within
+	// the same IP prefix.
+	Prefix RateLimit
+	// Peer limits messages received from every single peer.
+	Peer RateLimit
+
+	// PrefixV4 and PrefixV6 are the lengths of the prefixes IPv4 and IPv6
+	// peers are grouped under (default: 24 and 48).
+	PrefixV4 int
+	PrefixV6 int
+}
+
+func (l RateLimits) validate() error {
+	for _, limit := range []RateLimit{l.Global, l.Prefix, l.Peer} {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return errors.New("network: rate limits must not be negative")
+		}
+	}
+	if l.PrefixV4 < 0 || l.PrefixV4 > 32 {
+		return errors.Errorf("network: IPv4 rate limit prefix /%d is invalid", l.PrefixV4)
+	}
+	if l.PrefixV6 < 0 || l.PrefixV6 > 128 {
+		return errors.Errorf("network: IPv6 rate limit prefix /%d is invalid", l.PrefixV6)
+	}
+	return nil
+}
+
+// withDefaults returns the limits with unset prefix lengths defaulted.
+func (l RateLimits) withDefaults() RateLimits {
+	if l.PrefixV4 == 0 {
+		l.PrefixV4 = defaultRateLimitPrefixV4
+	}
+	if l.PrefixV6 == 0 {
+		l.PrefixV6 = defaultRateLimitPrefixV6
+	}
+	return l
+}
+
+// RateLimitLevelStats counts messages delayed by a level of the rate limits.
+type RateLimitLevelStats struct {
+	// Buckets is the number of buckets currently held for the level.
+	Buckets int
+	// Throttled is the number of messages delayed with the level being the
+	// most specific one exhausted.
+	Throttled uint64
+	// Delay is the total time those messages were delayed for.
+	Delay time.Duration
+}
+
+// RateLimitStats counts messages delayed by every level of the rate limits.
+type RateLimitStats struct {
+	Global RateLimitLevelStats
+	Prefix RateLimitLevelStats
+	Peer   RateLimitLevelStats
+}
+
+// rateLimiter holds the token buckets of every level of the rate limits.
+// Every connection waits out at most one message at a time, so that peers
+// sharing a bucket take turns at it rather than one crowding out the others.
+type rateLimiter struct {
+	sync.Mutex
+
+	limits RateLimits
+
+	global   *tokenBucket
+	prefixes map[string]*tokenBucket
+	peers    map[*PeerClient]*tokenBucket
+
+	// Number of prefix buckets held at which idle ones are next pruned.
+	pruneAt int
+
+	// peerLimit returns the limit of a peer in place of the per-peer limit,
+	// should it be overridden such as for the peer's tags.
+	peerLimit func(client *PeerClient, limit RateLimit) RateLimit
+
+	stats [3]RateLimitLevelStats
+}
+
+func newRateLimiter(limits RateLimits) *rateLimiter {
+	limits = limits.withDefaults()
+
+	return &rateLimiter{
+		limits:   limits,
+		global:   newTokenBucket(limits.Global.Rate, limits.Global.Burst),
+		prefixes: make(map[string]*tokenBucket),
+		peers:    make(map[*PeerClient]*tokenBucket),
+		pruneAt:  minPrefixBuckets,
+	}
+}
+
+// prefixOf returns the IP prefix a peer connecting from ip is grouped under,
+// or an empty string should ip be unknown.
+func (l *rateLimiter) prefixOf(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(l.limits.PrefixV4, 32)).String() + "/" + strconv.Itoa(l.limits.PrefixV4)
+	}
+	if len(ip) == net.IPv6len {
+		return ip.Mask(net.CIDRMask(l.limits.PrefixV6, 128)).String() + "/" + strconv.Itoa(l.limits.PrefixV6)
+	}
+	return ""
+}
+
+// reserve takes a message from every bucket a peer connecting from ip draws
+// from, returning how long to wait before handling it and the most specific
+// level exhausted.
+func (l *rateLimiter) reserve(now time.Time, client *PeerClient, ip net.IP) (time.Duration, RateLimitLevel) {
+	l.Lock()
+	defer l.Unlock()
+
+	var delays [3]time.Duration
+
+	delays[RateLimitGlobal] = l.global.reserve(now, 1)
+
+	if l.limits.Prefix.Rate > 0 {
+		if prefix := l.prefixOf(ip); prefix != "" {
+			bucket, exists := l.prefixes[prefix]
+			if !exists {
+				l.prunePrefixes(now)
+
+				bucket = newTokenBucket(l.limits.Prefix.Rate, l.limits.Prefix.Burst)
+				l.prefixes[prefix] = bucket
+			}
+			delays[RateLimitPrefix] = bucket.reserve(now, 1)
+		}
+	}
+
+	bucket, exists := l.peers[client]
+	if !exists {
+		if limit := l.limitOf(client); limit.Rate > 0 {
+			bucket = newTokenBucket(limit.Rate, limit.Burst)
+			l.peers[client] = bucket
+		}
+	}
+	if bucket != nil {
+		delays[RateLimitPeer] = bucket.reserve(now, 1)
+	}
+
+	var delay time.Duration
+	level := RateLimitGlobal
+
+	for current, d := range delays {
+		if d > 0 {
+			level = RateLimitLevel(current)
+		}
+		if d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		l.stats[level].Throttled++
+		l.stats[level].Delay += delay
+	}
+
+	return delay, level
+}
+
+// prunePrefixes drops the buckets of prefixes which have been idle for long
+// enough to be full, as they hold nothing a new bucket would not, should
+// enough of them be held.
+func (l *rateLimiter) prunePrefixes(now time.Time) {
+	if len(l.prefixes) < l.pruneAt {
+		return
+	}
+
+	for prefix, bucket := range l.prefixes {
+		if bucket.full(now) {
+			delete(l.prefixes, prefix)
+		}
+	}
+
+	l.pruneAt = 2 * len(l.prefixes)
+	if l.pruneAt < minPrefixBuckets {
+		l.pruneAt = minPrefixBuckets
+	}
+}
+
+// limitOf returns the per-peer limit of a peer.
+func (l *rateLimiter) limitOf(client *PeerClient) RateLimit {
+	if l.peerLimit != nil {
+		return l.peerLimit(client, l.limits.Peer)
+	}
+	return l.limits.Peer
+}
+
+// retune applies the per-peer limit of a peer whose limit was overridden
+// anew to its bucket, keeping any debt owed.
+func (l *rateLimiter) retune(now time.Time, client *PeerClient) {
+	l.Lock()
+	defer l.Unlock()
+
+	bucket, exists := l.peers[client]
+	if !exists {
+		return
+	}
+
+	if limit := l.limitOf(client); limit.Rate > 0 {
+		bucket.set(now, limit.Rate, limit.Burst)
+	} else {
+		delete(l.peers, client)
+	}
+}
+
+// forget drops the bucket of a peer which disconnected.
+func (l *rateLimiter) forget(client *PeerClient) {
+	l.Lock()
+	delete(l.peers, client)
+	l.Unlock()
+}
+
+// set changes the limits of every level. Buckets keep any debt owed, unless
+// the prefix lengths changed, in which case peers are regrouped into fresh
+// prefix buckets.
+func (l *rateLimiter) set(now time.Time, limits RateLimits) {
+	limits = limits.withDefaults()
+
+	l.Lock()
+	defer l.Unlock()
+
+	regroup := limits.PrefixV4 != l.limits.PrefixV4 || limits.PrefixV6 != l.limits.PrefixV6
+	l.limits = limits
+
+	l.global.set(now, limits.Global.Rate, limits.Global.Burst)
+
+	if regroup || limits.Prefix.Rate <= 0 {
+		l.prefixes = make(map[string]*tokenBucket)
+		l.pruneAt = minPrefixBuckets
+	}
+	for _, bucket := range l.prefixes {
+		bucket.set(now, limits.Prefix.Rate, limits.Prefix.Burst)
+	}
+
+	for client, bucket := range l.peers {
+		if limit := l.limitOf(client); limit.Rate > 0 {
+			bucket.set(now, limit.Rate, limit.Burst)
+		} else {
+			delete(l.peers, client)
+		}
+	}
+}
+
+func (l *rateLimiter) snapshot() RateLimitStats {
+	l.Lock()
+	defer l.Unlock()
+
+	stats := RateLimitStats{
+		Global: l.stats[RateLimitGlobal],
+		Prefix: l.stats[RateLimitPrefix],
+		Peer:   l.stats[RateLimitPeer],
+	}
+
+	if l.limits.Global.Rate > 0 {
+		stats.Global.Buckets = 1
+	}
+	stats.Prefix.Buckets = len(l.prefixes)
+	stats.Peer.Buckets = len(l.peers)
+
+	return stats
+}
+
+// remoteIP returns the IP address of the remote end of a connection, or nil
+// should it not have one.
+func remoteIP(addr net.Addr) net.IP {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// limitInbound waits until a message read from a peer connecting from ip may
+// be handled without exceeding the global, the peer's prefix nor the peer's
+// rate limit.
+func (n *Network) limitInbound(client *PeerClient, ip net.IP) {
+	delay, level := n.limiter.reserve(time.Now(), client, ip)
+	if delay <= 0 {
+		return
+	}
+
+	if n.opts.onRateLimited != nil {
+		n.opts.onRateLimited(client, level, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-client.closeSignal:
+	case <-n.kill:
+	}
+}
+
+// SetRateLimits changes the limits on the rate of messages read from peers
+// at runtime, such as to respond to an incident. Buckets keep any debt owed,
+// unless the prefix lengths changed, in which case peers are regrouped into
+// fresh prefix buckets.
+func (n *Network) SetRateLimits(limits RateLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+
+	n.limiter.set(time.Now(), limits)
+	return nil
+}
+
+// RateLimits returns the limits on the rate of messages read from peers.
+func (n *Network) RateLimits() RateLimits {
+	n.limiter.Lock()
+	defer n.limiter.Unlock()
+
+	return n.limiter.limits
+}
+
+// RateLimitStats returns how many messages read from peers were delayed by
+// every level of the rate limits, and for how long. Delays are attributed to
+// the most specific level exhausted.
+func (n *Network) RateLimitStats() RateLimitStats {
+	return n.limiter.snapshot()
+}
@@ -0,0 +1,144 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+// sourceLink is a TCP transport dialing peers from a loopback address of its
+// own, so that nodes appear to connect from different hosts.
+type sourceLink struct {
+	*transport.TCP
+	source string
+}
+
+func (l *sourceLink) Dial(address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := net.ResolveTCPAddr("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTCP("tcp", &net.TCPAddr{IP: net.ParseIP(l.source)}, remote)
+}
+
+func buildNodeFrom(t *testing.T, source string) *Network {
+	builder := NewBuilder()
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("tcp", &sourceLink{TCP: transport.NewTCP(), source: source})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestRateLimitsBindPrefix(t *testing.T) {
+	t.Parallel()
+
+	const (
+		rate     = 20
+		messages = 20
+	)
+
+	var mutex sync.Mutex
+	received := make(map[string]int)
+
+	counts := func(senders ...*Network) []int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		var counts []int
+		for _, sender := range senders {
+			counts = append(counts, received[sender.Address])
+		}
+		return counts
+	}
+
+	var levels sync.Map
+
+	receiver, _, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		mutex.Lock()
+		received[ctx.Client().Address]++
+		mutex.Unlock()
+	}, InboundRateLimits(RateLimits{Prefix: RateLimit{Rate: rate, Burst: 1}}), OnRateLimited(func(client *PeerClient, level RateLimitLevel, delay time.Duration) {
+		levels.Store(level, struct{}{})
+	}))
+	defer receiver.Close()
+
+	// Three peers connect from within the same /24, and one from elsewhere.
+	var group []*Network
+	for i := 2; i < 5; i++ {
+		node := buildNodeFrom(t, "127.0.0."+strconv.Itoa(i))
+		defer node.Close()
+		group = append(group, node)
+	}
+	outsider := buildNodeFrom(t, "127.0.1.2")
+	defer outsider.Close()
+
+	var clients []*PeerClient
+	for _, sender := range append(group, outsider) {
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+		clients = append(clients, client)
+	}
+
+	for _, client := range clients {
+		for i := 0; i < messages; i++ {
+			assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "flood"}))
+		}
+	}
+
+	// The outsider is unaffected by the prefix cap binding the group.
+	assert.True(t, waitUntil(time.Second, func() bool {
+		return counts(outsider)[0] == messages
+	}))
+
+	time.Sleep(500 * time.Millisecond)
+
+	// Peers within the group share their prefix's budget fairly.
+	total := 0
+	for _, count := range counts(group...) {
+		assert.True(t, count >= 4 && count < messages, "peer received %d messages", count)
+		total += count
+	}
+	assert.True(t, total < 3*rate/2+3, "group received %d messages", total)
+
+	stats := receiver.RateLimitStats()
+	assert.True(t, stats.Prefix.Throttled > 0)
+	assert.Equal(t, 2, stats.Prefix.Buckets)
+	assert.Zero(t, stats.Global.Throttled)
+	assert.Zero(t, stats.Peer.Throttled)
+
+	_, prefix := levels.Load(RateLimitPrefix)
+	assert.True(t, prefix)
+
+	// Lifting the limits at runtime lets the rest of the group through.
+	assert.Nil(t, receiver.SetRateLimits(RateLimits{}))
+	assert.True(t, waitUntil(time.Second, func() bool {
+		for _, count := range counts(group...) {
+			if count < messages {
+				return false
+			}
+		}
+		return true
+	}))
+
+	assert.Equal(t, RateLimits{PrefixV4: 24, PrefixV6: 48}, receiver.RateLimits())
+	assert.NotNil(t, receiver.SetRateLimits(RateLimits{PrefixV4: 33}))
+}
@@ -0,0 +1,244 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+)
+
+// ReachabilityCapability is advertised by nodes which answer reachability
+// probes, dialing their peers back at the addresses they advertise.
+const ReachabilityCapability = "noise/reachability"
+
+const (
+	// defaultReachabilityTimeout is how long a reachability probe waits for
+	// the peer to dial us back and answer.
+	defaultReachabilityTimeout = 10 * time.Second
+
+	// reachabilityRecheckInterval is how long after the latest probe another
+	// newly connected peer is probed, once reachability is determined.
+	reachabilityRecheckInterval = 10 * time.Minute
+
+	// reachabilityAnswerRate and reachabilityAnswerBurst bound how many peers
+	// are dialed back every second on behalf of their probes.
+	reachabilityAnswerRate  = 1
+	reachabilityAnswerBurst = 4
+)
+
+var reachabilityProbeName = proto.MessageName((*protobuf.ReachabilityProbe)(nil))
+
+// Reachability describes whether peers are able to dial this node at the
+// address it advertises.
+type Reachability int
+
+const (
+	// ReachabilityUnknown is reported until enough peers were probed.
+	ReachabilityUnknown Reachability = iota
+	// ReachabilityPublic is reported should most probed peers have been able
+	// to dial us back.
+	ReachabilityPublic
+	// ReachabilityPrivate is reported should most probed peers have failed to
+	// dial us back, as when a firewall drops inbound connections or the
+	// advertised port is wrong.
+	ReachabilityPrivate
+)
+
+func (r Reachability) String() string {
+	switch r {
+	case ReachabilityPublic:
+		return "public"
+	case ReachabilityPrivate:
+		return "private"
+	default:
+		return "unknown"
+	}
+}
+
+// reachabilityCheck tracks the outcomes of the latest reachability probes,
+// and the peers probed so far.
+type reachabilityCheck struct {
+	sync.Mutex
+
+	probed   map[string]struct{}
+	outcomes []bool
+	pending  int
+	last     time.Time
+	verdict  Reachability
+}
+
+// due returns true if another peer should be probed: until as many probes as
+// were asked for complete, and every so often afterwards.
+func (c *reachabilityCheck) due(now time.Time, probes int) bool {
+	if c.verdict == ReachabilityUnknown {
+		return len(c.outcomes)+c.pending < probes
+	}
+	return c.pending == 0 && now.Sub(c.last) >= reachabilityRecheckInterval
+}
+
+// record records the outcome of a probe, keeping those of the latest probes,
+// and returns the verdict before and after.
+func (c *reachabilityCheck) record(now time.Time, probes int, reachable bool) (previous, current Reachability) {
+	c.outcomes = append(c.outcomes, reachable)
+	if len(c.outcomes) > probes {
+		c.outcomes = c.outcomes[len(c.outcomes)-probes:]
+	}
+	c.last = now
+
+	previous = c.verdict
+	if len(c.outcomes) == probes {
+		reached := 0
+		for _, outcome := range c.outcomes {
+			if outcome {
+				reached++
+			}
+		}
+
+		c.verdict = ReachabilityPrivate
+		if 2*reached > probes {
+			c.verdict = ReachabilityPublic
+		}
+	}
+	return previous, c.verdict
+}
+
+// Reachability returns whether peers are able to dial us at the address we
+// advertise, as judged by the latest probes made by ReachabilityCheck. It is
+// ReachabilityUnknown until as many probes as were asked for complete.
+func (n *Network) Reachability() Reachability {
+	c := &n.reachability
+	c.Lock()
+	defer c.Unlock()
+
+	return c.verdict
+}
+
+// probeReachability asks a newly connected peer to dial us back at the
+// address we advertise, should ReachabilityCheck be set and another probe be
+// due. Peers are only ever probed once.
+func (n *Network) probeReachability(client *PeerClient) {
+	if n.opts.reachabilityProbes <= 0 || !client.HasCapability(ReachabilityCapability) {
+		return
+	}
+
+	c := &n.reachability
+	c.Lock()
+
+	if _, probed := c.probed[client.Address]; probed || !c.due(n.now(), n.opts.reachabilityProbes) {
+		c.Unlock()
+		return
+	}
+
+	if c.probed == nil {
+		c.probed = make(map[string]struct{})
+	}
+	c.probed[client.Address] = struct{}{}
+	c.pending++
+
+	c.Unlock()
+
+	n.spawn(func() {
+		reachable, conclusive := n.sendReachabilityProbe(client)
+
+		c.Lock()
+		c.pending--
+		if !conclusive {
+			c.Unlock()
+			return
+		}
+		previous, current := c.record(n.now(), n.opts.reachabilityProbes, reachable)
+		c.Unlock()
+
+		if previous != current {
+			glog.Infof("network: reachable at %s: %s", n.Address, current)
+
+			if n.opts.onReachabilityChanged != nil {
+				n.opts.onReachabilityChanged(previous, current)
+			}
+		}
+	})
+}
+
+// sendReachabilityProbe asks a peer to dial us back, returning whether it
+// could, and whether the probe was conclusive. Peers unable to dial us back
+// drop our session along with the probe, so probes left unanswered count as
+// failed, unlike those the peer refused.
+func (n *Network) sendReachabilityProbe(client *PeerClient) (reachable bool, conclusive bool) {
+	res, err := client.Request(&rpc.Request{
+		Message: &protobuf.ReachabilityProbe{},
+		Timeout: n.opts.reachabilityTimeout,
+	})
+	if n.isClosed() {
+		return false, false
+	}
+	if err != nil {
+		glog.Warningf("network: reachability probe through %s failed: %v", client.Address, err)
+		return false, true
+	}
+
+	result, ok := res.(*protobuf.ReachabilityResult)
+	if !ok || result.Refused {
+		return false, false
+	}
+	return result.Reachable, true
+}
+
+// handleReachabilityProbe dials a peer back at the address it advertises on
+// its behalf, answering with whether it answered there, and returns true if a
+// message was a reachability probe. Peers are only dialed back at addresses on
+// the host they connected to us from, or at addresses verified already, and
+// at a limited rate, so that probes may not have us dial arbitrary hosts.
+func (n *Network) handleReachabilityProbe(client *PeerClient, name string, message *protobuf.Message) bool {
+	if name != reachabilityProbeName || message.RequestNonce == 0 || message.ReplyFlag {
+		return false
+	}
+
+	nonce := message.RequestNonce
+	n.spawn(func() {
+		result := &protobuf.ReachabilityResult{}
+		if !n.mayDialBack(client) || !n.probeAnswers.take(time.Now()) {
+			result.Refused = true
+		} else {
+			result.Reachable = n.answersAt(client.ID.PublicKey, client.Address)
+		}
+
+		if err := client.Reply(nonce, result); err != nil {
+			glog.Warningf("failed to answer reachability probe from %s: %v", client.Address, err)
+		}
+	})
+	return true
+}
+
+// mayDialBack returns true if a peer may be dialed back at the address it
+// advertises on its behalf.
+func (n *Network) mayDialBack(client *PeerClient) bool {
+	advertised, err := ParseAddress(client.Address)
+	if err != nil {
+		return false
+	}
+
+	if source, ok := client.source.Load().(string); ok && sameHost(advertised.Host, source) {
+		return true
+	}
+
+	return n.opts.verifyAddresses && n.AddressStatus(client.ID.PublicKey, client.Address) == AddressVerified
+}
+
+// sameHost returns true if a host is the one a connection came from, treating
+// all loopback addresses alike.
+func sameHost(host string, source string) bool {
+	sourceHost, _, err := net.SplitHostPort(source)
+	if err != nil {
+		return false
+	}
+	if host == sourceHost {
+		return true
+	}
+
+	a, b := net.ParseIP(host), net.ParseIP(sourceHost)
+	return a != nil && b != nil && (a.Equal(b) || (a.IsLoopback() && b.IsLoopback()))
+}
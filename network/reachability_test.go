@@ -0,0 +1,153 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/stretchr/testify/assert"
+)
+
+// natLink is an in-memory transport seen through address-translation rules:
+// a listener bound to a port translated by a rule is only reachable at the
+// port it translates to. Accepted connections report coming from loopback.
+type natLink struct {
+	*memTransport
+	rules map[int]int
+}
+
+func (l *natLink) Listen(port int) (net.Listener, error) {
+	if translated, ok := l.rules[port]; ok {
+		port = translated
+	}
+
+	listener, err := l.memTransport.Listen(port)
+	if err != nil {
+		return nil, err
+	}
+	return &loopbackListener{Listener: listener}, nil
+}
+
+type loopbackListener struct {
+	net.Listener
+}
+
+func (l *loopbackListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &loopbackConn{Conn: conn}, nil
+}
+
+type loopbackConn struct {
+	net.Conn
+}
+
+func (c *loopbackConn) RemoteAddr() net.Addr {
+	return memAddr("127.0.0.1:40000")
+}
+
+// buildNATNode builds a listening node advertising port, connecting to peers
+// through an in-memory transport under address-translation rules.
+func buildNATNode(t *testing.T, mem *memTransport, port int, rules map[int]int, opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(port)))
+	builder.ClearTransportLayers()
+	builder.RegisterTransportLayer("tcp", &natLink{memTransport: mem, rules: rules})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestReachabilityCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		broken   bool
+		expected Reachability
+	}{
+		{"advertised", false, ReachabilityPublic},
+		// The node listens behind a rule translating its port to another one
+		// than it advertises, so peers dialing it back find nobody there.
+		{"broken", true, ReachabilityPrivate},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			mem := newMemTransport()
+
+			port := GetRandomUnusedPort()
+			rules := map[int]int{}
+			if c.broken {
+				rules[port] = GetRandomUnusedPort()
+			}
+
+			changes := make(chan Reachability, 4)
+			node := buildNATNode(t, mem, port, rules,
+				ReachabilityCheck(2, time.Second),
+				OnReachabilityChanged(func(previous, current Reachability) {
+					assert.Equal(t, ReachabilityUnknown, previous)
+					changes <- current
+				}),
+			)
+			defer node.Close()
+
+			assert.Equal(t, ReachabilityUnknown, node.Reachability())
+
+			for i := 0; i < 3; i++ {
+				peer := buildNATNode(t, mem, GetRandomUnusedPort(), nil)
+				defer peer.Close()
+
+				_, err := node.Client(peer.Address)
+				assert.Nil(t, err)
+			}
+
+			select {
+			case current := <-changes:
+				assert.Equal(t, c.expected, current)
+			case <-time.After(5 * time.Second):
+				t.Fatal("reachability was never determined")
+			}
+			assert.Equal(t, c.expected, node.Reachability())
+
+			// Only the first peers are probed, until a recheck is due.
+			select {
+			case current := <-changes:
+				t.Fatalf("reachability changed again to %s", current)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestReachabilityProbesOnlyDialSources(t *testing.T) {
+	t.Parallel()
+
+	responder := buildListeningNode(t)
+	defer responder.Close()
+
+	client, err := createPeerClient(responder, "tcp://10.0.0.1:3000")
+	assert.Nil(t, err)
+	client.ID = &responder.ID
+
+	// Peers may not have us dial hosts other than their own.
+	assert.False(t, responder.mayDialBack(client))
+
+	client.source.Store("10.0.0.2:51000")
+	assert.False(t, responder.mayDialBack(client))
+
+	client.source.Store("10.0.0.1:51000")
+	assert.True(t, responder.mayDialBack(client))
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteBeforeReady(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(WriteReadinessPolicy(FailUntilReady))
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	err = node.Write("tcp://127.0.0.1:1", &protobuf.Message{})
+	assert.Equal(t, ErrNotReady, err)
+
+	builder = NewBuilderWithOptions(WriteReadinessPolicy(QueueUntilReady), WriteTimeout(50*time.Millisecond))
+	node, err = builder.Build()
+	assert.Nil(t, err)
+
+	err = node.Write("tcp://127.0.0.1:1", &protobuf.Message{})
+	assert.Equal(t, ErrNotReady, err, "queued write should fail once the write timeout elapses")
+}
+
+func TestQueuedWriteReleasedOnReady(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(WriteReadinessPolicy(QueueUntilReady))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- node.Write("tcp://127.0.0.1:1", &protobuf.Message{})
+	}()
+
+	go node.Listen()
+	<-node.Ready()
+
+	select {
+	case err := <-result:
+		assert.NotEqual(t, ErrNotReady, err, "write should have been released once listening")
+	case <-time.After(time.Second):
+		t.Fatal("queued write was never released")
+	}
+}
+
+func TestWaitForPeersTimeout(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Nil(t, node.WaitForPeers(ctx, 0))
+	assert.Equal(t, context.DeadlineExceeded, node.WaitForPeers(ctx, 1))
+}
@@ -0,0 +1,241 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// DisconnectUnresponsive is the reason peers judged dead by the reaper are
+// disconnected for.
+const DisconnectUnresponsive = "unresponsive"
+
+// liveness tracks the signals a peer is judged dead or alive by.
+type liveness struct {
+	sync.Mutex
+
+	// When a message was last received from the peer.
+	lastReceived time.Time
+
+	// Keepalives sent since a message was last received from the peer.
+	probes int
+
+	// When the last keepalive was sent, until it is acknowledged.
+	probeSent time.Time
+
+	// Round trip to the peer, smoothed over those measured between keepalives
+	// and their acknowledgements, and between pings and their pongs.
+	rtt time.Duration
+
+	// Writes to the peer which missed their deadline in a row.
+	writeFailures int
+
+	// Connection the peer's messages are received over.
+	incoming net.Conn
+}
+
+func (l *liveness) received(now time.Time, incoming net.Conn) {
+	l.Lock()
+	l.lastReceived = now
+	l.probes = 0
+	if incoming != nil {
+		l.incoming = incoming
+	}
+	l.Unlock()
+}
+
+func (l *liveness) wrote(err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if err == nil {
+		l.writeFailures = 0
+	} else if ne, ok := errors.Cause(err).(net.Error); ok && ne.Timeout() {
+		l.writeFailures++
+	}
+}
+
+// acked records a keepalive being acknowledged, returning the latest round
+// trip measured to the peer.
+func (l *liveness) acked(now time.Time) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.probeSent.IsZero() {
+		l.smooth(now.Sub(l.probeSent))
+		l.probeSent = time.Time{}
+	}
+	return l.rtt
+}
+
+// measured folds a round trip measured to the peer into the smoothed one.
+func (l *liveness) measured(rtt time.Duration) {
+	l.Lock()
+	l.smooth(rtt)
+	l.Unlock()
+}
+
+// smooth folds a round trip into the smoothed one, weighing it by an eighth
+// as TCP does.
+func (l *liveness) smooth(rtt time.Duration) {
+	if l.rtt == 0 {
+		l.rtt = rtt
+		return
+	}
+	l.rtt += (rtt - l.rtt) / 8
+}
+
+// lastRTT returns the smoothed round trip to the peer, being zero should no
+// keepalive nor ping have been answered yet.
+func (l *liveness) lastRTT() time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	return l.rtt
+}
+
+// check returns whether a peer is judged dead, and otherwise whether it is due
+// another keepalive.
+func (l *liveness) check(now time.Time, interval time.Duration, probes int, writeFailures int) (dead bool, probe bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.lastReceived.IsZero() {
+		l.lastReceived = now
+	}
+
+	if writeFailures > 0 && l.writeFailures >= writeFailures {
+		return true, false
+	}
+
+	silent := int(now.Sub(l.lastReceived) / interval)
+	if silent > probes {
+		return true, false
+	}
+
+	if silent > l.probes {
+		l.probes = silent
+		l.probeSent = now
+		return false, true
+	}
+
+	return false, false
+}
+
+// reapLoop periodically disconnects peers judged dead until the network is
+// closed.
+func (n *Network) reapLoop() {
+	t := time.NewTicker(n.opts.reapInterval / 2)
+	defer t.Stop()
+
+	h := n.watchdog.watch(ComponentKeepalive, "", nil)
+	defer n.watchdog.unwatch(h)
+
+	for {
+		select {
+		case <-n.kill:
+			return
+		case <-t.C:
+			h.beat()
+			n.reap(n.now())
+			h.idle()
+		}
+	}
+}
+
+// reap disconnects peers which stayed silent despite being sent keepalives,
+// or which kept missing write deadlines, and sends keepalives to peers which
+// have gone silent.
+func (n *Network) reap(now time.Time) {
+	n.eachPeer(func(client *PeerClient) bool {
+		dead, probe := client.liveness.check(now, n.opts.reapInterval, n.opts.reapProbes, n.opts.reapWriteFailures)
+
+		if dead {
+			glog.Warningf("disconnecting from unresponsive peer %s", client.Address)
+
+			client.close(DisconnectUnresponsive)
+
+			// Reads from a dead peer may never return by themselves.
+			client.liveness.Lock()
+			if client.liveness.incoming != nil {
+				client.liveness.incoming.Close()
+			}
+			client.liveness.Unlock()
+		} else if probe {
+			// Legacy peers know no keepalives, though they answer pings.
+			var keepalive proto.Message = &protobuf.Ping{}
+			if !client.legacy {
+				keepalive = &protobuf.Keepalive{Payload: n.piggyback(client)}
+			}
+
+			n.spawn(func() {
+				if err := client.Tell(keepalive); err != nil {
+					glog.Warningf("failed to send keepalive to %s: %v", client.Address, err)
+				}
+			})
+		}
+
+		return true
+	})
+}
+
+// markReceived records that a message was received from a peer.
+func (n *Network) markReceived(client *PeerClient, incoming net.Conn) {
+	if n.opts.reapInterval <= 0 {
+		return
+	}
+	client.liveness.received(n.now(), incoming)
+}
+
+// markWritten records whether a write to a peer made its deadline.
+func (n *Network) markWritten(address string, err error) {
+	n.recordSend(address, err)
+
+	if n.opts.reapInterval <= 0 {
+		return
+	}
+	if client, exists := n.peers.Load(address); exists {
+		client.(*PeerClient).liveness.wrote(err)
+	}
+}
+
+// handleKeepalive answers keepalives, returning true if a message was one.
+// Keepalives are never handed over to plugins, though payloads piggybacking
+// on them are handed over to the consumer registered through
+// OnKeepalivePayload.
+func (n *Network) handleKeepalive(client *PeerClient, name string, payload *types.Any) bool {
+	switch name {
+	case keepaliveName:
+		var keepalive protobuf.Keepalive
+		if n.opts.onKeepalivePayload != nil {
+			if err := types.UnmarshalAny(payload, &keepalive); err != nil {
+				glog.Error(err)
+			}
+		}
+
+		if err := client.Tell(&protobuf.KeepaliveAck{Payload: n.piggyback(client)}); err != nil {
+			glog.Warningf("failed to acknowledge keepalive from %s: %v", client.Address, err)
+		}
+
+		n.consumePiggyback(client, keepalive.Payload, client.liveness.lastRTT())
+		return true
+	case keepaliveAckName:
+		rtt := client.liveness.acked(n.now())
+
+		var ack protobuf.KeepaliveAck
+		if n.opts.onKeepalivePayload != nil {
+			if err := types.UnmarshalAny(payload, &ack); err != nil {
+				glog.Error(err)
+			}
+		}
+
+		n.consumePiggyback(client, ack.Payload, rtt)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// blackholeLink is a TCP link which, once black-holed, discards everything
+// written to it and never returns from reads.
+type blackholeLink struct {
+	*transport.TCP
+	blackholed atomic.Bool
+}
+
+func (l *blackholeLink) Dial(address string) (net.Conn, error) {
+	conn, err := l.TCP.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return &blackholeConn{Conn: conn, link: l}, nil
+}
+
+func (l *blackholeLink) Listen(port int) (net.Listener, error) {
+	listener, err := l.TCP.Listen(port)
+	if err != nil {
+		return nil, err
+	}
+	return &blackholeListener{Listener: listener, link: l}, nil
+}
+
+type blackholeListener struct {
+	net.Listener
+	link *blackholeLink
+}
+
+func (l *blackholeListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &blackholeConn{Conn: conn, link: l.link}, nil
+}
+
+type blackholeConn struct {
+	net.Conn
+	link *blackholeLink
+}
+
+func (c *blackholeConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(b)
+		if err != nil || !c.link.blackholed.Load() {
+			return n, err
+		}
+	}
+}
+
+func (c *blackholeConn) Write(b []byte) (int, error) {
+	if c.link.blackholed.Load() {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+// disconnectPlugin records why peers disconnected.
+type disconnectPlugin struct {
+	*Plugin
+	reasons chan string
+}
+
+func (p *disconnectPlugin) PeerDisconnect(client *PeerClient) {
+	p.reasons <- client.Address + " " + client.DisconnectReason()
+}
+
+func TestReapUnresponsivePeers(t *testing.T) {
+	t.Parallel()
+
+	const interval = time.Minute
+
+	disconnects := &disconnectPlugin{reasons: make(chan string, 4)}
+
+	builder := NewBuilderWithOptions(ReapUnresponsive(interval, 2, 0))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(disconnects)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+
+	go node.Listen()
+	<-node.Ready()
+
+	// The dead peer dials and accepts connections over a link which is
+	// black-holed once it is connected.
+	link := &blackholeLink{TCP: transport.NewTCP()}
+
+	deadBuilder := NewBuilder()
+	deadBuilder.SetKeys(ed25519.RandomKeyPair())
+	deadBuilder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	deadBuilder.RegisterTransportLayer("tcp", link)
+
+	dead, err := deadBuilder.Build()
+	assert.Nil(t, err)
+	defer dead.Close()
+
+	go dead.Listen()
+	<-dead.Ready()
+
+	idle := buildListeningNode(t)
+	defer idle.Close()
+
+	for _, peer := range []*Network{dead, idle} {
+		client, err := peer.Client(node.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+	}
+	waitForPeers(t, node, 2)
+
+	link.blackholed.Store(true)
+
+	// Every interval, the idle peer is probed and answers.
+	idleClient, _ := node.peers.Load(idle.Address)
+	answered := func() bool {
+		idleClient.(*PeerClient).liveness.Lock()
+		defer idleClient.(*PeerClient).liveness.Unlock()
+		return idleClient.(*PeerClient).liveness.lastReceived.Equal(clock.Now())
+	}
+
+	for i := 0; i < 2; i++ {
+		clock.Advance(interval)
+		node.reap(clock.Now())
+		assert.True(t, waitUntil(3*time.Second, answered), "idle peer should answer keepalives")
+		assert.True(t, node.ConnectionStateExists(dead.Address), "dead peer should not be reaped before the window elapses")
+	}
+
+	clock.Advance(interval)
+	node.reap(clock.Now())
+
+	select {
+	case reason := <-disconnects.reasons:
+		assert.Equal(t, dead.Address+" "+DisconnectUnresponsive, reason)
+	case <-time.After(3 * time.Second):
+		t.Fatal("dead peer was not reaped")
+	}
+
+	assert.False(t, node.ConnectionStateExists(dead.Address))
+	assert.True(t, node.ConnectionStateExists(idle.Address), "idle peer should be left connected")
+}
+
+func TestReapPeersMissingWriteDeadlines(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, ReapUnresponsive(time.Hour, 2, 3))
+	defer node.Close()
+
+	peer := buildListeningNode(t)
+	defer peer.Close()
+
+	client, err := node.Client(peer.Address)
+	assert.Nil(t, err)
+
+	timeout := &net.OpError{Op: "write", Err: timeoutError{}}
+
+	node.markWritten(peer.Address, timeout)
+	node.markWritten(peer.Address, timeout)
+	node.markWritten(peer.Address, nil)
+	node.markWritten(peer.Address, timeout)
+	node.markWritten(peer.Address, timeout)
+	node.reap(node.now())
+	assert.Equal(t, "", client.DisconnectReason(), "successful writes should reset the count")
+
+	node.markWritten(peer.Address, timeout)
+	node.reap(node.now())
+	assert.Equal(t, DisconnectUnresponsive, client.DisconnectReason())
+}
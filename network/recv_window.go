@@ -21,9 +21,11 @@ func NewRecvWindow(size int) *RecvWindow {
 	}
 }
 
-// SetLocalNonce sets a expected nonce.
+// SetLocalNonce sets a expected nonce, which takes precedence over the nonce
+// of the first value pushed.
 func (w *RecvWindow) SetLocalNonce(nonce uint64) {
 	w.Lock()
+	w.once.Do(func() {})
 	w.lastNonce = nonce
 	w.Unlock()
 }
@@ -36,14 +38,18 @@ func (w *RecvWindow) LocalNonce() uint64 {
 	return nonce
 }
 
-// Push adds value with a given nonce to the window.
-func (w *RecvWindow) Push(nonce uint64, value interface{}) {
+// Push adds value with a given nonce to the window, returning the value it
+// displaced, if any.
+func (w *RecvWindow) Push(nonce uint64, value interface{}) interface{} {
 	w.Lock()
 	w.once.Do(func() {
 		w.lastNonce = nonce
 	})
-	w.buf[nonce%uint64(w.size)] = value
+	idx := nonce % uint64(w.size)
+	displaced := w.buf[idx]
+	w.buf[idx] = value
 	w.Unlock()
+	return displaced
 }
 
 // Pop returns a slice of values from last till not yet received nonce.
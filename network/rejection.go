@@ -0,0 +1,193 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// RejectionCapability is advertised by nodes which understand being notified
+// of the messages their peers dropped.
+const RejectionCapability = "noise/rejections"
+
+// ErrMessageTooLarge is the error a message is rejected with should it be
+// larger than the receiver accepts.
+var ErrMessageTooLarge = errors.New("network: message too large")
+
+// ErrMessageRejected is the error a message is rejected with should the
+// receiver give a reason this node does not know of.
+var ErrMessageRejected = errors.New("network: message rejected")
+
+var rejectionName = proto.MessageName((*protobuf.Rejection)(nil))
+
+// RejectReason is a machine-readable code for why a message was dropped.
+type RejectReason uint32
+
+const (
+	// RejectUnknown is sent for drops no other reason describes.
+	RejectUnknown RejectReason = iota
+	// RejectMessageTooLarge is sent for messages larger than the receiver accepts.
+	RejectMessageTooLarge
+	// RejectUnknownCriticalExtension is sent for messages carrying a critical
+	// extension the receiver does not implement.
+	RejectUnknownCriticalExtension
+	// RejectProtocolUnsupported is sent for messages sent under a protocol tag
+	// the receiver does not speak.
+	RejectProtocolUnsupported
+	// RejectJournalFull is sent for messages the receiver had no room left
+	// to journal.
+	RejectJournalFull
+)
+
+var rejectReasons = map[RejectReason]error{
+	RejectMessageTooLarge:          ErrMessageTooLarge,
+	RejectUnknownCriticalExtension: ErrUnknownCriticalExtension,
+	RejectProtocolUnsupported:      ErrProtocolUnsupported,
+	RejectJournalFull:              ErrJournalFull,
+}
+
+// Err returns the error a message rejected for the reason fails with.
+func (r RejectReason) Err() error {
+	if err, ok := rejectReasons[r]; ok {
+		return err
+	}
+	return ErrMessageRejected
+}
+
+// rejectReasonOf returns the reason sent to a peer whose message was dropped
+// with err.
+func rejectReasonOf(err error) RejectReason {
+	cause := errors.Cause(err)
+	for reason, err := range rejectReasons {
+		if err == cause {
+			return reason
+		}
+	}
+	return RejectUnknown
+}
+
+// Rejection is the error a request fails with, and the notification handed
+// to OnRejection, should a peer drop a message sent to it.
+type Rejection struct {
+	// RequestNonce is the nonce of the rejected request, or 0 if the rejected
+	// message was not a request.
+	RequestNonce uint64
+	// MessageNonce is the nonce the rejected message was written with.
+	MessageNonce uint64
+	// Hash is the hash of the rejected message as it was received.
+	Hash []byte
+	// Reason is why the message was dropped.
+	Reason RejectReason
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("network: message %d rejected by peer: %v", r.MessageNonce, r.Reason.Err())
+}
+
+// Cause returns the error the peer dropped the message with.
+func (r *Rejection) Cause() error {
+	return r.Reason.Err()
+}
+
+// RejectionStats counts rejections sent to and received from peers.
+type RejectionStats struct {
+	// Sent is the number of rejections sent to peers.
+	Sent uint64
+	// Suppressed is the number of rejections not sent to stay within the
+	// rejection rate of a peer.
+	Suppressed uint64
+	// Received is the number of rejections received from peers.
+	Received uint64
+}
+
+// RejectionStats returns how many rejections were sent, suppressed and received.
+func (n *Network) RejectionStats() RejectionStats {
+	return RejectionStats{
+		Sent:       atomic.LoadUint64(&n.rejectionsSent),
+		Suppressed: atomic.LoadUint64(&n.rejectionsSuppressed),
+		Received:   atomic.LoadUint64(&n.rejectionsReceived),
+	}
+}
+
+// reject notifies a peer that a message it sent was dropped with err, should
+// rejections be sent and the peer understand them. Rejections are rate
+// limited per peer so that they may not be used to amplify traffic, and are
+// never sent in response to rejections.
+func (n *Network) reject(client *PeerClient, frame *receivedMessage, err error) {
+	if client.rejections == nil || !client.HasCapability(RejectionCapability) {
+		return
+	}
+
+	if name, _ := payloadName(frame.Message.Message); name == rejectionName {
+		return
+	}
+
+	if !client.rejections.take(time.Now()) {
+		atomic.AddUint64(&n.rejectionsSuppressed, 1)
+		return
+	}
+
+	rejection := &protobuf.Rejection{
+		MessageNonce: frame.Message.MessageNonce,
+		Hash:         n.opts.hashPolicy.HashBytes(frame.raw),
+		Reason:       uint32(rejectReasonOf(err)),
+	}
+
+	// The nonces of replies are those of our own requests.
+	if !frame.Message.ReplyFlag {
+		rejection.RequestNonce = frame.Message.RequestNonce
+	}
+
+	n.spawn(func() {
+		if err := client.Tell(rejection); err != nil {
+			glog.Warningf("network: failed to send rejection to %s: %v", client.Address, err)
+			return
+		}
+		atomic.AddUint64(&n.rejectionsSent, 1)
+	})
+}
+
+// handleRejection fails the pending request a rejection received from a peer
+// refers to, or otherwise hands it to OnRejection. It returns false should
+// the payload not be a rejection.
+func (n *Network) handleRejection(client *PeerClient, name string, payload *types.Any) bool {
+	if name != rejectionName {
+		return false
+	}
+
+	var msg protobuf.Rejection
+	if err := types.UnmarshalAny(payload, &msg); err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	atomic.AddUint64(&n.rejectionsReceived, 1)
+
+	rejection := &Rejection{
+		RequestNonce: msg.RequestNonce,
+		MessageNonce: msg.MessageNonce,
+		Hash:         msg.Hash,
+		Reason:       RejectReason(msg.Reason),
+	}
+
+	if rejection.RequestNonce > 0 {
+		if state := n.requests.lookup(client, rejection.RequestNonce); state != nil {
+			select {
+			case state.rejected <- rejection:
+			case <-state.closeSignal:
+			}
+			return true
+		}
+	}
+
+	if n.opts.onRejection != nil {
+		n.opts.onRejection(client, rejection)
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOversizedRequestIsRejected(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		ctx.Reply(&testpb.TestMessage{Message: "ok"})
+	}, MaxMessageSize(1024), SendRejections(10, 10))
+	defer receiver.Close()
+	defer sender.Close()
+
+	// Small requests are answered as usual.
+	_, err := client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: "small"}, Timeout: 3 * time.Second})
+	assert.Nil(t, err)
+
+	// Oversized requests fail as soon as the peer rejects them.
+	start := time.Now()
+	_, err = client.Request(&rpc.Request{Message: &testpb.TestMessage{Message: strings.Repeat("x", 4096)}, Timeout: 10 * time.Second})
+	assert.Equal(t, ErrMessageTooLarge, errors.Cause(err))
+	assert.True(t, time.Since(start) < 3*time.Second, "rejection took %s", time.Since(start))
+
+	if rejection, ok := err.(*Rejection); assert.True(t, ok) {
+		assert.Equal(t, RejectMessageTooLarge, rejection.Reason)
+		assert.NotZero(t, rejection.RequestNonce)
+		assert.NotEmpty(t, rejection.Hash)
+	}
+
+	assert.Equal(t, uint64(1), receiver.RejectionStats().Sent)
+	assert.Equal(t, uint64(1), sender.RejectionStats().Received)
+}
+
+func TestRejectionsAreRateLimited(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	var rejections []*Rejection
+
+	receiver := buildListeningNode(t, MaxMessageSize(1024), SendRejections(1, 2))
+	defer receiver.Close()
+
+	sender := buildListeningNode(t, OnRejection(func(client *PeerClient, r *Rejection) {
+		mutex.Lock()
+		rejections = append(rejections, r)
+		mutex.Unlock()
+	}))
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	// Only a burst's worth of the oversized messages told are rejected.
+	const count = 5
+	for i := 0; i < count; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: strings.Repeat("x", 4096)}))
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		stats := receiver.RejectionStats()
+		return stats.Sent+stats.Suppressed == count
+	}))
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return sender.RejectionStats().Received == 2
+	}))
+
+	assert.Equal(t, RejectionStats{Sent: 2, Suppressed: count - 2}, receiver.RejectionStats())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if assert.Len(t, rejections, 2) {
+		for _, rejection := range rejections {
+			assert.Zero(t, rejection.RequestNonce)
+			assert.NotZero(t, rejection.MessageNonce)
+			assert.Equal(t, ErrMessageTooLarge, errors.Cause(rejection))
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"reflect"
+	"runtime/debug"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// ReplayResult is the outcome of replaying a received frame.
+type ReplayResult struct {
+	// Verified is false if the frame's signature failed verification, in
+	// which case none of its payloads were handed to plugins.
+	Verified bool
+	// Delivered is the number of payloads handed to plugins.
+	Delivered int
+	// Errors are the errors plugins returned handling the frame's payloads,
+	// alongside any panics recovered from them as *HandlerPanic.
+	Errors []error
+}
+
+// ReplayFrame feeds a frame as received from a peer through the decoding,
+// verification and dispatch of received messages, without any sockets. The
+// frame's payloads are handed to the plugins registered under their protocol
+// tag one at a time on the calling goroutine. Messages the network handles
+// itself, such as keepalives, are skipped, and replies plugins send fail for
+// there being no connection to the peer.
+func (n *Network) ReplayFrame(frame []byte) (*ReplayResult, error) {
+	msg, verified, err := n.decodeMessage(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReplayResult{Verified: verified}
+	if !verified {
+		return result, nil
+	}
+
+	if err := checkExtensions(msg); err != nil {
+		return nil, err
+	}
+
+	client, err := n.detachedClient(msg.Sender)
+	if err != nil {
+		return nil, err
+	}
+	client.setID((*peer.ID)(msg.Sender))
+
+	name, err := payloadName(msg.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	received := &receivedMessage{Message: msg, raw: frame, receivedAt: n.now(), refs: 1}
+
+	if name != batchName {
+		n.replayPayload(client, received, name, msg.Message, result)
+		return result, nil
+	}
+
+	var batch protobuf.Batch
+	if err := types.UnmarshalAny(msg.Message, &batch); err != nil {
+		return nil, err
+	}
+
+	for _, payload := range batch.Payloads {
+		name, err := payloadName(payload)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		n.replayPayload(client, received, name, payload, result)
+	}
+
+	return result, nil
+}
+
+// replayPayload hands a single replayed payload to all plugins registered
+// under the protocol tag it was sent under.
+func (n *Network) replayPayload(client *PeerClient, frame *receivedMessage, name string, payload *types.Any, result *ReplayResult) {
+	switch name {
+	case batchName, bytesName, keepaliveName, keepaliveAckName, serviceRecordsName, rejectionName:
+		return
+	}
+
+	var routed *protobuf.Routed
+	if name == routedName {
+		var err error
+		if routed, name, err = unwrapRouted(payload); err != nil {
+			result.Errors = append(result.Errors, err)
+			return
+		}
+		payload = routed.Message
+	}
+
+	protocol := frame.Message.Protocol
+
+	plugins, supported := n.protocolPlugins(protocol)
+	if !supported {
+		result.Errors = append(result.Errors, errors.Wrapf(ErrProtocolUnsupported, "protocol %q", protocol))
+		return
+	}
+
+	ctx := &PluginContext{client: client, nonce: frame.Message.RequestNonce, origin: *client.id(), frame: frame, protocol: protocol}
+	ctx.reset(name, payload)
+	ctx.routed = routed
+
+	result.Delivered++
+
+	plugins.Each(func(plugin PluginInterface) {
+		if err := replayReceive(plugin, ctx); err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+	})
+}
+
+// replayReceive invokes a single plugin's Receive callback, returning any
+// panic recovered as a *HandlerPanic.
+func replayReceive(plugin PluginInterface, ctx *PluginContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &HandlerPanic{
+				Plugin:  reflect.TypeOf(plugin).String(),
+				Message: ctx.Message(),
+				Value:   r,
+				Stack:   debug.Stack(),
+			}
+		}
+	}()
+
+	return plugin.Receive(ctx)
+}
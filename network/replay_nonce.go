@@ -0,0 +1,187 @@
+package network
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrReplayed is the error envelopes carrying a replay nonce seen from
+	// their sender before, or too old to tell, are dropped with.
+	ErrReplayed = errors.New("network: dropped replayed envelope")
+	// ErrReplayNonceMissing is the error envelopes carrying no replay nonce
+	// are dropped with should replay nonces be required.
+	ErrReplayNonceMissing = errors.New("network: dropped envelope carrying no replay nonce")
+)
+
+const (
+	// maxReplayPeers is how many peers the windows of replay nonces are
+	// remembered for, the least recently heard from being forgotten first.
+	maxReplayPeers = 4096
+
+	// defaultReplayHorizon is how old, by the time they were stamped at,
+	// replay nonces may be before envelopes carrying them are dropped.
+	defaultReplayHorizon = 5 * time.Minute
+)
+
+// serializeReplayNonce appends the replay nonce of a message, and the time it
+// was stamped at, to its serialized envelope, should it carry one, so that
+// neither may be altered in transit.
+func serializeReplayNonce(serialized []byte, nonce uint64, stampMs uint64) []byte {
+	if nonce == 0 {
+		return serialized
+	}
+
+	var word [8]byte
+	binary.LittleEndian.PutUint64(word[:], nonce)
+	serialized = append(serialized, word[:]...)
+	binary.LittleEndian.PutUint64(word[:], stampMs)
+	return append(serialized, word[:]...)
+}
+
+// stampReplayNonce stamps an envelope about to be signed with the next replay
+// nonce, and the time it was stamped at, should replay protection be enabled.
+func (n *Network) stampReplayNonce(msg *protobuf.Message) {
+	if n.opts.replayWindow > 0 {
+		msg.ReplayNonce = atomic.AddUint64(&n.replayNonce, 1)
+		msg.ReplayStampMs = uint64(n.now().UnixNano() / int64(time.Millisecond))
+	}
+}
+
+// checkReplay records the replay nonce of an envelope whose signature
+// verified, failing should the nonce have been seen from its sender before,
+// be too old to tell, or have been stamped longer ago than the replay horizon.
+// Windows are only held in memory, so the horizon is what keeps envelopes
+// captured before a restart, or before their sender's window was forgotten,
+// from being accepted again.
+func (n *Network) checkReplay(msg *protobuf.Message) error {
+	if n.opts.replayWindow == 0 {
+		return nil
+	}
+
+	if msg.ReplayNonce == 0 {
+		if n.opts.requireReplayNonces {
+			return ErrReplayNonceMissing
+		}
+		return nil
+	}
+
+	if horizon := n.opts.replayHorizon; horizon > 0 {
+		stamped := time.Unix(0, int64(msg.ReplayStampMs)*int64(time.Millisecond))
+		if age := n.now().Sub(stamped); age > horizon {
+			return errors.Wrapf(ErrReplayed, "nonce %d stamped %s ago", msg.ReplayNonce, age)
+		}
+	}
+
+	if !n.replays.accept(msg.Sender.PublicKey, msg.ReplayNonce) {
+		return errors.Wrapf(ErrReplayed, "nonce %d", msg.ReplayNonce)
+	}
+	return nil
+}
+
+// replayWindow holds the highest replay nonce received from a peer, and which
+// of the nonces below it within the window were received, as a ring of bits
+// indexed by nonce.
+type replayWindow struct {
+	highest uint64
+	seen    []uint64
+}
+
+func (w *replayWindow) bit(nonce uint64) (int, uint64) {
+	index := nonce % uint64(len(w.seen)*64)
+	return int(index / 64), 1 << (index % 64)
+}
+
+// accept records a nonce, returning false should it have been received
+// before, or be too far below the highest nonce received to tell.
+func (w *replayWindow) accept(nonce uint64, size uint64) bool {
+	if nonce > w.highest {
+		// Nonces skipped over are forgotten, as are those slid out of the
+		// window.
+		if nonce-w.highest >= size {
+			for i := range w.seen {
+				w.seen[i] = 0
+			}
+		} else {
+			for skipped := w.highest + 1; skipped < nonce; skipped++ {
+				word, mask := w.bit(skipped)
+				w.seen[word] &^= mask
+			}
+		}
+
+		w.highest = nonce
+		word, mask := w.bit(nonce)
+		w.seen[word] |= mask
+		return true
+	}
+
+	if w.highest-nonce >= size {
+		return false
+	}
+
+	word, mask := w.bit(nonce)
+	if w.seen[word]&mask != 0 {
+		return false
+	}
+	w.seen[word] |= mask
+	return true
+}
+
+type replayEntry struct {
+	key    string
+	window replayWindow
+}
+
+// replayFilter holds a window of the replay nonces received from every peer,
+// by public key, so that envelopes replayed over any connection to the peer
+// are dropped. Windows are not persisted: those of peers evicted, or of every
+// peer after a restart, start over empty, leaving envelopes stamped within the
+// replay horizon open to being replayed once more.
+type replayFilter struct {
+	sync.Mutex
+
+	size int
+
+	order *list.List
+	peers map[string]*list.Element
+}
+
+func newReplayFilter(size int) *replayFilter {
+	return &replayFilter{
+		size:  size,
+		order: list.New(),
+		peers: make(map[string]*list.Element),
+	}
+}
+
+// accept records a nonce received from the peer holding a public key,
+// returning false should it be a replay.
+func (f *replayFilter) accept(publicKey []byte, nonce uint64) bool {
+	f.Lock()
+	defer f.Unlock()
+
+	element, exists := f.peers[string(publicKey)]
+	if exists {
+		f.order.MoveToFront(element)
+	} else {
+		// Windows span a whole number of words, of which only size bits are
+		// ever looked at below the highest nonce.
+		entry := &replayEntry{key: string(publicKey), window: replayWindow{seen: make([]uint64, (f.size+63)/64)}}
+		element = f.order.PushFront(entry)
+		f.peers[entry.key] = element
+
+		for f.order.Len() > maxReplayPeers {
+			oldest := f.order.Back()
+			f.order.Remove(oldest)
+			delete(f.peers, oldest.Value.(*replayEntry).key)
+		}
+	}
+
+	return element.Value.(*replayEntry).window.accept(nonce, uint64(f.size))
+}
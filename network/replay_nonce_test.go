@@ -0,0 +1,179 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayWindow(t *testing.T) {
+	t.Parallel()
+
+	window := replayWindow{seen: make([]uint64, 2)}
+
+	steps := []struct {
+		nonce    uint64
+		accepted bool
+	}{
+		{1000, true},
+		{1000, false},
+		{999, true},
+		{901, true},
+		{900, false}, // trails the highest nonce by the whole window
+		{1050, true},
+		{999, false},
+		{1001, true}, // skipped over, and so forgotten as unseen
+		{1200, true},
+		{1150, true},
+		{1050, false},
+	}
+	for _, step := range steps {
+		assert.Equal(t, step.accepted, window.accept(step.nonce, 100), "nonce %d", step.nonce)
+	}
+
+	// Peers are told apart by public key.
+	filter := newReplayFilter(100)
+	assert.True(t, filter.accept([]byte("a"), 1))
+	assert.True(t, filter.accept([]byte("b"), 1))
+	assert.False(t, filter.accept([]byte("a"), 1))
+}
+
+func TestReplayNonceIsSigned(t *testing.T) {
+	t.Parallel()
+
+	for _, form := range []SigningForm{SigningLegacy, SigningCanonical} {
+		builder := NewBuilderWithOptions(ReplayProtection(64, false), EnvelopeSigning(form))
+		builder.SetKeys(ed25519.RandomKeyPair())
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		first, err := node.PrepareMessage(&testpb.TestMessage{Message: "first"})
+		assert.Nil(t, err)
+		second, err := node.PrepareMessage(&testpb.TestMessage{Message: "second"})
+		assert.Nil(t, err)
+
+		assert.True(t, second.ReplayNonce > first.ReplayNonce)
+		assert.Contains(t, first.CriticalExtensions, uint32(ExtensionReplayNonce))
+		assert.True(t, node.verifyMessage(first))
+
+		first.ReplayNonce = second.ReplayNonce
+		assert.False(t, node.verifyMessage(first))
+	}
+}
+
+func TestReplayedEnvelopesAreDropped(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, _, arrivals := connectPadded(t, []BuilderOption{ReplayProtection(64, true)}, ReplayProtection(64, false))
+	defer receiver.Close()
+	defer sender.Close()
+
+	signed, err := sender.PrepareMessage(&testpb.TestMessage{Message: "once"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, sender.Write(receiver.Address, signed))
+	assert.Equal(t, "once", receivePadded(t, arrivals).message)
+
+	assert.Nil(t, sender.Write(receiver.Address, signed))
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return receiver.ValidationStats().Replayed == 1
+	}), "replayed envelope was never dropped")
+
+	// Envelopes freshly signed carry on being delivered over the connection.
+	signed, err = sender.PrepareMessage(&testpb.TestMessage{Message: "twice"})
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Write(receiver.Address, signed))
+	assert.Equal(t, "twice", receivePadded(t, arrivals).message)
+	assert.Len(t, arrivals, 0)
+
+	// Peers stamping no nonces are dropped when nonces are required.
+	unstamped := buildListeningNode(t)
+	defer unstamped.Close()
+
+	client, err := unstamped.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "unstamped"}))
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return receiver.ValidationStats().Replayed == 2
+	}), "unstamped envelope was never dropped")
+	assert.Len(t, arrivals, 0)
+
+	_, err = NewBuilderWithOptions(ReplayProtection(0, true)).Build()
+	assert.NotNil(t, err)
+	_, err = NewBuilderWithOptions(ReplayProtection(64, true), AllowLegacyPeers(true)).Build()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, ErrReplayNonceMissing, errors.Cause(receiver.checkReplay(&Envelope{Sender: signed.Sender})))
+}
+
+func TestStaleReplayNoncesAreDropped(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(ReplayProtection(64, false), ReplayHorizon(time.Minute))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+
+	first, err := node.PrepareMessage(&testpb.TestMessage{Message: "first"})
+	assert.Nil(t, err)
+	second, err := node.PrepareMessage(&testpb.TestMessage{Message: "second"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(clock.Now().UnixNano()/int64(time.Millisecond)), first.ReplayStampMs)
+
+	// Stamps are signed along with the nonces.
+	assert.True(t, node.verifyMessage(first))
+	first.ReplayStampMs++
+	assert.False(t, node.verifyMessage(first))
+	first.ReplayStampMs--
+
+	assert.Nil(t, node.checkReplay(first))
+
+	// Once the horizon passes, envelopes are dropped even by nodes holding no
+	// window for their sender, as after a restart.
+	clock.Advance(2 * time.Minute)
+	node.replays = newReplayFilter(64)
+	assert.Equal(t, ErrReplayed, errors.Cause(node.checkReplay(second)))
+
+	fresh, err := node.PrepareMessage(&testpb.TestMessage{Message: "fresh"})
+	assert.Nil(t, err)
+	assert.Nil(t, node.checkReplay(fresh))
+
+	_, err = NewBuilderWithOptions(ReplayHorizon(-time.Second)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestReorderedReplayNoncesAreAccepted(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(ReplayProtection(64, false))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+
+	// However far apart in time envelopes are signed, they may be reordered
+	// by up to the window on their way, as by concurrent writes.
+	var signed []*Envelope
+	for i := 0; i < 64; i++ {
+		msg, err := node.PrepareMessage(&testpb.TestMessage{Message: "reordered"})
+		assert.Nil(t, err)
+		signed = append(signed, msg)
+		clock.Advance(time.Second)
+	}
+
+	for i := len(signed) - 1; i >= 0; i-- {
+		assert.Nil(t, node.checkReplay(signed[i]), "envelope %d", i)
+	}
+	for _, msg := range signed {
+		assert.Equal(t, ErrReplayed, errors.Cause(node.checkReplay(msg)))
+	}
+}
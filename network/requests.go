@@ -0,0 +1,456 @@
+package network
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultMaxPendingRequests is how many requests may await replies at
+	// once, across all peers, before the oldest are evicted.
+	defaultMaxPendingRequests = 1 << 16
+
+	// requestTick is how often requests awaiting replies are checked for
+	// having expired, and so how late past its timeout a request may fail.
+	requestTick = 10 * time.Millisecond
+	// requestWheelSlots is the number of ticks of the wheel requests are
+	// expired by. Requests expiring further ahead than a full turn of the
+	// wheel wait out as many turns as they need to.
+	requestWheelSlots = 1024
+
+	// leakCheckInterval is how often the pending request table is checked
+	// for leaked requests, should it be.
+	leakCheckInterval = time.Second
+)
+
+var (
+	// ErrRequestEvicted is returned by requests evicted from the pending
+	// request table to make room for newer ones.
+	ErrRequestEvicted = errors.New("network: request evicted from pending request table")
+
+	errRequestAborted = errors.New("request aborted: peer client closed")
+)
+
+// RequestTableStats describes the table of requests awaiting replies.
+type RequestTableStats struct {
+	// Pending is the number of requests awaiting replies.
+	Pending int
+	// Expired is the number of requests which timed out.
+	Expired uint64
+	// Aborted is the number of requests failed as their peer disconnected.
+	Aborted uint64
+	// Evicted is the number of requests failed with ErrRequestEvicted.
+	Evicted uint64
+	// Leaked is the number of times a request was found pending for longer
+	// than it should have been, with RequestLeakCheck.
+	Leaked uint64
+}
+
+// requestKey identifies a request awaiting a reply by the client it was sent
+// through and its nonce.
+type requestKey struct {
+	client *PeerClient
+	nonce  uint64
+}
+
+// requestEntry is an attempt at a request awaiting a reply. It times out at
+// its deadline, though late replies to it are still taken until it expires
+// along with the request, should the request be retried.
+type requestEntry struct {
+	key      requestKey
+	state    *RequestState
+	sent     time.Time
+	deadline time.Time
+	expiry   time.Time
+	timedOut bool
+
+	// tick is the tick of the wheel the attempt next times out or expires at.
+	tick int64
+	// age is the request's place in the table's order of age.
+	age *list.Element
+}
+
+// requestTable holds the requests awaiting replies across all peers. Requests
+// expire on a timer wheel swept by a single goroutine, which runs only while
+// requests are pending.
+type requestTable struct {
+	sync.Mutex
+
+	entries  map[requestKey]*requestEntry
+	byClient map[*PeerClient]map[*requestEntry]struct{}
+
+	// ages orders requests from oldest to newest.
+	ages *list.List
+
+	wheel [requestWheelSlots]map[*requestEntry]struct{}
+	// swept is the last tick of the wheel swept.
+	swept    int64
+	sweeping bool
+
+	leakChecked time.Time
+
+	expired uint64
+	aborted uint64
+	evicted uint64
+	leaked  uint64
+}
+
+// tickOf returns the tick of the wheel a time falls within.
+func tickOf(t time.Time) int64 {
+	return t.UnixNano() / int64(requestTick)
+}
+
+// trackAttempt starts tracking an attempt at a request sent through a client
+// under a nonce, timing it out after timeout and expiring it at expiry, and
+// evicting the oldest attempt tracked should the table be full. Attempts sent
+// through clients which closed fail right away.
+func (n *Network) trackAttempt(client *PeerClient, nonce uint64, state *RequestState, timeout time.Duration, expiry time.Time) *requestEntry {
+	now := n.now()
+
+	entry := &requestEntry{
+		key:      requestKey{client: client, nonce: nonce},
+		state:    state,
+		sent:     now,
+		deadline: now.Add(timeout),
+		expiry:   expiry,
+	}
+	if entry.expiry.Before(entry.deadline) {
+		entry.expiry = entry.deadline
+	}
+	entry.tick = tickOf(entry.deadline)
+
+	t := &n.requests
+	t.Lock()
+
+	// Clients mark themselves closed before failing their requests.
+	if client.isClosed() {
+		t.Unlock()
+		state.fail(n.abortError())
+		return entry
+	}
+
+	if t.entries == nil {
+		t.entries = make(map[requestKey]*requestEntry)
+		t.byClient = make(map[*PeerClient]map[*requestEntry]struct{})
+		t.ages = list.New()
+	}
+
+	var evicted *requestEntry
+	if len(t.entries) >= n.opts.maxPendingRequests {
+		evicted = t.ages.Front().Value.(*requestEntry)
+		t.remove(evicted)
+		t.evicted++
+	}
+
+	t.insert(entry)
+
+	start := !t.sweeping
+	if start {
+		t.sweeping = true
+		t.swept = tickOf(now)
+	}
+
+	t.Unlock()
+
+	if evicted != nil {
+		evicted.state.fail(ErrRequestEvicted)
+	}
+	if start {
+		n.spawnIn(SubsystemNetwork, n.sweepRequests)
+	}
+
+	return entry
+}
+
+// insert files a request away, under the table's lock.
+func (t *requestTable) insert(entry *requestEntry) {
+	t.entries[entry.key] = entry
+
+	clientEntries, exists := t.byClient[entry.key.client]
+	if !exists {
+		clientEntries = make(map[*requestEntry]struct{})
+		t.byClient[entry.key.client] = clientEntries
+	}
+	clientEntries[entry] = struct{}{}
+
+	t.file(entry)
+
+	entry.age = t.ages.PushBack(entry)
+}
+
+// file files a request away on the wheel at its tick, under the table's lock.
+func (t *requestTable) file(entry *requestEntry) {
+	slot := &t.wheel[entry.tick%requestWheelSlots]
+	if *slot == nil {
+		*slot = make(map[*requestEntry]struct{})
+	}
+	(*slot)[entry] = struct{}{}
+}
+
+// remove stops tracking a request, under the table's lock, returning false
+// should it no longer be tracked.
+func (t *requestTable) remove(entry *requestEntry) bool {
+	if t.entries[entry.key] != entry {
+		return false
+	}
+
+	delete(t.entries, entry.key)
+
+	clientEntries := t.byClient[entry.key.client]
+	delete(clientEntries, entry)
+	if len(clientEntries) == 0 {
+		delete(t.byClient, entry.key.client)
+	}
+
+	delete(t.wheel[entry.tick%requestWheelSlots], entry)
+	t.ages.Remove(entry.age)
+
+	return true
+}
+
+// lookup returns the state of a request sent through a client under a nonce,
+// or nil should it no longer await a reply.
+func (t *requestTable) lookup(client *PeerClient, nonce uint64) *RequestState {
+	t.Lock()
+	defer t.Unlock()
+
+	if entry, exists := t.entries[requestKey{client: client, nonce: nonce}]; exists {
+		return entry.state
+	}
+	return nil
+}
+
+// untrack stops tracking requests, such as once they were answered or their
+// callers gave up on them.
+func (t *requestTable) untrack(entries ...*requestEntry) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, entry := range entries {
+		t.remove(entry)
+	}
+}
+
+// fail fails every request sent through a client, such as once it closed.
+func (t *requestTable) fail(client *PeerClient, err error) {
+	t.Lock()
+
+	var failed []*requestEntry
+	for entry := range t.byClient[client] {
+		t.remove(entry)
+		failed = append(failed, entry)
+	}
+	t.aborted += uint64(len(failed))
+
+	t.Unlock()
+
+	for _, entry := range failed {
+		entry.state.fail(err)
+	}
+}
+
+// move hands the requests sent through a client over to another, which the
+// client's session was merged into, so that replies received through the
+// latter answer them.
+func (t *requestTable) move(from, to *PeerClient) {
+	t.Lock()
+	defer t.Unlock()
+
+	for entry := range t.byClient[from] {
+		t.remove(entry)
+		entry.key.client = to
+		t.insert(entry)
+	}
+}
+
+// sweepRequests expires requests as their deadlines pass, until none are
+// left to expire or the network closes.
+func (n *Network) sweepRequests() {
+	for {
+		select {
+		case <-n.after(requestTick):
+		case <-n.kill:
+			n.requests.Lock()
+			n.requests.sweeping = false
+			n.requests.Unlock()
+			return
+		}
+
+		if !n.sweepRequestsAt(n.now()) {
+			return
+		}
+	}
+}
+
+// sweepRequestsAt fails the attempts whose deadlines passed by now with
+// ErrRequestTimeout, and stops tracking those which expired, returning false,
+// once the sweeper stopped, should no attempts be left.
+func (n *Network) sweepRequestsAt(now time.Time) bool {
+	t := &n.requests
+	t.Lock()
+
+	current := tickOf(now)
+
+	// Ticks are swept from the last one swept, which may have expired only
+	// partly, and at most one turn of the wheel at a time.
+	from := t.swept
+	if current-from >= requestWheelSlots {
+		from = current - requestWheelSlots + 1
+	}
+
+	var expired []*requestEntry
+	for tick := from; tick <= current; tick++ {
+		slot := t.wheel[tick%requestWheelSlots]
+		for entry := range slot {
+			if !entry.timedOut && !entry.deadline.After(now) {
+				entry.timedOut = true
+				expired = append(expired, entry)
+			}
+			if !entry.timedOut {
+				continue
+			}
+
+			if !entry.expiry.After(now) {
+				t.remove(entry)
+				continue
+			}
+
+			// Attempts timed out wait for late replies on the wheel until
+			// they expire.
+			if entry.tick != tickOf(entry.expiry) {
+				delete(slot, entry)
+				entry.tick = tickOf(entry.expiry)
+				t.file(entry)
+			}
+		}
+	}
+	t.swept = current
+	t.expired += uint64(len(expired))
+
+	var leaked []*requestEntry
+	if n.opts.requestLeakFactor > 0 && now.Sub(t.leakChecked) >= leakCheckInterval {
+		t.leakChecked = now
+		for _, entry := range t.entries {
+			if now.Sub(entry.sent) > time.Duration(n.opts.requestLeakFactor)*entry.expiry.Sub(entry.sent) {
+				leaked = append(leaked, entry)
+			}
+		}
+		t.leaked += uint64(len(leaked))
+	}
+
+	pending := len(t.entries) > 0
+	if !pending {
+		t.sweeping = false
+	}
+
+	t.Unlock()
+
+	for _, entry := range expired {
+		entry.state.fail(ErrRequestTimeout)
+	}
+	for _, entry := range leaked {
+		glog.Errorf("request %d to %s has been pending for %s, far past its expiry after %s", entry.key.nonce, entry.key.client.Address, now.Sub(entry.sent), entry.expiry.Sub(entry.sent))
+	}
+
+	return pending
+}
+
+// abortError returns the error requests sent through a client which closed
+// fail with.
+func (n *Network) abortError() error {
+	if n.isClosed() {
+		return ErrNetworkClosed
+	}
+	return errRequestAborted
+}
+
+// RequestTableStats returns how many requests await replies, and how many
+// were failed by the pending request table.
+func (n *Network) RequestTableStats() RequestTableStats {
+	t := &n.requests
+	t.Lock()
+	defer t.Unlock()
+
+	return RequestTableStats{
+		Pending: len(t.entries),
+		Expired: t.expired,
+		Aborted: t.aborted,
+		Evicted: t.evicted,
+		Leaked:  t.leaked,
+	}
+}
+
+// RequestID identifies a request a peer sent us, so that it may be answered
+// through Network.Reply once its handler returned.
+type RequestID struct {
+	// Address is the address of the peer which sent the request.
+	Address string
+	// Nonce is the nonce the peer sent the request under.
+	Nonce uint64
+	// Protocol is the protocol tag the request was sent under.
+	Protocol string
+
+	deadline time.Time
+}
+
+// Request sends a request to the peer at an address, connecting to it should
+// we not be connected to it yet, and waits for its reply, retrying as
+// configured through WithRetry. The request, along with connecting to the
+// peer, is given up on once ctx is done, and waits for up to the connection
+// timeout under a context without a deadline.
+func (n *Network) Request(ctx context.Context, address string, req proto.Message, opts ...RequestOption) (proto.Message, error) {
+	if !n.ConnectionStateExists(address) && !n.isClosed() {
+		unified, err := ToUnifiedAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		address = unified
+
+		if !n.ConnectionStateExists(address) {
+			if err := n.dialForWrite(ctx, address, DialOnDemand); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	client, err := n.Client(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.opts.connectionTimeout)
+		defer cancel()
+	}
+
+	return client.RequestContext(ctx, req, opts...)
+}
+
+// Reply answers a request a peer sent us, as PluginContext.Reply does from
+// within the request's handler. Replies to requests whose deadline passed are
+// skipped, and replies are given up on once ctx is done.
+func (n *Network) Reply(ctx context.Context, id RequestID, message proto.Message) error {
+	if id.Nonce == 0 {
+		return errors.Errorf("network: message from %s is not a request", id.Address)
+	}
+
+	if n.expired(id.deadline) {
+		return nil
+	}
+
+	signed, err := n.prepareMessage(id.Protocol, message)
+	if err != nil {
+		return err
+	}
+	signed.RequestNonce = id.Nonce
+	signed.ReplyFlag = true
+
+	return n.WriteContext(ctx, id.Address, signed, WithDialPolicy(DialNever))
+}
@@ -0,0 +1,315 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestAsync sends a request the receiver never replies to from a goroutine
+// of its own.
+func requestAsync(ctx context.Context, client *PeerClient) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.RequestContext(ctx, &testpb.TestMessage{Message: "unanswered"})
+		result <- err
+	}()
+	return result
+}
+
+func requestFailed(t *testing.T, result <-chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(3 * time.Second):
+		t.Fatal("request never failed")
+		return nil
+	}
+}
+
+func TestDisconnectFailsPendingRequests(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	defer sender.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var results []<-chan error
+	for i := 0; i < 3; i++ {
+		results = append(results, requestAsync(ctx, client))
+	}
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.RequestTableStats().Pending == 3 }))
+
+	assert.Nil(t, client.Close())
+	for _, result := range results {
+		assert.Equal(t, errRequestAborted, requestFailed(t, result))
+	}
+
+	stats := sender.RequestTableStats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, uint64(3), stats.Aborted)
+}
+
+func TestAbandonedRequestsLeaveTable(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	defer sender.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	result := requestAsync(ctx, client)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.RequestTableStats().Pending == 1 }))
+
+	cancel()
+	assert.Equal(t, context.Canceled, requestFailed(t, result))
+	assert.Equal(t, RequestTableStats{}, sender.RequestTableStats())
+}
+
+func TestFullRequestTableEvictsOldest(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, _ := connectWithHandler(t, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	defer sender.Close()
+
+	sender.opts.maxPendingRequests = 2
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	oldest := requestAsync(ctx, client)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.RequestTableStats().Pending == 1 }))
+	requestAsync(ctx, client)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.RequestTableStats().Pending == 2 }))
+	requestAsync(ctx, client)
+
+	assert.Equal(t, ErrRequestEvicted, requestFailed(t, oldest))
+
+	stats := sender.RequestTableStats()
+	assert.Equal(t, 2, stats.Pending)
+	assert.Equal(t, uint64(1), stats.Evicted)
+}
+
+func TestRequestsTimeOutOnWheel(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	receiver := buildClockedNode(t, &fakeClock{now: time.Now()}, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	sender := buildClockedNode(t, clock, func(ctx *PluginContext) {})
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(time.Second))
+	defer cancel()
+
+	result := requestAsync(ctx, client)
+	assert.True(t, waitUntil(3*time.Second, func() bool { return sender.RequestTableStats().Pending == 1 }))
+
+	// A single sweeper waits on the wheel's next tick, and only while
+	// requests are pending.
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(time.Second - requestTick)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, sender.RequestTableStats().Pending)
+
+	clock.Advance(requestTick)
+	assert.Equal(t, ErrRequestTimeout, requestFailed(t, result))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return clock.Waiters() == 0 }), "sweeper never stopped")
+	assert.Equal(t, RequestTableStats{Expired: 1}, sender.RequestTableStats())
+}
+
+func TestRequestLeakCheck(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, RequestLeakCheck(2))
+	defer node.Close()
+
+	client, err := createPeerClient(node, "tcp://127.0.0.1:1")
+	assert.Nil(t, err)
+
+	state := &RequestState{failed: make(chan error, 1)}
+	entry := node.trackAttempt(client, 1, state, time.Second, time.Time{})
+
+	// Should a request ever slip off the wheel, it is reported once it
+	// lingers for longer than twice its expiry.
+	node.requests.Lock()
+	delete(node.requests.wheel[entry.tick%requestWheelSlots], entry)
+	node.requests.Unlock()
+
+	clock.Advance(2 * time.Second)
+	assert.True(t, node.sweepRequestsAt(clock.Now()))
+	assert.Equal(t, uint64(0), node.RequestTableStats().Leaked)
+
+	clock.Advance(time.Second)
+	assert.True(t, node.sweepRequestsAt(clock.Now()))
+	assert.Equal(t, uint64(1), node.RequestTableStats().Leaked)
+
+	_, err = NewBuilderWithOptions(MaxPendingRequests(0)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestRequestTableSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skipf("skipping %s in short mode", t.Name())
+	}
+
+	clock := &fakeClock{now: time.Now()}
+	node := buildClockedNode(t, clock, func(ctx *PluginContext) {}, MaxPendingRequests(4096))
+	defer node.Close()
+
+	clients := make([]*PeerClient, 64)
+	for i := range clients {
+		client, err := createPeerClient(node, fmt.Sprintf("tcp://127.0.0.1:%d", 1000+i))
+		assert.Nil(t, err)
+		clients[i] = client
+	}
+
+	heap := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+
+	const requests = 1000000
+
+	var baseline uint64
+	for i := 0; i < requests; i++ {
+		client := clients[i%len(clients)]
+		state := &RequestState{failed: make(chan error, 1)}
+		entry := node.trackAttempt(client, uint64(i), state, time.Duration(1+i%50)*requestTick, time.Time{})
+
+		switch i % 4 {
+		case 0:
+			// Answered, or abandoned by its caller.
+			assert.Equal(t, state, node.requests.lookup(client, uint64(i)))
+			node.requests.untrack(entry)
+		case 1:
+			// Left to time out.
+		case 2:
+			// Failed as its peer disconnects.
+			if i%1000 == 2 {
+				node.requests.fail(client, errRequestAborted)
+			}
+		case 3:
+			// Left to be evicted, should the table fill up.
+		}
+
+		if i%500 == 0 {
+			clock.Advance(requestTick)
+			node.sweepRequestsAt(clock.Now())
+		}
+
+		if i == requests/10 {
+			baseline = heap()
+		}
+	}
+
+	clock.Advance(time.Second)
+	node.sweepRequestsAt(clock.Now())
+
+	stats := node.RequestTableStats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.True(t, stats.Expired > 0 && stats.Aborted > 0 && stats.Evicted > 0, "unexpected stats %+v", stats)
+
+	node.requests.Lock()
+	assert.Empty(t, node.requests.byClient)
+	assert.Equal(t, 0, node.requests.ages.Len())
+	for _, slot := range node.requests.wheel {
+		assert.Empty(t, slot)
+	}
+	node.requests.Unlock()
+
+	// The table holds nothing more than it did early on.
+	const slack = 4 << 20
+	assert.True(t, heap() < baseline+slack, "heap grew from %d to %d bytes", baseline, heap())
+}
+
+func TestNetworkRequestAndDeferredReply(t *testing.T) {
+	t.Parallel()
+
+	ids := make(chan RequestID, 1)
+
+	receiver, sender, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok && msg.Message == "deferred" {
+			ids <- ctx.RequestID()
+		}
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	// Requests are answered once their handler hands them off to be replied to.
+	go func() {
+		id := <-ids
+		assert.Nil(t, receiver.Reply(context.Background(), id, &testpb.TestMessage{Message: "answer"}))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := sender.Request(ctx, receiver.Address, &testpb.TestMessage{Message: "deferred"})
+	assert.Nil(t, err)
+	assert.Equal(t, "answer", res.(*testpb.TestMessage).Message)
+
+	// Requests left unanswered are given up on once their context is done.
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = sender.Request(ctx, receiver.Address, &testpb.TestMessage{Message: "ignored"})
+	assert.NotNil(t, err)
+
+	_, err = sender.Request(context.Background(), "tcp://localhost:1", &testpb.TestMessage{Message: "unreachable"})
+	assert.NotNil(t, err)
+
+	assert.NotNil(t, receiver.Reply(context.Background(), RequestID{Address: sender.Address}, &testpb.TestMessage{}))
+}
+
+func TestCancelledRequestGivesUpOnDialing(t *testing.T) {
+	t.Parallel()
+
+	// The peer accepts connections, though never answers them.
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	node := buildListeningNode(t, ConnectionTimeout(10*time.Second), HandshakeTimeout(10*time.Second))
+	defer node.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err = node.Request(ctx, "tcp://"+listener.Addr().String(), &testpb.TestMessage{Message: "unreachable"})
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+	assert.True(t, time.Since(start) < time.Second, "request took %s to give up", time.Since(start))
+}
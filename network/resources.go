@@ -0,0 +1,275 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Subsystems goroutines and file descriptors are accounted to.
+const (
+	// SubsystemNetwork accounts for the listener, the loop accepting
+	// connections off it, and loops serving the whole network.
+	SubsystemNetwork = "network"
+	// SubsystemPeers accounts for the connections to peers, and the loops
+	// reading from and writing to them.
+	SubsystemPeers = "peers"
+	// SubsystemDials accounts for the connections being dialed, up until
+	// their handshake completes and they are accounted to SubsystemPeers.
+	SubsystemDials = "dials"
+	// SubsystemDispatch accounts for the dispatch workers, and the goroutines
+	// handling messages without them.
+	SubsystemDispatch = "dispatch"
+)
+
+// subsystems lists every subsystem resources are accounted to.
+var subsystems = []string{SubsystemNetwork, SubsystemPeers, SubsystemDials, SubsystemDispatch}
+
+// Resources which may be budgeted.
+const (
+	ResourceGoroutines = "goroutines"
+	ResourceFDs        = "fds"
+)
+
+// ErrOverBudget is the cause of BudgetErrors.
+var ErrOverBudget = errors.New("network: resource budget exceeded")
+
+// Budget bounds the goroutines and file descriptors a subsystem may hold at
+// once. Zero leaves a resource unbounded.
+type Budget struct {
+	Goroutines int
+	FDs        int
+}
+
+// BudgetError is returned should a subsystem be refused a resource as it
+// would go over budget.
+type BudgetError struct {
+	// Subsystem is the subsystem refused the resource, such as SubsystemPeers.
+	Subsystem string
+	// Resource is the resource refused, being either ResourceGoroutines or
+	// ResourceFDs.
+	Resource string
+	// Budget is the number of the resource the subsystem may hold at once.
+	Budget int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("network: %s budget of %d %s exceeded", e.Subsystem, e.Budget, e.Resource)
+}
+
+// Cause returns ErrOverBudget.
+func (e *BudgetError) Cause() error {
+	return ErrOverBudget
+}
+
+// ResourceUsage counts the goroutines and file descriptors held at once, and
+// how many were refused for going over budget.
+type ResourceUsage struct {
+	Goroutines int64
+	FDs        int64
+	Refused    uint64
+}
+
+// ResourceReport breaks the resources held by the network down by subsystem.
+type ResourceReport struct {
+	// ResourceUsage is the total across every subsystem.
+	ResourceUsage
+	// Subsystems is the usage of every subsystem, by name.
+	Subsystems map[string]ResourceUsage
+	// Budgets are the budgets of subsystems which have one, by name.
+	Budgets map[string]Budget
+}
+
+// resourceCounter counts the resources held by a subsystem.
+type resourceCounter struct {
+	goroutines int64  // for atomic ops
+	fds        int64  // for atomic ops
+	refused    uint64 // for atomic ops
+
+	budget Budget
+}
+
+func (c *resourceCounter) usage() ResourceUsage {
+	return ResourceUsage{
+		Goroutines: atomic.LoadInt64(&c.goroutines),
+		FDs:        atomic.LoadInt64(&c.fds),
+		Refused:    atomic.LoadUint64(&c.refused),
+	}
+}
+
+// resourceRegistry accounts resources to subsystems, which are fixed once
+// built.
+type resourceRegistry struct {
+	counters   map[string]*resourceCounter
+	onExceeded func(err *BudgetError)
+}
+
+func newResourceRegistry(budgets map[string]Budget, onExceeded func(err *BudgetError)) *resourceRegistry {
+	r := &resourceRegistry{counters: make(map[string]*resourceCounter, len(subsystems)), onExceeded: onExceeded}
+	for _, subsystem := range subsystems {
+		r.counters[subsystem] = &resourceCounter{budget: budgets[subsystem]}
+	}
+	return r
+}
+
+// track accounts resources taken, or given back should they be negative, to
+// a subsystem regardless of its budget.
+func (r *resourceRegistry) track(subsystem string, goroutines, fds int) {
+	c := r.counters[subsystem]
+	atomic.AddInt64(&c.goroutines, int64(goroutines))
+	atomic.AddInt64(&c.fds, int64(fds))
+}
+
+// acquire accounts resources taken to a subsystem, refusing them should the
+// subsystem go over budget.
+func (r *resourceRegistry) acquire(subsystem string, goroutines, fds int) error {
+	c := r.counters[subsystem]
+
+	var refused *BudgetError
+
+	held := atomic.AddInt64(&c.goroutines, int64(goroutines))
+	if goroutines > 0 && c.budget.Goroutines > 0 && held > int64(c.budget.Goroutines) {
+		refused = &BudgetError{Subsystem: subsystem, Resource: ResourceGoroutines, Budget: c.budget.Goroutines}
+	}
+
+	held = atomic.AddInt64(&c.fds, int64(fds))
+	if refused == nil && fds > 0 && c.budget.FDs > 0 && held > int64(c.budget.FDs) {
+		refused = &BudgetError{Subsystem: subsystem, Resource: ResourceFDs, Budget: c.budget.FDs}
+	}
+
+	if refused == nil {
+		return nil
+	}
+
+	r.track(subsystem, -goroutines, -fds)
+	atomic.AddUint64(&c.refused, 1)
+
+	glog.Warning(refused)
+	if r.onExceeded != nil {
+		r.onExceeded(refused)
+	}
+
+	return refused
+}
+
+// report returns the resources held by every subsystem.
+func (r *resourceRegistry) report() ResourceReport {
+	report := ResourceReport{
+		Subsystems: make(map[string]ResourceUsage, len(r.counters)),
+		Budgets:    make(map[string]Budget),
+	}
+
+	for subsystem, c := range r.counters {
+		usage := c.usage()
+		report.Subsystems[subsystem] = usage
+
+		report.Goroutines += usage.Goroutines
+		report.FDs += usage.FDs
+		report.Refused += usage.Refused
+
+		if c.budget != (Budget{}) {
+			report.Budgets[subsystem] = c.budget
+		}
+	}
+
+	return report
+}
+
+// ResourceReport returns the goroutines and file descriptors held by the
+// network, broken down by subsystem.
+func (n *Network) ResourceReport() ResourceReport {
+	return n.resources.report()
+}
+
+// spawnIn spawns a task accounted as a goroutine of a subsystem for as long
+// as it runs, regardless of the subsystem's budget.
+func (n *Network) spawnIn(subsystem string, task func()) {
+	n.resources.track(subsystem, 1, 0)
+	n.spawn(func() {
+		defer n.resources.track(subsystem, -1, 0)
+		task()
+	})
+}
+
+// trySpawnIn spawns a task accounted as a goroutine of a subsystem for as long
+// as it runs, unless the subsystem would go over budget.
+func (n *Network) trySpawnIn(subsystem string, task func()) error {
+	if err := n.resources.acquire(subsystem, 1, 0); err != nil {
+		return err
+	}
+	n.spawn(func() {
+		defer n.resources.track(subsystem, -1, 0)
+		task()
+	})
+	return nil
+}
+
+// accountedExecutor accounts the tasks it runs as goroutines of a subsystem.
+type accountedExecutor struct {
+	n         *Network
+	subsystem string
+}
+
+func (e accountedExecutor) Go(task func()) {
+	e.n.spawnIn(e.subsystem, task)
+}
+
+func (e accountedExecutor) Yield() {
+	e.n.yield()
+}
+
+// accountedConn is a connection accounted as a file descriptor of a
+// subsystem until it is closed.
+type accountedConn struct {
+	net.Conn
+
+	resources *resourceRegistry
+
+	mu        sync.Mutex
+	subsystem string
+	closed    bool
+}
+
+// accountConn accounts a connection to a subsystem until it is closed, unless
+// the subsystem would go over budget.
+func (n *Network) accountConn(subsystem string, conn net.Conn) (*accountedConn, error) {
+	if err := n.resources.acquire(subsystem, 0, 1); err != nil {
+		return nil, err
+	}
+	return &accountedConn{Conn: conn, resources: n.resources, subsystem: subsystem}, nil
+}
+
+// transfer accounts the connection to another subsystem, unless it would go
+// over budget.
+func (c *accountedConn) transfer(subsystem string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	if err := c.resources.acquire(subsystem, 0, 1); err != nil {
+		return err
+	}
+	c.resources.track(c.subsystem, 0, -1)
+	c.subsystem = subsystem
+
+	return nil
+}
+
+// Close closes the connection, and gives its file descriptor back.
+func (c *accountedConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		c.resources.track(c.subsystem, 0, -1)
+	}
+	c.mu.Unlock()
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// connectPeers has a node dial peers, and write to them so that they dial it
+// back, until every connection is up.
+func connectPeers(t *testing.T, node *Network, peers ...*Network) {
+	for _, peer := range peers {
+		_, err := node.Client(peer.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, node.Write(peer.Address, signedTestMessage(t, node, "hello")))
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		for _, peer := range peers {
+			if !peer.ConnectionStateExists(node.Address) {
+				return false
+			}
+		}
+		return countPeers(node, DirectionOutbound) == len(peers) && node.ResourceReport().Subsystems[SubsystemPeers].FDs == int64(2*len(peers))
+	}), "peers never dialed back")
+}
+
+func TestResourceReportMatchesTopology(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+	a := buildListeningNode(t)
+	defer a.Close()
+	b := buildListeningNode(t)
+	defer b.Close()
+
+	connectPeers(t, node, a, b)
+
+	// Every peer takes a connection each way, a send loop for the connection
+	// we dialed and a receive loop for the one it dialed back.
+	expected := map[string]ResourceUsage{
+		SubsystemNetwork:  {Goroutines: 2, FDs: 1}, // accept and flush loops, listener
+		SubsystemPeers:    {Goroutines: 4, FDs: 4},
+		SubsystemDials:    {},
+		SubsystemDispatch: {},
+	}
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return assert.ObjectsAreEqual(expected, node.ResourceReport().Subsystems)
+	}), "report %+v", node.ResourceReport().Subsystems)
+
+	assert.Equal(t, ResourceUsage{Goroutines: 6, FDs: 5}, node.Stats().Resources())
+
+	// Resources are given back as peers are disconnected from.
+	client, connected := node.peers.Load(b.Address)
+	if assert.True(t, connected) {
+		client.(*PeerClient).Close()
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return node.ResourceReport().Subsystems[SubsystemPeers] == ResourceUsage{Goroutines: 2, FDs: 2}
+	}), "report %+v", node.ResourceReport().Subsystems)
+}
+
+func TestResourceBudgetRefusesConnections(t *testing.T) {
+	t.Parallel()
+
+	exceeded := make(chan *BudgetError, 1)
+
+	// Room for two peers, each connected both ways.
+	node := buildListeningNode(t, ResourceBudget(SubsystemPeers, 0, 4), OnBudgetExceeded(func(err *BudgetError) {
+		exceeded <- err
+	}))
+	defer node.Close()
+
+	var peers []*Network
+	for i := 0; i < 3; i++ {
+		peer := buildListeningNode(t)
+		defer peer.Close()
+		peers = append(peers, peer)
+	}
+
+	connectPeers(t, node, peers[:2]...)
+
+	_, err := node.Client(peers[2].Address)
+	assert.Equal(t, ErrOverBudget, errors.Cause(err))
+
+	select {
+	case err := <-exceeded:
+		assert.Equal(t, &BudgetError{Subsystem: SubsystemPeers, Resource: ResourceFDs, Budget: 4}, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("budget was never reported exceeded")
+	}
+
+	report := node.ResourceReport()
+	assert.Equal(t, ResourceUsage{Goroutines: 4, FDs: 4, Refused: 1}, report.Subsystems[SubsystemPeers])
+	assert.Equal(t, ResourceUsage{}, report.Subsystems[SubsystemDials])
+	assert.Equal(t, map[string]Budget{SubsystemPeers: {FDs: 4}}, report.Budgets)
+	assert.Len(t, node.Peers(), 2)
+}
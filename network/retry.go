@@ -0,0 +1,280 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+const (
+	// IdempotencyKeyMetadata is the metadata key the idempotency key shared
+	// by all attempts of a retried request is carried under.
+	IdempotencyKeyMetadata = "noise-idempotency-key"
+
+	// defaultIdempotencyWindow is how long the idempotency keys of requests
+	// handled are remembered for.
+	defaultIdempotencyWindow = time.Minute
+	// defaultIdempotencySize is how many idempotency keys are remembered.
+	defaultIdempotencySize = 4096
+)
+
+var (
+	// ErrRequestTimeout is returned should no reply to a request arrive in
+	// time.
+	ErrRequestTimeout = errors.New("request timed out")
+	// ErrNoDeadline is returned when sending a request through RequestContext
+	// under a context without a deadline.
+	ErrNoDeadline = errors.New("network: request context has no deadline")
+)
+
+// RetryPolicy retries requests whose attempts fail in a retryable way, within
+// the deadline of the context the request was sent under.
+type RetryPolicy struct {
+	// MaxAttempts bounds how many times a request is sent, counting the first
+	// attempt (default: 1).
+	MaxAttempts int
+	// Backoff returns how long to wait before the attempt following a failed
+	// one, numbered from 1 (default: no wait).
+	Backoff func(failed int) time.Duration
+	// Retryable returns true should a failed attempt be retried (default:
+	// IsRetryable).
+	Retryable func(err error) bool
+}
+
+// IsRetryable returns true should an attempt of a request have timed out.
+func IsRetryable(err error) bool {
+	return errors.Cause(err) == ErrRequestTimeout
+}
+
+// RequestOption configures a request sent through RequestContext.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	retry RetryPolicy
+}
+
+// WithRetry returns a RequestOption that retries the request under a policy.
+// All attempts carry the same idempotency key, so that peers handle the
+// request at most once however many attempts reach them.
+func WithRetry(policy RetryPolicy) RequestOption {
+	return func(o *requestOptions) {
+		o.retry = policy
+	}
+}
+
+// AttemptsError is returned should every attempt of a retried request fail.
+type AttemptsError struct {
+	// Errors holds why every attempt failed, in order.
+	Errors []error
+}
+
+func (e *AttemptsError) Error() string {
+	failures := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		failures[i] = err.Error()
+	}
+	return "request failed after " + pluralize(len(e.Errors), "attempt") + ": " + strings.Join(failures, "; ")
+}
+
+// Cause returns why the last attempt failed.
+func (e *AttemptsError) Cause() error {
+	return e.Errors[len(e.Errors)-1]
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(count) + " " + noun + "s"
+}
+
+// pendingRequest tracks the attempts of a request awaiting a reply, which
+// answers any of them.
+type pendingRequest struct {
+	client      *PeerClient
+	replies     chan proto.Message
+	closeSignal chan struct{}
+	attempts    []*requestEntry
+
+	// urgent requests are flushed out as they are sent, rather than left to
+	// the flusher.
+	urgent bool
+}
+
+func (c *PeerClient) trackRequest() *pendingRequest {
+	return &pendingRequest{
+		client:      c,
+		replies:     make(chan proto.Message, 1),
+		closeSignal: make(chan struct{}),
+	}
+}
+
+// attempt sends a request under a nonce of its own, waiting for a reply to it
+// or to any earlier attempt for up to timeout.
+func (r *pendingRequest) attempt(ctx context.Context, signed *protobuf.Message, timeout time.Duration) (proto.Message, error) {
+	c := r.client
+
+	attempt := *signed
+	attempt.RequestNonce = atomic.AddUint64(&c.RequestNonce, 1)
+	attempt.BudgetMs = c.Network.requestBudget(timeout)
+
+	// Attempts are answered by late replies for as long as the request is.
+	retain := c.Network.now().Add(timeout)
+	if deadline, ok := ctx.Deadline(); ok && deadline.After(retain) {
+		retain = deadline
+	}
+
+	// Start tracking the attempt before sending it, as the peer may reject it
+	// right away.
+	state := &RequestState{
+		data:        r.replies,
+		rejected:    make(chan *Rejection, 1),
+		failed:      make(chan error, 1),
+		closeSignal: r.closeSignal,
+	}
+	r.attempts = append(r.attempts, c.Network.trackAttempt(c, attempt.RequestNonce, state, timeout, retain))
+
+	if err := c.Network.Write(c.Address, &attempt); err != nil {
+		return nil, err
+	}
+	if r.urgent {
+		c.Network.flushPeer(c.Address)
+	}
+
+	// The pending request table times the attempt out, fails it should the
+	// peer disconnect, and carries it on over the session the peer's session
+	// is merged into.
+	select {
+	case res := <-r.replies:
+		return res, nil
+	case rejection := <-state.rejected:
+		return nil, rejection
+	case err := <-state.failed:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// close stops tracking all attempts, such as once the request was answered
+// or its caller gave up on it.
+func (r *pendingRequest) close() {
+	r.client.Network.requests.untrack(r.attempts...)
+	close(r.closeSignal)
+}
+
+// RequestContext sends a request to the peer, retrying it as configured
+// through WithRetry. The deadline of ctx is the budget all attempts share:
+// every attempt waits for its share of the time remaining, so that each
+// retry leaves time for those after it. Replies to earlier attempts still
+// answer the request. Should every attempt fail, an *AttemptsError holds why.
+func (c *PeerClient) RequestContext(ctx context.Context, message proto.Message, opts ...RequestOption) (proto.Message, error) {
+	return c.requestContext(ctx, "", message, opts...)
+}
+
+func (c *PeerClient) requestContext(ctx context.Context, protocol string, message proto.Message, opts ...RequestOption) (proto.Message, error) {
+	var o requestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	policy := o.retry
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil, ErrNoDeadline
+	}
+
+	if !c.SupportsProtocol(protocol) {
+		return nil, errors.Wrapf(ErrProtocolUnsupported, "failed to send request to %s under %q", c.Address, protocol)
+	}
+
+	var fields envelopeFields
+	if policy.MaxAttempts > 1 {
+		key, err := newIdempotencyKey()
+		if err != nil {
+			return nil, err
+		}
+		fields.metadata = map[string]string{IdempotencyKeyMetadata: key}
+	}
+
+	signed, err := c.Network.prepareEnvelope(protocol, message, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	r := c.trackRequest()
+	defer r.close()
+
+	var failures []error
+	for attempt := 1; ; attempt++ {
+		remaining := deadline.Sub(c.Network.now())
+		if remaining <= 0 {
+			failures = append(failures, context.DeadlineExceeded)
+			break
+		}
+
+		res, err := r.attempt(ctx, signed, remaining/time.Duration(policy.MaxAttempts-attempt+1))
+		if err == nil {
+			return res, nil
+		}
+		failures = append(failures, err)
+
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !policy.Retryable(err) {
+			break
+		}
+
+		if policy.Backoff != nil {
+			if backoff := policy.Backoff(attempt); backoff > 0 {
+				select {
+				case <-c.Network.after(backoff):
+				case <-ctx.Done():
+				}
+			}
+		}
+	}
+
+	if len(failures) == 1 {
+		return nil, failures[0]
+	}
+	return nil, &AttemptsError{Errors: failures}
+}
+
+// newIdempotencyKey returns a random key identifying all attempts of a request.
+func newIdempotencyKey() (string, error) {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", errors.Wrap(err, "failed to generate idempotency key")
+	}
+	return hex.EncodeToString(key[:]), nil
+}
+
+// isRetriedRequest returns true, counting a duplicate, should a request carry
+// the idempotency key of a request from the same peer handled already.
+func (n *Network) isRetriedRequest(message *protobuf.Message) bool {
+	key := message.Metadata[IdempotencyKeyMetadata]
+	if key == "" || n.idempotency == nil || message.RequestNonce == 0 || message.Sender == nil {
+		return false
+	}
+
+	if !n.idempotency.seen(string(message.Sender.PublicKey)+key, n.now()) {
+		return false
+	}
+
+	atomic.AddUint64(&n.duplicates, 1)
+	return true
+}
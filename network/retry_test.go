@@ -0,0 +1,156 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// retryBudget is the deadline the requests of retry tests are sent under.
+const retryBudget = 900 * time.Millisecond
+
+// retryPair builds a requester on a fake clock, connected to a responder on a
+// clock of its own which never moves, running fn on every test message.
+func retryPair(t *testing.T, fn func(ctx *PluginContext), opts ...BuilderOption) (requester, responder *Network, client *PeerClient, clock *fakeClock) {
+	// Contexts expire in real time, which is left far behind the fake clock.
+	clock = &fakeClock{now: time.Now().Add(time.Hour)}
+
+	responder = buildClockedNode(t, &fakeClock{now: time.Now()}, fn, opts...)
+	requester = buildClockedNode(t, clock, func(*PluginContext) {})
+
+	client, err := requester.Client(responder.Address)
+	assert.Nil(t, err)
+
+	return requester, responder, client, clock
+}
+
+// requestWithRetry sends a request under a policy retrying it up to attempts
+// times within retryBudget, from a goroutine of its own.
+func requestWithRetry(client *PeerClient, clock *fakeClock, attempts int) <-chan error {
+	result := make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(retryBudget))
+		defer cancel()
+
+		_, err := client.RequestContext(ctx, &testpb.TestMessage{Message: "request"}, WithRetry(RetryPolicy{MaxAttempts: attempts}))
+		result <- err
+	}()
+
+	return result
+}
+
+// expireAttempt advances the requester's clock past the timeout of the attempt
+// being waited on.
+func expireAttempt(t *testing.T, clock *fakeClock, timeout time.Duration) {
+	assert.True(t, waitUntil(3*time.Second, func() bool { return clock.Waiters() > 0 }))
+	clock.Advance(timeout)
+}
+
+func TestRetrySharesDeadlineBudget(t *testing.T) {
+	t.Parallel()
+
+	budgets := make(chan time.Duration, 4)
+	requester, responder, client, clock := retryPair(t, func(ctx *PluginContext) {
+		deadline, _ := ctx.Deadline()
+		budgets <- deadline.Sub(ctx.Network().now())
+	}, IdempotencyWindow(0, 0))
+	defer requester.Close()
+	defer responder.Close()
+
+	result := requestWithRetry(client, clock, 3)
+
+	// Every attempt waits for its share of what remains of the budget.
+	var total time.Duration
+	for i := 0; i < 3; i++ {
+		select {
+		case budget := <-budgets:
+			assert.Equal(t, retryBudget/3, budget)
+			total += budget
+			expireAttempt(t, clock, budget)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("attempt %d never arrived", i+1)
+		}
+	}
+	assert.Equal(t, retryBudget, total)
+
+	err := <-result
+	attempts, ok := err.(*AttemptsError)
+	assert.True(t, ok, "unexpected error: %v", err)
+	assert.Len(t, attempts.Errors, 3)
+	assert.Equal(t, ErrRequestTimeout, errors.Cause(err))
+	assert.Len(t, budgets, 0)
+}
+
+func TestRetryStopsOnSuccess(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	requester, responder, client, clock := retryPair(t, func(ctx *PluginContext) {
+		if attempts.Inc() == 2 {
+			ctx.Reply(&testpb.TestMessage{Message: "reply"})
+		}
+	}, IdempotencyWindow(0, 0))
+	defer requester.Close()
+	defer responder.Close()
+
+	result := requestWithRetry(client, clock, 3)
+
+	assert.True(t, waitUntil(3*time.Second, func() bool { return attempts.Load() == 1 }))
+	expireAttempt(t, clock, retryBudget/3)
+
+	select {
+	case err := <-result:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("request never succeeded")
+	}
+
+	// No attempt follows the one which succeeded.
+	clock.Advance(retryBudget)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestRetryIsHandledOnce(t *testing.T) {
+	t.Parallel()
+
+	executions := make(chan struct{}, 4)
+	requester, responder, client, clock := retryPair(t, func(ctx *PluginContext) {
+		executions <- struct{}{}
+
+		// The reply is held back until the retry reached the responder.
+		peer, nonce, network := ctx.Client(), ctx.nonce, ctx.Network()
+		go func() {
+			waitUntil(3*time.Second, func() bool { return network.DedupeStats().Duplicates > 0 })
+			peer.Reply(nonce, &testpb.TestMessage{Message: "reply"})
+		}()
+	})
+	defer requester.Close()
+	defer responder.Close()
+
+	result := requestWithRetry(client, clock, 3)
+
+	<-executions
+	expireAttempt(t, clock, retryBudget/3)
+
+	// The late reply to the first attempt answers the request.
+	select {
+	case err := <-result:
+		assert.Nil(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("request never succeeded")
+	}
+
+	assert.Len(t, executions, 0)
+	assert.Equal(t, uint64(1), responder.DedupeStats().Duplicates)
+
+	// Requests need a deadline to share between their attempts.
+	_, err := client.RequestContext(context.Background(), &testpb.TestMessage{})
+	assert.Equal(t, ErrNoDeadline, err)
+}
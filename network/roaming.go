@@ -0,0 +1,130 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DisconnectMerged is the reason sessions displaced by a session of the same
+// peer are disconnected for, once merged into it.
+const DisconnectMerged = "merged"
+
+// RoamingPolicy decides what happens when a known peer connects from a new address.
+type RoamingPolicy int
+
+const (
+	// RoamingDisabled treats a known public key connecting from a new address
+	// as a brand new peer.
+	RoamingDisabled RoamingPolicy = iota
+	// RoamingMigrate carries the sequence numbers and quarantine status of a
+	// known public key over to its new address once the peer has proven
+	// possession of the key, merging any session lingering at the old address
+	// into the new one.
+	RoamingMigrate
+)
+
+// identities binds the public keys of peers to the address each was last
+// seen at.
+type identities struct {
+	sync.Mutex
+	addresses map[string]string // public key -> address
+}
+
+// bind binds a public key to an address, returning the address it was bound to before.
+func (i *identities) bind(publicKey []byte, address string) (string, bool) {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.addresses == nil {
+		i.addresses = make(map[string]string)
+	}
+
+	previous, known := i.addresses[string(publicKey)]
+	i.addresses[string(publicKey)] = address
+
+	return previous, known && previous != address
+}
+
+// lookup returns the address a public key is bound to.
+func (i *identities) lookup(publicKey []byte) (string, bool) {
+	i.Lock()
+	defer i.Unlock()
+
+	address, known := i.addresses[string(publicKey)]
+	return address, known
+}
+
+// unbind forgets a public key, provided it is still bound to an address.
+func (i *identities) unbind(publicKey []byte, address string) {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.addresses[string(publicKey)] == address {
+		delete(i.addresses, string(publicKey))
+	}
+}
+
+// roam binds a peer's public key to the address of a client which has just
+// proven possession of the key in a handshake, migrating state the key holds
+// at another address over to the client should the roaming policy allow it.
+func (n *Network) roam(client *PeerClient, state *ConnState, publicKey []byte) {
+	previous, moved := n.identities.bind(publicKey, client.Address)
+	if !moved || n.opts.roamingPolicy != RoamingMigrate {
+		return
+	}
+
+	// Take over from a session lingering at the old address.
+	if c, exists := n.peers.Load(previous); exists {
+		stale := c.(*PeerClient)
+
+		if staleState, ok := n.ConnectionState(previous); ok {
+			atomic.StoreUint64(&state.messageNonce, atomic.LoadUint64(&staleState.messageNonce))
+		}
+		atomic.StoreUint64(&client.RequestNonce, atomic.LoadUint64(&stale.RequestNonce))
+
+		if !stale.Quarantined() {
+			client.quarantine.Lock()
+			client.quarantine.active = false
+			client.quarantine.Unlock()
+		}
+
+		n.merge(stale, client)
+		return
+	}
+
+	// Otherwise, carry on from the session held for the old address.
+	if sess := n.sessions.migrateHeld(previous, publicKey); sess != nil {
+		atomic.StoreUint64(&state.messageNonce, sess.messageNonce)
+		atomic.StoreUint64(&client.RequestNonce, sess.requestNonce)
+	}
+}
+
+// merge closes a session lingering at the old address of a peer without
+// losing what it received: messages yet to be dispatched, handlers queued up
+// in order and requests awaiting replies are handed over to the client which
+// displaced it, and messages still read from the lingering connection are
+// dispatched through that client from then on.
+func (n *Network) merge(stale *PeerClient, client *PeerClient) {
+	stale.mergeMutex.Lock()
+	stale.successor = client
+
+	// Nothing is queued up on the client yet, as it is still being set up.
+	for queued := true; queued; {
+		select {
+		case job := <-stale.jobs:
+			client.submit(job)
+		default:
+			queued = false
+		}
+	}
+
+	stale.orderedQueues.Range(func(name, queue interface{}) bool {
+		client.orderedQueues.LoadOrStore(name, queue)
+		return true
+	})
+	stale.mergeMutex.Unlock()
+
+	n.requests.move(stale, client)
+
+	stale.close(DisconnectMerged)
+}
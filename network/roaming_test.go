@@ -0,0 +1,100 @@
+package network
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoaming(t *testing.T) {
+	t.Parallel()
+
+	build := func(keys *crypto.KeyPair, plugin PluginInterface, clock *fakeClock, opts ...BuilderOption) *Network {
+		builder := NewBuilderWithOptions(opts...)
+		builder.SetKeys(keys)
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(plugin)
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		if clock != nil {
+			node.now = clock.Now
+			node.after = clock.After
+		}
+
+		go node.Listen()
+		<-node.Ready()
+
+		return node
+	}
+
+	addresses := func(n *Network) []string {
+		var addresses []string
+		for _, info := range n.Peers() {
+			addresses = append(addresses, info.Address)
+		}
+		return addresses
+	}
+
+	for _, policy := range []RoamingPolicy{RoamingDisabled, RoamingMigrate} {
+		clock := &fakeClock{now: time.Now()}
+
+		node := build(ed25519.RandomKeyPair(), new(Plugin), clock, Roaming(policy), QuarantinePeriod(time.Minute))
+
+		keys := ed25519.RandomKeyPair()
+
+		roamer := build(keys, new(Plugin), nil)
+
+		client, err := roamer.Client(node.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+		waitForPeers(t, node, 1)
+
+		stale, err := node.Client(roamer.Address)
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, stale.Tell(&testpb.TestMessage{Message: "hello"}))
+		}
+
+		clock.Advance(time.Minute)
+		assert.True(t, waitUntil(3*time.Second, func() bool { return !stale.Quarantined() }), "quarantine period elapsed without the peer graduating")
+
+		// Reconnect with the same keys from a different address, leaving the
+		// session at the old address lingering.
+		roamedPlugin := new(countingPlugin)
+		roamed := build(keys, roamedPlugin, nil)
+
+		client, err = roamed.Client(node.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+		assert.True(t, waitUntil(3*time.Second, func() bool { return node.ConnectionStateExists(roamed.Address) }))
+
+		moved, err := node.Client(roamed.Address)
+		assert.Nil(t, err)
+		state, _ := node.ConnectionState(roamed.Address)
+
+		if policy == RoamingMigrate {
+			assert.True(t, waitUntil(3*time.Second, func() bool { return len(node.Peers()) == 1 }), "stale session should be closed")
+			assert.Equal(t, []string{roamed.Address}, addresses(node))
+			assert.Equal(t, uint64(3), atomic.LoadUint64(&state.messageNonce), "sequence numbers should carry on")
+			assert.False(t, moved.Quarantined(), "graduation should carry over")
+		} else {
+			assert.ElementsMatch(t, []string{roamer.Address, roamed.Address}, addresses(node))
+			assert.Equal(t, uint64(0), atomic.LoadUint64(&state.messageNonce))
+			assert.True(t, moved.Quarantined())
+		}
+
+		assert.Nil(t, moved.Tell(&testpb.TestMessage{Message: "hello"}))
+		assert.True(t, waitUntil(3*time.Second, func() bool { return roamedPlugin.received.Load() == 1 }))
+
+		node.Close()
+		roamer.Close()
+		roamed.Close()
+	}
+}
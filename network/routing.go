@@ -0,0 +1,248 @@
+package network
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// RoutingCapability is advertised by nodes which accept messages routed
+// toward a key through WriteToward.
+const RoutingCapability = "noise/routing"
+
+// defaultMaxHops bounds how many times a routed message is forwarded should
+// its sender not bound it.
+const defaultMaxHops = 16
+
+var (
+	// ErrNoCloserPeer is returned should no connected peer be eligible to be
+	// sent a message routed toward a key.
+	ErrNoCloserPeer = errors.New("network: no connected peer is closer to the key")
+	// ErrHopLimit is returned when forwarding a routed message which was
+	// already forwarded as many times as its sender allowed.
+	ErrHopLimit = errors.New("network: routed message reached its hop limit")
+	// ErrNotRouted is returned when forwarding a message which was not routed
+	// toward a key.
+	ErrNotRouted = errors.New("network: message was not routed toward a key")
+)
+
+var routedName = proto.MessageName((*protobuf.Routed)(nil))
+
+// TowardOptions select the peer a message routed toward a key is sent to.
+type TowardOptions struct {
+	// Exclude skips peers for which it returns true.
+	Exclude func(peer PeerInfo) bool
+	// StrictlyCloser only selects peers closer to the key than this node is,
+	// so that messages forwarded greedily never loop.
+	StrictlyCloser bool
+	// MaxHops bounds how many peers a message is forwarded through, counting
+	// the one it is first sent to (default: 16). It is ignored when
+	// forwarding, as the bound its sender set travels with the message.
+	MaxHops int
+	// Hints are routing hints the message is sent with, which every node on
+	// the way may read without decoding the message, and which are covered
+	// by the signature of every node sending it on. They are ignored when
+	// forwarding, as the hints its sender set travel with the message.
+	Hints map[string][]byte
+	// NextHop returns the address of the connected peer to send a message to
+	// given its hints, or an empty address to send it to the peer closest to
+	// its key. Peers chosen must not be excluded.
+	NextHop func(hints map[string][]byte) string
+}
+
+// xorDistance returns the XOR distance between a key and the hash of a peer ID.
+func xorDistance(key, hash []byte) []byte {
+	distance := make([]byte, len(key))
+	for i := 0; i < len(key) && i < len(hash); i++ {
+		distance[i] = key[i] ^ hash[i]
+	}
+	return distance
+}
+
+// closestPeerToward returns the connected peer understanding routed messages
+// with the smallest XOR distance between its ID's hash and a key.
+func (n *Network) closestPeerToward(key []byte, opts TowardOptions) (*PeerClient, error) {
+	var (
+		closest  *PeerClient
+		distance []byte
+	)
+
+	if opts.StrictlyCloser {
+		distance = xorDistance(key, n.ID.Id)
+	}
+
+	n.eachPeer(func(client *PeerClient) bool {
+		if client.isClosed() || !client.HasCapability(RoutingCapability) {
+			return true
+		}
+
+		id := client.PeerID()
+		if id.IsZero() {
+			return true
+		}
+
+		if opts.Exclude != nil && opts.Exclude(client.info()) {
+			return true
+		}
+
+		d := xorDistance(key, id.Hash())
+		if distance == nil || bytes.Compare(d, distance) < 0 {
+			closest, distance = client, d
+		}
+		return true
+	})
+
+	if closest == nil {
+		return nil, ErrNoCloserPeer
+	}
+	return closest, nil
+}
+
+// WriteToward sends a message to the connected peer with the smallest XOR
+// distance between its ID's hash and a key the size of a peer ID's hash,
+// without looking up the peers closest to the key across the network first.
+// It returns the peer the message was sent to, or ErrNoCloserPeer should no
+// peer be eligible. Handlers of the peer may forward the message on toward
+// the key through PluginContext.Forward.
+func (n *Network) WriteToward(ctx context.Context, key []byte, message proto.Message, opts TowardOptions) (PeerInfo, error) {
+	maxHops := opts.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+
+	payload, err := types.MarshalAny(message)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+
+	return n.route(ctx, "", &protobuf.Routed{Key: key, Hops: 1, MaxHops: uint32(maxHops), Message: payload}, toHints(opts.Hints), opts)
+}
+
+// route sends a routed message under a protocol tag to the peer its hints
+// point to, or otherwise to the peer closest to its key.
+func (n *Network) route(ctx context.Context, protocol string, routed *protobuf.Routed, hints []*protobuf.Hint, opts TowardOptions) (PeerInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return PeerInfo{}, err
+	}
+
+	client, err := n.nextHop(routed.Key, hints, opts)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+
+	if err := client.tellEnvelope(protocol, routed, envelopeFields{hints: hints}); err != nil {
+		return PeerInfo{}, err
+	}
+	return client.info(), nil
+}
+
+// nextHop returns the peer a routed message is sent to next.
+func (n *Network) nextHop(key []byte, hints []*protobuf.Hint, opts TowardOptions) (*PeerClient, error) {
+	if opts.NextHop == nil {
+		return n.closestPeerToward(key, opts)
+	}
+
+	address := opts.NextHop(hintsMap(hints))
+	if address == "" {
+		return n.closestPeerToward(key, opts)
+	}
+
+	client, exists := n.peers.Load(address)
+	if !exists || client.(*PeerClient).isClosed() {
+		return nil, errors.Wrapf(ErrNotConnected, "failed to route message to %s", address)
+	}
+	if opts.Exclude != nil && opts.Exclude(client.(*PeerClient).info()) {
+		return nil, errors.Errorf("network: next hop %s is excluded", address)
+	}
+	return client.(*PeerClient), nil
+}
+
+// unwrapRouted returns the routed message a payload holds, alongside the name
+// of the message it carries.
+func unwrapRouted(payload *types.Any) (*protobuf.Routed, string, error) {
+	var routed protobuf.Routed
+	if err := types.UnmarshalAny(payload, &routed); err != nil {
+		return nil, "", err
+	}
+
+	if routed.Message == nil {
+		return nil, "", errors.New("network: routed message carries no message")
+	}
+
+	name, err := payloadName(routed.Message)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch name {
+	case routedName, batchName, bytesName:
+		return nil, "", errors.Errorf("network: %s may not be routed", name)
+	}
+
+	return &routed, name, nil
+}
+
+// RoutedKey returns the key the message was routed toward, or nil should it
+// have been sent to us directly.
+func (ctx *PluginContext) RoutedKey() []byte {
+	ctx.own.check()
+	if ctx.routed == nil {
+		return nil
+	}
+	return ctx.routed.Key
+}
+
+// Hops returns how many peers a routed message went through, counting us, or
+// 0 should it have been sent to us directly.
+func (ctx *PluginContext) Hops() int {
+	ctx.own.check()
+	if ctx.routed == nil {
+		return 0
+	}
+	return int(ctx.routed.Hops)
+}
+
+// Forward sends a routed message on to the connected peer closest to its key
+// other than the peer it came from, or to the peer opts.NextHop picks given
+// its hints, under the protocol tag and with the hints it was sent with.
+// It returns the peer the message was forwarded to, ErrHopLimit should the
+// message have gone through as many peers as its sender allowed, or
+// ErrNoCloserPeer should no peer be eligible, such as when none is closer to
+// the key than we are under opts.StrictlyCloser.
+func (ctx *PluginContext) Forward(opts TowardOptions) (PeerInfo, error) {
+	ctx.own.check()
+	if ctx.routed == nil {
+		return PeerInfo{}, ErrNotRouted
+	}
+
+	if ctx.routed.Hops >= ctx.routed.MaxHops {
+		return PeerInfo{}, ErrHopLimit
+	}
+
+	from := ctx.client
+	exclude := opts.Exclude
+	opts.Exclude = func(peer PeerInfo) bool {
+		if peer.Address == from.Address {
+			return true
+		}
+		return exclude != nil && exclude(peer)
+	}
+
+	routed := &protobuf.Routed{
+		Key:     ctx.routed.Key,
+		Hops:    ctx.routed.Hops + 1,
+		MaxHops: ctx.routed.MaxHops,
+		Message: ctx.payload,
+	}
+
+	var hints []*protobuf.Hint
+	if ctx.frame != nil {
+		hints = ctx.frame.Message.Hints
+	}
+
+	return ctx.client.Network.route(ctx.Context(), ctx.protocol, routed, hints, opts)
+}
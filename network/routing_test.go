@@ -0,0 +1,167 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"math/bits"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+type arrival struct {
+	node *Network
+	hops int
+	err  error
+}
+
+// commonPrefixLen returns the number of leading bits two hashes share.
+func commonPrefixLen(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
+
+// buildRing builds nodes arranged on a ring by ID, every node connected both
+// ways to its successor and, for every other node's common prefix length with
+// it, to the closest node sharing that prefix length. Routed messages are forwarded
+// greedily until no peer is closer to their key.
+func buildRing(t *testing.T, count int, arrivals chan arrival) []*Network {
+	nodes := make([]*Network, count)
+	for i := range nodes {
+		builder := NewBuilder()
+		builder.SetKeys(ed25519.RandomKeyPair())
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+			if ctx.RoutedKey() == nil {
+				return
+			}
+			if _, err := ctx.Forward(TowardOptions{StrictlyCloser: true}); err != ErrNoCloserPeer {
+				if err != nil {
+					arrivals <- arrival{node: ctx.Network(), hops: ctx.Hops(), err: err}
+				}
+				return
+			}
+			arrivals <- arrival{node: ctx.Network(), hops: ctx.Hops()}
+		}})
+
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		go node.Listen()
+		<-node.Ready()
+
+		nodes[i] = node
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].ID.Id, nodes[j].ID.Id) < 0
+	})
+
+	for i, node := range nodes {
+		contacts := map[int]*Network{-1: nodes[(i+1)%count]}
+		for _, other := range nodes {
+			if other == node {
+				continue
+			}
+
+			bucket := commonPrefixLen(node.ID.Id, other.ID.Id)
+			if closest, ok := contacts[bucket]; !ok || bytes.Compare(xorDistance(node.ID.Id, other.ID.Id), xorDistance(node.ID.Id, closest.ID.Id)) < 0 {
+				contacts[bucket] = other
+			}
+		}
+
+		for _, contact := range contacts {
+			_, err := node.Client(contact.Address)
+			assert.Nil(t, err)
+			_, err = contact.Client(node.Address)
+			assert.Nil(t, err)
+		}
+	}
+
+	return nodes
+}
+
+func TestWriteTowardReachesClosestNode(t *testing.T) {
+	t.Parallel()
+
+	const count = 12
+
+	arrivals := make(chan arrival, count)
+	nodes := buildRing(t, count, arrivals)
+	for _, node := range nodes {
+		defer node.Close()
+	}
+
+	for _, target := range []int{0, count / 2, count - 1} {
+		key := nodes[target].ID.Id
+		origin := nodes[(target+count/2+1)%count]
+
+		// Every hop moves to a node sharing a longer prefix with the key.
+		levels := make(map[int]struct{})
+		for _, node := range nodes {
+			if node != nodes[target] {
+				levels[commonPrefixLen(node.ID.Id, key)] = struct{}{}
+			}
+		}
+
+		chosen, err := origin.WriteToward(context.Background(), key, &testpb.TestMessage{Message: "toward"}, TowardOptions{StrictlyCloser: true})
+		assert.Nil(t, err)
+		assert.True(t, chosen.Connected)
+
+		select {
+		case arrival := <-arrivals:
+			assert.Nil(t, arrival.err)
+			assert.Equal(t, nodes[target].Address, arrival.node.Address)
+			assert.True(t, arrival.hops >= 1 && arrival.hops <= len(levels)+1, "message took %d hops", arrival.hops)
+		case <-time.After(5 * time.Second):
+			t.Fatal("routed message never arrived")
+		}
+	}
+}
+
+func TestWriteTowardOptions(t *testing.T) {
+	t.Parallel()
+
+	arrivals := make(chan arrival, 3)
+	nodes := buildRing(t, 3, arrivals)
+	for _, node := range nodes {
+		defer node.Close()
+	}
+
+	origin, target := nodes[0], nodes[1]
+
+	// No peer is closer to our own ID than we are.
+	_, err := origin.WriteToward(context.Background(), origin.ID.Id, &testpb.TestMessage{}, TowardOptions{StrictlyCloser: true})
+	assert.Equal(t, ErrNoCloserPeer, err)
+
+	// Excluded peers are never chosen, and messages are not forwarded past
+	// their hop limit.
+	chosen, err := origin.WriteToward(context.Background(), target.ID.Id, &testpb.TestMessage{}, TowardOptions{
+		Exclude: func(peer PeerInfo) bool { return peer.Address == target.Address },
+		MaxHops: 1,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, nodes[2].Address, chosen.Address)
+
+	select {
+	case arrival := <-arrivals:
+		assert.Equal(t, nodes[2].Address, arrival.node.Address)
+		assert.Equal(t, 1, arrival.hops)
+		assert.Equal(t, ErrHopLimit, arrival.err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("routed message never arrived")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = origin.WriteToward(ctx, target.ID.Id, &testpb.TestMessage{}, TowardOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
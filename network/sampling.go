@@ -0,0 +1,404 @@
+package network
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network/rpc"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+)
+
+// PeerSamplingCapability is advertised by nodes which maintain a view of the
+// network through PeerSampling, and shuffle it with their peers.
+const PeerSamplingCapability = "noise/sampling"
+
+const (
+	// defaultPeerSamplingInterval is how often views are shuffled once peer
+	// sampling is enabled.
+	defaultPeerSamplingInterval = 10 * time.Second
+	defaultPeerSamplingHealing  = 1
+	defaultPeerSamplingSwap     = 1
+
+	// maxPeerSamplingView bounds the size of views, and so of the samples
+	// exchanged by shuffles, which carry up to half a view.
+	maxPeerSamplingView = 128
+	maxShuffleSamples   = maxPeerSamplingView / 2
+)
+
+// ErrInvalidShuffle is reported for shuffles carrying too many samples, or
+// samples which are malformed.
+var ErrInvalidShuffle = errors.New("network: invalid shuffle")
+
+var shuffleRequestName = proto.MessageName((*protobuf.ShuffleRequest)(nil))
+
+// sample is a node held in a view, aged by one every shuffle.
+type sample struct {
+	id  peer.ID
+	age uint32
+}
+
+// peerSampler holds a partial view of the network, refreshed by shuffling
+// half of it with a random peer every so often as in gossip-based peer
+// sampling, so that the nodes held are close to a uniform sample of the
+// whole network rather than of our peers.
+type peerSampler struct {
+	sync.Mutex
+
+	view []sample
+	rand *rand.Rand
+}
+
+func newPeerSampler() *peerSampler {
+	return &peerSampler{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// seed adds a newly connected peer to the view, should it not be full yet.
+func (s *peerSampler) seed(id peer.ID, size int) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.view) >= size || s.index(id.PublicKey) >= 0 {
+		return
+	}
+	s.view = append(s.view, sample{id: id})
+}
+
+func (s *peerSampler) index(publicKey []byte) int {
+	for i, entry := range s.view {
+		if bytes.Equal(entry.id.PublicKey, publicKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pick returns up to count samples off the head of the view, after shuffling
+// it and moving its healing oldest samples to its tail so that they are not
+// passed on.
+func (s *peerSampler) pick(count int, healing int) []sample {
+	s.rand.Shuffle(len(s.view), func(i, j int) {
+		s.view[i], s.view[j] = s.view[j], s.view[i]
+	})
+
+	oldest := s.oldest(healing)
+	kept := make([]sample, 0, len(s.view))
+	for i, entry := range s.view {
+		if _, old := oldest[i]; !old {
+			kept = append(kept, entry)
+		}
+	}
+	for i, entry := range s.view {
+		if _, old := oldest[i]; old {
+			kept = append(kept, entry)
+		}
+	}
+	s.view = kept
+
+	if count > len(s.view) {
+		count = len(s.view)
+	}
+	return append([]sample(nil), s.view[:count]...)
+}
+
+// oldest returns the indices of the count oldest samples of the view.
+func (s *peerSampler) oldest(count int) map[int]struct{} {
+	indices := make([]int, len(s.view))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return s.view[indices[i]].age > s.view[indices[j]].age
+	})
+
+	if count > len(indices) {
+		count = len(indices)
+	}
+
+	oldest := make(map[int]struct{}, count)
+	for _, i := range indices[:count] {
+		oldest[i] = struct{}{}
+	}
+	return oldest
+}
+
+// merge appends samples received from a peer to the view, keeping the
+// youngest of duplicates, and trims it back to size: first dropping up to
+// healing of its oldest samples, then up to swap of the samples at its head,
+// those sent to the peer, and then random samples.
+func (s *peerSampler) merge(received []sample, self []byte, size, healing, swap int) {
+	for _, entry := range received {
+		if bytes.Equal(entry.id.PublicKey, self) {
+			continue
+		}
+		if i := s.index(entry.id.PublicKey); i >= 0 {
+			if entry.age < s.view[i].age {
+				s.view[i] = entry
+			}
+			continue
+		}
+		s.view = append(s.view, entry)
+	}
+
+	excess := func(limit int) int {
+		if over := len(s.view) - size; over < limit {
+			return over
+		}
+		return limit
+	}
+
+	if count := excess(healing); count > 0 {
+		oldest := s.oldest(count)
+		kept := s.view[:0]
+		for i, entry := range s.view {
+			if _, old := oldest[i]; !old {
+				kept = append(kept, entry)
+			}
+		}
+		s.view = kept
+	}
+
+	if count := excess(swap); count > 0 {
+		s.view = s.view[count:]
+	}
+
+	for len(s.view) > size {
+		i := s.rand.Intn(len(s.view))
+		s.view = append(s.view[:i], s.view[i+1:]...)
+	}
+}
+
+// takeOldest removes the oldest sample from the view, and returns it.
+func (s *peerSampler) takeOldest() (sample, bool) {
+	if len(s.view) == 0 {
+		return sample{}, false
+	}
+
+	oldest := 0
+	for i, entry := range s.view {
+		if entry.age > s.view[oldest].age {
+			oldest = i
+		}
+	}
+
+	entry := s.view[oldest]
+	s.view = append(s.view[:oldest], s.view[oldest+1:]...)
+	return entry, true
+}
+
+// age ages every sample of the view by one shuffle.
+func (s *peerSampler) age() {
+	for i := range s.view {
+		s.view[i].age++
+	}
+}
+
+// SamplePeers returns up to count distinct nodes picked at random from the
+// view maintained by PeerSampling, which approximates a uniform sample of
+// the whole network rather than of our peers. It returns none unless peer
+// sampling is enabled.
+func (n *Network) SamplePeers(count int) []peer.ID {
+	s := n.sampler
+	s.Lock()
+	defer s.Unlock()
+
+	if count > len(s.view) {
+		count = len(s.view)
+	}
+
+	ids := make([]peer.ID, 0, count)
+	for _, i := range s.rand.Perm(len(s.view))[:count] {
+		ids = append(ids, s.view[i].id)
+	}
+	return ids
+}
+
+// seedSampler adds a newly connected peer to the view maintained by
+// PeerSampling, should it take part in peer sampling.
+func (n *Network) seedSampler(client *PeerClient) {
+	if n.opts.peerSamplingView <= 0 || len(client.publicKey) == 0 || !client.HasCapability(PeerSamplingCapability) {
+		return
+	}
+	n.sampler.seed(peer.CreateID(client.Address, client.publicKey), n.opts.peerSamplingView)
+}
+
+func (n *Network) samplingLoop() {
+	t := time.NewTicker(n.opts.peerSamplingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-n.kill:
+			return
+		case <-t.C:
+			n.shuffle()
+		}
+	}
+}
+
+// shuffle exchanges half of our view, ourselves included, with the node held
+// the longest in our view, or with a random peer taking part in peer sampling
+// while our view is empty. The node is dropped from our view, making room for
+// the samples it answers with, so that nodes which left are eventually
+// forgotten. Nodes we were not connected to are dialed for the shuffle alone.
+func (n *Network) shuffle() {
+	s := n.sampler
+	s.Lock()
+
+	s.age()
+	target, exists := s.takeOldest()
+	offered := append([]sample{{id: n.ID}}, s.pick(n.opts.peerSamplingView/2-1, n.opts.peerSamplingHealing)...)
+
+	s.Unlock()
+
+	partner, dialed := n.shufflePartner(target, exists)
+	if partner == nil {
+		return
+	}
+	if dialed {
+		defer partner.Close()
+	}
+
+	res, err := partner.Request(&rpc.Request{
+		Message: &protobuf.ShuffleRequest{Samples: encodeSamples(offered)},
+		Timeout: n.opts.peerSamplingInterval,
+	})
+	if err != nil {
+		glog.Warningf("network: shuffle with %s failed: %v", partner.Address, err)
+		return
+	}
+
+	response, ok := res.(*protobuf.ShuffleResponse)
+	if !ok {
+		return
+	}
+
+	received, err := decodeSamples(response.Samples)
+	if err != nil {
+		n.reportViolation(partner, err)
+		return
+	}
+
+	s.Lock()
+	s.merge(received, n.keys.PublicKey, n.opts.peerSamplingView, n.opts.peerSamplingHealing, n.opts.peerSamplingSwap)
+	s.Unlock()
+}
+
+// shufflePartner returns the client of the node to shuffle our view with, or
+// nil should there be none, and whether it was dialed to shuffle with.
+func (n *Network) shufflePartner(target sample, exists bool) (*PeerClient, bool) {
+	if !exists {
+		var partners []*PeerClient
+		n.eachPeer(func(client *PeerClient) bool {
+			// Clients still being dialed have yet to learn what the peer
+			// is capable of.
+			if client.outgoingReadyNow() && client.HasCapability(PeerSamplingCapability) {
+				partners = append(partners, client)
+			}
+			return true
+		})
+		if len(partners) == 0 {
+			return nil, false
+		}
+		return partners[rand.Intn(len(partners))], false
+	}
+
+	_, connected := n.peers.Load(target.id.Address)
+
+	client, err := n.Client(target.id.Address)
+	if err != nil {
+		glog.Warningf("network: failed to dial %s to shuffle with: %v", target.id.Address, err)
+		return nil, false
+	}
+	dialed := !connected
+
+	// Samples are not signed, so the node at the address may not be the
+	// one sampled.
+	if !bytes.Equal(client.publicKey, target.id.PublicKey) || !client.HasCapability(PeerSamplingCapability) {
+		if dialed {
+			client.Close()
+		}
+		return nil, false
+	}
+	return client, dialed
+}
+
+// handleShuffle answers a shuffle with half of our view, replacing the samples
+// sent with those offered, and returns true if a message was a shuffle. Peers
+// shuffling with us more than twice an interval are answered with no
+// samples, and shuffles carrying too many samples or malformed ones are
+// reported as violations.
+func (n *Network) handleShuffle(client *PeerClient, name string, message *protobuf.Message) bool {
+	if name != shuffleRequestName || message.RequestNonce == 0 || message.ReplyFlag {
+		return false
+	}
+
+	var request protobuf.ShuffleRequest
+	if err := types.UnmarshalAny(message.Message, &request); err != nil {
+		n.reportViolation(client, errors.Wrap(ErrInvalidShuffle, err.Error()))
+		return true
+	}
+
+	received, err := decodeSamples(request.Samples)
+	if err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	response := &protobuf.ShuffleResponse{}
+
+	now := n.now().UnixNano()
+	last := atomic.SwapInt64(&client.shuffledAt, now)
+
+	if n.opts.peerSamplingView > 0 && now-last >= int64(n.opts.peerSamplingInterval/2) {
+		s := n.sampler
+		s.Lock()
+		answered := s.pick(n.opts.peerSamplingView/2, 0)
+		s.merge(received, n.keys.PublicKey, n.opts.peerSamplingView, 0, len(answered))
+		s.Unlock()
+
+		response.Samples = encodeSamples(answered)
+	}
+
+	if err := client.Reply(message.RequestNonce, response); err != nil {
+		glog.Warningf("failed to answer shuffle from %s: %v", client.Address, err)
+	}
+	return true
+}
+
+func encodeSamples(samples []sample) []*protobuf.PeerSample {
+	encoded := make([]*protobuf.PeerSample, 0, len(samples))
+	for _, entry := range samples {
+		id := protobuf.ID(entry.id)
+		encoded = append(encoded, &protobuf.PeerSample{Id: &id, Age: entry.age})
+	}
+	return encoded
+}
+
+// decodeSamples decodes the samples of a shuffle, failing should there be too
+// many of them, or should any not hold a public key and a valid address.
+func decodeSamples(encoded []*protobuf.PeerSample) ([]sample, error) {
+	if len(encoded) > maxShuffleSamples {
+		return nil, errors.Wrapf(ErrInvalidShuffle, "%d samples", len(encoded))
+	}
+
+	samples := make([]sample, 0, len(encoded))
+	for _, entry := range encoded {
+		if entry.Id == nil || len(entry.Id.PublicKey) == 0 {
+			return nil, errors.Wrap(ErrInvalidShuffle, "sample missing a public key")
+		}
+		if _, err := ParseAddress(entry.Id.Address); err != nil {
+			return nil, errors.Wrap(ErrInvalidShuffle, err.Error())
+		}
+		samples = append(samples, sample{id: peer.CreateID(entry.Id.Address, entry.Id.PublicKey), age: entry.Age})
+	}
+	return samples, nil
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// spread returns the coefficient of variation of how many times every node
+// was counted, which is 0 should every node be counted as many times.
+func spread(counts map[string]int, nodes []*Network) float64 {
+	total := 0
+	for _, node := range nodes {
+		total += counts[node.Address]
+	}
+	mean := float64(total) / float64(len(nodes))
+
+	variance := 0.0
+	for _, node := range nodes {
+		variance += math.Pow(float64(counts[node.Address])-mean, 2)
+	}
+	return math.Sqrt(variance/float64(len(nodes))) / mean
+}
+
+// TestPeerSamplingIsUniform has 24 nodes connect to a few hubs and to one
+// another in a chain, so that picking peers among neighbors favors the hubs,
+// and checks that nodes are sampled much more evenly than neighbors are.
+func TestPeerSamplingIsUniform(t *testing.T) {
+	t.Parallel()
+
+	const hubs = 3
+
+	mem := newMemTransport()
+
+	nodes := make([]*Network, 24)
+	for i := range nodes {
+		nodes[i] = buildNATNode(t, mem, 1000+i, nil, PeerSampling(8, 50*time.Millisecond, 1, 3))
+		defer nodes[i].Close()
+	}
+
+	// Nodes are picked among neighbors as often as they have neighbors.
+	neighbors := make(map[string]int)
+	for i := hubs; i < len(nodes); i++ {
+		for _, peer := range []*Network{nodes[i%hubs], nodes[(i+1)%hubs], nodes[i-1]} {
+			_, err := nodes[i].Client(peer.Address)
+			assert.Nil(t, err)
+
+			neighbors[nodes[i].Address]++
+			neighbors[peer.Address]++
+		}
+	}
+
+	assert.True(t, waitUntil(5*time.Second, func() bool {
+		for _, node := range nodes {
+			if len(node.SamplePeers(8)) < 4 {
+				return false
+			}
+		}
+		return true
+	}))
+
+	// Let views mix for a score of shuffles, then sample them as they keep
+	// mixing.
+	time.Sleep(time.Second)
+
+	samples := make(map[string]int)
+	for round := 0; round < 20; round++ {
+		for _, node := range nodes {
+			for _, id := range node.SamplePeers(8) {
+				assert.NotEqual(t, node.Address, id.Address)
+				samples[id.Address]++
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Logf("spread of neighbors: %.2f, spread of samples: %.2f", spread(neighbors, nodes), spread(samples, nodes))
+	assert.True(t, spread(samples, nodes) < spread(neighbors, nodes)/2)
+}
+
+func TestShufflesAreBounded(t *testing.T) {
+	t.Parallel()
+
+	id := protobuf.ID(peer.CreateID("tcp://localhost:3000", []byte("key")))
+
+	_, err := decodeSamples([]*protobuf.PeerSample{{Id: &id}})
+	assert.Nil(t, err)
+
+	oversized := make([]*protobuf.PeerSample, maxShuffleSamples+1)
+	for i := range oversized {
+		oversized[i] = &protobuf.PeerSample{Id: &id}
+	}
+	_, err = decodeSamples(oversized)
+	assert.Equal(t, ErrInvalidShuffle, errors.Cause(err))
+
+	_, err = decodeSamples([]*protobuf.PeerSample{{Id: &protobuf.ID{Address: "tcp://localhost:3000"}}})
+	assert.Equal(t, ErrInvalidShuffle, errors.Cause(err))
+
+	_, err = decodeSamples([]*protobuf.PeerSample{{Id: &protobuf.ID{PublicKey: []byte("key"), Address: "localhost"}}})
+	assert.Equal(t, ErrInvalidShuffle, errors.Cause(err))
+}
@@ -0,0 +1,151 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// FrameInfo holds the fields of a received frame which are cheap to look at,
+// available before its payload is decoded or its signature verified. None of
+// them are authenticated yet.
+type FrameInfo struct {
+	// RemoteAddr is the address of the connection the frame was read off.
+	RemoteAddr net.Addr
+	// Sender is the peer the frame claims to be signed by.
+	Sender PeerID
+	// SenderAddress is the address the sender claims to listen at.
+	SenderAddress string
+	// Protocol is the protocol tag the message was sent under.
+	Protocol string
+	// MessageName is the name of the type of the message carried.
+	MessageName string
+	// Size is the size of the frame in bytes.
+	Size int
+}
+
+// FrameFilter is invoked on every frame received which passed the network's
+// own screening, before its signature is verified. Returning an error drops
+// the frame. Filters run on the goroutine reading off the peer's connection,
+// and should return quickly.
+type FrameFilter func(frame FrameInfo) error
+
+// ValidationStats counts frames dropped by each of the two tiers received
+// frames are validated in.
+type ValidationStats struct {
+	// Screened is the number of frames dropped by screening, which looks at
+	// the frame's cheap fields alone and runs before signatures are
+	// verified: frames failing to unmarshal or missing headers, frames sent
+	// by peers other than the one handshaked with, frames carrying messages
+	// of unknown types, copies of messages delivered recently, and frames
+	// dropped by filters registered with AddFrameFilter.
+	Screened uint64
+	// Rejected is the number of frames dropped by verification: frames whose
+	// signature does not verify, and frames carrying unknown critical
+	// extensions or malformed hints.
+	Rejected uint64
+	// Replayed is the number of frames whose signature verified dropped for
+	// carrying a replay nonce seen before or too old to tell, or no replay
+	// nonce at all though one is required.
+	Replayed uint64
+}
+
+// screenedFrameError is the error frames dropped by screening, other than
+// malformed ones, fail with. Such frames are skipped silently.
+type screenedFrameError struct {
+	err error
+}
+
+func (e *screenedFrameError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns why the frame was dropped.
+func (e *screenedFrameError) Cause() error {
+	return e.err
+}
+
+func isScreenedFrame(err error) bool {
+	_, ok := err.(*screenedFrameError)
+	return ok
+}
+
+// ValidationStats returns how many frames received were dropped by each tier
+// of validation.
+func (n *Network) ValidationStats() ValidationStats {
+	return ValidationStats{
+		Screened: atomic.LoadUint64(&n.screenedFrames),
+		Rejected: atomic.LoadUint64(&n.rejectedFrames),
+		Replayed: atomic.LoadUint64(&n.replayedFrames),
+	}
+}
+
+// validateFrame decodes a frame read off a connection handshaked with the
+// peer holding a public key, screening it before verifying its signature. The
+// message is returned alongside any error should the frame have unmarshaled.
+func (n *Network) validateFrame(source net.Addr, remote []byte, buffer []byte) (*protobuf.Message, error) {
+	msg, err := decodeEnvelope(buffer)
+	if err != nil {
+		atomic.AddUint64(&n.screenedFrames, 1)
+		return nil, &malformedFrameError{err: err}
+	}
+
+	if err := n.screenFrame(source, remote, msg, len(buffer)); err != nil {
+		atomic.AddUint64(&n.screenedFrames, 1)
+		return msg, err
+	}
+
+	if !n.verifyMessage(msg) {
+		atomic.AddUint64(&n.rejectedFrames, 1)
+		return msg, &malformedFrameError{err: errors.New("received message had an malformed signature")}
+	}
+
+	return msg, nil
+}
+
+// screenFrame checks the cheap fields of a frame, running the filters
+// registered with AddFrameFilter last.
+func (n *Network) screenFrame(source net.Addr, remote []byte, msg *protobuf.Message, size int) error {
+	sender, err := PeerIDFromPublicKey(msg.Sender.PublicKey)
+	if err != nil {
+		return &malformedFrameError{err: err}
+	}
+
+	if remote != nil && !bytes.Equal(msg.Sender.PublicKey, remote) {
+		return &malformedFrameError{err: errors.New("network: message sender does not match handshake")}
+	}
+
+	name, err := payloadName(msg.Message)
+	if err != nil {
+		return &screenedFrameError{err: err}
+	}
+
+	if set, exists := n.dedupes[ProtocolMessageName(msg.Protocol, name)]; exists && set.contains(string(n.opts.hashPolicy.HashBytes(msg.Message.Value)), n.now()) {
+		atomic.AddUint64(&n.duplicates, 1)
+		return &screenedFrameError{err: errors.Errorf("network: dropped copy of a %s delivered recently", name)}
+	}
+
+	if len(n.frameFilters) == 0 {
+		return nil
+	}
+
+	info := FrameInfo{
+		RemoteAddr:    source,
+		Sender:        sender,
+		SenderAddress: msg.Sender.Address,
+		Protocol:      msg.Protocol,
+		MessageName:   name,
+		Size:          size,
+	}
+
+	for _, filter := range n.frameFilters {
+		if err := filter(info); err != nil {
+			return &screenedFrameError{err: errors.Wrapf(err, "network: filtered %s", name)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// unknownFrame returns a signed frame carrying a message of a type no node
+// knows of.
+func unknownFrame(t testing.TB, sender *Network, text string) []byte {
+	msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: text})
+	assert.Nil(t, err)
+	msg.Message.TypeUrl = "type.googleapis.com/unknown.Message"
+
+	frame, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+	return frame
+}
+
+func TestScreeningDropsFramesBeforeVerification(t *testing.T) {
+	t.Parallel()
+
+	blocked := ed25519.RandomKeyPair()
+	received := make(chan string, 4)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	}})
+	builder.AddFrameFilter(func(frame FrameInfo) error {
+		if id, _ := PeerIDFromPublicKey(blocked.PublicKey); frame.Sender == id {
+			return errors.New("blocked")
+		}
+		return nil
+	})
+
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "first"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "first", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("first message was never delivered")
+	}
+
+	// A flood of messages of unknown types is screened out, without the
+	// connection being closed as it would be over malformed frames.
+	state, ok := sender.ConnectionState(receiver.Address)
+	assert.True(t, ok)
+
+	frame := unknownFrame(t, sender, "unknown")
+	for i := 0; i < 2*maxMalformedFrames; i++ {
+		assert.Nil(t, sender.writeFrame(state.writer, frame, state.writerMutex))
+	}
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "second"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "second", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message following screened frames was never delivered")
+	}
+
+	assert.Equal(t, ValidationStats{Screened: 2 * maxMalformedFrames}, receiver.ValidationStats())
+	assert.Equal(t, "", client.DisconnectReason())
+
+	// Frames the application filters out never reach plugins.
+	builder = NewBuilder()
+	builder.SetKeys(blocked)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	intruder, err := builder.Build()
+	assert.Nil(t, err)
+	defer intruder.Close()
+
+	go intruder.Listen()
+	<-intruder.Ready()
+
+	blockedClient, err := intruder.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, blockedClient.Tell(&testpb.TestMessage{Message: "blocked"}))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return receiver.ValidationStats().Screened == 2*maxMalformedFrames+1
+	}))
+
+	select {
+	case msg := <-received:
+		t.Fatalf("filtered message %q was delivered", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// benchmarkValidateFrame validates distinct frames one after the other, as a
+// peer flooding us with them would have us, so that no verification is
+// cached.
+func benchmarkValidateFrame(b *testing.B, frame func(sender *Network, i int) []byte) {
+	sender := buildListeningNode(b)
+	defer sender.Close()
+
+	receiver := buildListeningNode(b)
+	defer receiver.Close()
+
+	frames := make([][]byte, b.N)
+	for i := range frames {
+		frames[i] = frame(sender, i)
+	}
+	source := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		receiver.validateFrame(source, sender.keys.PublicKey, frames[i])
+	}
+}
+
+func BenchmarkValidateUnknownMessage(b *testing.B) {
+	benchmarkValidateFrame(b, func(sender *Network, i int) []byte {
+		return unknownFrame(b, sender, strconv.Itoa(i))
+	})
+}
+
+func BenchmarkValidateForgedMessage(b *testing.B) {
+	benchmarkValidateFrame(b, func(sender *Network, i int) []byte {
+		msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: strconv.Itoa(i)})
+		assert.Nil(b, err)
+
+		// The payload is tampered with once signed.
+		msg.Message.Value[len(msg.Message.Value)-1] ^= 1
+
+		frame, err := proto.Marshal(msg)
+		assert.Nil(b, err)
+		return frame
+	})
+}
@@ -0,0 +1,330 @@
+package network
+
+import (
+	"net"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// ServiceRecordsCapability is advertised by nodes which accept refreshes of
+// the service records of their peers.
+const ServiceRecordsCapability = "noise/services"
+
+const (
+	// maxServiceRecords bounds how many services a node may advertise.
+	maxServiceRecords = 32
+	// maxServiceNameSize bounds the size of the name of a service.
+	maxServiceNameSize = 64
+	// maxServiceRecordsSize bounds the total size of the names, addresses and
+	// metadata of all services a node advertises.
+	maxServiceRecordsSize = 4 * 1024
+)
+
+var (
+	// ErrServiceRecordInvalid is returned should a service record be malformed.
+	ErrServiceRecordInvalid = errors.New("network: invalid service record")
+	// ErrServiceRecordsTooLarge is returned should the service records of a
+	// node exceed their size caps.
+	ErrServiceRecordsTooLarge = errors.New("network: service records too large")
+)
+
+var serviceRecordsName = proto.MessageName((*protobuf.ServiceRecords)(nil))
+
+// ServiceRecord is a named auxiliary service a node offers, such as an HTTP
+// API or a metrics port, reachable at a host:port address.
+type ServiceRecord struct {
+	Name     string
+	Address  string
+	Metadata map[string][]byte
+}
+
+// size returns how much of maxServiceRecordsSize a record takes up.
+func (r ServiceRecord) size() int {
+	size := len(r.Name) + len(r.Address)
+	for key, value := range r.Metadata {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// validate checks that a record is well formed.
+func (r ServiceRecord) validate() error {
+	if r.Name == "" || len(r.Name) > maxServiceNameSize {
+		return errors.Wrapf(ErrServiceRecordInvalid, "name %q must be between 1 and %d bytes", r.Name, maxServiceNameSize)
+	}
+	if _, _, err := net.SplitHostPort(r.Address); err != nil {
+		return errors.Wrapf(ErrServiceRecordInvalid, "address %q of service %q: %v", r.Address, r.Name, err)
+	}
+	return nil
+}
+
+// serviceRecords are the services a node advertises, replaced as a whole
+// whenever they change.
+type serviceRecords struct {
+	version uint64
+	records map[string]ServiceRecord
+}
+
+// encodeServiceRecords encodes service records sorted by name, so that their
+// signature covers the same bytes on either side.
+func encodeServiceRecords(records map[string]ServiceRecord, version uint64) (*protobuf.ServiceRecords, error) {
+	names := make([]string, 0, len(records))
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msg := &protobuf.ServiceRecords{Version: version}
+	for _, name := range names {
+		metadata, err := encodePeerMetadata(records[name].Metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Records = append(msg.Records, &protobuf.ServiceRecord{
+			Name:     name,
+			Address:  records[name].Address,
+			Metadata: metadata,
+		})
+	}
+	return msg, nil
+}
+
+// decodeServiceRecords decodes and validates the service records a peer
+// advertised, rejecting them all should any one be malformed.
+func decodeServiceRecords(msg *protobuf.ServiceRecords) (*serviceRecords, error) {
+	if len(msg.Records) > maxServiceRecords {
+		return nil, errors.Wrapf(ErrServiceRecordsTooLarge, "%d records", len(msg.Records))
+	}
+
+	records := make(map[string]ServiceRecord, len(msg.Records))
+	size := 0
+	for _, entry := range msg.Records {
+		metadata, err := decodePeerMetadata(entry.Metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		record := ServiceRecord{Name: entry.Name, Address: entry.Address, Metadata: metadata}
+		if err := record.validate(); err != nil {
+			return nil, err
+		}
+		if _, duplicate := records[record.Name]; duplicate {
+			return nil, errors.Wrapf(ErrServiceRecordInvalid, "service %q is advertised twice", record.Name)
+		}
+
+		records[record.Name] = record
+		size += record.size()
+	}
+
+	if size > maxServiceRecordsSize {
+		return nil, ErrServiceRecordsTooLarge
+	}
+
+	return &serviceRecords{version: msg.Version, records: records}, nil
+}
+
+// RegisterService advertises a named service to all peers, replacing any
+// service registered under the same name before. Connected peers are sent
+// the node's records afresh, and peers connecting afterwards receive them in
+// the handshake.
+func (n *Network) RegisterService(name string, address string, metadata map[string][]byte) error {
+	record := ServiceRecord{Name: name, Address: address}
+	if err := record.validate(); err != nil {
+		return err
+	}
+
+	if len(metadata) > 0 {
+		record.Metadata = make(map[string][]byte, len(metadata))
+		for key, value := range metadata {
+			record.Metadata[key] = append([]byte(nil), value...)
+		}
+	}
+
+	return n.updateServices(func(records map[string]ServiceRecord) error {
+		records[name] = record
+
+		size := 0
+		for _, record := range records {
+			size += record.size()
+		}
+		if len(records) > maxServiceRecords || size > maxServiceRecordsSize {
+			return ErrServiceRecordsTooLarge
+		}
+		return nil
+	})
+}
+
+// UnregisterService stops advertising a named service to all peers.
+func (n *Network) UnregisterService(name string) error {
+	return n.updateServices(func(records map[string]ServiceRecord) error {
+		delete(records, name)
+		return nil
+	})
+}
+
+// Services returns the services the node advertises, by name.
+func (n *Network) Services() map[string]ServiceRecord {
+	n.servicesMutex.Lock()
+	defer n.servicesMutex.Unlock()
+
+	return copyServiceRecords(n.services.records)
+}
+
+// updateServices applies a change to the services the node advertises, and
+// sends the resulting records to every connected peer accepting them.
+func (n *Network) updateServices(update func(records map[string]ServiceRecord) error) error {
+	n.servicesMutex.Lock()
+
+	records := copyServiceRecords(n.services.records)
+	if records == nil {
+		records = make(map[string]ServiceRecord)
+	}
+	if err := update(records); err != nil {
+		n.servicesMutex.Unlock()
+		return err
+	}
+
+	msg, err := encodeServiceRecords(records, n.services.version+1)
+	if err != nil {
+		n.servicesMutex.Unlock()
+		return err
+	}
+	n.services = serviceRecords{version: msg.Version, records: records}
+
+	n.servicesMutex.Unlock()
+
+	// Peers discard records older than the ones they hold, should concurrent
+	// updates reach them out of order.
+	n.peers.Range(func(_, value interface{}) bool {
+		client := value.(*PeerClient)
+		if client.HasCapability(ServiceRecordsCapability) {
+			if err := client.Tell(msg); err != nil {
+				glog.Warningf("failed to send service records to %s: %v", client.Address, err)
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+// localServices returns the service records to offer in handshakes, being nil
+// should no service ever have been registered.
+func (n *Network) localServices() (*protobuf.ServiceRecords, error) {
+	n.servicesMutex.Lock()
+	defer n.servicesMutex.Unlock()
+
+	if n.services.version == 0 {
+		return nil, nil
+	}
+	return encodeServiceRecords(n.services.records, n.services.version)
+}
+
+// adoptServices replaces the service records held for a peer with newer ones,
+// returning true should they have been replaced.
+func (c *PeerClient) adoptServices(msg *protobuf.ServiceRecords) (bool, error) {
+	records, err := decodeServiceRecords(msg)
+	if err != nil {
+		return false, err
+	}
+
+	c.servicesMutex.Lock()
+	defer c.servicesMutex.Unlock()
+
+	if c.services != nil && records.version <= c.services.version {
+		return false, nil
+	}
+	c.services = records
+
+	return true, nil
+}
+
+// Services returns the services the peer advertises, by name.
+func (c *PeerClient) Services() map[string]ServiceRecord {
+	c.servicesMutex.Lock()
+	defer c.servicesMutex.Unlock()
+
+	if c.services == nil {
+		return nil
+	}
+	return copyServiceRecords(c.services.records)
+}
+
+// PeerServices returns the services a connected peer advertises, by name.
+func (n *Network) PeerServices(id PeerID) map[string]ServiceRecord {
+	client, ok := n.PeerByID(id)
+	if !ok {
+		return nil
+	}
+	return client.Services()
+}
+
+// adoptOfferedServices holds the service records a peer offered during its
+// handshake. Malformed records are ignored rather than failing the handshake.
+func (n *Network) adoptOfferedServices(client *PeerClient, services *protobuf.ServiceRecords) {
+	if services == nil {
+		return
+	}
+
+	replaced, err := client.adoptServices(services)
+	if err != nil {
+		glog.Warningf("ignoring service records offered by %s: %v", client.Address, err)
+		return
+	}
+
+	if replaced && len(services.Records) > 0 {
+		n.notifyPeerServices(client)
+	}
+}
+
+// notifyPeerServices invokes the callback registered through OnPeerServices
+// with the records held for a peer.
+func (n *Network) notifyPeerServices(client *PeerClient) {
+	if n.opts.onPeerServices != nil {
+		n.opts.onPeerServices(client.info(), client.Services())
+	}
+}
+
+// handleServiceRecords replaces the service records held for a peer should a
+// message refresh them, returning true if a message was a refresh. Malformed
+// refreshes are rejected, leaving the records held before in place.
+func (n *Network) handleServiceRecords(client *PeerClient, name string, payload *types.Any) bool {
+	if name != serviceRecordsName {
+		return false
+	}
+
+	var msg protobuf.ServiceRecords
+	if err := types.UnmarshalAny(payload, &msg); err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	replaced, err := client.adoptServices(&msg)
+	if err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	if replaced {
+		n.notifyPeerServices(client)
+	}
+	return true
+}
+
+func copyServiceRecords(records map[string]ServiceRecord) map[string]ServiceRecord {
+	if records == nil {
+		return nil
+	}
+
+	copied := make(map[string]ServiceRecord, len(records))
+	for name, record := range records {
+		copied[name] = record
+	}
+	return copied
+}
@@ -0,0 +1,139 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceRegisteredAfterConnectPropagates(t *testing.T) {
+	t.Parallel()
+
+	refreshed := make(chan map[string]ServiceRecord, 4)
+	observer := buildListeningNode(t, OnPeerServices(func(peer PeerInfo, services map[string]ServiceRecord) {
+		refreshed <- services
+	}))
+	defer observer.Close()
+
+	provider := buildListeningNode(t)
+	defer provider.Close()
+
+	_, err := provider.Client(observer.Address)
+	assert.Nil(t, err)
+
+	providerID, err := PeerIDFromPublicKey(provider.ID.PublicKey)
+	assert.Nil(t, err)
+	assert.Nil(t, observer.PeerServices(providerID))
+
+	assert.Nil(t, provider.RegisterService("http", "127.0.0.1:8080", map[string][]byte{"path": []byte("/api")}))
+
+	expected := map[string]ServiceRecord{
+		"http": {Name: "http", Address: "127.0.0.1:8080", Metadata: map[string][]byte{"path": []byte("/api")}},
+	}
+
+	select {
+	case services := <-refreshed:
+		assert.Equal(t, expected, services)
+	case <-time.After(3 * time.Second):
+		t.Fatal("service registered after connecting was not propagated")
+	}
+	assert.Equal(t, expected, observer.PeerServices(providerID))
+
+	// Records are replaced as a whole rather than merged.
+	assert.Nil(t, provider.RegisterService("metrics", "127.0.0.1:9090", nil))
+	assert.Nil(t, provider.UnregisterService("http"))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		services := observer.PeerServices(providerID)
+		_, stale := services["http"]
+		return len(services) == 1 && !stale
+	}))
+
+	// Peers connecting afterwards receive the records during the handshake.
+	latecomer := buildListeningNode(t)
+	defer latecomer.Close()
+
+	_, err = latecomer.Client(provider.Address)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]ServiceRecord{
+		"metrics": {Name: "metrics", Address: "127.0.0.1:9090"},
+	}, latecomer.PeerServices(providerID))
+}
+
+func TestMalformedServiceRecordsAreRejected(t *testing.T) {
+	t.Parallel()
+
+	violations := make(chan error, 4)
+	received := make(chan string, 4)
+
+	builder := NewBuilderWithOptions(OnViolation(func(client *PeerClient, err error) {
+		violations <- err
+	}))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	}})
+	observer, err := builder.Build()
+	assert.Nil(t, err)
+	defer observer.Close()
+
+	go observer.Listen()
+	<-observer.Ready()
+
+	provider := buildListeningNode(t)
+	defer provider.Close()
+
+	assert.Nil(t, provider.RegisterService("http", "127.0.0.1:8080", nil))
+
+	client, err := provider.Client(observer.Address)
+	assert.Nil(t, err)
+
+	providerID, err := PeerIDFromPublicKey(provider.ID.PublicKey)
+	assert.Nil(t, err)
+
+	malformed := &protobuf.ServiceRecords{Version: 100, Records: []*protobuf.ServiceRecord{
+		{Name: "grpc", Address: "not an address"},
+	}}
+	assert.Nil(t, client.Tell(malformed))
+
+	select {
+	case err := <-violations:
+		assert.Equal(t, ErrServiceRecordInvalid, errors.Cause(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("malformed service records should have been rejected")
+	}
+
+	// The session carries on, with the records held before left in place.
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "still connected"}))
+	select {
+	case message := <-received:
+		assert.Equal(t, "still connected", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("session should have survived malformed service records")
+	}
+
+	assert.Equal(t, map[string]ServiceRecord{
+		"http": {Name: "http", Address: "127.0.0.1:8080"},
+	}, observer.PeerServices(providerID))
+}
+
+func TestRegisterServiceValidates(t *testing.T) {
+	t.Parallel()
+
+	node, err := NewBuilderWithOptions().Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	assert.Equal(t, ErrServiceRecordInvalid, errors.Cause(node.RegisterService("", "127.0.0.1:80", nil)))
+	assert.Equal(t, ErrServiceRecordInvalid, errors.Cause(node.RegisterService("http", "localhost", nil)))
+	assert.Equal(t, ErrServiceRecordsTooLarge, errors.Cause(node.RegisterService("http", "127.0.0.1:80", map[string][]byte{
+		"blob": make([]byte, maxServiceRecordsSize),
+	})))
+	assert.Len(t, node.Services(), 0)
+}
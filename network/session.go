@@ -0,0 +1,270 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+const sessionTokenSize = 32
+
+// session is a resumable session, either issued to a peer which dialed us or
+// held by us for a peer we dialed. Its token is only ever usable once, and
+// only by the peer whose public key it was issued to.
+type session struct {
+	token     []byte
+	publicKey []byte
+	version   string
+	offer     *protobuf.HandshakeOffer
+
+	// Sequence numbers as of the last disconnect. For sessions we hold,
+	// messageNonce is the last nonce we sent; for sessions we issued, it is
+	// the next nonce we expect.
+	messageNonce uint64
+	requestNonce uint64
+
+	// Values of the peer's PeerSession retained for it to be resumed with.
+	carried map[interface{}]sessionEntry
+
+	// Secret the keys of an encrypted connection resuming the session are
+	// chained onto.
+	resumption []byte
+
+	expiry time.Time
+	used   bool
+}
+
+// renew returns a copy of the session under a new token.
+func (s *session) renew(token []byte) *session {
+	renewed := *s
+	renewed.token = token
+	renewed.used = false
+	return &renewed
+}
+
+// sessionStore keeps track of resumable sessions.
+type sessionStore struct {
+	sync.Mutex
+
+	lifetime time.Duration
+
+	issued map[string]*session // token -> session
+	held   map[string]*session // address -> session
+}
+
+func newSessionStore(lifetime time.Duration) *sessionStore {
+	return &sessionStore{
+		lifetime: lifetime,
+		issued:   make(map[string]*session),
+		held:     make(map[string]*session),
+	}
+}
+
+// enabled returns true if sessions may be resumed at all.
+func (s *sessionStore) enabled() bool {
+	return s.lifetime > 0
+}
+
+// newToken generates a random session token.
+func (s *sessionStore) newToken() []byte {
+	token := make([]byte, sessionTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func (s *sessionStore) newSession(token, publicKey []byte, version string, offer *protobuf.HandshakeOffer) *session {
+	return &session{
+		token:     token,
+		publicKey: publicKey,
+		version:   version,
+		offer:     offer,
+	}
+}
+
+// issue remembers a session handed out to a peer which dialed us.
+func (s *sessionStore) issue(sess *session) {
+	if !s.enabled() {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	for token, issued := range s.issued {
+		if now.After(issued.expiry) {
+			delete(s.issued, token)
+		}
+	}
+
+	sess.expiry = now.Add(s.lifetime)
+	s.issued[string(sess.token)] = sess
+}
+
+// takeIssued redeems a token presented by a peer, provided it has not expired
+// and was issued to the same public key under the same offer.
+func (s *sessionStore) takeIssued(token, publicKey []byte, offer *protobuf.HandshakeOffer) *session {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, exists := s.issued[string(token)]
+	if !exists {
+		return nil
+	}
+
+	if !bytes.Equal(sess.publicKey, publicKey) {
+		// Leave the session to its rightful owner.
+		return nil
+	}
+
+	delete(s.issued, string(token))
+	sess.used = true
+
+	if time.Now().After(sess.expiry) || !sess.offer.Equal(offer) {
+		return nil
+	}
+
+	return sess
+}
+
+// retainIssued records how far a session issued to a peer got before the
+// peer disconnected, and restarts its lifetime.
+func (s *sessionStore) retainIssued(sess *session, messageNonce uint64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if sess.used {
+		return
+	}
+
+	sess.messageNonce = messageNonce
+	sess.expiry = time.Now().Add(s.lifetime)
+	s.issued[string(sess.token)] = sess
+}
+
+// hold remembers a session a peer we dialed handed out to us.
+func (s *sessionStore) hold(address string, sess *session) {
+	if !s.enabled() {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	sess.expiry = time.Now().Add(s.lifetime)
+	s.held[address] = sess
+}
+
+// takeHeld returns the session held for an address, so that its token may be
+// presented. A held session is only ever presented once.
+func (s *sessionStore) takeHeld(address string) *session {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, exists := s.held[address]
+	if !exists {
+		return nil
+	}
+
+	delete(s.held, address)
+	sess.used = true
+
+	if time.Now().After(sess.expiry) {
+		return nil
+	}
+
+	return sess
+}
+
+// retainHeld records how far a session held for a peer we dialed got before
+// we disconnected and the values of its PeerSession carried over, and restarts
+// its lifetime.
+func (s *sessionStore) retainHeld(address string, sess *session, messageNonce, requestNonce uint64, carried map[interface{}]sessionEntry) {
+	s.Lock()
+	defer s.Unlock()
+
+	if sess.used {
+		return
+	}
+
+	sess.messageNonce = messageNonce
+	sess.requestNonce = requestNonce
+	sess.carried = carried
+	sess.expiry = time.Now().Add(s.lifetime)
+	s.held[address] = sess
+}
+
+// migrateHeld takes the session held for an address a peer roamed away from,
+// provided it was handed out by the same public key.
+func (s *sessionStore) migrateHeld(address string, publicKey []byte) *session {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, exists := s.held[address]
+	if !exists || !bytes.Equal(sess.publicKey, publicKey) {
+		return nil
+	}
+
+	delete(s.held, address)
+	sess.used = true
+
+	if time.Now().After(sess.expiry) {
+		return nil
+	}
+
+	return sess
+}
+
+// handOver returns the sessions which may still be resumed, issued ones and
+// held ones by address, for another process to take them over.
+func (s *sessionStore) handOver() (issued []*session, held map[string]*session) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	for _, sess := range s.issued {
+		if !sess.used && now.Before(sess.expiry) {
+			issued = append(issued, sess)
+		}
+	}
+
+	held = make(map[string]*session, len(s.held))
+	for address, sess := range s.held {
+		if !sess.used && now.Before(sess.expiry) {
+			held[address] = sess
+		}
+	}
+
+	return issued, held
+}
+
+// takeOver adopts sessions handed over by another process, keeping their
+// expiry.
+func (s *sessionStore) takeOver(issued []*session, held map[string]*session) {
+	if !s.enabled() {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	for _, sess := range issued {
+		if now.Before(sess.expiry) {
+			s.issued[string(sess.token)] = sess
+		}
+	}
+
+	for address, sess := range held {
+		if now.Before(sess.expiry) {
+			s.held[address] = sess
+		}
+	}
+}
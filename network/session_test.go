@@ -0,0 +1,117 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// noncePlugin stores the message nonces of all received test messages.
+type noncePlugin struct {
+	*Plugin
+	nonces chan uint64
+}
+
+func (p *noncePlugin) Receive(ctx *PluginContext) error {
+	if _, ok := ctx.Message().(*testpb.TestMessage); ok {
+		p.nonces <- ctx.frame.MessageNonce
+	}
+	return nil
+}
+
+func TestSessionResumption(t *testing.T) {
+	t.Parallel()
+
+	plugin := &noncePlugin{nonces: make(chan uint64, 16)}
+
+	builder := NewBuilderWithOptions(SessionResumption(time.Minute), OrderedHandlers(&testpb.TestMessage{}))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(plugin)
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	builder = NewBuilderWithOptions(SessionResumption(time.Minute))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go receiver.Listen()
+	go sender.Listen()
+	<-receiver.Ready()
+	<-sender.Ready()
+
+	handshakesSent := func() uint64 {
+		return sender.HandshakeStats().Sent + receiver.HandshakeStats().Sent
+	}
+
+	// connect sends count messages over a fresh link, and returns the number
+	// of handshake messages it took to set the link up.
+	connect := func(count int) uint64 {
+		before := handshakesSent()
+
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+
+		for i := 0; i < count; i++ {
+			assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+		}
+		waitForPeers(t, sender, 1)
+		waitForPeers(t, receiver, 1)
+
+		return handshakesSent() - before
+	}
+
+	disconnect := func() {
+		client, err := sender.Client(receiver.Address)
+		assert.Nil(t, err)
+		client.Close()
+
+		assert.True(t, waitUntil(5*time.Second, func() bool {
+			return len(sender.Peers()) == 0 && len(receiver.Peers()) == 0
+		}), "peers never disconnected")
+	}
+
+	// Both connections of a link pay for a full handshake.
+	assert.Equal(t, uint64(6), connect(3))
+	for i := uint64(1); i <= 3; i++ {
+		assert.Equal(t, i, <-plugin.nonces)
+	}
+
+	disconnect()
+
+	// Both connections of a link are resumed.
+	assert.Equal(t, uint64(4), connect(1))
+	assert.Equal(t, uint64(4), <-plugin.nonces, "sequence numbers should carry on after resumption")
+	assert.Equal(t, uint64(2), sender.HandshakeStats().Resumed)
+	assert.Equal(t, uint64(2), receiver.HandshakeStats().Resumed)
+
+	disconnect()
+
+	// Tokens are single-use, though each resumption hands out a new one.
+	assert.Equal(t, uint64(4), connect(1))
+	assert.Equal(t, uint64(5), <-plugin.nonces)
+}
+
+func TestSessionTokenBoundToPublicKey(t *testing.T) {
+	store := newSessionStore(time.Minute)
+	offer := &protobuf.HandshakeOffer{Versions: []string{DefaultProtocolVersion}}
+
+	sess := store.newSession(store.newToken(), []byte("owner"), DefaultProtocolVersion, offer)
+	store.issue(sess)
+
+	assert.Nil(t, store.takeIssued(sess.token, []byte("thief"), offer), "token should be bound to its owner")
+	assert.NotNil(t, store.takeIssued(sess.token, []byte("owner"), offer))
+	assert.Nil(t, store.takeIssued(sess.token, []byte("owner"), offer), "token should be single-use")
+
+	expired := newSessionStore(time.Nanosecond)
+	sess = expired.newSession(expired.newToken(), []byte("owner"), DefaultProtocolVersion, offer)
+	expired.issue(sess)
+	time.Sleep(time.Millisecond)
+
+	assert.Nil(t, expired.takeIssued(sess.token, []byte("owner"), offer), "token should expire")
+}
@@ -0,0 +1,243 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+// ShapingStats counts writes delayed to keep within bandwidth limits.
+type ShapingStats struct {
+	// Delayed is the number of messages whose writes were delayed.
+	Delayed uint64
+	// Delay is the total time writes were delayed for.
+	Delay time.Duration
+}
+
+// tokenBucket limits a rate of bytes, allowing bursts of up to burst bytes.
+// Writes larger than the tokens available go into debt, which later writes
+// wait out.
+type tokenBucket struct {
+	sync.Mutex
+
+	rate   float64 // bytes per second, or 0 if unlimited
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int) *tokenBucket {
+	b := &tokenBucket{}
+	b.set(time.Now(), rate, burst)
+	return b
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+// set changes the rate and burst of the bucket, keeping any debt owed.
+func (b *tokenBucket) set(now time.Time, rate, burst int) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.refill(now)
+
+	// Buckets which were unlimited start off full.
+	unlimited := b.rate <= 0
+
+	if burst <= 0 {
+		burst = rate
+	}
+	b.rate, b.burst = float64(rate), float64(burst)
+
+	if unlimited || b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// reserve takes size bytes from the bucket, returning how long to wait
+// before writing them.
+func (b *tokenBucket) reserve(now time.Time, size int) time.Duration {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.refill(now)
+	b.tokens -= float64(size)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// full returns true if the bucket holds as many tokens as it may.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// headroom returns how many bytes may be taken from the bucket before writes
+// are delayed, and false should the bucket be unlimited.
+func (b *tokenBucket) headroom(now time.Time) (int64, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.rate <= 0 {
+		return 0, false
+	}
+
+	b.refill(now)
+	return int64(b.tokens), true
+}
+
+// take takes a single token from the bucket without going into debt,
+// returning false should none be available.
+func (b *tokenBucket) take(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.rate <= 0 {
+		return true
+	}
+
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// exemptFromShaping returns true if a message bypasses bandwidth limits.
+func (n *Network) exemptFromShaping(message *protobuf.Message) bool {
+	if message.Message == nil {
+		return false
+	}
+
+	name, err := types.AnyMessageName(message.Message)
+	if err != nil {
+		return false
+	}
+
+	_, exempt := n.shapingExempt[name]
+	return exempt
+}
+
+// shape waits until size bytes of a message may be written to a peer
+// without exceeding the global nor the peer's bandwidth limit. Handshakes
+// are written outside of shaping, and messages such as pings exempt from it
+// are never delayed.
+func (n *Network) shape(state *ConnState, message *protobuf.Message, size int) {
+	if n.exemptFromShaping(message) {
+		return
+	}
+
+	now := time.Now()
+
+	delay := n.bandwidth.reserve(now, size)
+	if peerDelay := state.bandwidth.reserve(now, size); peerDelay > delay {
+		delay = peerDelay
+	}
+
+	if delay <= 0 {
+		return
+	}
+
+	atomic.AddUint64(&n.shapingDelayed, 1)
+	atomic.AddInt64(&n.shapingDelay, int64(delay))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-n.kill:
+	}
+}
+
+// SetBandwidthLimit changes the outbound bandwidth limit across all peers to
+// bytesPerSecond, allowing bursts of up to burst bytes. A rate of 0 lifts the
+// limit.
+func (n *Network) SetBandwidthLimit(bytesPerSecond, burst int) {
+	n.bandwidth.set(time.Now(), bytesPerSecond, burst)
+}
+
+// SetPeerBandwidthLimit changes the outbound bandwidth limit of every peer,
+// connected or not, to bytesPerSecond, allowing bursts of up to burst bytes.
+// A rate of 0 lifts the limit. Limits overridden for the tags of peers are
+// kept.
+func (n *Network) SetPeerBandwidthLimit(bytesPerSecond, burst int) {
+	n.peerBandwidthMutex.Lock()
+	defer n.peerBandwidthMutex.Unlock()
+
+	n.peerBandwidth, n.peerBandwidthBurst = bytesPerSecond, burst
+
+	now := time.Now()
+	n.connections.Range(func(key, value interface{}) bool {
+		state, ok := value.(*ConnState)
+		if !ok {
+			return true
+		}
+
+		limit := bandwidthLimit{rate: bytesPerSecond, burst: burst}
+		if client, exists := n.peers.Load(key); exists {
+			limit = n.tagBandwidthLimit(client.(*PeerClient).publicKey, limit)
+		}
+		state.bandwidth.set(now, limit.rate, limit.burst)
+		return true
+	})
+}
+
+// newPeerBandwidth returns the bucket limiting the bandwidth of a newly
+// connected peer of a public key.
+func (n *Network) newPeerBandwidth(publicKey []byte) *tokenBucket {
+	n.peerBandwidthMutex.Lock()
+	defer n.peerBandwidthMutex.Unlock()
+
+	limit := n.tagBandwidthLimit(publicKey, bandwidthLimit{rate: n.peerBandwidth, burst: n.peerBandwidthBurst})
+	return newTokenBucket(limit.rate, limit.burst)
+}
+
+// ShapingStats returns how many writes were delayed to keep within bandwidth
+// limits, and for how long.
+func (n *Network) ShapingStats() ShapingStats {
+	return ShapingStats{
+		Delayed: atomic.LoadUint64(&n.shapingDelayed),
+		Delay:   time.Duration(atomic.LoadInt64(&n.shapingDelay)),
+	}
+}
+
+// shapingExemptions returns the names of messages exempt from bandwidth
+// limits. Pings, pongs and keepalives are always exempt, so that shaping
+// never delays liveness checks.
+func shapingExemptions(names []string) map[string]struct{} {
+	exempt := map[string]struct{}{
+		proto.MessageName(&protobuf.Ping{}): {},
+		proto.MessageName(&protobuf.Pong{}): {},
+
+		proto.MessageName(&protobuf.Keepalive{}):    {},
+		proto.MessageName(&protobuf.KeepaliveAck{}): {},
+	}
+	for _, name := range names {
+		exempt[name] = struct{}{}
+	}
+	return exempt
+}
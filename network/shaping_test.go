@@ -0,0 +1,131 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+func TestBandwidthLimitThroughput(t *testing.T) {
+	t.Parallel()
+
+	const rate, messageSize, numMessages = 1024 * 1024, 32 * 1024, 48
+
+	var received atomic.Int64
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received.Add(int64(len(msg.Message)))
+		}
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	sender.SetBandwidthLimit(rate, messageSize)
+
+	// Keep pinging the receiver while it is being flooded.
+	stop := make(chan struct{})
+	pings := make(chan time.Duration, 64)
+	go func() {
+		defer close(pings)
+
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				start := time.Now()
+				assert.Nil(t, client.Tell(&protobuf.Ping{}))
+				pings <- time.Since(start)
+			}
+		}
+	}()
+
+	payload := strings.Repeat("x", messageSize)
+
+	start := time.Now()
+	for i := 0; i < numMessages; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: payload}))
+	}
+	assert.True(t, waitUntil(10*time.Second, func() bool { return received.Load() == messageSize*numMessages }))
+	elapsed := time.Since(start)
+
+	close(stop)
+
+	// The first burst is sent without delay.
+	achieved := float64(messageSize*(numMessages-1)) / elapsed.Seconds()
+	assert.InEpsilon(t, float64(rate), achieved, 0.2, "achieved %.0f bytes per second", achieved)
+
+	count := 0
+	for latency := range pings {
+		assert.True(t, latency < 50*time.Millisecond, "pings should not be shaped, but took %s", latency)
+		count++
+	}
+	assert.True(t, count >= int(elapsed/(100*time.Millisecond))-1, "only %d pings were sent in %s", count, elapsed)
+
+	stats := sender.ShapingStats()
+	assert.True(t, stats.Delayed > 0)
+	assert.True(t, stats.Delay > 0)
+}
+
+func TestPeerBandwidthLimit(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t, PeerBandwidthLimit(1024, 0))
+	defer node.Close()
+
+	first := buildListeningNode(t)
+	defer first.Close()
+
+	_, err := node.Client(first.Address)
+	assert.Nil(t, err)
+
+	state, ok := node.ConnectionState(first.Address)
+	assert.True(t, ok)
+	assert.Equal(t, 1024.0, state.bandwidth.rate)
+	assert.Equal(t, 1024.0, state.bandwidth.burst)
+
+	node.SetPeerBandwidthLimit(4096, 8192)
+	assert.Equal(t, 4096.0, state.bandwidth.rate)
+	assert.Equal(t, 8192.0, state.bandwidth.burst)
+
+	second := buildListeningNode(t)
+	defer second.Close()
+
+	_, err = node.Client(second.Address)
+	assert.Nil(t, err)
+
+	state, ok = node.ConnectionState(second.Address)
+	assert.True(t, ok)
+	assert.Equal(t, 4096.0, state.bandwidth.rate)
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	b := &tokenBucket{}
+	b.set(start, 1000, 500)
+
+	assert.Equal(t, time.Duration(0), b.reserve(start, 500), "bursts should not be delayed")
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start, 500))
+
+	// Debt is paid off over time.
+	assert.Equal(t, 500*time.Millisecond, b.reserve(start.Add(time.Second), 1000))
+
+	// Unlimited buckets never delay, and start off full once limited.
+	b.set(start.Add(time.Second), 0, 0)
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(time.Second), 1<<20))
+
+	b.set(start.Add(time.Second), 100, 200)
+	assert.Equal(t, time.Duration(0), b.reserve(start.Add(time.Second), 200))
+	assert.Equal(t, time.Second, b.reserve(start.Add(time.Second), 100))
+}
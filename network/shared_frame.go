@@ -0,0 +1,115 @@
+package network
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// sharedFrame is a signed message serialized once without its message nonce,
+// so that the same bytes may be written out to many peers. Its body is never
+// modified once encoded: writes append the nonce as a separate tail rather
+// than copying the body, and batches leave shared frames out. Bodies are left
+// to the garbage collector rather than pooled, as any number of queued writes
+// may still reference them.
+type sharedFrame struct {
+	message *protobuf.Message
+	body    []byte
+}
+
+// shareFrame serializes a signed message into a frame shared by every peer it
+// is broadcast to, returning nil should per-peer hooks enrich the message for
+// every peer, in which case it has to be written out through Write.
+func (n *Network) shareFrame(signed *protobuf.Message) *sharedFrame {
+	if n.hasPeerHooks() {
+		return nil
+	}
+
+	body, err := encodeBody(signed)
+	if err != nil {
+		glog.Warningf("failed to share broadcast frame [err=%s]", err)
+		return nil
+	}
+
+	return &sharedFrame{message: signed, body: body}
+}
+
+// writeBroadcast writes a broadcast message out to a peer, through its shared
+// frame should it have one.
+func (n *Network) writeBroadcast(address string, signed *protobuf.Message, frame *sharedFrame) error {
+	if frame == nil {
+		return n.Write(address, signed)
+	}
+	return n.writeShared(address, frame)
+}
+
+// writeShared writes a shared frame out to a peer.
+func (n *Network) writeShared(address string, frame *sharedFrame) error {
+	if n.isClosed() {
+		return ErrNetworkClosed
+	}
+
+	if err := n.waitUntilReady(); err != nil {
+		return err
+	}
+
+	state, ok := n.ConnectionState(address)
+	if !ok {
+		return errors.New("network: connection does not exist")
+	}
+
+	// Control messages are small, and are tagged with the nonce of the
+	// control connection on a copy of their own.
+	if state.control != nil && n.isControlMessage(frame.message) {
+		message := *frame.message
+		return n.writeControl(address, state.control, &message)
+	}
+
+	return n.writeBody(address, state, frame.message, frame.body)
+}
+
+// writeBody writes out the body of a serialized message, tagged with the next
+// message nonce of the peer's connection and padded should the peer strip
+// padding. The body is written out as is
+// rather than copied, and is not modified. The bytes the futures it carries
+// were counted as queued for are taken over.
+func (n *Network) writeBody(address string, state *ConnState, message *protobuf.Message, body []byte, futures ...*SendFuture) error {
+	if state.legacy {
+		return n.writeLegacy(address, state, message, futures...)
+	}
+
+	if err := n.allowWrite(address); err != nil {
+		return err
+	}
+
+	tail := make([]byte, 0, 1+binary.MaxVarintLen64)
+	tail = append(tail, messageNonceTag)
+	tail = appendUvarint(tail, atomic.AddUint64(&state.messageNonce, 1))
+	tail = append(tail, n.padding(address, message, len(body)+len(tail))...)
+
+	size := len(body) + len(tail) + 4
+
+	// The message counts as queued until it makes it into the buffer.
+	state.flow.add(int64(size) - state.flow.release(futures...))
+
+	n.shape(state, message, size)
+
+	state.conn.SetWriteDeadline(time.Now().Add(n.writeTimeout(address, size)))
+
+	err := n.writeFrameParts(state.writer, state.writerMutex, state.flow, body, tail)
+	state.flow.add(-int64(size))
+	n.markWritten(address, err)
+	if err != nil {
+		return err
+	}
+
+	n.tailMessage(DirectionOutbound, address, message, size, true)
+	n.captureOutbound(address, body, tail)
+	n.mirrorOutbound(address, message, body, tail)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package network
+
+import (
+	"bufio"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+// discardConn is an in-memory connection discarding everything written to it.
+type discardConn struct {
+	written *atomic.Int64
+}
+
+func (c discardConn) Read([]byte) (int, error)         { select {} }
+func (c discardConn) Write(b []byte) (int, error)      { c.written.Add(int64(len(b))); return len(b), nil }
+func (c discardConn) Close() error                     { return nil }
+func (c discardConn) LocalAddr() net.Addr              { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (c discardConn) RemoteAddr() net.Addr             { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+func (c discardConn) SetDeadline(time.Time) error      { return nil }
+func (c discardConn) SetReadDeadline(time.Time) error  { return nil }
+func (c discardConn) SetWriteDeadline(time.Time) error { return nil }
+
+// addDiscardPeers registers in-memory peers whose connections discard every
+// message written to them.
+func addDiscardPeers(t *testing.T, node *Network, count int, written *atomic.Int64) {
+	for i := 0; i < count; i++ {
+		address := FormatAddress("tcp", "localhost", uint16(20000+i))
+
+		client, err := createPeerClient(node, address)
+		assert.Nil(t, err)
+
+		conn := discardConn{written: written}
+		state := &ConnState{
+			conn:        conn,
+			writer:      bufio.NewWriterSize(conn, node.opts.writeBufferSize),
+			writerMutex: new(sync.Mutex),
+			sends:       newSendQueue(),
+			bandwidth:   node.newPeerBandwidth(nil),
+		}
+
+		node.peers.Store(address, client)
+		node.connections.Store(address, state)
+		node.spawn(func() { node.sendLoop(address, state.sends) })
+	}
+}
+
+// broadcastAllocs returns how many bytes were allocated broadcasting a message
+// to every peer of a node until it was written out to all of them.
+func broadcastAllocs(t *testing.T, node *Network, message *testpb.TestMessage, written *atomic.Int64, total int64) uint64 {
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	node.Broadcast(message)
+	assert.True(t, waitUntil(10*time.Second, func() bool {
+		return written.Load() >= total
+	}))
+
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestBroadcastSharesFrame(t *testing.T) {
+	const (
+		peers = 100
+		size  = 1 << 20
+	)
+
+	message := &testpb.TestMessage{Message: strings.Repeat("x", size)}
+
+	for _, opts := range [][]BuilderOption{nil, {SendWorkers(4)}} {
+		written := atomic.NewInt64(0)
+
+		node := buildListeningNode(t, opts...)
+		addDiscardPeers(t, node, peers, written)
+
+		// Copying the frame for every peer would allocate upwards of 100MB.
+		allocs := broadcastAllocs(t, node, message, written, peers*size)
+		assert.True(t, allocs < 16*size, "broadcast allocated %d bytes", allocs)
+
+		node.Close()
+	}
+}
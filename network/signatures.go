@@ -0,0 +1,267 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+// PrimarySignatureScheme names the scheme set by SignaturePolicy, which
+// messages are always signed under.
+const PrimarySignatureScheme = "primary"
+
+// SignatureScheme is a signature policy alongside the keys this node signs
+// messages with under it.
+type SignatureScheme struct {
+	Name   string
+	Policy crypto.SignaturePolicy
+	// Keys are generated should they be nil.
+	Keys *crypto.KeyPair
+}
+
+// SignatureStats describes how far peers got migrating between signature schemes.
+type SignatureStats struct {
+	// Peers is the number of connected peers.
+	Peers int
+	// Schemes is the number of connected peers which demonstrated each scheme.
+	Schemes map[string]int
+}
+
+// signatureBindings remembers the public keys peers demonstrated under each
+// signature scheme, keyed by their primary public key.
+type signatureBindings struct {
+	sync.Mutex
+	keys map[string]map[string][]byte // primary public key -> scheme -> public key
+}
+
+// bind records the public key a peer demonstrated under a scheme, returning
+// false should it differ from the one demonstrated before.
+func (b *signatureBindings) bind(primary []byte, scheme string, publicKey []byte) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.keys == nil {
+		b.keys = make(map[string]map[string][]byte)
+	}
+
+	schemes, exists := b.keys[string(primary)]
+	if !exists {
+		schemes = make(map[string][]byte)
+		b.keys[string(primary)] = schemes
+	}
+
+	if bound, exists := schemes[scheme]; exists {
+		return bytes.Equal(bound, publicKey)
+	}
+
+	schemes[scheme] = append([]byte(nil), publicKey...)
+	return true
+}
+
+// bound returns true if a peer demonstrated a public key under a scheme before.
+func (b *signatureBindings) bound(primary []byte, scheme string, publicKey []byte) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	bound, exists := b.keys[string(primary)][scheme]
+	return exists && bytes.Equal(bound, publicKey)
+}
+
+// schemes returns the schemes a peer demonstrated, sorted by name.
+func (b *signatureBindings) schemes(primary []byte) []string {
+	b.Lock()
+	defer b.Unlock()
+
+	var schemes []string
+	for scheme := range b.keys[string(primary)] {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// serializeSignatureKeys appends the schemes and public keys of a message's
+// additional signatures to its serialized envelope, so that the primary
+// signature vouches for them.
+func serializeSignatureKeys(serialized []byte, signatures []*protobuf.Signature) []byte {
+	var size [4]byte
+	for _, signature := range signatures {
+		for _, field := range [][]byte{[]byte(signature.Scheme), signature.PublicKey} {
+			binary.LittleEndian.PutUint32(size[:], uint32(len(field)))
+			serialized = append(serialized, size[:]...)
+			serialized = append(serialized, field...)
+		}
+	}
+	return serialized
+}
+
+// serializeSignatureScheme appends the scheme the primary signature of a
+// message was made under to its serialized envelope, should it carry one, so
+// that the scheme may not be altered in transit.
+func serializeSignatureScheme(serialized []byte, scheme string) []byte {
+	if len(scheme) == 0 {
+		return serialized
+	}
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(scheme)))
+	serialized = append(serialized, size[:]...)
+	return append(serialized, scheme...)
+}
+
+// signatureScheme returns the identifier envelopes and handshake steps are
+// stamped with of the scheme set by SignaturePolicy. It is left out for
+// ed25519 and policies which do not identify their scheme, so that peers
+// unaware of identifiers verify them as before.
+func (n *Network) signatureScheme() string {
+	scheme := crypto.Scheme(n.opts.signaturePolicy)
+	if scheme == ed25519.SchemeName {
+		return ""
+	}
+	return scheme
+}
+
+// signaturePolicy returns the policy to verify a primary signature made under
+// a scheme with, out of the primary policy and those of the schemes being
+// migrated to. Signatures stamped with no scheme were made under ed25519, or
+// under the primary policy should it not identify its scheme. It returns nil
+// should this node know of no policy for the scheme.
+func (n *Network) signaturePolicy(scheme string) crypto.SignaturePolicy {
+	if len(scheme) == 0 {
+		if crypto.Scheme(n.opts.signaturePolicy) == "" {
+			return n.opts.signaturePolicy
+		}
+		scheme = ed25519.SchemeName
+	}
+
+	if crypto.Scheme(n.opts.signaturePolicy) == scheme {
+		return n.opts.signaturePolicy
+	}
+	for _, additional := range n.opts.signatureSchemes {
+		if crypto.Scheme(additional.Policy) == scheme {
+			return additional.Policy
+		}
+	}
+	return nil
+}
+
+// signMessage signs over a messages contents, sender, metadata and hints with
+// this nodes private key, under the primary signature scheme and any scheme
+// being migrated to, in the form set by EnvelopeSigning.
+func (n *Network) signMessage(msg *protobuf.Message) error {
+	if err := checkHints(msg.Hints); err != nil {
+		return err
+	}
+
+	msg.Signatures = nil
+	for _, scheme := range n.opts.signatureSchemes {
+		msg.Signatures = append(msg.Signatures, &protobuf.Signature{
+			Scheme:    scheme.Name,
+			PublicKey: scheme.Keys.PublicKey,
+		})
+	}
+	msg.SignatureScheme = n.signatureScheme()
+
+	markExtensions(msg)
+	if n.signsCanonically() {
+		MarkExtension(msg, ExtensionCanonicalSigning)
+	}
+
+	payload := signingPreimage(msg)
+
+	signature, err := n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, payload)
+	if err != nil {
+		return err
+	}
+	msg.Signature = signature
+
+	for i, scheme := range n.opts.signatureSchemes {
+		msg.Signatures[i].Signature, err = scheme.Keys.Sign(scheme.Policy, n.opts.hashPolicy, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// strictSignatures returns true once messages must carry a verified signature
+// under a scheme being migrated to.
+func (n *Network) strictSignatures() bool {
+	return n.opts.strictSignatures && !n.now().Before(n.opts.signatureTransitionEnd)
+}
+
+// verifyMessage returns true if a message carries a verified signature under a
+// scheme this node trusts. Signatures under schemes being migrated to are only
+// trusted once the sender demonstrated their public key alongside a verified
+// primary signature. Once the transition window ends in strict mode, messages
+// only signed under the primary scheme are rejected, and under SigningCanonical
+// messages signed over their legacy preimage are.
+func (n *Network) verifyMessage(msg *protobuf.Message) bool {
+	if n.opts.signingForm == SigningCanonical && !signedCanonically(msg) {
+		return false
+	}
+
+	result := n.checkSignatures(msg)
+	primary := msg.Sender.PublicKey
+
+	if result.primary {
+		n.signatureBindings.bind(primary, PrimarySignatureScheme, primary)
+	}
+
+	migrated := false
+
+	for _, signature := range result.verified {
+		if result.primary {
+			migrated = n.signatureBindings.bind(primary, signature.scheme, signature.publicKey) || migrated
+		} else {
+			migrated = n.signatureBindings.bound(primary, signature.scheme, signature.publicKey) || migrated
+		}
+	}
+
+	if n.strictSignatures() {
+		return migrated
+	}
+	return result.primary || migrated
+}
+
+// verifySignatures checks every signature a message carries under the schemes
+// this node knows of, regardless of whether they are trusted. The primary
+// signature is checked under the scheme the message is stamped with.
+func (n *Network) verifySignatures(msg *protobuf.Message, payload []byte) *verificationResult {
+	policy := n.signaturePolicy(msg.SignatureScheme)
+
+	result := &verificationResult{
+		primary: policy != nil && len(msg.Signature) > 0 && crypto.Verify(policy, n.opts.hashPolicy, msg.Sender.PublicKey, payload, msg.Signature),
+	}
+
+	for _, signature := range msg.Signatures {
+		for _, scheme := range n.opts.signatureSchemes {
+			if signature.Scheme == scheme.Name && crypto.Verify(scheme.Policy, n.opts.hashPolicy, signature.PublicKey, payload, signature.Signature) {
+				result.verified = append(result.verified, verifiedKey{scheme: scheme.Name, publicKey: signature.PublicKey})
+			}
+		}
+	}
+
+	return result
+}
+
+// SignatureStats returns how many connected peers demonstrated each signature scheme.
+func (n *Network) SignatureStats() SignatureStats {
+	stats := SignatureStats{Schemes: make(map[string]int)}
+
+	n.eachPeer(func(client *PeerClient) bool {
+		stats.Peers++
+		for _, scheme := range n.signatureBindings.schemes(client.PeerID().PublicKey()) {
+			stats.Schemes[scheme]++
+		}
+		return true
+	})
+
+	return stats
+}
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/crypto/mldsa"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixedPolicy stands in for a new signature scheme, its signatures never
+// verifying under plain ed25519 nor the other way around.
+type prefixedPolicy struct {
+	*ed25519.Ed25519
+}
+
+func (p prefixedPolicy) Sign(privateKey []byte, message []byte) []byte {
+	return p.Ed25519.Sign(privateKey, append([]byte("v2:"), message...))
+}
+
+func (p prefixedPolicy) Verify(publicKey []byte, message []byte, signature []byte) bool {
+	return p.Ed25519.Verify(publicKey, append([]byte("v2:"), message...), signature)
+}
+
+func newSchemeTransition(until time.Time) BuilderOption {
+	return SignatureTransition(SignatureScheme{Name: "v2", Policy: prefixedPolicy{ed25519.New()}}, until)
+}
+
+// exchange returns true if a message sent from a node built with senderOpts
+// is handled by a node built with receiverOpts.
+func exchange(t *testing.T, senderOpts []BuilderOption, receiverOpts []BuilderOption) (bool, *Network, *Network) {
+	received := make(chan struct{}, 1)
+
+	receiver, idle, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- struct{}{}
+	}, receiverOpts...)
+	idle.Close()
+
+	sender := buildListeningNode(t, senderOpts...)
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+	select {
+	case <-received:
+		return true, sender, receiver
+	case <-time.After(1 * time.Second):
+		return false, sender, receiver
+	}
+}
+
+func TestSignatureTransition(t *testing.T) {
+	t.Parallel()
+
+	window := time.Now().Add(time.Hour)
+	old := []BuilderOption(nil)
+	migrating := []BuilderOption{newSchemeTransition(window), StrictSignatures()}
+
+	ok, sender, receiver := exchange(t, old, migrating)
+	assert.True(t, ok, "messages from old nodes should be accepted during the transition window")
+	assert.Equal(t, []string{PrimarySignatureScheme}, receiver.peerInfo(sender.Address).SignatureSchemes)
+	sender.Close()
+	receiver.Close()
+
+	ok, sender, receiver = exchange(t, migrating, old)
+	assert.True(t, ok, "old nodes should accept messages signed under both schemes")
+	sender.Close()
+	receiver.Close()
+
+	ok, sender, receiver = exchange(t, migrating, migrating)
+	assert.True(t, ok)
+	assert.Equal(t, []string{PrimarySignatureScheme, "v2"}, receiver.peerInfo(sender.Address).SignatureSchemes)
+
+	stats := receiver.SignatureStats()
+	assert.Equal(t, 1, stats.Schemes["v2"])
+	assert.True(t, stats.Peers >= 1)
+	sender.Close()
+	receiver.Close()
+}
+
+func TestStrictSignatures(t *testing.T) {
+	t.Parallel()
+
+	ended := time.Now().Add(-time.Minute)
+	strict := []BuilderOption{newSchemeTransition(ended), StrictSignatures()}
+
+	ok, sender, receiver := exchange(t, nil, strict)
+	assert.False(t, ok, "messages only signed under the old scheme should be rejected after the window")
+	sender.Close()
+	receiver.Close()
+
+	ok, sender, receiver = exchange(t, []BuilderOption{newSchemeTransition(ended)}, strict)
+	assert.True(t, ok)
+	sender.Close()
+	receiver.Close()
+
+	// Without strict mode, old signatures remain accepted after the window.
+	ok, sender, receiver = exchange(t, nil, []BuilderOption{newSchemeTransition(ended)})
+	assert.True(t, ok)
+	sender.Close()
+	receiver.Close()
+}
+
+func TestSignatureSchemeKeysBoundToPeer(t *testing.T) {
+	t.Parallel()
+
+	sender := buildListeningNode(t, newSchemeTransition(time.Now().Add(time.Hour)))
+	defer sender.Close()
+
+	receiver := buildListeningNode(t, newSchemeTransition(time.Now().Add(time.Hour)))
+	defer receiver.Close()
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+
+	signature := message.Signature
+	message.Signature = []byte("forged")
+
+	assert.False(t, receiver.verifyMessage(message), "keys under new schemes should not be trusted before being vouched for")
+
+	message.Signature = signature
+	assert.True(t, receiver.verifyMessage(message))
+
+	message.Signature = []byte("forged")
+	assert.True(t, receiver.verifyMessage(message), "keys vouched for should be trusted on their own")
+
+	// Keys under new schemes are covered by the primary signature.
+	message.Signature = signature
+	message.Signatures[0].PublicKey = ed25519.RandomKeyPair().PublicKey
+	assert.False(t, receiver.verifyMessage(message))
+}
+
+// buildSigningNode builds a listening node signing with keys under the given
+// options, handing every message it receives to fn.
+func buildSigningNode(t *testing.T, keys *crypto.KeyPair, fn func(ctx *PluginContext), opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(keys)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: fn})
+
+	node, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func TestSignatureSchemeIsSigned(t *testing.T) {
+	t.Parallel()
+
+	for _, form := range []SigningForm{SigningLegacy, SigningCanonical} {
+		builder := NewBuilderWithOptions(SignaturePolicy(mldsa.New()), EnvelopeSigning(form))
+		builder.SetKeys(mldsa.RandomKeyPair())
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		message, err := node.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+		assert.Nil(t, err)
+
+		assert.Equal(t, mldsa.SchemeName, message.SignatureScheme)
+		assert.Contains(t, message.CriticalExtensions, uint32(ExtensionSignatureScheme))
+		assert.True(t, node.verifyMessage(message))
+
+		message.SignatureScheme = ed25519.SchemeName
+		assert.False(t, node.verifyMessage(message))
+	}
+
+	// Envelopes signed under ed25519 are left unstamped.
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	message, err := node.PrepareMessage(&testpb.TestMessage{Message: "hello"})
+	assert.Nil(t, err)
+	assert.Empty(t, message.SignatureScheme)
+	assert.NotContains(t, message.CriticalExtensions, uint32(ExtensionSignatureScheme))
+}
+
+func TestSignatureSchemeDispatch(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan struct{}, 1)
+	handler := func(ctx *PluginContext) {
+		received <- struct{}{}
+	}
+
+	postQuantum := SignaturePolicy(mldsa.New())
+	transition := SignatureTransition(SignatureScheme{Name: "pq", Policy: mldsa.New()}, time.Now().Add(time.Hour))
+
+	// Peers signing under differing schemes each need to know of the other's.
+	sender := buildSigningNode(t, mldsa.RandomKeyPair(), func(ctx *PluginContext) {}, postQuantum,
+		SignatureTransition(SignatureScheme{Name: "classic", Policy: ed25519.New()}, time.Now().Add(time.Hour)))
+	defer sender.Close()
+
+	receivers := []struct {
+		node     *Network
+		verifies bool
+	}{
+		{buildSigningNode(t, mldsa.RandomKeyPair(), handler, postQuantum), true},
+		{buildSigningNode(t, ed25519.RandomKeyPair(), handler, transition), true},
+		{buildSigningNode(t, ed25519.RandomKeyPair(), handler), false},
+	}
+
+	for i, receiver := range receivers {
+		defer receiver.node.Close()
+
+		client, err := sender.Client(receiver.node.Address)
+		if err == nil {
+			err = client.Tell(&testpb.TestMessage{Message: "hello"})
+		}
+
+		select {
+		case <-received:
+			assert.True(t, receiver.verifies, "receiver %d should have rejected the message", i)
+		case <-time.After(1 * time.Second):
+			assert.False(t, receiver.verifies, "receiver %d never received the message: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,584 @@
+package network
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// simExecutor runs tasks one at a time, picking which runs next from a seeded
+// source. A task keeps its turn until it finishes, yields, or stays blocked
+// for longer than settle, so that a seed reproduces the order in which tasks
+// start and resume from yields.
+type simExecutor struct {
+	rand   *rand.Rand
+	settle time.Duration
+
+	mutex sync.Mutex
+	ready []*simTask
+	tasks map[int64]*simTask // by goroutine ID
+
+	wake    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+type simTask struct {
+	resume chan chan struct{}
+
+	// turn is signaled once the task yields or finishes its turn.
+	turn chan struct{}
+}
+
+func newSimExecutor(seed int64) *simExecutor {
+	s := &simExecutor{
+		rand:    rand.New(rand.NewSource(seed)),
+		settle:  20 * time.Millisecond,
+		tasks:   make(map[int64]*simTask),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *simExecutor) Go(task func()) {
+	t := &simTask{resume: make(chan chan struct{})}
+	s.enqueue(t)
+
+	go func() {
+		id := goroutineID()
+
+		s.mutex.Lock()
+		s.tasks[id] = t
+		s.mutex.Unlock()
+
+		s.wait(t)
+		task()
+
+		s.mutex.Lock()
+		delete(s.tasks, id)
+		s.mutex.Unlock()
+
+		t.turn <- struct{}{}
+	}()
+}
+
+func (s *simExecutor) Yield() {
+	s.mutex.Lock()
+	t, isTask := s.tasks[goroutineID()]
+	s.mutex.Unlock()
+
+	if !isTask {
+		return
+	}
+
+	s.enqueue(t)
+	t.turn <- struct{}{}
+	s.wait(t)
+}
+
+func (s *simExecutor) enqueue(t *simTask) {
+	s.mutex.Lock()
+	s.ready = append(s.ready, t)
+	s.mutex.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until a task is given its turn, or the executor is stopped.
+func (s *simExecutor) wait(t *simTask) {
+	select {
+	case t.turn = <-t.resume:
+	case <-s.stopped:
+		t.turn = make(chan struct{}, 1)
+	}
+}
+
+func (s *simExecutor) run() {
+	for {
+		s.mutex.Lock()
+		if len(s.ready) == 0 {
+			s.mutex.Unlock()
+
+			select {
+			case <-s.wake:
+				continue
+			case <-s.stopped:
+				return
+			}
+		}
+
+		i := s.rand.Intn(len(s.ready))
+		t := s.ready[i]
+		s.ready = append(s.ready[:i], s.ready[i+1:]...)
+		s.mutex.Unlock()
+
+		turn := make(chan struct{}, 1)
+		select {
+		case t.resume <- turn:
+		case <-s.stopped:
+			return
+		}
+
+		select {
+		case <-turn:
+		case <-time.After(s.settle):
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// stop lets all tasks run freely from then on.
+func (s *simExecutor) stop() {
+	s.once.Do(func() { close(s.stopped) })
+}
+
+func goroutineID() int64 {
+	var buf [64]byte
+	fields := strings.Fields(string(buf[:runtime.Stack(buf[:], false)]))
+	id, _ := strconv.ParseInt(fields[1], 10, 64)
+	return id
+}
+
+// memTransport connects nodes through buffered in-memory pipes, keyed by
+// port alone.
+type memTransport struct {
+	sync.Mutex
+	listeners map[string]*memListener
+	dialed    []net.Conn
+}
+
+func newMemTransport() *memTransport {
+	return &memTransport{listeners: make(map[string]*memListener)}
+}
+
+func (m *memTransport) Listen(port int) (net.Listener, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	l := &memListener{conns: make(chan net.Conn, 16), closed: make(chan struct{}), port: strconv.Itoa(port)}
+	m.listeners[l.port] = l
+	return l, nil
+}
+
+func (m *memTransport) Dial(address string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	l, exists := m.listeners[port]
+	m.Unlock()
+
+	if !exists {
+		return nil, errors.Errorf("connection refused: %s", address)
+	}
+
+	client, server := memPipe()
+
+	select {
+	case l.conns <- server:
+	case <-l.closed:
+		return nil, errors.Errorf("connection refused: %s", address)
+	}
+
+	m.Lock()
+	m.dialed = append(m.dialed, client)
+	m.Unlock()
+
+	return client, nil
+}
+
+// openDialed returns the number of dialed connections yet to be closed.
+func (m *memTransport) openDialed() int {
+	m.Lock()
+	defer m.Unlock()
+
+	open := 0
+	for _, conn := range m.dialed {
+		if !conn.(*memConn).isClosed() {
+			open++
+		}
+	}
+	return open
+}
+
+type memListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+	port   string
+}
+
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *memListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr {
+	return memAddr("localhost:" + l.port)
+}
+
+type memAddr string
+
+func (a memAddr) Network() string { return "mem" }
+func (a memAddr) String() string  { return string(a) }
+
+// memBuffer is one direction of a pipe.
+type memBuffer struct {
+	sync.Mutex
+	data     []byte
+	closed   bool
+	readable chan struct{} // closed and replaced whenever data arrives
+}
+
+func newMemBuffer() *memBuffer {
+	return &memBuffer{readable: make(chan struct{})}
+}
+
+func (b *memBuffer) write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	b.data = append(b.data, p...)
+	close(b.readable)
+	b.readable = make(chan struct{})
+
+	return len(p), nil
+}
+
+func (b *memBuffer) close() {
+	b.Lock()
+	defer b.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.readable)
+	}
+}
+
+type memConn struct {
+	in, out *memBuffer
+
+	mutex        sync.Mutex
+	readDeadline time.Time
+}
+
+func memPipe() (net.Conn, net.Conn) {
+	a, b := newMemBuffer(), newMemBuffer()
+	return &memConn{in: a, out: b}, &memConn{in: b, out: a}
+}
+
+func (c *memConn) Read(p []byte) (int, error) {
+	for {
+		c.in.Lock()
+		if len(c.in.data) > 0 {
+			n := copy(p, c.in.data)
+			c.in.data = c.in.data[n:]
+			c.in.Unlock()
+			return n, nil
+		}
+		if c.in.closed {
+			c.in.Unlock()
+			return 0, io.EOF
+		}
+		readable := c.in.readable
+		c.in.Unlock()
+
+		c.mutex.Lock()
+		deadline := c.readDeadline
+		c.mutex.Unlock()
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timer := time.NewTimer(time.Until(deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case <-readable:
+		case <-timeout:
+			return 0, timeoutError{}
+		}
+	}
+}
+
+func (c *memConn) Write(p []byte) (int, error) {
+	return c.out.write(p)
+}
+
+func (c *memConn) Close() error {
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+func (c *memConn) isClosed() bool {
+	c.out.Lock()
+	defer c.out.Unlock()
+	return c.out.closed
+}
+
+func (c *memConn) LocalAddr() net.Addr  { return memAddr("local") }
+func (c *memConn) RemoteAddr() net.Addr { return memAddr("remote") }
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.readDeadline = t
+	c.mutex.Unlock()
+	return nil
+}
+
+// Writes never block, so their deadlines never pass.
+func (c *memConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// buildSimNode builds a node connecting to peers through an in-memory
+// transport, running its tasks on an executor.
+func buildSimNode(t *testing.T, mem *memTransport, executor Executor) *Network {
+	builder := NewBuilderWithOptions(TaskExecutor(executor))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.ClearTransportLayers()
+	builder.RegisterTransportLayer("tcp", mem)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func countConnections(n *Network) int {
+	count := 0
+	n.connections.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// TestSimDialDuringShutdown closes a node while it dials a peer, across
+// schedules in which closing interleaves with every step of the dial.
+func TestSimDialDuringShutdown(t *testing.T) {
+	t.Parallel()
+
+	for seed := int64(1); seed <= 64; seed++ {
+		sim := newSimExecutor(seed)
+		mem := newMemTransport()
+
+		peer := buildSimNode(t, mem, goroutineExecutor{})
+		node := buildSimNode(t, mem, sim)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		sim.Go(func() {
+			defer wg.Done()
+			node.Client(peer.Address)
+		})
+		sim.Go(func() {
+			defer wg.Done()
+			node.Close()
+		})
+		wg.Wait()
+
+		assert.Equal(t, 0, countConnections(node), "seed %d: connections should not outlive the node", seed)
+		assert.True(t, waitUntil(time.Second, func() bool { return mem.openDialed() == 0 }), "seed %d: dialed connections should be closed", seed)
+
+		sim.stop()
+		peer.Close()
+	}
+}
+
+// TestSimDuplicateConnections has two nodes dial each other at once, across
+// schedules in which either dial may complete first. On seeds 1, 3, 14 and 15
+// a node attaches the connection dialed by its peer while its own dial to the
+// peer is still in flight; should attaching not wait for that dial, the
+// connection is dropped and the peer's message lost.
+func TestSimDuplicateConnections(t *testing.T) {
+	t.Parallel()
+
+	for seed := int64(1); seed <= 16; seed++ {
+		sim := newSimExecutor(seed)
+		mem := newMemTransport()
+
+		received := make(chan string, 2)
+		plugin := &handlerPlugin{fn: func(ctx *PluginContext) {
+			if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+				received <- msg.Message
+			}
+		}}
+
+		var nodes []*Network
+		for i := 0; i < 2; i++ {
+			builder := NewBuilderWithOptions(TaskExecutor(sim))
+			builder.SetKeys(ed25519.RandomKeyPair())
+			builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+			builder.ClearTransportLayers()
+			builder.RegisterTransportLayer("tcp", mem)
+			builder.AddPlugin(plugin)
+
+			node, err := builder.Build()
+			assert.Nil(t, err)
+
+			go node.Listen()
+			<-node.Ready()
+
+			nodes = append(nodes, node)
+		}
+
+		var wg sync.WaitGroup
+		for i := range nodes {
+			local, remote := nodes[i], nodes[1-i]
+			wg.Add(1)
+			sim.Go(func() {
+				defer wg.Done()
+
+				client, err := local.Client(remote.Address)
+				if assert.Nil(t, err, "seed %d", seed) {
+					assert.Nil(t, client.Tell(&testpb.TestMessage{Message: local.Address}), "seed %d", seed)
+				}
+			})
+		}
+		wg.Wait()
+
+		for range nodes {
+			select {
+			case <-received:
+			case <-time.After(3 * time.Second):
+				t.Fatalf("seed %d: message was not received", seed)
+			}
+		}
+
+		for _, node := range nodes {
+			assert.Equal(t, 1, len(node.Peers()), "seed %d: peers should be connected exactly once", seed)
+			assert.Equal(t, 1, countConnections(node), "seed %d", seed)
+		}
+
+		sim.stop()
+		for _, node := range nodes {
+			node.Close()
+		}
+	}
+}
+
+// TestSimMergeDuplicateSessions has a peer dial a node from two addresses at
+// once while sending it messages over both sessions, across schedules in
+// which either session may be merged into the other with messages in flight.
+func TestSimMergeDuplicateSessions(t *testing.T) {
+	t.Parallel()
+
+	const messages = 20
+
+	for seed := int64(1); seed <= 16; seed++ {
+		sim := newSimExecutor(seed)
+		mem := newMemTransport()
+
+		var mutex sync.Mutex
+		received := make(map[string]int)
+
+		total := func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(received)
+		}
+
+		build := func(keys *crypto.KeyPair, opts ...BuilderOption) *Network {
+			builder := NewBuilderWithOptions(append(opts, TaskExecutor(sim))...)
+			builder.SetKeys(keys)
+			builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+			builder.ClearTransportLayers()
+			builder.RegisterTransportLayer("tcp", mem)
+			builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+				mutex.Lock()
+				received[ctx.Message().(*testpb.TestMessage).Message]++
+				mutex.Unlock()
+			}})
+
+			node, err := builder.Build()
+			assert.Nil(t, err)
+
+			go node.Listen()
+			<-node.Ready()
+
+			return node
+		}
+
+		node := build(ed25519.RandomKeyPair(), Roaming(RoamingMigrate))
+
+		keys := ed25519.RandomKeyPair()
+		senders := []*Network{build(keys), build(keys)}
+
+		var wg sync.WaitGroup
+		for _, sender := range senders {
+			sender := sender
+			wg.Add(1)
+			sim.Go(func() {
+				defer wg.Done()
+
+				client, err := sender.Client(node.Address)
+				if !assert.Nil(t, err, "seed %d", seed) {
+					return
+				}
+				for i := 0; i < messages; i++ {
+					assert.Nil(t, client.Tell(&testpb.TestMessage{Message: sender.Address + "/" + strconv.Itoa(i)}), "seed %d", seed)
+				}
+			})
+		}
+		wg.Wait()
+
+		assert.True(t, waitUntil(5*time.Second, func() bool { return total() == 2*messages }), "seed %d: received %d of %d messages", seed, total(), 2*messages)
+		assert.True(t, waitUntil(3*time.Second, func() bool { return len(node.Peers()) == 1 }), "seed %d: sessions should be merged", seed)
+
+		mutex.Lock()
+		for message, count := range received {
+			assert.Equal(t, 1, count, "seed %d: %s should be delivered exactly once", seed, message)
+		}
+		mutex.Unlock()
+
+		sim.stop()
+		node.Close()
+		for _, sender := range senders {
+			sender.Close()
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// spillSegmentBytes is the size past which a spill log starts appending to
+	// a new segment, segments being deleted once all their records were sent.
+	spillSegmentBytes = 4 * 1024 * 1024
+
+	// spillHeaderSize is the size of a spilled record's header: its length and
+	// the CRC-32 of its data, both little endian.
+	spillHeaderSize = 8
+)
+
+// spillSegment is a file holding a run of records of a spill log.
+type spillSegment struct {
+	id      uint64
+	size    int64
+	records int
+}
+
+func (s *spillSegment) path(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d.log", s.id))
+}
+
+// spillLog is an on-disk log of records read back in the order they were
+// appended. Each record is prefixed by its length and a CRC-32 of its data,
+// and records failing their check when read back are skipped.
+type spillLog struct {
+	dir string
+
+	segments []*spillSegment
+	nextID   uint64
+
+	writer *os.File
+
+	// Segment being read from, at offset, alongside the record read ahead.
+	reader *os.File
+	buffer *bufio.Reader
+	offset int64
+	head   []byte
+}
+
+func newSpillLog(dir string) *spillLog {
+	return &spillLog{dir: dir}
+}
+
+// len returns the number of records left to read.
+func (l *spillLog) len() int {
+	records := 0
+	for _, s := range l.segments {
+		records += s.records
+	}
+	return records
+}
+
+// bytes returns the size of the segments left on disk.
+func (l *spillLog) bytes() int64 {
+	var size int64
+	for _, s := range l.segments {
+		size += s.size
+	}
+	return size
+}
+
+// append appends a record to the last segment, starting a new one should the
+// last segment be full.
+func (l *spillLog) append(record []byte) error {
+	if l.writer == nil || l.segments[len(l.segments)-1].size >= spillSegmentBytes {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, spillHeaderSize+len(record))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(record))
+	copy(buf[spillHeaderSize:], record)
+
+	if _, err := l.writer.Write(buf); err != nil {
+		return errors.Wrap(err, "failed to spill record")
+	}
+
+	last := l.segments[len(l.segments)-1]
+	last.size += int64(len(buf))
+	last.records++
+
+	return nil
+}
+
+// rotate starts a new segment to append records to.
+func (l *spillLog) rotate() error {
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return errors.Wrap(err, "failed to create spill directory")
+	}
+
+	segment := &spillSegment{id: l.nextID}
+
+	file, err := os.OpenFile(segment.path(l.dir), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create spill segment")
+	}
+
+	if l.writer != nil {
+		l.writer.Close()
+	}
+
+	l.writer = file
+	l.nextID++
+	l.segments = append(l.segments, segment)
+
+	return nil
+}
+
+// peek returns the next record without consuming it, skipping corrupted
+// records and counting them in skipped.
+func (l *spillLog) peek() (record []byte, skipped int, err error) {
+	for l.head == nil && len(l.segments) > 0 {
+		segment := l.segments[0]
+
+		if l.offset >= segment.size {
+			// The last segment may still be appended to.
+			if len(l.segments) == 1 {
+				break
+			}
+			l.removeHead()
+			continue
+		}
+
+		if l.reader == nil {
+			if l.reader, err = os.Open(segment.path(l.dir)); err != nil {
+				return nil, skipped, errors.Wrap(err, "failed to open spill segment")
+			}
+			if _, err = l.reader.Seek(l.offset, io.SeekStart); err != nil {
+				l.closeReader()
+				return nil, skipped, errors.Wrap(err, "failed to seek spill segment")
+			}
+			l.buffer = bufio.NewReader(l.reader)
+		}
+
+		record, size, corrupted := l.read(segment.size - l.offset)
+		if corrupted {
+			skipped++
+
+			// Records past one of a bogus length can not be found; the rest
+			// of the segment is skipped over.
+			if size < 0 {
+				skipped += segment.records - 1
+				segment.records = 0
+				l.offset = segment.size
+				l.closeReader()
+				continue
+			}
+
+			segment.records--
+			l.offset += size
+			continue
+		}
+
+		l.head = record
+		l.offset += size
+	}
+
+	return l.head, skipped, nil
+}
+
+// read reads the next record of the segment being read from, which has left
+// bytes left. It returns the size the record took up, or -1 should its
+// length not be trusted, and whether the record failed its check.
+func (l *spillLog) read(left int64) (record []byte, size int64, corrupted bool) {
+	var header [spillHeaderSize]byte
+	if _, err := io.ReadFull(l.buffer, header[:]); err != nil {
+		return nil, -1, true
+	}
+
+	length := int64(binary.LittleEndian.Uint32(header[0:4]))
+	if length > left-spillHeaderSize {
+		return nil, -1, true
+	}
+
+	record = make([]byte, length)
+	if _, err := io.ReadFull(l.buffer, record); err != nil {
+		return nil, -1, true
+	}
+
+	if crc32.ChecksumIEEE(record) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, spillHeaderSize + length, true
+	}
+
+	return record, spillHeaderSize + length, false
+}
+
+// pop consumes the record returned by peek, deleting its segment should it
+// be the segment's last record.
+func (l *spillLog) pop() {
+	if l.head == nil {
+		return
+	}
+	l.head = nil
+	l.segments[0].records--
+
+	if l.offset < l.segments[0].size {
+		return
+	}
+
+	// Records appended from then on go to a new segment.
+	if len(l.segments) == 1 && l.writer != nil {
+		l.writer.Close()
+		l.writer = nil
+	}
+	l.removeHead()
+}
+
+// closeReader closes the segment being read from, to be reopened at the
+// current offset.
+func (l *spillLog) closeReader() {
+	if l.reader != nil {
+		l.reader.Close()
+		l.reader, l.buffer = nil, nil
+	}
+}
+
+// removeHead deletes the segment being read from.
+func (l *spillLog) removeHead() {
+	l.closeReader()
+
+	os.Remove(l.segments[0].path(l.dir))
+
+	l.segments = l.segments[1:]
+	l.offset = 0
+}
+
+// remove closes the log and deletes its directory.
+func (l *spillLog) remove() error {
+	l.closeReader()
+	if l.writer != nil {
+		l.writer.Close()
+	}
+
+	l.segments, l.head, l.writer = nil, nil, nil
+
+	return os.RemoveAll(l.dir)
+}
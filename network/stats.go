@@ -0,0 +1,270 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+const (
+	defaultStatsInterval  = 10 * time.Second
+	defaultStatsRetention = 15 * time.Minute
+)
+
+// MessageCounts holds how many messages of a type were sent and received, and
+// how many bytes they occupied on the wire.
+type MessageCounts struct {
+	Sent          uint64
+	Received      uint64
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// Snapshot holds the message counts of every message type at a point in time.
+// Messages sent under a protocol tag are counted by their type's name
+// qualified with the tag, as returned by ProtocolMessageName.
+type Snapshot struct {
+	Time     time.Time
+	Messages map[string]MessageCounts
+}
+
+// MessageRates holds the per-second rates at which messages of a type were
+// sent and received over a window of time.
+type MessageRates struct {
+	Sent          float64
+	Received      float64
+	BytesSent     float64
+	BytesReceived float64
+}
+
+// Rates holds the per-second rates of every message type over a window of
+// time, which is shorter than requested should not enough history be kept.
+type Rates struct {
+	Window   time.Duration
+	Messages map[string]MessageRates
+}
+
+// Diff returns the message counts accrued between snapshots a and b, taken at
+// b's time. Counts which shrunk, as they were reset in between, are taken to
+// have accrued since the reset.
+func Diff(a, b Snapshot) Snapshot {
+	diff := Snapshot{Time: b.Time, Messages: make(map[string]MessageCounts, len(b.Messages))}
+
+	for name, counts := range b.Messages {
+		before := a.Messages[name]
+		diff.Messages[name] = MessageCounts{
+			Sent:          delta(before.Sent, counts.Sent),
+			Received:      delta(before.Received, counts.Received),
+			BytesSent:     delta(before.BytesSent, counts.BytesSent),
+			BytesReceived: delta(before.BytesReceived, counts.BytesReceived),
+		}
+	}
+
+	return diff
+}
+
+func delta(before, after uint64) uint64 {
+	if after < before {
+		return after
+	}
+	return after - before
+}
+
+type messageCounter struct {
+	sent, received, bytesSent, bytesReceived uint64
+}
+
+// add counts a message sent or received.
+func (c *messageCounter) add(direction ConnDirection, size int) {
+	if direction == DirectionOutbound {
+		atomic.AddUint64(&c.sent, 1)
+		atomic.AddUint64(&c.bytesSent, uint64(size))
+	} else {
+		atomic.AddUint64(&c.received, 1)
+		atomic.AddUint64(&c.bytesReceived, uint64(size))
+	}
+}
+
+func (c *messageCounter) counts() MessageCounts {
+	return MessageCounts{
+		Sent:          atomic.LoadUint64(&c.sent),
+		Received:      atomic.LoadUint64(&c.received),
+		BytesSent:     atomic.LoadUint64(&c.bytesSent),
+		BytesReceived: atomic.LoadUint64(&c.bytesReceived),
+	}
+}
+
+// Stats counts messages sent and received by type, and rolls the counts up
+// into snapshots taken every interval for rates over recent windows of time.
+// Rollups only start once the stats are first read.
+type Stats struct {
+	counters sync.Map // message type -> *messageCounter
+
+	now       func() time.Time
+	interval  time.Duration
+	retention time.Duration
+	kill      chan struct{}
+	exec      Executor
+
+	once    sync.Once
+	mutex   sync.Mutex
+	history []Snapshot
+
+	resources *resourceRegistry
+}
+
+func newStats(now func() time.Time, interval, retention time.Duration, kill chan struct{}, exec Executor) *Stats {
+	return &Stats{
+		now:       now,
+		interval:  interval,
+		retention: retention,
+		kill:      kill,
+		exec:      exec,
+	}
+}
+
+// record counts a message sent or received.
+func (s *Stats) record(direction ConnDirection, msg *protobuf.Message, size int) {
+	var name string
+	if msg.Message != nil {
+		name, _ = types.AnyMessageName(msg.Message)
+	}
+	name = ProtocolMessageName(msg.Protocol, name)
+
+	value, exists := s.counters.Load(name)
+	if !exists {
+		value, _ = s.counters.LoadOrStore(name, new(messageCounter))
+	}
+	value.(*messageCounter).add(direction, size)
+}
+
+// start begins rolling up snapshots on first read, until the network shuts
+// down.
+func (s *Stats) start() {
+	s.once.Do(func() {
+		s.rollup(s.now())
+		s.exec.Go(s.rollupLoop)
+	})
+}
+
+func (s *Stats) rollupLoop() {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.kill:
+			return
+		case <-t.C:
+			s.rollup(s.now())
+		}
+	}
+}
+
+// rollup keeps a snapshot should an interval have passed since the last one.
+// Intervals which passed while idle are covered by a single snapshot.
+func (s *Stats) rollup(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.history) > 0 && now.Sub(s.history[len(s.history)-1].Time) < s.interval {
+		return
+	}
+
+	s.history = append(s.history, s.capture(now))
+
+	// Keep a single snapshot older than the retention period, so that rates
+	// over the entire retention period may be computed.
+	expired := 0
+	for expired+1 < len(s.history) && now.Sub(s.history[expired+1].Time) >= s.retention {
+		expired++
+	}
+	s.history = s.history[expired:]
+}
+
+func (s *Stats) capture(now time.Time) Snapshot {
+	snapshot := Snapshot{Time: now, Messages: make(map[string]MessageCounts)}
+
+	s.counters.Range(func(key, value interface{}) bool {
+		snapshot.Messages[key.(string)] = value.(*messageCounter).counts()
+		return true
+	})
+
+	return snapshot
+}
+
+// Snapshot returns the message counts of every message type so far.
+func (s *Stats) Snapshot() Snapshot {
+	s.start()
+
+	now := s.now()
+	s.rollup(now)
+
+	return s.capture(now)
+}
+
+// RateSince returns the per-second rates of every message type over the last
+// d. Rates are computed from the newest snapshot at least d old, or the oldest
+// snapshot kept should there be none.
+func (s *Stats) RateSince(d time.Duration) Rates {
+	current := s.Snapshot()
+
+	s.mutex.Lock()
+	base := s.history[0]
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if current.Time.Sub(s.history[i].Time) >= d {
+			base = s.history[i]
+			break
+		}
+	}
+	s.mutex.Unlock()
+
+	rates := Rates{Window: current.Time.Sub(base.Time), Messages: make(map[string]MessageRates)}
+	if rates.Window <= 0 {
+		return rates
+	}
+
+	seconds := rates.Window.Seconds()
+	for name, counts := range Diff(base, current).Messages {
+		rates.Messages[name] = MessageRates{
+			Sent:          float64(counts.Sent) / seconds,
+			Received:      float64(counts.Received) / seconds,
+			BytesSent:     float64(counts.BytesSent) / seconds,
+			BytesReceived: float64(counts.BytesReceived) / seconds,
+		}
+	}
+
+	return rates
+}
+
+// Reset zeroes all message counts, and discards all snapshots kept so far.
+func (s *Stats) Reset() {
+	s.counters.Range(func(key, value interface{}) bool {
+		s.counters.Delete(key)
+		return true
+	})
+
+	s.mutex.Lock()
+	s.history = nil
+	s.mutex.Unlock()
+
+	s.start()
+	s.rollup(s.now())
+}
+
+// Resources returns the goroutines and file descriptors held by the network
+// in total. They are broken down by subsystem by Network.ResourceReport.
+func (s *Stats) Resources() ResourceUsage {
+	if s.resources == nil {
+		return ResourceUsage{}
+	}
+	return s.resources.report().ResourceUsage
+}
+
+// Stats returns the counts and rates of messages sent and received by type.
+func (n *Network) Stats() *Stats {
+	return n.stats
+}
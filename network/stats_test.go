@@ -0,0 +1,158 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMessageName = "protobuf.TestMessage"
+
+func buildStatsNode(t *testing.T) (*Network, *fakeClock) {
+	node := buildListeningNode(t, StatsRollup(10*time.Second, time.Minute))
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+
+	return node, clock
+}
+
+// sendSynthetic records count messages of size bytes as sent.
+func sendSynthetic(t *testing.T, node *Network, count, size int) {
+	msg, err := node.PrepareMessage(&testpb.TestMessage{Message: "synthetic"})
+	assert.Nil(t, err)
+
+	for i := 0; i < count; i++ {
+		node.stats.record(DirectionOutbound, msg, size)
+	}
+}
+
+func TestStatsRateSince(t *testing.T) {
+	t.Parallel()
+
+	node, clock := buildStatsNode(t)
+	defer node.Close()
+
+	stats := node.Stats()
+	assert.Equal(t, 0, len(stats.Snapshot().Messages))
+
+	// 10 messages per second over the first 30 seconds, then 1 per second.
+	for i := 0; i < 3; i++ {
+		sendSynthetic(t, node, 100, 50)
+		clock.Advance(10 * time.Second)
+		stats.Snapshot()
+	}
+	for i := 0; i < 3; i++ {
+		sendSynthetic(t, node, 10, 50)
+		clock.Advance(10 * time.Second)
+		stats.Snapshot()
+	}
+
+	rates := stats.RateSince(30 * time.Second)
+	assert.Equal(t, 30*time.Second, rates.Window)
+	assert.InDelta(t, 1.0, rates.Messages[testMessageName].Sent, 0.001)
+	assert.InDelta(t, 50.0, rates.Messages[testMessageName].BytesSent, 0.001)
+	assert.Equal(t, 0.0, rates.Messages[testMessageName].Received)
+
+	rates = stats.RateSince(time.Minute)
+	assert.Equal(t, time.Minute, rates.Window)
+	assert.InDelta(t, 5.5, rates.Messages[testMessageName].Sent, 0.001)
+
+	// Windows beyond retention are cut short.
+	sendSynthetic(t, node, 60, 50)
+	clock.Advance(10 * time.Second)
+
+	rates = stats.RateSince(time.Hour)
+	assert.Equal(t, time.Minute, rates.Window)
+	assert.InDelta(t, 290.0/60, rates.Messages[testMessageName].Sent, 0.001)
+
+	assert.Equal(t, uint64(390), stats.Snapshot().Messages[testMessageName].Sent)
+}
+
+func TestStatsIdleIntervals(t *testing.T) {
+	t.Parallel()
+
+	node, clock := buildStatsNode(t)
+	defer node.Close()
+
+	stats := node.Stats()
+	stats.Snapshot()
+
+	// Nobody reads the stats for a while, so no snapshots are taken.
+	sendSynthetic(t, node, 40, 10)
+	clock.Advance(40 * time.Second)
+
+	rates := stats.RateSince(10 * time.Second)
+	assert.Equal(t, 40*time.Second, rates.Window)
+	assert.InDelta(t, 1.0, rates.Messages[testMessageName].Sent, 0.001)
+}
+
+func TestStatsResetAndDiff(t *testing.T) {
+	t.Parallel()
+
+	node, clock := buildStatsNode(t)
+	defer node.Close()
+
+	stats := node.Stats()
+
+	sendSynthetic(t, node, 5, 10)
+	a := stats.Snapshot()
+
+	sendSynthetic(t, node, 3, 10)
+	clock.Advance(time.Second)
+	b := stats.Snapshot()
+
+	diff := Diff(a, b)
+	assert.Equal(t, b.Time, diff.Time)
+	assert.Equal(t, MessageCounts{Sent: 3, BytesSent: 30}, diff.Messages[testMessageName])
+
+	stats.Reset()
+	assert.Equal(t, 0, len(stats.Snapshot().Messages))
+
+	sendSynthetic(t, node, 2, 10)
+	clock.Advance(10 * time.Second)
+
+	// Counts reset in between snapshots accrue from the reset.
+	assert.Equal(t, uint64(2), Diff(b, stats.Snapshot()).Messages[testMessageName].Sent)
+
+	rates := stats.RateSince(10 * time.Second)
+	assert.Equal(t, 10*time.Second, rates.Window)
+	assert.InDelta(t, 0.2, rates.Messages[testMessageName].Sent, 0.001)
+}
+
+func TestStatsCountsMessages(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan struct{}, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- struct{}{}
+	})
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("message never received")
+	}
+
+	sent := sender.Stats().Snapshot().Messages[testMessageName]
+	assert.Equal(t, uint64(1), sent.Sent)
+	assert.True(t, sent.BytesSent > 0)
+
+	assert.Equal(t, uint64(1), receiver.Stats().Snapshot().Messages[testMessageName].Received)
+}
+
+func TestStatsRollupValidated(t *testing.T) {
+	t.Parallel()
+
+	builder := NewBuilderWithOptions(StatsRollup(time.Minute, time.Second))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	_, err := builder.Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// NetworkDegraded describes a disconnect storm: many peers disconnecting at
+// once, as when the network link of this node blips.
+type NetworkDegraded struct {
+	// Disconnects is the number of disconnects which triggered the storm.
+	Disconnects int
+	// Window is the window of time the disconnects happened within.
+	Window time.Duration
+	// Since is when the storm was detected.
+	Since time.Time
+}
+
+// BulkDisconnectPlugin is implemented by plugins which would rather be
+// notified of all peers disconnecting during a storm at once than one by one.
+type BulkDisconnectPlugin interface {
+	PeersDisconnect(clients []*PeerClient)
+}
+
+// stormDetector counts recent disconnects, holding back notifying plugins of
+// them while they arrive in a storm.
+type stormDetector struct {
+	sync.Mutex
+
+	disconnects []time.Time
+	active      bool
+	pending     []*PeerClient
+}
+
+// record records a disconnect, returning true if it happened during a storm
+// and should be held back, and whether the disconnect started the storm.
+func (d *stormDetector) record(c *PeerClient, now time.Time, threshold int, window time.Duration) (held bool, started bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.disconnects = append(d.disconnects, now)
+	d.prune(now, window)
+
+	if !d.active && len(d.disconnects) >= threshold {
+		d.active = true
+		started = true
+	}
+
+	if d.active {
+		d.pending = append(d.pending, c)
+		return true, started
+	}
+
+	return false, false
+}
+
+// prune forgets disconnects older than the window.
+func (d *stormDetector) prune(now time.Time, window time.Duration) {
+	recent := 0
+	for recent < len(d.disconnects) && now.Sub(d.disconnects[recent]) >= window {
+		recent++
+	}
+	d.disconnects = d.disconnects[recent:]
+}
+
+// drain returns the disconnects held back so far, ending the storm once no
+// disconnects happened for a whole window.
+func (d *stormDetector) drain(now time.Time, window time.Duration) (pending []*PeerClient, over bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.prune(now, window)
+
+	pending, d.pending = d.pending, nil
+	if len(d.disconnects) == 0 {
+		d.active = false
+		over = true
+	}
+
+	return pending, over
+}
+
+// peerDisconnected notifies plugins of a peer disconnecting, unless the
+// disconnect is part of a storm, in which case plugins are notified of all
+// disconnects at once every window until the storm passes.
+func (n *Network) peerDisconnected(c *PeerClient) {
+	if n.opts.stormThreshold <= 0 || n.isClosed() {
+		n.notifyDisconnects(c)
+		return
+	}
+
+	now := n.now()
+
+	held, started := n.storm.record(c, now, n.opts.stormThreshold, n.opts.stormWindow)
+	if !held {
+		n.notifyDisconnects(c)
+		return
+	}
+
+	if started {
+		if n.opts.onNetworkDegraded != nil {
+			n.opts.onNetworkDegraded(NetworkDegraded{
+				Disconnects: n.opts.stormThreshold,
+				Window:      n.opts.stormWindow,
+				Since:       now,
+			})
+		}
+
+		n.spawnIn(SubsystemNetwork, n.stormLoop)
+	}
+}
+
+// stormLoop notifies plugins of disconnects held back every window, until the
+// storm passes.
+func (n *Network) stormLoop() {
+	t := time.NewTicker(n.opts.stormWindow)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-n.kill:
+		case <-t.C:
+		}
+
+		pending, over := n.storm.drain(n.now(), n.opts.stormWindow)
+		n.notifyDisconnects(pending...)
+
+		if over || n.isClosed() {
+			return
+		}
+	}
+}
+
+// notifyDisconnects notifies plugins of peers which disconnected, all at once
+// should a plugin implement BulkDisconnectPlugin.
+func (n *Network) notifyDisconnects(clients ...*PeerClient) {
+	if len(clients) == 0 {
+		return
+	}
+
+	n.eachPlugin(func(plugin PluginInterface) {
+		if bulk, ok := plugin.(BulkDisconnectPlugin); ok && len(clients) > 1 {
+			bulk.PeersDisconnect(clients)
+			return
+		}
+
+		for _, client := range clients {
+			plugin.PeerDisconnect(client)
+		}
+	})
+}
+
+// ReconnectSmear returns the window of time over which reconnects to peers
+// which disconnected during a storm should be spread out.
+func (n *Network) ReconnectSmear() time.Duration {
+	return n.opts.stormSmear
+}
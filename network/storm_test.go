@@ -0,0 +1,112 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkDisconnectPlugin records peers disconnecting one by one, and in bulk.
+type bulkDisconnectPlugin struct {
+	*Plugin
+	single chan string
+	bulk   chan []string
+}
+
+func (p *bulkDisconnectPlugin) PeerDisconnect(client *PeerClient) {
+	p.single <- client.Address
+}
+
+func (p *bulkDisconnectPlugin) PeersDisconnect(clients []*PeerClient) {
+	var addresses []string
+	for _, client := range clients {
+		addresses = append(addresses, client.Address)
+	}
+	p.bulk <- addresses
+}
+
+func TestDampDisconnectStorms(t *testing.T) {
+	t.Parallel()
+
+	const window = 500 * time.Millisecond
+
+	disconnects := &bulkDisconnectPlugin{single: make(chan string, 16), bulk: make(chan []string, 16)}
+	degraded := make(chan NetworkDegraded, 4)
+
+	builder := NewBuilderWithOptions(DampDisconnectStorms(3, window, time.Second), OnNetworkDegraded(func(event NetworkDegraded) {
+		degraded <- event
+	}))
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(disconnects)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	go node.Listen()
+	<-node.Ready()
+
+	var clients []*PeerClient
+	for i := 0; i < 6; i++ {
+		peer := buildListeningNode(t)
+		defer peer.Close()
+
+		client, err := node.Client(peer.Address)
+		assert.Nil(t, err)
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+		clients = append(clients, client)
+	}
+
+	// A single disconnect is notified of right away.
+	clients[0].Close()
+
+	select {
+	case address := <-disconnects.single:
+		assert.Equal(t, clients[0].Address, address)
+	case <-time.After(time.Second):
+		t.Fatal("single disconnect was not notified of")
+	}
+
+	// Wait out the window so the disconnect no longer counts towards a storm.
+	time.Sleep(window)
+
+	for _, client := range clients[1:] {
+		client.Close()
+	}
+
+	select {
+	case event := <-degraded:
+		assert.Equal(t, 3, event.Disconnects)
+		assert.Equal(t, window, event.Window)
+	case <-time.After(time.Second):
+		t.Fatal("storm was not detected")
+	}
+
+	// The two disconnects before the storm are notified of right away, and
+	// the rest all at once.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-disconnects.single:
+		case <-time.After(time.Second):
+			t.Fatal("disconnect before the storm was not notified of")
+		}
+	}
+
+	select {
+	case addresses := <-disconnects.bulk:
+		assert.Equal(t, 3, len(addresses))
+	case <-time.After(3 * window):
+		t.Fatal("disconnects during the storm were not notified of")
+	}
+
+	assert.True(t, waitUntil(3*window, func() bool {
+		node.storm.Lock()
+		defer node.storm.Unlock()
+		return !node.storm.active
+	}), "storm should pass")
+
+	assert.Equal(t, 0, len(disconnects.single))
+	assert.Equal(t, 0, len(degraded))
+}
@@ -2,123 +2,247 @@ package network
 
 import (
 	"bufio"
-	"encoding/binary"
 	"io"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/glog"
-	"github.com/perlin-network/noise/crypto"
 	"github.com/perlin-network/noise/internal/protobuf"
 	"github.com/pkg/errors"
 )
 
 var errEmptyMsg = errors.New("received an empty message from a peer")
 
-// sendMessage marshals, signs and sends a message over a stream.
-func (n *Network) sendMessage(w io.Writer, message *protobuf.Message, writerMutex *sync.Mutex) error {
+// maxMalformedFrames is how many malformed frames a connection may carry
+// before it is closed.
+const maxMalformedFrames = 8
+
+// malformedFrameError is the error frames read whole which could not be
+// decoded nor verified fail with. Such frames are skipped, as the next frame
+// starts where they end, rather than closing the connection.
+type malformedFrameError struct {
+	err error
+}
+
+func (e *malformedFrameError) Error() string {
+	return e.err.Error()
+}
+
+// Cause returns why the frame could not be decoded or verified.
+func (e *malformedFrameError) Cause() error {
+	return e.err
+}
+
+// isMalformedFrame returns true if a frame failed to be received with an
+// error scoped to the frame alone.
+func isMalformedFrame(err error) bool {
+	_, ok := err.(*malformedFrameError)
+	return ok
+}
+
+// receivedMessage is a verified message alongside the frame it was decoded from.
+type receivedMessage struct {
+	*protobuf.Message
+
+	// raw is the signed message exactly as received, excluding its length
+	// prefix. It is never reused by the network once handed out.
+	raw []byte
+
+	receivedAt time.Time
+
+	// padding is the number of bytes of raw the padding stripped off the
+	// message took up.
+	padding int
+
+	// legacy is set for messages received in a legacy frame.
+	legacy bool
+
+	// Memory reserved for the message from the receive budget, released once
+	// refs drops to zero.
+	budget   *receiveBudget
+	reserved int
+	refs     int32 // for atomic ops
+	own      ownership
+}
+
+// sendMessage marshals and sends a signed message over a stream followed by
+// a tail of serialized fields, if any, returning the frame written without its
+// tail.
+func (n *Network) sendMessage(w io.Writer, message *protobuf.Message, writerMutex *sync.Mutex, flow *sendFlow, tail []byte) ([]byte, error) {
 	bytes, err := proto.Marshal(message)
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal message")
+		return nil, errors.Wrap(err, "failed to marshal message")
 	}
 
-	// Serialize size.
-	buffer := make([]byte, 4)
-	binary.BigEndian.PutUint32(buffer, uint32(len(bytes)))
+	return bytes, n.writeFrameParts(w, writerMutex, flow, bytes, tail)
+}
+
+// writeFrame writes out a serialized message prefixed with its size.
+func (n *Network) writeFrame(w io.Writer, bytes []byte, writerMutex *sync.Mutex) error {
+	return n.writeFrameParts(w, writerMutex, nil, bytes, nil)
+}
 
-	buffer = append(buffer, bytes...)
-	totalSize := len(buffer)
+// writeFrameParts writes out a serialized message made up of a body and a
+// tail, prefixed with its size. Neither part is copied nor modified, so that
+// a body may be shared by the frames written to many peers. How many bytes
+// the stream's buffer holds is recorded to flow, if any.
+func (n *Network) writeFrameParts(w io.Writer, writerMutex *sync.Mutex, flow *sendFlow, body []byte, tail []byte) error {
+	return n.writeParts(w, writerMutex, flow, n.frameCodec(false).header(len(body)+len(tail)), body, tail)
+}
 
-	// Write until all bytes have been written.
-	bytesWritten, totalBytesWritten := 0, 0
+// writeParts writes out the header, body and tail of a frame.
+func (n *Network) writeParts(w io.Writer, writerMutex *sync.Mutex, flow *sendFlow, header []byte, body []byte, tail []byte) error {
+	totalSize := len(header) + len(body) + len(tail)
 
 	writerMutex.Lock()
+	defer writerMutex.Unlock()
 
 	bw, isBuffered := w.(*bufio.Writer)
+	if isBuffered {
+		defer func() { flow.setBuffered(bw.Buffered()) }()
+	}
 	if isBuffered && (bw.Buffered() > 0) && (bw.Available() < totalSize) {
 		if err := bw.Flush(); err != nil {
-			return err
+			return errors.Wrap(err, "stream: failed to write to socket")
+		}
+	}
+
+	for _, part := range [][]byte{header, body, tail} {
+		// Write until all bytes have been written.
+		for totalBytesWritten := 0; totalBytesWritten < len(part); {
+			bytesWritten, err := w.Write(part[totalBytesWritten:])
+			if err != nil {
+				glog.Errorf("stream: failed to write entire buffer, err: %+v\n", err)
+				return errors.Wrap(err, "stream: failed to write to socket")
+			}
+			totalBytesWritten += bytesWritten
 		}
 	}
 
-	for totalBytesWritten < len(buffer) && err == nil {
-		bytesWritten, err = w.Write(buffer[totalBytesWritten:])
-		if err != nil {
-			glog.Errorf("stream: failed to write entire buffer, err: %+v\n", err)
+	// Light nodes run no flusher, and so flush frames out as they are written.
+	if isBuffered && n.opts.light {
+		if err := bw.Flush(); err != nil {
+			return errors.Wrap(err, "stream: failed to write to socket")
 		}
-		totalBytesWritten += bytesWritten
 	}
 
-	writerMutex.Unlock()
+	return nil
+}
+
+// receiveMessage reads, unmarshals and verifies a message from a net.Conn,
+// framed as legacy peers frame them if legacy is set. Waiting for the header
+// of the message falls under the read idle timeout, and reading its body
+// under the read body timeout.
+func (n *Network) receiveMessage(conn net.Conn, remote []byte, legacy bool) (*receivedMessage, error) {
+	idleTimeout := n.opts.readIdleTimeout
+	if idleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+	} else if n.opts.readBodyTimeout > 0 {
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	// A header holding no size, such as one never read as the connection
+	// closed, is reported as an empty message.
+	_, size, err := n.frameCodec(legacy).readHeader(conn)
+	if size == 0 {
+		return nil, errEmptyMsg
+	}
 
 	if err != nil {
-		return errors.Wrap(err, "stream: failed to write to socket")
+		return nil, err
 	}
 
-	return nil
+	// Pause reading until the message fits within the receive budget.
+	reserved := n.budget.reserve(int(size))
+
+	// Time spent waiting on the receive budget does not count against the
+	// body deadline.
+	if bodyTimeout := n.readBodyTimeout(int(size)); bodyTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(bodyTimeout))
+	} else if idleTimeout > 0 {
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	frame, err := n.readMessage(conn, uint32(size), remote, legacy)
+	if err != nil {
+		n.budget.release(reserved)
+		return nil, err
+	}
+
+	frame.budget = n.budget
+	frame.reserved = reserved
+	frame.refs = 1
+	frame.own.acquire(&n.owned, "received frame")
+
+	return frame, nil
 }
 
-// receiveMessage reads, unmarshals and verifies a message from a net.Conn.
-func (n *Network) receiveMessage(conn net.Conn) (*protobuf.Message, error) {
+// readMessage reads, unmarshals and validates the body of a message sent by
+// the peer holding a public key, in a legacy frame if legacy is set.
+func (n *Network) readMessage(conn net.Conn, size uint32, remote []byte, legacy bool) (*receivedMessage, error) {
 	var err error
 
-	// Read until all header bytes have been read.
-	buffer := make([]byte, 4)
+	// Read until all message bytes have been read.
+	buffer := make([]byte, size)
 
 	bytesRead, totalBytesRead := 0, 0
 
-	for totalBytesRead < 4 && err == nil {
+	for totalBytesRead < int(size) && err == nil {
 		bytesRead, err = conn.Read(buffer[totalBytesRead:])
 		totalBytesRead += bytesRead
 	}
 
-	// Decode message size.
-	size := binary.BigEndian.Uint32(buffer)
-
-	if size == 0 {
-		return nil, errEmptyMsg
+	if totalBytesRead < int(size) {
+		return nil, errors.Wrap(err, "failed to read message body")
 	}
 
-	// Message size at most is limited to 4MB. If a big message need be sent,
-	// consider partitioning to message into chunks of 4MB.
-	if size > 4e+6 {
-		return nil, errors.Errorf("message has length of %d which is either broken or too large", size)
+	receivedAt := time.Now()
+
+	validate := n.validateFrame
+	if legacy {
+		validate = n.validateLegacyFrame
 	}
 
-	// Read until all message bytes have been read.
-	buffer = make([]byte, size)
+	msg, err := validate(conn.RemoteAddr(), remote, buffer)
+	if msg != nil {
+		n.tailMessage(DirectionInbound, msg.Sender.Address, msg, int(size)+frameHeaderSize(legacy), err == nil)
+		n.captureFrame(DirectionInbound, msg.Sender.Address, msg.Sender.PublicKey, buffer, err == nil, receivedAt)
+		n.mirrorFrame(DirectionInbound, msg.Sender.Address, msg.Sender.PublicKey, msg, err == nil, receivedAt, buffer)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	bytesRead, totalBytesRead = 0, 0
+	return &receivedMessage{Message: msg, raw: buffer, receivedAt: receivedAt, padding: n.stripPadding(msg), legacy: legacy}, nil
+}
 
-	for totalBytesRead < int(size) && err == nil {
-		bytesRead, err = conn.Read(buffer[totalBytesRead:])
-		totalBytesRead += bytesRead
+// decodeMessage unmarshals a message received, and verifies its signature.
+func (n *Network) decodeMessage(buffer []byte) (*protobuf.Message, bool, error) {
+	msg, err := decodeEnvelope(buffer)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// Deserialize message.
+	// Verify signature of message.
+	return msg, n.verifyMessage(msg), nil
+}
+
+// decodeEnvelope unmarshals the envelope of a message received, leaving its
+// payload encoded, and checks that none of its headers are missing. Senders
+// may advertise no address, which only ephemeral peers are allowed to.
+func decodeEnvelope(buffer []byte) (*protobuf.Message, error) {
 	msg := new(protobuf.Message)
 
-	err = proto.Unmarshal(buffer, msg)
-	if err != nil {
+	if err := proto.Unmarshal(buffer, msg); err != nil {
 		return nil, errors.Wrap(err, "failed to unmarshal message")
 	}
 
 	// Check if any of the message headers are invalid or null.
-	if msg.Message == nil || msg.Sender == nil || msg.Sender.PublicKey == nil || len(msg.Sender.Address) == 0 || msg.Signature == nil {
+	if msg.Message == nil || msg.Sender == nil || msg.Sender.PublicKey == nil || (msg.Signature == nil && len(msg.Signatures) == 0) {
 		return nil, errors.New("received an invalid message (either no message, no sender, or no signature) from a peer")
 	}
 
-	// Verify signature of message.
-	if !crypto.Verify(
-		n.opts.signaturePolicy,
-		n.opts.hashPolicy,
-		msg.Sender.PublicKey,
-		SerializeMessage(msg.Sender, msg.Message.Value),
-		msg.Signature,
-	) {
-		return nil, errors.New("received message had an malformed signature")
-	}
-
 	return msg, nil
 }
@@ -0,0 +1,205 @@
+package network
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+// relay relays a single connection to a target address, handing out the
+// connection to the target so that raw bytes may be injected into it. The
+// returned channel is closed once the target hangs up.
+func relay(t *testing.T, target string) (string, <-chan net.Conn, <-chan struct{}) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	targetInfo, err := ParseAddress(target)
+	assert.Nil(t, err)
+
+	conns := make(chan net.Conn, 1)
+	closed := make(chan struct{})
+
+	go func() {
+		defer listener.Close()
+
+		in, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer in.Close()
+
+		out, err := net.Dial("tcp", targetInfo.HostPort())
+		if err != nil {
+			return
+		}
+		defer out.Close()
+		conns <- out
+
+		go io.Copy(out, in)
+
+		io.Copy(in, out)
+		close(closed)
+	}()
+
+	return FormatAddress("tcp", "127.0.0.1", uint16(listener.Addr().(*net.TCPAddr).Port)), conns, closed
+}
+
+func TestReadBodyDeadlineCutsOffStalledSenders(t *testing.T) {
+	t.Parallel()
+
+	const bodyTimeout = 300 * time.Millisecond
+
+	// Peers are admitted once the receiver read the whole of their handshake.
+	admitted := make(chan struct{}, 2)
+	admit := ValidatePeerMetadata(func(PeerInfo, map[string][]byte) error {
+		admitted <- struct{}{}
+		return nil
+	})
+
+	received := make(chan string, 1)
+	builder := NewBuilderWithOptions(ReadBodyTimeout(bodyTimeout, 0), admit)
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	// A sender which writes the length prefix of a message, and then stalls,
+	// is cut off by the body deadline.
+	stalled := buildListeningNode(t)
+	defer stalled.Close()
+
+	address, conns, closed := relay(t, receiver.Address)
+	_, err = stalled.Client(address)
+	assert.Nil(t, err)
+	<-admitted
+
+	start := time.Now()
+	_, err = (<-conns).Write([]byte{0, 0, 1, 0})
+	assert.Nil(t, err)
+
+	select {
+	case <-closed:
+		assert.True(t, time.Since(start) >= bodyTimeout)
+	case <-time.After(3 * bodyTimeout):
+		t.Fatal("stalled sender should have been cut off by the body deadline")
+	}
+
+	// A completely idle connection survives past the body deadline.
+	idle := buildListeningNode(t)
+	defer idle.Close()
+
+	address, _, closed = relay(t, receiver.Address)
+	client, err := idle.Client(address)
+	assert.Nil(t, err)
+	<-admitted
+
+	select {
+	case <-closed:
+		t.Fatal("idle connection should not have been closed")
+	case <-time.After(3 * bodyTimeout):
+	}
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "still here"}))
+	select {
+	case message := <-received:
+		assert.Equal(t, "still here", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message sent over the idle connection was not delivered")
+	}
+}
+
+func TestReadIdleDeadlineClosesIdleConnections(t *testing.T) {
+	t.Parallel()
+
+	const idleTimeout = 300 * time.Millisecond
+
+	receiver := buildListeningNode(t, ReadIdleTimeout(idleTimeout))
+	defer receiver.Close()
+
+	idle := buildListeningNode(t)
+	defer idle.Close()
+
+	address, _, closed := relay(t, receiver.Address)
+	_, err := idle.Client(address)
+	assert.Nil(t, err)
+
+	start := time.Now()
+	select {
+	case <-closed:
+		assert.True(t, time.Since(start) >= idleTimeout/2)
+	case <-time.After(3 * time.Second):
+		t.Fatal("idle connection should have been closed by the idle deadline")
+	}
+}
+
+func TestReadBodyTimeoutScalesWithSize(t *testing.T) {
+	t.Parallel()
+
+	n := &Network{opts: options{readBodyTimeout: time.Second, readBodyMinRate: 1024 * 1024}}
+	assert.Equal(t, time.Second, n.readBodyTimeout(0))
+	assert.Equal(t, 3*time.Second, n.readBodyTimeout(2*1024*1024))
+
+	// Without a base, there is no deadline regardless of size.
+	n = &Network{opts: options{readBodyMinRate: 1024}}
+	assert.Equal(t, time.Duration(0), n.readBodyTimeout(1024))
+}
+
+func TestMalformedFramesKeepSessionUp(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 2)
+	violations := make(chan error, 1)
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- ctx.Message().(*testpb.TestMessage).Message
+	}, OnViolation(func(client *PeerClient, err error) {
+		violations <- err
+	}))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "first"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "first", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("first message was never delivered")
+	}
+
+	// A frame whose body is not an envelope, framed correctly nonetheless.
+	state, ok := sender.ConnectionState(receiver.Address)
+	assert.True(t, ok)
+	assert.Nil(t, sender.writeFrame(state.writer, []byte{0xff, 0xff, 0xff, 0xff}, state.writerMutex))
+
+	select {
+	case err := <-violations:
+		assert.True(t, isMalformedFrame(err))
+	case <-time.After(3 * time.Second):
+		t.Fatal("malformed frame was not reported")
+	}
+
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "second"}))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "second", msg)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message following a malformed frame was never delivered")
+	}
+
+	assert.Equal(t, "", client.DisconnectReason())
+	assert.True(t, receiver.ConnectionStateExists(sender.Address))
+}
@@ -0,0 +1,373 @@
+package network
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// DisconnectEvicted is the reason peers evicted to make room for a peer
+// holding a protected tag are disconnected for.
+const DisconnectEvicted = "evicted"
+
+// ErrEmptyTag is returned when tagging a peer with an empty tag.
+var ErrEmptyTag = errors.New("network: peer tags must not be empty")
+
+// PeerFilter selects peers by what is known about them, such as the tags
+// they were given.
+type PeerFilter func(info PeerInfo) bool
+
+// HasTag returns a PeerFilter selecting peers tagged with tag.
+func HasTag(tag string) PeerFilter {
+	return func(info PeerInfo) bool {
+		return containsString(info.Tags, tag)
+	}
+}
+
+// LacksTag returns a PeerFilter selecting peers not tagged with tag.
+func LacksTag(tag string) PeerFilter {
+	return func(info PeerInfo) bool {
+		return !containsString(info.Tags, tag)
+	}
+}
+
+// bandwidthLimit is a rate of bytes per second, allowing bursts of up to
+// burst bytes. A rate of 0 leaves bandwidth unlimited.
+type bandwidthLimit struct {
+	rate  int
+	burst int
+}
+
+// peerTags holds the tags the application gave peers, keyed by their public
+// keys so that they outlive connections. Tags are never sent to peers.
+type peerTags struct {
+	sync.RWMutex
+	tags map[PeerID]map[string]struct{}
+
+	// counters count the traffic of peers while they hold a tag, by tag.
+	counters map[string]*messageCounter
+
+	// tagged is the number of peers holding any tag, so that counting the
+	// traffic of untagged networks takes no lock.
+	tagged int32
+}
+
+// of returns the tags of a peer in sorted order, or nil should it hold none.
+func (t *peerTags) of(id PeerID) []string {
+	t.RLock()
+	defer t.RUnlock()
+
+	held := t.tags[id]
+	if len(held) == 0 {
+		return nil
+	}
+
+	tags := make([]string, 0, len(held))
+	for tag := range held {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// holdsAny returns true if a peer holds any of a set of tags.
+func (t *peerTags) holdsAny(id PeerID, tags map[string]struct{}) bool {
+	if len(tags) == 0 || atomic.LoadInt32(&t.tagged) == 0 {
+		return false
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+
+	for tag := range t.tags[id] {
+		if _, exists := tags[tag]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// TagPeer tags a peer by its ID, whether or not it is connected. Tags are
+// purely local, and are kept across reconnects until the peer is untagged.
+func (n *Network) TagPeer(id PeerID, tag string) error {
+	if err := id.Validate(); err != nil {
+		return err
+	}
+	if tag == "" {
+		return ErrEmptyTag
+	}
+
+	n.tags.Lock()
+
+	if n.tags.tags == nil {
+		n.tags.tags = make(map[PeerID]map[string]struct{})
+		n.tags.counters = make(map[string]*messageCounter)
+	}
+
+	held, exists := n.tags.tags[id]
+	if !exists {
+		held = make(map[string]struct{})
+		n.tags.tags[id] = held
+		atomic.AddInt32(&n.tags.tagged, 1)
+	}
+
+	_, already := held[tag]
+	held[tag] = struct{}{}
+
+	if _, exists := n.tags.counters[tag]; !exists {
+		n.tags.counters[tag] = &messageCounter{}
+	}
+
+	n.tags.Unlock()
+
+	if !already {
+		n.peerRetagged(id, tag, true)
+	}
+	return nil
+}
+
+// UntagPeer removes a tag from a peer, if it holds it.
+func (n *Network) UntagPeer(id PeerID, tag string) {
+	n.tags.Lock()
+
+	held := n.tags.tags[id]
+	_, exists := held[tag]
+	if exists {
+		delete(held, tag)
+		if len(held) == 0 {
+			delete(n.tags.tags, id)
+			atomic.AddInt32(&n.tags.tagged, -1)
+		}
+	}
+
+	n.tags.Unlock()
+
+	if exists {
+		n.peerRetagged(id, tag, false)
+	}
+}
+
+// PeerTags returns the tags of a peer in sorted order.
+func (n *Network) PeerTags(id PeerID) []string {
+	return n.tags.of(id)
+}
+
+// PeersWithTag returns the IDs of all peers tagged with tag, connected or
+// not, sorted by their hashes.
+func (n *Network) PeersWithTag(tag string) []PeerID {
+	n.tags.RLock()
+
+	var ids []PeerID
+	for id, held := range n.tags.tags {
+		if _, exists := held[tag]; exists {
+			ids = append(ids, id)
+		}
+	}
+
+	n.tags.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Less(ids[j])
+	})
+	return ids
+}
+
+// TagStats returns the messages sent to and received from peers while they
+// were tagged with tag, which keep accumulating after they are untagged.
+func (n *Network) TagStats(tag string) MessageCounts {
+	n.tags.RLock()
+	defer n.tags.RUnlock()
+
+	if counter, exists := n.tags.counters[tag]; exists {
+		return counter.counts()
+	}
+	return MessageCounts{}
+}
+
+// countTraffic counts a message sent to or received from a peer against the
+// peer and every tag it holds.
+func (n *Network) countTraffic(client *PeerClient, direction ConnDirection, size int) {
+	client.traffic.add(direction, size)
+
+	if atomic.LoadInt32(&n.tags.tagged) == 0 || client.publicKey == nil {
+		return
+	}
+
+	n.tags.RLock()
+	defer n.tags.RUnlock()
+
+	for tag := range n.tags.tags[PeerID{publicKey: string(client.publicKey)}] {
+		n.tags.counters[tag].add(direction, size)
+	}
+}
+
+// peerRetagged applies the limits overridden for the tags of a connected
+// peer whose tags changed, and notifies the application.
+func (n *Network) peerRetagged(id PeerID, tag string, tagged bool) {
+	if client, connected := n.PeerByID(id); connected {
+		if _, overridden := n.opts.tagRateLimits[tag]; overridden {
+			n.limiter.retune(time.Now(), client)
+		}
+
+		if _, overridden := n.opts.tagBandwidth[tag]; overridden {
+			if state, ok := n.ConnectionState(client.Address); ok {
+				n.peerBandwidthMutex.Lock()
+				limit := n.tagBandwidthLimit(client.publicKey, bandwidthLimit{rate: n.peerBandwidth, burst: n.peerBandwidthBurst})
+				n.peerBandwidthMutex.Unlock()
+
+				state.bandwidth.set(time.Now(), limit.rate, limit.burst)
+			}
+		}
+	}
+
+	if n.opts.onPeerTagged != nil {
+		n.opts.onPeerTagged(id, tag, tagged)
+	}
+
+	header := EventHeader{Peer: id}
+	if client, exists := n.PeerByID(id); exists {
+		header.Address = client.Address
+	}
+	n.publishEvent(&PeerTaggedEvent{EventHeader: header, Tag: tag, Tagged: tagged})
+
+	if strings.HasPrefix(tag, groupTagPrefix) {
+		n.refreshGroupHealth()
+	}
+}
+
+// tagRateLimit returns the limit on the rate of messages read from a peer,
+// being the loosest of the limits overridden for its tags, or otherwise
+// limit.
+func (n *Network) tagRateLimit(client *PeerClient, limit RateLimit) RateLimit {
+	if len(n.opts.tagRateLimits) == 0 || client.publicKey == nil {
+		return limit
+	}
+
+	overridden := false
+	for _, tag := range n.tags.of(PeerID{publicKey: string(client.publicKey)}) {
+		override, exists := n.opts.tagRateLimits[tag]
+		if !exists {
+			continue
+		}
+
+		if !overridden || looser(override.Rate, override.Burst, limit.Rate, limit.Burst) {
+			limit = override
+		}
+		overridden = true
+	}
+	return limit
+}
+
+// tagBandwidthLimit returns the outbound bandwidth limit of a peer, being the
+// loosest of the limits overridden for its tags, or otherwise limit.
+func (n *Network) tagBandwidthLimit(publicKey []byte, limit bandwidthLimit) bandwidthLimit {
+	if len(n.opts.tagBandwidth) == 0 || publicKey == nil {
+		return limit
+	}
+
+	overridden := false
+	for _, tag := range n.tags.of(PeerID{publicKey: string(publicKey)}) {
+		override, exists := n.opts.tagBandwidth[tag]
+		if !exists {
+			continue
+		}
+
+		if !overridden || looser(override.rate, override.burst, limit.rate, limit.burst) {
+			limit = override
+		}
+		overridden = true
+	}
+	return limit
+}
+
+// looser returns true if a rate and burst allow more through than another,
+// a rate of 0 being unlimited.
+func looser(rate, burst, otherRate, otherBurst int) bool {
+	switch {
+	case otherRate <= 0:
+		return false
+	case rate <= 0:
+		return true
+	case rate != otherRate:
+		return rate > otherRate
+	default:
+		return burst > otherBurst
+	}
+}
+
+// isProtected returns true if a public key belongs to a peer holding any of
+// the tags protected from eviction.
+func (n *Network) isProtected(publicKey []byte) bool {
+	return n.tags.holdsAny(PeerID{publicKey: string(publicKey)}, n.protectedTags)
+}
+
+// evictFor disconnects the least active peer connected in a direction which
+// is neither pinned, reserved nor protected, to make room for a peer holding
+// a protected tag. It returns false should no peer be evictable.
+func (n *Network) evictFor(direction ConnDirection) bool {
+	var victim *PeerClient
+	var victimTraffic uint64
+
+	n.eachPeer(func(client *PeerClient) bool {
+		if client.direction != direction || client.reserved || client.publicKey == nil || client.isClosed() {
+			return true
+		}
+		if !n.ConnectionStateExists(client.Address) || n.isPinnedPeer(client) || n.isProtected(client.publicKey) {
+			return true
+		}
+
+		counts := client.traffic.counts()
+		if traffic := counts.Sent + counts.Received; victim == nil || traffic < victimTraffic {
+			victim, victimTraffic = client, traffic
+		}
+		return true
+	})
+
+	if victim == nil {
+		return false
+	}
+
+	glog.Infof("evicting peer %s to make room for a protected peer", victim.Address)
+	victim.close(DisconnectEvicted)
+
+	return true
+}
+
+// BroadcastFilter asynchronously broadcasts a message to all peers selected
+// by filter, such as those holding a tag. The message is signed only once,
+// and is sent at most once per public key.
+func (n *Network) BroadcastFilter(message proto.Message, filter PeerFilter) {
+	signed, err := n.prepareMessage("", message)
+	if err != nil {
+		return
+	}
+	frame := n.shareFrame(signed)
+
+	sent := make(map[string]struct{})
+
+	n.eachFanoutPeer(func(client *PeerClient) bool {
+		if !filter(client.info()) {
+			return true
+		}
+
+		if id := client.id(); id != nil {
+			key := id.PublicKeyHex()
+			if _, already := sent[key]; already {
+				return true
+			}
+			sent[key] = struct{}{}
+		}
+
+		if err := n.writeBroadcast(client.Address, signed, frame); err != nil {
+			glog.Warningf("failed to send message to peer %v [err=%s]", client.id(), err)
+		}
+		return true
+	})
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func peerIDOfNode(t *testing.T, n *Network) PeerID {
+	id, err := PeerIDFromPublicKey(n.GetKeys().PublicKey)
+	assert.Nil(t, err)
+	return id
+}
+
+func TestEvictionSparesProtectedPeers(t *testing.T) {
+	// MaxPeers(5) leaves two outbound slots and a reserved one.
+	node := buildListeningNode(t, MaxPeers(5), ProtectTags("validator"))
+	defer node.Close()
+
+	var others []*Network
+	for i := 0; i < 5; i++ {
+		other := buildListeningNode(t)
+		defer other.Close()
+		others = append(others, other)
+	}
+
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, others[0]), "validator"))
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, others[2]), "validator"))
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, others[3]), "validator"))
+
+	_, err := node.Client(others[0].Address)
+	assert.Nil(t, err)
+	unprotected, err := node.Client(others[1].Address)
+	assert.Nil(t, err)
+
+	// Strangers are turned away once slots run out.
+	_, err = node.Client(others[4].Address)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	// A protected peer evicts the unprotected one.
+	_, err = node.Client(others[2].Address)
+	assert.Nil(t, err)
+	assert.Equal(t, DisconnectEvicted, unprotected.DisconnectReason())
+
+	// Protected peers are never evicted, not even for one another.
+	_, err = node.Client(others[3].Address)
+	assert.Equal(t, ErrOutboundQuotaExceeded, err)
+
+	connected := make(map[string]bool)
+	for _, info := range node.Peers() {
+		connected[info.Address] = true
+		assert.Equal(t, []string{"validator"}, info.Tags)
+	}
+	assert.Equal(t, map[string]bool{others[0].Address: true, others[2].Address: true}, connected)
+}
+
+func TestBroadcastFilterReachesTaggedPeers(t *testing.T) {
+	t.Parallel()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	var mu sync.Mutex
+	received := make(map[int][]string)
+
+	var receivers []*Network
+	for i := 0; i < 4; i++ {
+		i := i
+
+		builder := NewBuilder()
+		builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+		builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+			if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+				mu.Lock()
+				received[i] = append(received[i], msg.Message)
+				mu.Unlock()
+			}
+		}})
+		receiver, err := builder.Build()
+		assert.Nil(t, err)
+		defer receiver.Close()
+
+		go receiver.Listen()
+		<-receiver.Ready()
+
+		_, err = node.Client(receiver.Address)
+		assert.Nil(t, err)
+
+		receivers = append(receivers, receiver)
+	}
+
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, receivers[1]), "customer"))
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, receivers[3]), "customer"))
+	assert.Nil(t, node.TagPeer(peerIDOfNode(t, receivers[3]), "volunteer"))
+
+	node.BroadcastFilter(&testpb.TestMessage{Message: "customers"}, HasTag("customer"))
+	node.BroadcastFilter(&testpb.TestMessage{Message: "others"}, LacksTag("customer"))
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 4
+	}), "broadcasts were never received")
+
+	// Give stray copies a chance to arrive.
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, map[int][]string{
+		0: {"others"},
+		1: {"customers"},
+		2: {"others"},
+		3: {"customers"},
+	}, received)
+}
+
+func TestTagStatsAccumulate(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var events []string
+
+	receiver, sender, client := connectWithHandler(t, func(ctx *PluginContext) {})
+	defer receiver.Close()
+	defer sender.Close()
+
+	sender.opts.onPeerTagged = func(id PeerID, tag string, tagged bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if tagged {
+			events = append(events, "+"+tag)
+		} else {
+			events = append(events, "-"+tag)
+		}
+	}
+	sender.opts.tagBandwidth = map[string]bandwidthLimit{"customer": {rate: 1 << 20, burst: 1 << 20}}
+
+	id := peerIDOfNode(t, receiver)
+	assert.Nil(t, sender.TagPeer(id, "customer"))
+	assert.Nil(t, sender.TagPeer(id, "customer"))
+	assert.Nil(t, receiver.TagPeer(peerIDOfNode(t, sender), "supplier"))
+	assert.Equal(t, ErrEmptyTag, sender.TagPeer(id, ""))
+
+	// Limits overridden for a tag apply as soon as a peer is tagged.
+	state, ok := sender.ConnectionState(receiver.Address)
+	assert.True(t, ok)
+	assert.Equal(t, float64(1<<20), state.bandwidth.rate)
+
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "tagged"}))
+	}
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return sender.TagStats("customer").Sent == 5 && receiver.TagStats("supplier").Received == 5
+	}), "tagged traffic was never counted")
+
+	// Traffic is only counted against tags while peers hold them.
+	sender.UntagPeer(id, "customer")
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "untagged"}))
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		return client.Info().Traffic.Sent == 6
+	}), "untagged traffic was never counted")
+
+	stats := sender.TagStats("customer")
+	assert.Equal(t, uint64(5), stats.Sent)
+	assert.True(t, stats.BytesSent > 0)
+	assert.Equal(t, MessageCounts{}, sender.TagStats("volunteer"))
+	assert.Equal(t, float64(0), state.bandwidth.rate)
+
+	assert.Empty(t, sender.PeersWithTag("customer"))
+	assert.Equal(t, []PeerID{peerIDOfNode(t, sender)}, receiver.PeersWithTag("supplier"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"+customer", "-customer"}, events)
+}
@@ -0,0 +1,205 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+const (
+	defaultTailBufferSize     = 256
+	defaultTailMaxPayloadSize = 4096
+)
+
+// MessageSummary describes a single message sent or received by the network.
+type MessageSummary struct {
+	// Direction is DirectionInbound for received messages, and DirectionOutbound for sent ones.
+	Direction ConnDirection
+	// Peer is the address of the peer the message was sent to or received from.
+	Peer string
+	// Type is the fully-qualified protobuf name of the message.
+	Type string
+	// Size is the number of bytes the message occupies on the wire.
+	Size int
+	// RequestNonce correlates requests with their replies. Zero otherwise.
+	RequestNonce uint64
+	// Reply is true if the message is a reply to a request.
+	Reply bool
+	// Verified is false if the message's signature failed verification.
+	Verified bool
+	// Time is when the message was sent or received.
+	Time time.Time
+	// Payload holds the encoded message, should the filter have opted in.
+	Payload []byte
+}
+
+// TailFilter selects which messages are summarized for a tail. Empty fields
+// match everything.
+type TailFilter struct {
+	// Peers limits the tail to messages exchanged with the given addresses.
+	Peers []string
+	// Types limits the tail to messages of the given fully-qualified protobuf names.
+	Types []string
+	// Directions limits the tail to either sent or received messages.
+	Directions []ConnDirection
+
+	// IncludePayload attaches the encoded message to summaries of messages no
+	// larger than MaxPayloadSize (default: 4096 bytes).
+	IncludePayload bool
+	MaxPayloadSize int
+
+	// BufferSize is the number of summaries buffered before further ones are
+	// dropped (default: 256).
+	BufferSize int
+}
+
+func (f *TailFilter) matches(summary *MessageSummary) bool {
+	if len(f.Peers) > 0 && !containsString(f.Peers, summary.Peer) {
+		return false
+	}
+
+	if len(f.Types) > 0 && !containsString(f.Types, summary.Type) {
+		return false
+	}
+
+	if len(f.Directions) > 0 {
+		found := false
+		for _, direction := range f.Directions {
+			if direction == summary.Direction {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MessageTail is a live stream of summaries of messages matching a filter.
+type MessageTail struct {
+	// C delivers summaries, and is closed once the tail is unsubscribed.
+	C <-chan MessageSummary
+
+	ch      chan MessageSummary
+	filter  TailFilter
+	dropped uint64 // for atomic ops
+}
+
+// Dropped returns the number of summaries dropped for not being consumed in time.
+func (t *MessageTail) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// tails keeps track of all subscribers to the network's messages.
+type tails struct {
+	sync.RWMutex
+	count int32 // for atomic ops
+	subs  map[*MessageTail]struct{}
+}
+
+// TailMessages subscribes to summaries of all messages sent or received
+// matching a filter, until ctx is cancelled or the network is closed. A slow
+// consumer never holds up the network; summaries which do not fit in the
+// tail's buffer are dropped and counted instead.
+func (n *Network) TailMessages(ctx context.Context, filter TailFilter) *MessageTail {
+	if filter.BufferSize <= 0 {
+		filter.BufferSize = defaultTailBufferSize
+	}
+	if filter.MaxPayloadSize <= 0 {
+		filter.MaxPayloadSize = defaultTailMaxPayloadSize
+	}
+
+	ch := make(chan MessageSummary, filter.BufferSize)
+	tail := &MessageTail{C: ch, ch: ch, filter: filter}
+
+	n.tails.Lock()
+	if n.tails.subs == nil {
+		n.tails.subs = make(map[*MessageTail]struct{})
+	}
+	n.tails.subs[tail] = struct{}{}
+	atomic.AddInt32(&n.tails.count, 1)
+	n.tails.Unlock()
+
+	n.spawn(func() {
+		select {
+		case <-ctx.Done():
+		case <-n.kill:
+		}
+
+		n.tails.Lock()
+		delete(n.tails.subs, tail)
+		atomic.AddInt32(&n.tails.count, -1)
+		close(tail.ch)
+		n.tails.Unlock()
+	})
+
+	return tail
+}
+
+// tailMessage counts a message sent or received, and hands a summary of it to
+// all subscribed tails.
+func (n *Network) tailMessage(direction ConnDirection, peer string, msg *protobuf.Message, size int, verified bool) {
+	n.stats.record(direction, msg, size)
+
+	// Messages received are counted against their sender once its client is
+	// known, in the loop reading them off its connection.
+	if direction == DirectionOutbound {
+		if client, exists := n.peers.Load(peer); exists {
+			n.countTraffic(client.(*PeerClient), direction, size)
+		}
+	}
+
+	if atomic.LoadInt32(&n.tails.count) == 0 {
+		return
+	}
+
+	summary := MessageSummary{
+		Direction:    direction,
+		Peer:         peer,
+		Size:         size,
+		RequestNonce: msg.RequestNonce,
+		Reply:        msg.ReplyFlag,
+		Verified:     verified,
+		Time:         time.Now(),
+	}
+
+	if msg.Message != nil {
+		summary.Type, _ = types.AnyMessageName(msg.Message)
+	}
+
+	n.tails.RLock()
+	defer n.tails.RUnlock()
+
+	for tail := range n.tails.subs {
+		if !tail.filter.matches(&summary) {
+			continue
+		}
+
+		s := summary
+		if tail.filter.IncludePayload && msg.Message != nil && len(msg.Message.Value) <= tail.filter.MaxPayloadSize {
+			s.Payload = append([]byte(nil), msg.Message.Value...)
+		}
+
+		select {
+		case tail.ch <- s:
+		default:
+			atomic.AddUint64(&tail.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/internal/protobuf"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailMessagesFiltersByType(t *testing.T) {
+	t.Parallel()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testName := proto.MessageName(&testpb.TestMessage{})
+
+	tail := receiver.TailMessages(ctx, TailFilter{
+		Types:          []string{testName},
+		IncludePayload: true,
+	})
+	everything := receiver.TailMessages(ctx, TailFilter{})
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		assert.Nil(t, client.Tell(&protobuf.Ping{}))
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "tailed"}))
+	}
+
+	for i := 0; i < count; i++ {
+		select {
+		case summary := <-tail.C:
+			assert.Equal(t, testName, summary.Type)
+			assert.Equal(t, DirectionInbound, summary.Direction)
+			assert.Equal(t, sender.Address, summary.Peer)
+			assert.True(t, summary.Verified)
+			assert.True(t, summary.Size > 0)
+
+			msg := new(testpb.TestMessage)
+			assert.Nil(t, proto.Unmarshal(summary.Payload, msg))
+			assert.Equal(t, "tailed", msg.Message)
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for summary")
+		}
+	}
+
+	// The unfiltered subscriber sees the pings too.
+	for i := 0; i < 2*count; i++ {
+		select {
+		case <-everything.C:
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for summary")
+		}
+	}
+
+	select {
+	case summary := <-tail.C:
+		t.Fatalf("unexpected summary of a %s", summary.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case _, open := <-tail.C:
+		assert.False(t, open, "tail should be closed once its context is cancelled")
+	case <-time.After(time.Second):
+		t.Fatal("tail was not closed once its context was cancelled")
+	}
+}
+
+func TestTailMessagesCountsDrops(t *testing.T) {
+	t.Parallel()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tail := sender.TailMessages(ctx, TailFilter{
+		Directions: []ConnDirection{DirectionOutbound},
+		BufferSize: 1,
+	})
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "unread"}))
+	}
+
+	assert.Equal(t, 1, len(tail.C))
+	assert.Equal(t, uint64(9), tail.Dropped())
+}
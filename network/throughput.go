@@ -0,0 +1,157 @@
+package network
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// minThroughputSamples is the number of socket writes observed before
+	// write deadlines adapt to a peer's throughput.
+	minThroughputSamples = 3
+	// minThroughputSampleSize is the smallest socket write worth observing;
+	// smaller writes measure syscall overhead rather than throughput.
+	minThroughputSampleSize = 8 * 1024
+	// throughputIdlePeriod is how long a peer may stay idle before half of the
+	// confidence in its estimated throughput is lost.
+	throughputIdlePeriod = 30 * time.Second
+	// throughputSmoothing is the weight of each new sample in the estimate.
+	throughputSmoothing = 0.3
+)
+
+// throughputEstimator tracks a moving average of the rate at which writes to a
+// peer make it onto the wire.
+type throughputEstimator struct {
+	sync.Mutex
+
+	rate    float64 // bytes per second
+	samples int
+	last    time.Time
+}
+
+// decay discards confidence in the estimate for every idle period elapsed
+// since the last sample.
+func (e *throughputEstimator) decay(now time.Time) {
+	if e.samples == 0 {
+		return
+	}
+
+	periods := int(now.Sub(e.last) / throughputIdlePeriod)
+	if periods <= 0 {
+		return
+	}
+
+	if periods >= 32 {
+		e.samples = 0
+	} else {
+		e.samples >>= uint(periods)
+	}
+	e.last = e.last.Add(time.Duration(periods) * throughputIdlePeriod)
+
+	if e.samples == 0 {
+		e.rate = 0
+	}
+}
+
+// observe records that size bytes took elapsed to be written.
+func (e *throughputEstimator) observe(size int, elapsed time.Duration) {
+	if size < minThroughputSampleSize || elapsed <= 0 {
+		return
+	}
+
+	rate := float64(size) / elapsed.Seconds()
+	now := time.Now()
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.decay(now)
+
+	if e.samples == 0 {
+		e.rate = rate
+	} else {
+		e.rate += throughputSmoothing * (rate - e.rate)
+	}
+	e.samples++
+	e.last = now
+}
+
+// estimate returns the estimated throughput in bytes per second, and whether
+// enough recent samples back it.
+func (e *throughputEstimator) estimate() (float64, bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.decay(time.Now())
+	return e.rate, e.samples >= minThroughputSamples
+}
+
+// meteredWriter feeds the duration of every write to a peer's socket into its
+// throughput estimator.
+type meteredWriter struct {
+	w         io.Writer
+	estimator *throughputEstimator
+}
+
+func (m *meteredWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := m.w.Write(p)
+	if err == nil {
+		m.estimator.observe(n, time.Since(start))
+	}
+	return n, err
+}
+
+// writeTimeout returns how long writing a message of a given size to a peer
+// may take. In adaptive mode, it is the base timeout plus the time the peer's
+// estimated throughput needs to get the message across, bounded by the floor
+// and ceiling. It falls back to the static write timeout otherwise.
+func (n *Network) writeTimeout(address string, size int) time.Duration {
+	if !n.opts.adaptiveWrites {
+		return n.opts.writeTimeout
+	}
+
+	c, exists := n.peers.Load(address)
+	if !exists {
+		return n.opts.writeTimeout
+	}
+
+	rate, ok := c.(*PeerClient).throughput.estimate()
+	if !ok || rate <= 0 {
+		return n.opts.writeTimeout
+	}
+
+	timeout := n.opts.adaptiveWriteBase + transferTime(size, rate)
+	if timeout < n.opts.adaptiveWriteFloor {
+		timeout = n.opts.adaptiveWriteFloor
+	}
+	if n.opts.adaptiveWriteCeiling > 0 && timeout > n.opts.adaptiveWriteCeiling {
+		timeout = n.opts.adaptiveWriteCeiling
+	}
+	return timeout
+}
+
+// readBodyTimeout returns how long the body of a message of a given size may
+// take to be read, being zero should there be no deadline.
+func (n *Network) readBodyTimeout(size int) time.Duration {
+	if n.opts.readBodyTimeout <= 0 {
+		return 0
+	}
+
+	if n.opts.readBodyMinRate <= 0 {
+		return n.opts.readBodyTimeout
+	}
+
+	return n.opts.readBodyTimeout + transferTime(size, float64(n.opts.readBodyMinRate))
+}
+
+// transferTime returns how long size bytes take at rate bytes per second.
+func transferTime(size int, rate float64) time.Duration {
+	transfer := float64(size) / rate * float64(time.Second)
+	if transfer > math.MaxInt64/2 {
+		transfer = math.MaxInt64 / 2
+	}
+	return time.Duration(transfer)
+}
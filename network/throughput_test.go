@@ -0,0 +1,243 @@
+package network
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/atomic"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// slowConn simulates a link with limited bandwidth that may stall altogether,
+// honoring write deadlines.
+type slowConn struct {
+	net.Conn
+	link *slowLink
+
+	mutex    sync.Mutex
+	deadline time.Time
+}
+
+func (c *slowConn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.deadline = t
+	c.mutex.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *slowConn) SetDeadline(t time.Time) error {
+	c.mutex.Lock()
+	c.deadline = t
+	c.mutex.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *slowConn) Write(p []byte) (int, error) {
+	const chunkSize = 16 * 1024
+
+	c.mutex.Lock()
+	deadline := c.deadline
+	c.mutex.Unlock()
+
+	written := 0
+	for written < len(p) {
+		chunk := len(p) - written
+		if chunk > chunkSize {
+			chunk = chunkSize
+		}
+
+		for c.link.stalled.Load() {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return written, timeoutError{}
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		delay := time.Duration(float64(chunk) / float64(c.link.rate) * float64(time.Second))
+
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			time.Sleep(time.Until(deadline))
+			return written, timeoutError{}
+		}
+		time.Sleep(delay)
+
+		n, err := c.Conn.Write(p[written : written+chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// slowLink is a TCP transport whose dialed connections take a while to be
+// established, and are throttled to a number of bytes per second.
+type slowLink struct {
+	*transport.TCP
+	rate    int
+	latency time.Duration
+	stalled atomic.Bool
+}
+
+func (l *slowLink) Dial(address string) (net.Conn, error) {
+	time.Sleep(l.latency)
+
+	conn, err := l.TCP.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	return &slowConn{Conn: conn, link: l}, nil
+}
+
+// buildNodeOverLink builds a listening node which dials peers over a link.
+func buildNodeOverLink(t *testing.T, link *slowLink, opts ...BuilderOption) *Network {
+	builder := NewBuilderWithOptions(opts...)
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("tcp", link)
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+
+	go node.Listen()
+	<-node.Ready()
+
+	return node
+}
+
+func connectOverSlowLink(t *testing.T, rate int, opts ...BuilderOption) (*Network, *Network, *slowLink, chan int) {
+	received := make(chan int, 16)
+
+	receiver, _, _ := connectWithHandler(t, func(ctx *PluginContext) {
+		received <- len(ctx.Message().(*testpb.TestMessage).Message)
+	})
+
+	link := &slowLink{TCP: transport.NewTCP(), rate: rate}
+	sender := buildNodeOverLink(t, link, opts...)
+
+	_, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+
+	return receiver, sender, link, received
+}
+
+func TestAdaptiveWriteTimeoutSlowLink(t *testing.T) {
+	t.Parallel()
+
+	const rate = 1024 * 1024
+
+	large := &testpb.TestMessage{Message: strings.Repeat("x", 2*1024*1024)}
+
+	// A fixed deadline of one second is too short to get 2MB across.
+	receiver, sender, _, _ := connectOverSlowLink(t, rate, WriteTimeout(1*time.Second))
+	defer receiver.Close()
+	defer sender.Close()
+
+	message, err := sender.PrepareMessage(large)
+	assert.Nil(t, err)
+	assert.NotNil(t, sender.Write(receiver.Address, message))
+
+	// Adapting to the link's throughput lets it through.
+	receiver, sender, _, received := connectOverSlowLink(t, rate, WriteTimeout(1*time.Second), AdaptiveWriteTimeout(500*time.Millisecond, 100*time.Millisecond, 10*time.Second))
+	defer receiver.Close()
+	defer sender.Close()
+
+	assert.Equal(t, float64(0), sender.peerInfo(receiver.Address).WriteThroughput, "no estimate should be made before enough writes")
+
+	warmup := &testpb.TestMessage{Message: strings.Repeat("x", 64*1024)}
+	for i := 0; i < minThroughputSamples+1; i++ {
+		message, err := sender.PrepareMessage(warmup)
+		assert.Nil(t, err)
+		assert.Nil(t, sender.Write(receiver.Address, message))
+	}
+
+	estimate := sender.peerInfo(receiver.Address).WriteThroughput
+	assert.True(t, estimate > rate/2 && estimate < rate*2, "estimated %.0f bytes/sec over a %d bytes/sec link", estimate, rate)
+
+	message, err = sender.PrepareMessage(large)
+	assert.Nil(t, err)
+	assert.Nil(t, sender.Write(receiver.Address, message))
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case size := <-received:
+			if size == len(large.Message) {
+				return
+			}
+		case <-timeout:
+			t.Fatal("large message was never received")
+		}
+	}
+}
+
+func TestAdaptiveWriteTimeoutDeadPeer(t *testing.T) {
+	t.Parallel()
+
+	receiver, sender, link, _ := connectOverSlowLink(t, 1024*1024, AdaptiveWriteTimeout(100*time.Millisecond, 100*time.Millisecond, 10*time.Second))
+	defer receiver.Close()
+	defer sender.Close()
+
+	warmup := &testpb.TestMessage{Message: strings.Repeat("x", 32*1024)}
+	for i := 0; i < minThroughputSamples; i++ {
+		message, err := sender.PrepareMessage(warmup)
+		assert.Nil(t, err)
+		assert.Nil(t, sender.Write(receiver.Address, message))
+	}
+	assert.True(t, sender.peerInfo(receiver.Address).WriteThroughput > 0)
+
+	link.stalled.Store(true)
+
+	message, err := sender.PrepareMessage(&testpb.TestMessage{Message: "small"})
+	assert.Nil(t, err)
+
+	start := time.Now()
+
+	future, err := sender.WriteAsync(receiver.Address, message)
+	assert.Nil(t, err)
+
+	select {
+	case <-future.Done():
+		assert.NotNil(t, future.Err())
+	case <-time.After(defaultWriteTimeout):
+		t.Fatal("writing to a dead peer should fail before the static write timeout")
+	}
+	assert.True(t, time.Since(start) < time.Second, "small messages should fail fast, took %s", time.Since(start))
+}
+
+func TestThroughputEstimatorDecays(t *testing.T) {
+	var e throughputEstimator
+
+	for i := 0; i < minThroughputSamples; i++ {
+		e.observe(minThroughputSampleSize/2, time.Millisecond)
+	}
+	_, ok := e.estimate()
+	assert.False(t, ok, "small writes should not be sampled")
+
+	for i := 0; i < minThroughputSamples+1; i++ {
+		e.observe(1024*1024, time.Second)
+	}
+	rate, ok := e.estimate()
+	assert.True(t, ok)
+	assert.InDelta(t, 1024*1024, rate, 1)
+
+	// Going idle for a couple of periods loses confidence in the estimate.
+	e.Lock()
+	e.last = e.last.Add(-2 * throughputIdlePeriod)
+	e.Unlock()
+
+	_, ok = e.estimate()
+	assert.False(t, ok, "estimates should decay while idle")
+}
@@ -7,3 +7,10 @@ type Layer interface {
 	Listen(port int) (net.Listener, error)
 	Dial(address string) (net.Conn, error)
 }
+
+// AddressListener is implemented by transport layers which listen at an
+// address other than a port, such as a socket file. Networks prefer it over
+// Layer.Listen.
+type AddressListener interface {
+	ListenAddress(address string) (net.Listener, error)
+}
@@ -0,0 +1,46 @@
+package transport
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Unix represents the transport of connections over Unix domain sockets, for
+// nodes running on the same machine.
+type Unix struct{}
+
+// NewUnix instantiates a new instance of the Unix domain socket transport.
+func NewUnix() *Unix {
+	return &Unix{}
+}
+
+// Listen fails, as Unix domain sockets listen at a socket file rather than a
+// port.
+func (t *Unix) Listen(port int) (net.Listener, error) {
+	return nil, errors.New("unix: listening requires a socket file rather than a port")
+}
+
+// ListenAddress listens for incoming connections at a socket file, replacing
+// the file left behind by a process which exited without removing it.
+func (t *Unix) ListenAddress(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, errors.Errorf("unix: socket file %s is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrapf(err, "unix: failed to remove stale socket file %s", path)
+		}
+	}
+
+	return net.Listen("unix", path)
+}
+
+// Dial dials the socket file of a Unix domain socket.
+func (t *Unix) Dial(path string) (net.Conn, error) {
+	return net.Dial("unix", path)
+}
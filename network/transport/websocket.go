@@ -0,0 +1,490 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// websocketGUID is appended to the key a dialer sends to derive the key the
+// listener accepts it with, as set by RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC11B65"
+
+// Opcodes of the WebSocket frames.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControlPayload is the largest payload a control frame may carry.
+const maxControlPayload = 125
+
+// WebSocket represents a transport carrying connections as streams of binary
+// WebSocket frames over plain HTTP, for peers which may only reach each other
+// over HTTP, such as through corporate proxies.
+type WebSocket struct {
+	// Path is the HTTP path connections are upgraded at.
+	Path string
+	// HandshakeTimeout bounds how long upgrading a connection may take.
+	HandshakeTimeout time.Duration
+	// Proxy returns the HTTP proxy to tunnel connections dialed through
+	// with CONNECT, such as http.ProxyFromEnvironment, being nil or
+	// returning no URL to dial peers directly.
+	Proxy func(*http.Request) (*url.URL, error)
+}
+
+// NewWebSocket instantiates a new instance of the WebSocket transport protocol.
+func NewWebSocket() *WebSocket {
+	return &WebSocket{
+		Path:             "/",
+		HandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// Listen listens for incoming WebSocket connections on a specified port.
+// Connections are upgraded concurrently, so that a slow peer does not hold
+// up others.
+func (t *WebSocket) Listen(port int) (net.Listener, error) {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &websocketListener{
+		Listener: listener,
+		t:        t,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	go l.serve()
+
+	return l, nil
+}
+
+// Dial dials an address via. the WebSocket protocol, through an HTTP proxy
+// should one be set.
+func (t *WebSocket) Dial(address string) (net.Conn, error) {
+	target := &url.URL{Scheme: "http", Host: address, Path: t.Path}
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        target,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       address,
+	}
+
+	var proxy *url.URL
+	if t.Proxy != nil {
+		var err error
+		if proxy, err = t.Proxy(req); err != nil {
+			return nil, err
+		}
+	}
+
+	dialAddress := address
+	if proxy != nil {
+		dialAddress = proxy.Host
+	}
+
+	conn, err := net.DialTimeout("tcp", dialAddress, t.HandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(t.HandshakeTimeout))
+
+	reader := bufio.NewReader(conn)
+
+	if proxy != nil {
+		if err := tunnel(conn, reader, proxy, address); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	var nonce [16]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to send websocket upgrade")
+	}
+
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "failed to read websocket upgrade")
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols || !headerContains(res.Header, "Upgrade", "websocket") ||
+		res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, errors.Errorf("websocket upgrade to %s refused with status %q", address, res.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return newWebsocketConn(conn, reader, true), nil
+}
+
+// tunnel asks an HTTP proxy to tunnel a connection through to an address.
+func tunnel(conn net.Conn, reader *bufio.Reader, proxy *url.URL, address string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+
+	if user := proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return errors.Wrap(err, "failed to ask proxy for a tunnel")
+	}
+
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to read proxy response")
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("proxy %s refused to tunnel to %s with status %q", proxy.Host, address, res.Status)
+	}
+
+	return nil
+}
+
+// acceptKey returns the key a listener accepts a dialer's key with.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContains returns true if a comma-separated header holds a token,
+// regardless of case.
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocketListener hands out the connections accepted off a TCP listener
+// once they are upgraded to WebSocket connections.
+type websocketListener struct {
+	net.Listener
+	t *WebSocket
+
+	conns chan net.Conn
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// serve accepts connections and upgrades each of them on its own goroutine,
+// until the listener is closed.
+func (l *websocketListener) serve() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.closeOnce.Do(func() {
+				l.err = err
+				close(l.done)
+			})
+			return
+		}
+
+		go func() {
+			upgraded, err := l.upgrade(conn)
+			if err != nil {
+				conn.Close()
+				return
+			}
+
+			select {
+			case l.conns <- upgraded:
+			case <-l.done:
+				upgraded.Close()
+			}
+		}()
+	}
+}
+
+// upgrade reads the upgrade request off a connection, and accepts it should
+// it ask for a WebSocket connection at the listener's path.
+func (l *websocketListener) upgrade(conn net.Conn) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(l.t.HandshakeTimeout))
+
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if req.Method != http.MethodGet || req.URL.Path != l.t.Path || len(key) == 0 ||
+		!headerContains(req.Header, "Upgrade", "websocket") || !headerContains(req.Header, "Connection", "upgrade") ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" {
+		io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n")
+		return nil, errors.New("not a websocket upgrade")
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	if _, err := io.WriteString(conn, response); err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return newWebsocketConn(conn, reader, false), nil
+}
+
+// Accept waits for and returns the next upgraded connection.
+func (l *websocketListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// websocketConn carries a stream of bytes as WebSocket frames, each write
+// being sent as a single binary frame.
+type websocketConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	// client is set for dialed connections, which mask the frames they
+	// send, and expect those they receive to be unmasked.
+	client bool
+
+	writeMutex sync.Mutex
+
+	readMutex sync.Mutex
+	// remaining is how many bytes of the data frame being read are left.
+	remaining uint64
+	masked    bool
+	mask      [4]byte
+	maskIndex int
+}
+
+func newWebsocketConn(conn net.Conn, reader *bufio.Reader, client bool) *websocketConn {
+	return &websocketConn{Conn: conn, reader: reader, client: client}
+}
+
+// Read reads the payloads of the data frames received as a stream of bytes,
+// answering pings along the way.
+func (c *websocketConn) Read(out []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(out)) > c.remaining {
+		out = out[:c.remaining]
+	}
+
+	n, err := c.reader.Read(out)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			out[i] ^= c.mask[c.maskIndex%4]
+			c.maskIndex++
+		}
+	}
+	c.remaining -= uint64(n)
+
+	if err == io.EOF && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextFrame reads the header of the next frame, handling control frames in
+// full, and leaving the payload of data frames to be read.
+func (c *websocketConn) nextFrame() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return err
+	}
+
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+
+	// Dialers mask the frames they send, and listeners never do.
+	if masked == c.client {
+		return errors.New("websocket: frame masked the wrong way")
+	}
+
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return noEOF(err)
+		}
+		size = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return noEOF(err)
+		}
+		size = binary.BigEndian.Uint64(extended[:])
+	}
+
+	c.masked, c.maskIndex = masked, 0
+	if masked {
+		if _, err := io.ReadFull(c.reader, c.mask[:]); err != nil {
+			return noEOF(err)
+		}
+	}
+
+	switch opcode {
+	case opContinuation, opText, opBinary:
+		c.remaining = size
+		return nil
+	case opClose, opPing, opPong:
+	default:
+		return errors.Errorf("websocket: unknown opcode %d", opcode)
+	}
+
+	if size > maxControlPayload {
+		return errors.Errorf("websocket: control frame of %d bytes", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return noEOF(err)
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= c.mask[i%4]
+		}
+	}
+
+	switch opcode {
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opClose:
+		c.writeFrame(opClose, nil)
+		return io.EOF
+	}
+	return nil
+}
+
+// noEOF reports a stream ending mid-frame as unexpected.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Write sends data as a single binary frame.
+func (c *websocketConn) Write(data []byte) (int, error) {
+	if err := c.writeFrame(opBinary, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// writeFrame writes a frame carrying a payload, masking it should the
+// connection have been dialed. The payload is never modified.
+func (c *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	switch size := len(payload); {
+	case size <= maxControlPayload:
+		frame = append(frame, maskBit|byte(size))
+	case size <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(size))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(size))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame on a best-effort basis, and closes the
+// connection.
+func (c *websocketConn) Close() error {
+	c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(opClose, nil)
+	return c.Conn.Close()
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixSocketTransport(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "unix")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Socket files left behind by exited processes are replaced.
+	stale, err := net.Listen("unix", filepath.Join(dir, "receiver.sock"))
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	received := make(chan string, 1)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatUnixAddress(filepath.Join(dir, "receiver.sock")))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	builder = NewBuilder()
+	builder.SetAddress(FormatUnixAddress(filepath.Join(dir, "sender.sock")))
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "local"}))
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "local", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never received over the socket")
+	}
+
+	assert.True(t, receiver.ConnectionStateExists(sender.Address))
+}
@@ -0,0 +1,358 @@
+package network
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/pkg/errors"
+)
+
+// UpgradeCapability is advertised by nodes which accept and relay protocol
+// upgrade announcements.
+const UpgradeCapability = "noise/upgrades"
+
+// DisconnectOutdated is the reason peers lagging behind the protocol version
+// enforced by EnforceUpgrade are disconnected with.
+const DisconnectOutdated = "outdated"
+
+const (
+	// maxUpgradeNoteSize bounds the size of the note of an announcement.
+	maxUpgradeNoteSize = 256
+	// maxUpgradeAnnouncementSize bounds the size of a signed announcement.
+	maxUpgradeAnnouncementSize = 1024
+	// maxUpgradeAnnouncers bounds how many nodes announcements are held for.
+	maxUpgradeAnnouncers = 1024
+
+	// upgradeAnnounceInterval is how often a node may announce an upgrade.
+	// Announcements of a node arriving more often are dropped.
+	upgradeAnnounceInterval = time.Minute
+
+	// upgradeEnforceInterval is how often peers are checked against the
+	// enforced protocol version once it activates. Peers which never
+	// announced an upgrade are given as long to do so once they connect.
+	upgradeEnforceInterval = 10 * time.Second
+)
+
+var (
+	// ErrUpgradeNoteTooLarge is returned when announcing an upgrade with a
+	// note larger than 256 bytes.
+	ErrUpgradeNoteTooLarge = errors.New("network: upgrade note too large")
+	// ErrUpgradeTooFrequent is returned when announcing upgrades more often
+	// than once a minute.
+	ErrUpgradeTooFrequent = errors.New("network: upgrades announced too often")
+	// ErrInvalidUpgrade is reported for announcements which are malformed,
+	// or whose signature does not verify.
+	ErrInvalidUpgrade = errors.New("network: invalid upgrade announcement")
+)
+
+var signedUpgradeName = proto.MessageName((*protobuf.SignedUpgradeAnnouncement)(nil))
+
+// UpgradeAnnouncement is a node's announcement that it will require a
+// protocol version from an activation time onward.
+type UpgradeAnnouncement struct {
+	Version    uint32
+	Activation time.Time
+	// Note is a human-readable note for operators, such as a release name.
+	Note string
+}
+
+// UpgradeReadiness counts the connected peers announcing an upgrade to the
+// same version at the same activation time.
+type UpgradeReadiness struct {
+	Version    uint32
+	Activation time.Time
+	// Peers is how many connected peers announced the upgrade.
+	Peers int
+	// Fraction is the share of all connected peers which announced it.
+	Fraction float64
+}
+
+// UpgradeStatus describes which upgrades connected peers announced.
+type UpgradeStatus struct {
+	// Peers is the number of connected peers.
+	Peers int
+	// Upgrades lists the upgrades connected peers announced, by version and
+	// then activation time.
+	Upgrades []UpgradeReadiness
+}
+
+// heldUpgrade is the latest announcement of a node, alongside its signed
+// form relayed to peers.
+type heldUpgrade struct {
+	announcement UpgradeAnnouncement
+	issuedAt     int64
+	received     time.Time
+	signed       *protobuf.SignedUpgradeAnnouncement
+}
+
+// upgradeBook holds the latest upgrade announced by every node, by public key.
+type upgradeBook struct {
+	sync.Mutex
+	held map[string]heldUpgrade
+}
+
+// adopt holds an announcement should it be newer than the one held for its
+// signer, and the signer not have announced one within the last interval,
+// returning true if it was.
+func (b *upgradeBook) adopt(signer []byte, upgrade heldUpgrade) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.held == nil {
+		b.held = make(map[string]heldUpgrade)
+	}
+
+	held, exists := b.held[string(signer)]
+	if !exists && len(b.held) >= maxUpgradeAnnouncers {
+		return false
+	}
+	if exists && (upgrade.issuedAt <= held.issuedAt || upgrade.received.Sub(held.received) < upgradeAnnounceInterval) {
+		return false
+	}
+
+	b.held[string(signer)] = upgrade
+	return true
+}
+
+func (b *upgradeBook) lookup(signer []byte) (heldUpgrade, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	held, exists := b.held[string(signer)]
+	return held, exists
+}
+
+// AnnounceUpgrade announces to all peers that this node will require a
+// protocol version from activation onward, replacing any upgrade announced
+// before. Announcements are signed, relayed by peers to theirs, and sent to
+// peers connecting afterwards. They are informational, unless peers enforce
+// the version through EnforceUpgrade. Upgrades may be announced at most once
+// a minute, with a note of up to 256 bytes.
+func (n *Network) AnnounceUpgrade(version uint32, activation time.Time, note string) error {
+	if len(note) > maxUpgradeNoteSize {
+		return errors.Wrapf(ErrUpgradeNoteTooLarge, "%d bytes", len(note))
+	}
+
+	now := n.now()
+
+	serialized, err := proto.Marshal(&protobuf.UpgradeAnnouncement{
+		Version:    version,
+		Activation: activation.UnixNano(),
+		Note:       note,
+		IssuedAt:   now.UnixNano(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal upgrade announcement")
+	}
+
+	signature, err := n.keys.Sign(n.opts.signaturePolicy, n.opts.hashPolicy, serialized)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign upgrade announcement")
+	}
+
+	signed := &protobuf.SignedUpgradeAnnouncement{
+		Announcement: serialized,
+		Signer:       n.keys.PublicKey,
+		Signature:    signature,
+	}
+
+	held := heldUpgrade{
+		announcement: UpgradeAnnouncement{Version: version, Activation: activation, Note: note},
+		issuedAt:     now.UnixNano(),
+		received:     now,
+		signed:       signed,
+	}
+	if !n.upgrades.adopt(n.keys.PublicKey, held) {
+		return ErrUpgradeTooFrequent
+	}
+
+	n.relayUpgrade(signed, nil)
+	return nil
+}
+
+// UpgradeStatus returns which upgrades connected peers announced, and the
+// share of connected peers announcing each.
+func (n *Network) UpgradeStatus() UpgradeStatus {
+	type key struct {
+		version    uint32
+		activation int64
+	}
+
+	var status UpgradeStatus
+	counts := make(map[key]*UpgradeReadiness)
+
+	n.eachPeer(func(client *PeerClient) bool {
+		status.Peers++
+
+		held, exists := n.upgrades.lookup(client.publicKey)
+		if !exists {
+			return true
+		}
+
+		k := key{held.announcement.Version, held.announcement.Activation.UnixNano()}
+		if counts[k] == nil {
+			counts[k] = &UpgradeReadiness{Version: held.announcement.Version, Activation: held.announcement.Activation}
+		}
+		counts[k].Peers++
+		return true
+	})
+
+	for _, readiness := range counts {
+		readiness.Fraction = float64(readiness.Peers) / float64(status.Peers)
+		status.Upgrades = append(status.Upgrades, *readiness)
+	}
+
+	sort.Slice(status.Upgrades, func(i, j int) bool {
+		if status.Upgrades[i].Version != status.Upgrades[j].Version {
+			return status.Upgrades[i].Version < status.Upgrades[j].Version
+		}
+		return status.Upgrades[i].Activation.Before(status.Upgrades[j].Activation)
+	})
+
+	return status
+}
+
+// relayUpgrade sends a signed announcement to every connected peer accepting
+// them, other than the peer it came from and the node which signed it.
+func (n *Network) relayUpgrade(signed *protobuf.SignedUpgradeAnnouncement, from *PeerClient) {
+	n.eachPeer(func(client *PeerClient) bool {
+		if client == from || bytes.Equal(client.publicKey, signed.Signer) || !client.HasCapability(UpgradeCapability) {
+			return true
+		}
+
+		if err := client.Tell(signed); err != nil {
+			glog.Warningf("failed to send upgrade announcement to %s: %v", client.Address, err)
+		}
+		return true
+	})
+}
+
+// announceUpgradeTo sends a newly connected peer the upgrade this node
+// announced, if any, and starts the peer's grace period for announcing its own.
+func (n *Network) announceUpgradeTo(client *PeerClient) {
+	atomic.StoreInt64(&client.connectedAt, n.now().UnixNano())
+
+	held, exists := n.upgrades.lookup(n.keys.PublicKey)
+	if !exists || !client.HasCapability(UpgradeCapability) {
+		return
+	}
+
+	if err := client.Tell(held.signed); err != nil {
+		glog.Warningf("failed to send upgrade announcement to %s: %v", client.Address, err)
+	}
+}
+
+// handleUpgrade holds and relays an upgrade announcement should a message be
+// a new one, returning true if a message was an announcement. Malformed
+// announcements and those whose signature does not verify are reported as
+// violations.
+func (n *Network) handleUpgrade(client *PeerClient, name string, payload *types.Any) bool {
+	if name != signedUpgradeName {
+		return false
+	}
+
+	if len(payload.Value) > maxUpgradeAnnouncementSize {
+		n.reportViolation(client, errors.Wrapf(ErrInvalidUpgrade, "%d bytes", len(payload.Value)))
+		return true
+	}
+
+	var signed protobuf.SignedUpgradeAnnouncement
+	if err := types.UnmarshalAny(payload, &signed); err != nil {
+		n.reportViolation(client, errors.Wrap(ErrInvalidUpgrade, err.Error()))
+		return true
+	}
+
+	held, err := n.verifyUpgrade(&signed)
+	if err != nil {
+		n.reportViolation(client, err)
+		return true
+	}
+
+	// Announcements of our own find their way back to us through relays.
+	if bytes.Equal(signed.Signer, n.keys.PublicKey) {
+		return true
+	}
+
+	if n.upgrades.adopt(signed.Signer, held) {
+		n.relayUpgrade(&signed, client)
+	}
+	return true
+}
+
+// verifyUpgrade verifies the signature of an announcement, and decodes it.
+func (n *Network) verifyUpgrade(signed *protobuf.SignedUpgradeAnnouncement) (heldUpgrade, error) {
+	if !crypto.Verify(n.opts.signaturePolicy, n.opts.hashPolicy, signed.Signer, signed.Announcement, signed.Signature) {
+		return heldUpgrade{}, errors.Wrap(ErrInvalidUpgrade, "signature does not verify")
+	}
+
+	var announcement protobuf.UpgradeAnnouncement
+	if err := proto.Unmarshal(signed.Announcement, &announcement); err != nil {
+		return heldUpgrade{}, errors.Wrap(ErrInvalidUpgrade, err.Error())
+	}
+
+	if len(announcement.Note) > maxUpgradeNoteSize {
+		return heldUpgrade{}, errors.Wrapf(ErrUpgradeNoteTooLarge, "%d bytes", len(announcement.Note))
+	}
+
+	return heldUpgrade{
+		announcement: UpgradeAnnouncement{
+			Version:    announcement.Version,
+			Activation: time.Unix(0, announcement.Activation),
+			Note:       announcement.Note,
+		},
+		issuedAt: announcement.IssuedAt,
+		received: n.now(),
+		signed:   signed,
+	}, nil
+}
+
+func (n *Network) upgradeLoop() {
+	t := time.NewTicker(upgradeEnforceInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-n.kill:
+			return
+		case <-t.C:
+			n.enforceUpgrade(n.now())
+		}
+	}
+}
+
+// enforceUpgrade disconnects peers lagging behind the protocol version set by
+// EnforceUpgrade once it activates: peers which announced an upgrade to an
+// older version, and peers which announced none despite having been
+// connected for long enough to.
+func (n *Network) enforceUpgrade(now time.Time) {
+	if n.opts.enforceUpgradeVersion == 0 || now.Before(n.opts.enforceUpgradeActivation) {
+		return
+	}
+
+	n.eachPeer(func(client *PeerClient) bool {
+		held, exists := n.upgrades.lookup(client.publicKey)
+		if exists && held.announcement.Version >= n.opts.enforceUpgradeVersion {
+			return true
+		}
+
+		if !exists {
+			connectedAt := atomic.LoadInt64(&client.connectedAt)
+			if connectedAt == 0 || now.Sub(time.Unix(0, connectedAt)) < upgradeEnforceInterval {
+				return true
+			}
+		}
+
+		glog.Warningf("disconnecting from peer %s lagging behind protocol version %d", client.Address, n.opts.enforceUpgradeVersion)
+
+		client.close(DisconnectOutdated)
+		return true
+	})
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeAnnouncements(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Now()}
+	activation := clock.Now().Add(time.Hour).Round(0)
+
+	observer := buildClockedNode(t, clock, func(ctx *PluginContext) {}, EnforceUpgrade(3, activation))
+	defer observer.Close()
+
+	nodes := []*Network{buildListeningNode(t), buildListeningNode(t), buildListeningNode(t)}
+	clients := make([]*PeerClient, len(nodes))
+	for i, node := range nodes {
+		defer node.Close()
+
+		_, err := node.Client(observer.Address)
+		assert.Nil(t, err)
+
+		clients[i], err = observer.Client(node.Address)
+		assert.Nil(t, err)
+	}
+
+	// The last node lags behind, still announcing the previous version.
+	assert.Nil(t, nodes[0].AnnounceUpgrade(3, activation, "release 3.0"))
+	assert.Nil(t, nodes[1].AnnounceUpgrade(3, activation, ""))
+	assert.Nil(t, nodes[2].AnnounceUpgrade(2, activation.Add(-24*time.Hour), ""))
+
+	expected := UpgradeStatus{
+		Peers: 3,
+		Upgrades: []UpgradeReadiness{
+			{Version: 2, Activation: activation.Add(-24 * time.Hour), Peers: 1, Fraction: 1.0 / 3},
+			{Version: 3, Activation: activation, Peers: 2, Fraction: 2.0 / 3},
+		},
+	}
+	assert.True(t, waitUntil(5*time.Second, func() bool {
+		status := observer.UpgradeStatus()
+		return len(status.Upgrades) == 2 && status.Upgrades[1].Peers == 2
+	}))
+
+	status := observer.UpgradeStatus()
+	for i := range status.Upgrades {
+		status.Upgrades[i].Activation = status.Upgrades[i].Activation.Round(0)
+	}
+	assert.Equal(t, expected, status)
+
+	// Announcements are relayed to peers not connected to their signer.
+	assert.True(t, waitUntil(5*time.Second, func() bool {
+		held, exists := nodes[0].upgrades.lookup(nodes[2].keys.PublicKey)
+		return exists && held.announcement.Version == 2
+	}))
+
+	assert.Equal(t, ErrUpgradeTooFrequent, nodes[0].AnnounceUpgrade(4, activation, ""))
+	assert.Equal(t, ErrUpgradeNoteTooLarge, errors.Cause(nodes[0].AnnounceUpgrade(4, activation, strings.Repeat("x", 257))))
+
+	// Announcements are informational until the version activates.
+	observer.enforceUpgrade(clock.Now())
+	for _, client := range clients {
+		assert.Equal(t, "", client.DisconnectReason())
+	}
+
+	clock.Advance(time.Hour)
+	observer.enforceUpgrade(clock.Now())
+
+	assert.Equal(t, "", clients[0].DisconnectReason())
+	assert.Equal(t, "", clients[1].DisconnectReason())
+	assert.Equal(t, DisconnectOutdated, clients[2].DisconnectReason())
+	assert.Equal(t, 2, observer.UpgradeStatus().Peers)
+}
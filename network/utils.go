@@ -3,6 +3,7 @@ package network
 import (
 	"encoding/binary"
 	"net"
+	"sort"
 
 	"github.com/perlin-network/noise/internal/protobuf"
 )
@@ -36,6 +37,35 @@ func SerializeMessage(id *protobuf.ID, message []byte) []byte {
 	return serialized
 }
 
+// serializeEnvelope packs a message together with its metadata for cryptographic
+// signing purposes. Messages without metadata serialize exactly as SerializeMessage.
+func serializeEnvelope(msg *protobuf.Message) []byte {
+	serialized := SerializeMessage(msg.Sender, msg.Message.Value)
+	serialized = serializeSignatureKeys(serialized, msg.Signatures)
+	serialized = serializeCriticalExtensions(serialized, msg.CriticalExtensions)
+	serialized = serializeProtocol(serialized, msg.Protocol)
+	if len(msg.Metadata) == 0 {
+		return serializeSignatureScheme(serializeReplayNonce(serializeHints(serialized, msg.Hints), msg.ReplayNonce, msg.ReplayStampMs), msg.SignatureScheme)
+	}
+
+	keys := make([]string, 0, len(msg.Metadata))
+	for key := range msg.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var size [4]byte
+	for _, key := range keys {
+		for _, field := range []string{key, msg.Metadata[key]} {
+			binary.LittleEndian.PutUint32(size[:], uint32(len(field)))
+			serialized = append(serialized, size[:]...)
+			serialized = append(serialized, field...)
+		}
+	}
+
+	return serializeSignatureScheme(serializeReplayNonce(serializeHints(serialized, msg.Hints), msg.ReplayNonce, msg.ReplayStampMs), msg.SignatureScheme)
+}
+
 // FilterPeers filters out duplicate/empty addresses.
 func FilterPeers(address string, peers []string) (filtered []string) {
 	visited := make(map[string]struct{})
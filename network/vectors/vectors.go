@@ -0,0 +1,98 @@
+// Package vectors holds test vectors of envelopes signed over their canonical
+// preimage, as laid out by network.CanonicalPreimage, for implementations of
+// the wire protocol to check themselves against. The vectors are kept in
+// envelopes.json alongside this package, with every byte string hex-encoded.
+// Envelopes are signed with ed25519 over a blake2b hash of their preimage.
+package vectors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/blake2b"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/pkg/errors"
+)
+
+// Vector is an envelope signed over its canonical preimage, alongside the key
+// pair it was signed with.
+type Vector struct {
+	// Name describes what the vector exercises.
+	Name string `json:"name"`
+	// PrivateKey and PublicKey are the ed25519 key pair of the sender.
+	PrivateKey string `json:"private_key"`
+	PublicKey  string `json:"public_key"`
+	// Envelope is the signed envelope as sent on the wire, without its length
+	// prefix.
+	Envelope string `json:"envelope"`
+	// Preimage is the canonical preimage of the envelope.
+	Preimage string `json:"preimage"`
+	// Signature is the primary signature of the envelope.
+	Signature string `json:"signature"`
+}
+
+// Decode reads vectors encoded as a JSON array.
+func Decode(r io.Reader) ([]Vector, error) {
+	var vectors []Vector
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, errors.Wrap(err, "failed to decode vectors")
+	}
+	return vectors, nil
+}
+
+// Verify checks a vector: that its public key is derived from its private
+// key, that its envelope is sent by the public key, carries the signature and
+// is marked as signed canonically, that the canonical preimage of the
+// envelope is the one expected, and that the signature verifies over it.
+func Verify(v Vector) error {
+	var fields [5][]byte
+	for i, field := range []string{v.PrivateKey, v.PublicKey, v.Envelope, v.Preimage, v.Signature} {
+		decoded, err := hex.DecodeString(field)
+		if err != nil {
+			return errors.Wrapf(err, "vector %q is not hex-encoded", v.Name)
+		}
+		fields[i] = decoded
+	}
+	privateKey, publicKey, envelope, preimage, signature := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	derived, err := ed25519.New().PrivateToPublic(privateKey)
+	if err != nil || !bytes.Equal(derived, publicKey) {
+		return errors.Errorf("vector %q: public key is not derived from private key", v.Name)
+	}
+
+	var msg protobuf.Message
+	if err := proto.Unmarshal(envelope, &msg); err != nil {
+		return errors.Wrapf(err, "vector %q: failed to unmarshal envelope", v.Name)
+	}
+
+	if msg.Sender == nil || !bytes.Equal(msg.Sender.PublicKey, publicKey) {
+		return errors.Errorf("vector %q: envelope is not sent by public key", v.Name)
+	}
+	if !bytes.Equal(msg.Signature, signature) {
+		return errors.Errorf("vector %q: envelope does not carry signature", v.Name)
+	}
+
+	canonical := false
+	for _, extension := range msg.CriticalExtensions {
+		canonical = canonical || network.Extension(extension) == network.ExtensionCanonicalSigning
+	}
+	if !canonical {
+		return errors.Errorf("vector %q: envelope is not marked as signed canonically", v.Name)
+	}
+
+	if computed := network.CanonicalPreimage(&msg); !bytes.Equal(computed, preimage) {
+		return errors.Errorf("vector %q: canonical preimage is %x, expected %x", v.Name, computed, preimage)
+	}
+
+	if !crypto.Verify(ed25519.New(), blake2b.New(), publicKey, preimage, signature) {
+		return errors.Errorf("vector %q: signature does not verify", v.Name)
+	}
+
+	return nil
+}
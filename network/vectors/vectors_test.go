@@ -0,0 +1,179 @@
+package vectors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/perlin-network/noise/crypto"
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/perlin-network/noise/internal/protobuf"
+	"github.com/perlin-network/noise/network"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateVectors = flag.Bool("update", false, "update envelopes.json")
+
+// seededKeys returns an ed25519 key pair generated from a seed of 32 copies
+// of a byte, so that vectors are the same every time they are generated.
+func seededKeys(t *testing.T, seed byte) *crypto.KeyPair {
+	publicKey, privateKey, err := ed25519.GenerateKey(bytes.NewReader(bytes.Repeat([]byte{seed}, 32)))
+	assert.Nil(t, err)
+	return &crypto.KeyPair{PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+type vectorCase struct {
+	name    string
+	payload []byte
+	opts    []network.BuilderOption
+	hook    network.OutboundHook
+}
+
+// generate signs every case through a network signing canonically, shaping
+// envelopes with an outbound hook before they are signed.
+func generate(t *testing.T) []Vector {
+	cases := []vectorCase{
+		{name: "payload", payload: []byte("hello")},
+		{name: "empty payload"},
+		{
+			name:    "protocol",
+			payload: []byte("hello"),
+			hook: func(_ network.PeerInfo, msg *network.Envelope) error {
+				msg.Protocol = "chat/1"
+				return nil
+			},
+		},
+		{
+			name:    "metadata",
+			payload: []byte("hello"),
+			hook: func(_ network.PeerInfo, msg *network.Envelope) error {
+				msg.Metadata = map[string]string{"trace": "abc", "region": "eu", "empty": ""}
+				return nil
+			},
+		},
+		{
+			name:    "hints",
+			payload: []byte("hello"),
+			hook: func(_ network.PeerInfo, msg *network.Envelope) error {
+				msg.Hints = []*protobuf.Hint{{Key: "shard", Value: []byte{7}}, {Key: "key", Value: []byte("abc")}}
+				return nil
+			},
+		},
+		{
+			name:    "signature schemes",
+			payload: []byte("hello"),
+			opts: []network.BuilderOption{
+				network.SignatureTransition(network.SignatureScheme{Name: "next", Policy: ed25519.New(), Keys: seededKeys(t, 2)}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+	}
+
+	keys := seededKeys(t, 1)
+
+	vectors := make([]Vector, 0, len(cases))
+	for _, c := range cases {
+		builder := network.NewBuilderWithOptions(append(c.opts, network.EnvelopeSigning(network.SigningCanonical))...)
+		builder.SetKeys(keys)
+		builder.SetAddress("tcp://localhost:3000")
+		if c.hook != nil {
+			builder.AddPeerIndependentOutboundHook(c.hook)
+		}
+
+		node, err := builder.Build()
+		assert.Nil(t, err)
+
+		msg, err := node.PrepareMessage(&types.BytesValue{Value: c.payload})
+		assert.Nil(t, err)
+		node.Close()
+
+		envelope, err := proto.Marshal(msg)
+		assert.Nil(t, err)
+
+		vectors = append(vectors, Vector{
+			Name:       c.name,
+			PrivateKey: hex.EncodeToString(keys.PrivateKey),
+			PublicKey:  hex.EncodeToString(keys.PublicKey),
+			Envelope:   hex.EncodeToString(envelope),
+			Preimage:   hex.EncodeToString(network.CanonicalPreimage(msg)),
+			Signature:  hex.EncodeToString(msg.Signature),
+		})
+	}
+	return vectors
+}
+
+// decodeEnvelope decodes the envelope of a vector, as protobuf encoders may
+// order map entries differently every time an envelope is marshaled.
+func decodeEnvelope(t *testing.T, v Vector) *protobuf.Message {
+	raw, err := hex.DecodeString(v.Envelope)
+	assert.Nil(t, err)
+
+	var msg protobuf.Message
+	assert.Nil(t, proto.Unmarshal(raw, &msg))
+	return &msg
+}
+
+// TestVectorsDoNotDrift fails should envelopes stop being signed exactly as
+// the vectors kept in envelopes.json were. Run with -update to regenerate
+// them, which breaks every other implementation checking against them.
+func TestVectorsDoNotDrift(t *testing.T) {
+	generated := generate(t)
+
+	if *updateVectors {
+		encoded, err := json.MarshalIndent(generated, "", "  ")
+		assert.Nil(t, err)
+		assert.Nil(t, ioutil.WriteFile("envelopes.json", append(encoded, '\n'), 0644))
+	}
+
+	f, err := os.Open("envelopes.json")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	kept, err := Decode(f)
+	assert.Nil(t, err)
+
+	if !assert.Equal(t, len(kept), len(generated)) {
+		return
+	}
+
+	for i, v := range kept {
+		assert.Nil(t, Verify(v))
+
+		assert.Equal(t, v.Name, generated[i].Name)
+		assert.Equal(t, v.PrivateKey, generated[i].PrivateKey)
+		assert.Equal(t, v.PublicKey, generated[i].PublicKey)
+		assert.Equal(t, v.Preimage, generated[i].Preimage, v.Name)
+		assert.Equal(t, v.Signature, generated[i].Signature, v.Name)
+		assert.True(t, decodeEnvelope(t, v).Equal(decodeEnvelope(t, generated[i])), v.Name)
+	}
+}
+
+func TestVerifyRejectsTamperedVectors(t *testing.T) {
+	vectors := generate(t)
+	v := vectors[0]
+
+	assert.Nil(t, Verify(v))
+
+	msg := decodeEnvelope(t, v)
+	msg.Message.TypeUrl = "type.googleapis.com/google.protobuf.StringValue"
+	tampered, err := proto.Marshal(msg)
+	assert.Nil(t, err)
+
+	typed := v
+	typed.Envelope = hex.EncodeToString(tampered)
+	assert.NotNil(t, Verify(typed))
+
+	legacy := v
+	legacy.Signature = vectors[1].Signature
+	assert.NotNil(t, Verify(legacy))
+
+	foreign := v
+	foreign.PublicKey = hex.EncodeToString(seededKeys(t, 3).PublicKey)
+	assert.NotNil(t, Verify(foreign))
+}
@@ -0,0 +1,242 @@
+package network
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/glog"
+	"github.com/perlin-network/noise/internal/protobuf"
+)
+
+const (
+	defaultVerificationCacheSize = 8192
+	defaultVerificationCacheTTL  = 5 * time.Minute
+)
+
+// VerificationStats counts how often signature checks were skipped for
+// messages whose exact content was checked before.
+type VerificationStats struct {
+	// Hits is the number of messages whose signature checks were cached.
+	Hits uint64
+	// Misses is the number of messages whose signatures were checked.
+	Misses uint64
+	// KnownBad is the number of messages rejected for being copies of a
+	// message which failed signature checks before.
+	KnownBad uint64
+}
+
+// verifiedKey is a public key whose signature verified under a scheme.
+type verifiedKey struct {
+	scheme    string
+	publicKey []byte
+}
+
+// verificationResult holds which of a message's signatures verified. Whether
+// they are trusted is decided afresh every time, as it depends on the keys
+// peers demonstrated so far.
+type verificationResult struct {
+	primary  bool
+	verified []verifiedKey
+}
+
+func (r *verificationResult) bad() bool {
+	return !r.primary && len(r.verified) == 0
+}
+
+type verificationEntry struct {
+	key     [sha256.Size]byte
+	result  *verificationResult
+	expires time.Time
+}
+
+// verificationCache remembers the results of checking the signatures of
+// recently received messages, evicting the least recently used beyond size
+// entries, and entries older than ttl.
+type verificationCache struct {
+	sync.Mutex
+
+	size int
+	ttl  time.Duration
+
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	peak    peakSize
+
+	stats VerificationStats
+}
+
+func newVerificationCache(size int, ttl time.Duration) *verificationCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &verificationCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+func (c *verificationCache) get(key [sha256.Size]byte, now time.Time) (*verificationResult, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*verificationEntry)
+	if now.After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+
+	c.stats.Hits++
+	if entry.result.bad() {
+		c.stats.KnownBad++
+	}
+
+	return entry.result, true
+}
+
+func (c *verificationCache) put(key [sha256.Size]byte, result *verificationResult, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		c.order.Remove(element)
+	}
+
+	for c.order.Len() >= c.size {
+		evicted := c.order.Remove(c.order.Back()).(*verificationEntry)
+		delete(c.entries, evicted.key)
+	}
+
+	c.entries[key] = c.order.PushFront(&verificationEntry{key: key, result: result, expires: now.Add(c.ttl)})
+}
+
+// compact drops the results older than ttl, examining at most budget of
+// them, least recently used first.
+func (c *verificationCache) compact(now time.Time, budget int) compaction {
+	c.Lock()
+	defer c.Unlock()
+
+	var result compaction
+
+	before := len(c.entries)
+	for element := c.order.Back(); element != nil && result.examined < budget; {
+		previous := element.Prev()
+		result.examined++
+
+		if entry := element.Value.(*verificationEntry); now.After(entry.expires) {
+			c.order.Remove(element)
+			delete(c.entries, entry.key)
+			result.reclaimed++
+		}
+		element = previous
+	}
+	result.live = len(c.entries)
+
+	if c.peak.sparse(before, result.live, budget-result.examined) {
+		c.entries = rebuildMap(c.entries)
+		result.examined += result.live
+		result.rebuilds++
+	}
+
+	return result
+}
+
+// writeField writes a length-prefixed field to a hash, so that no two
+// distinct sets of fields hash the same.
+func writeField(h hash.Hash, field []byte) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(field)))
+	h.Write(size[:])
+	h.Write(field)
+}
+
+// verificationKey hashes everything the signatures of a message cover,
+// alongside the signatures and the public keys they were made with.
+func verificationKey(msg *protobuf.Message, payload []byte) [sha256.Size]byte {
+	h := sha256.New()
+
+	writeField(h, payload)
+	writeField(h, msg.Sender.PublicKey)
+	writeField(h, msg.Signature)
+
+	for _, signature := range msg.Signatures {
+		writeField(h, []byte(signature.Scheme))
+		writeField(h, signature.PublicKey)
+		writeField(h, signature.Signature)
+	}
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// checkSignatures checks the signatures of a message, skipping the checks
+// should the exact same message have been checked recently. Messages of
+// types set by VerifyAlways are always checked.
+func (n *Network) checkSignatures(msg *protobuf.Message) *verificationResult {
+	payload := signingPreimage(msg)
+
+	if n.verifications == nil || n.verifyAlways(msg) {
+		return n.verifySignatures(msg, payload)
+	}
+
+	key := verificationKey(msg, payload)
+	now := n.now()
+
+	if result, cached := n.verifications.get(key, now); cached {
+		if result.bad() {
+			glog.Warningf("received a copy of a message which failed verification before [sender=%s]", msg.Sender.Address)
+		}
+		return result
+	}
+
+	result := n.verifySignatures(msg, payload)
+	n.verifications.put(key, result, now)
+
+	return result
+}
+
+func (n *Network) verifyAlways(msg *protobuf.Message) bool {
+	if len(n.opts.verifyAlways) == 0 {
+		return false
+	}
+
+	name, err := types.AnyMessageName(msg.Message)
+	if err != nil {
+		return true
+	}
+
+	_, always := n.opts.verifyAlways[name]
+	return always
+}
+
+// VerificationStats returns how often signature checks were skipped for
+// messages received before, being the zero value should the verification
+// cache be disabled.
+func (n *Network) VerificationStats() VerificationStats {
+	if n.verifications == nil {
+		return VerificationStats{}
+	}
+
+	n.verifications.Lock()
+	defer n.verifications.Unlock()
+
+	return n.verifications.stats
+}
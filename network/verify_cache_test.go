@@ -0,0 +1,161 @@
+package network
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationCacheSkipsDuplicates(t *testing.T) {
+	t.Parallel()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: "gossip"})
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, node.verifyMessage(msg))
+	}
+
+	assert.Equal(t, VerificationStats{Hits: 9, Misses: 1}, node.VerificationStats())
+}
+
+func TestVerificationCacheRejectsTamperedCopies(t *testing.T) {
+	t.Parallel()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	node := buildListeningNode(t)
+	defer node.Close()
+
+	msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: "gossip"})
+	assert.Nil(t, err)
+	assert.True(t, node.verifyMessage(msg))
+
+	// Copies carrying the same sender and signature, but a different payload
+	// or metadata, must not hit the cached result.
+	payload := *msg.Message
+	tampered := *msg
+	tampered.Message = &payload
+	tampered.Message.Value = append([]byte(nil), msg.Message.Value...)
+	tampered.Message.Value[len(tampered.Message.Value)-1] ^= 0xff
+	assert.False(t, node.verifyMessage(&tampered))
+
+	relabeled := *msg
+	relabeled.Metadata = map[string]string{"trace": "forged"}
+	assert.False(t, node.verifyMessage(&relabeled))
+
+	assert.Equal(t, VerificationStats{Misses: 3}, node.VerificationStats())
+
+	// Known-bad copies are rejected without being checked again.
+	assert.False(t, node.verifyMessage(&tampered))
+	assert.Equal(t, VerificationStats{Hits: 1, Misses: 3, KnownBad: 1}, node.VerificationStats())
+
+	assert.True(t, node.verifyMessage(msg))
+}
+
+func TestVerificationCacheBounds(t *testing.T) {
+	t.Parallel()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	node := buildListeningNode(t, VerificationCache(2, time.Minute))
+	defer node.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	node.now = clock.Now
+
+	messages := make([]*testpb.TestMessage, 3)
+	for i := range messages {
+		messages[i] = &testpb.TestMessage{Message: fmt.Sprint(i)}
+	}
+
+	verify := func(i int) {
+		msg, err := sender.PrepareMessage(messages[i])
+		assert.Nil(t, err)
+		assert.True(t, node.verifyMessage(msg))
+	}
+
+	verify(0)
+	verify(1)
+	verify(2) // Evicts the first message.
+	verify(0)
+	assert.Equal(t, uint64(4), node.VerificationStats().Misses)
+
+	verify(0)
+	assert.Equal(t, uint64(1), node.VerificationStats().Hits)
+
+	clock.Advance(time.Minute + time.Second)
+
+	verify(0)
+	assert.Equal(t, uint64(5), node.VerificationStats().Misses, "expired entries should be checked again")
+}
+
+func TestVerifyAlwaysBypassesCache(t *testing.T) {
+	t.Parallel()
+
+	sender := buildListeningNode(t)
+	defer sender.Close()
+
+	node := buildListeningNode(t, VerifyAlways(&testpb.TestMessage{}))
+	defer node.Close()
+
+	msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: "transfer"})
+	assert.Nil(t, err)
+
+	assert.True(t, node.verifyMessage(msg))
+	assert.True(t, node.verifyMessage(msg))
+	assert.Equal(t, VerificationStats{}, node.VerificationStats())
+
+	disabled := buildListeningNode(t, VerificationCache(0, 0))
+	defer disabled.Close()
+
+	assert.True(t, disabled.verifyMessage(msg))
+	assert.Equal(t, VerificationStats{}, disabled.VerificationStats())
+}
+
+// benchmarkVerifyDuplicates verifies every message as received from each of
+// 10 neighbors.
+func benchmarkVerifyDuplicates(b *testing.B, opts ...BuilderOption) {
+	const numNeighbors = 10
+
+	sender := buildListeningNode(b)
+	defer sender.Close()
+
+	node := buildListeningNode(b, opts...)
+	defer node.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		msg, err := sender.PrepareMessage(&testpb.TestMessage{Message: fmt.Sprint(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		for j := 0; j < numNeighbors; j++ {
+			if !node.verifyMessage(msg) {
+				b.Fatal("message failed verification")
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyDuplicatesCached(b *testing.B) {
+	benchmarkVerifyDuplicates(b)
+}
+
+func BenchmarkVerifyDuplicatesUncached(b *testing.B) {
+	benchmarkVerifyDuplicates(b, VerificationCache(0, 0))
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Warm dials and completes handshakes with peers in the background, so that
+// the first write to them does not pay for it inline. Warmed peers count
+// toward the peer quotas like any other, and failures are reported through
+// the OnDialFailed callback. Peers already connected or being dialed are
+// skipped. Cancelling ctx stops peers yet to be dialed from being dialed.
+func (n *Network) Warm(ctx context.Context, addresses ...string) {
+	for _, address := range addresses {
+		address := address
+		n.spawn(func() { n.warm(ctx, address) })
+	}
+}
+
+// WarmAll is equivalent to Warm, though it blocks until all peers were dialed
+// and returns an error should any of them fail.
+func (n *Network) WarmAll(ctx context.Context, addresses ...string) error {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	var first error
+	failed := 0
+
+	for _, address := range addresses {
+		wg.Add(1)
+		address := address
+		n.spawn(func() {
+			defer wg.Done()
+
+			if err := n.warm(ctx, address); err != nil {
+				mutex.Lock()
+				if first == nil {
+					first = err
+				}
+				failed++
+				mutex.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	if first != nil {
+		return errors.Wrapf(first, "failed to warm up %d of %d peers", failed, len(addresses))
+	}
+	return nil
+}
+
+func (n *Network) warm(ctx context.Context, address string) error {
+	unified, err := ToUnifiedAddress(address)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		n.dialFailed(address, err)
+		return err
+	}
+
+	if _, exists := n.peers.Load(unified); exists {
+		return nil
+	}
+
+	// Failures to dial are reported by client itself.
+	_, err = n.Client(unified)
+	return err
+}
+
+// dialFailed reports a failure to connect to a peer.
+func (n *Network) dialFailed(address string, err error) {
+	if n.opts.onDialFailed != nil {
+		n.opts.onDialFailed(address, err)
+	}
+
+	n.publishEvent(&DialFailedEvent{EventHeader: n.eventHeader(PeerID{}, address), Err: err})
+}
+
+// acquireDial blocks until fewer than the maximum number of concurrent dials
+// are in progress, returning false should the network shut down meanwhile.
+func (n *Network) acquireDial() bool {
+	if n.dialSlots == nil {
+		return true
+	}
+
+	select {
+	case n.dialSlots <- struct{}{}:
+		return true
+	case <-n.kill:
+		return false
+	}
+}
+
+func (n *Network) releaseDial() {
+	if n.dialSlots != nil {
+		<-n.dialSlots
+	}
+}
@@ -0,0 +1,115 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const dialLatency = 300 * time.Millisecond
+
+// firstWrite times connecting to a peer and sending it its first message.
+func firstWrite(t *testing.T, node *Network, address string) time.Duration {
+	start := time.Now()
+
+	client, err := node.Client(address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "hello"}))
+
+	return time.Since(start)
+}
+
+func TestWarmReducesFirstWriteLatency(t *testing.T) {
+	t.Parallel()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	cold := buildNodeOverLink(t, &slowLink{TCP: transport.NewTCP(), rate: 1e+8, latency: dialLatency})
+	defer cold.Close()
+
+	warm := buildNodeOverLink(t, &slowLink{TCP: transport.NewTCP(), rate: 1e+8, latency: dialLatency}, WarmUpPeers(receiver.Address))
+	defer warm.Close()
+
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		_, ok := warm.ConnectionState(receiver.Address)
+		return ok
+	}), "peer should have been warmed up at startup")
+
+	coldLatency := firstWrite(t, cold, receiver.Address)
+	warmLatency := firstWrite(t, warm, receiver.Address)
+
+	assert.True(t, coldLatency >= dialLatency)
+	assert.True(t, warmLatency < coldLatency/4, "first write took %s warmed up, and %s otherwise", warmLatency, coldLatency)
+}
+
+func TestWarmAllReportsFailures(t *testing.T) {
+	t.Parallel()
+
+	var failed []string
+	var failedMutex sync.Mutex
+
+	node := buildListeningNode(t, MaxOutboundPeers(1), OnDialFailed(func(address string, err error) {
+		failedMutex.Lock()
+		failed = append(failed, address)
+		failedMutex.Unlock()
+	}))
+	defer node.Close()
+
+	receiver := buildListeningNode(t)
+	defer receiver.Close()
+
+	unreachable := FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort()))
+
+	// Warm does not return failures, but reports them.
+	node.Warm(context.Background(), unreachable)
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		failedMutex.Lock()
+		defer failedMutex.Unlock()
+		return len(failed) == 1
+	}))
+
+	// Warmed peers count toward the peer quotas.
+	assert.Nil(t, node.WarmAll(context.Background(), receiver.Address))
+	assert.Equal(t, 1, countPeers(node, DirectionOutbound))
+
+	other := buildListeningNode(t)
+	defer other.Close()
+
+	assert.NotNil(t, node.WarmAll(context.Background(), other.Address, unreachable))
+	assert.Equal(t, 1, countPeers(node, DirectionOutbound))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, errors.Cause(node.WarmAll(ctx, other.Address)))
+
+	failedMutex.Lock()
+	defer failedMutex.Unlock()
+	assert.Equal(t, 4, len(failed))
+}
+
+func TestMaxConcurrentDials(t *testing.T) {
+	t.Parallel()
+
+	var addresses []string
+	for i := 0; i < 3; i++ {
+		receiver := buildListeningNode(t)
+		defer receiver.Close()
+
+		addresses = append(addresses, receiver.Address)
+	}
+
+	node := buildNodeOverLink(t, &slowLink{TCP: transport.NewTCP(), rate: 1e+8, latency: dialLatency}, MaxConcurrentDials(1))
+	defer node.Close()
+
+	start := time.Now()
+	assert.Nil(t, node.WarmAll(context.Background(), addresses...))
+	assert.True(t, time.Since(start) >= 3*dialLatency, "dials should have been made one at a time")
+	assert.Equal(t, 3, countPeers(node, DirectionOutbound))
+}
@@ -0,0 +1,235 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// DisconnectStalled is the reason peers are disconnected from should the
+// watchdog find one of their loops stuck.
+const DisconnectStalled = "stalled"
+
+// Components the watchdog watches the loops of.
+const (
+	// ComponentSend is a peer's send loop.
+	ComponentSend = "send"
+	// ComponentReceive is the loop reading a peer's connection.
+	ComponentReceive = "receive"
+	// ComponentDispatch is a worker of the dispatch pool.
+	ComponentDispatch = "dispatch"
+	// ComponentPipeline is a worker of the send pipeline.
+	ComponentPipeline = "pipeline"
+	// ComponentFlush is the loop flushing out buffered writes.
+	ComponentFlush = "flush"
+	// ComponentKeepalive is the reaper, which schedules keepalives.
+	ComponentKeepalive = "keepalive"
+)
+
+// NetworkStall describes a loop the watchdog found stuck.
+type NetworkStall struct {
+	// Component is the kind of loop which got stuck, such as ComponentSend.
+	Component string
+	// Address is the address of the peer the loop serves, being empty for
+	// loops serving the whole network.
+	Address string
+	// Stalled is how long the loop had been stuck for.
+	Stalled time.Duration
+	// Stack is the stack trace of the goroutine running the loop.
+	Stack string
+	// Recovered is true should the loop have been torn down, alongside every
+	// other loop of its peer, and its peer been dialed anew.
+	Recovered bool
+}
+
+// heartbeat is touched by a long-lived loop whenever it takes on work, and
+// reset once it is done, so that the watchdog may tell loops stuck on a piece
+// of work apart from loops idly waiting on more.
+type heartbeat struct {
+	busy int64 // for atomic ops
+
+	component string
+	address   string
+	goroutine string
+
+	// conn is closed to recover the loop, if set.
+	conn net.Conn
+
+	// reported is the time the loop got busy at when it was last reported
+	// stuck, only ever accessed by the watchdog.
+	reported int64
+}
+
+// beat marks the loop busy as of now. It is a no-op on a nil heartbeat.
+func (h *heartbeat) beat() {
+	if h != nil {
+		atomic.StoreInt64(&h.busy, time.Now().UnixNano())
+	}
+}
+
+// idle marks the loop idle. It is a no-op on a nil heartbeat.
+func (h *heartbeat) idle() {
+	if h != nil {
+		atomic.StoreInt64(&h.busy, 0)
+	}
+}
+
+// watchdog keeps track of the heartbeats of the loops it watches. A nil
+// watchdog watches nothing.
+type watchdog struct {
+	sync.Mutex
+	beats map[*heartbeat]struct{}
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{beats: make(map[*heartbeat]struct{})}
+}
+
+// watch registers the heartbeat of a loop run by the calling goroutine,
+// serving the peer at an address should it be set. Watching the loop of a
+// peer recovers it once stuck, by closing conn should it be set.
+func (w *watchdog) watch(component string, address string, conn net.Conn) *heartbeat {
+	if w == nil {
+		return nil
+	}
+
+	h := &heartbeat{component: component, address: address, goroutine: currentGoroutine(), conn: conn}
+
+	w.Lock()
+	w.beats[h] = struct{}{}
+	w.Unlock()
+
+	return h
+}
+
+// unwatch stops watching the loop of a heartbeat, once the loop ends.
+func (w *watchdog) unwatch(h *heartbeat) {
+	if w == nil || h == nil {
+		return
+	}
+
+	w.Lock()
+	delete(w.beats, h)
+	w.Unlock()
+}
+
+// stuck returns the heartbeats of loops which have been busy for at least
+// a threshold as of now, and which were not reported stuck yet.
+func (w *watchdog) stuck(now time.Time, threshold time.Duration) []*heartbeat {
+	w.Lock()
+	defer w.Unlock()
+
+	var stuck []*heartbeat
+	for h := range w.beats {
+		busy := atomic.LoadInt64(&h.busy)
+		if busy == 0 || busy == h.reported || now.Sub(time.Unix(0, busy)) < threshold {
+			continue
+		}
+
+		h.reported = busy
+		stuck = append(stuck, h)
+	}
+	return stuck
+}
+
+// watchdogLoop periodically looks for stuck loops until the network is
+// closed.
+func (n *Network) watchdogLoop() {
+	t := time.NewTicker(n.opts.watchdogThreshold / 4)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-n.kill:
+			return
+		case <-t.C:
+			for _, h := range n.watchdog.stuck(time.Now(), n.opts.watchdogThreshold) {
+				n.stalled(h)
+			}
+		}
+	}
+}
+
+// stalled reports a stuck loop, and recovers it should it serve a single
+// peer.
+func (n *Network) stalled(h *heartbeat) {
+	stall := NetworkStall{
+		Component: h.component,
+		Address:   h.address,
+		Stalled:   time.Since(time.Unix(0, atomic.LoadInt64(&h.busy))),
+		Stack:     goroutineStack(h.goroutine),
+	}
+
+	glog.Errorf("network: %s loop stuck for %s [address=%s]\n%s", stall.Component, stall.Stalled, stall.Address, stall.Stack)
+
+	if len(h.address) > 0 {
+		n.recoverPeer(h)
+		stall.Recovered = true
+	}
+
+	if n.opts.onNetworkStalled != nil {
+		n.opts.onNetworkStalled(stall)
+	}
+}
+
+// recoverPeer tears down every loop of the peer a stuck loop serves, and
+// dials the peer anew. The stuck goroutine is left to exit by itself, should
+// it ever become unstuck. Ephemeral peers may not be dialed, and are left to
+// dial us anew.
+func (n *Network) recoverPeer(h *heartbeat) {
+	if client, exists := n.peers.Load(h.address); exists {
+		client.(*PeerClient).close(DisconnectStalled)
+	}
+	if h.conn != nil {
+		h.conn.Close()
+	}
+
+	if strings.HasPrefix(h.address, ephemeralScheme) || n.isClosed() {
+		return
+	}
+
+	n.spawn(func() {
+		if _, err := n.Client(h.address); err != nil {
+			glog.Warningf("failed to reconnect to %s after its %s loop got stuck: %v", h.address, h.component, err)
+		}
+	})
+}
+
+// currentGoroutine returns the header the stack trace of the calling
+// goroutine starts with, such as "goroutine 42 ".
+func currentGoroutine() string {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+
+	if i := bytes.IndexByte(header, '['); i > 0 {
+		return string(header[:i])
+	}
+	return string(header)
+}
+
+// goroutineStack returns the stack trace of the goroutine whose stack trace
+// starts with a header, filtered out of the stack traces of all goroutines.
+func goroutineStack(header string) string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if strings.HasPrefix(stack, header) {
+			return stack
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package network
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perlin-network/noise/crypto/ed25519"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogRecoversStuckPeerLoops(t *testing.T) {
+	t.Parallel()
+
+	var stuckReceived, healthyReceived int32
+	stuck := buildCountingNode(t, &stuckReceived)
+	defer stuck.Close()
+	healthy := buildCountingNode(t, &healthyReceived)
+	defer healthy.Close()
+
+	stalls := make(chan NetworkStall, 4)
+
+	builder := NewBuilderWithOptions(Watchdog(200*time.Millisecond), OnNetworkStalled(func(stall NetworkStall) {
+		stalls <- stall
+	}))
+	builder.SetKeys(ed25519.RandomKeyPair())
+	builder.SetAddress(FormatAddress("tcp", "localhost", uint16(GetRandomUnusedPort())))
+
+	// Messages marked as stalling hold up the send loop of the stuck peer.
+	release := make(chan struct{})
+	defer close(release)
+
+	builder.AddOutboundHook(func(peer PeerInfo, msg *Envelope) error {
+		if peer.Address == stuck.Address && bytes.Contains(msg.Message.Value, []byte("stall")) {
+			<-release
+		}
+		return nil
+	})
+
+	node, err := builder.Build()
+	assert.Nil(t, err)
+	defer node.Close()
+
+	go node.Listen()
+	<-node.Ready()
+
+	_, err = node.Client(stuck.Address)
+	assert.Nil(t, err)
+	_, err = node.Client(healthy.Address)
+	assert.Nil(t, err)
+
+	stuckState, _ := node.ConnectionState(stuck.Address)
+	healthyState, _ := node.ConnectionState(healthy.Address)
+
+	_, err = node.WriteAsync(stuck.Address, signedTestMessage(t, node, "stall"))
+	assert.Nil(t, err)
+
+	select {
+	case stall := <-stalls:
+		assert.Equal(t, ComponentSend, stall.Component)
+		assert.Equal(t, stuck.Address, stall.Address)
+		assert.True(t, stall.Stalled >= 200*time.Millisecond)
+		assert.True(t, stall.Recovered)
+		assert.True(t, strings.Contains(stall.Stack, "sendLoop"), "stack trace lacks the send loop:\n%s", stall.Stack)
+	case <-time.After(3 * time.Second):
+		t.Fatal("stuck send loop was never detected")
+	}
+
+	// The stuck peer is reconnected to afresh, and written to as usual.
+	assert.True(t, waitUntil(3*time.Second, func() bool {
+		state, connected := node.ConnectionState(stuck.Address)
+		return connected && state != stuckState
+	}), "stuck peer was never reconnected to")
+
+	assert.Nil(t, node.Write(stuck.Address, signedTestMessage(t, node, "recovered")))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return atomic.LoadInt32(&stuckReceived) >= 1 }))
+
+	// Whereas the healthy peer was never disturbed.
+	state, connected := node.ConnectionState(healthy.Address)
+	assert.True(t, connected)
+	assert.True(t, state == healthyState)
+
+	assert.Nil(t, node.Write(healthy.Address, signedTestMessage(t, node, "undisturbed")))
+	assert.True(t, waitUntil(3*time.Second, func() bool { return atomic.LoadInt32(&healthyReceived) == 1 }))
+
+	select {
+	case stall := <-stalls:
+		t.Fatalf("unexpected stall of %s loop", stall.Component)
+	default:
+	}
+}
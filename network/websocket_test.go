@@ -0,0 +1,115 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	testpb "github.com/perlin-network/noise/internal/test/protobuf"
+	"github.com/perlin-network/noise/network/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveConnectProxy serves an HTTP proxy which only tunnels connections
+// through CONNECT, counting the tunnels it opened.
+func serveConnectProxy(t *testing.T, tunnels *uint32) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				req, err := http.ReadRequest(reader)
+				if err != nil || req.Method != http.MethodConnect {
+					io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+					return
+				}
+
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+
+				atomic.AddUint32(tunnels, 1)
+				io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+
+				go io.Copy(target, reader)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return listener
+}
+
+func TestWebSocketThroughProxy(t *testing.T) {
+	t.Parallel()
+
+	var tunnels uint32
+	proxy := serveConnectProxy(t, &tunnels)
+	defer proxy.Close()
+
+	received := make(chan string, 1)
+
+	builder := NewBuilder()
+	builder.SetAddress(FormatAddress("ws", "localhost", uint16(GetRandomUnusedPort())))
+	builder.AddPlugin(&handlerPlugin{fn: func(ctx *PluginContext) {
+		if msg, ok := ctx.Message().(*testpb.TestMessage); ok {
+			received <- msg.Message
+		}
+	}})
+	receiver, err := builder.Build()
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	go receiver.Listen()
+	<-receiver.Ready()
+
+	layer := transport.NewWebSocket()
+	layer.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: proxy.Addr().String()})
+
+	builder = NewBuilder()
+	builder.SetAddress(FormatAddress("ws", "localhost", uint16(GetRandomUnusedPort())))
+	builder.RegisterTransportLayer("ws", layer)
+	sender, err := builder.Build()
+	assert.Nil(t, err)
+	defer sender.Close()
+
+	go sender.Listen()
+	<-sender.Ready()
+
+	client, err := sender.Client(receiver.Address)
+	assert.Nil(t, err)
+	assert.Nil(t, client.Tell(&testpb.TestMessage{Message: "tunneled"}))
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "tunneled", message)
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was never received through the proxy")
+	}
+
+	assert.True(t, atomic.LoadUint32(&tunnels) > 0)
+
+	// Listeners only upgrade WebSocket requests.
+	res, err := http.Get("http://" + receiver.Address[len("ws://"):])
+	if assert.Nil(t, err) {
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+}